@@ -814,6 +814,9 @@ static error_fixture_t error_fixtures[] = {
     {"array_multiline_invalid_nested_space", "array-multiline-invalid-nested-space.nay", "- -  a\n  - b\n", 13, "Unexpected space after \"-\" at 1:3 of <array-multiline-invalid-nested-space.nay>"},
     {"array_multiline_invalid_trailing_space", "array-multiline-invalid-trailing-space.nay", "- \n  1\n", 7, "Unexpected trailing space at 1:2 of <array-multiline-invalid-trailing-space.nay>"},
     {"array_multiline_nested_invalid_bare_word", "array-multiline-nested-invalid-bare-word.nay", "- - a\n  - b\n- - 1\n  - 2\n", 24, "Unexpected character \"a\" at 1:3 of <array-multiline-nested-invalid-bare-word.nay>"},
+    {"array_multiline_nested_invalid_colon_array", "array-multiline-nested-invalid-colon-array.nay", "- - :[42, 42]\n", 14, "Unexpected character \":\""},
+    {"array_multiline_nested_invalid_colon_space", "array-multiline-nested-invalid-colon-space.nay", "- - : 1\n", 8, "Unexpected character \":\""},
+    {"array_multiline_nested_invalid_colon", "array-multiline-nested-invalid-colon.nay", "- - :1\n", 7, "Unexpected character \":\""},
     {"blank_line_trailing_space", "blank-line-trailing-space.nay", "   \n", 4, "Unexpected trailing space at 1:3 of <blank-line-trailing-space.nay>"},
     {"bytearray_block_invalid_empty_leader", "bytearray-block-invalid-empty-leader.nay", ">\n  b0b5\n", 9, "Expected hex or comment in hex block"},
     {"bytearray_block_property_invalid_leader_next_line", "bytearray-block-property-invalid-leader-next-line.nay", "i:\n  >\n    cafe\n    babe\n", 25, "Unexpected indent at 2:1 of <bytearray-block-property-invalid-leader-next-line.nay>"},
@@ -855,6 +858,11 @@ static error_fixture_t error_fixtures[] = {
     {"object_multiline_invalid_quoted_key_space_before_colon_single", "object-multiline-invalid-quoted-key-space-before-colon-single.nay", "'key' : 1\n", 10, "Unexpected space before \":\" at 1:6 of <object-multiline-invalid-quoted-key-space-before-colon-single.nay>"},
     {"object_multiline_invalid_quoted_key_space_before_colon", "object-multiline-invalid-quoted-key-space-before-colon.nay", "\"key\" : 1\n", 10, "Unexpected space before \":\" at 1:6 of <object-multiline-invalid-quoted-key-space-before-colon.nay>"},
     {"object_multiline_invalid_value_space", "object-multiline-invalid-value-space.nay", "key:  1\n", 8, "Unexpected space after \":\" at 1:6 of <object-multiline-invalid-value-space.nay>"},
+    {"object_multiline_nested_invalid_dedented_item_between", "object-multiline-nested-invalid-dedented-item-between.nay", "a:\n  b: 1\n- 2\nc: 3\n", 19, "Unexpected indent at 3:1 of <object-multiline-nested-invalid-dedented-item-between.nay>"},
+    {"object_multiline_nested_invalid_dedented_item_value", "object-multiline-nested-invalid-dedented-item-value.nay", "outer:\n  inner:\n- 42\n", 21, "Expected value after property at 2:9 of <object-multiline-nested-invalid-dedented-item-value.nay>"},
+    {"object_multiline_nested_invalid_dedented_item", "object-multiline-nested-invalid-dedented-item.nay", "a:\n  x: 1\n- 2\n", 14, "Unexpected indent at 3:1 of <object-multiline-nested-invalid-dedented-item.nay>"},
+    {"object_multiline_nested_invalid_key_comment", "object-multiline-nested-invalid-key-comment.nay", "a:\n  b:\n    - 1\n  #- c: 3\n", 26, "Invalid key at 4:3 of <object-multiline-nested-invalid-key-comment.nay>"},
+    {"object_multiline_nested_invalid_missing_key", "object-multiline-nested-invalid-missing-key.nay", "items:\n  : 1\n - 42\n", 19, "Missing key at 2:3 of <object-multiline-nested-invalid-missing-key.nay>"},
     {"string_block_invalid_empty", "string-block-invalid-empty.nay", "name: `\n`\n", 10, "Empty block string not allowed (use \"\" or \"\\n\" explicitly)"},
     {"string_block_invalid_tab", "string-block-invalid-tab.nay", "message: `\n  hello\011world\n", 25, "Tab not allowed (use spaces) at 2:8 of <string-block-invalid-tab.nay>"},
     {"string_block_invalid_trailing_space", "string-block-invalid-trailing-space.nay", "message: `\n  hello world \n", 26, "Unexpected trailing space at 2:14 of <string-block-invalid-trailing-space.nay>"},
@@ -896,6 +904,6 @@ static error_fixture_t error_fixtures[] = {
     {NULL, NULL, NULL, 0, NULL}
 };
 
-#define ERROR_FIXTURE_COUNT 91
+#define ERROR_FIXTURE_COUNT 99
 
 #endif /* FIXTURES_GEN_H */
@@ -0,0 +1,102 @@
+package yay
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ============================================================================
+// Typed Accessors
+// ============================================================================
+//
+// The As functions take a value Unmarshal produces, such as one Get
+// returns, and give it back as the Go type the caller wants, or an error
+// saying what was found instead. They accept the Go types Marshal accepts
+// as well, so they serve for values built by hand.
+
+// AsString returns v if it is a string.
+func AsString(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("Expected a string, not %s", describeValue(v))
+}
+
+// AsBool returns v if it is a boolean.
+func AsBool(v any) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("Expected a boolean, not %s", describeValue(v))
+}
+
+// AsBytes returns v if it is a byte array.
+func AsBytes(v any) ([]byte, error) {
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("Expected a byte array, not %s", describeValue(v))
+}
+
+// AsInt64 returns v as an int64 if it is an integer in the range of one.
+// Floats are not integers, even when integral.
+func AsInt64(v any) (int64, error) {
+	n, ok := asInteger(v)
+	if !ok {
+		return 0, fmt.Errorf("Expected an integer, not %s", describeValue(v))
+	}
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("Integer %s overflows int64", n)
+	}
+	return n.Int64(), nil
+}
+
+// AsFloat64 returns v as a float64 if it is a float, or an integer that a
+// float64 represents exactly.
+func AsFloat64(v any) (float64, error) {
+	switch f := v.(type) {
+	case float64:
+		return f, nil
+	case float32:
+		return float64(f), nil
+	}
+	n, ok := asInteger(v)
+	if !ok {
+		return 0, fmt.Errorf("Expected a number, not %s", describeValue(v))
+	}
+	f, accuracy := new(big.Float).SetInt(n).Float64()
+	if accuracy != big.Exact {
+		return 0, fmt.Errorf("Integer %s is not exactly a float64", n)
+	}
+	return f, nil
+}
+
+// asInteger returns v as a *big.Int if it is an integer of any of the Go
+// types Marshal accepts.
+func asInteger(v any) (*big.Int, bool) {
+	switch n := v.(type) {
+	case *big.Int:
+		return n, n != nil
+	case int:
+		return big.NewInt(int64(n)), true
+	case int8:
+		return big.NewInt(int64(n)), true
+	case int16:
+		return big.NewInt(int64(n)), true
+	case int32:
+		return big.NewInt(int64(n)), true
+	case int64:
+		return big.NewInt(n), true
+	case uint:
+		return new(big.Int).SetUint64(uint64(n)), true
+	case uint8:
+		return new(big.Int).SetUint64(uint64(n)), true
+	case uint16:
+		return new(big.Int).SetUint64(uint64(n)), true
+	case uint32:
+		return new(big.Int).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Int).SetUint64(n), true
+	}
+	return nil, false
+}
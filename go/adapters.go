@@ -0,0 +1,170 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// ============================================================================
+// Standard Library Adapters
+// ============================================================================
+//
+// The value types of the standard library that turn up most in
+// configuration have built-in codecs, so that they need neither wrappers
+// nor codecs of their own. Each is written as a string in its usual
+// notation:
+//
+//   - time.Time in RFC 3339, with as many fractional digits as it needs.
+//   - time.Duration as time.Duration.String and time.ParseDuration spell
+//     it, such as "1m30s".
+//   - net.IP and netip.Addr, netip.Prefix, and netip.AddrPort, as their
+//     String methods give them. The zero netip values are "".
+//   - url.URL and *url.URL as the URL.
+//   - regexp.Regexp and *regexp.Regexp as the source of the expression.
+//
+// A nil pointer or net.IP is written as null, and null decodes to one.
+// Codecs added to a Codecs set or with RegisterCodec take precedence over
+// these.
+//
+// Builds with the yay_tiny tag leave the adapters out, along with the
+// packages they need; see tiny.go.
+
+// builtinCodecs holds the codecs for standard library types. It is filled
+// once, before use, and never changed, so it is read without locking.
+var builtinCodecs = func() map[reflect.Type]codec {
+	var c Codecs
+	addTextCodec[time.Time](&c)
+	AddCodec(&c,
+		func(d time.Duration) (any, error) { return d.String(), nil },
+		func(v any) (time.Duration, error) {
+			s, err := AsString(v)
+			if err != nil {
+				return 0, err
+			}
+			return time.ParseDuration(s)
+		})
+	AddCodec(&c,
+		func(ip net.IP) (any, error) {
+			if ip == nil {
+				return nil, nil
+			}
+			return ip.String(), nil
+		},
+		func(v any) (net.IP, error) {
+			if v == nil {
+				return nil, nil
+			}
+			s, err := AsString(v)
+			if err != nil {
+				return nil, err
+			}
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid IP address %q", s)
+			}
+			return ip, nil
+		})
+	addTextCodec[netip.Addr](&c)
+	addTextCodec[netip.Prefix](&c)
+	addTextCodec[netip.AddrPort](&c)
+	addPointerCodec(&c, (*url.URL).String, url.Parse)
+	addPointerCodec(&c, (*regexp.Regexp).String, regexp.Compile)
+	AddCodec(&c,
+		func(u url.URL) (any, error) { return u.String(), nil },
+		func(v any) (url.URL, error) {
+			s, err := AsString(v)
+			if err != nil {
+				return url.URL{}, err
+			}
+			u, err := url.Parse(s)
+			if err != nil {
+				return url.URL{}, err
+			}
+			return *u, nil
+		})
+	AddCodec(&c,
+		func(re regexp.Regexp) (any, error) { return re.String(), nil },
+		func(v any) (regexp.Regexp, error) {
+			s, err := AsString(v)
+			if err != nil {
+				return regexp.Regexp{}, err
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return regexp.Regexp{}, err
+			}
+			return *re, nil
+		})
+	return c.byType
+}()
+
+// builtinFormats gives the "format" of the schemas for the types with
+// built-in codecs whose strings have one.
+var builtinFormats = map[reflect.Type]string{
+	reflect.TypeOf(time.Time{}):           "date-time",
+	reflect.TypeOf(url.URL{}):             "uri-reference",
+	reflect.TypeOf((*url.URL)(nil)):       "uri-reference",
+	reflect.TypeOf(regexp.Regexp{}):       "regex",
+	reflect.TypeOf((*regexp.Regexp)(nil)): "regex",
+}
+
+// compilePattern compiles the expression of a pattern constraint.
+func compilePattern(expr string) (matcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// addTextCodec adds to c the codec for T written as the text its
+// MarshalText method gives and read by its UnmarshalText method.
+func addTextCodec[T any, P interface {
+	*T
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}](c *Codecs) {
+	AddCodec(c,
+		func(v T) (any, error) {
+			text, err := P(&v).MarshalText()
+			return string(text), err
+		},
+		func(v any) (T, error) {
+			var t T
+			s, err := AsString(v)
+			if err == nil {
+				err = P(&t).UnmarshalText([]byte(s))
+			}
+			return t, err
+		})
+}
+
+// addPointerCodec adds to c the codec for *T written as the string format
+// gives and read by parse. A nil *T is null.
+func addPointerCodec[T any](c *Codecs, format func(*T) string, parse func(string) (*T, error)) {
+	AddCodec(c,
+		func(v *T) (any, error) {
+			if v == nil {
+				return nil, nil
+			}
+			return format(v), nil
+		},
+		func(v any) (*T, error) {
+			if v == nil {
+				return nil, nil
+			}
+			s, err := AsString(v)
+			if err != nil {
+				return nil, err
+			}
+			return parse(s)
+		})
+}
@@ -0,0 +1,126 @@
+package yay
+
+import (
+	"reflect"
+	"testing"
+)
+
+// allocBudgets are per-call allocation ceilings for the benchmark corpus.
+// Each is the count measured when the budget was last set, plus a little
+// headroom for pooled scratch space dropped by a garbage collection during
+// the run. A change that fails here should either win the allocations back
+// or lower the ceilings it beats and raise, with a reason, the ones it
+// does not.
+var allocBudgets = map[string]struct {
+	unmarshal, batch, valid, marshal float64
+}{
+	"scalars":      {2020, 525, 10, 1},
+	"strings":      {2020, 1025, 10, 2},
+	"blockstrings": {80, 80, 80, 3},
+	"bytes":        {145, 85, 80, 2},
+	"deep":         {425, 425, 420, 205},
+	"wide":         {6030, 40, 20, 2},
+}
+
+func TestAllocBudgets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation counts are slow to measure")
+	}
+	if raceEnabled {
+		t.Skip("allocation counts are not stable under the race detector")
+	}
+	for _, doc := range benchDocs() {
+		doc := doc
+		budget, ok := allocBudgets[doc.name]
+		if !ok {
+			t.Errorf("%s: no allocation budget", doc.name)
+			continue
+		}
+		value, err := Unmarshal(doc.yay)
+		if err != nil {
+			t.Fatalf("%s: %v", doc.name, err)
+		}
+
+		check := func(op string, limit float64, f func()) {
+			if got := testing.AllocsPerRun(10, f); got > limit {
+				t.Errorf("%s/%s: %v allocations, budget %v", doc.name, op, got, limit)
+			}
+		}
+		check("unmarshal", budget.unmarshal, func() {
+			Unmarshal(doc.yay)
+		})
+		check("batch", budget.batch, func() {
+			UnmarshalWithOptions(doc.yay, DecodeOptions{Batch: true})
+		})
+		check("valid", budget.valid, func() {
+			Valid(doc.yay)
+		})
+		check("marshal", budget.marshal, func() {
+			Marshal(value)
+		})
+	}
+}
+
+func TestScratchPools(t *testing.T) {
+	// Strings are built in pooled buffers, which must not be shared with
+	// the values decoded from them.
+	first, err := Unmarshal([]byte("a: \"x\\ty\"\nb: 'single'\nc: `\n  line one\n  line two\nd: >\n  cafe\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"a": "x\ty", "b": "single", "c": "line one\nline two\n", "d": []byte{0xca, 0xfe}}
+	for i := 0; i < 100; i++ {
+		if _, err := Unmarshal([]byte("a: \"zz\\tzz\"\nb: 'other'\nc: `\n  other text\n  here\nd: >\n  beef\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !Equal(first, want) {
+		t.Errorf("after more documents: got %#v, want %#v", first, want)
+	}
+
+	// A slice returned to its pool is cleared, so that it keeps nothing
+	// of the document reachable, and one too large is not kept.
+	var pool slicePool[string]
+	s := append(pool.get(), "source text")
+	pool.put(s)
+	if got := pool.get(); cap(got) > 0 && got[:1][0] != "" {
+		t.Errorf("pooled slice holds %q", got[:1][0])
+	}
+	pool.put(make([]string, 1, maxPooledCap+1))
+	if got := pool.get(); cap(got) > maxPooledCap {
+		t.Errorf("pooled a slice of capacity %d", cap(got))
+	}
+}
+
+func TestPresizedCollections(t *testing.T) {
+	tokens := outlineLex(mustScan(t, "a:\n  - 1\n  - 2\n  - 3\nb: 2\nc:\n  d: 1\n  e: 2\n"), nil)
+	var counts []int
+	for _, tok := range tokens {
+		if tok.count > 0 {
+			counts = append(counts, tok.count)
+		}
+	}
+	// The root's three properties, the array's three items and the three
+	// lines of their values, and the two properties nested in c.
+	if want := []int{3, 3, 3, 2}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("sibling counts: got %v, want %v", counts, want)
+	}
+
+	v, err := Unmarshal([]byte("- 1\n- 2\n- 3\n- 4\n- 5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := v.([]any); len(a) != 5 || cap(a) != 5 {
+		t.Errorf("got len %d, cap %d, want both 5", len(a), cap(a))
+	}
+}
+
+// mustScan returns the scan lines of source.
+func mustScan(t *testing.T, source string) []scanLine {
+	t.Helper()
+	lines, err := scan(source, &parseContext{source: source}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
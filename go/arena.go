@@ -0,0 +1,191 @@
+package yay
+
+import (
+	"encoding/hex"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"unsafe"
+)
+
+// ============================================================================
+// Batch Allocation
+// ============================================================================
+//
+// With DecodeOptions.Batch, the value parser carves integers, strings, byte
+// arrays, and array backing stores out of large per-parse slabs instead of
+// allocating each value on its own. A document of thousands of scalars then
+// costs the garbage collector a handful of objects rather than thousands.
+//
+// Go offers no way to free memory explicitly, so "released together" means
+// that a slab becomes garbage only when every value carved from it has.
+// Carved slices have their capacity clipped, so appending to a decoded
+// array or byte slice reallocates instead of overwriting a neighbor.
+
+// Slab lengths, in elements, for each kind of carved storage.
+const (
+	intSlab  = 256
+	wordSlab = 256
+	anySlab  = 1024
+	byteSlab = 16 << 10
+)
+
+// arena holds the unused tails of the current slabs for one parse.
+type arena struct {
+	ints  []big.Int
+	words []big.Word
+	anys  []any
+	bytes []byte
+}
+
+// carve returns n zeroed elements from the front of *slab, starting a new
+// slab of size elements when the current one is exhausted. Requests larger
+// than a quarter slab get their own allocation so they don't waste a slab.
+func carve[T any](slab *[]T, n, size int) []T {
+	if n > size/4 {
+		return make([]T, n)
+	}
+	if len(*slab) < n {
+		*slab = make([]T, size)
+	}
+	s := (*slab)[:n:n]
+	*slab = (*slab)[n:]
+	return s
+}
+
+// anyScratchPool holds the scratch slices in which batched arrays gather
+// their elements before being copied into the arena.
+var anyScratchPool slicePool[any]
+
+// batching reports whether values should be carved from the arena.
+func (ctx *parseContext) batching() bool {
+	return ctx != nil && ctx.arena != nil
+}
+
+// discarding reports whether values are only being checked, for Valid.
+func (ctx *parseContext) discarding() bool {
+	return ctx != nil && ctx.discard
+}
+
+// discardedInt stands in for every integer when discarding.
+var discardedInt = new(big.Int)
+
+// discardedFloat stands in for every float when discarding, since a
+// float64 other than zero is allocated to be held in an any.
+var discardedFloat any = 0.0
+
+// newFloat returns f as a value.
+func (ctx *parseContext) newFloat(f float64) any {
+	if ctx.discarding() {
+		return discardedFloat
+	}
+	return f
+}
+
+// newInt parses a decimal integer with optional sign.
+// When batching, integers that fit in a machine word use no allocations
+// of their own.
+func (ctx *parseContext) newInt(s string) *big.Int {
+	if ctx.discarding() {
+		return discardedInt
+	}
+	if !ctx.batching() {
+		n := new(big.Int)
+		n.SetString(s, 10)
+		return n
+	}
+	a := ctx.arena
+	z := &carve(&a.ints, 1, intSlab)[0]
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil && bits.UintSize == 64 {
+		if v == 0 {
+			return z
+		}
+		abs := uint64(v)
+		if v < 0 {
+			abs = uint64(-v)
+		}
+		w := carve(&a.words, 1, wordSlab)
+		w[0] = big.Word(abs)
+		z.SetBits(w)
+		if v < 0 {
+			z.Neg(z)
+		}
+		return z
+	}
+	z.SetString(s, 10)
+	return z
+}
+
+// newString returns b as a string, copied into the arena when batching.
+func (ctx *parseContext) newString(b []byte) string {
+	if ctx.discarding() {
+		return ""
+	}
+	if !ctx.batching() || len(b) == 0 {
+		return string(b)
+	}
+	dst := carve(&ctx.arena.bytes, len(b), byteSlab)
+	copy(dst, b)
+	return unsafe.String(&dst[0], len(dst))
+}
+
+// newBytes returns a zeroed byte slice of length n. When discarding, the
+// slice is scratch space that the next call overwrites.
+func (ctx *parseContext) newBytes(n int) []byte {
+	if ctx.discarding() {
+		if cap(ctx.sink) < n {
+			ctx.sink = make([]byte, n)
+		}
+		return ctx.sink[:n]
+	}
+	if !ctx.batching() {
+		return make([]byte, n)
+	}
+	return carve(&ctx.arena.bytes, n, byteSlab)
+}
+
+// decodeHex decodes a string of hex digit pairs.
+func (ctx *parseContext) decodeHex(s string) ([]byte, error) {
+	if ctx.discarding() {
+		// Callers have already checked the digits.
+		return nil, nil
+	}
+	if !ctx.batching() {
+		return hex.DecodeString(s)
+	}
+	dst := ctx.newBytes(len(s) / 2)
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// newSlice begins accumulating array elements, with capacity for n when
+// the element count is known in advance. When batching, elements gather in
+// pooled scratch space until finishSlice moves them to the arena; when
+// discarding, until finishSlice drops them.
+func (ctx *parseContext) newSlice(n int) []any {
+	if !ctx.batching() && !ctx.discarding() {
+		if n == 0 {
+			return nil
+		}
+		return make([]any, 0, n)
+	}
+	return anyScratchPool.get()
+}
+
+// finishSlice returns the final storage for elements gathered since
+// newSlice.
+func (ctx *parseContext) finishSlice(items []any) []any {
+	if !ctx.batching() && !ctx.discarding() {
+		return items
+	}
+	if len(items) == 0 || ctx.discarding() {
+		anyScratchPool.put(items)
+		return nil
+	}
+	out := carve(&ctx.arena.anys, len(items), anySlab)
+	copy(out, items)
+	anyScratchPool.put(items)
+	return out
+}
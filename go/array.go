@@ -0,0 +1,61 @@
+package yay
+
+// ============================================================================
+// Arrays
+// ============================================================================
+//
+// Unmarshal decodes arrays to []any, which serves for reading. Array gives
+// the same slice methods for editing in place. Marshal and Equal accept an
+// Array wherever they accept a []any.
+
+// Array is an array of YAY values.
+type Array []any
+
+// Len returns the number of items.
+func (a Array) Len() int {
+	return len(a)
+}
+
+// Get returns the item at index i and whether i is in range.
+func (a Array) Get(i int) (any, bool) {
+	if i < 0 || i >= len(a) {
+		return nil, false
+	}
+	return a[i], true
+}
+
+// Set replaces the item at index i, reporting whether i is in range.
+func (a Array) Set(i int, value any) bool {
+	if i < 0 || i >= len(a) {
+		return false
+	}
+	a[i] = value
+	return true
+}
+
+// Append adds values at the end.
+func (a *Array) Append(values ...any) {
+	*a = append(*a, values...)
+}
+
+// Insert adds value at index i, moving the items from i on along by one,
+// and reports whether i is in range. An index of Len appends.
+func (a *Array) Insert(i int, value any) bool {
+	if i < 0 || i > len(*a) {
+		return false
+	}
+	*a = append(*a, nil)
+	copy((*a)[i+1:], (*a)[i:])
+	(*a)[i] = value
+	return true
+}
+
+// Delete removes the item at index i, reporting whether i was in range.
+// The remaining items keep their order.
+func (a *Array) Delete(i int) bool {
+	if i < 0 || i >= len(*a) {
+		return false
+	}
+	*a = append((*a)[:i], (*a)[i+1:]...)
+	return true
+}
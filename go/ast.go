@@ -0,0 +1,587 @@
+package yay
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Syntax Trees
+// ============================================================================
+//
+// ParseAST parses a document into a tree of nodes, each with the span of
+// text it was written in, for tools that point at or rewrite parts of a
+// document rather than use its value. The document is parsed as for
+// decoding, with the keys of its objects in order, so that it is checked by
+// the same parser, and the text is then walked alongside the value, which
+// by then is known to be well formed:
+//
+//   - The members of an inline array or object follow one another after
+//     ", ", and the first begins after the bracket. A value within one ends
+//     at the comma or bracket after it, and the collection at its closing
+//     bracket.
+//   - The members of a block array or object each begin on the first line
+//     after the one before that is not blank or a comment, at the column of
+//     the first, and the collection ends where its last member ends. Lines
+//     without a key among the properties of an object are passed over, as
+//     parsing passes over them.
+//   - Of a key given more than once, the last occurrence is the one kept.
+//   - Any other value ends with the last line, not counting comments and
+//     blank lines, of those that follow its own and are indented deeper
+//     than the key or dash that introduces it, as the lines of a block
+//     string, block bytes, or concatenated strings are.
+//
+// The offsets that parsing records for error messages are used only to
+// tell which occurrence of a key is the last, since within a list item's
+// line they count from the dash, as the reference implementation's do.
+// Text the walk cannot follow, which the parser reads more loosely, is
+// reported as an error with the code unplaced-value.
+
+// Node is a node of the syntax tree ParseAST returns: an *ObjectNode,
+// *PropertyNode, *ArrayNode, *StringNode, *BytesNode, *IntegerNode,
+// *FloatNode, *BoolNode, or *NullNode.
+type Node interface {
+	// Extent returns the span of the document the node was written in.
+	Extent() Span
+}
+
+// Span is the text of a document that a node was written in, from the
+// position of its first byte to the position just past its last. The span
+// of a value does not include comments after it, nor the key or dash that
+// introduces it.
+type Span struct {
+	Start, End Position
+}
+
+// Extent returns s, making each node that embeds a Span a Node.
+func (s Span) Extent() Span {
+	return s
+}
+
+// ObjectNode is an object, with its properties in the order the document
+// gives them.
+type ObjectNode struct {
+	Span
+	Properties []*PropertyNode
+	Inline     bool // Whether written in braces on one line
+}
+
+// PropertyNode is a property of an object, spanning its key and value.
+type PropertyNode struct {
+	Span
+	Key     string
+	KeySpan Span // Of the key as written, with any quotes
+	Value   Node
+}
+
+// ArrayNode is an array.
+type ArrayNode struct {
+	Span
+	Items  []Node
+	Inline bool // Whether written in brackets on one line
+}
+
+// StringNode is a string, written quoted, as a block string, or as
+// concatenated quoted strings.
+type StringNode struct {
+	Span
+	Value string
+}
+
+// BytesNode is a byte array, written in angle brackets or as block bytes.
+type BytesNode struct {
+	Span
+	Value []byte
+}
+
+// IntegerNode is an integer.
+type IntegerNode struct {
+	Span
+	Value *big.Int
+}
+
+// FloatNode is a floating-point number, including nan and the infinities.
+type FloatNode struct {
+	Span
+	Value float64
+}
+
+// BoolNode is true or false.
+type BoolNode struct {
+	Span
+	Value bool
+}
+
+// NullNode is null.
+type NullNode struct {
+	Span
+}
+
+// ParseAST parses a YAY document into its syntax tree, returning the node
+// of its root value, or the error Unmarshal would return for it.
+func ParseAST(data []byte) (Node, error) {
+	return ParseASTWithOptions(data, DecodeOptions{})
+}
+
+// ParseASTWithOptions parses a YAY document into its syntax tree according
+// to opts, whose Filename and Columns the positions of the nodes follow.
+// Options that change the decoded value rather than which documents are
+// accepted, such as PreserveKeyOrder and JSONCompatible, have no effect.
+func ParseASTWithOptions(data []byte, opts DecodeOptions) (Node, error) {
+	if err := checkInput(len(data), opts); err != nil {
+		return nil, err
+	}
+	ctx := newParseContext(string(data), opts.Filename, opts)
+	ctx.ordered = true
+	ctx.chomp = false // The text of a block string is as written
+	ctx.positions = &positions{
+		props: make(map[propertyAt]int),
+		items: make(map[uintptr][]int),
+	}
+	root, err := parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b := &astBuilder{ctx: ctx, source: ctx.source}
+	for off := 0; off < len(b.source); {
+		b.lines = append(b.lines, off)
+		end := strings.IndexByte(b.source[off:], '\n')
+		if end < 0 {
+			break
+		}
+		off += end + 1
+	}
+	if len(b.lines) == 0 {
+		b.lines = []int{0}
+	}
+	// The root begins the first line that is not blank or a comment, at
+	// its indent.
+	start, ok := b.contentLine(0)
+	if !ok {
+		return nil, b.errorf(0)
+	}
+	return b.node(root, b.lines[start]+countIndent(b.line(start)), -1, false)
+}
+
+// astBuilder builds the nodes of a syntax tree from a parsed value and the
+// text it was parsed from.
+type astBuilder struct {
+	ctx    *parseContext
+	source string
+	lines  []int // Byte offset of the start of each line of source
+}
+
+// errorf returns the error for a value the builder cannot find at off. The
+// document has parsed, so this is for text the parser reads more loosely
+// than the builder walks it, such as lines indented less than the key
+// whose value they give, which Format would rewrite.
+func (b *astBuilder) errorf(off int) error {
+	return b.ctx.errorf(b.clamp(off), "Value not found in the text at this position")
+}
+
+// node returns the node of v, whose text begins at off. The key or dash
+// that introduces v is at column owner, or -1 for the root, and inline
+// reports whether v is a member of an inline array or object.
+func (b *astBuilder) node(v any, off, owner int, inline bool) (Node, error) {
+	if off < 0 || off > len(b.source) {
+		return nil, b.errorf(off)
+	}
+	switch x := v.(type) {
+	case *OrderedMap:
+		if off == len(b.source) {
+			return nil, b.errorf(off)
+		}
+		if b.source[off] == '{' {
+			return b.inlineObject(x, off)
+		}
+		return b.object(x, off)
+	case []any:
+		if off == len(b.source) {
+			return nil, b.errorf(off)
+		}
+		return b.array(x, off)
+	}
+	end := b.scalarEnd(off, owner, inline)
+	span := b.span(off, end)
+	switch x := v.(type) {
+	case nil:
+		return &NullNode{span}, nil
+	case bool:
+		return &BoolNode{span, x}, nil
+	case *big.Int:
+		return &IntegerNode{span, x}, nil
+	case float64:
+		return &FloatNode{span, x}, nil
+	case string:
+		return &StringNode{span, x}, nil
+	case []byte:
+		return &BytesNode{span, x}, nil
+	}
+	return nil, fmt.Errorf("%w: no node for %T", errInternal, v)
+}
+
+// object returns the node of obj, a block object whose text begins at
+// off. A key given more than once takes the value of its last occurrence,
+// as parsing does, at the position of its first among the properties; the
+// parse records where that last occurrence begins, so the others are
+// passed over.
+func (b *astBuilder) object(obj *OrderedMap, off int) (Node, error) {
+	n := &ObjectNode{}
+	built := make([]*PropertyNode, len(obj.members))
+	end := off
+	for keyOff, left := off, len(obj.members); left > 0; {
+		colon := b.colonAfter(keyOff)
+		if colon < 0 {
+			return nil, b.errorf(keyOff)
+		}
+		key := parseKeyName(b.source[keyOff:colon])
+		i := obj.find(key)
+		if i < 0 || built[i] != nil {
+			return nil, b.errorf(keyOff)
+		}
+		last, ok := b.ctx.positions.props[propertyAt{identity(obj), key}]
+		if ok && last > keyOff {
+			// An earlier occurrence of a key given again.
+			next, ok := b.nextKey(b.skipMember(keyOff), off)
+			if !ok {
+				return nil, b.errorf(keyOff)
+			}
+			keyOff = next
+			continue
+		}
+		value, err := b.node(obj.members[i].Value, b.valueStart(colon+1, column(b.source, keyOff)), column(b.source, keyOff), false)
+		if err != nil {
+			return nil, err
+		}
+		valueEnd := b.offsetOf(value.Extent().End)
+		end = max(end, valueEnd)
+		built[i] = &PropertyNode{
+			Span:    b.span(keyOff, valueEnd),
+			Key:     key,
+			KeySpan: b.span(keyOff, colon),
+			Value:   value,
+		}
+		if left--; left > 0 {
+			if keyOff, ok = b.nextKey(valueEnd, off); !ok {
+				return nil, b.errorf(valueEnd)
+			}
+		}
+	}
+	n.Properties = built
+	n.Span = b.span(off, end)
+	return n, nil
+}
+
+// inlineObject returns the node of obj, an inline object whose text begins
+// at off, with the brace.
+func (b *astBuilder) inlineObject(obj *OrderedMap, off int) (Node, error) {
+	n := &ObjectNode{Inline: true}
+	// Find each property in the text, so that of a key given more than
+	// once, the last is the one kept.
+	type member struct{ keyOff, colon, valueOff int }
+	found := make(map[string]member, len(obj.members))
+	for next := off + 1; next < len(b.source) && b.source[next] != '}'; {
+		colon := b.colonAfter(next)
+		if colon < 0 {
+			return nil, b.errorf(next)
+		}
+		valueOff := colon + 1 + countIndent(b.source[colon+1:])
+		found[parseKeyName(b.source[next:colon])] = member{next, colon, valueOff}
+		next = inlineEnd(b.source, valueOff)
+		for next < len(b.source) && (b.source[next] == ',' || b.source[next] == ' ') {
+			next++
+		}
+	}
+	for _, m := range obj.members {
+		at, ok := found[m.Key]
+		if !ok {
+			return nil, b.errorf(off)
+		}
+		value, err := b.node(m.Value, at.valueOff, column(b.source, at.keyOff), true)
+		if err != nil {
+			return nil, err
+		}
+		n.Properties = append(n.Properties, &PropertyNode{
+			Span:    b.span(at.keyOff, b.offsetOf(value.Extent().End)),
+			Key:     m.Key,
+			KeySpan: b.span(at.keyOff, at.colon),
+			Value:   value,
+		})
+	}
+	n.Span = b.span(off, inlineEnd(b.source, off))
+	return n, nil
+}
+
+// array returns the node of arr, whose text begins at off.
+func (b *astBuilder) array(arr []any, off int) (Node, error) {
+	n := &ArrayNode{Inline: b.source[off] == '[', Items: make([]Node, 0, len(arr))}
+	end, next := off, off+1
+	if !n.Inline {
+		next = off + len("- ")
+	}
+	for i, item := range arr {
+		itemOff := next
+		if i > 0 && !n.Inline {
+			dash, ok := b.nextMember(end, off)
+			if !ok || !strings.HasPrefix(b.source[dash:], "-") {
+				return nil, b.errorf(end)
+			}
+			itemOff = dash + len("- ")
+		}
+		if !n.Inline {
+			// The item may begin on a later line, deeper than the dash.
+			itemOff = b.valueStart(itemOff-1, column(b.source, off))
+		}
+		value, err := b.node(item, itemOff, column(b.source, off), n.Inline)
+		if err != nil {
+			return nil, err
+		}
+		n.Items = append(n.Items, value)
+		end = b.offsetOf(value.Extent().End)
+		next = end + len(", ")
+	}
+	if n.Inline {
+		end = inlineEnd(b.source, off)
+	}
+	n.Span = b.span(off, end)
+	return n, nil
+}
+
+// colonAfter returns the offset of the colon after the key that begins at
+// off, or -1 if the line has none.
+func (b *astBuilder) colonAfter(off int) int {
+	i := findColonOutsideQuotes(b.source[off:b.lineEnd(off)])
+	if i < 0 {
+		return -1
+	}
+	return off + i
+}
+
+// nextMember returns where the member of a block array or object after
+// the one that ends at end begins: at the column of first, where the
+// first member begins, on the next line that is not blank or a comment.
+// It reports false if there is no such line, or it is not indented to
+// that column.
+func (b *astBuilder) nextMember(end, first int) (int, bool) {
+	i, ok := b.contentLine(b.lineOf(end) + 1)
+	if !ok || countIndent(b.line(i)) != column(b.source, first) {
+		return 0, false
+	}
+	return b.lines[i] + column(b.source, first), true
+}
+
+// nextKey returns where the property of a block object after the one that
+// ends at end begins, as nextMember does, passing over lines without a key,
+// which parsing passes over too.
+func (b *astBuilder) nextKey(end, first int) (int, bool) {
+	for {
+		off, ok := b.nextMember(end, first)
+		if !ok || b.colonAfter(off) >= 0 {
+			return off, ok
+		}
+		end = b.lineEnd(off)
+	}
+}
+
+// skipMember returns where the member of a block object whose key begins
+// at keyOff ends: with the last line, not blank or a comment, before the
+// next indented no deeper than the key.
+func (b *astBuilder) skipMember(keyOff int) int {
+	end := b.lineEnd(keyOff)
+	for i := b.lineOf(keyOff) + 1; i < len(b.lines); i++ {
+		line := b.line(i)
+		indent := countIndent(line)
+		if indent == len(line) || stripComment(line[indent:]) == "" {
+			continue
+		}
+		if indent <= column(b.source, keyOff) {
+			break
+		}
+		end = b.lineEnd(b.lines[i])
+	}
+	return end
+}
+
+// contentLine returns the index of the first line from the one of index i
+// that is not blank or a comment, or false if there is none.
+func (b *astBuilder) contentLine(i int) (int, bool) {
+	for ; i < len(b.lines); i++ {
+		line := b.line(i)
+		if stripComment(strings.TrimLeft(line, " ")) != "" {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// line returns the text of the line of index i, without its newline.
+func (b *astBuilder) line(i int) string {
+	return b.source[b.lines[i]:b.lineEnd(b.lines[i])]
+}
+
+// valueStart returns where the value of a property or item begins, given
+// the offset just past the colon or dash at column owner that introduces
+// it: later on the line, or else on the next line that is not blank or a
+// comment, if that is indented deeper than the owner or is an array at
+// its column. A value with no text, as null is written, begins at off.
+func (b *astBuilder) valueStart(off, owner int) int {
+	rest := stripComment(b.source[off:b.lineEnd(off)])
+	if trimmed := strings.TrimLeft(rest, " "); trimmed != "" {
+		return off + len(rest) - len(trimmed)
+	}
+	if i, ok := b.contentLine(b.lineOf(off) + 1); ok {
+		line := b.line(i)
+		indent := countIndent(line)
+		if indent > owner || indent == owner && strings.HasPrefix(line[indent:], "- ") {
+			return b.lines[i] + indent
+		}
+	}
+	return off
+}
+
+// scalarEnd returns where the value that begins at off, and is neither an
+// array nor an object, ends.
+func (b *astBuilder) scalarEnd(off, owner int, inline bool) int {
+	if inline {
+		return inlineEnd(b.source, off)
+	}
+	text := off < len(b.source) && b.source[off] == '`' // Comments are text in a block string
+	content := func(line string) string {
+		if text {
+			return line
+		}
+		return strings.TrimRight(stripComment(line), " ")
+	}
+	end := off + len(content(b.source[off:b.lineEnd(off)]))
+	for i := b.lineOf(off) + 1; i < len(b.lines); i++ {
+		line := b.source[b.lines[i]:b.lineEnd(b.lines[i])]
+		indent := countIndent(line)
+		if indent == len(line) {
+			continue // Blank
+		}
+		if indent <= owner {
+			break
+		}
+		if indent == 0 && line[0] == '#' {
+			continue // The scanner skips comments at the margin
+		}
+		if c := content(line); len(c) > indent {
+			end = b.lines[i] + len(c)
+		}
+	}
+	return end
+}
+
+// inlineEnd returns where the value that begins at s[off], within or
+// including an inline array or object, ends.
+func inlineEnd(s string, off int) int {
+	depth := 0
+	for i := off; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			quote := s[i]
+			for i++; i < len(s) && s[i] != quote; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '<':
+			i += strings.IndexByte(s[i:], '>')
+		case '[', '{':
+			depth++
+			continue
+		case ']', '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ',', ' ', '\n':
+			if depth == 0 {
+				return i
+			}
+			continue
+		default:
+			continue
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// lineOf returns the zero-based index of the line that holds off.
+func (b *astBuilder) lineOf(off int) int {
+	return max(sort.Search(len(b.lines), func(i int) bool { return b.lines[i] > off })-1, 0)
+}
+
+// lineEnd returns the offset of the end of the line that holds off.
+func (b *astBuilder) lineEnd(off int) int {
+	return endOfLine(b.source, off)
+}
+
+// startOfLine returns the offset in src of the start of the line that
+// holds off, which is brought within src if it is not.
+func startOfLine[T string | []byte](src T, off int) int {
+	for off = min(max(off, 0), len(src)); off > 0; off-- {
+		if src[off-1] == '\n' {
+			break
+		}
+	}
+	return off
+}
+
+// endOfLine returns the offset in src of the end of the line that holds
+// off, at its newline or the end of src, with off brought within src if
+// it is not.
+func endOfLine[T string | []byte](src T, off int) int {
+	for off = min(max(off, 0), len(src)); off < len(src); off++ {
+		if src[off] == '\n' {
+			break
+		}
+	}
+	return off
+}
+
+// column returns the byte column of off within its line of src, not
+// counting the byte order mark that may begin the first.
+func column[T string | []byte](src T, off int) int {
+	start := startOfLine(src, off)
+	if start == 0 && len(src) >= len("\uFEFF") && string(src[:len("\uFEFF")]) == "\uFEFF" {
+		start = len("\uFEFF")
+	}
+	return off - start
+}
+
+// clamp returns off, or the nearest offset within the source.
+func (b *astBuilder) clamp(off int) int {
+	return min(max(off, 0), len(b.source))
+}
+
+// span returns the span from start to end.
+func (b *astBuilder) span(start, end int) Span {
+	return Span{Start: b.position(start), End: b.position(end)}
+}
+
+// position returns the position of off, counting columns as
+// DecodeOptions.Columns asks.
+func (b *astBuilder) position(off int) Position {
+	off = b.clamp(off)
+	line := b.lineOf(off)
+	_, col := positionAt(b.source[b.lines[line]:], off-b.lines[line], b.ctx.columns)
+	p := Position{Filename: b.ctx.filename, Line: line + 1, Column: col + 1, Offset: off}
+	if b.ctx.bom {
+		p.Offset += len("\uFEFF")
+	}
+	return p
+}
+
+// offsetOf returns the offset in the source of p, a position of b.
+func (b *astBuilder) offsetOf(p Position) int {
+	if b.ctx.bom {
+		return p.Offset - len("\uFEFF")
+	}
+	return p.Offset
+}
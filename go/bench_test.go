@@ -0,0 +1,220 @@
+package yay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchDoc is a YAY document paired with an equivalent JSON document,
+// so that decode throughput can be compared against encoding/json.
+type benchDoc struct {
+	name string
+	yay  []byte
+	json []byte
+}
+
+// benchDocs builds the benchmark corpus. Sizes are chosen so each document
+// is tens of kilobytes: large enough to dominate per-call overhead, small
+// enough to stay in cache.
+func benchDocs() []benchDoc {
+	return []benchDoc{
+		scalarDoc(2000),
+		stringDoc(1000),
+		blockStringDoc(20, 200),
+		bytesDoc(64, 32),
+		deepDoc(200),
+		wideDoc(2000),
+	}
+}
+
+// scalarDoc is an array of integers, floats, booleans, and nulls.
+func scalarDoc(n int) benchDoc {
+	var y, j strings.Builder
+	j.WriteString("[")
+	for i := 0; i < n; i++ {
+		var v string
+		switch i % 4 {
+		case 0:
+			v = fmt.Sprint(i * 7919)
+		case 1:
+			v = fmt.Sprintf("%d.5", i)
+		case 2:
+			v = "true"
+		case 3:
+			v = "null"
+		}
+		fmt.Fprintf(&y, "- %s\n", v)
+		if i > 0 {
+			j.WriteString(",")
+		}
+		j.WriteString(v)
+	}
+	j.WriteString("]")
+	return benchDoc{"scalars", []byte(y.String()), []byte(j.String())}
+}
+
+// stringDoc is an array of quoted strings, some with escapes.
+func stringDoc(n int) benchDoc {
+	var y, j strings.Builder
+	j.WriteString("[")
+	for i := 0; i < n; i++ {
+		v := fmt.Sprintf(`"Item %d says \"ni\" to the knights who say \"ni\".\n"`, i)
+		fmt.Fprintf(&y, "- %s\n", v)
+		if i > 0 {
+			j.WriteString(",")
+		}
+		j.WriteString(v)
+	}
+	j.WriteString("]")
+	return benchDoc{"strings", []byte(y.String()), []byte(j.String())}
+}
+
+// blockStringDoc is an object of block strings, each of lines lines,
+// some indented further than others.
+func blockStringDoc(keys, lines int) benchDoc {
+	var y, j strings.Builder
+	j.WriteString("{")
+	for k := 0; k < keys; k++ {
+		fmt.Fprintf(&y, "text%d: `\n", k)
+		if k > 0 {
+			j.WriteString(",")
+		}
+		fmt.Fprintf(&j, `"text%d":"`, k)
+		for l := 0; l < lines; l++ {
+			pad := strings.Repeat(" ", l%3*2)
+			fmt.Fprintf(&y, "  %sNobody expects the Spanish Inquisition! (%d)\n", pad, l)
+			fmt.Fprintf(&j, `%sNobody expects the Spanish Inquisition! (%d)\n`, pad, l)
+		}
+		j.WriteString(`"`)
+	}
+	j.WriteString("}")
+	return benchDoc{"blockstrings", []byte(y.String()), []byte(j.String())}
+}
+
+// bytesDoc is an object of block byte arrays, each of lines×16 bytes.
+// The JSON twin carries the same data as hex strings.
+func bytesDoc(keys, lines int) benchDoc {
+	var y, j strings.Builder
+	j.WriteString("{")
+	for k := 0; k < keys; k++ {
+		fmt.Fprintf(&y, "blob%d: >\n", k)
+		if k > 0 {
+			j.WriteString(",")
+		}
+		fmt.Fprintf(&j, `"blob%d":"`, k)
+		for l := 0; l < lines; l++ {
+			y.WriteString("  ")
+			for b := 0; b < 16; b++ {
+				h := fmt.Sprintf("%02x", (k+l+b)&0xff)
+				y.WriteString(h)
+				j.WriteString(h)
+				if b%2 == 1 && b < 15 {
+					y.WriteString(" ")
+				}
+			}
+			y.WriteString("\n")
+		}
+		j.WriteString(`"`)
+	}
+	j.WriteString("}")
+	return benchDoc{"bytes", []byte(y.String()), []byte(j.String())}
+}
+
+// deepDoc is a chain of singly nested objects depth levels deep.
+func deepDoc(depth int) benchDoc {
+	var y, j strings.Builder
+	for d := 0; d < depth; d++ {
+		fmt.Fprintf(&y, "%slevel%d:\n", strings.Repeat("  ", d), d)
+		fmt.Fprintf(&j, `{"level%d":`, d)
+	}
+	fmt.Fprintf(&y, "%sleaf: 42\n", strings.Repeat("  ", depth))
+	j.WriteString(`{"leaf":42}`)
+	j.WriteString(strings.Repeat("}", depth))
+	return benchDoc{"deep", []byte(y.String()), []byte(j.String())}
+}
+
+// wideDoc is a flat root object with n properties.
+func wideDoc(n int) benchDoc {
+	var y, j strings.Builder
+	j.WriteString("{")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&y, "key%04d: %d\n", i, i)
+		if i > 0 {
+			j.WriteString(",")
+		}
+		fmt.Fprintf(&j, `"key%04d":%d`, i, i)
+	}
+	j.WriteString("}")
+	return benchDoc{"wide", []byte(y.String()), []byte(j.String())}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, doc := range benchDocs() {
+		doc := doc
+		if _, err := Unmarshal(doc.yay); err != nil {
+			b.Fatalf("%s: %v", doc.name, err)
+		}
+		b.Run(doc.name+"/yay", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(doc.yay)))
+			for i := 0; i < b.N; i++ {
+				if _, err := Unmarshal(doc.yay); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(doc.name+"/yay-batch", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(doc.yay)))
+			opts := DecodeOptions{Batch: true}
+			for i := 0; i < b.N; i++ {
+				if _, err := UnmarshalWithOptions(doc.yay, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(doc.name+"/json", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(doc.json)))
+			for i := 0; i < b.N; i++ {
+				var v any
+				if err := json.Unmarshal(doc.json, &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidateCodePoints(b *testing.B) {
+	for _, doc := range benchDocs() {
+		source := string(doc.yay)
+		b.Run(doc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(source)))
+			for i := 0; i < b.N; i++ {
+				if err := validateCodePoints(source, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkScanLines(b *testing.B) {
+	for _, doc := range benchDocs() {
+		source := string(doc.yay)
+		b.Run(doc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(source)))
+			for i := 0; i < b.N; i++ {
+				lines, err := scanLines(source, nil, scanLinePool.get())
+				if err != nil {
+					b.Fatal(err)
+				}
+				scanLinePool.put(lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+//go:build !yay_tiny
+
+// Command yay converts between YAY and other formats, for using YAY
+// documents in existing pipelines.
+//
+// Usage:
+//
+//	yay to-json [-bytes base64|hex] [-int-strings] [file]
+//	yay from-json [-int-strings] [file]
+//	yay from-yaml [-strict] [file]
+//	yay from-toml [-strict] [file]
+//	yay to-toml [file]
+//	yay from-cbor [-strict] [file]
+//	yay to-cbor [file]
+//	yay from-msgpack [-strict] [file]
+//	yay to-msgpack [-strict] [file]
+//
+// Each subcommand reads the named file, or standard input if there is none
+// or it is "-", and writes the converted document to standard output.
+//
+// to-json writes the value of a YAY document as indented JSON, keeping the
+// order of object keys, as yay.ToJSON does. Integers are written with all
+// their digits, byte arrays as strings of base64 or, with -bytes hex, of
+// hex, and NaN and the infinities, which JSON lacks, as null.
+//
+// from-json writes a JSON document as YAY, as yay.FromJSON does. Numbers
+// written without a fraction or exponent become integers, keeping all
+// their digits, and the rest become floats. Object keys keep their order.
+//
+// from-yaml writes a YAML document as YAY, as yayconv.FromYAML does, with
+// a warning on standard error for each anchor, alias, tag, and key that
+// is not a string, which YAY lacks and the conversion expands, drops, or
+// writes as a string, and for each "<<", which stays a key. from-toml writes a TOML document as YAY,
+// with a warning for each date-time, which becomes a string, and to-toml
+// writes a YAY document, which must be an object, as TOML, failing on any
+// null, byte array, or integer beyond 64 bits. With -strict, each warning
+// is an error instead.
+//
+// to-cbor writes a YAY document as CBOR, with integers beyond 64 bits as
+// bignums and byte arrays as byte strings, and from-cbor writes a CBOR
+// data item as YAY, with a warning for each tag other than the bignums,
+// undefined, and map key that is not text.
+//
+// to-msgpack and from-msgpack do the same for MessagePack, which lacks
+// integers beyond 64 bits, written as strings with a warning, and whose
+// timestamps and other extension types YAY lacks, written as strings and
+// byte arrays with a warning.
+//
+// With -int-strings, to-json writes integers beyond ±2^53 as strings, for
+// readers that take every number as a float64, and from-json reads such
+// strings back as integers.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/yayconv"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n\tyay to-json [-bytes base64|hex] [-int-strings] [file]\n\tyay from-json [-int-strings] [file]\n\tyay from-yaml [-strict] [file]\n\tyay from-toml [-strict] [file]\n\tyay to-toml [file]\n\tyay from-cbor [-strict] [file]\n\tyay to-cbor [file]\n\tyay from-msgpack [-strict] [file]\n\tyay to-msgpack [-strict] [file]\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("yay "+os.Args[1], flag.ExitOnError)
+	intStrings := flags.Bool("int-strings", false, "write integers beyond ±2^53 as JSON strings, and read them back")
+	var command func(in []byte, opts yay.JSONOptions) ([]byte, error)
+	switch os.Args[1] {
+	case "to-json":
+		byteStrings := flags.String("bytes", "base64", "how to write byte arrays: base64 or hex")
+		command = func(in []byte, opts yay.JSONOptions) ([]byte, error) {
+			switch *byteStrings {
+			case "base64":
+			case "hex":
+				opts.Bytes = yay.BytesHex
+			default:
+				return nil, fmt.Errorf("unknown -bytes %q", *byteStrings)
+			}
+			return toJSON(in, opts)
+		}
+	case "from-json":
+		command = yay.FromJSONWithOptions
+	case "from-yaml", "from-toml", "to-toml", "from-cbor", "to-cbor", "from-msgpack", "to-msgpack":
+		convert := map[string]func([]byte, yayconv.Options) ([]byte, error){
+			"from-yaml":    yayconv.FromYAML,
+			"from-toml":    yayconv.FromTOML,
+			"to-toml":      yayconv.ToTOML,
+			"from-cbor":    yayconv.FromCBOR,
+			"to-cbor":      yayconv.ToCBOR,
+			"from-msgpack": yayconv.FromMsgPack,
+			"to-msgpack":   yayconv.ToMsgPack,
+		}[os.Args[1]]
+		strict := flags.Bool("strict", false, "fail on what one format has and the other lacks")
+		command = func(in []byte, _ yay.JSONOptions) ([]byte, error) {
+			return convertWith(convert, in, flags.Arg(0), *strict, os.Stderr)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "yay: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	flags.Parse(os.Args[2:])
+	if flags.NArg() > 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := flags.Arg(0)
+	if name == "" {
+		name = "-"
+	}
+	if err := run(command, name, yay.JSONOptions{IntegerStrings: *intStrings}, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "yay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run applies command to the file name, or to standard input for "-".
+func run(command func([]byte, yay.JSONOptions) ([]byte, error), name string, opts yay.JSONOptions, out io.Writer) error {
+	var in []byte
+	var err error
+	if name == "-" {
+		in, err = io.ReadAll(os.Stdin)
+	} else {
+		in, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return err
+	}
+	if name != "-" {
+		opts.Filename = name
+	}
+	data, err := command(in, opts)
+	if err != nil {
+		var parseErr *yay.ParseError
+		if name != "-" && !(errors.As(err, &parseErr) && parseErr.Filename != "") {
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// toJSON returns the YAY document in as indented JSON.
+func toJSON(in []byte, opts yay.JSONOptions) ([]byte, error) {
+	data, err := yay.ToJSONWithOptions(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// convertWith returns the document in, from the file name, converted by
+// convert, writing a line to warnings for each construct that the other
+// format lacks.
+func convertWith(convert func([]byte, yayconv.Options) ([]byte, error), in []byte, name string, strict bool, warnings io.Writer) ([]byte, error) {
+	if name == "" || name == "-" {
+		name = "stdin"
+	}
+	return convert(in, yayconv.Options{
+		Filename: name,
+		Strict:   strict,
+		Warn: func(w yay.Warning) {
+			fmt.Fprintf(warnings, "yay: warning: %s\n", w)
+		},
+	})
+}
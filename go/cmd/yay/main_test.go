@@ -0,0 +1,98 @@
+//go:build !yay_tiny
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/yayconv"
+)
+
+func TestRun(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.yay")
+	if err := os.WriteFile(name, []byte("name: \"app\"\nkey: <cafe>\nlimits: [nan, 1.5]\nempty: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run(toJSON, name, yay.JSONOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"name\": \"app\",\n  \"key\": \"yv4=\",\n  \"limits\": [\n    null,\n    1.5\n  ],\n  \"empty\": {}\n}\n"
+	if out.String() != want {
+		t.Errorf("to-json: got %q, want %q", out.String(), want)
+	}
+
+	if err := os.WriteFile(name, []byte("a:\t1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(toJSON, name, yay.JSONOptions{}, &out); err == nil || err.Error() != "Tab not allowed (use spaces) at 1:3 of <"+name+">" {
+		t.Errorf("invalid document: got %v", err)
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.yaml")
+	if err := os.WriteFile(name, []byte("defaults: &d {retries: 3}\nprod: *d\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out, warnings bytes.Buffer
+	command := func(in []byte, _ yay.JSONOptions) ([]byte, error) {
+		return convertWith(yayconv.FromYAML, in, name, false, &warnings)
+	}
+	if err := run(command, name, yay.JSONOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "defaults: {retries: 3}\nprod: {retries: 3}\n"; out.String() != want {
+		t.Errorf("from-yaml: got %q, want %q", out.String(), want)
+	}
+	if !strings.HasPrefix(warnings.String(), "yay: warning: Dropped anchor &d (YAY has no anchors) at 1:11 of <"+name+">\n") {
+		t.Errorf("from-yaml: got warnings %q", warnings.String())
+	}
+
+	command = func(in []byte, _ yay.JSONOptions) ([]byte, error) {
+		return convertWith(yayconv.FromYAML, in, name, true, &warnings)
+	}
+	if err := run(command, name, yay.JSONOptions{}, &out); err == nil || err.Error() != "Dropped anchor &d (YAY has no anchors) at 1:11 of <"+name+">" {
+		t.Errorf("from-yaml -strict: got %v", err)
+	}
+}
+
+func TestTOML(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.toml")
+	if err := os.WriteFile(name, []byte("[server]\nhost = \"localhost\"\nport = 8080\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out, warnings bytes.Buffer
+	command := func(in []byte, _ yay.JSONOptions) ([]byte, error) {
+		return convertWith(yayconv.FromTOML, in, name, false, &warnings)
+	}
+	if err := run(command, name, yay.JSONOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "server: {host: \"localhost\", port: 8080}\n"; out.String() != want {
+		t.Errorf("from-toml: got %q, want %q", out.String(), want)
+	}
+
+	yayName := filepath.Join(t.TempDir(), "app.yay")
+	if err := os.WriteFile(yayName, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	command = func(in []byte, _ yay.JSONOptions) ([]byte, error) {
+		return convertWith(yayconv.ToTOML, in, yayName, false, &warnings)
+	}
+	if err := run(command, yayName, yay.JSONOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[server]\nhost = \"localhost\"\nport = 8080\n"; out.String() != want {
+		t.Errorf("to-toml: got %q, want %q", out.String(), want)
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("got warnings %q", warnings.String())
+	}
+}
@@ -0,0 +1,92 @@
+// Command yayconform runs the YAY conformance corpus against a decoder.
+//
+// Usage:
+//
+//	yayconform [-dir test] [-format text|tap|json] [command [args...]]
+//
+// The command is run once per document, as described in the conformance
+// package, and the results are written to standard output. With no
+// command, the corpus is run against this module's own decoder. The exit
+// status is 1 if any case fails.
+//
+// With -decode, yayconform instead acts as a decoder under test: it reads a
+// document from standard input and writes its value in typed JSON, which
+// makes it a reference for implementers of the protocol.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/conformance"
+)
+
+func main() {
+	dir := flag.String("dir", "test", "the repository's test directory")
+	format := flag.String("format", "text", "result format: text, tap, or json")
+	decode := flag.Bool("decode", false, "decode standard input to typed JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: yayconform [flags] [command [args...]]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *decode {
+		if err := decodeStdin(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	write := map[string]func(io.Writer, []conformance.Result) error{
+		"text": conformance.WriteText,
+		"tap":  conformance.WriteTAP,
+		"json": conformance.WriteJSON,
+	}[*format]
+	if write == nil {
+		fmt.Fprintf(os.Stderr, "yayconform: unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	cases, err := conformance.Load(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yayconform: %v\n", err)
+		os.Exit(2)
+	}
+
+	decoder := conformance.Decoder(yay.UnmarshalFile)
+	if args := flag.Args(); len(args) > 0 {
+		decoder = conformance.Command(args[0], args[1:]...)
+	}
+
+	results := conformance.Run(cases, decoder)
+	if err := write(os.Stdout, results); err != nil {
+		fmt.Fprintf(os.Stderr, "yayconform: %v\n", err)
+		os.Exit(2)
+	}
+	if conformance.Failures(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+// decodeStdin implements the decoder side of the protocol.
+func decodeStdin() error {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	v, err := yay.UnmarshalFile(input, os.Getenv("YAY_FILENAME"))
+	if err != nil {
+		return err
+	}
+	out, err := conformance.EncodeJSON(v)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(out, '\n'))
+	return err
+}
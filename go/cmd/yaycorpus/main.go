@@ -0,0 +1,219 @@
+// Command yaycorpus writes synthetic YAY documents of a chosen shape, for
+// seeding fuzz corpora and producing benchmark inputs. The same flags and
+// seed always produce the same documents.
+//
+// Usage:
+//
+//	yaycorpus [flags]
+//
+// Each document is a tree of collections -depth levels deep in which every
+// collection holds -fanout members, so a document has fanout^depth
+// scalars. Collections are objects or arrays at random, and scalars are
+// drawn according to -mix, a comma-separated list of kind:weight pairs
+// from int, bigint, float, string, bool, null, and bytes. Byte arrays are
+// -bytes long, and strings up to -strlen characters.
+//
+// Documents are written to standard output, separated by "---" lines, or
+// with -o to files in a directory: NAME-0000.yay and so on, or, with
+// -fuzz, files in the format of a Go fuzz corpus, so that -o can name a
+// testdata/fuzz/FuzzXxx directory directly.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+// shape controls the documents generated.
+type shape struct {
+	depth, fanout int
+	bytes, strlen int
+	kinds         []string
+	weights       []int
+	total         int
+}
+
+func main() {
+	seed := flag.Int64("seed", 1, "random seed")
+	count := flag.Int("n", 1, "number of documents")
+	depth := flag.Int("depth", 3, "levels of nested collections")
+	fanout := flag.Int("fanout", 8, "members per collection")
+	mix := flag.String("mix", "int:4,bigint:1,float:2,string:4,bool:1,null:1,bytes:1", "relative weights of scalar kinds")
+	byteLen := flag.Int("bytes", 32, "length of byte arrays")
+	strlen := flag.Int("strlen", 24, "maximum length of strings")
+	out := flag.String("o", "", "directory to write documents to instead of standard output")
+	name := flag.String("name", "doc", "file name prefix with -o")
+	fuzz := flag.Bool("fuzz", false, "with -o, write Go fuzz corpus files")
+	flag.Parse()
+
+	s := shape{depth: *depth, fanout: *fanout, bytes: *byteLen, strlen: *strlen}
+	if err := s.parseMix(*mix); err != nil {
+		fail(err)
+	}
+	if s.depth < 0 || s.fanout < 1 || s.bytes < 0 || s.strlen < 0 {
+		fail(fmt.Errorf("depth, bytes, and strlen must not be negative, and fanout must be positive"))
+	}
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			fail(err)
+		}
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	if err := s.write(r, *count, *out, *name, *fuzz, os.Stdout); err != nil {
+		fail(err)
+	}
+}
+
+// write writes count documents to stdout, separated by "---" lines, or to
+// files in dir named after name, as Go fuzz corpus files if fuzz is set.
+func (s *shape) write(r *rand.Rand, count int, dir, name string, fuzz bool, stdout io.Writer) error {
+	for i := 0; i < count; i++ {
+		doc, err := yay.Marshal(s.value(r, s.depth))
+		if err != nil {
+			return err
+		}
+		switch {
+		case dir == "":
+			if i > 0 {
+				if _, err := io.WriteString(stdout, "---\n"); err != nil {
+					return err
+				}
+			}
+			if _, err := stdout.Write(doc); err != nil {
+				return err
+			}
+		case fuzz:
+			file := filepath.Join(dir, fmt.Sprintf("%s-%04d", name, i))
+			data := "go test fuzz v1\n[]byte(" + strconv.Quote(string(doc)) + ")\n"
+			if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+				return err
+			}
+		default:
+			file := filepath.Join(dir, fmt.Sprintf("%s-%04d.yay", name, i))
+			if err := os.WriteFile(file, doc, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "yaycorpus: %v\n", err)
+	os.Exit(1)
+}
+
+// parseMix reads kind:weight pairs.
+func (s *shape) parseMix(mix string) error {
+	for _, pair := range strings.Split(mix, ",") {
+		kind, weight, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		w, err := strconv.Atoi(weight)
+		if !ok || err != nil || w < 0 {
+			return fmt.Errorf("bad -mix entry %q, expected kind:weight", pair)
+		}
+		switch kind {
+		case "int", "bigint", "float", "string", "bool", "null", "bytes":
+		default:
+			return fmt.Errorf("unknown scalar kind %q in -mix", kind)
+		}
+		s.kinds = append(s.kinds, kind)
+		s.weights = append(s.weights, w)
+		s.total += w
+	}
+	if s.total == 0 {
+		return fmt.Errorf("-mix has no positive weights")
+	}
+	return nil
+}
+
+// value returns a collection tree depth levels deep, or a scalar at depth 0.
+func (s *shape) value(r *rand.Rand, depth int) any {
+	if depth == 0 {
+		return s.scalar(r)
+	}
+	if r.Intn(2) == 0 {
+		a := make([]any, s.fanout)
+		for i := range a {
+			a[i] = s.value(r, depth-1)
+		}
+		return a
+	}
+	m := make(map[string]any, s.fanout)
+	for i := 0; i < s.fanout; i++ {
+		m[fmt.Sprintf("key%d", i)] = s.value(r, depth-1)
+	}
+	return m
+}
+
+// scalar returns a scalar of a kind drawn from the mix.
+func (s *shape) scalar(r *rand.Rand) any {
+	n := r.Intn(s.total)
+	kind := s.kinds[0]
+	for i, w := range s.weights {
+		if n < w {
+			kind = s.kinds[i]
+			break
+		}
+		n -= w
+	}
+	switch kind {
+	case "int":
+		return big.NewInt(r.Int63n(2000001) - 1000000)
+	case "bigint":
+		n := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), 128))
+		return n.Neg(n)
+	case "float":
+		return math.Round(r.NormFloat64()*1e6) / 1e3
+	case "string":
+		return s.text(r)
+	case "bool":
+		return r.Intn(2) == 0
+	case "bytes":
+		b := make([]byte, s.bytes)
+		r.Read(b)
+		return b
+	}
+	return nil
+}
+
+// words supply the text of strings, with a few that need escapes and a
+// few of more than one byte to the character.
+var words = []string{
+	"spam", "eggs", "ham", `"quoted"`, `back\slash`, "tab\there",
+	"naïve", "café", "日本", "😀", "ni", "shrubbery",
+}
+
+// text returns a string of words, of at most s.strlen characters, the last
+// word cut short if need be.
+func (s *shape) text(r *rand.Rand) string {
+	var b strings.Builder
+	n := 0 // Characters written
+	for n < s.strlen {
+		word := words[r.Intn(len(words))]
+		if n > 0 {
+			word = " " + word
+		}
+		for _, c := range word {
+			if n == s.strlen {
+				break
+			}
+			b.WriteRune(c)
+			n++
+		}
+		if r.Intn(4) == 0 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"kriskowal.com/go/yay"
+)
+
+func TestWords(t *testing.T) {
+	for _, w := range []string{"tab\there", `back\slash`, "日本"} {
+		found := false
+		for _, word := range words {
+			found = found || word == w
+		}
+		if !found {
+			t.Errorf("no word %q among %q", w, words)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, strlen := range []int{0, 1, 3, 24} {
+		s := shape{strlen: strlen}
+		for i := 0; i < 1000; i++ {
+			text := s.text(r)
+			if n := utf8.RuneCountInString(text); n > strlen || text == "" && strlen > 0 {
+				t.Fatalf("-strlen %d: %q has %d characters", strlen, text, n)
+			}
+		}
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	var s shape
+	if err := s.parseMix("int:2, bytes:0,null:1"); err != nil || s.total != 3 || strings.Join(s.kinds, ",") != "int,bytes,null" {
+		t.Errorf("got %+v, %v", s, err)
+	}
+	for _, mix := range []string{"int", "int:x", "int:-1", "char:1", "int:0"} {
+		if err := new(shape).parseMix(mix); err == nil {
+			t.Errorf("%q: got no error", mix)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	s := shape{depth: 2, fanout: 3, bytes: 4, strlen: 8}
+	if err := s.parseMix("int:1,bigint:1,float:1,string:1,bool:1,null:1,bytes:1"); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := s.write(rand.New(rand.NewSource(7)), 3, "", "", false, &out); err != nil {
+		t.Fatal(err)
+	}
+	docs := strings.Split(out.String(), "---\n")
+	if len(docs) != 3 {
+		t.Fatalf("got %d documents:\n%s", len(docs), out.String())
+	}
+	for _, doc := range docs {
+		v, err := yay.Unmarshal([]byte(doc))
+		if err != nil {
+			t.Fatalf("%q: %v", doc, err)
+		}
+		if n := scalars(v); n != 9 {
+			t.Errorf("%q has %d scalars, want 9", doc, n)
+		}
+	}
+
+	// The same seed gives the same documents.
+	var again bytes.Buffer
+	if err := s.write(rand.New(rand.NewSource(7)), 3, "", "", false, &again); err != nil || again.String() != out.String() {
+		t.Errorf("seed 7 again: got %q, %v", again.String(), err)
+	}
+
+	dir := t.TempDir()
+	if err := s.write(rand.New(rand.NewSource(7)), 2, dir, "doc", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "doc-0000.yay")); err != nil || string(data) != docs[0] {
+		t.Errorf("doc-0000.yay: got %q, %v", data, err)
+	}
+	if err := s.write(rand.New(rand.NewSource(7)), 1, dir, "seed", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "seed-0000"))
+	if err != nil || !strings.HasPrefix(string(data), "go test fuzz v1\n[]byte(\"") {
+		t.Errorf("seed-0000: got %q, %v", data, err)
+	}
+}
+
+// scalars counts the scalars of v, a value as Unmarshal returns it.
+func scalars(v any) int {
+	n := 0
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			n += scalars(item)
+		}
+	case map[string]any:
+		for _, item := range v {
+			n += scalars(item)
+		}
+	default:
+		n = 1
+	}
+	return n
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// unifiedDiff returns a unified diff from a to b, the original and
+// formatted text of the file name, or "" if they are the same.
+func unifiedDiff(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+	x := strings.SplitAfter(a, "\n")
+	y := strings.SplitAfter(b, "\n")
+	if x[len(x)-1] == "" {
+		x = x[:len(x)-1]
+	}
+	if y[len(y)-1] == "" {
+		y = y[:len(y)-1]
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Each line of the edit script, with its line numbers in a and b.
+	type line struct {
+		mark byte
+		text string
+		i, j int
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i], i, j})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	for k := 0; k < len(lines); {
+		if lines[k].mark == ' ' {
+			k++
+			continue
+		}
+		// A hunk runs from context lines before this change through
+		// context lines after the last change within twice that of the
+		// one before.
+		start, end := max(k-context, 0), k
+		for end < len(lines) {
+			next := end
+			for next < len(lines) && lines[next].mark == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+			for next < len(lines) && lines[next].mark != ' ' {
+				next++
+			}
+			end = next
+		}
+		end = min(end+context, len(lines))
+		hunk := lines[start:end]
+		var aLen, bLen int
+		for _, l := range hunk {
+			if l.mark != '+' {
+				aLen++
+			}
+			if l.mark != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunk[0].i, aLen), hunkRange(hunk[0].j, bLen))
+		for _, l := range hunk {
+			out.WriteByte(l.mark)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange returns the range of n lines from the zero-based line start as
+// a unified diff writes it.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
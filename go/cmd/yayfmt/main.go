@@ -0,0 +1,141 @@
+// yayfmt formats YAY documents, as gofmt formats Go source, for editors to
+// run on save and continuous integration to enforce.
+//
+// Usage:
+//
+//	yayfmt [-l] [-w] [-d] [path ...]
+//
+// Each path is a file, or a directory whose .yay files are formatted
+// recursively. With no paths, yayfmt formats standard input to standard
+// output. Formatting is yay.Format: canonical indentation and quoting,
+// keeping comments, key order, and the choice of inline or block for each
+// collection.
+//
+// By default, the formatted documents are written to standard output. The
+// flags are:
+//
+//	-l  list the files whose formatting differs, and exit 1 if any does
+//	-w  write the formatted document back to its file
+//	-d  print a unified diff of the changes instead of the document
+//
+// A document that cannot be parsed is reported on standard error, and the
+// exit status is 2.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+var (
+	list  = flag.Bool("l", false, "list files whose formatting differs from yayfmt's")
+	write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	diffs = flag.Bool("d", false, "display diffs instead of rewriting files")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: yayfmt [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	status := 0
+	report := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		status = 2
+	}
+	if flag.NArg() == 0 {
+		if *write {
+			report(fmt.Errorf("yayfmt: cannot use -w with standard input"))
+		} else if err := process("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(status)
+	}
+
+	for _, path := range flag.Args() {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || name != path && !strings.HasSuffix(name, ".yay") {
+				return nil
+			}
+			if err := processFile(name); err != nil {
+				report(err)
+			}
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+	if status == 0 && *list && changed {
+		status = 1
+	}
+	os.Exit(status)
+}
+
+// changed records whether any document's formatting differs.
+var changed bool
+
+// processFile formats the file name as the flags ask.
+func processFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return process(name, f, os.Stdout)
+}
+
+// process formats the document read from in, named name, writing to out
+// what the flags ask for.
+func process(name string, in io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, err := yay.Format(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if bytes.Equal(src, res) {
+		if !*list && !*write && !*diffs {
+			_, err = out.Write(res)
+		}
+		return err
+	}
+	changed = true
+	if *list {
+		fmt.Fprintln(out, name)
+	}
+	if *write {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if *diffs {
+		_, err = io.WriteString(out, unifiedDiff(name, string(src), string(res)))
+		return err
+	}
+	if !*list && !*write {
+		_, err = out.Write(res)
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	src := "a:\n    b: 'x'  # Quoted.\nc: 1\n"
+	var out bytes.Buffer
+	if err := process("a.yay", strings.NewReader(src), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a:\n  b: \"x\"  # Quoted.\nc: 1\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	*diffs = true
+	defer func() { *diffs = false }()
+	out.Reset()
+	if err := process("a.yay", strings.NewReader(src), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "--- a.yay.orig\n+++ a.yay\n@@ -1,3 +1,3 @@\n a:\n-    b: 'x'  # Quoted.\n+  b: \"x\"  # Quoted.\n c: 1\n"
+	if out.String() != want {
+		t.Errorf("diff: got %q, want %q", out.String(), want)
+	}
+
+	if err := process("bad.yay", strings.NewReader("a:\t1\n"), &out); err == nil || !strings.HasPrefix(err.Error(), "bad.yay: ") {
+		t.Errorf("invalid document: got %v", err)
+	}
+
+	*diffs, *list = false, true
+	defer func() { *list = false }()
+	out.Reset()
+	changed = false
+	if err := process("list.yay", strings.NewReader("  - 42\n  - \"hello\"\n"), &out); err != nil || !changed {
+		t.Errorf("-l: got %v, changed %v", err, changed)
+	}
+	if want := "list.yay\n"; out.String() != want {
+		t.Errorf("-l: got %q, want %q", out.String(), want)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	b := strings.Replace(strings.Replace(a, "2\n", "two\n", 1), "15\n", "", 1)
+	want := "--- f.orig\n+++ f\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n@@ -12,5 +12,4 @@\n 12\n 13\n 14\n-15\n 16\n"
+	if got := unifiedDiff("f", a, b); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := unifiedDiff("f", a, a); got != "" {
+		t.Errorf("same: got %q", got)
+	}
+}
@@ -0,0 +1,294 @@
+// Code generated by yaygen -type=Config,Server; DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"kriskowal.com/go/yay"
+)
+
+// MarshalYAY returns the YAY encoding of v.
+func (v *Config) MarshalYAY() ([]byte, error) {
+	return yay.Marshal(v.toYAY())
+}
+
+// UnmarshalYAY decodes a YAY document into v.
+func (v *Config) UnmarshalYAY(data []byte) error {
+	doc, err := yay.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	return v.fromYAY(doc)
+}
+
+// toYAY converts v to the value Marshal encodes for it.
+func (v *Config) toYAY() map[string]any {
+	return map[string]any{
+		"name":    v.Name,
+		"debug":   v.Debug,
+		"level":   string(v.Level),
+		"ratio":   v.Ratio,
+		"retries": new(big.Int).SetUint64(uint64(v.Retries)),
+		"serial": func() any {
+			if v.Serial == nil {
+				return nil
+			}
+			return v.Serial
+		}(),
+		"key":     v.Key,
+		"primary": v.Primary.toYAY(),
+		"backup": func() any {
+			if v.Backup == nil {
+				return nil
+			}
+			return (*v.Backup).toYAY()
+		}(),
+		"servers": func() any {
+			if v.Servers == nil {
+				return nil
+			}
+			a1 := make([]any, len(v.Servers))
+			for i2 := range v.Servers {
+				a1[i2] = v.Servers[i2].toYAY()
+			}
+			return a1
+		}(),
+		"labels": func() any {
+			if v.Labels == nil {
+				return nil
+			}
+			m3 := make(map[string]any, len(v.Labels))
+			for k4, e5 := range v.Labels {
+				m3[k4] = e5
+			}
+			return m3
+		}(),
+		"extra":    v.Extra,
+		"Untagged": big.NewInt(int64(v.Untagged)),
+	}
+}
+
+// fromYAY fills v from a value returned by Unmarshal.
+func (v *Config) fromYAY(doc any) error {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return fmt.Errorf("Expected object for Config, got %T", doc)
+	}
+	if x6 := obj["name"]; x6 != nil {
+		v7, ok := x6.(string)
+		if !ok {
+			return fmt.Errorf("Expected string for Config.name, got %T", x6)
+		}
+		v.Name = v7
+	}
+	if x8 := obj["debug"]; x8 != nil {
+		v9, ok := x8.(bool)
+		if !ok {
+			return fmt.Errorf("Expected bool for Config.debug, got %T", x8)
+		}
+		v.Debug = v9
+	}
+	if x10 := obj["level"]; x10 != nil {
+		v11, ok := x10.(string)
+		if !ok {
+			return fmt.Errorf("Expected string for Config.level, got %T", x10)
+		}
+		v.Level = Level(v11)
+	}
+	if x12 := obj["ratio"]; x12 != nil {
+		var v13 float64
+		switch n := x12.(type) {
+		case float64:
+			v13 = n
+		case *big.Int:
+			v13, _ = new(big.Float).SetInt(n).Float64()
+		default:
+			return fmt.Errorf("Expected number for Config.ratio, got %T", x12)
+		}
+		v.Ratio = v13
+	}
+	if x14 := obj["retries"]; x14 != nil {
+		n16, ok := x14.(*big.Int)
+		if !ok {
+			return fmt.Errorf("Expected integer for Config.retries, got %T", x14)
+		}
+		if !n16.IsUint64() || n16.Uint64() > math.MaxUint8 {
+			return fmt.Errorf("Integer %v out of range for Config.retries", n16)
+		}
+		v15 := n16.Uint64()
+		v.Retries = uint8(v15)
+	}
+	if x17 := obj["serial"]; x17 != nil {
+		n18, ok := x17.(*big.Int)
+		if !ok {
+			return fmt.Errorf("Expected integer for Config.serial, got %T", x17)
+		}
+		v.Serial = n18
+	}
+	if x19 := obj["key"]; x19 != nil {
+		b20, ok := x19.([]byte)
+		if !ok {
+			return fmt.Errorf("Expected bytes for Config.key, got %T", x19)
+		}
+		v.Key = b20
+	}
+	if x21 := obj["primary"]; x21 != nil {
+		if err := v.Primary.fromYAY(x21); err != nil {
+			return err
+		}
+	}
+	if x22 := obj["backup"]; x22 != nil {
+		p23 := new(Server)
+		if err := (*p23).fromYAY(x22); err != nil {
+			return err
+		}
+		v.Backup = p23
+	}
+	if x24 := obj["servers"]; x24 != nil {
+		a25, ok := x24.([]any)
+		if !ok {
+			return fmt.Errorf("Expected array for Config.servers, got %T", x24)
+		}
+		s26 := make([]Server, len(a25))
+		for i27, e28 := range a25 {
+			if e28 != nil {
+				if err := s26[i27].fromYAY(e28); err != nil {
+					return err
+				}
+			}
+		}
+		v.Servers = s26
+	}
+	if x29 := obj["labels"]; x29 != nil {
+		m30, ok := x29.(map[string]any)
+		if !ok {
+			return fmt.Errorf("Expected object for Config.labels, got %T", x29)
+		}
+		o31 := make(map[string]string, len(m30))
+		for k32, e33 := range m30 {
+			var v34 string
+			if e33 != nil {
+				v35, ok := e33.(string)
+				if !ok {
+					return fmt.Errorf("Expected string for Config.labels{}, got %T", e33)
+				}
+				v34 = v35
+			}
+			o31[k32] = v34
+		}
+		v.Labels = o31
+	}
+	if x36 := obj["extra"]; x36 != nil {
+		v.Extra = x36
+	}
+	if x37 := obj["Untagged"]; x37 != nil {
+		n39, ok := x37.(*big.Int)
+		if !ok {
+			return fmt.Errorf("Expected integer for Config.Untagged, got %T", x37)
+		}
+		if !n39.IsInt64() || n39.Int64() < math.MinInt || n39.Int64() > math.MaxInt {
+			return fmt.Errorf("Integer %v out of range for Config.Untagged", n39)
+		}
+		v38 := n39.Int64()
+		v.Untagged = int(v38)
+	}
+	return nil
+}
+
+// MarshalYAY returns the YAY encoding of v.
+func (v *Server) MarshalYAY() ([]byte, error) {
+	return yay.Marshal(v.toYAY())
+}
+
+// UnmarshalYAY decodes a YAY document into v.
+func (v *Server) UnmarshalYAY(data []byte) error {
+	doc, err := yay.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	return v.fromYAY(doc)
+}
+
+// toYAY converts v to the value Marshal encodes for it.
+func (v *Server) toYAY() map[string]any {
+	return map[string]any{
+		"host": v.Host,
+		"port": big.NewInt(int64(v.Port)),
+		"tags": func() any {
+			if v.Tags == nil {
+				return nil
+			}
+			a40 := make([]any, len(v.Tags))
+			for i41 := range v.Tags {
+				a40[i41] = v.Tags[i41]
+			}
+			return a40
+		}(),
+		"limit": func() any {
+			if v.Limit == nil {
+				return nil
+			}
+			return big.NewInt(int64((*v.Limit)))
+		}(),
+	}
+}
+
+// fromYAY fills v from a value returned by Unmarshal.
+func (v *Server) fromYAY(doc any) error {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return fmt.Errorf("Expected object for Server, got %T", doc)
+	}
+	if x42 := obj["host"]; x42 != nil {
+		v43, ok := x42.(string)
+		if !ok {
+			return fmt.Errorf("Expected string for Server.host, got %T", x42)
+		}
+		v.Host = v43
+	}
+	if x44 := obj["port"]; x44 != nil {
+		n46, ok := x44.(*big.Int)
+		if !ok {
+			return fmt.Errorf("Expected integer for Server.port, got %T", x44)
+		}
+		if !n46.IsInt64() || n46.Int64() < math.MinInt || n46.Int64() > math.MaxInt {
+			return fmt.Errorf("Integer %v out of range for Server.port", n46)
+		}
+		v45 := n46.Int64()
+		v.Port = int(v45)
+	}
+	if x47 := obj["tags"]; x47 != nil {
+		a48, ok := x47.([]any)
+		if !ok {
+			return fmt.Errorf("Expected array for Server.tags, got %T", x47)
+		}
+		s49 := make([]string, len(a48))
+		for i50, e51 := range a48 {
+			if e51 != nil {
+				v52, ok := e51.(string)
+				if !ok {
+					return fmt.Errorf("Expected string for Server.tags[], got %T", e51)
+				}
+				s49[i50] = v52
+			}
+		}
+		v.Tags = s49
+	}
+	if x53 := obj["limit"]; x53 != nil {
+		p54 := new(int32)
+		n56, ok := x53.(*big.Int)
+		if !ok {
+			return fmt.Errorf("Expected integer for Server.limit, got %T", x53)
+		}
+		if !n56.IsInt64() || n56.Int64() < math.MinInt32 || n56.Int64() > math.MaxInt32 {
+			return fmt.Errorf("Integer %v out of range for Server.limit", n56)
+		}
+		v55 := n56.Int64()
+		(*p54) = int32(v55)
+		v.Limit = p54
+	}
+	return nil
+}
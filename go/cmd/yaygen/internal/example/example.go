@@ -0,0 +1,36 @@
+// Package example holds types for exercising the code yaygen generates.
+package example
+
+import "math/big"
+
+//go:generate go run ../.. -type=Config,Server
+
+// Level is a named string type.
+type Level string
+
+// Config is a typical configuration document.
+type Config struct {
+	Name     string            `yay:"name"`
+	Debug    bool              `yay:"debug"`
+	Level    Level             `yay:"level"`
+	Ratio    float64           `yay:"ratio"`
+	Retries  uint8             `yay:"retries"`
+	Serial   *big.Int          `yay:"serial"`
+	Key      []byte            `yay:"key"`
+	Primary  Server            `yay:"primary"`
+	Backup   *Server           `yay:"backup"`
+	Servers  []Server          `yay:"servers"`
+	Labels   map[string]string `yay:"labels"`
+	Extra    any               `yay:"extra"`
+	Ignored  string            `yay:"-"`
+	Untagged int
+	internal int
+}
+
+// Server is a nested configuration object.
+type Server struct {
+	Host  string   `yay:"host"`
+	Port  int      `yay:"port"`
+	Tags  []string `yay:"tags"`
+	Limit *int32   `yay:"limit"`
+}
@@ -0,0 +1,151 @@
+package example
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalYAY(t *testing.T) {
+	doc := `name: "edge"
+debug: true
+level: "warn"
+ratio: 1
+retries: 3
+serial: 12345678901234567890
+key: <cafe>
+primary:
+  host: "a.example"
+  port: 443
+  limit: 10
+backup: null
+servers:
+- host: "b.example"
+  tags: ["x", "y"]
+- null
+labels: {env: "prod"}
+extra: [1, "two"]
+Untagged: -1
+`
+	var c Config
+	if err := c.UnmarshalYAY([]byte(doc)); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "edge" || !c.Debug || c.Level != "warn" || c.Ratio != 1 || c.Retries != 3 || c.Untagged != -1 {
+		t.Errorf("scalars: got %+v", c)
+	}
+	if c.Serial.String() != "12345678901234567890" || string(c.Key) != "\xca\xfe" {
+		t.Errorf("serial/key: got %v, %x", c.Serial, c.Key)
+	}
+	if c.Primary.Host != "a.example" || c.Primary.Port != 443 || c.Primary.Limit == nil || *c.Primary.Limit != 10 {
+		t.Errorf("primary: got %+v", c.Primary)
+	}
+	if c.Backup != nil {
+		t.Errorf("backup: got %+v", c.Backup)
+	}
+	if len(c.Servers) != 2 || c.Servers[0].Host != "b.example" || strings.Join(c.Servers[0].Tags, ",") != "x,y" {
+		t.Errorf("servers: got %+v", c.Servers)
+	}
+	if c.Labels["env"] != "prod" {
+		t.Errorf("labels: got %v", c.Labels)
+	}
+	if extra, ok := c.Extra.([]any); !ok || len(extra) != 2 {
+		t.Errorf("extra: got %#v", c.Extra)
+	}
+}
+
+func TestUnmarshalYAYErrors(t *testing.T) {
+	cases := []struct {
+		doc  string
+		want string
+	}{
+		{`[1]`, "Expected object for Config, got []interface {}"},
+		{`name: 1`, "Expected string for Config.name, got *big.Int"},
+		{`retries: 256`, "Integer 256 out of range for Config.retries"},
+		{"servers:\n- port: \"80\"", "Expected integer for Server.port, got string"},
+		{`labels: {a: true}`, "Expected string for Config.labels{}, got bool"},
+	}
+	for _, c := range cases {
+		var cfg Config
+		err := cfg.UnmarshalYAY([]byte(c.doc))
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.doc, err, c.want)
+		}
+	}
+}
+
+func TestMarshalYAY(t *testing.T) {
+	limit := int32(10)
+	serial, _ := new(big.Int).SetString("12345678901234567890", 10)
+	c := Config{
+		Name:     "edge",
+		Debug:    true,
+		Level:    "warn",
+		Ratio:    0.5,
+		Retries:  3,
+		Serial:   serial,
+		Key:      []byte{0xca, 0xfe},
+		Primary:  Server{Host: "a.example", Port: 443, Limit: &limit},
+		Servers:  []Server{{Host: "b.example", Tags: []string{"x", "y"}}},
+		Labels:   map[string]string{"env": "prod"},
+		Extra:    []any{big.NewInt(1), "two"},
+		Ignored:  "not written",
+		Untagged: -1,
+	}
+	data, err := c.MarshalYAY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Untagged: -1
+backup: null
+debug: true
+extra: [1, "two"]
+key: <cafe>
+labels: {env: "prod"}
+level: "warn"
+name: "edge"
+primary:
+  host: "a.example"
+  limit: 10
+  port: 443
+  tags: null
+ratio: 0.5
+retries: 3
+serial: 12345678901234567890
+servers:
+  - host: "b.example"
+    limit: null
+    port: 0
+    tags: ["x", "y"]
+`
+	if string(data) != want {
+		t.Errorf("MarshalYAY:\ngot:\n%s\nwant:\n%s", data, want)
+	}
+
+	// The document reads back as the value written, but for the field
+	// left out.
+	var got Config
+	if err := got.UnmarshalYAY(data); err != nil {
+		t.Fatalf("UnmarshalYAY(%q): %v", data, err)
+	}
+	c.Ignored = ""
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("round trip through %q:\ngot:  %+v\nwant: %+v", data, got, c)
+	}
+}
+
+func TestMarshalYAYZero(t *testing.T) {
+	var s Server
+	data, err := s.MarshalYAY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "host: \"\"\nlimit: null\nport: 0\ntags: null\n"; string(data) != want {
+		t.Errorf("MarshalYAY: got %q, want %q", data, want)
+	}
+	var got Server
+	if err := got.UnmarshalYAY(data); err != nil || !reflect.DeepEqual(got, s) {
+		t.Errorf("round trip through %q: got %+v, %v", data, got, err)
+	}
+}
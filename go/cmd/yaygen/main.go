@@ -0,0 +1,550 @@
+// yaygen generates MarshalYAY and UnmarshalYAY methods for Go struct types,
+// so that services decoding YAY on request paths can move values in and out
+// of structs without reflection.
+//
+// Usage:
+//
+//	//go:generate go run kriskowal.com/go/yay/cmd/yaygen -type=Config,Server
+//
+// yaygen reads the Go package in the current directory (or the directory
+// given as its argument) and writes the methods for the named types to
+// <type>_yay.go, named after the first type. For each type T it emits:
+//
+//	func (v *T) MarshalYAY() ([]byte, error)
+//	func (v *T) UnmarshalYAY(data []byte) error
+//
+// Object keys come from `yay:"name"` struct tags, defaulting to the field
+// name; a tag of "-" skips the field. Supported field types are strings,
+// booleans, integers, floats, []byte, *big.Int, any, other types named in
+// -type, types of this package whose underlying type is one of these, and
+// pointers, slices, and string-keyed maps of any supported type.
+//
+// Decoding ignores unknown keys and leaves fields whose keys are missing or
+// null untouched.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output    = flag.String("output", "", "output file name; default srcdir/<type>_yay.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: yaygen -type=T[,T...] [-output=file] [directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("yaygen: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(pkg, names, "yaygen "+strings.Join(os.Args[1:], " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outName := *output
+	if outName == "" {
+		outName = filepath.Join(dir, strings.ToLower(names[0])+"_yay.go")
+	}
+	if err := os.WriteFile(outName, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pkgInfo is what yaygen needs to know about the source package: its name
+// and the type expression of each type it declares.
+type pkgInfo struct {
+	name  string
+	types map[string]ast.Expr
+}
+
+// loadPackage parses the non-test Go files in dir.
+func loadPackage(dir string) (*pkgInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	pkg := &pkgInfo{types: map[string]ast.Expr{}}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = file.Name.Name
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				pkg.types[ts.Name.Name] = ts.Type
+			}
+		}
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+// yayImport is the import path of the yay package.
+const yayImport = "kriskowal.com/go/yay"
+
+// generate returns the formatted source of the methods for the named types.
+func generate(pkg *pkgInfo, names []string, command string) ([]byte, error) {
+	g := &generator{
+		pkg:     pkg,
+		structs: map[string]bool{},
+		imports: map[string]bool{},
+	}
+	for _, name := range names {
+		if _, ok := pkg.types[name].(*ast.StructType); !ok {
+			return nil, fmt.Errorf("%s is not a struct type in package %s", name, pkg.name)
+		}
+		g.structs[name] = true
+	}
+	for _, name := range names {
+		if err := g.genType(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by %s; DO NOT EDIT.\n\n", command)
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkg.name)
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	for _, path := range imports {
+		if path != yayImport {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	fmt.Fprintf(&out, "\n\t%q\n)\n", yayImport)
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// generator accumulates the generated methods.
+type generator struct {
+	pkg     *pkgInfo
+	structs map[string]bool // Types being generated
+	imports map[string]bool
+	buf     bytes.Buffer
+	tmp     int // Counter for fresh variable names
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// fresh returns a variable name not used elsewhere in the generated code.
+func (g *generator) fresh(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+// genField is a struct field that takes part in encoding.
+type genField struct {
+	name string   // Go field name
+	key  string   // Object key
+	typ  ast.Expr // Field type
+}
+
+// fields lists the encoded fields of struct type name.
+func (g *generator) fields(name string) ([]genField, error) {
+	st := g.pkg.types[name].(*ast.StructType)
+	var fields []genField
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded field %s is not supported", name, types.ExprString(f.Type))
+		}
+		var tag string
+		if f.Tag != nil {
+			raw, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(raw).Get("yay")
+		}
+		if tag == "-" {
+			continue
+		}
+		key, _, _ := strings.Cut(tag, ",")
+		for _, id := range f.Names {
+			if !id.IsExported() {
+				continue
+			}
+			k := key
+			if k == "" {
+				k = id.Name
+			}
+			fields = append(fields, genField{name: id.Name, key: k, typ: f.Type})
+		}
+	}
+	return fields, nil
+}
+
+// genType emits the methods for struct type name.
+func (g *generator) genType(name string) error {
+	fields, err := g.fields(name)
+	if err != nil {
+		return err
+	}
+
+	g.printf("\n// MarshalYAY returns the YAY encoding of v.\n")
+	g.printf("func (v *%s) MarshalYAY() ([]byte, error) {\n", name)
+	g.printf("return yay.Marshal(v.toYAY())\n}\n")
+
+	g.printf("\n// UnmarshalYAY decodes a YAY document into v.\n")
+	g.printf("func (v *%s) UnmarshalYAY(data []byte) error {\n", name)
+	g.printf("doc, err := yay.Unmarshal(data)\nif err != nil {\nreturn err\n}\n")
+	g.printf("return v.fromYAY(doc)\n}\n")
+
+	g.printf("\n// toYAY converts v to the value Marshal encodes for it.\n")
+	g.printf("func (v *%s) toYAY() map[string]any {\n", name)
+	g.printf("return map[string]any{\n")
+	for _, f := range fields {
+		expr, err := g.encode(f.typ, "v."+f.name)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+		g.printf("%q: %s,\n", f.key, expr)
+	}
+	g.printf("}\n}\n")
+
+	g.imports["fmt"] = true
+	g.printf("\n// fromYAY fills v from a value returned by Unmarshal.\n")
+	g.printf("func (v *%s) fromYAY(doc any) error {\n", name)
+	g.printf("obj, ok := doc.(map[string]any)\nif !ok {\n")
+	g.printf("return fmt.Errorf(\"Expected object for %s, got %%T\", doc)\n}\n", name)
+	for _, f := range fields {
+		src := g.fresh("x")
+		g.printf("if %s := obj[%q]; %s != nil {\n", src, f.key, src)
+		if err := g.decode(f.typ, "v."+f.name, src, name+"."+f.key); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// basicKind resolves t to one of the predeclared types yaygen supports,
+// following named types declared in the package. It returns "" for any
+// other type.
+func (g *generator) basicKind(t ast.Expr) string {
+	for depth := 0; depth < 10; depth++ {
+		id, ok := t.(*ast.Ident)
+		if !ok {
+			return ""
+		}
+		switch id.Name {
+		case "string", "bool", "float32", "float64",
+			"int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64":
+			return id.Name
+		case "byte":
+			return "uint8"
+		case "rune":
+			return "int32"
+		}
+		if t, ok = g.pkg.types[id.Name]; !ok {
+			return ""
+		}
+	}
+	return ""
+}
+
+// isBigInt reports whether t is *big.Int.
+func isBigInt(t ast.Expr) bool {
+	star, ok := t.(*ast.StarExpr)
+	return ok && types.ExprString(star.X) == "big.Int"
+}
+
+// isAny reports whether t is the empty interface.
+func isAny(t ast.Expr) bool {
+	if id, ok := t.(*ast.Ident); ok && id.Name == "any" {
+		return true
+	}
+	it, ok := t.(*ast.InterfaceType)
+	return ok && len(it.Methods.List) == 0
+}
+
+// isBytes reports whether t is []byte.
+func isBytes(t ast.Expr) bool {
+	at, ok := t.(*ast.ArrayType)
+	if !ok || at.Len != nil {
+		return false
+	}
+	id, ok := at.Elt.(*ast.Ident)
+	return ok && (id.Name == "byte" || id.Name == "uint8")
+}
+
+// encode returns an expression converting x, of type t, to a decoded value.
+func (g *generator) encode(t ast.Expr, x string) (string, error) {
+	if id, ok := t.(*ast.Ident); ok && g.structs[id.Name] {
+		return x + ".toYAY()", nil
+	}
+	if kind := g.basicKind(t); kind != "" {
+		switch {
+		case kind == "string" || kind == "bool" || kind == "float64":
+			return convert(kind, types.ExprString(t), x), nil
+		case kind == "float32":
+			return fmt.Sprintf("float64(%s)", x), nil
+		case strings.HasPrefix(kind, "uint"):
+			g.imports["math/big"] = true
+			return fmt.Sprintf("new(big.Int).SetUint64(uint64(%s))", x), nil
+		default:
+			g.imports["math/big"] = true
+			return fmt.Sprintf("big.NewInt(int64(%s))", x), nil
+		}
+	}
+	switch {
+	case isAny(t):
+		return x, nil
+	case isBytes(t):
+		return convert("[]byte", types.ExprString(t), x), nil
+	case isBigInt(t):
+		return fmt.Sprintf("func() any {\nif %s == nil {\nreturn nil\n}\nreturn %s\n}()", x, x), nil
+	}
+
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		inner, err := g.encode(t.X, "(*"+x+")")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("func() any {\nif %s == nil {\nreturn nil\n}\nreturn %s\n}()", x, inner), nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break
+		}
+		arr, i := g.fresh("a"), g.fresh("i")
+		elem, err := g.encode(t.Elt, x+"["+i+"]")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("func() any {\nif %[1]s == nil {\nreturn nil\n}\n"+
+			"%[2]s := make([]any, len(%[1]s))\n"+
+			"for %[3]s := range %[1]s {\n%[2]s[%[3]s] = %[4]s\n}\n"+
+			"return %[2]s\n}()", x, arr, i, elem), nil
+	case *ast.MapType:
+		if g.basicKind(t.Key) != "string" {
+			break
+		}
+		m, k, e := g.fresh("m"), g.fresh("k"), g.fresh("e")
+		elem, err := g.encode(t.Value, e)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("func() any {\nif %[1]s == nil {\nreturn nil\n}\n"+
+			"%[2]s := make(map[string]any, len(%[1]s))\n"+
+			"for %[3]s, %[4]s := range %[1]s {\n%[2]s[%[6]s] = %[5]s\n}\n"+
+			"return %[2]s\n}()", x, m, k, e, elem, convert("string", types.ExprString(t.Key), k)), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", types.ExprString(t))
+}
+
+// decode emits statements that convert src, a non-nil decoded value, to
+// type t and store it in target. Error messages name the value by path.
+func (g *generator) decode(t ast.Expr, target, src, path string) error {
+	if id, ok := t.(*ast.Ident); ok && g.structs[id.Name] {
+		g.printf("if err := %s.fromYAY(%s); err != nil {\nreturn err\n}\n", target, src)
+		return nil
+	}
+	if kind := g.basicKind(t); kind != "" {
+		g.decodeBasic(kind, types.ExprString(t), target, src, path)
+		return nil
+	}
+	switch {
+	case isAny(t):
+		g.printf("%s = %s\n", target, src)
+		return nil
+	case isBytes(t):
+		b := g.fresh("b")
+		g.printf("%s, ok := %s.([]byte)\nif !ok {\n", b, src)
+		g.printf("return fmt.Errorf(\"Expected bytes for %s, got %%T\", %s)\n}\n", path, src)
+		g.printf("%s = %s\n", target, convert(types.ExprString(t), "[]byte", b))
+		return nil
+	case isBigInt(t):
+		g.imports["math/big"] = true
+		n := g.fresh("n")
+		g.printf("%s, ok := %s.(*big.Int)\nif !ok {\n", n, src)
+		g.printf("return fmt.Errorf(\"Expected integer for %s, got %%T\", %s)\n}\n", path, src)
+		g.printf("%s = %s\n", target, n)
+		return nil
+	}
+
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		p := g.fresh("p")
+		g.printf("%s := new(%s)\n", p, types.ExprString(t.X))
+		if err := g.decode(t.X, "(*"+p+")", src, path); err != nil {
+			return err
+		}
+		g.printf("%s = %s\n", target, p)
+		return nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break
+		}
+		arr, out, i, e := g.fresh("a"), g.fresh("s"), g.fresh("i"), g.fresh("e")
+		g.printf("%s, ok := %s.([]any)\nif !ok {\n", arr, src)
+		g.printf("return fmt.Errorf(\"Expected array for %s, got %%T\", %s)\n}\n", path, src)
+		g.printf("%s := make(%s, len(%s))\n", out, types.ExprString(t), arr)
+		g.printf("for %s, %s := range %s {\n", i, e, arr)
+		if err := g.decodeElem(t.Elt, out+"["+i+"]", e, path+"[]"); err != nil {
+			return err
+		}
+		g.printf("}\n%s = %s\n", target, out)
+		return nil
+	case *ast.MapType:
+		if g.basicKind(t.Key) != "string" {
+			break
+		}
+		m, out, k, e, val := g.fresh("m"), g.fresh("o"), g.fresh("k"), g.fresh("e"), g.fresh("v")
+		g.printf("%s, ok := %s.(map[string]any)\nif !ok {\n", m, src)
+		g.printf("return fmt.Errorf(\"Expected object for %s, got %%T\", %s)\n}\n", path, src)
+		g.printf("%s := make(%s, len(%s))\n", out, types.ExprString(t), m)
+		g.printf("for %s, %s := range %s {\n", k, e, m)
+		g.printf("var %s %s\n", val, types.ExprString(t.Value))
+		if err := g.decodeElem(t.Value, val, e, path+"{}"); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n", out, convert(types.ExprString(t.Key), "string", k), val)
+		g.printf("%s = %s\n", target, out)
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", types.ExprString(t))
+}
+
+// decodeElem is decode for an array element or object value, which may be
+// null. Null elements are left as the zero value.
+func (g *generator) decodeElem(t ast.Expr, target, src, path string) error {
+	g.printf("if %s != nil {\n", src)
+	if err := g.decode(t, target, src, path); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// decodeBasic emits the conversion of src to typ, a type whose underlying
+// type is the predeclared type kind.
+func (g *generator) decodeBasic(kind, typ, target, src, path string) {
+	v := g.fresh("v")
+	switch {
+	case kind == "string" || kind == "bool":
+		g.printf("%s, ok := %s.(%s)\nif !ok {\n", v, src, kind)
+		g.printf("return fmt.Errorf(\"Expected %s for %s, got %%T\", %s)\n}\n", kind, path, src)
+	case strings.HasPrefix(kind, "float"):
+		g.imports["math/big"] = true
+		g.printf("var %s float64\nswitch n := %s.(type) {\ncase float64:\n%s = n\n", v, src, v)
+		g.printf("case *big.Int:\n%s, _ = new(big.Float).SetInt(n).Float64()\n", v)
+		g.printf("default:\nreturn fmt.Errorf(\"Expected number for %s, got %%T\", %s)\n}\n", path, src)
+	default:
+		g.imports["math/big"] = true
+		n := g.fresh("n")
+		g.printf("%s, ok := %s.(*big.Int)\nif !ok {\n", n, src)
+		g.printf("return fmt.Errorf(\"Expected integer for %s, got %%T\", %s)\n}\n", path, src)
+		g.printf("if %s {\n", rangeCheck(kind, n))
+		g.printf("return fmt.Errorf(\"Integer %%v out of range for %s\", %s)\n}\n", path, n)
+		if strings.HasPrefix(kind, "uint") {
+			g.printf("%s := %s.Uint64()\n", v, n)
+		} else {
+			g.printf("%s := %s.Int64()\n", v, n)
+		}
+		if kind != "int64" && kind != "uint64" {
+			g.imports["math"] = true
+		}
+	}
+	have := kind
+	if strings.HasPrefix(kind, "float") {
+		have = "float64"
+	} else if strings.HasPrefix(kind, "uint") {
+		have = "uint64"
+	} else if strings.HasPrefix(kind, "int") {
+		have = "int64"
+	}
+	g.printf("%s = %s\n", target, convert(typ, have, v))
+}
+
+// convert returns x, of type from, converted to type to.
+func convert(to, from, x string) string {
+	if to == from {
+		return x
+	}
+	return to + "(" + x + ")"
+}
+
+// rangeCheck returns a condition that holds when big.Int n does not fit in
+// integer type kind.
+func rangeCheck(kind, n string) string {
+	bounds := map[string][2]string{
+		"int":    {"math.MinInt", "math.MaxInt"},
+		"int8":   {"math.MinInt8", "math.MaxInt8"},
+		"int16":  {"math.MinInt16", "math.MaxInt16"},
+		"int32":  {"math.MinInt32", "math.MaxInt32"},
+		"uint":   {"", "math.MaxUint"},
+		"uint8":  {"", "math.MaxUint8"},
+		"uint16": {"", "math.MaxUint16"},
+		"uint32": {"", "math.MaxUint32"},
+	}
+	switch kind {
+	case "int64":
+		return "!" + n + ".IsInt64()"
+	case "uint64":
+		return "!" + n + ".IsUint64()"
+	}
+	b := bounds[kind]
+	if b[0] == "" {
+		return fmt.Sprintf("!%[1]s.IsUint64() || %[1]s.Uint64() > %[2]s", n, b[1])
+	}
+	return fmt.Sprintf("!%[1]s.IsInt64() || %[1]s.Int64() < %[2]s || %[1]s.Int64() > %[3]s", n, b[0], b[1])
+}
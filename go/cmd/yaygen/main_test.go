@@ -0,0 +1,59 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedExampleIsCurrent(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(pkg, []string{"Config", "Server"}, "yaygen -type=Config,Server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "config_yay.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("config_yay.go is stale; run go generate in %s", dir)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"type T int", "T is not a struct type"},
+		{"type T struct{ C chan int }", "T.C: unsupported type chan int"},
+		{"type T struct{ M map[int]string }", "T.M: unsupported type map[int]string"},
+		{"type U struct{}\ntype T struct{ U }", "T: embedded field U is not supported"},
+	}
+	for _, c := range cases {
+		file, err := parser.ParseFile(token.NewFileSet(), "t.go", "package p\n"+c.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := &pkgInfo{name: "p", types: map[string]ast.Expr{}}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok {
+				pkg.types[ts.Name.Name] = ts.Type
+			}
+			return true
+		})
+		_, err = generate(pkg, []string{"T"}, "yaygen")
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: got %v, want %q", c.src, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+//go:build js && wasm
+
+// Command yaywasm exposes the YAY decoder and encoder to JavaScript, for an
+// interactive playground and for validating examples in documentation.
+//
+// Build it with the js/wasm port and load it with the wasm_exec.js that
+// ships with Go:
+//
+//	GOOS=js GOARCH=wasm go build -o yay.wasm ./cmd/yaywasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+//	const go = new Go();
+//	const { instance } = await WebAssembly.instantiateStreaming(fetch("yay.wasm"), go.importObject);
+//	go.run(instance);
+//	yay.parse("a: 1\n", "example.yay"); // { value: { a: 1 } }
+//
+// Once running, it defines a global yay object with three functions, each
+// of which takes the text of a document and an optional filename, which
+// errors mention with their positions:
+//
+//   - parse(text, filename) returns { value } with the value of the
+//     document, or { error, code } with the message and code of the error.
+//     Integers are numbers where a number holds them exactly, and BigInts
+//     otherwise; byte arrays are Uint8Arrays.
+//   - format(text, filename) returns { text } with the document in the
+//     canonical layout, as yay.Format writes it, keeping its comments and
+//     the order of its properties, or { error, code }.
+//   - validate(text, filename) returns { valid: true }, or { valid: false,
+//     error, code }.
+package main
+
+import (
+	"math/big"
+	"syscall/js"
+
+	"kriskowal.com/go/yay"
+)
+
+func main() {
+	js.Global().Set("yay", map[string]any{
+		"parse":    js.FuncOf(parse),
+		"format":   js.FuncOf(format),
+		"validate": js.FuncOf(validate),
+	})
+	select {} // Serve calls from JavaScript until the page goes away.
+}
+
+// document returns the document given by the arguments of a call from
+// JavaScript: its text and an optional filename.
+func document(args []js.Value) (text []byte, filename string) {
+	if len(args) > 0 {
+		text = []byte(args[0].String())
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeString {
+		filename = args[1].String()
+	}
+	return text, filename
+}
+
+// decode decodes the document given by the arguments of a call from
+// JavaScript.
+func decode(args []js.Value) (any, error) {
+	return yay.UnmarshalFile(document(args))
+}
+
+// failure returns the result for err, with the other properties given.
+func failure(err error, props map[string]any) map[string]any {
+	props["error"] = err.Error()
+	props["code"] = yay.ErrorCode(err)
+	return props
+}
+
+func parse(_ js.Value, args []js.Value) any {
+	v, err := decode(args)
+	if err != nil {
+		return failure(err, map[string]any{})
+	}
+	return map[string]any{"value": toJS(v)}
+}
+
+func format(_ js.Value, args []js.Value) any {
+	// Decoding first gives errors the position of the filename.
+	if _, err := decode(args); err != nil {
+		return failure(err, map[string]any{})
+	}
+	text, _ := document(args)
+	text, err := yay.Format(text)
+	if err != nil {
+		return failure(err, map[string]any{})
+	}
+	return map[string]any{"text": string(text)}
+}
+
+func validate(_ js.Value, args []js.Value) any {
+	if _, err := decode(args); err != nil {
+		return failure(err, map[string]any{"valid": false})
+	}
+	return map[string]any{"valid": true}
+}
+
+// maxSafeInteger is the largest integer a JavaScript number holds exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// toJS returns the JavaScript value for v, a value that Unmarshal returns.
+func toJS(v any) js.Value {
+	switch v := v.(type) {
+	case nil:
+		return js.Null()
+	case *big.Int:
+		if v.IsInt64() && v.Int64() >= -maxSafeInteger && v.Int64() <= maxSafeInteger {
+			return js.ValueOf(float64(v.Int64()))
+		}
+		return js.Global().Call("BigInt", v.String())
+	case []byte:
+		a := js.Global().Get("Uint8Array").New(len(v))
+		js.CopyBytesToJS(a, v)
+		return a
+	case []any:
+		a := js.Global().Get("Array").New(len(v))
+		for i, item := range v {
+			a.SetIndex(i, toJS(item))
+		}
+		return a
+	case map[string]any:
+		o := js.Global().Get("Object").New()
+		for k, item := range v {
+			o.Set(k, toJS(item))
+		}
+		return o
+	}
+	return js.ValueOf(v) // bool, float64, and string
+}
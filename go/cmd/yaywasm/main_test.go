@@ -0,0 +1,65 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+)
+
+// call calls f as JavaScript would, with the given arguments.
+func call(f func(js.Value, []js.Value) any, args ...any) js.Value {
+	values := make([]js.Value, len(args))
+	for i, arg := range args {
+		values[i] = js.ValueOf(arg)
+	}
+	return js.ValueOf(f(js.Undefined(), values))
+}
+
+func TestParse(t *testing.T) {
+	got := call(parse, "a: 1\nb: [true, \"x\"]\nc: <cafe>\nd: 12345678901234567890\n", "example.yay").Get("value")
+	if a := got.Get("a"); a.Type() != js.TypeNumber || a.Int() != 1 {
+		t.Errorf("a: got %v", a)
+	}
+	if b := got.Get("b"); b.Length() != 2 || !b.Index(0).Bool() || b.Index(1).String() != "x" {
+		t.Errorf("b: got %v", b)
+	}
+	if c := got.Get("c"); !c.InstanceOf(js.Global().Get("Uint8Array")) || c.Length() != 2 || c.Index(0).Int() != 0xca {
+		t.Errorf("c: got %v", c)
+	}
+	if d := got.Get("d"); !d.Equal(js.Global().Call("BigInt", "12345678901234567890")) {
+		t.Errorf("d: got %v", d)
+	}
+
+	res := call(parse, "a:\t1\n", "example.yay")
+	if res.Get("error").String() != "Tab not allowed (use spaces) at 1:3 of <example.yay>" || res.Get("code").String() != "tab" {
+		t.Errorf("error: got %v, %v", res.Get("error"), res.Get("code"))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	// Comments and the order of properties are kept.
+	got := call(format, "# Header.\nb: 'x'  # Note.\na:\n    - 1\n")
+	if want := "# Header.\nb: \"x\"  # Note.\na:\n  - 1\n"; got.Get("text").String() != want {
+		t.Errorf("got %q, want %q", got.Get("text").String(), want)
+	}
+
+	res := call(format, "a: [\n", "example.yay")
+	if res.Get("error").Type() != js.TypeString || res.Get("code").Type() != js.TypeString {
+		t.Errorf("error: got %v, %v", res.Get("error"), res.Get("code"))
+	}
+	if msg := res.Get("error").String(); !strings.HasSuffix(msg, " of <example.yay>") {
+		t.Errorf("error: got %q, want the filename", msg)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if got := call(validate, "a: 1\n"); !got.Get("valid").Bool() {
+		t.Errorf("got %v", got)
+	}
+	got := call(validate, "a: 1  \n", "example.yay")
+	if got.Get("valid").Bool() || got.Get("error").String() != "Unexpected trailing space at 1:6 of <example.yay>" {
+		t.Errorf("got %v, %v", got.Get("valid"), got.Get("error"))
+	}
+}
@@ -0,0 +1,130 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ============================================================================
+// Codecs
+// ============================================================================
+//
+// A codec gives the representation of a Go type that this package knows
+// nothing of, such as a decimal or a UUID from another module, as a YAY
+// value: Marshal writes the value the codec's MarshalFunc returns in place
+// of the Go value, and a Decoder turns a decoded value back into the Go
+// type with its UnmarshalFunc. Codecs apply to the exact type they are
+// added for, not to pointers to it or to other types of the same
+// underlying type.
+//
+// Codecs are added to a Codecs set given to EncodeOptions and
+// DecodeOptions, or registered for the whole program with RegisterCodec.
+// The codecs of a set take precedence over those registered globally,
+// which take precedence over the built-in codecs for standard library
+// types in adapters.go.
+
+// MarshalFunc returns the YAY value that represents v, of any of the types
+// Marshal accepts. The value may itself contain values of types with
+// codecs, but not of type T.
+type MarshalFunc[T any] func(v T) (any, error)
+
+// UnmarshalFunc returns the T that a decoded YAY value represents, or an
+// error if the value does not represent one.
+type UnmarshalFunc[T any] func(v any) (T, error)
+
+// Codecs is a set of codecs by the types they convert. The zero value is
+// an empty set ready to use. A Codecs may be used by several encoders and
+// decoders at once, and have codecs added meanwhile.
+type Codecs struct {
+	mu     sync.RWMutex
+	byType map[reflect.Type]codec
+}
+
+// codec is a MarshalFunc and UnmarshalFunc with their types erased, either
+// of which may be nil.
+type codec struct {
+	marshal   func(v any) (any, error)
+	unmarshal func(v any) (any, error)
+}
+
+// globalCodecs holds the codecs added by RegisterCodec.
+var globalCodecs Codecs
+
+// AddCodec adds to c the codec for type T made of marshal and unmarshal,
+// replacing any codec for T that c had. Either function may be nil, for a
+// type that is only encoded or only decoded.
+//
+//	var codecs yay.Codecs
+//	yay.AddCodec(&codecs,
+//		func(d decimal.Decimal) (any, error) { return d.String(), nil },
+//		func(v any) (decimal.Decimal, error) {
+//			s, err := yay.AsString(v)
+//			if err != nil {
+//				return decimal.Decimal{}, err
+//			}
+//			return decimal.NewFromString(s)
+//		})
+func AddCodec[T any](c *Codecs, marshal MarshalFunc[T], unmarshal UnmarshalFunc[T]) {
+	var k codec
+	if marshal != nil {
+		k.marshal = func(v any) (any, error) { return marshal(v.(T)) }
+	}
+	if unmarshal != nil {
+		k.unmarshal = func(v any) (any, error) { return unmarshal(v) }
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byType == nil {
+		c.byType = make(map[reflect.Type]codec)
+	}
+	c.byType[reflect.TypeOf((*T)(nil)).Elem()] = k
+}
+
+// RegisterCodec adds the codec for type T to those every encoder and
+// decoder uses, as AddCodec would. It is meant to be called from init
+// functions, by the package that owns T or by the program.
+func RegisterCodec[T any](marshal MarshalFunc[T], unmarshal UnmarshalFunc[T]) {
+	AddCodec(&globalCodecs, marshal, unmarshal)
+}
+
+// lookup returns the codec for t in c, if any. A nil c has none.
+func (c *Codecs) lookup(t reflect.Type) (codec, bool) {
+	if c == nil {
+		return codec{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	k, ok := c.byType[t]
+	return k, ok
+}
+
+// findCodec returns the codec for t in c, or else among the registered
+// codecs, or else among the built-in codecs for standard library types.
+func findCodec(c *Codecs, t reflect.Type) (codec, bool) {
+	if k, ok := c.lookup(t); ok {
+		return k, true
+	}
+	if k, ok := globalCodecs.lookup(t); ok {
+		return k, true
+	}
+	k, ok := builtinCodecs[t]
+	return k, ok
+}
+
+// decodeCodec stores in target, as its codec k converts it, value decoded
+// from a document.
+func decodeCodec(target reflect.Value, k codec, value any) error {
+	v, err := k.unmarshal(value)
+	if err != nil {
+		return fmt.Errorf("Cannot decode %s: %w", target.Type(), err)
+	}
+	if v == nil {
+		target.SetZero()
+	} else {
+		target.Set(reflect.ValueOf(v))
+	}
+	return nil
+}
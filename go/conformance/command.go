@@ -0,0 +1,39 @@
+package conformance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Command returns a Decoder that runs an external program once per
+// document. The document is written to the program's standard input, and
+// its file name is given in the YAY_FILENAME environment variable. On
+// success the program writes the decoded value to standard output in the
+// typed JSON form and exits with status zero; on failure it writes the
+// error message to standard error and exits with any other status.
+func Command(name string, args ...string) Decoder {
+	return func(input []byte, filename string) (any, error) {
+		cmd := exec.Command(name, args...)
+		cmd.Env = append(os.Environ(), "YAY_FILENAME="+filename)
+		cmd.Stdin = bytes.NewReader(input)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			var exit *exec.ExitError
+			if !errors.As(err, &exit) {
+				return nil, fmt.Errorf("Cannot run %s: %w", name, err)
+			}
+			return nil, errors.New(strings.TrimSpace(stderr.String()))
+		}
+		v, err := DecodeJSON(stdout.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("Invalid output from %s: %w", name, err)
+		}
+		return v, nil
+	}
+}
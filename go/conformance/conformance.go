@@ -0,0 +1,155 @@
+// Package conformance runs the YAY fixture corpus against a decoder, so that
+// implementations in other languages can be held to exactly the battery of
+// tests this one is.
+//
+// The corpus is the repository's test directory: every test/yay/NAME.yay is
+// a document that must decode, and every test/nay/NAME.nay is a document
+// that must be rejected with an error containing the message in
+// test/nay/NAME.error. The value each yay document must decode to comes
+// from the Go expression test/go/NAME.go.
+//
+// A decoder written in another language takes part through Command: it is
+// run once per document with the document on standard input, and reports
+// the decoded value on standard output in the typed JSON form described by
+// EncodeJSON, or reports an error by writing the message to standard error
+// and exiting with a non-zero status.
+package conformance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+// Case is one document from the corpus.
+type Case struct {
+	Name     string // Base name, shared by the document and its expectations
+	Filename string // Name of the document file, as it appears in errors
+	Input    []byte // The document
+	Valid    bool   // Whether the document must decode
+	Want     any    // For a valid document, the value it decodes to
+	Error    string // For an invalid one, text the error must contain
+}
+
+// Load reads the corpus from dir, the repository's test directory, in
+// order of name with valid documents first.
+func Load(dir string) ([]Case, error) {
+	valid, err := loadDir(filepath.Join(dir, "yay"), ".yay", func(c *Case) error {
+		want, err := expectation(dir, c.Name)
+		if err != nil {
+			return err
+		}
+		c.Valid = true
+		c.Want = want
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalid, err := loadDir(filepath.Join(dir, "nay"), ".nay", func(c *Case) error {
+		msg, err := os.ReadFile(filepath.Join(dir, "nay", c.Name+".error"))
+		if err != nil {
+			return err
+		}
+		c.Error = strings.TrimSpace(string(msg))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(valid, invalid...), nil
+}
+
+// loadDir reads each file in dir with extension ext as a case, completed
+// by finish.
+func loadDir(dir, ext string, finish func(*Case) error) ([]Case, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No %s files in %s", ext, dir)
+	}
+	sort.Strings(paths)
+	cases := make([]Case, 0, len(paths))
+	for _, path := range paths {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		filename := filepath.Base(path)
+		c := Case{
+			Name:     strings.TrimSuffix(filename, ext),
+			Filename: filename,
+			Input:    input,
+		}
+		if err := finish(&c); err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// Decoder decodes a document, given the name of the file it came from for
+// use in error messages. Values use the representation yay.Unmarshal
+// returns.
+type Decoder func(input []byte, filename string) (any, error)
+
+// Result is the outcome of one case.
+type Result struct {
+	Name    string `json:"name"`
+	Pass    bool   `json:"pass"`
+	Message string `json:"message,omitempty"` // Why the case failed
+}
+
+// Run decodes every case with decode and reports the outcomes in order.
+func Run(cases []Case, decode Decoder) []Result {
+	results := make([]Result, len(cases))
+	for i, c := range cases {
+		results[i] = Result{Name: c.Name, Pass: true}
+		if msg := check(c, decode); msg != "" {
+			results[i].Pass = false
+			results[i].Message = msg
+		}
+	}
+	return results
+}
+
+// check runs one case, returning a description of any failure.
+func check(c Case, decode Decoder) string {
+	got, err := decode(c.Input, c.Filename)
+	if !c.Valid {
+		if err == nil {
+			return fmt.Sprintf("expected error containing %q, got success", c.Error)
+		}
+		if !strings.Contains(err.Error(), c.Error) {
+			return fmt.Sprintf("expected error containing %q, got %q", c.Error, err.Error())
+		}
+		return ""
+	}
+	if err != nil {
+		return fmt.Sprintf("unexpected error: %v", err)
+	}
+	if !yay.Equal(got, c.Want) {
+		gotJSON, _ := EncodeJSON(got)
+		wantJSON, _ := EncodeJSON(c.Want)
+		return fmt.Sprintf("got %s, want %s", gotJSON, wantJSON)
+	}
+	return ""
+}
+
+// Failures counts the failed results.
+func Failures(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.Pass {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+package conformance
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+)
+
+func TestReferenceDecoder(t *testing.T) {
+	cases, err := Load("../../test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range Run(cases, yay.UnmarshalFile) {
+		if !r.Pass {
+			t.Errorf("%s: %s", r.Name, r.Message)
+		}
+	}
+}
+
+func TestFailuresReported(t *testing.T) {
+	cases, err := Load("../../test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A decoder that accepts everything as null.
+	results := Run(cases, func([]byte, string) (any, error) { return nil, nil })
+	if Failures(results) == 0 {
+		t.Fatal("expected failures")
+	}
+
+	var tap bytes.Buffer
+	if err := WriteTAP(&tap, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(tap.String(), "TAP version 13\n1..") || !strings.Contains(tap.String(), "\nnot ok ") {
+		t.Errorf("unexpected TAP output:\n%s", tap.String())
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"yay/answer.yay": "answer: 42\n",
+		"yay/bytes.yay":  "<cafe>\n",
+		"yay/floats.yay": "[-0.0, infinity, 1.5]\n",
+		"go/answer.go":   `map[string]any{"answer": big.NewInt(43)}`,
+		"go/bytes.go":    `[]byte{0xca, 0xfe}`,
+		"go/floats.go":   `[]any{math.Copysign(0, -1), math.Inf(1), 1.5}`,
+		"nay/tab.nay":    "\t1\n",
+		"nay/tab.error":  "Tab not allowed",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The expectation, not the decoder, decides: answer is 43.
+	results := Run(cases, yay.UnmarshalFile)
+	for _, r := range results {
+		if r.Pass == (r.Name == "answer") {
+			t.Errorf("%s: pass %v, %s", r.Name, r.Pass, r.Message)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, "go", "answer.go")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "No expected value for answer.yay") {
+		t.Errorf("missing expectation: got %v", err)
+	}
+}
+
+func TestTypedJSON(t *testing.T) {
+	v := map[string]any{
+		"$ref":  "escaped key",
+		"n":     new(big.Int).Lsh(big.NewInt(1), 100),
+		"f":     []any{1.5, math.NaN(), math.Inf(-1)},
+		"b":     []byte{0xca, 0xfe},
+		"plain": []any{nil, true, "text"},
+	}
+	data, err := EncodeJSON(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$$ref":"escaped key","b":{"$bytes":"cafe"},"f":[{"$float":"1.5"},{"$float":"nan"},{"$float":"-infinity"}],` +
+		`"n":{"$integer":"1267650600228229401496703205376"},"plain":[null,true,"text"]}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+	got, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !yay.Equal(got, v) {
+		t.Errorf("got %#v, want %#v", got, v)
+	}
+
+	for _, bad := range []string{`1`, `{"$integer":"x"}`, `{"$int":"1"}`, `[] []`} {
+		if _, err := DecodeJSON([]byte(bad)); err == nil {
+			t.Errorf("DecodeJSON(%s): expected error", bad)
+		}
+	}
+}
+
+// TestCommand runs the corpus through this test binary acting as an
+// external decoder.
+func TestCommand(t *testing.T) {
+	cases, err := Load("../../test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("YAY_CONFORMANCE_HELPER", "1")
+	decode := Command(os.Args[0], "-test.run=^TestHelperDecoder$")
+	for _, r := range Run(cases, decode) {
+		if !r.Pass {
+			t.Errorf("%s: %s", r.Name, r.Message)
+		}
+	}
+}
+
+func TestHelperDecoder(t *testing.T) {
+	if os.Getenv("YAY_CONFORMANCE_HELPER") != "1" {
+		t.Skip("run by TestCommand")
+	}
+	input, _ := io.ReadAll(os.Stdin)
+	v, err := yay.UnmarshalFile(input, os.Getenv("YAY_FILENAME"))
+	if err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+	out, _ := EncodeJSON(v)
+	os.Stdout.Write(out)
+	os.Exit(0)
+}
@@ -0,0 +1,219 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ============================================================================
+// Expected Values
+// ============================================================================
+//
+// The value a yay document must decode to is written by hand in the
+// fixtures of the corpus, never taken from a decoder, so that the suite
+// holds this module's decoder to account as it does any other. It comes
+// from go/NAME.go, the value as a Go expression of the few forms the
+// fixtures use: literals, []any, []byte, and map[string]any composites, and
+// calls to big.NewInt, math.Inf, math.NaN, and math.Copysign.
+
+// expectation returns the expected value of the yay document NAME of the
+// corpus in dir.
+func expectation(dir, name string) (any, error) {
+	src, err := os.ReadFile(filepath.Join(dir, "go", name+".go"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No expected value for %s.yay in go/%s.go", name, name)
+	} else if err != nil {
+		return nil, err
+	}
+	expr, err := parser.ParseExpr(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse go/%s.go: %w", name, err)
+	}
+	v, err := goValue(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot evaluate go/%s.go: %w", name, err)
+	}
+	return v, nil
+}
+
+var errUnsupportedExpr = errors.New("Unsupported expression")
+
+// goValue returns the value of expr, a fixture's Go expression.
+func goValue(expr ast.Expr) (any, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		switch e.Name {
+		case "nil":
+			return nil, nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.STRING:
+			return strconv.Unquote(e.Value)
+		case token.FLOAT:
+			return strconv.ParseFloat(e.Value, 64)
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.SUB {
+			if f, err := goValue(e.X); err == nil {
+				if f, ok := f.(float64); ok {
+					return -f, nil
+				}
+			}
+		}
+	case *ast.CompositeLit:
+		return goComposite(e)
+	case *ast.CallExpr:
+		return goCall(e)
+	}
+	return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, expr.Pos()-1)
+}
+
+// goComposite returns the value of a []any, []byte, or map[string]any
+// composite literal.
+func goComposite(e *ast.CompositeLit) (any, error) {
+	switch typeName(e.Type) {
+	case "[]any":
+		items := make([]any, len(e.Elts))
+		for i, elt := range e.Elts {
+			v, err := goValue(elt)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = v
+		}
+		return items, nil
+	case "[]byte":
+		b := make([]byte, len(e.Elts))
+		for i, elt := range e.Elts {
+			n, err := goInt(elt)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || n > math.MaxUint8 {
+				return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, elt.Pos()-1)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	case "map[string]any":
+		m := make(map[string]any, len(e.Elts))
+		for _, elt := range e.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, elt.Pos()-1)
+			}
+			k, err := goValue(kv.Key)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, kv.Key.Pos()-1)
+			}
+			v, err := goValue(kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, e.Pos()-1)
+}
+
+// goCall returns the value of a call to big.NewInt, math.Inf, math.NaN,
+// or math.Copysign.
+func goCall(e *ast.CallExpr) (any, error) {
+	switch typeName(e.Fun) {
+	case "big.NewInt":
+		if len(e.Args) == 1 {
+			n, err := goInt(e.Args[0])
+			if err != nil {
+				return nil, err
+			}
+			return big.NewInt(n), nil
+		}
+	case "math.Inf":
+		if len(e.Args) == 1 {
+			n, err := goInt(e.Args[0])
+			if err != nil {
+				return nil, err
+			}
+			return math.Inf(int(n)), nil
+		}
+	case "math.NaN":
+		if len(e.Args) == 0 {
+			return math.NaN(), nil
+		}
+	case "math.Copysign":
+		if len(e.Args) == 2 {
+			var f [2]float64
+			for i, arg := range e.Args {
+				if n, err := goInt(arg); err == nil {
+					f[i] = float64(n)
+					continue
+				}
+				v, err := goValue(arg)
+				if err != nil {
+					return nil, err
+				}
+				x, ok := v.(float64)
+				if !ok {
+					return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, arg.Pos()-1)
+				}
+				f[i] = x
+			}
+			return math.Copysign(f[0], f[1]), nil
+		}
+	}
+	return nil, fmt.Errorf("%w at offset %d", errUnsupportedExpr, e.Pos()-1)
+}
+
+// goInt returns the value of an integer literal, perhaps negated.
+func goInt(expr ast.Expr) (int64, error) {
+	sign := ""
+	if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.SUB {
+		sign, expr = "-", u.X
+	}
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.INT {
+		return strconv.ParseInt(sign+lit.Value, 0, 64)
+	}
+	return 0, fmt.Errorf("%w at offset %d", errUnsupportedExpr, expr.Pos()-1)
+}
+
+// typeName returns a type or function name as written, such as "[]any"
+// or "big.NewInt", or "" if it is not one goValue knows.
+func typeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			return x.Name + "." + e.Sel.Name
+		}
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + typeName(e.Elt)
+		}
+	case *ast.MapType:
+		return "map[" + typeName(e.Key) + "]" + typeName(e.Value)
+	case *ast.InterfaceType:
+		if len(e.Methods.List) == 0 {
+			return "any"
+		}
+	}
+	return ""
+}
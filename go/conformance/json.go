@@ -0,0 +1,234 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+// ============================================================================
+// Typed JSON
+// ============================================================================
+//
+// JSON alone cannot tell an integer from a float, has no byte arrays, and
+// has no NaN or infinities, so decoders under test report values in a
+// typed form of JSON that every language can produce with its standard
+// library:
+//
+//   - null, booleans, strings, and arrays are written as JSON
+//   - an integer is {"$integer": "-42"}, in decimal
+//   - a float is {"$float": "1.5"}, or "nan", "infinity", "-infinity"
+//   - a byte array is {"$bytes": "cafe"}, in hex
+//   - an object is a JSON object, with every key that begins with "$"
+//     given one more "$", so "$ref" is written "$$ref"
+//
+// JSON numbers do not appear.
+
+// EncodeJSON writes v in the typed JSON form.
+func EncodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeJSON(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		return writeJSONString(buf, v)
+	case *big.Int:
+		buf.WriteString(`{"$integer":"`)
+		buf.WriteString(v.String())
+		buf.WriteString(`"}`)
+	case float64:
+		buf.WriteString(`{"$float":"`)
+		buf.WriteString(formatFloat(v))
+		buf.WriteString(`"}`)
+	case []byte:
+		buf.WriteString(`{"$bytes":"`)
+		buf.WriteString(hex.EncodeToString(v))
+		buf.WriteString(`"}`)
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		members := make([]yay.Member, len(keys))
+		for i, k := range keys {
+			members[i] = yay.Member{Key: k, Value: v[k]}
+		}
+		return encodeJSONObject(buf, members)
+	case *yay.OrderedMap:
+		return encodeJSONObject(buf, v.Members())
+	default:
+		return fmt.Errorf("Cannot encode value of type %T", v)
+	}
+	return nil
+}
+
+// encodeJSONObject writes an object's members in order.
+func encodeJSONObject(buf *bytes.Buffer, members []yay.Member) error {
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name := m.Key
+		if strings.HasPrefix(name, "$") {
+			name = "$" + name
+		}
+		if err := writeJSONString(buf, name); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := encodeJSON(buf, m.Value); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// formatFloat writes f with the fewest digits that read back exactly,
+// spelling the special values as YAY does.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "infinity"
+	case math.IsInf(f, -1):
+		return "-infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// DecodeJSON reads a value in the typed JSON form.
+func DecodeJSON(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var raw any
+	if err := d.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("Unexpected content after JSON value")
+	}
+	return fromJSON(raw)
+}
+
+func fromJSON(raw any) (any, error) {
+	switch raw := raw.(type) {
+	case nil, bool, string:
+		return raw, nil
+	case json.Number:
+		return nil, fmt.Errorf("Unexpected JSON number %s; use {\"$integer\": ...} or {\"$float\": ...}", raw)
+	case []any:
+		for i, item := range raw {
+			v, err := fromJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			raw[i] = v
+		}
+		return raw, nil
+	case map[string]any:
+		if len(raw) == 1 {
+			for k, v := range raw {
+				if typed, ok, err := fromTagged(k, v); ok || err != nil {
+					return typed, err
+				}
+			}
+		}
+		obj := make(map[string]any, len(raw))
+		for k, item := range raw {
+			v, err := fromJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(k, "$$") {
+				k = k[1:]
+			} else if strings.HasPrefix(k, "$") {
+				return nil, fmt.Errorf("Unknown type tag %q", k)
+			}
+			obj[k] = v
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("Unexpected JSON value of type %T", raw)
+}
+
+// fromTagged decodes a single-property object whose key is a type tag.
+func fromTagged(tag string, raw any) (any, bool, error) {
+	switch tag {
+	case "$integer", "$float", "$bytes":
+	default:
+		return nil, false, nil
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return nil, true, fmt.Errorf("Expected string for %s, got %T", tag, raw)
+	}
+	switch tag {
+	case "$integer":
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, true, fmt.Errorf("Invalid integer %q", s)
+		}
+		return n, true, nil
+	case "$float":
+		switch s {
+		case "nan":
+			return math.NaN(), true, nil
+		case "infinity":
+			return math.Inf(1), true, nil
+		case "-infinity":
+			return math.Inf(-1), true, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, true, fmt.Errorf("Invalid float %q", s)
+		}
+		return f, true, nil
+	default:
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, true, fmt.Errorf("Invalid hex %q", s)
+		}
+		return b, true, nil
+	}
+}
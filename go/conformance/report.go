@@ -0,0 +1,65 @@
+package conformance
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTAP reports results in the Test Anything Protocol, version 13.
+func WriteTAP(w io.Writer, results []Result) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "TAP version 13\n1..%d\n", len(results))
+	for i, r := range results {
+		if r.Pass {
+			fmt.Fprintf(bw, "ok %d - %s\n", i+1, r.Name)
+			continue
+		}
+		fmt.Fprintf(bw, "not ok %d - %s\n", i+1, r.Name)
+		fmt.Fprintf(bw, "  ---\n  message: %s\n  ...\n", quoteYAML(r.Message))
+	}
+	return bw.Flush()
+}
+
+// quoteYAML quotes s as a YAML double-quoted scalar, as TAP diagnostics
+// are YAML. JSON strings are valid YAML double-quoted scalars.
+func quoteYAML(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// Summary counts the outcomes of a run.
+type Summary struct {
+	Total   int      `json:"total"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+// WriteJSON reports results as a JSON Summary.
+func WriteJSON(w io.Writer, results []Result) error {
+	failed := Failures(results)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Summary{
+		Total:   len(results),
+		Passed:  len(results) - failed,
+		Failed:  failed,
+		Results: results,
+	})
+}
+
+// WriteText reports failures one per line, followed by a count.
+func WriteText(w io.Writer, results []Result) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range results {
+		if !r.Pass {
+			fmt.Fprintf(bw, "FAIL %s: %s\n", r.Name, strings.ReplaceAll(r.Message, "\n", "\n    "))
+		}
+	}
+	failed := Failures(results)
+	fmt.Fprintf(bw, "%d passed, %d failed\n", len(results)-failed, failed)
+	return bw.Flush()
+}
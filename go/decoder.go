@@ -0,0 +1,165 @@
+package yay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+)
+
+// ============================================================================
+// Decoder
+// ============================================================================
+
+// Decoder reads a YAY document from an input stream.
+//
+// A Decoder reads the stream a block at a time, growing a buffer of its
+// own, and parses that buffer where it lies, so decoding from a reader
+// costs no more memory than the document itself, where reading the stream
+// into a []byte for Unmarshal costs twice that, since Unmarshal copies
+// its input before parsing it.
+//
+// With DecodeOptions.MaxInputBytes set, a Decoder stops reading as soon as
+// the stream runs past the limit, so an HTTP handler can hand it a request
+// body of any size:
+//
+//	dec := yay.NewDecoderWithOptions(req.Body, yay.SafeOptions())
+//	var v any
+//	if err := dec.Decode(&v); errors.Is(err, yay.ErrTooLarge) {
+//		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+//		return
+//	}
+type Decoder struct {
+	r    io.Reader
+	opts DecodeOptions
+	done bool // Whether the document has been decoded
+
+	stream *tokenStream // Once Token has been called
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// NewDecoderWithOptions returns a Decoder reading from r and decoding
+// according to opts.
+func NewDecoderWithOptions(r io.Reader, opts DecodeOptions) *Decoder {
+	return &Decoder{r: r, opts: opts}
+}
+
+// DisallowUnknownFields makes Decode fail at a key that names no field of
+// the struct its object is decoded into, as DecodeOptions says.
+func (d *Decoder) DisallowUnknownFields() {
+	d.opts.DisallowUnknownFields = true
+}
+
+// Decode reads the document from the stream and stores its value in v,
+// which must be a non-nil pointer. An *Object takes a document whose root
+// is an object, keeping the order of its keys and of those of the objects
+// within it, as with PreserveKeyOrder. An *Array takes a document whose
+// root is an array. A pointer to any other type takes a document whose
+// value converts to that type: booleans, strings, and numbers to the Go
+// types of their kind, arrays to slices and arrays, objects to maps with
+// string keys and to structs, filling their Position fields, null to
+// pointers and nullable types such as Optional and sql.NullString, values
+// of types with codecs through their codecs, and values of Unmarshalers
+// through their UnmarshalYAY methods. A value that does not convert is a
+// *DecodeError. The constraints in the struct tags of the value are then
+// checked, as by ValidateStruct, with the position of the value that
+// breaks one in the error. A stream holds one document, so later calls
+// return io.EOF.
+func (d *Decoder) Decode(v any) error {
+	opts := d.opts
+	var ok bool
+	switch p := v.(type) {
+	case *any:
+		ok = p != nil
+	case *Object:
+		ok = p != nil
+		opts.PreserveKeyOrder = true
+	case *Array:
+		ok = p != nil
+	}
+	if !ok {
+		if err := checkTarget(v); err != nil {
+			return err
+		}
+	}
+	if d.stream != nil {
+		return d.decodeToken(v)
+	}
+	if d.done {
+		return io.EOF
+	}
+	d.done = true
+	data, err := d.read()
+	if err != nil {
+		return err
+	}
+	if opts.Recorder == nil {
+		return decodeInto(v, data, opts)
+	}
+	start := time.Now()
+	err = decodeInto(v, data, opts)
+	record(opts.Recorder, opts.Filename, len(data), start, err)
+	return err
+}
+
+// decodeInto stores the value of the document of data in v, a target that
+// Decode accepts. Data is the Decoder's own buffer, which nothing else
+// writes to, so it is parsed in place rather than copied, and the strings
+// of the value share its memory.
+func decodeInto(v any, data []byte, opts DecodeOptions) error {
+	source := unsafe.String(unsafe.SliceData(data), len(data))
+	value, err := decodeSource(source, opts.Filename, opts)
+	if err != nil {
+		return err
+	}
+	switch v.(type) {
+	case *any, *Object, *Array:
+		return store(v, value)
+	}
+	return decodeTyped(v, value, opts, positionFinder(data, opts))
+}
+
+// store stores value, decoded from a whole document, in v, a target that
+// Decode accepts.
+func store(v, value any) error {
+	switch p := v.(type) {
+	case *any:
+		*p = value
+	case *Object:
+		obj, ok := value.(*Object)
+		if !ok {
+			return fmt.Errorf("Cannot decode %s into an Object", describeValue(value))
+		}
+		*p = *obj
+	case *Array:
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("Cannot decode %s into an Array", describeValue(value))
+		}
+		*p = arr
+	}
+	return nil
+}
+
+// read reads the rest of the stream a block at a time into a buffer of its
+// own, failing once it exceeds MaxInputBytes rather than reading on to the
+// end. The buffer is never reused, so decodeInto may parse it in place.
+func (d *Decoder) read() ([]byte, error) {
+	limit := d.opts.MaxInputBytes
+	if limit <= 0 {
+		return io.ReadAll(d.r)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(d.r, int64(limit)+1)); err != nil {
+		return nil, err
+	}
+	if buf.Len() > limit {
+		return nil, d.opts.Catalog.errorf("%w (limit %d bytes)", ErrTooLarge, limit)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,412 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Decoding into Go Values
+// ============================================================================
+//
+// A Decoder given a pointer to a Go type other than any, Object, or Array
+// converts the decoded value to that type with a valueDecoder. Scalars
+// convert to the Go types of their kind, with integers checked against the
+// range of the target; arrays convert to slices and Go arrays; objects
+// convert to maps with string keys and to structs, by the keys of their
+// fields; types with codecs convert through their codecs; and pointers and
+// nullable types take null as their zero value.
+//
+// A value that does not convert is reported with a DecodeError, which gives
+// the path of the value in the document, the path of the Go value it was
+// to be stored in, and where the value begins in the document.
+
+// ErrUnknownField is wrapped by the DecodeError for a key that names no
+// field of the struct its object is decoded into, when
+// DecodeOptions.DisallowUnknownFields is set.
+var ErrUnknownField = errors.New("Unknown field")
+
+// DecodeError reports a value of a document that does not convert to the
+// Go type it is decoded into.
+type DecodeError struct {
+	Value any          // The value in the document
+	Type  reflect.Type // The Go type it does not convert to
+	Path  string       // The path of the value in the document, as ".ports[1]"
+	Field string       // The Go path of the value from the target, as "Ports[1]"
+
+	// Filename, Line, and Column give where the value begins in the
+	// document. Line and Column count from 1, with columns as
+	// DecodeOptions.Columns counts them, and are 0 if unknown.
+	Filename     string
+	Line, Column int
+
+	Err error // Why the value does not convert
+
+	segments []pathSegment // The path, for finding the position
+	inStruct bool          // Whether the Go path passes through struct fields
+}
+
+func (e *DecodeError) Error() string {
+	msg := e.Err.Error()
+	if e.Path != "." {
+		msg += " at " + e.Path
+	}
+	if e.inStruct {
+		msg += " (field " + e.Field + ")"
+	}
+	if e.Filename != "" && e.Line > 0 {
+		msg += fmt.Sprintf(" at %d:%d of <%s>", e.Line, e.Column, e.Filename)
+	}
+	return msg
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// checkTarget returns an error unless v, given to Decode, is a non-nil
+// pointer.
+func checkTarget(v any) error {
+	if target := reflect.ValueOf(v); target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("Decode needs a non-nil pointer, not %T", v)
+	}
+	return nil
+}
+
+// decodeTyped stores value in the variable v points to, of a type other
+// than any, Object, and Array, and checks the constraints of its struct
+// tags. Should the value not convert or break a constraint, or a struct
+// want its Position, where, if not nil, finds where values begin.
+func decodeTyped(v, value any, opts DecodeOptions, where func(path []pathSegment) Position) error {
+	target := reflect.ValueOf(v).Elem()
+	d := valueDecoder{
+		codecs: opts.Codecs,
+		strict: opts.DisallowUnknownFields,
+		where:  where,
+	}
+	err := d.decode(target, value)
+	if err == nil {
+		err = validateDecoded(target, value)
+	}
+	if where == nil {
+		return err
+	}
+	var de *DecodeError
+	var ce *constraintError
+	if errors.As(err, &de) {
+		p := where(de.segments)
+		de.Filename, de.Line, de.Column = p.Filename, p.Line, p.Column
+	} else if errors.As(err, &ce) {
+		ce.pos = where(ce.path)
+	}
+	return err
+}
+
+// isDecodeError reports whether err is or wraps a *DecodeError.
+func isDecodeError(err error) bool {
+	var de *DecodeError
+	return errors.As(err, &de)
+}
+
+// valueDecoder converts values decoded from a document to Go values,
+// keeping the path to the value it is converting.
+type valueDecoder struct {
+	codecs *Codecs
+	strict bool          // Whether keys must name fields
+	path   []pathSegment // From the root of the document
+	fields []string      // Go path elements from the target, as "[1]" or ".Name"
+
+	where func(path []pathSegment) Position // Where the value at path begins
+}
+
+// fail returns the DecodeError for value, at the current path, failing to
+// convert to type t for reason err.
+func (d *valueDecoder) fail(value any, t reflect.Type, err error) error {
+	var path strings.Builder
+	for _, seg := range d.path {
+		if seg.isKey {
+			path.WriteString(keyPathElement(seg.key))
+		} else {
+			fmt.Fprintf(&path, "[%d]", seg.index)
+		}
+	}
+	inStruct := false
+	for _, f := range d.fields {
+		inStruct = inStruct || strings.HasPrefix(f, ".")
+	}
+	return &DecodeError{
+		Value:    value,
+		Type:     t,
+		Path:     displayPath(path.String()),
+		Field:    strings.TrimPrefix(strings.Join(d.fields, ""), "."),
+		Err:      err,
+		segments: append([]pathSegment(nil), d.path...),
+		inStruct: inStruct,
+	}
+}
+
+// decode stores value, decoded from a document, in target, converting it
+// to the type of target.
+func (d *valueDecoder) decode(target reflect.Value, value any) error {
+	t := target.Type()
+	if k, ok := findCodec(d.codecs, t); ok && k.unmarshal != nil {
+		if err := decodeCodec(target, k, value); err != nil {
+			return d.fail(value, t, err)
+		}
+		return nil
+	}
+	if ok, err := unmarshalSelf(target, value); ok {
+		if err != nil {
+			return d.fail(value, t, fmt.Errorf("Cannot decode %s: %w", t, err))
+		}
+		return nil
+	}
+	if ok, err := unmarshalText(target, value); ok {
+		if err != nil {
+			return d.fail(value, t, err)
+		}
+		return nil
+	}
+	if isNullable(t) {
+		if value == nil {
+			target.SetZero()
+			return nil
+		}
+		if err := d.decode(target.Field(0), value); err != nil {
+			return err
+		}
+		target.Field(1).SetBool(true)
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		if value == nil {
+			target.SetZero()
+			return nil
+		}
+		if v := reflect.ValueOf(value); v.Type().AssignableTo(t) {
+			target.Set(v)
+			return nil
+		}
+	case reflect.Pointer:
+		if value == nil {
+			target.SetZero()
+			return nil
+		}
+		if t == bigIntType {
+			if n, ok := value.(*big.Int); ok {
+				target.Set(reflect.ValueOf(new(big.Int).Set(n)))
+				return nil
+			}
+			break
+		}
+		p := reflect.New(t.Elem())
+		if err := d.decode(p.Elem(), value); err != nil {
+			return err
+		}
+		target.Set(p)
+		return nil
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			target.SetBool(b)
+			return nil
+		}
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			target.SetString(s)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := value.(*big.Int); ok {
+			if !n.IsInt64() || target.OverflowInt(n.Int64()) {
+				return d.fail(value, t, fmt.Errorf("Integer %s overflows %s", n, t))
+			}
+			target.SetInt(n.Int64())
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := value.(*big.Int); ok {
+			if !n.IsUint64() || target.OverflowUint(n.Uint64()) {
+				return d.fail(value, t, fmt.Errorf("Integer %s overflows %s", n, t))
+			}
+			target.SetUint(n.Uint64())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch value.(type) {
+		case float64, *big.Int:
+			f, err := AsFloat64(value)
+			if err != nil {
+				return d.fail(value, t, err)
+			}
+			if t.Kind() == reflect.Float32 && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+				return d.fail(value, t, fmt.Errorf("Float %s overflows %s", describeValue(f), t))
+			}
+			target.SetFloat(f)
+			return nil
+		}
+	case reflect.Slice:
+		if b, ok := value.([]byte); ok && t.Elem().Kind() == reflect.Uint8 {
+			target.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		if value == nil {
+			target.SetZero()
+			return nil
+		}
+		if items, ok := value.([]any); ok {
+			s := reflect.MakeSlice(t, len(items), len(items))
+			if err := d.decodeItems(s, items); err != nil {
+				return err
+			}
+			target.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if b, ok := value.([]byte); ok && len(b) == t.Len() && t.Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(target, reflect.ValueOf(b))
+			return nil
+		}
+		if items, ok := value.([]any); ok && len(items) == t.Len() {
+			return d.decodeItems(target, items)
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		if value == nil {
+			target.SetZero()
+			return nil
+		}
+		if isObject(value) {
+			return d.decodeProperties(target, value)
+		}
+	case reflect.Struct:
+		if isObject(value) {
+			return d.decodeFields(target, value)
+		}
+	}
+	return d.fail(value, t, fmt.Errorf("Cannot decode %s into %s", describeValue(value), t))
+}
+
+// decodeItems stores items in s, a slice or Go array of their length.
+func (d *valueDecoder) decodeItems(s reflect.Value, items []any) error {
+	for i, item := range items {
+		d.path = append(d.path, pathSegment{index: i})
+		d.fields = append(d.fields, "["+strconv.Itoa(i)+"]")
+		if err := d.decode(s.Index(i), item); err != nil {
+			return err
+		}
+		d.path, d.fields = d.path[:len(d.path)-1], d.fields[:len(d.fields)-1]
+	}
+	return nil
+}
+
+// decodeProperties stores the properties of obj in m, a map with string
+// keys, making the map if it is nil.
+func (d *valueDecoder) decodeProperties(m reflect.Value, obj any) error {
+	t := m.Type()
+	if m.IsNil() {
+		m.Set(reflect.MakeMapWithSize(t, objectLen(obj)))
+	}
+	for _, k := range propertyKeys(obj) {
+		v, _ := getProperty(obj, k)
+		d.path = append(d.path, pathSegment{key: k, isKey: true})
+		d.fields = append(d.fields, "["+strconv.Quote(k)+"]")
+		e := reflect.New(t.Elem()).Elem()
+		if err := d.decode(e, v); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), e)
+		d.path, d.fields = d.path[:len(d.path)-1], d.fields[:len(d.fields)-1]
+	}
+	return nil
+}
+
+// decodeFields stores the properties of obj in the fields of s, a struct,
+// by their keys. Properties without a field are skipped, and fields
+// without a property keep their values.
+func (d *valueDecoder) decodeFields(s reflect.Value, obj any) error {
+	fields := cachedTypeFields(s.Type())
+	if fields.position != nil && d.where != nil {
+		if f, ok := settableField(s, fields.position); ok {
+			setPosition(f, d.where(d.path))
+		}
+	}
+	for _, k := range propertyKeys(obj) {
+		i, ok := fields.byName[k]
+		v, _ := getProperty(obj, k)
+		if !ok {
+			if d.strict {
+				d.path = append(d.path, pathSegment{key: k, isKey: true})
+				return d.fail(v, s.Type(), fmt.Errorf("%w %q in %s", ErrUnknownField, k, s.Type()))
+			}
+			continue
+		}
+		f := &fields.list[i]
+		d.path = append(d.path, pathSegment{key: k, isKey: true})
+		d.fields = append(d.fields, "."+f.goName)
+		target, ok := settableField(s, f.index)
+		if !ok {
+			return d.fail(v, f.typ, fmt.Errorf("Cannot set %s through a nil pointer to an unexported struct", f.goName))
+		}
+		if err := d.decode(target, v); err != nil {
+			return err
+		}
+		d.path, d.fields = d.path[:len(d.path)-1], d.fields[:len(d.fields)-1]
+	}
+	return nil
+}
+
+// propertyKeys returns the keys of obj, in order, so that of several values
+// that do not convert, the same one is reported every time.
+func propertyKeys(obj any) []string {
+	keys := make([]string, 0, objectLen(obj))
+	eachProperty(obj, func(k string, _ any) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if _, ordered := obj.(*OrderedMap); !ordered {
+		slices.Sort(keys)
+	}
+	return keys
+}
+
+// settableField returns the field of s at index, as fieldByIndex does, but
+// making the embedded structs it is promoted through where they are nil
+// pointers, or false if one is a nil pointer to an unexported type, which
+// cannot be made.
+func settableField(s reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && s.Kind() == reflect.Pointer {
+			if s.IsNil() {
+				if !s.CanSet() {
+					return reflect.Value{}, false
+				}
+				s.Set(reflect.New(s.Type().Elem()))
+			}
+			s = s.Elem()
+		}
+		s = s.Field(x)
+	}
+	return s, true
+}
+
+// setPosition stores p in f, a Position field, a *Position field, or a
+// field of another type tagged as a position, which is left alone.
+func setPosition(f reflect.Value, p Position) {
+	switch f.Type() {
+	case positionType:
+		f.Set(reflect.ValueOf(p))
+	case reflect.PointerTo(positionType):
+		f.Set(reflect.ValueOf(&p))
+	}
+}
@@ -0,0 +1,80 @@
+package yay
+
+// ============================================================================
+// Diff
+// ============================================================================
+
+// ChangeKind says how a value differs between two documents.
+type ChangeKind int
+
+const (
+	// Added values are present only in the second document.
+	Added ChangeKind = iota + 1
+	// Removed values are present only in the first document.
+	Removed
+	// Changed values are present in both documents but differ.
+	Changed
+)
+
+// String returns "added", "removed", or "changed".
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Change is one difference between two documents.
+type Change struct {
+	Kind ChangeKind
+	// Path is where the documents differ, in the syntax Get accepts, such
+	// as ".servers[2].port", or "." for the whole document.
+	Path string
+	// Pointer is Path as a JSON Pointer, such as "/servers/2/port".
+	Pointer Pointer
+	// Before is the value in the first document, nil if Added.
+	Before any
+	// After is the value in the second document, nil if Removed.
+	After any
+}
+
+// String describes the change on one line, as in
+// "~ .servers[2].port: 80 -> 8080", with "+" for an added value and "-"
+// for a removed one.
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return "+ " + c.Path + ": " + describeValue(c.After)
+	case Removed:
+		return "- " + c.Path + ": " + describeValue(c.Before)
+	}
+	return "~ " + c.Path + ": " + describeValue(c.Before) + " -> " + describeValue(c.After)
+}
+
+// Diff returns the differences between a and b, values Unmarshal produces,
+// in order of path with object keys sorted. Objects are compared property
+// by property and arrays item by item, so an item inserted into an array
+// shows as changes to the items after it. Other values are compared with
+// Equal, so NaN does not differ from NaN, nor 1 as a *big.Int from another
+// 1. Objects differ from arrays and scalars as a whole. Equal documents
+// have no differences.
+func Diff(a, b any) []Change {
+	var changes []Change
+	eachDifference(a, b, "", func(d difference) bool {
+		segments, _ := parsePath(d.path)
+		c := Change{Kind: Changed, Path: displayPath(d.path), Pointer: pointerOf(segments), Before: d.a, After: d.b}
+		if !d.aok {
+			c.Kind = Added
+		} else if !d.bok {
+			c.Kind = Removed
+		}
+		changes = append(changes, c)
+		return true
+	})
+	return changes
+}
@@ -0,0 +1,436 @@
+package yay
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ============================================================================
+// Documents
+// ============================================================================
+//
+// A Document is the text of a YAY document with its syntax tree, for tools
+// that change a value within a hand-written document, as "yay set
+// server.port 8080" would, without rewriting the rest of it. Each edit
+// replaces, inserts, or removes only the text of the value it changes:
+//
+//   - A value is replaced where it is written, by the text Marshal would
+//     write for the new one, laid out at the indentation of the property or
+//     item that holds it.
+//   - A property or item is added after the last of its collection, on a
+//     line of its own in a block or after ", " in an inline collection.
+//   - A property or item is removed with the lines it occupies in a block,
+//     or with the ", " beside it in an inline collection.
+//
+// Where the new text cannot go where the old text was, as a value written
+// over several lines cannot go in an inline collection, and a collection
+// that loses its last member must be written "[]" or "{}", the nearest
+// collection that holds it is rewritten instead. After each edit, the
+// document is parsed again and checked to hold the value it should, and the
+// edit fails, leaving the document as it was, if it does not.
+
+// Document is a YAY document that can be edited in place, keeping its
+// comments and layout. The zero Document is not usable; see ParseDocument.
+type Document struct {
+	src   []byte
+	opts  DecodeOptions
+	root  Node
+	value any // Of root, with objects as *OrderedMap
+}
+
+// ParseDocument parses a YAY document for editing, returning the error
+// Unmarshal would return for it.
+func ParseDocument(data []byte) (*Document, error) {
+	return ParseDocumentWithOptions(data, DecodeOptions{})
+}
+
+// ParseDocumentWithOptions parses a YAY document for editing according to
+// opts, as ParseASTWithOptions does. The options apply to the document
+// after each edit too.
+func ParseDocumentWithOptions(data []byte, opts DecodeOptions) (*Document, error) {
+	root, err := ParseASTWithOptions(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{src: bytes.Clone(data), opts: opts, root: root, value: nodeValue(root)}, nil
+}
+
+// Bytes returns the text of the document, which the caller must not
+// modify.
+func (d *Document) Bytes() []byte {
+	return d.src
+}
+
+// Root returns the syntax tree of the document.
+func (d *Document) Root() Node {
+	return d.root
+}
+
+// Value returns the value of the document, with its objects as
+// *OrderedMap in the order the document gives their properties.
+func (d *Document) Value() any {
+	return d.value
+}
+
+// Get returns the value at path within the document, as the Get function
+// does.
+func (d *Document) Get(path string) (any, error) {
+	return Get(d.value, path)
+}
+
+// Set stores value at path within the document, as the Set function does,
+// adding missing properties and arrays along the way. The value is
+// written as Marshal writes it, and the rest of the document is kept as it
+// was:
+//
+//	doc, err := yay.ParseDocument(src)
+//	err = doc.Set("server.port", 8080)
+//	src = doc.Bytes()
+func (d *Document) Set(path string, value any) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	// The value as the document will hold it.
+	data, err := Marshal(value)
+	if err != nil {
+		return err
+	}
+	v, err := UnmarshalWithOptions(data, DecodeOptions{PreserveKeyOrder: true})
+	if err != nil {
+		return err
+	}
+	want := copyValue(d.value)
+	if err := Set(&want, path, v); err != nil {
+		return err
+	}
+	// Such as for a key along path that cannot be written.
+	if _, err := Marshal(want); err != nil {
+		return err
+	}
+	nodes := d.locate(segments)
+	if len(nodes) == len(segments)+1 {
+		return d.commit(path, d.replace(nodes, segments, want), want)
+	}
+	return d.commit(path, d.insert(nodes, segments, want), want)
+}
+
+// Delete removes the property or array item at path from the document, as
+// the remove operation of a patch does, along with the lines it is
+// written on. Deleting the root leaves null.
+func (d *Document) Delete(path string) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	want, _, err := removeValue(copyValue(d.value), segments)
+	if err != nil {
+		return err
+	}
+	return d.commit(path, d.remove(d.locate(segments), segments, want), want)
+}
+
+// edit is a change to the text of a document: the bytes from start to end
+// replaced by text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// commit applies e to the document if it leaves the document holding want.
+func (d *Document) commit(path string, e edit, want any) error {
+	src := make([]byte, 0, len(d.src)-(e.end-e.start)+len(e.text))
+	src = append(src, d.src[:e.start]...)
+	src = append(src, e.text...)
+	src = append(src, d.src[e.end:]...)
+	root, err := ParseASTWithOptions(src, d.opts)
+	if err != nil {
+		return fmt.Errorf("%w: editing %s leaves an invalid document: %v", errInternal, displayPath(path), err)
+	}
+	value := nodeValue(root)
+	if !Equal(value, want) {
+		return fmt.Errorf("%w: editing %s changes the document otherwise", errInternal, displayPath(path))
+	}
+	d.src, d.root, d.value = src, root, value
+	return nil
+}
+
+// locate returns the nodes of the values along path, beginning with the
+// root, for as much of the path as leads to one.
+func (d *Document) locate(segments []pathSegment) []Node {
+	nodes := []Node{d.root}
+	v := d.value
+	for i := range segments {
+		item, ok, err := step(v, segments[:i+1])
+		if err != nil || !ok {
+			break
+		}
+		_, n := member(nodes[i], resolveSegment(v, segments[i]))
+		nodes = append(nodes, n)
+		v = item
+	}
+	return nodes
+}
+
+// member returns the index within parent, an object or array, of the
+// member that s selects, and the node of its value.
+func member(parent Node, s pathSegment) (int, Node) {
+	switch parent := parent.(type) {
+	case *ObjectNode:
+		for i := len(parent.Properties) - 1; i >= 0; i-- {
+			if p := parent.Properties[i]; p.Key == s.key {
+				return i, p.Value
+			}
+		}
+	case *ArrayNode:
+		return s.index, parent.Items[s.index]
+	}
+	return -1, nil
+}
+
+// members returns the spans of the members of a collection: of each
+// property, or of each item with its dash in a block array.
+func members(n Node) []Span {
+	var spans []Span
+	switch n := n.(type) {
+	case *ObjectNode:
+		for _, p := range n.Properties {
+			spans = append(spans, p.Span)
+		}
+	case *ArrayNode:
+		for _, item := range n.Items {
+			s := item.Extent()
+			if !n.Inline {
+				s.Start.Offset -= len("- ")
+			}
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// isInline reports whether n is an inline array or object.
+func isInline(n Node) bool {
+	switch n := n.(type) {
+	case *ObjectNode:
+		return n.Inline
+	case *ArrayNode:
+		return n.Inline
+	}
+	return false
+}
+
+// replace returns the edit that writes the value at path within want
+// where the last of nodes, the value at path in the document, is written.
+func (d *Document) replace(nodes []Node, segments []pathSegment, want any) edit {
+	v := valueAt(want, segments)
+	target := nodes[len(nodes)-1].Extent()
+	if len(segments) == 0 {
+		text, _ := d.encode(v, 0, editRoot)
+		return edit{target.Start.Offset, target.End.Offset, text}
+	}
+	parent := nodes[len(nodes)-2]
+	if isInline(parent) {
+		text, ok := d.encode(v, 0, editInline)
+		if !ok {
+			return d.replace(nodes[:len(nodes)-1], segments[:len(segments)-1], want)
+		}
+		return edit{target.Start.Offset, target.End.Offset, text}
+	}
+	i, _ := member(parent, resolveSegment(valueAt(d.value, segments[:len(segments)-1]), segments[len(segments)-1]))
+	start := members(parent)[i].Start.Offset
+	if p, ok := parent.(*ObjectNode); ok {
+		text, _ := d.encode(v, column(d.src, start), editProperty)
+		colon := p.Properties[i].KeySpan.End.Offset
+		if !strings.HasPrefix(text, "\n") && d.lineStart(target.Start.Offset) <= colon {
+			// The value stays on the line of its key.
+			return edit{target.Start.Offset, target.End.Offset, text[len(" "):]}
+		}
+		return edit{colon + len(":"), target.End.Offset, text}
+	}
+	text, _ := d.encode(v, column(d.src, start), editItem)
+	return edit{target.Start.Offset, target.End.Offset, text}
+}
+
+// insert returns the edit that adds the value at path within want to the
+// collection that the last of nodes is, which does not have it.
+func (d *Document) insert(nodes []Node, segments []pathSegment, want any) edit {
+	n := len(nodes) - 1
+	parent := nodes[n]
+	s := resolveSegment(valueAt(d.value, segments[:n]), segments[n])
+	item := valueAt(want, segments[:n+1])
+	spans := members(parent)
+	_, obj := parent.(*ObjectNode)
+	_, arr := parent.(*ArrayNode)
+	if !obj && !arr {
+		// A null root, which Set replaces with a collection.
+		return d.replace(nodes, segments[:n], want)
+	}
+	key := ""
+	if obj {
+		e := newEncoder(EncodeOptions{}, len(s.key)+2)
+		e.appendKey(s.key) // Set checks that the key can be written
+		key = string(e.buf) + ":"
+	}
+	if isInline(parent) {
+		text, ok := d.encode(item, 0, editInline)
+		if !ok {
+			return d.replace(nodes, segments[:n], want)
+		}
+		if obj {
+			text = key + " " + text
+		}
+		if len(spans) > 0 {
+			text = ", " + text
+		}
+		end := parent.Extent().End.Offset - len("]")
+		return edit{end, end, text}
+	}
+	last := spans[len(spans)-1]
+	at := d.lineEnd(last.End.Offset)
+	col := column(d.src, last.Start.Offset)
+	var text string
+	if obj {
+		text, _ = d.encode(item, col, editProperty)
+		text = key + text
+	} else {
+		text, _ = d.encode(item, col, editItem)
+		text = "- " + text
+	}
+	return edit{at, at, "\n" + strings.Repeat(" ", col) + text}
+}
+
+// remove returns the edit that removes the last of nodes, the value at
+// path in the document, from the collection that holds it.
+func (d *Document) remove(nodes []Node, segments []pathSegment, want any) edit {
+	if len(segments) == 0 {
+		return d.replace(nodes, segments, want)
+	}
+	parent := nodes[len(nodes)-2]
+	spans := members(parent)
+	if len(spans) == 1 {
+		// The collection is left empty, to be written "[]" or "{}".
+		return d.replace(nodes[:len(nodes)-1], segments[:len(segments)-1], want)
+	}
+	i, _ := member(parent, resolveSegment(valueAt(d.value, segments[:len(segments)-1]), segments[len(segments)-1]))
+	start, end := spans[i].Start.Offset, spans[i].End.Offset
+	switch {
+	case isInline(parent) && i < len(spans)-1:
+		return edit{start, spans[i+1].Start.Offset, ""}
+	case isInline(parent):
+		return edit{spans[i-1].End.Offset, end, ""}
+	case i < len(spans)-1 && strings.Trim(string(d.src[d.lineStart(start):start]), " ") != "":
+		// The first member of a collection that begins after a dash, on
+		// the line of the dash, is replaced by the next.
+		return edit{start, spans[i+1].Start.Offset, ""}
+	}
+	start, end = d.lineStart(start), d.lineEnd(end)
+	if end < len(d.src) {
+		end++
+	} else if start > 0 {
+		start--
+	}
+	return edit{start, end, ""}
+}
+
+// editMode is where the text of a value goes in a document.
+type editMode int
+
+const (
+	editRoot     editMode = iota // As the root value
+	editProperty                 // After the colon of a block property
+	editItem                     // After the dash of a block array item
+	editInline                   // Within an inline array or object
+)
+
+// encode returns the text of v, a value of a document, to be written in
+// the given place within a block collection whose members begin at column
+// indent. A property's text begins with the space or line break after its
+// colon. The text for an inline collection is reported not to fit if it
+// would not be on one line.
+func (d *Document) encode(v any, indent int, mode editMode) (string, bool) {
+	e := newEncoder(EncodeOptions{}, estimateSize(v, indent+2)+1)
+	var err error
+	switch mode {
+	case editRoot:
+		err = e.encodeValue(v, 0, false)
+	case editProperty:
+		if e.isBlock(v) {
+			e.buf = append(e.buf, '\n')
+		} else {
+			e.buf = append(e.buf, ' ')
+		}
+		err = e.encodeValue(v, indent+2, false)
+	case editItem:
+		err = e.encodeValue(v, indent+2, true)
+	case editInline:
+		if e.isBlock(v) || !e.isInlineScalar(v) && !isObject(v) && !isArray(v) {
+			return "", false
+		}
+		err = e.encodeValue(v, 0, true)
+	}
+	// The value came from a document, so it can be written.
+	return string(e.buf), err == nil
+}
+
+// isArray reports whether v is an array in either representation.
+func isArray(v any) bool {
+	_, ok := arrayItems(v)
+	return ok
+}
+
+// lineStart returns the offset of the start of the line that holds off,
+// after any BOM.
+func (d *Document) lineStart(off int) int {
+	start := startOfLine(d.src, off)
+	if start == 0 && d.opts.AllowBOM && bytes.HasPrefix(d.src, []byte("\uFEFF")) {
+		start = len("\uFEFF")
+	}
+	return start
+}
+
+// lineEnd returns the offset of the end of the line that holds off.
+func (d *Document) lineEnd(off int) int {
+	return endOfLine(d.src, off)
+}
+
+// valueAt returns the value at the path segments within v, which must
+// lead to one.
+func valueAt(v any, segments []pathSegment) any {
+	for i := range segments {
+		v, _, _ = step(v, segments[:i+1])
+	}
+	return v
+}
+
+// nodeValue returns the value of the syntax tree n, with its objects as
+// *OrderedMap.
+func nodeValue(n Node) any {
+	switch n := n.(type) {
+	case *ObjectNode:
+		obj := &OrderedMap{members: make([]Member, 0, len(n.Properties))}
+		for _, p := range n.Properties {
+			obj.Set(p.Key, nodeValue(p.Value))
+		}
+		return obj
+	case *ArrayNode:
+		items := make([]any, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = nodeValue(item)
+		}
+		return items
+	case *StringNode:
+		return n.Value
+	case *BytesNode:
+		return n.Value
+	case *IntegerNode:
+		return new(big.Int).Set(n.Value)
+	case *FloatNode:
+		return n.Value
+	case *BoolNode:
+		return n.Value
+	}
+	return nil
+}
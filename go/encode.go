@@ -0,0 +1,798 @@
+package yay
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Encoder
+// ============================================================================
+//
+// The encoder writes the canonical layout used throughout the test corpus:
+// object keys in sorted order (an OrderedMap keeps its own), two-space
+// indentation, small collections of scalars written inline, and larger or
+// nested collections, even those holding only empty collections, written
+// as blocks. Strings are double-quoted, unless
+// EncodeOptions.BlockStrings asks for block strings, and byte arrays always
+// use the inline <hex> form.
+//
+// Before writing anything, Marshal walks the value once to estimate the
+// size of the output, so that a large document is written into a single
+// buffer instead of one grown by repeated doubling.
+
+// Collections no larger than these, holding only scalars, are written inline,
+// unless EncodeOptions says otherwise.
+const (
+	inlineArrayMax  = 5
+	inlineObjectMax = 3
+)
+
+// encoder accumulates encoded output.
+type encoder struct {
+	buf       []byte
+	blocks    BlockStringMode // Which strings to write as block strings
+	arrayMax  int             // Most items of an inline array
+	objectMax int             // Most properties of an inline object
+}
+
+// newEncoder returns an encoder for opts whose buffer has room for size
+// bytes.
+func newEncoder(opts EncodeOptions, size int) *encoder {
+	return &encoder{
+		buf:       make([]byte, 0, size),
+		blocks:    opts.BlockStrings,
+		arrayMax:  inlineLimit(opts.InlineArrayMax, inlineArrayMax),
+		objectMax: inlineLimit(opts.InlineObjectMax, inlineObjectMax),
+	}
+}
+
+// inlineLimit returns the limit an inline option gives, or def for zero.
+func inlineLimit(opt, def int) int {
+	switch {
+	case opt == 0:
+		return def
+	case opt < 0:
+		return 0
+	}
+	return opt
+}
+
+// keyPool recycles the slices used to sort object keys.
+var keyPool slicePool[string]
+
+func marshal(v any, opts EncodeOptions) ([]byte, error) {
+	// One more byte for the final line feed.
+	e := newEncoder(opts, estimateSize(v, 0)+1)
+	if err := e.encodeValue(v, 0, false); err != nil {
+		return nil, err
+	}
+	e.buf = append(e.buf, '\n')
+	return e.buf, nil
+}
+
+// ============================================================================
+// Size Estimation
+// ============================================================================
+
+// estimateSize returns the approximate length of the encoding of v at the
+// given indent. Scalars are sized from their lengths without being
+// formatted, and strings are assumed to need no escapes, so the estimate
+// is cheap but may fall short for escape-heavy text.
+func estimateSize(v any, indent int) int {
+	switch v := v.(type) {
+	case nil:
+		return 4
+	case bool:
+		return 5
+	case *big.Int:
+		if v == nil {
+			return 4
+		}
+		// log10(2) is just over 0.301.
+		return v.BitLen()*302/1000 + 2
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return 20
+	case float32, float64:
+		return 24
+	case string:
+		return len(v) + 2
+	case []byte:
+		return 2*len(v) + 2
+	case Array:
+		return estimateSize([]any(v), indent)
+	case []any:
+		n := 0
+		for _, item := range v {
+			// Padding, "- ", and a line feed per item.
+			n += indent + 3 + estimateSize(item, indent+2)
+		}
+		return n + 2
+	case map[string]any:
+		n := 0
+		for k, item := range v {
+			// Padding, ": ", and a line feed per property.
+			n += indent + len(k) + 3 + estimateSize(item, indent+2)
+		}
+		return n + 2
+	case *OrderedMap:
+		n := 0
+		for _, m := range v.members {
+			n += indent + len(m.Key) + 3 + estimateSize(m.Value, indent+2)
+		}
+		return n + 2
+	}
+	return 0
+}
+
+// ============================================================================
+// Values
+// ============================================================================
+
+// encodeValue writes v as a value indented by indent spaces. When inline is
+// set, the value begins part way along a line already indented to indent,
+// after an array item's "- ", so the first line of a block is not padded.
+func (e *encoder) encodeValue(v any, indent int, inline bool) error {
+	switch v := v.(type) {
+	case Array:
+		return e.encodeValue([]any(v), indent, inline)
+	case []any:
+		if e.canInlineArray(v) {
+			return e.encodeInline(v)
+		}
+		return e.encodeBlockArray(v, indent, inline)
+	case map[string]any:
+		if e.canInlineObject(v) {
+			return e.encodeInline(v)
+		}
+		return e.encodeBlockObject(v, indent, inline)
+	case *OrderedMap:
+		if e.canInlineMembers(v.members) {
+			return e.encodeInline(v)
+		}
+		return e.encodeBlockMembers(v.members, indent, inline)
+	case string:
+		if e.isBlockString(v) && itemBlockReadsBack(v, inline) {
+			// The first line follows the backtick, and the rest are
+			// indented past it.
+			e.buf = append(e.buf, "` "...)
+			e.appendBlockLines(v, indent+2, true)
+			return nil
+		}
+	}
+	return e.encodeScalar(v)
+}
+
+// encodeScalar writes a value that is never laid out over several lines.
+// Collections reach it only when empty.
+func (e *encoder) encodeScalar(v any) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, "null"...)
+	case bool:
+		e.buf = strconv.AppendBool(e.buf, v)
+	case *big.Int:
+		if v == nil {
+			e.buf = append(e.buf, "null"...)
+		} else if v.IsInt64() {
+			e.buf = strconv.AppendInt(e.buf, v.Int64(), 10)
+		} else {
+			e.buf = v.Append(e.buf, 10)
+		}
+	case int:
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	case int8:
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	case int16:
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	case int32:
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	case int64:
+		e.buf = strconv.AppendInt(e.buf, v, 10)
+	case uint:
+		e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+	case uint8:
+		e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+	case uint16:
+		e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+	case uint32:
+		e.buf = strconv.AppendUint(e.buf, uint64(v), 10)
+	case uint64:
+		e.buf = strconv.AppendUint(e.buf, v, 10)
+	case float32:
+		e.buf = appendFloat(e.buf, float64(v), 32)
+	case float64:
+		e.buf = appendFloat(e.buf, v, 64)
+	case string:
+		e.buf = appendString(e.buf, v)
+	case []byte:
+		e.buf = appendBytes(e.buf, v)
+	case []any, Array:
+		e.buf = append(e.buf, "[]"...)
+	case map[string]any:
+		e.buf = append(e.buf, "{}"...)
+	case *OrderedMap:
+		e.buf = append(e.buf, "{}"...)
+	default:
+		return fmt.Errorf("Cannot encode %T", v)
+	}
+	return nil
+}
+
+// appendFloat appends f in a form the parser reads back as a float: with
+// a decimal point even when integral, and with an exponent only for very
+// large or very small magnitudes.
+func appendFloat(buf []byte, f float64, bits int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(buf, "nan"...)
+	case math.IsInf(f, 1):
+		return append(buf, "infinity"...)
+	case math.IsInf(f, -1):
+		return append(buf, "-infinity"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// Move the exponent aside to insert ".0" when the mantissa has no
+		// point, and trim a zero from two-digit exponents: 1e-07 to 1.0e-7.
+		mark := start
+		for buf[mark] != 'e' {
+			mark++
+		}
+		var exp [5]byte
+		n := copy(exp[:], buf[mark:])
+		if n == 4 && exp[2] == '0' {
+			exp[2], exp[3] = exp[3], 0
+			n = 3
+		}
+		buf = buf[:mark]
+		if !containsByte(buf[start:], '.') {
+			buf = append(buf, ".0"...)
+		}
+		return append(buf, exp[:n]...)
+	}
+	if !containsByte(buf[start:], '.') {
+		buf = append(buf, ".0"...)
+	}
+	return buf
+}
+
+// containsByte reports whether b contains c.
+func containsByte(b []byte, c byte) bool {
+	for _, x := range b {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// appendString appends s as a double-quoted string. Characters that may not
+// appear literally in a YAY document are written as \u{...} escapes, and
+// invalid UTF-8 is replaced with U+FFFD.
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf && c >= 0x20 && c != '"' && c != '\\' && c != 0x7F {
+			i++
+			continue
+		}
+		r, size := rune(c), 1
+		if c >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(s[i:])
+			if r != utf8.RuneError && isAllowedCodePoint(r) {
+				i += size
+				continue
+			}
+		}
+		buf = append(buf, s[start:i]...)
+		switch r {
+		case '"':
+			buf = append(buf, `\"`...)
+		case '\\':
+			buf = append(buf, `\\`...)
+		case '\b':
+			buf = append(buf, `\b`...)
+		case '\f':
+			buf = append(buf, `\f`...)
+		case '\n':
+			buf = append(buf, `\n`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		case '\t':
+			buf = append(buf, `\t`...)
+		case utf8.RuneError:
+			buf = append(buf, "�"...)
+		default:
+			buf = append(buf, `\u{`...)
+			buf = strconv.AppendInt(buf, int64(r), 16)
+			buf = append(buf, '}')
+		}
+		i += size
+		start = i
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+// appendBytes appends b in the inline <hex> form.
+func appendBytes(buf []byte, b []byte) []byte {
+	const digits = "0123456789abcdef"
+	buf = append(buf, '<')
+	for _, c := range b {
+		buf = append(buf, digits[c>>4], digits[c&0xf])
+	}
+	return append(buf, '>')
+}
+
+// ============================================================================
+// Collections
+// ============================================================================
+
+// canInlineArray reports whether a fits on one line.
+func (e *encoder) canInlineArray(a []any) bool {
+	if len(a) > e.arrayMax {
+		return false
+	}
+	for _, v := range a {
+		if !e.isInlineScalar(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// canInlineObject reports whether m fits on one line.
+func (e *encoder) canInlineObject(m map[string]any) bool {
+	if len(m) > e.objectMax {
+		return false
+	}
+	for k, v := range m {
+		if !isBareKey(k) || !e.isInlineScalar(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// canInlineMembers reports whether the properties of an OrderedMap fit on
+// one line.
+func (e *encoder) canInlineMembers(members []Member) bool {
+	if len(members) > e.objectMax {
+		return false
+	}
+	for _, m := range members {
+		if !isBareKey(m.Key) || !e.isInlineScalar(m.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// isInlineScalar reports whether v may appear inside an inline collection.
+// Collections, even empty ones, do not count, as in the layout of the
+// other implementations' encoders. Strings that need \u{...} escapes are
+// kept out of inline collections, which accept only the JSON escapes, as
+// are strings to be written as block strings.
+func (e *encoder) isInlineScalar(v any) bool {
+	switch v := v.(type) {
+	case []any, Array, map[string]any, *OrderedMap:
+		return false
+	case string:
+		return !e.isBlockString(v) && isInlineString(v)
+	}
+	return true
+}
+
+// isInlineString reports whether s may be written in an inline collection,
+// needing none but the JSON escapes.
+func isInlineString(s string) bool {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r < 0x20 && r != '\b' && r != '\f' && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+		if r >= 0x20 && !isAllowedCodePoint(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// encodeInline writes a small collection of scalars on one line.
+func (e *encoder) encodeInline(v any) error {
+	switch v := v.(type) {
+	case []any:
+		e.buf = append(e.buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				e.buf = append(e.buf, ", "...)
+			}
+			if err := e.encodeScalar(item); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, ']')
+	case map[string]any:
+		keys := sortedKeys(v)
+		defer keyPool.put(keys)
+		e.buf = append(e.buf, '{')
+		for i, k := range keys {
+			if i > 0 {
+				e.buf = append(e.buf, ", "...)
+			}
+			e.buf = append(e.buf, k...)
+			e.buf = append(e.buf, ": "...)
+			if err := e.encodeScalar(v[k]); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+	case *OrderedMap:
+		e.buf = append(e.buf, '{')
+		for i, m := range v.members {
+			if i > 0 {
+				e.buf = append(e.buf, ", "...)
+			}
+			e.buf = append(e.buf, m.Key...)
+			e.buf = append(e.buf, ": "...)
+			if err := e.encodeScalar(m.Value); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+	}
+	return nil
+}
+
+// encodeBlockArray writes one "- " item per line.
+func (e *encoder) encodeBlockArray(a []any, indent int, inline bool) error {
+	for i, item := range a {
+		if i > 0 {
+			e.buf = append(e.buf, '\n')
+		}
+		if i > 0 || !inline {
+			e.pad(indent)
+		}
+		e.buf = append(e.buf, "- "...)
+		if err := e.encodeItem(item, indent+2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeItem writes an array item, which follows its "- " at an indent of
+// indent. An array that is not written inline is a nested list, which
+// begins on the same line.
+func (e *encoder) encodeItem(v any, indent int) error {
+	switch a := v.(type) {
+	case Array:
+		return e.encodeItem([]any(a), indent)
+	case []any:
+		if !e.canInlineArray(a) {
+			return e.encodeNestedArray(a, indent)
+		}
+	}
+	return e.encodeValue(v, indent, true)
+}
+
+// encodeNestedArray writes an array that is an item of another array, the
+// first of its own items following the enclosing "- " and the rest on
+// lines of their own. The parser reads only a scalar or an inline
+// collection after the first dash, so every item is written on one line.
+func (e *encoder) encodeNestedArray(a []any, indent int) error {
+	for i, item := range a {
+		if i > 0 {
+			e.buf = append(e.buf, '\n')
+			e.pad(indent)
+		}
+		e.buf = append(e.buf, "- "...)
+		var err error
+		if s, ok := item.(string); ok {
+			e.buf = appendString(e.buf, s)
+		} else {
+			err = e.encodeFlow(item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeFlow writes v on one line, nesting collections inline.
+func (e *encoder) encodeFlow(v any) error {
+	switch v := v.(type) {
+	case Array:
+		return e.encodeFlow([]any(v))
+	case []any:
+		e.buf = append(e.buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				e.buf = append(e.buf, ", "...)
+			}
+			if err := e.encodeFlow(item); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, ']')
+	case map[string]any:
+		keys := sortedKeys(v)
+		defer keyPool.put(keys)
+		e.buf = append(e.buf, '{')
+		for i, k := range keys {
+			if err := e.encodeFlowProperty(i, k, v[k]); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+	case *OrderedMap:
+		e.buf = append(e.buf, '{')
+		for i, m := range v.members {
+			if err := e.encodeFlowProperty(i, m.Key, m.Value); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, '}')
+	case string:
+		if !isInlineString(v) {
+			return fmt.Errorf("Cannot encode %q in an inline collection", v)
+		}
+		e.buf = appendString(e.buf, v)
+	default:
+		return e.encodeScalar(v)
+	}
+	return nil
+}
+
+// encodeFlowProperty writes the ith property of an inline object, whose
+// quoted keys take the same escapes as its strings.
+func (e *encoder) encodeFlowProperty(i int, k string, v any) error {
+	if i > 0 {
+		e.buf = append(e.buf, ", "...)
+	}
+	switch {
+	case isBareKey(k):
+		e.buf = append(e.buf, k...)
+	case isInlineString(k):
+		e.buf = appendString(e.buf, k)
+	default:
+		return fmt.Errorf("Cannot encode key %q", k)
+	}
+	e.buf = append(e.buf, ": "...)
+	return e.encodeFlow(v)
+}
+
+// encodeBlockObject writes one property per line, with block values on the
+// lines that follow their keys.
+func (e *encoder) encodeBlockObject(m map[string]any, indent int, inline bool) error {
+	keys := sortedKeys(m)
+	defer keyPool.put(keys)
+	for i, k := range keys {
+		if err := e.encodeProperty(i, k, m[k], indent, inline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeBlockMembers writes the properties of an OrderedMap in order.
+func (e *encoder) encodeBlockMembers(members []Member, indent int, inline bool) error {
+	for i, m := range members {
+		if err := e.encodeProperty(i, m.Key, m.Value, indent, inline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeProperty writes the ith property of a block object.
+func (e *encoder) encodeProperty(i int, k string, v any, indent int, inline bool) error {
+	if i > 0 {
+		e.buf = append(e.buf, '\n')
+	}
+	if i > 0 || !inline {
+		e.pad(indent)
+	}
+	if err := e.appendKey(k); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, ':')
+	// A block string property has its backtick alone. The parser does not
+	// read one among the properties of an object that is an array item,
+	// so those stay quoted.
+	if s, ok := v.(string); ok {
+		if inline || !e.isBlockString(s) {
+			e.buf = append(e.buf, ' ')
+			return e.encodeScalar(v)
+		}
+		e.buf = append(e.buf, " `\n"...)
+		e.appendBlockLines(s, indent+2, false)
+		return nil
+	}
+	if e.isBlock(v) {
+		e.buf = append(e.buf, '\n')
+	} else {
+		e.buf = append(e.buf, ' ')
+	}
+	return e.encodeValue(v, indent+2, false)
+}
+
+// isBlock reports whether v is written over several lines.
+func (e *encoder) isBlock(v any) bool {
+	switch v := v.(type) {
+	case []any:
+		return !e.canInlineArray(v)
+	case Array:
+		return !e.canInlineArray(v)
+	case map[string]any:
+		return !e.canInlineObject(v)
+	case *OrderedMap:
+		return !e.canInlineMembers(v.members)
+	}
+	return false
+}
+
+// isBlockString reports whether s is written as a block string.
+func (e *encoder) isBlockString(s string) bool {
+	switch e.blocks {
+	case BlockStringsExact:
+		return canBlockString(s)
+	case BlockStringsAddNewline:
+		if len(s) > 0 && s[len(s)-1] != '\n' && strings.IndexByte(s, '\n') >= 0 {
+			s += "\n"
+		}
+		return canBlockString(s)
+	}
+	return false
+}
+
+// canBlockString reports whether a block string reads back as s: a
+// string of one or more lines, each ending in a newline, the first not
+// empty and the last not empty, with no two empty lines in a row, which
+// the parsers read back as one. No line may end with a space, which the
+// document may not, begin with "-", which would be read as a list item,
+// or be "*" or begin with "* ", which the parser rejects as a stray byte
+// array leader, and no line may hold a character the document may not.
+// The lines are read back without the indentation they all share, so one
+// of the lines after the first must have none if any has some, and the
+// first may be indented only if one of them is not.
+func canBlockString(s string) bool {
+	if len(s) < 2 || s[0] == '\n' || s[len(s)-1] != '\n' || s[len(s)-2] == '\n' || strings.Contains(s, "\n\n\n") {
+		return false
+	}
+	lines := strings.Split(s[:len(s)-1], "\n")
+	flush, indented := false, false // Among the lines after the first
+	for i, line := range lines {
+		// The parser trims any Unicode space from the ends of a line.
+		if strings.TrimSpace(line) != strings.Trim(line, " ") || strings.HasSuffix(line, " ") || strings.HasPrefix(strings.TrimLeft(line, " "), "-") {
+			return false
+		}
+		if t := strings.TrimLeft(line, " "); t == "*" || strings.HasPrefix(t, "* ") {
+			return false
+		}
+		for _, r := range line {
+			if r < 0x20 || r == utf8.RuneError || !isAllowedCodePoint(r) {
+				return false
+			}
+		}
+		if i > 0 && line != "" {
+			flush = flush || line[0] != ' '
+			indented = indented || line[0] == ' '
+		}
+	}
+	if lines[0][0] == ' ' {
+		return flush
+	}
+	return flush || !indented
+}
+
+// itemBlockReadsBack reports whether the parser reads back the block
+// string s written as an array item, if item is set, or else as the root
+// value. At the root, a first line with a colon would be read as a key,
+// and in an array, an empty line may end the string.
+func itemBlockReadsBack(s string, item bool) bool {
+	if item {
+		return !strings.Contains(s, "\n\n")
+	}
+	return !strings.Contains(s[:strings.IndexByte(s, '\n')], ":")
+}
+
+// appendBlockLines writes the lines of s, whose final newline is implied,
+// each on a line of its own indented by indent, but for the first when
+// first is set, which follows a backtick. Empty lines are left unpadded,
+// having no trailing space.
+func (e *encoder) appendBlockLines(s string, indent int, first bool) {
+	for i, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		if i > 0 {
+			e.buf = append(e.buf, '\n')
+		}
+		if line != "" && (i > 0 || !first) {
+			e.pad(indent)
+		}
+		e.buf = append(e.buf, line...)
+	}
+}
+
+// pad writes indent spaces.
+func (e *encoder) pad(indent int) {
+	for i := 0; i < indent; i++ {
+		e.buf = append(e.buf, ' ')
+	}
+}
+
+// sortedKeys returns the keys of m in order, in a pooled slice.
+func sortedKeys(m map[string]any) []string {
+	keys := keyPool.get()
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isBareKey reports whether k may be written without quotes. A leading
+// "-" would be read as a list item.
+func isBareKey(k string) bool {
+	if k == "" || k[0] == '-' {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		if !isAlphanumeric(k[i]) && k[i] != '_' && k[i] != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// appendKey writes an object key, quoted when necessary. Block keys accept
+// only the \" and \\ escapes, so keys holding characters that would need
+// other escapes, or holding both kinds of quote, cannot be written.
+func (e *encoder) appendKey(k string) error {
+	if isBareKey(k) {
+		e.buf = append(e.buf, k...)
+		return nil
+	}
+	hasDouble, hasSingle := false, false
+	for i := 0; i < len(k); {
+		r, size := utf8.DecodeRuneInString(k[i:])
+		if r == utf8.RuneError && size == 1 || !isAllowedCodePoint(r) || r == '\n' {
+			return fmt.Errorf("Cannot encode key %q", k)
+		}
+		hasDouble = hasDouble || r == '"'
+		hasSingle = hasSingle || r == '\''
+		i += size
+	}
+	switch {
+	case !hasDouble:
+		e.buf = append(e.buf, '"')
+		for i := 0; i < len(k); i++ {
+			if k[i] == '\\' {
+				e.buf = append(e.buf, '\\')
+			}
+			e.buf = append(e.buf, k[i])
+		}
+		e.buf = append(e.buf, '"')
+	case !hasSingle:
+		e.buf = append(e.buf, '\'')
+		e.buf = append(e.buf, k...)
+		e.buf = append(e.buf, '\'')
+	default:
+		return fmt.Errorf("Cannot encode key %q", k)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package yay
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Encoder
+// ============================================================================
+
+// Encoder writes YAY documents to an output stream.
+type Encoder struct {
+	w    io.Writer
+	opts EncodeOptions
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// NewEncoderWithOptions returns an Encoder writing to w and encoding
+// according to opts.
+func NewEncoderWithOptions(w io.Writer, opts EncodeOptions) *Encoder {
+	return &Encoder{w: w, opts: opts}
+}
+
+// Encode writes the YAY encoding of v to the stream, as MarshalWithOptions
+// would. Nothing is written if v cannot be encoded, unless v is an
+// iterator, whose items are written as they are yielded, so that those
+// before an item that cannot be encoded are written already.
+func (e *Encoder) Encode(v any) error {
+	if ok, err := e.streamIter(v); ok {
+		return err
+	}
+	data, err := MarshalWithOptions(v, e.opts)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// ============================================================================
+// Preparing Values
+// ============================================================================
+//
+// Before encoding, a value passes through prepare, which rewrites it into
+// the types the encoder writes: values of types with codecs become the
+// values their codecs return, nullable values become their values or nil,
+// iterators become arrays and objects, and, with OmitNull, null properties
+// are dropped.
+
+// prepare returns v ready for the encoder, as described above. Arrays and
+// objects are copied only where something within them is replaced.
+func prepare(v any, opts EncodeOptions) (any, error) {
+	v, _, err := preparer{opts: opts}.value(v)
+	if err == nil && opts.Redact != nil {
+		v, _ = redact(v, "", opts.Redact)
+	}
+	return v, err
+}
+
+// preparer holds the state of prepare: its options, how deep it is within
+// the value, and, as encoding/json keeps, the pointers, maps, and slices
+// it is within, once it is deep enough that they may form a cycle, which
+// would otherwise recur until the stack overflows. It is passed by value,
+// each level having its own depth, while the levels below the first to
+// record what it is within share that one's map.
+type preparer struct {
+	opts  EncodeOptions
+	depth int
+	seen  map[visit]bool
+}
+
+// startDetectingCyclesAfter is how deep the preparer goes before it begins
+// recording what it is within, as encoding/json does, sparing shallow
+// values the cost.
+const startDetectingCyclesAfter = 1000
+
+// value is prepare, also reporting whether anything was replaced.
+func (p preparer) value(v any) (any, bool, error) {
+	p.depth++
+	if p.depth > startDetectingCyclesAfter {
+		if key, ok := visitOf(v); ok {
+			if p.seen[key] {
+				return nil, false, fmt.Errorf("Cannot encode a value that encountered a cycle via %T", v)
+			}
+			if p.seen == nil {
+				p.seen = make(map[visit]bool)
+			}
+			p.seen[key] = true
+			defer delete(p.seen, key)
+		}
+	}
+	switch x := v.(type) {
+	case nil, bool, *big.Int, string, []byte, float32, float64,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, false, nil
+	case Array:
+		return p.value([]any(x))
+	case []any:
+		var copied []any
+		for i, item := range x {
+			converted, changed, err := p.value(item)
+			if err != nil {
+				return nil, false, atPath(err, "["+strconv.Itoa(i)+"]")
+			}
+			if changed && copied == nil {
+				copied = append([]any(nil), x...)
+			}
+			if copied != nil {
+				copied[i] = converted
+			}
+		}
+		if copied == nil {
+			return v, false, nil
+		}
+		return copied, true, nil
+	case map[string]any:
+		var copied map[string]any
+		for k, item := range x {
+			converted, changed, err := p.value(item)
+			if err != nil {
+				return nil, false, atPath(err, keyPathElement(k))
+			}
+			if p.opts.OmitNull && isNull(converted) {
+				changed = true
+			}
+			if changed && copied == nil {
+				copied = make(map[string]any, len(x))
+				for k, item := range x {
+					copied[k] = item
+				}
+			}
+			if copied == nil {
+				continue
+			}
+			if p.opts.OmitNull && isNull(converted) {
+				delete(copied, k)
+			} else {
+				copied[k] = converted
+			}
+		}
+		if copied == nil {
+			return v, false, nil
+		}
+		return copied, true, nil
+	case *OrderedMap:
+		if x == nil {
+			return v, false, nil
+		}
+		var copied *OrderedMap
+		for i, m := range x.members {
+			converted, changed, err := p.value(m.Value)
+			if err != nil {
+				return nil, false, atPath(err, keyPathElement(m.Key))
+			}
+			omit := p.opts.OmitNull && isNull(converted)
+			if (changed || omit) && copied == nil {
+				copied = &OrderedMap{members: append([]Member(nil), x.members[:i]...)}
+			}
+			if copied != nil && !omit {
+				copied.members = append(copied.members, Member{Key: m.Key, Value: converted})
+			}
+		}
+		if copied == nil {
+			return v, false, nil
+		}
+		return copied, true, nil
+	}
+	return p.convert(v)
+}
+
+// redacted is written in place of the values EncodeOptions.Redact matches.
+const redacted = "[redacted]"
+
+// redact returns v, a prepared value at path, with the values within it
+// that match replaced, also reporting whether anything was replaced. The
+// document itself, at "", is never replaced. Arrays and objects are copied
+// only where something within them is replaced.
+func redact(v any, path string, match func(path string) bool) (any, bool) {
+	if path != "" && match(path) {
+		return redacted, true
+	}
+	switch x := v.(type) {
+	case []any:
+		var copied []any
+		for i, item := range x {
+			converted, changed := redact(item, path+"["+strconv.Itoa(i)+"]", match)
+			if changed && copied == nil {
+				copied = append([]any(nil), x...)
+			}
+			if copied != nil {
+				copied[i] = converted
+			}
+		}
+		if copied != nil {
+			return copied, true
+		}
+	case map[string]any:
+		var copied map[string]any
+		for k, item := range x {
+			converted, changed := redact(item, path+keyPathElement(k), match)
+			if changed && copied == nil {
+				copied = make(map[string]any, len(x))
+				for k, item := range x {
+					copied[k] = item
+				}
+			}
+			if copied != nil {
+				copied[k] = converted
+			}
+		}
+		if copied != nil {
+			return copied, true
+		}
+	case *OrderedMap:
+		if x == nil {
+			return v, false
+		}
+		var copied *OrderedMap
+		for i, m := range x.members {
+			converted, changed := redact(m.Value, path+keyPathElement(m.Key), match)
+			if changed && copied == nil {
+				copied = &OrderedMap{members: append([]Member(nil), x.members...)}
+			}
+			if copied != nil {
+				copied.members[i].Value = converted
+			}
+		}
+		if copied != nil {
+			return copied, true
+		}
+	}
+	return v, false
+}
+
+// RedactKeys returns a matcher for EncodeOptions.Redact that redacts the
+// value of every property with one of the given keys, wherever it is.
+//
+//	opts := yay.EncodeOptions{Redact: yay.RedactKeys("password", "token")}
+func RedactKeys(keys ...string) func(path string) bool {
+	elems := make([]string, len(keys))
+	for i, k := range keys {
+		elems[i] = keyPathElement(k)
+	}
+	return func(path string) bool {
+		for _, elem := range elems {
+			if strings.HasSuffix(path, elem) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// encodeError reports a value that cannot be encoded: one of a type the
+// encoder does not write, or for which a codec failed.
+type encodeError struct {
+	typ    string // The Go type of the value
+	path   string // Of the value, as keyPathElement writes it
+	reason string // Why the value cannot be encoded, if not err
+	err    error  // From the codec
+}
+
+func (e *encodeError) Error() string {
+	msg := "Cannot encode " + e.typ
+	if e.path != "" {
+		msg += " at " + displayPath(e.path)
+	}
+	if e.err != nil {
+		return msg + ": " + e.err.Error()
+	}
+	if e.reason != "" {
+		return msg + ": " + e.reason
+	}
+	return msg
+}
+
+func (e *encodeError) Unwrap() error {
+	return e.err
+}
+
+// atPath returns err, from preparing the value at elem within its parent,
+// with elem added to the front of its path.
+func atPath(err error, elem string) error {
+	if e, ok := err.(*encodeError); ok {
+		e.path = elem + e.path
+	}
+	return err
+}
+
+// isNull reports whether the encoder writes v as null.
+func isNull(v any) bool {
+	n, isInt := v.(*big.Int)
+	return v == nil || isInt && n == nil
+}
@@ -0,0 +1,108 @@
+package yay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Environment Overrides
+// ============================================================================
+//
+// ApplyEnv lets the environment override a decoded configuration, in the
+// manner of a twelve-factor app, without any interpolation in the files.
+// A variable names the value it overrides by its path, mangled thus:
+//
+//   - The name begins with a prefix, such as "APP_", which is removed.
+//   - "__" separates the keys of the path, so APP_SERVER__PORT overrides
+//     server.port. A single "_" is part of a key.
+//   - A key matches a property of the document whatever its case, and with
+//     "_" matching "-" as well, so APP_LOG__MAX_SIZE overrides
+//     log.max-size. A key that matches none is added in lower case.
+//   - A key of digits selects an array item, as in APP_SERVERS__0__HOST.
+//
+// The value of a variable replaces a string as it is. Any other value is
+// replaced by the variable read as a YAY value, such as 9090, true, or
+// [1, 2], or as a string if it does not read as one.
+
+// ApplyEnv applies the variables in environ, given as "NAME=value" in the
+// form os.Environ returns, whose names begin with prefix, to *doc as
+// described above. Variables apply in order of name, so a variable for a
+// value applies after one for a value that holds it. Errors, such as a
+// path that crosses a scalar, name the variable.
+//
+//	err := yay.ApplyEnv(&config, "APP_", os.Environ())
+func ApplyEnv(doc *any, prefix string, environ []string) error {
+	var names []string
+	values := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		if _, seen := values[name]; !seen {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := applyEnvVar(doc, strings.Split(name[len(prefix):], "__"), values[name]); err != nil {
+			return fmt.Errorf("Cannot apply %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// applyEnvVar stores value at the path of mangled keys within *doc.
+func applyEnvVar(doc *any, keys []string, value string) error {
+	segments := make([]pathSegment, len(keys))
+	v, ok := *doc, *doc != nil
+	for i, k := range keys {
+		if k == "" {
+			return fmt.Errorf("%w: empty key", ErrInvalidPath)
+		}
+		if index, isIndex := arrayIndex(k); isIndex {
+			if _, isArray := arrayItems(v); isArray || !ok {
+				segments[i] = pathSegment{index: index}
+				v, ok, _ = step(v, segments[:i+1])
+				continue
+			}
+		}
+		segments[i] = pathSegment{key: envKey(v, k), isKey: true}
+		if ok {
+			v, ok, _ = step(v, segments[:i+1])
+		}
+	}
+	var newValue any = value
+	if _, isString := v.(string); !isString {
+		if parsed, err := Unmarshal([]byte(value)); err == nil {
+			newValue = parsed
+		}
+	}
+	updated, err := setPath(*doc, *doc != nil, segments, 0, newValue)
+	if err != nil {
+		return err
+	}
+	*doc = updated
+	return nil
+}
+
+// envKey returns the key of the property of obj that k, a key from a
+// variable name, matches, or k in lower case if there is none. Of several
+// matching keys, the least is chosen.
+func envKey(obj any, k string) string {
+	match := ""
+	found := false
+	eachProperty(obj, func(key string, _ any) bool {
+		if strings.EqualFold(strings.ReplaceAll(key, "-", "_"), k) && (!found || key < match) {
+			match, found = key, true
+		}
+		return true
+	})
+	if found {
+		return match
+	}
+	return strings.ToLower(k)
+}
@@ -0,0 +1,76 @@
+package yay
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+)
+
+// ============================================================================
+// Equality
+// ============================================================================
+
+// Equal reports whether a and b are the same YAY value. It compares the
+// values Unmarshal produces the way a reader of the documents would:
+// *big.Int values by number rather than by pointer, NaN equal to NaN, and
+// byte arrays, arrays, and objects element by element. Arrays are equal
+// whether they are []any or Array, and objects are equal when they hold
+// the same properties, whatever their order and whether they are
+// map[string]any or *OrderedMap. Values of other types are compared with
+// reflect.DeepEqual, or in a yay_tiny build with ==.
+func Equal(a, b any) bool {
+	if x, ok := a.(Array); ok {
+		a = []any(x)
+	}
+	if x, ok := b.(Array); ok {
+		b = []any(x)
+	}
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			return a == b || math.IsNaN(a) && math.IsNaN(b)
+		}
+	case *big.Int:
+		if b, ok := b.(*big.Int); ok {
+			if a == nil || b == nil {
+				return a == b
+			}
+			return a.Cmp(b) == 0
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			return bytes.Equal(a, b)
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			if len(a) != len(b) {
+				return false
+			}
+			for i := range a {
+				if !Equal(a[i], b[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	case map[string]any, *OrderedMap:
+		if isObject(b) {
+			return equalObjects(a, b)
+		}
+	}
+	return deepEqual(a, b)
+}
+
+// equalObjects compares two objects in either representation.
+func equalObjects(a, b any) bool {
+	if objectLen(a) != objectLen(b) {
+		return false
+	}
+	equal := true
+	eachProperty(a, func(k string, av any) bool {
+		bv, ok := getProperty(b, k)
+		equal = ok && Equal(av, bv)
+		return equal
+	})
+	return equal
+}
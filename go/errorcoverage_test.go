@@ -0,0 +1,215 @@
+package yay
+
+import (
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uncoveredErrors lists the parser's error messages that no nay fixture
+// produces yet, by format string. TestErrorCoverage fails when a new
+// message appears without a fixture, and when a listed message gains one,
+// so that the list only ever shrinks.
+var uncoveredErrors = map[string]bool{
+	"%w while parsing: %v":             true, // Reports a bug; see errInternal
+	"Bad character in string%s":        true,
+	"Expected array%s":                 true,
+	"Expected object%s":                true,
+	"Float overflow%s":                 true,
+	"Float underflow%s":                true,
+	"Inconsistent indentation%s":       true,
+	"Invalid UTF-8 (byte offset %d)%s": true,
+	"Invalid byte literal%s":           true,
+	"Unclosed angle bracket%s":         true,
+	"Unexpected empty value%s":         true,
+	"Unterminated inline array%s":      true,
+	"Unterminated inline object%s":     true,
+	"expected single-quoted string":    true,
+	"expected string":                  true,
+	"invalid unicode escape":           true,
+	"unterminated string":              true,
+}
+
+// errorFormat is a message the parser can emit, with where it is emitted.
+type errorFormat struct {
+	format string
+	pos    gotoken.Position
+	re     *regexp.Regexp
+}
+
+// parserErrorFormats finds every error the parser source in file makes
+// whose format is a string literal: with fmt.Errorf, with errorf, which
+// adds the position with a final %s, or with unplacedErrorf. With warnings,
+// it finds the formats of warnf too.
+func parserErrorFormats(tb testing.TB, file string, warnings bool) []errorFormat {
+	fset := gotoken.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	var formats []errorFormat
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		arg, suffix := 0, ""
+		switch sel.Sel.Name {
+		case "Errorf":
+			if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "fmt" {
+				return true
+			}
+		case "errorf":
+			arg, suffix = 1, "%s"
+		case "warnf":
+			if !warnings {
+				return true
+			}
+			arg = 1
+		case "unplacedErrorf":
+		default:
+			return true
+		}
+		if len(call.Args) <= arg {
+			return true
+		}
+		lit, ok := call.Args[arg].(*ast.BasicLit)
+		if !ok || lit.Kind != gotoken.STRING {
+			return true
+		}
+		format, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		format += suffix
+		if !seen[format] {
+			seen[format] = true
+			formats = append(formats, errorFormat{
+				format: format,
+				pos:    fset.Position(lit.Pos()),
+				re:     formatRegexp(format),
+			})
+		}
+		return true
+	})
+	return formats
+}
+
+// verb matches a fmt verb with its flags, width, and precision.
+var verb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]*)?[a-zA-Z%]`)
+
+// formatRegexp returns a pattern matching the messages format produces,
+// with each verb standing for any text.
+func formatRegexp(format string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range verb.FindAllStringIndex(format, -1) {
+		b.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		if format[loc[1]-1] == '%' {
+			b.WriteString("%")
+		} else {
+			b.WriteString("(?s:.*)")
+		}
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(format[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func TestErrorCoverage(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "test", "nay", "*.nay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := UnmarshalFile(data, filepath.Base(path)); err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	var uncovered []string
+	formats := parserErrorFormats(t, "yay.go", false)
+	made := make(map[string]bool)
+	for _, f := range formats {
+		made[f.format] = true
+	}
+	for format := range uncoveredErrors {
+		if !made[format] {
+			t.Errorf("%q is no longer made; remove it from uncoveredErrors", format)
+		}
+	}
+	for _, f := range formats {
+		covered := false
+		for _, msg := range messages {
+			if f.re.MatchString(msg) {
+				covered = true
+				break
+			}
+		}
+		switch {
+		case !covered && !uncoveredErrors[f.format]:
+			t.Errorf("%s: no nay fixture produces %q", f.pos, f.format)
+		case covered && uncoveredErrors[f.format]:
+			t.Errorf("%s: %q is now covered; remove it from uncoveredErrors", f.pos, f.format)
+		}
+		if !covered {
+			uncovered = append(uncovered, f.format)
+		}
+	}
+	sort.Strings(uncovered)
+	for _, format := range uncovered {
+		t.Logf("uncovered: %q", format)
+	}
+}
+
+// TestCatalog checks that every message the parser makes has a code in the
+// catalog, and that every code in the catalog is for a message it makes.
+func TestCatalog(t *testing.T) {
+	used := make(map[string]bool)
+	for _, file := range []string{"yay.go", "limits.go", "version.go", "warnings.go", "literals.go", "ast.go"} {
+		for _, f := range parserErrorFormats(t, file, true) {
+			if f.format == "%w%s" || f.format == "%w while parsing: %v" {
+				continue // Passes another error along, or reports a bug
+			}
+			format := strings.TrimSuffix(f.format, "%s")
+			if strings.HasPrefix(format, "%w") {
+				// The message of the sentinel is spelled out in the catalog.
+				for code, english := range englishMessages {
+					if strings.HasSuffix(english, format[len("%w"):]) {
+						used[code] = true
+					}
+				}
+				continue
+			}
+			code, ok := englishCodes[format]
+			if !ok {
+				t.Errorf("%s: %q has no code in the catalog", f.pos, format)
+			}
+			used[code] = true
+		}
+	}
+	for code := range englishMessages {
+		if !used[code] {
+			t.Errorf("%q is in the catalog, but no message has it", code)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Collecting Errors
+// ============================================================================
+//
+// The parser stops at the first error it finds. To find the errors after
+// it, for DecodeOptions.MaxErrors, the document is parsed again with the
+// line of the error blanked, along with the lines indented beneath it,
+// which would otherwise be reported in turn as out of place. The line
+// numbers of the document stay as they were, and the offsets of the errors
+// are moved back to where they are in the document as it was.
+//
+// Blanking a line can make an error of its own, as when it leaves a key
+// with no value, so the errors after the first are a guide to what else
+// needs fixing rather than a promise that each is there. An error with no
+// one position, such as "No value found", once lines have been blanked, is
+// of the blanking, and ends the search.
+
+// ParseErrors is the error of a document decoded with
+// DecodeOptions.MaxErrors, listing the errors of its parse in the order
+// they were found, the first being the one that decoding alone reports.
+// It unwraps to them, so errors.As finds the first *ParseError, and
+// ErrorCode gives its code.
+type ParseErrors []*ParseError
+
+func (l ParseErrors) Error() string {
+	switch len(l) {
+	case 0:
+		return "No errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return l[0].Error() + " (and 1 more error)"
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Render returns each error rendered as ParseError.Render renders it, in
+// the order of the list.
+func (l ParseErrors) Render(source []byte) string {
+	var b strings.Builder
+	for _, e := range l {
+		b.WriteString(e.Render(source))
+	}
+	return b.String()
+}
+
+func (l ParseErrors) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// collectErrors returns the ParseErrors of source, named filename, whose
+// parse under opts failed with err, finding up to opts.MaxErrors of them.
+// An error other than a *ParseError, as of a bug, is returned alone.
+func collectErrors(source string, err error, filename string, opts DecodeOptions) error {
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	list := ParseErrors{pe}
+	lines := strings.SplitAfter(source, "\n")
+	blanked := make([]bool, len(lines))
+	blank := func(i int) {
+		blanked[i] = true
+		bom := ""
+		if i == 0 && opts.AllowBOM && strings.HasPrefix(lines[0], "\uFEFF") {
+			bom = "\uFEFF"
+		}
+		if strings.HasSuffix(lines[i], "\n") {
+			lines[i] = bom + "\n"
+		} else {
+			lines[i] = bom
+		}
+	}
+	for len(list) < opts.MaxErrors && pe.Line > 0 && pe.Line <= len(lines) && !blanked[pe.Line-1] {
+		i := pe.Line - 1
+		indent := countIndent(lines[i])
+		blank(i)
+		for j := i + 1; j < len(lines); j++ {
+			line := strings.TrimSuffix(lines[j], "\n")
+			if line == "" {
+				continue
+			}
+			if countIndent(line) <= indent {
+				break
+			}
+			blank(j)
+		}
+
+		edited := strings.Join(lines, "")
+		ctx := newParseContext(edited, filename, opts)
+		ctx.warn = nil // Warned of already
+		if _, err := parse(ctx); !errors.As(err, &pe) || pe.Line == 0 {
+			break
+		}
+		pe.Offset += lineStart(source, pe.Line-1) - lineStart(edited, pe.Line-1)
+		list = append(list, pe)
+	}
+	return list
+}
+
+// lineStart returns the byte offset in source of the line with zero-based
+// index line.
+func lineStart(source string, line int) int {
+	off := 0
+	for ; line > 0; line-- {
+		off += strings.IndexByte(source[off:], '\n') + 1
+	}
+	return off
+}
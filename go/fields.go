@@ -0,0 +1,234 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// Struct Field Metadata
+// ============================================================================
+//
+// Encoding and decoding Go structs needs, for each struct type, the list of
+// fields that take part, the object key for each, and the index path used to
+// reach fields promoted from embedded structs. Working that out means walking
+// the type with reflection and parsing every `yay:"..."` tag, which is far
+// more expensive than the decode itself for a small configuration document.
+// As in encoding/json, the result is computed once per type and cached.
+
+// field describes one struct field as seen by the encoder and decoder.
+type field struct {
+	name      string       // Object key
+	goName    string       // Go field name
+	index     []int        // Index path for reflect.Value.FieldByIndex
+	typ       reflect.Type // Field type
+	tagged    bool         // Whether the key came from a tag
+	omitEmpty bool         // Tag option "omitempty"
+
+	constraints   *constraints // Tag options for validation, if any
+	constraintErr error        // Why the validation options are malformed
+}
+
+// structFields is the cached metadata for one struct type.
+type structFields struct {
+	list     []field        // In declaration order, embedded fields inline
+	byName   map[string]int // Object key to position in list
+	position []int          // Index path of the Position field, if any
+}
+
+var positionType = reflect.TypeOf(Position{})
+
+// fieldCache maps reflect.Type to *structFields.
+var fieldCache sync.Map
+
+// cachedTypeFields returns the field metadata for struct type t, computing
+// it on first use. Concurrent first uses may each compute it; all but one
+// result are discarded.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(*structFields)
+}
+
+// parseTag splits a struct tag into its key name and comma-separated options.
+func parseTag(tag string) (string, tagOptions) {
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, tagOptions(opts)
+}
+
+// tagOptions is the portion of a struct tag after the first comma.
+type tagOptions string
+
+// contains reports whether the options include opt.
+func (o tagOptions) contains(opt string) bool {
+	s := string(o)
+	for s != "" {
+		var name string
+		name, s, _ = strings.Cut(s, ",")
+		if name == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// typeFields walks struct type t breadth first, so that shallower fields
+// are found before the embedded fields they shadow, and applies the Go
+// visibility rules for promoted fields: among fields of one name, the
+// shallowest wins, a tagged field beats an untagged one at the same depth,
+// and any remaining tie hides the name altogether.
+func typeFields(t reflect.Type) *structFields {
+	type pending struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []field
+	var position []int
+	current := []pending{}
+	next := []pending{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	// How many times each struct type is embedded at the current and
+	// next depth, used to detect ties without revisiting.
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, p := range current {
+			if visited[p.typ] {
+				continue
+			}
+			visited[p.typ] = true
+
+			for i := 0; i < p.typ.NumField(); i++ {
+				sf := p.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("yay")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+
+				index := make([]int, len(p.index)+1)
+				copy(index, p.index)
+				index[len(p.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				// A Position field takes where the object begins rather
+				// than a key, the shallowest first.
+				if ft == positionType || opts.contains("position") {
+					if position == nil {
+						position = index
+					}
+					continue
+				}
+
+				// Untagged embedded structs are flattened into the parent.
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, pending{typ: ft, index: index})
+					}
+					continue
+				}
+
+				f := field{
+					name:      name,
+					goName:    sf.Name,
+					index:     index,
+					typ:       sf.Type,
+					tagged:    name != "",
+					omitEmpty: opts.contains("omitempty"),
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				f.constraints, f.constraintErr = parseConstraints(opts)
+				fields = append(fields, f)
+
+				// A struct embedded twice at one depth contributes its
+				// fields twice, so that the tie below hides them.
+				if count[p.typ] > 1 {
+					fields = append(fields, fields[len(fields)-1])
+				}
+			}
+		}
+	}
+
+	// Order by name, then depth, then taggedness, so that each run of
+	// same-named fields starts with its dominant candidate.
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := &fields[i], &fields[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(a.index) != len(b.index) {
+			return len(a.index) < len(b.index)
+		}
+		return a.tagged && !b.tagged
+	})
+
+	kept := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if f, ok := dominantField(fields[i:j]); ok {
+			kept = append(kept, f)
+		}
+		i = j
+	}
+	fields = kept
+
+	// Restore declaration order.
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	byName := make(map[string]int, len(fields))
+	for i, f := range fields {
+		byName[f.name] = i
+	}
+	return &structFields{list: fields, byName: byName, position: position}
+}
+
+// dominantField picks the visible field among same-named candidates, sorted
+// shallowest and tagged first. It fails when the first two are tied.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
@@ -10,6 +10,11 @@ import (
 // Helper functions for fixture expressions
 func NewInt(x int64) *big.Int { return big.NewInt(x) }
 
+func BigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
 func List(items ...any) []any { return items }
 
 func Map(kvs ...any) map[string]any {
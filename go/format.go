@@ -0,0 +1,286 @@
+package yay
+
+import (
+	"errors"
+	"strings"
+)
+
+// ============================================================================
+// Formatting
+// ============================================================================
+//
+// Format lays a document out as Marshal would, while keeping what the
+// author chose: the order of properties, whether each collection is
+// written inline or as a block, and the comments and blank lines between
+// members. It walks the syntax tree alongside the text, so that the lines
+// between the members of a block, which can only be blank or comments at
+// the margin, are carried over as they are, and a comment after a value
+// stays after it on its line.
+//
+// Scalars keep their spelling, such as the grouping of the digits of a
+// number, but for single-quoted strings, which are double-quoted. Block
+// strings, block bytes, and concatenated strings keep their lines as
+// written, moved left or right with the key or dash that introduces them,
+// since the indentation their lines share is not part of their values.
+
+// Format returns src formatted in the canonical layout: two spaces of
+// indentation for each level of nesting, arrays indented under their keys,
+// and strings double-quoted, as Marshal writes them. Comments are kept,
+// and runs of blank lines are reduced to one. Format checks that the
+// document it returns has the value of src, and fails rather than return
+// one that does not. It returns an error, and never panics, for any src.
+func Format(src []byte) ([]byte, error) {
+	root, err := ParseAST(src)
+	if err != nil {
+		return nil, err
+	}
+	f := &formatter{src: string(src), buf: make([]byte, 0, len(src))}
+	f.gap(root.Extent().Start.Offset)
+	f.root(root)
+	f.gap(len(src) + 1)
+	if f.reordered {
+		return nil, errors.New("Cannot format this document, which repeats a key after other properties")
+	}
+	out := f.buf
+	if got, err := ParseAST(out); err != nil || !Equal(nodeValue(got), nodeValue(root)) {
+		return nil, errors.New("Cannot format this document without changing its value")
+	}
+	return out, nil
+}
+
+// formatter writes the formatted text of a document. Each of its methods
+// that writes a value begins part way along a line and ends after the
+// line feed of the value's last line.
+type formatter struct {
+	src   string
+	buf   []byte
+	next  int  // Offset in src of the first line not yet written
+	blank bool // Whether a blank line is due before the next line
+
+	// reordered records whether a property began on a line already
+	// written, as when a key repeats, and the last occurrence, whose value
+	// stands, follows the lines of other properties.
+	reordered bool
+}
+
+// root writes the root value.
+func (f *formatter) root(n Node) {
+	switch {
+	case isBlockCollection(n):
+		f.block(n, 0, false)
+	case isMultiline(f.src, n):
+		f.shifted(n, 0)
+	default:
+		f.line(0)
+		f.inline(n)
+		f.trailing(n.Extent().End.Offset)
+	}
+}
+
+// block writes a block array or object whose members are at column
+// indent. With first set, the first member continues the current line,
+// after an array item's "- ".
+func (f *formatter) block(n Node, indent int, first bool) {
+	switch n := n.(type) {
+	case *ObjectNode:
+		for i, p := range n.Properties {
+			f.reordered = f.reordered || p.Start.Offset < f.next
+			if i > 0 || !first {
+				f.gap(p.Start.Offset)
+				f.line(indent)
+			}
+			f.property(p, indent)
+		}
+	case *ArrayNode:
+		for i, item := range n.Items {
+			dash := item.Extent().Start.Offset - len("- ")
+			if i > 0 || !first {
+				f.gap(dash)
+				f.line(indent)
+			}
+			f.buf = append(f.buf, "- "...)
+			switch {
+			case isBlockCollection(item):
+				f.block(item, indent+2, true)
+			case isMultiline(f.src, item):
+				f.shifted(item, indent-column(f.src, dash))
+			default:
+				f.inline(item)
+				f.trailing(item.Extent().End.Offset)
+			}
+		}
+	}
+}
+
+// property writes a property of a block object at column indent.
+func (f *formatter) property(p *PropertyNode, indent int) {
+	e := newEncoder(EncodeOptions{}, len(p.Key)+2)
+	if err := e.appendKey(p.Key); err != nil {
+		e.buf = append(e.buf[:0], f.src[p.KeySpan.Start.Offset:p.KeySpan.End.Offset]...)
+	}
+	f.buf = append(f.buf, e.buf...)
+	f.buf = append(f.buf, ':')
+	colon := p.KeySpan.End.Offset
+	v := p.Value
+	start := v.Extent().Start.Offset
+	switch {
+	case isBlockCollection(v):
+		f.trailing(colon + len(":"))
+		f.block(v, indent+2, false)
+	case isMultiline(f.src, v) && startOfLine(f.src, start) <= colon:
+		f.buf = append(f.buf, ' ')
+		f.shifted(v, indent-column(f.src, p.Start.Offset))
+	case isMultiline(f.src, v):
+		f.trailing(colon + len(":"))
+		f.gap(start)
+		f.shifted(v, indent-column(f.src, p.Start.Offset))
+	case start == v.Extent().End.Offset:
+		f.trailing(colon + len(":")) // A null left implicit at the end
+	default:
+		f.buf = append(f.buf, ' ')
+		f.inline(v)
+		f.trailing(v.Extent().End.Offset)
+	}
+}
+
+// inline writes a value that is on one line: an inline array or object,
+// or a scalar as it is written, but for a single-quoted string, which is
+// written double-quoted as Marshal writes strings.
+func (f *formatter) inline(n Node) {
+	switch n := n.(type) {
+	case *ObjectNode:
+		f.buf = append(f.buf, '{')
+		for i, p := range n.Properties {
+			if i > 0 {
+				f.buf = append(f.buf, ", "...)
+			}
+			e := newEncoder(EncodeOptions{}, len(p.Key)+2)
+			if err := e.appendKey(p.Key); err != nil {
+				e.buf = append(e.buf[:0], f.src[p.KeySpan.Start.Offset:p.KeySpan.End.Offset]...)
+			}
+			f.buf = append(f.buf, e.buf...)
+			f.buf = append(f.buf, ": "...)
+			f.inline(p.Value)
+		}
+		f.buf = append(f.buf, '}')
+	case *ArrayNode:
+		f.buf = append(f.buf, '[')
+		for i, item := range n.Items {
+			if i > 0 {
+				f.buf = append(f.buf, ", "...)
+			}
+			f.inline(item)
+		}
+		f.buf = append(f.buf, ']')
+	default:
+		s := n.Extent()
+		if str, ok := n.(*StringNode); ok && strings.HasPrefix(f.src[s.Start.Offset:], "'") {
+			f.buf = appendString(f.buf, str.Value)
+		} else {
+			f.buf = append(f.buf, f.src[s.Start.Offset:s.End.Offset]...)
+		}
+	}
+}
+
+// shifted writes the lines of a value as they are in the source, each
+// moved delta columns, but for comments at the margin, which the scanner
+// skips wherever they are. The first line is written as it is if it
+// continues the current line.
+func (f *formatter) shifted(n Node, delta int) {
+	s := n.Extent()
+	start, end := s.Start.Offset, s.End.Offset
+	if len(f.buf) == 0 || f.buf[len(f.buf)-1] == '\n' {
+		f.line(column(f.src, start) + delta)
+	}
+	for {
+		lineEnd := min(endOfLine(f.src, start), end)
+		f.buf = append(f.buf, f.src[start:lineEnd]...)
+		if lineEnd == end {
+			break
+		}
+		f.buf = append(f.buf, '\n')
+		start = lineEnd + 1
+		line := f.src[start:min(endOfLine(f.src, start), end)]
+		indent := countIndent(line)
+		switch {
+		case indent == len(line):
+			start += indent // Blank, and left so
+		case indent == 0 && line[0] == '#':
+		default:
+			f.pad(indent + delta)
+			start += indent
+		}
+	}
+	f.trailing(end)
+}
+
+// trailing writes what follows off on its line, which can only be a
+// comment, and the line feed that ends the line.
+func (f *formatter) trailing(off int) {
+	end := endOfLine(f.src, off)
+	f.buf = append(f.buf, strings.TrimRight(f.src[off:end], " ")...)
+	f.buf = append(f.buf, '\n')
+	f.next = min(end+1, len(f.src))
+}
+
+// gap writes the lines from the first not yet written up to the line that
+// holds off, which are blank or comments at the margin. A run of blank
+// lines becomes one, before the next line written, unless that is the
+// first or there is none.
+func (f *formatter) gap(off int) {
+	for f.next < len(f.src) && endOfLine(f.src, f.next) < off {
+		end := endOfLine(f.src, f.next)
+		line := f.src[f.next:end]
+		if line == "" {
+			f.blank = len(f.buf) > 0
+		} else {
+			f.line(0)
+			f.buf = append(f.buf, line...)
+			f.buf = append(f.buf, '\n')
+		}
+		f.next = end + 1
+	}
+}
+
+// line begins a line at column indent, after a blank line if one is due.
+func (f *formatter) line(indent int) {
+	if f.blank {
+		f.buf = append(f.buf, '\n')
+		f.blank = false
+	}
+	f.pad(indent)
+}
+
+// pad writes indent spaces.
+func (f *formatter) pad(indent int) {
+	for i := 0; i < indent; i++ {
+		f.buf = append(f.buf, ' ')
+	}
+}
+
+// isBlockCollection reports whether n is an array or object written as a
+// block.
+func isBlockCollection(n Node) bool {
+	switch n := n.(type) {
+	case *ObjectNode:
+		return !n.Inline
+	case *ArrayNode:
+		return !n.Inline
+	}
+	return false
+}
+
+// isMultiline reports whether n, a scalar of the document src, is written
+// over several lines, or as a block string or block bytes, which keep
+// their form.
+func isMultiline(src string, n Node) bool {
+	s := n.Extent()
+	if s.Start.Line != s.End.Line {
+		return true
+	}
+	if s.Start.Offset >= len(src) {
+		return false
+	}
+	c := src[s.Start.Offset]
+	return c == '`' || c == '>'
+}
@@ -0,0 +1,379 @@
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// corpus returns the contents of every yay and nay fixture, to seed the
+// fuzz targets with documents that reach each corner of the parser.
+func corpus(tb testing.TB) [][]byte {
+	var docs [][]byte
+	for _, dir := range []string{"yay", "nay"} {
+		paths, err := filepath.Glob(filepath.Join("..", "test", dir, "*."+dir))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			docs = append(docs, data)
+		}
+	}
+	return docs
+}
+
+// malformed holds short documents that end, or break off, where the parser
+// indexes into what it expects to follow, to seed the fuzz targets with the
+// edges of the no-panic guarantee.
+var malformed = []string{
+	"", "\n", "-", "- ", "- -", "- - -", "-\n-", "#!yay", "#!yay ", "#!yay 9",
+	"\"", "\"\\", "\"\\u", "\"\\u12", "'", "`", "` ", ">", "> ", "<", "<>", "< >",
+	"[", "[[", "[,", "[1,", "{", "{a", "{a:", "{a: ", "{\"", "{'a': [",
+	"a:", "a: ", "a: >", "a: `", "a: {", "a: [", "a: <", "a: \"", "a:\n  b:",
+	"a:\n  - ", "- a:\n    -", "a: >\n  0", "a: `\n\n", ".", "-.", "1e", "-infinity",
+}
+
+// checkParseError asserts that err, from parsing data, is an ordinary
+// error and not a panic recovered by the parser.
+func checkParseError(t *testing.T, data []byte, err error) {
+	if errors.Is(err, errInternal) {
+		t.Fatalf("parse of %q: %v", data, err)
+	}
+}
+
+// checkRoundTrip asserts that a value parsed from data survives being
+// encoded and parsed again.
+func checkRoundTrip(t *testing.T, data []byte, v any) {
+	out, err := Marshal(v)
+	if err != nil {
+		// Some keys, such as those holding both kinds of quote, parse but
+		// have no block encoding, and some strings within an array in an
+		// array have no inline encoding.
+		if strings.HasPrefix(err.Error(), "Cannot encode key") || strings.HasSuffix(err.Error(), "in an inline collection") {
+			return
+		}
+		t.Fatalf("Marshal(%#v) of %q: %v", v, data, err)
+	}
+	again, err := Unmarshal(out)
+	if err != nil {
+		t.Fatalf("re-parse of %q from %q: %v", out, data, err)
+	}
+	if !Equal(v, again) {
+		t.Fatalf("round trip of %q through %q:\ngot:  %#v\nwant: %#v", data, out, again, v)
+	}
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc)
+	}
+	for _, doc := range malformed {
+		f.Add([]byte(doc))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Unmarshal(data)
+		checkParseError(t, data, err)
+		if valid := Valid(data); valid != (err == nil) {
+			t.Fatalf("Valid(%q) = %v, Unmarshal error %v", data, valid, err)
+		}
+		if _, ferr := UnmarshalFile(data, "fuzz.yay"); fmt.Sprint(ferr) != fmt.Sprint(ValidFile(data, "fuzz.yay")) {
+			t.Fatalf("ValidFile(%q) = %v, UnmarshalFile error %v", data, ValidFile(data, "fuzz.yay"), ferr)
+		}
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, data, v)
+	})
+}
+
+func FuzzInline(f *testing.F) {
+	for _, doc := range corpus(f) {
+		line, _, _ := strings.Cut(string(doc), "\n")
+		if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "{") {
+			f.Add(line)
+		}
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if strings.ContainsAny(s, "\r\n") {
+			return
+		}
+		for _, doc := range []string{s, "key: " + s, "- " + s} {
+			data := []byte(doc + "\n")
+			v, err := Unmarshal(data)
+			checkParseError(t, data, err)
+			if err == nil {
+				checkRoundTrip(t, data, v)
+			}
+		}
+	})
+}
+
+func FuzzBlockString(f *testing.F) {
+	f.Add("Hello,\n  World!\n")
+	f.Add("\n\nafter blank lines\n")
+	f.Add("# not a comment\n`\n")
+	f.Add("trailing spaces  \n")
+	f.Fuzz(func(t *testing.T, body string) {
+		var b strings.Builder
+		for _, line := range strings.Split(body, "\n") {
+			if line != "" {
+				b.WriteString("  ")
+				b.WriteString(line)
+			}
+			b.WriteString("\n")
+		}
+		indented := b.String()
+		for _, doc := range []string{"`\n" + indented, "key: `\n" + indented, "- `\n" + indented} {
+			data := []byte(doc)
+			v, err := Unmarshal(data)
+			checkParseError(t, data, err)
+			if err == nil {
+				checkRoundTrip(t, data, v)
+			}
+		}
+	})
+}
+
+// FuzzOptions checks that options which change only how values are built
+// or errors are reported decode exactly what Unmarshal does, and that the
+// limits can only turn a value into an error.
+func FuzzOptions(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc)
+	}
+	for _, doc := range malformed {
+		f.Add([]byte(doc))
+	}
+	exact := []DecodeOptions{
+		{InternKeys: true, Batch: true},
+		{PreserveKeyOrder: true, Columns: ColumnUTF16},
+		{Columns: ColumnBytes},
+	}
+	limited := []DecodeOptions{
+		SafeOptions(),
+		{MaxLineBytes: 16, MaxDepth: 2, MaxInlineDepth: 1, MaxItems: 2, MaxKeyBytes: 2, MaxStringBytes: 2, MaxBlockBytes: 2, MemoryBudget: 256},
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, wantErr := Unmarshal(data)
+		for i, opts := range append(exact, limited...) {
+			got, err := UnmarshalFileWithOptions(data, "fuzz.yay", opts)
+			checkParseError(t, data, err)
+			switch {
+			case err == nil && wantErr != nil:
+				t.Fatalf("%+v accepts %q: %#v, which Unmarshal rejects: %v", opts, data, got, wantErr)
+			case err != nil && wantErr == nil && i < len(exact):
+				t.Fatalf("%+v rejects %q, which Unmarshal accepts: %v", opts, data, err)
+			case err == nil && !Equal(got, want):
+				t.Fatalf("%+v decodes %q as %#v, not %#v", opts, data, got, want)
+			}
+		}
+		var v any
+		err := NewDecoderWithOptions(strings.NewReader(string(data)), SafeOptions()).Decode(&v)
+		checkParseError(t, data, err)
+		_, err = Version(data)
+		checkParseError(t, data, err)
+	})
+}
+
+func FuzzParseAST(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc)
+	}
+	for _, doc := range malformed {
+		f.Add([]byte(doc))
+	}
+	for _, doc := range unplaced {
+		f.Add([]byte(doc))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, wantErr := Unmarshal(data)
+		root, err := ParseAST(data)
+		checkParseError(t, data, err)
+		if wantErr == nil && ErrorCode(err) == "unplaced-value" {
+			// Some documents parse more loosely than their text can be
+			// walked, but once formatted, every one can be.
+			out, err := Marshal(want)
+			if err != nil {
+				return
+			}
+			if _, err := ParseAST(out); err != nil {
+				t.Fatalf("ParseAST(%q), %q formatted: %v", out, data, err)
+			}
+			return
+		}
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("ParseAST(%q) error %v, Unmarshal error %v", data, err, wantErr)
+		}
+		if err != nil {
+			return
+		}
+		checkSpans(t, data, root)
+		if got := nodeValue(root); !Equal(got, want) {
+			t.Fatalf("ParseAST(%q) has the value %#v, not %#v", data, got, want)
+		}
+	})
+}
+
+func FuzzSortKeys(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc)
+	}
+	for _, doc := range malformed {
+		f.Add([]byte(doc))
+	}
+	for _, doc := range unplaced {
+		f.Add([]byte(doc))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := SortKeys(data, SortOptions{})
+		checkParseError(t, data, err)
+		if err != nil {
+			return
+		}
+		want, _ := Unmarshal(data)
+		if got, err := Unmarshal(out); err != nil || !Equal(got, want) {
+			t.Fatalf("SortKeys(%q) = %q, which has the value %#v (%v), not %#v", data, out, got, err, want)
+		}
+	})
+}
+
+func FuzzStripComments(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc)
+	}
+	for _, doc := range malformed {
+		f.Add([]byte(doc))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := StripComments(data)
+		checkParseError(t, data, err)
+		want, invalid := Unmarshal(data)
+		if invalid != nil {
+			return
+		}
+		if err != nil {
+			// Some documents, such as `""#"`, which the parsers read as
+			// the string `"#`, have no comment that can be stripped.
+			if strings.HasPrefix(err.Error(), "Cannot strip") {
+				return
+			}
+			t.Fatalf("StripComments(%q) of a valid document: %v", data, err)
+		}
+		if got, err := Unmarshal(out); err != nil || !Equal(got, want) {
+			t.Fatalf("StripComments(%q) = %q, which has the value %#v (%v), not %#v", data, out, got, err, want)
+		}
+	})
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc)
+	}
+	for _, doc := range malformed {
+		f.Add([]byte(doc))
+	}
+	for _, doc := range unplaced {
+		f.Add([]byte(doc))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := Format(data)
+		checkParseError(t, data, err)
+		if err != nil {
+			return
+		}
+		want, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Format(%q) formatted a document Unmarshal rejects: %v", data, err)
+		}
+		got, err := Unmarshal(out)
+		if err != nil || !Equal(got, want) {
+			t.Fatalf("Format(%q) = %q, which has the value %#v (%v), not %#v", data, out, got, err, want)
+		}
+	})
+}
+
+// unplaced holds documents that parse, but whose values ParseAST once
+// failed to find in the text, or still cannot, as with a key whose value
+// is indented less than it.
+var unplaced = []string{
+	"  - 42\n  - \"hello\"\n",
+	"data: > # raw bytes\n'  b0b5 c0ff\n",
+	" - - [42, 42]\n",
+	" - - {a: 42, b: \"hello\"}\n",
+	"a: 1\n# c\na: 2\n",
+	"a: 1\nb:\n  c: 2\na: 3 # c\n",
+	"{a: 1, b: [2], a: {c: 3}}\n",
+	"a:\n- 1\n- 2\nb: 3\n",
+	"a: 1\nb\n",
+	": \"\"\n0\n: ''",
+	"- 0:\n0:",
+}
+
+// checkSpans asserts that the span of n, and of each node within it, lies
+// within data, the document it was parsed from.
+func checkSpans(t *testing.T, data []byte, n Node) {
+	span := n.Extent()
+	if span.Start.Offset < 0 || span.Start.Offset > span.End.Offset || span.End.Offset > len(data) {
+		t.Fatalf("ParseAST(%q) has a node %T spanning %d to %d", data, n, span.Start.Offset, span.End.Offset)
+	}
+	switch n := n.(type) {
+	case *ObjectNode:
+		for _, p := range n.Properties {
+			checkSpans(t, data, p.Value)
+		}
+	case *ArrayNode:
+		for _, item := range n.Items {
+			checkSpans(t, data, item)
+		}
+	}
+}
+
+func FuzzMarshal(f *testing.F) {
+	for _, doc := range corpus(f) {
+		f.Add(doc, "key", uint8(0))
+	}
+	for _, s := range []string{"", "a b", "\"'", "`", "line\n", "two\nlines\n", "# not a comment\n", "\t", " lead", "trail ", "\x00", "\xff", "é"} {
+		f.Add([]byte("a: 1\n"), s, uint8(1))
+	}
+	f.Fuzz(func(t *testing.T, data []byte, s string, mode uint8) {
+		// Documents are UTF-8, so a string that is not is written with
+		// replacement characters and does not survive.
+		if !utf8.ValidString(s) || !utf8.Valid(data) {
+			return
+		}
+		v, err := Unmarshal(data)
+		if err != nil {
+			v = string(data)
+		}
+		// The string goes both in a key and in a value, and the mode
+		// chooses among the options that must keep every value as it is.
+		v = map[string]any{s: []any{s, v}, "v": v}
+		opts := EncodeOptions{
+			InlineArrayMax:  int(mode>>1&3) - 1,
+			InlineObjectMax: int(mode>>3&3) - 1,
+		}
+		if mode&1 != 0 {
+			opts.BlockStrings = BlockStringsExact
+		}
+		out, err := MarshalWithOptions(v, opts)
+		checkParseError(t, data, err)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "Cannot encode key") || strings.HasSuffix(err.Error(), "in an inline collection") {
+				return
+			}
+			t.Fatalf("MarshalWithOptions(%#v, %+v): %v", v, opts, err)
+		}
+		got, err := Unmarshal(out)
+		if err != nil || !Equal(got, v) {
+			t.Fatalf("MarshalWithOptions(%#v, %+v) = %q, which has the value %#v (%v)", v, opts, out, got, err)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package yay
+
+import (
+	"errors"
+	"time"
+)
+
+// ============================================================================
+// Instrumentation
+// ============================================================================
+//
+// A service that loads configuration at run time may want to watch how
+// that goes: how much it parses, how long parsing takes, and how often and
+// why documents are rejected. With DecodeOptions.Recorder set, every
+// document decoded with those options is measured and the measurement
+// handed to the Recorder. Without one, decoding is not measured at all.
+
+// Recorder receives the measurements of documents as they are decoded.
+// RecordDecode may be called from several goroutines at once, and should
+// return quickly, since it is called before decoding returns.
+type Recorder interface {
+	RecordDecode(DecodeStats)
+}
+
+// RecorderFunc adapts a function to a Recorder.
+type RecorderFunc func(DecodeStats)
+
+// RecordDecode calls f(s).
+func (f RecorderFunc) RecordDecode(s DecodeStats) {
+	f(s)
+}
+
+// DecodeStats measures the decoding of one document.
+type DecodeStats struct {
+	Filename string        // The filename of the document, if any
+	Bytes    int           // The length of the document
+	Duration time.Duration // How long decoding took
+	Err      error         // Why decoding failed, if it did
+
+	// Code classifies Err so that failures can be counted by kind: the
+	// code ErrorCode gives for errors in the document, or else CodeType,
+	// CodeInternal, or CodeInvalid. It is "" if decoding succeeded.
+	Code string
+}
+
+// The codes of DecodeStats for errors that have no code of their own.
+const (
+	CodeType     = "type"     // A *DecodeError
+	CodeInternal = "internal" // A bug in the parser
+	CodeInvalid  = "invalid"  // Any other error
+)
+
+// errorCode returns the code that classifies err.
+func errorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	if code := ErrorCode(err); code != "" {
+		return code
+	}
+	switch {
+	case isDecodeError(err):
+		return CodeType
+	case errors.Is(err, errInternal):
+		return CodeInternal
+	}
+	return CodeInvalid
+}
+
+// record hands r the measurement of a document of n bytes, named
+// filename, whose decoding began at start and ended with err.
+func record(r Recorder, filename string, n int, start time.Time, err error) {
+	r.RecordDecode(DecodeStats{
+		Filename: filename,
+		Bytes:    n,
+		Duration: time.Since(start),
+		Err:      err,
+		Code:     errorCode(err),
+	})
+}
@@ -0,0 +1,163 @@
+//go:build go1.23 && !yay_tiny
+
+package yay
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// ============================================================================
+// Iterators
+// ============================================================================
+//
+// Marshal writes an iter.Seq as an array of the values it yields, and an
+// iter.Seq2 with string keys as an object of the pairs it yields, in the
+// order it yields them, so that a pipeline producing values lazily need not
+// collect them into a slice or map just to write them out. An Encoder
+// given an iterator as the whole document goes further and writes each
+// item to its stream as it is yielded, holding back only the first few,
+// until it knows whether they fit on one line. Either way the output is
+// that of the equivalent slice or OrderedMap. An iterator is run once.
+
+// collectIter returns the values an iter.Seq v yields as an array, or the
+// pairs an iter.Seq2 with string keys yields as an *OrderedMap. It reports
+// false if v is neither.
+func collectIter(v reflect.Value, p preparer) (any, bool, error) {
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, false, nil
+	}
+	t := v.Type()
+	switch {
+	case t.CanSeq():
+		items := []any{}
+		for item := range v.Seq() {
+			x, _, err := p.value(item.Interface())
+			if err != nil {
+				return nil, true, atPath(err, "["+strconv.Itoa(len(items))+"]")
+			}
+			items = append(items, x)
+		}
+		return items, true, nil
+	case t.CanSeq2() && t.In(0).In(0).Kind() == reflect.String:
+		obj := &OrderedMap{}
+		seen := make(map[string]bool)
+		for k, item := range v.Seq2() {
+			x, _, err := p.value(item.Interface())
+			if err != nil {
+				return nil, true, atPath(err, keyPathElement(k.String()))
+			}
+			if seen[k.String()] {
+				return nil, true, fmt.Errorf("Iterator yields the key %q twice", k.String())
+			}
+			seen[k.String()] = true
+			if !p.opts.OmitNull || !isNull(x) {
+				obj.members = append(obj.members, Member{Key: k.String(), Value: x})
+			}
+		}
+		return obj, true, nil
+	}
+	return nil, false, nil
+}
+
+// streamIter writes v to the stream item by item if v is an iterator, and
+// reports whether it is.
+func (e *Encoder) streamIter(v any) (bool, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return false, nil
+	}
+	p := preparer{opts: e.opts}
+	t := rv.Type()
+	switch {
+	case t.CanSeq():
+		var pending []any
+		block := false
+		i := 0
+		for item := range rv.Seq() {
+			x, _, err := p.value(item.Interface())
+			if err != nil {
+				return true, atPath(err, "["+strconv.Itoa(i)+"]")
+			}
+			pending = append(pending, e.redact(x, "["+strconv.Itoa(i)+"]"))
+			i++
+			if !block && e.encoder().canInlineArray(pending) {
+				continue
+			}
+			block = true
+			if err := e.writeBlock(func(enc *encoder) error {
+				return enc.encodeBlockArray(pending, 0, false)
+			}); err != nil {
+				return true, err
+			}
+			pending = pending[:0]
+		}
+		if block {
+			return true, nil
+		}
+		return true, e.writeBlock(func(enc *encoder) error {
+			return enc.encodeValue(append([]any{}, pending...), 0, false)
+		})
+	case t.CanSeq2() && t.In(0).In(0).Kind() == reflect.String:
+		var pending []Member
+		seen := make(map[string]bool)
+		block := false
+		for k, item := range rv.Seq2() {
+			x, _, err := p.value(item.Interface())
+			if err != nil {
+				return true, atPath(err, keyPathElement(k.String()))
+			}
+			if seen[k.String()] {
+				return true, fmt.Errorf("Iterator yields the key %q twice", k.String())
+			}
+			seen[k.String()] = true
+			if e.opts.OmitNull && isNull(x) {
+				continue
+			}
+			pending = append(pending, Member{Key: k.String(), Value: e.redact(x, keyPathElement(k.String()))})
+			if !block && e.encoder().canInlineMembers(pending) {
+				continue
+			}
+			block = true
+			if err := e.writeBlock(func(enc *encoder) error {
+				return enc.encodeBlockMembers(pending, 0, false)
+			}); err != nil {
+				return true, err
+			}
+			pending = pending[:0]
+		}
+		if block {
+			return true, nil
+		}
+		return true, e.writeBlock(func(enc *encoder) error {
+			return enc.encodeValue(&OrderedMap{members: pending}, 0, false)
+		})
+	}
+	return false, nil
+}
+
+// redact returns x, the item at path of a streamed iterator, redacted as
+// the options of the stream ask.
+func (e *Encoder) redact(x any, path string) any {
+	if e.opts.Redact != nil {
+		x, _ = redact(x, path, e.opts.Redact)
+	}
+	return x
+}
+
+// encoder returns an empty encoder with the options of the stream.
+func (e *Encoder) encoder() *encoder {
+	return newEncoder(e.opts, 0)
+}
+
+// writeBlock writes the lines that encode writes to the stream.
+func (e *Encoder) writeBlock(encode func(enc *encoder) error) error {
+	enc := e.encoder()
+	if err := encode(enc); err != nil {
+		return err
+	}
+	enc.buf = append(enc.buf, '\n')
+	_, err := e.w.Write(enc.buf)
+	return err
+}
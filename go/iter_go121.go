@@ -0,0 +1,15 @@
+//go:build !go1.23 && !yay_tiny
+
+package yay
+
+import "reflect"
+
+// collectIter reports false, there being no iterators before Go 1.23.
+func collectIter(v reflect.Value, p preparer) (any, bool, error) {
+	return nil, false, nil
+}
+
+// streamIter reports false, there being no iterators before Go 1.23.
+func (e *Encoder) streamIter(v any) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,113 @@
+//go:build go1.23 && !yay_tiny
+
+package yay
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// countTo yields 1 through n.
+func countTo(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 1; i <= n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestMarshalIterators(t *testing.T) {
+	pairs := func(yield func(string, any) bool) {
+		_ = yield("b", 1) && yield("a", countTo(2))
+	}
+	doc := map[string]any{
+		"few":   countTo(3),
+		"many":  countTo(7),
+		"none":  countTo(0),
+		"pairs": iter.Seq2[string, any](pairs),
+		"keys":  slices.Values([]string{"x"}),
+	}
+	got, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "few: [1, 2, 3]\nkeys: [\"x\"]\nmany:\n  - 1\n  - 2\n  - 3\n  - 4\n  - 5\n  - 6\n  - 7\nnone: []\npairs:\n  b: 1\n  a: [1, 2]\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, v := range []any{countTo(3), countTo(7), countTo(0), maps.All(map[string]int{"a": 1}), iter.Seq2[string, any](pairs)} {
+		want, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(v); err != nil || buf.String() != string(want) {
+			t.Errorf("Encoder: got %q, %v, want %q", buf.String(), err, want)
+		}
+	}
+
+	withNull := iter.Seq2[string, any](func(yield func(string, any) bool) {
+		_ = yield("a", nil) && yield("b", 2)
+	})
+	opts := EncodeOptions{OmitNull: true}
+	var buf bytes.Buffer
+	if got, err := MarshalWithOptions(withNull, opts); err != nil || string(got) != "{b: 2}\n" {
+		t.Errorf("OmitNull: got %q, %v", got, err)
+	}
+	if err := NewEncoderWithOptions(&buf, opts).Encode(withNull); err != nil || buf.String() != "{b: 2}\n" {
+		t.Errorf("Encoder with OmitNull: got %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	redactB := EncodeOptions{Redact: func(path string) bool { return path == ".b" || path == "[1]" }}
+	if err := NewEncoderWithOptions(&buf, redactB).Encode(withNull); err != nil || buf.String() != "{a: null, b: \"[redacted]\"}\n" {
+		t.Errorf("Encoder with Redact: got %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := NewEncoderWithOptions(&buf, redactB).Encode(countTo(3)); err != nil || buf.String() != "[1, \"[redacted]\", 3]\n" {
+		t.Errorf("Encoder with Redact: got %q, %v", buf.String(), err)
+	}
+
+	twice := func(yield func(string, int) bool) {
+		_ = yield("a", 1) && yield("a", 2)
+	}
+	if _, err := Marshal(iter.Seq2[string, int](twice)); err == nil || err.Error() != `Iterator yields the key "a" twice` {
+		t.Errorf("duplicate key: got %v", err)
+	}
+	withChan := slices.Values([]any{1, make(chan int)})
+	if err := NewEncoder(&buf).Encode(withChan); err == nil || err.Error() != "Cannot encode chan int at .[1]" {
+		t.Errorf("Encoder error path: got %v", err)
+	}
+	if _, err := Marshal(maps.All(map[int]int{1: 1})); err == nil {
+		t.Errorf("integer keys: got no error")
+	}
+}
+
+func TestEncoderStreamsIterators(t *testing.T) {
+	var buf bytes.Buffer
+	bad := errors.New("bad")
+	var codecs Codecs
+	AddCodec(&codecs, func(p point) (any, error) { return nil, bad }, nil)
+	items := func(yield func(any) bool) {
+		for i := 0; i < 6; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+		if buf.String() != "- 0\n- 1\n- 2\n- 3\n- 4\n- 5\n" {
+			t.Errorf("before the last item: got %q", buf.String())
+		}
+		yield(point{})
+	}
+	err := NewEncoderWithOptions(&buf, EncodeOptions{Codecs: &codecs}).Encode(iter.Seq[any](items))
+	if !errors.Is(err, bad) {
+		t.Errorf("got %v", err)
+	}
+}
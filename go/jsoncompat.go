@@ -0,0 +1,56 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// ============================================================================
+// JSON Compatibility
+// ============================================================================
+//
+// With DecodeOptions.JSONCompatible, the parsed value is rewritten into the
+// shapes encoding/json produces before it is returned.
+
+// jsonCompatible returns v, a parsed value, as opts.JSONCompatible asks.
+func jsonCompatible(v any, opts DecodeOptions) (any, error) {
+	return toJSONValue(v, opts.UseNumber), nil
+}
+
+// toJSONValue returns v with its numbers and byte arrays in the shapes
+// json.Unmarshal produces, as json.Number if useNumber. Arrays and objects
+// are rewritten in place.
+func toJSONValue(v any, useNumber bool) any {
+	switch x := v.(type) {
+	case *big.Int:
+		if useNumber {
+			return json.Number(x.String())
+		}
+		f, _ := new(big.Float).SetInt(x).Float64()
+		return f
+	case float64:
+		if useNumber && !math.IsNaN(x) && !math.IsInf(x, 0) {
+			return json.Number(strconv.FormatFloat(x, 'g', -1, 64))
+		}
+	case []byte:
+		return base64.StdEncoding.EncodeToString(x)
+	case []any:
+		for i, item := range x {
+			x[i] = toJSONValue(item, useNumber)
+		}
+	case map[string]any:
+		for k, item := range x {
+			x[k] = toJSONValue(item, useNumber)
+		}
+	case *OrderedMap:
+		for i := range x.members {
+			x.members[i].Value = toJSONValue(x.members[i].Value, useNumber)
+		}
+	}
+	return v
+}
@@ -0,0 +1,265 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// JSON Conversion
+// ============================================================================
+//
+// ToJSON and FromJSON convert documents directly, keeping the order of
+// object keys, which decoding to a map and encoding with encoding/json
+// would lose. JSON lacks three of YAY's kinds of value: integers, which a
+// JSON number can spell but which many readers take as float64, byte
+// arrays, and NaN and the infinities. Integers are written with all their
+// digits, or as strings where a float64 would round them, byte arrays as
+// strings, and NaN and the infinities as null. Going the other way, a
+// number written without a fraction or exponent is an integer.
+
+// JSONOptions configures ToJSON and FromJSON.
+type JSONOptions struct {
+	// IntegerStrings writes the integers that a float64 cannot hold
+	// exactly, beyond ±2^53, as JSON strings of their digits, for readers
+	// such as JavaScript that take every number as a float64. FromJSON
+	// then reads such strings, of digits beyond that range, as integers.
+	// Other integers are numbers either way.
+	IntegerStrings bool
+
+	// Bytes selects the strings that ToJSON writes for byte arrays. JSON
+	// has no byte arrays, so FromJSON reads every string as a string.
+	Bytes BytesEncoding
+
+	// Filename, if set, is given in the positions of errors.
+	Filename string
+}
+
+// BytesEncoding selects how ToJSON writes byte arrays.
+type BytesEncoding int
+
+const (
+	// BytesBase64 writes standard base64, as encoding/json writes a
+	// []byte.
+	BytesBase64 BytesEncoding = iota
+	// BytesHex writes lowercase hex, as YAY writes byte arrays.
+	BytesHex
+)
+
+// maxSafeInteger is the largest integer beyond which a float64 cannot
+// hold every integer exactly, 2^53.
+var maxSafeInteger = big.NewInt(1 << 53)
+
+// ToJSON returns the JSON encoding of a YAY document, written compactly,
+// with object keys in the order the document gives them.
+func ToJSON(yayData []byte) ([]byte, error) {
+	return ToJSONWithOptions(yayData, JSONOptions{})
+}
+
+// ToJSONWithOptions returns the JSON encoding of a YAY document according
+// to opts.
+func ToJSONWithOptions(yayData []byte, opts JSONOptions) ([]byte, error) {
+	v, err := UnmarshalWithOptions(yayData, DecodeOptions{PreserveKeyOrder: true, Filename: opts.Filename})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(yayData))
+	writeJSON(&buf, v, opts)
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes v, a value Unmarshal produces with PreserveKeyOrder,
+// as JSON.
+func writeJSON(buf *bytes.Buffer, v any, opts JSONOptions) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case *big.Int:
+		if opts.IntegerStrings && new(big.Int).Abs(v).Cmp(maxSafeInteger) > 0 {
+			buf.WriteByte('"')
+			buf.WriteString(v.String())
+			buf.WriteByte('"')
+		} else {
+			buf.WriteString(v.String())
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			buf.WriteString("null")
+			break
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		buf.WriteString(s)
+		if !strings.ContainsAny(s, ".e") {
+			buf.WriteString(".0") // To read back as a float
+		}
+	case string:
+		writeJSONString(buf, v)
+	case []byte:
+		if opts.Bytes == BytesHex {
+			writeJSONString(buf, hex.EncodeToString(v))
+		} else {
+			writeJSONString(buf, base64.StdEncoding.EncodeToString(v))
+		}
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, item, opts)
+		}
+		buf.WriteByte(']')
+	case *OrderedMap:
+		buf.WriteByte('{')
+		for i, m := range v.members {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, m.Key)
+			buf.WriteByte(':')
+			writeJSON(buf, m.Value, opts)
+		}
+		buf.WriteByte('}')
+	}
+}
+
+// writeJSONString writes s as a JSON string, escaping only the quote, the
+// backslash, and control characters, as JSON requires.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(buf, `\u%04x`, r)
+		default:
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+}
+
+// FromJSON returns the YAY encoding of a JSON document, as Marshal writes
+// it but with object keys in the order the document gives them.
+func FromJSON(jsonData []byte) ([]byte, error) {
+	return FromJSONWithOptions(jsonData, JSONOptions{})
+}
+
+// FromJSONWithOptions returns the YAY encoding of a JSON document according
+// to opts.
+func FromJSONWithOptions(jsonData []byte, opts JSONOptions) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	v, err := readJSON(dec, jsonData, opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("Unexpected content after the JSON value")
+	}
+	return Marshal(v)
+}
+
+// readJSON reads the next JSON value of data from dec, with objects as
+// *OrderedMap.
+func readJSON(dec *json.Decoder, data []byte, opts JSONOptions) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			items := []any{}
+			for dec.More() {
+				item, err := readJSON(dec, data, opts)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			_, err := dec.Token()
+			return items, err
+		}
+		obj := NewOrderedMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readJSON(dec, data, opts)
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Number:
+		if strings.ContainsAny(string(tok), ".eE") {
+			f, err := tok.Float64()
+			if err != nil {
+				return nil, jsonErrorf(data, int(dec.InputOffset())-len(tok), opts, "Float overflow")
+			}
+			return f, nil
+		}
+		n, _ := new(big.Int).SetString(string(tok), 10)
+		return n, nil
+	case string:
+		if opts.IntegerStrings {
+			if n, ok := new(big.Int).SetString(tok, 10); ok && isDecimal(tok) && new(big.Int).Abs(n).Cmp(maxSafeInteger) > 0 {
+				return n, nil
+			}
+		}
+	}
+	return tok, nil
+}
+
+// jsonErrorf returns the error for the message of English format, at
+// offset off of the JSON document data.
+func jsonErrorf(data []byte, off int, opts JSONOptions, format string, args ...any) error {
+	err := Catalog(nil).errorf(format, args...)
+	line, col := positionAt(string(data), off, ColumnCodePoints)
+	err.Position = Position{Filename: opts.Filename, Line: line + 1, Column: col + 1, Offset: off}
+	return err
+}
+
+// isDecimal reports whether s is an integer as JSON writes numbers: an
+// optional minus sign and digits, without a leading zero.
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || s[0] == '0' && len(s) > 1 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,251 @@
+package yay
+
+import (
+	"errors"
+	"strings"
+)
+
+// ============================================================================
+// Limits
+// ============================================================================
+//
+// The limits in DecodeOptions protect services that decode documents from
+// untrusted sources. Each is off when zero. SafeOptions bundles them with
+// conservative values. The parser consults them through the checks below,
+// which cost a comparison when a limit is off.
+//
+// Nesting is limited even when MaxDepth is off, since the parser recurses
+// once per level of indentation, as do the functions that walk the value
+// or its syntax tree, and a deep enough document would otherwise exhaust
+// the goroutine stack and crash the process.
+
+// ErrTooDeep is reported, wrapped with the position of the offending
+// array or object, for documents that nest more deeply than MaxDepth or
+// the default limit allow. Test for it with errors.Is.
+var ErrTooDeep = errors.New("Nesting too deep")
+
+// ErrTooLarge is reported, wrapped with the limit, for documents longer
+// than MaxInputBytes.
+var ErrTooLarge = errors.New("Document too large")
+
+// ErrMemoryBudget is reported, wrapped with the budget and the position
+// of the value that exceeds it, for documents whose values would occupy
+// more memory than MemoryBudget allows.
+var ErrMemoryBudget = errors.New("Memory budget exceeded")
+
+// defaultMaxDepth limits nesting when MaxDepth is off. Inline values and
+// lists begun on one line nest without recursion, but nesting by
+// indentation recurses, about a kilobyte of stack a level, as do ParseAST,
+// Format, SortKeys, and the encoder, so at this depth each uses some
+// megabytes of stack, as TestDeepNestingStack checks.
+const defaultMaxDepth = 1 << 12
+
+// SafeOptions returns options suitable for decoding untrusted input, such
+// as documents uploaded by users. Every limit is set conservatively for
+// configuration-sized documents:
+//
+//   - MaxInputBytes: 1 MiB
+//   - MaxLineBytes: 64 KiB
+//   - MaxDepth: 64
+//   - MaxInlineDepth: 32
+//   - MaxItems: 10000
+//   - MaxKeyBytes: 1024
+//   - MaxStringBytes: 64 KiB
+//   - MaxBlockBytes: 256 KiB
+//   - MemoryBudget: 32 MiB
+//
+// The result is a fresh value, so callers may raise or lower individual
+// limits before use:
+//
+//	opts := yay.SafeOptions()
+//	opts.MaxInputBytes = 16 << 20
+//	v, err := yay.UnmarshalWithOptions(data, opts)
+func SafeOptions() DecodeOptions {
+	return DecodeOptions{
+		MaxInputBytes:  1 << 20,
+		MaxLineBytes:   64 << 10,
+		MaxDepth:       64,
+		MaxInlineDepth: 32,
+		MaxItems:       10000,
+		MaxKeyBytes:    1024,
+		MaxStringBytes: 64 << 10,
+		MaxBlockBytes:  256 << 10,
+		MemoryBudget:   32 << 20,
+	}
+}
+
+// limits holds the limits of DecodeOptions for a parse.
+type limits struct {
+	line    int
+	depth   int // Arrays and objects enclosing a value
+	inline  int // Inline arrays and objects enclosing a value
+	items   int
+	key     int
+	str     int
+	block   int // Limit on block values, all told
+	blocks  int // Bytes of block values decoded so far
+	budget  int // Memory budget
+	spent   int // Memory charged against the budget so far
+	current int // Depth of the collection being parsed
+}
+
+// Estimated sizes of the parts of decoded values, in bytes, charged
+// against the memory budget. A value stored in an array or object costs a
+// slot in addition to whatever the value itself occupies.
+const (
+	sizeSlot       = 16 // An interface value
+	sizeCollection = 48 // A slice or map header and its allocation
+	sizeEntry      = 32 // Map overhead for a property, beyond key and value
+	sizeString     = 16 // A string header
+	sizeBytes      = 24 // A slice header
+	sizeInt        = 40 // A *big.Int of one word
+	sizeFloat      = 8  // A boxed float64
+)
+
+// charge charges n bytes for the value at offset against the memory
+// budget, failing once the budget is exceeded.
+func (ctx *parseContext) charge(n, offset int) error {
+	if ctx == nil || ctx.limits.budget == 0 {
+		return nil
+	}
+	ctx.limits.spent += n
+	if ctx.limits.spent <= ctx.limits.budget {
+		return nil
+	}
+	return ctx.errorf(offset, "%w (limit %d bytes)", ErrMemoryBudget, ctx.limits.budget)
+}
+
+// chargeInt charges for an integer of the given decimal digits.
+func (ctx *parseContext) chargeInt(digits string, offset int) error {
+	return ctx.charge(sizeInt+len(digits)/19*8, offset)
+}
+
+// checkInput reports whether a document of n bytes is within opts.
+func checkInput(n int, opts DecodeOptions) error {
+	if opts.MaxInputBytes > 0 && n > opts.MaxInputBytes {
+		return opts.Catalog.errorf("%w (limit %d bytes)", ErrTooLarge, opts.MaxInputBytes)
+	}
+	return nil
+}
+
+// checkLineLength reports whether every line of source is within the line
+// length limit, pointing an error at the start of the first that is not.
+func checkLineLength(source string, ctx *parseContext) error {
+	limit := ctx.limits.line
+	if limit <= 0 || len(source) <= limit {
+		return nil
+	}
+	for start := 0; start < len(source); {
+		n := strings.IndexByte(source[start:], '\n')
+		if n < 0 {
+			n = len(source) - start
+		}
+		if n > limit {
+			return ctx.errorf(start, "Line exceeds the limit of %d bytes", limit)
+		}
+		start += n + 1
+	}
+	return nil
+}
+
+// enter notes that the parse has entered an array or object beginning at
+// offset, failing if that nests too deeply. Each successful enter is
+// paired with a leave.
+func (ctx *parseContext) enter(offset int) error {
+	if ctx == nil {
+		return nil
+	}
+	if err := ctx.charge(sizeCollection, offset); err != nil {
+		return err
+	}
+	ctx.limits.current++
+	limit := ctx.limits.depth
+	if limit <= 0 {
+		limit = defaultMaxDepth
+	}
+	if ctx.limits.current > limit {
+		ctx.limits.current--
+		return ctx.errorf(offset, "%w (limit %d)", ErrTooDeep, limit)
+	}
+	return nil
+}
+
+// leave notes that the parse has finished an array or object.
+func (ctx *parseContext) leave() {
+	if ctx != nil {
+		ctx.limits.current--
+	}
+}
+
+// depth returns the current nesting depth, for restoring with leaveTo
+// when a parse that has entered several levels at once fails.
+func (ctx *parseContext) depth() int {
+	if ctx == nil {
+		return 0
+	}
+	return ctx.limits.current
+}
+
+// leaveTo leaves every level entered since the nesting depth was depth.
+func (ctx *parseContext) leaveTo(depth int) {
+	if ctx != nil {
+		ctx.limits.current = depth
+	}
+}
+
+// checkInlineDepth reports whether an inline array or object beginning at
+// offset may nest at the given depth among inline collections.
+func (ctx *parseContext) checkInlineDepth(depth, offset int) error {
+	if ctx == nil || ctx.limits.inline == 0 || depth <= ctx.limits.inline {
+		return nil
+	}
+	return ctx.errorf(offset, "%w in inline value (limit %d)", ErrTooDeep, ctx.limits.inline)
+}
+
+// checkItems reports whether a collection that already has n items may
+// take the one beginning at offset.
+func (ctx *parseContext) checkItems(n, offset int) error {
+	if ctx == nil {
+		return nil
+	}
+	if ctx.limits.items > 0 && n >= ctx.limits.items {
+		return ctx.errorf(offset, "Collection exceeds the limit of %d items", ctx.limits.items)
+	}
+	return ctx.charge(sizeSlot, offset)
+}
+
+// propertyKey checks the length of key k, found at offset, and interns it.
+func (ctx *parseContext) propertyKey(k string, offset int) (string, error) {
+	if ctx != nil && ctx.limits.key > 0 && len(k) > ctx.limits.key {
+		return "", ctx.errorf(offset, "Key exceeds the limit of %d bytes", ctx.limits.key)
+	}
+	if err := ctx.charge(sizeEntry+sizeString+len(k), offset); err != nil {
+		return "", err
+	}
+	return ctx.internKey(k), nil
+}
+
+// countBlock counts n bytes decoded from the block string or block byte
+// array at offset, failing if block values exceed their limit.
+func (ctx *parseContext) countBlock(n, offset int) error {
+	if ctx == nil || ctx.limits.block == 0 {
+		return nil
+	}
+	ctx.limits.blocks += n
+	if ctx.limits.blocks <= ctx.limits.block {
+		return nil
+	}
+	return ctx.errorf(offset, "Block values exceed the limit of %d bytes", ctx.limits.block)
+}
+
+// checkString reports whether string s, found at offset, is within the
+// string length limit and the memory budget.
+func (ctx *parseContext) checkString(s string, offset int) error {
+	if ctx == nil {
+		return nil
+	}
+	if ctx.limits.str > 0 && len(s) > ctx.limits.str {
+		return ctx.errorf(offset, "String exceeds the limit of %d bytes", ctx.limits.str)
+	}
+	return ctx.charge(sizeString+len(s), offset)
+}
@@ -0,0 +1,117 @@
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Literals
+// ============================================================================
+//
+// ParseNumber, ParseBytesLiteral, and ParseQuotedString parse one scalar
+// as it would be written as the whole of a document, with the parser's own
+// code, so that command lines and little languages that borrow YAY's
+// notation for numbers, bytes, and strings read them exactly as documents
+// do. A literal that does not parse is reported with a LiteralError, which
+// gives where in the literal the problem is, for the caller to add to
+// where the literal began in its own input.
+
+// LiteralError reports a literal that does not parse.
+type LiteralError struct {
+	Offset int   // The byte offset of the problem in the literal
+	Err    error // The problem, whose code ErrorCode gives
+}
+
+func (e *LiteralError) Error() string {
+	return fmt.Sprintf("%v (byte offset %d)", e.Err, e.Offset)
+}
+
+func (e *LiteralError) Unwrap() error {
+	return e.Err
+}
+
+// ParseNumber parses s as a YAY number: a *big.Int for an integer, such
+// as "42" or "1 000 000", and a float64 for a float, such as "0.5", "6e23",
+// "nan", "infinity", or "-infinity".
+//
+//	n, err := yay.ParseNumber("1 048 576") // big.NewInt(1048576)
+func ParseNumber(s string) (any, error) {
+	ctx, err := literalContext(s)
+	if err != nil {
+		return nil, err
+	}
+	switch s {
+	case "nan", "infinity", "-infinity":
+		v, _ := parseKeyword(s)
+		return v, nil
+	}
+	v, ok, err := parseNumberStrict(s, ctx, 0)
+	if err != nil {
+		return nil, literalError(err)
+	}
+	if !ok {
+		return nil, literalError(ctx.errorf(0, "Invalid number"))
+	}
+	return v, nil
+}
+
+// ParseBytesLiteral parses s as a YAY byte array in angle brackets, such
+// as "<cafe>" or "<ca fe>".
+func ParseBytesLiteral(s string) ([]byte, error) {
+	ctx, err := literalContext(s)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseAngleBytesStrict(s, ctx, 0)
+	if err != nil {
+		return nil, literalError(err)
+	}
+	return b, nil
+}
+
+// ParseQuotedString parses s as a YAY string in double quotes, with JSON's
+// escapes, or in single quotes, literally.
+//
+//	s, err := yay.ParseQuotedString(`"café"`) // "café"
+func ParseQuotedString(s string) (string, error) {
+	ctx, err := literalContext(s)
+	if err != nil {
+		return "", err
+	}
+	if len(s) < 2 || s[0] != '"' && s[0] != '\'' || s[len(s)-1] != s[0] {
+		return "", literalError(ctx.errorf(0, "Expected quoted string"))
+	}
+	str, err := parseQuotedString(s, ctx, 0)
+	if err != nil {
+		return "", literalError(err)
+	}
+	return str, nil
+}
+
+// literalContext returns the context for parsing the literal s, after
+// checking s for what the scanner would reject in a document.
+func literalContext(s string) (*parseContext, error) {
+	ctx := newParseContext(s, "", DecodeOptions{})
+	if err := validateCodePoints(s, ctx); err != nil {
+		return nil, literalError(err)
+	}
+	if strings.HasPrefix(s, " ") {
+		return nil, literalError(ctx.errorf(0, "Unexpected leading space"))
+	}
+	if strings.HasSuffix(s, " ") {
+		return nil, literalError(ctx.errorf(len(s)-1, "Unexpected trailing space"))
+	}
+	return ctx, nil
+}
+
+// literalError returns err, an error of the parser, as a LiteralError.
+func literalError(err error) error {
+	off := 0
+	var pe *ParseError
+	if errors.As(err, &pe) && pe.Line > 0 {
+		off = pe.Offset
+	}
+	return &LiteralError{Offset: off, Err: err}
+}
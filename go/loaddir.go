@@ -0,0 +1,95 @@
+package yay
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ============================================================================
+// Directory Trees
+// ============================================================================
+//
+// A configuration may be spread over a tree of files, conf.d style, one
+// file per component. LoadDir assembles such a tree into one document:
+// each file "name.yay" becomes the property "name" of the object for its
+// directory, and each directory the property of its parent named for it.
+//
+//	conf/
+//	  server.yay       ->  {server: ..., plugins: {auth: ..., cache: ...}}
+//	  plugins/
+//	    auth.yay
+//	    cache.yay
+
+// LoadDir reads the tree of .yay files under root in fsys and returns them
+// as one object, as described above. Files and directories whose names
+// begin with "." are skipped, as are files with other extensions.
+// Directories holding no .yay files are left out.
+//
+// Errors from a file carry its path, relative to fsys, as the filename.
+// A file and a directory that would give the same property are an error.
+func LoadDir(fsys fs.FS, root string) (any, error) {
+	return LoadDirWithOptions(fsys, root, DecodeOptions{})
+}
+
+// LoadDirWithOptions is like LoadDir but decodes each file according to
+// opts, whose Filename is ignored. With PreserveKeyOrder, the properties
+// for files and directories are in the order of their names.
+func LoadDirWithOptions(fsys fs.FS, root string, opts DecodeOptions) (any, error) {
+	ctx := &parseContext{ordered: opts.PreserveKeyOrder}
+	tree := ctx.newObject(0)
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || path.Ext(name) != ".yay" {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		v, err := unmarshal(data, name, opts)
+		if err != nil {
+			return err
+		}
+		rel := name
+		if root != "." {
+			rel = strings.TrimPrefix(name, root+"/")
+		}
+		return placeFile(ctx, tree, strings.Split(strings.TrimSuffix(rel, ".yay"), "/"), v, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// placeFile stores v, decoded from the file name, in tree at the property
+// path keys, adding objects for the directories along the way. A
+// directory is walked before the file of the same name with ".yay" added,
+// so a clash is always found at the file.
+func placeFile(ctx *parseContext, tree any, keys []string, v any, name string) error {
+	obj := tree
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := getProperty(obj, k)
+		if !ok {
+			next = ctx.newObject(0)
+			setProperty(obj, k, next)
+		}
+		obj = next
+	}
+	k := keys[len(keys)-1]
+	if _, ok := getProperty(obj, k); ok {
+		return fmt.Errorf("Both %s and the directory %s give the property %q", name, strings.TrimSuffix(name, ".yay"), k)
+	}
+	setProperty(obj, k, v)
+	return nil
+}
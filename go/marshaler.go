@@ -0,0 +1,142 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// ============================================================================
+// Marshalers
+// ============================================================================
+//
+// A type may give its own YAY representation by implementing Marshaler and
+// Unmarshaler, as the methods cmd/yaygen writes do, where a codec gives the
+// representation of a type from outside it. Marshal writes the document
+// that MarshalYAY returns in place of the value, indented to fit where the
+// value appears, and a Decoder gives UnmarshalYAY the document of the value
+// that is to be decoded into the type. A codec for the type, if any, takes
+// precedence, so that a program can override how another package's type
+// represents itself.
+//
+// Failing those, a type that implements encoding.TextMarshaler is written
+// as the string its MarshalText method returns, and one whose pointer
+// implements encoding.TextUnmarshaler is decoded from a string with its
+// UnmarshalText method, so that the many types of other packages that
+// have a text form need no codec.
+
+// Marshaler is implemented by types that encode themselves as YAY.
+// MarshalYAY returns a YAY document, such as Marshal returns, whose value
+// stands for the receiver.
+type Marshaler interface {
+	MarshalYAY() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves from YAY.
+// UnmarshalYAY is given a YAY document and stores its value in the
+// receiver. It must copy data if it keeps it.
+type Unmarshaler interface {
+	UnmarshalYAY(data []byte) error
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// methodReceiver returns v, or else a pointer to a copy of v, as whichever
+// implements the interface type i, as with the methods cmd/yaygen writes,
+// which belong to pointers. It returns nil if neither does, and reports
+// whether v is a nil pointer, which has the methods but cannot be given
+// to them.
+func methodReceiver(v reflect.Value, i reflect.Type) (recv any, isNil bool) {
+	if v.Type().Implements(i) {
+		return v.Interface(), v.Kind() == reflect.Pointer && v.IsNil()
+	}
+	if v.Kind() != reflect.Pointer && reflect.PointerTo(v.Type()).Implements(i) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface(), false
+	}
+	return nil, false
+}
+
+// marshalSelf returns the value of the document that v's MarshalYAY method
+// returns, and whether v has one. A nil pointer is encoded as null without
+// calling it.
+func marshalSelf(v reflect.Value) (any, bool, error) {
+	recv, isNil := methodReceiver(v, marshalerType)
+	if recv == nil {
+		return nil, false, nil
+	}
+	if isNil {
+		return nil, true, nil
+	}
+	data, err := recv.(Marshaler).MarshalYAY()
+	if err != nil {
+		return nil, true, &encodeError{typ: v.Type().String(), err: err}
+	}
+	x, err := UnmarshalWithOptions(data, DecodeOptions{PreserveKeyOrder: true})
+	if err != nil {
+		return nil, true, &encodeError{typ: v.Type().String(), err: fmt.Errorf("MarshalYAY returned an invalid document: %w", err)}
+	}
+	return x, true, nil
+}
+
+// marshalText returns the string that v's MarshalText method returns, and
+// whether v has one. A nil pointer is encoded as null without calling it.
+func marshalText(v reflect.Value) (any, bool, error) {
+	recv, isNil := methodReceiver(v, textMarshalerType)
+	if recv == nil {
+		return nil, false, nil
+	}
+	if isNil {
+		return nil, true, nil
+	}
+	text, err := recv.(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return nil, true, &encodeError{typ: v.Type().String(), err: err}
+	}
+	return string(text), true, nil
+}
+
+// unmarshalSelf stores value in target with the UnmarshalYAY method of
+// its pointer, encoding value as a document for it, and reports whether
+// target has the method.
+func unmarshalSelf(target reflect.Value, value any) (bool, error) {
+	if !target.CanAddr() {
+		return false, nil
+	}
+	u, ok := target.Addr().Interface().(Unmarshaler)
+	if !ok {
+		return false, nil
+	}
+	data, err := Marshal(value)
+	if err != nil {
+		return true, err
+	}
+	return true, u.UnmarshalYAY(data)
+}
+
+// unmarshalText stores value, which must be a string, in target with the
+// UnmarshalText method of its pointer, and reports whether target has the
+// method.
+func unmarshalText(target reflect.Value, value any) (bool, error) {
+	if !target.CanAddr() {
+		return false, nil
+	}
+	u, ok := target.Addr().Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		return false, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return true, fmt.Errorf("Cannot decode %s into %s", describeValue(value), target.Type())
+	}
+	if err := u.UnmarshalText([]byte(s)); err != nil {
+		return true, fmt.Errorf("Cannot decode %s: %w", target.Type(), err)
+	}
+	return true, nil
+}
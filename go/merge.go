@@ -0,0 +1,127 @@
+package yay
+
+import (
+	"errors"
+)
+
+// ============================================================================
+// Merging
+// ============================================================================
+//
+// Merge overlays one decoded document on another, as a deployment's
+// settings overlay a program's defaults. Objects merge property by
+// property, all the way down; anything else in the overlay replaces what
+// it overlays, except that arrays may instead be combined as
+// MergeOptions.Arrays directs.
+
+// ArrayMerge selects how Merge combines two arrays.
+type ArrayMerge int
+
+const (
+	// MergeReplace replaces the array with the overlay's.
+	MergeReplace ArrayMerge = iota
+	// MergeAppend appends the overlay's items to the array's.
+	MergeAppend
+	// MergeByKey matches the items of the two arrays by the value of the
+	// property MergeOptions.Key and merges matching items. Items of the
+	// overlay that match none are appended, as are those that are not
+	// objects or lack the property.
+	MergeByKey
+)
+
+// MergeOptions configures Merge.
+type MergeOptions struct {
+	// Arrays is how arrays are combined. By default the overlay's array
+	// replaces the other.
+	Arrays ArrayMerge
+
+	// Key names the property that identifies the items of arrays merged
+	// with MergeByKey, such as "name".
+	Key string
+}
+
+// Merge returns src overlaid on dst, both values Unmarshal produces.
+// Where both are objects, the result holds every property of either,
+// with properties present in both merged in turn; where both are arrays,
+// they are combined as opts.Arrays directs; and otherwise the result is
+// src. A null in src replaces what it overlays like any other value.
+//
+// Neither dst nor src is modified, but the result shares any values they
+// hold that did not need merging. Merged objects keep the representation
+// of dst, and their keys the order of dst followed by those new in src.
+func Merge(dst, src any, opts MergeOptions) (any, error) {
+	if opts.Arrays == MergeByKey && opts.Key == "" {
+		return nil, errors.New("MergeByKey needs a Key")
+	}
+	return merge(dst, src, &opts), nil
+}
+
+// merge returns src overlaid on dst.
+func merge(dst, src any, opts *MergeOptions) any {
+	if isObject(dst) && isObject(src) {
+		out := copyObject(dst, objectLen(dst)+objectLen(src))
+		eachProperty(src, func(k string, v any) bool {
+			if old, ok := getProperty(out, k); ok {
+				v = merge(old, v, opts)
+			}
+			setProperty(out, k, v)
+			return true
+		})
+		return out
+	}
+	a, aok := arrayItems(dst)
+	b, bok := arrayItems(src)
+	if !aok || !bok {
+		return src
+	}
+	switch opts.Arrays {
+	case MergeAppend:
+		out := make([]any, 0, len(a)+len(b))
+		return append(append(out, a...), b...)
+	case MergeByKey:
+		return mergeByKey(a, b, opts)
+	}
+	return src
+}
+
+// mergeByKey merges the items of b into those of a that have the same
+// value of the property opts.Key, appending the rest.
+func mergeByKey(a, b []any, opts *MergeOptions) []any {
+	out := append(make([]any, 0, len(a)+len(b)), a...)
+	for _, item := range b {
+		if i := findByKey(out[:len(a)], item, opts.Key); i >= 0 {
+			out[i] = merge(out[i], item, opts)
+		} else {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// findByKey returns the position of the first item that has the same value
+// of property key as item does, or -1.
+func findByKey(items []any, item any, key string) int {
+	want, ok := getProperty(item, key)
+	if !ok {
+		return -1
+	}
+	for i, candidate := range items {
+		if got, ok := getProperty(candidate, key); ok && Equal(got, want) {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyObject returns a shallow copy of obj, in the same representation,
+// with room for n properties.
+func copyObject(obj any, n int) any {
+	var out any
+	if _, ok := obj.(*OrderedMap); ok {
+		out = &OrderedMap{members: make([]Member, 0, n)}
+	} else {
+		out = make(map[string]any, n)
+	}
+	mergeObject(out, obj)
+	return out
+}
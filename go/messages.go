@@ -0,0 +1,334 @@
+package yay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ============================================================================
+// Message Catalog
+// ============================================================================
+//
+// Every error and warning the parser reports has a code, which names it
+// stably however its wording changes, and an English message format. A
+// Catalog given to DecodeOptions.Catalog replaces the formats by code, so
+// that an embedder can localize the messages, or word them as another
+// implementation does, while programs that check codes go on working.
+//
+// The parser writes each message with its English format, and the format
+// is looked up in englishCodes to find its code. A format that begins with
+// %w wraps a sentinel error such as ErrTooDeep; its catalog entry spells
+// the sentinel out, as in "Nesting too deep (limit %d)", so that a
+// translation can word it too, and the error wraps the sentinel still.
+
+// Catalog holds message formats by their codes, as DefaultCatalog gives
+// them. A format takes the same arguments, in the same order, as the
+// English format for its code, though it may use fmt's explicit argument
+// indexes, as in %[2]d, to place them differently. Codes missing from a
+// Catalog keep their English messages. The position of the error, when
+// the document has a filename, is added after the message.
+type Catalog map[string]string
+
+// englishMessages is the English format of each message, by code.
+var englishMessages = Catalog{
+	// Characters and encoding
+	"illegal-bom":          "Illegal BOM",
+	"forbidden-code-point": "Forbidden code point U+%04X",
+	"invalid-utf8":         "Invalid UTF-8 (byte offset %d)",
+	"illegal-surrogate":    "Illegal surrogate",
+	"unsupported-version":  "Unsupported YAY version %d (up to %d is supported)",
+
+	// Whitespace and layout
+	"tab":                      "Tab not allowed (use spaces)",
+	"trailing-space":           "Unexpected trailing space",
+	"leading-space":            "Unexpected leading space",
+	"unexpected-indent":        "Unexpected indent",
+	"inconsistent-indentation": "Inconsistent indentation",
+	"space-before":             "Unexpected space before \"%s\"",
+	"space-after":              "Unexpected space after \"%s\"",
+	"expected-space-after":     "Expected space after \"%s\"",
+	"newline-in-inline-array":  "Unexpected newline in inline array",
+	"newline-in-inline-object": "Unexpected newline in inline object",
+
+	// Structure
+	"no-value":                   "No value found in document <%s>",
+	"empty-value":                "Unexpected empty value",
+	"extra-content":              "Unexpected extra content",
+	"unexpected-character":       "Unexpected character \"%s\"",
+	"expected-array":             "Expected array",
+	"expected-object":            "Expected object",
+	"expected-value":             "Expected value after property",
+	"expected-colon":             "Expected colon after key",
+	"missing-key":                "Missing key",
+	"invalid-key":                "Invalid key",
+	"invalid-key-character":      "Invalid key character",
+	"unterminated-inline-array":  "Unterminated inline array",
+	"unterminated-inline-object": "Unterminated inline object",
+	"block-leader-in-property":   "Expected newline after block leader in property",
+	"unplaced-value":             "Value not found in the text at this position",
+
+	// Numbers
+	"space-in-number":    "Unexpected space in number",
+	"uppercase-exponent": "Uppercase exponent (use lowercase 'e')",
+	"float-overflow":     "Float overflow",
+	"float-underflow":    "Float underflow",
+	"invalid-number":     "Invalid number",
+
+	// Strings
+	"unterminated-string":           "Unterminated string",
+	"unterminated-quoted-string":    "unterminated string",
+	"expected-string":               "expected string",
+	"expected-single-quoted-string": "expected single-quoted string",
+	"invalid-unicode-escape":        "invalid unicode escape",
+	"bad-escape":                    "Bad escaped character",
+	"bad-unicode-escape":            "Bad Unicode escape",
+	"code-point-out-of-range":       "Unicode code point out of range",
+	"bad-string-character":          "Bad character in string",
+	"empty-block-string":            "Empty block string not allowed (use \"\" or \"\\n\" explicitly)",
+	"uppercase-hex":                 "Uppercase hex digit (use lowercase)",
+	"invalid-hex":                   "Invalid hex",
+	"invalid-hex-digit":             "Invalid hex digit",
+	"odd-hex-digits":                "Odd number of hex digits in byte literal",
+	"invalid-byte-literal":          "Invalid byte literal",
+	"unclosed-angle-bracket":        "Unclosed angle bracket",
+	"unmatched-angle-bracket":       "Unmatched angle bracket",
+	"expected-hex-block":            "Expected hex or comment in hex block",
+	"expected-quoted-string":        "Expected quoted string",
+
+	// Limits
+	"too-large":       "Document too large (limit %d bytes)",
+	"too-deep":        "Nesting too deep (limit %d)",
+	"too-deep-inline": "Nesting too deep in inline value (limit %d)",
+	"memory-budget":   "Memory budget exceeded (limit %d bytes)",
+	"line-too-long":   "Line exceeds the limit of %d bytes",
+	"too-many-items":  "Collection exceeds the limit of %d items",
+	"key-too-long":    "Key exceeds the limit of %d bytes",
+	"string-too-long": "String exceeds the limit of %d bytes",
+	"block-too-large": "Block values exceed the limit of %d bytes",
+
+	// Warnings
+	"ignored-bom":             "Ignored BOM",
+	"float-overflow-rounded":  "Float overflow rounded to %v",
+	"float-underflow-rounded": "Float underflow rounded to 0",
+}
+
+// englishCodes gives the code of each English format.
+var englishCodes = func() map[string]string {
+	codes := make(map[string]string, len(englishMessages))
+	for code, format := range englishMessages {
+		codes[format] = code
+	}
+	return codes
+}()
+
+// DefaultCatalog returns a copy of the English catalog, every code with
+// its format, as a base for a translation.
+func DefaultCatalog() Catalog {
+	c := make(Catalog, len(englishMessages))
+	for code, format := range englishMessages {
+		c[code] = format
+	}
+	return c
+}
+
+// ErrorCode returns the code of the parser's message that err is or wraps,
+// or "" if it has none.
+//
+//	if yay.ErrorCode(err) == "tab" {
+//		// Offer to replace the tabs with spaces.
+//	}
+func ErrorCode(err error) string {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	return ""
+}
+
+// ParseError reports a document that is not well-formed YAY, or that
+// exceeds a limit of DecodeOptions, with its message apart from where in
+// the document it is, so that linters and editors can place it without
+// reading the position back out of the text of the error:
+//
+//	var pe *yay.ParseError
+//	if errors.As(err, &pe) && pe.Line > 0 {
+//		report(pe.Line, pe.Column, pe.Message)
+//	}
+//
+// The position is known whatever the filename, though the text of the
+// error gives it only with one, as it always has. An error with no one
+// position, such as that of a document too large, has a Line of 0.
+type ParseError struct {
+	Code    string // Names the message stably, as ErrorCode returns it
+	Message string // As the Catalog words it, without the position
+
+	Position
+
+	err error // The sentinel the message wraps, if any
+}
+
+func (e *ParseError) Error() string {
+	if e.Filename == "" || e.Line == 0 {
+		return e.Message
+	}
+	return e.Message + " at " + e.Position.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+// Render returns the error as its text, followed by the line of source,
+// the document that failed, on which it is, and a caret beneath the column,
+// for a command to show its user:
+//
+//	Tab not allowed (use spaces) at 2:3 of <app.yay>
+//	b:	2
+//	  ^
+//
+// Tabs before the column are kept in the caret's line, so that the caret
+// lines up where the terminal expands them, and the other characters are
+// padded by the columns a terminal gives them: two for wide characters, as
+// of Chinese, Japanese, and Korean, and none for combining marks. An error
+// with no one position renders as its text alone.
+func (e *ParseError) Render(source []byte) string {
+	msg := e.Error() + "\n"
+	if e.Line == 0 || e.Offset > len(source) {
+		return msg
+	}
+	start := bytes.LastIndexByte(source[:e.Offset], '\n') + 1
+	end := bytes.IndexByte(source[e.Offset:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += e.Offset
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	b.Write(source[start:end])
+	b.WriteByte('\n')
+	for _, r := range string(source[start:e.Offset]) {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteString("  "[:runeWidth(r)])
+		}
+	}
+	b.WriteString("^\n")
+	return b.String()
+}
+
+// wideRanges are the ranges of code points a terminal shows two columns
+// wide: the East Asian Wide and Fullwidth characters of Unicode's
+// EastAsianWidth.txt, in the blocks that are wide throughout, and the
+// emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals through CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana through CJK compatibility
+	{0x3400, 0x4DBF},   // CJK unified ideographs extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // Vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms and small form variants
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Miscellaneous symbols and pictographs, emoticons
+	{0x1F900, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x20000, 0x3FFFD}, // CJK unified ideographs extensions B and later
+}
+
+// runeWidth returns the columns a terminal gives r: none for a combining
+// mark or format character, two for a wide character, and otherwise one.
+func runeWidth(r rune) int {
+	if r < 0x300 {
+		return 1
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, w := range wideRanges {
+		if r < w[0] {
+			break
+		}
+		if r <= w[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// format returns the format c gives for the message of English format,
+// and its code. A format that begins with %w takes an error as its first
+// argument, which is spelled out in the English format, and returned
+// apart from the rest of args.
+func (c Catalog) format(format string, args []any) (code, translated string, wrapped error, rest []any) {
+	if strings.HasPrefix(format, "%w") && len(args) > 0 {
+		if err, ok := args[0].(error); ok {
+			format, wrapped, args = err.Error()+format[len("%w"):], err, args[1:]
+		}
+	}
+	code = englishCodes[format]
+	if t, ok := c[code]; ok && code != "" {
+		format = t
+	}
+	return code, format, wrapped, args
+}
+
+// errorf returns the error for the message of English format, as c words
+// it, with no position.
+func (c Catalog) errorf(format string, args ...any) *ParseError {
+	code, format, wrapped, args := c.format(format, args)
+	return &ParseError{Code: code, Message: fmt.Sprintf(format, args...), err: wrapped}
+}
+
+// catalog returns the catalog of ctx, if any.
+func (ctx *parseContext) catalog() Catalog {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.messages
+}
+
+// errorf returns the error for the message of English format, at offset
+// off of the document.
+func (ctx *parseContext) errorf(off int, format string, args ...any) error {
+	err := ctx.catalog().errorf(format, args...)
+	ctx.place(err, off)
+	return err
+}
+
+// unplacedErrorf returns the error for the message of English format, for
+// an error that has no one position in the document.
+func (ctx *parseContext) unplacedErrorf(format string, args ...any) error {
+	return ctx.catalog().errorf(format, args...)
+}
+
+// placed returns err, from parsing the part of the document at offset off
+// without knowing where that part is, placed there.
+func (ctx *parseContext) placed(err error, off int) error {
+	var pe *ParseError
+	if errors.As(err, &pe) && pe.Line == 0 {
+		ctx.place(pe, off)
+		return err
+	}
+	return fmt.Errorf("%w%s", err, locSuffix(ctx, off))
+}
+
+// place gives err the position of offset off of the document.
+func (ctx *parseContext) place(err *ParseError, off int) {
+	if ctx == nil {
+		return
+	}
+	line, col := positionAt(ctx.source, off, ctx.columns)
+	if ctx.bom {
+		off += len("\uFEFF")
+	}
+	err.Position = Position{Filename: ctx.filename, Line: line + 1, Column: col + 1, Offset: off}
+}
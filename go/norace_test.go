@@ -0,0 +1,5 @@
+//go:build !race
+
+package yay
+
+const raceEnabled = false
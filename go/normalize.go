@@ -0,0 +1,99 @@
+package yay
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// ============================================================================
+// Normalization
+// ============================================================================
+//
+// Decoded values come in more shapes than most consumers care to handle:
+// an integer is a *big.Int however small, an object may be a map or an
+// *OrderedMap, and a float may be NaN. Normalize rewrites a value into the
+// narrower shape a consumer chooses, so that code comparing values or
+// handing them to encoding/json deals with one predictable set of types.
+
+// NonFinite selects what Normalize does with NaN and the infinities.
+type NonFinite int
+
+const (
+	// NonFiniteKeep leaves NaN and the infinities as they are.
+	NonFiniteKeep NonFinite = iota
+	// NonFiniteNull replaces NaN and the infinities with null.
+	NonFiniteNull
+	// NonFiniteError makes NaN and the infinities errors.
+	NonFiniteError
+)
+
+// NormalizeOptions selects the shape Normalize produces. The zero value
+// changes only Array, to []any.
+type NormalizeOptions struct {
+	// SmallInts converts integers that fit in an int64 to int64, leaving
+	// larger ones as *big.Int.
+	SmallInts bool
+
+	// Unordered converts *OrderedMap objects to map[string]any.
+	Unordered bool
+
+	// NonFinite is what becomes of NaN and the infinities.
+	NonFinite NonFinite
+}
+
+// Normalize returns a copy of v, a value Unmarshal produces, in the shape
+// opts selects. Arrays are always []any in the result. The error, if any,
+// names the path of the value that opts rejects. v itself is not modified.
+func Normalize(v any, opts NormalizeOptions) (any, error) {
+	return normalize(v, &opts, "")
+}
+
+// normalize returns v, found at path, normalized.
+func normalize(v any, opts *NormalizeOptions, path string) (any, error) {
+	switch x := v.(type) {
+	case *big.Int:
+		if opts.SmallInts && x != nil && x.IsInt64() {
+			return x.Int64(), nil
+		}
+	case float64:
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			break
+		}
+		switch opts.NonFinite {
+		case NonFiniteNull:
+			return nil, nil
+		case NonFiniteError:
+			return nil, fmt.Errorf("Non-finite number %s at %s", appendFloat(nil, x, 64), displayPath(path))
+		}
+	case map[string]any, *OrderedMap:
+		var out any
+		if _, ok := x.(*OrderedMap); ok && !opts.Unordered {
+			out = &OrderedMap{members: make([]Member, 0, objectLen(x))}
+		} else {
+			out = make(map[string]any, objectLen(x))
+		}
+		var err error
+		eachProperty(x, func(k string, item any) bool {
+			item, err = normalize(item, opts, path+keyPathElement(k))
+			setProperty(out, k, item)
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	case []any, Array:
+		items, _ := arrayItems(x)
+		out := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if out[i], err = normalize(item, opts, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,71 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ============================================================================
+// Nullable Values
+// ============================================================================
+//
+// Configuration and database-adjacent structs are full of fields that may
+// be absent, which Go spells as a value with a validity flag: Optional in
+// this package, and sql.NullString, sql.NullInt64, and the rest in
+// database/sql. These nullable types encode as null when they are not
+// valid and as their value when they are, and decode the other way.
+
+// Optional holds a value of type T or nothing, which YAY writes as null.
+// The zero value holds nothing.
+type Optional[T any] struct {
+	Value T
+	Valid bool // Whether Value is set
+}
+
+// Some returns an Optional holding v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Valid: true}
+}
+
+// Get returns the value and whether there is one.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Valid
+}
+
+// Or returns the value if there is one, or else def.
+func (o Optional[T]) Or(def T) T {
+	if o.Valid {
+		return o.Value
+	}
+	return def
+}
+
+// yayNullable marks the Optional types for isNullable.
+func (Optional[T]) yayNullable() {}
+
+// nullableType is the interface of the Optional types.
+var nullableType = reflect.TypeOf((*interface{ yayNullable() })(nil)).Elem()
+
+// isNullable reports whether t is an Optional or one of the Null types of
+// database/sql, which have the same shape: a value field followed by a
+// Valid field.
+func isNullable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if t.Implements(nullableType) {
+		return true
+	}
+	return t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null") &&
+		t.NumField() == 2 && t.Field(1).Name == "Valid" && t.Field(1).Type.Kind() == reflect.Bool
+}
+
+// nullableValue returns the value a nullable value v holds, or nil.
+func nullableValue(v reflect.Value) any {
+	if !v.Field(1).Bool() {
+		return nil
+	}
+	return v.Field(0).Interface()
+}
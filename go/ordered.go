@@ -0,0 +1,231 @@
+package yay
+
+// ============================================================================
+// Ordered Objects
+// ============================================================================
+//
+// With DecodeOptions.PreserveKeyOrder, objects decode to *OrderedMap rather
+// than map[string]any, keeping their keys in the order the document gives
+// them. The parser builds objects only through the helpers at the end of
+// this file, which choose the representation.
+
+// Object is another name for OrderedMap, for code that treats ordered
+// objects as the value type of choice rather than an option of decoding.
+type Object = OrderedMap
+
+// Member is one property of an OrderedMap.
+type Member struct {
+	Key   string
+	Value any
+}
+
+// orderedIndexMin is the size at which an OrderedMap indexes its keys
+// instead of searching its members.
+const orderedIndexMin = 8
+
+// OrderedMap is an object that keeps its keys in insertion order.
+// Marshal writes its properties in that order. The zero value is an empty
+// map ready to use.
+type OrderedMap struct {
+	members []Member
+	index   map[string]int // Member positions, once there are enough
+}
+
+// NewOrderedMap returns an OrderedMap holding members, in order. A key that
+// appears more than once takes the last value given at its first position.
+func NewOrderedMap(members ...Member) *OrderedMap {
+	m := &OrderedMap{members: make([]Member, 0, len(members))}
+	for _, member := range members {
+		m.Set(member.Key, member.Value)
+	}
+	return m
+}
+
+// Len returns the number of properties.
+func (m *OrderedMap) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.members)
+}
+
+// find returns the position of key, or -1.
+func (m *OrderedMap) find(key string) int {
+	if m == nil {
+		return -1
+	}
+	if m.index != nil {
+		if i, ok := m.index[key]; ok {
+			return i
+		}
+		return -1
+	}
+	for i := range m.members {
+		if m.members[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the value of key and whether it is present.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	if i := m.find(key); i >= 0 {
+		return m.members[i].Value, true
+	}
+	return nil, false
+}
+
+// Set gives key a value, keeping the key's position if it is present and
+// adding it at the end otherwise.
+func (m *OrderedMap) Set(key string, value any) {
+	if i := m.find(key); i >= 0 {
+		m.members[i].Value = value
+		return
+	}
+	m.members = append(m.members, Member{Key: key, Value: value})
+	switch {
+	case m.index != nil:
+		m.index[key] = len(m.members) - 1
+	case len(m.members) >= orderedIndexMin:
+		m.index = make(map[string]int, len(m.members))
+		for i := range m.members {
+			m.index[m.members[i].Key] = i
+		}
+	}
+}
+
+// Delete removes key, reporting whether it was present. The remaining
+// keys keep their order.
+func (m *OrderedMap) Delete(key string) bool {
+	i := m.find(key)
+	if i < 0 {
+		return false
+	}
+	m.members = append(m.members[:i], m.members[i+1:]...)
+	if m.index != nil {
+		delete(m.index, key)
+		for j := i; j < len(m.members); j++ {
+			m.index[m.members[j].Key] = j
+		}
+	}
+	return true
+}
+
+// Keys returns the keys in order.
+func (m *OrderedMap) Keys() []string {
+	keys := make([]string, m.Len())
+	for i := range keys {
+		keys[i] = m.members[i].Key
+	}
+	return keys
+}
+
+// Members returns a copy of the properties in order.
+func (m *OrderedMap) Members() []Member {
+	if m.Len() == 0 {
+		return nil
+	}
+	return append([]Member(nil), m.members...)
+}
+
+// Map returns the properties as a map[string]any, losing their order.
+// Nested objects are left as they are.
+func (m *OrderedMap) Map() map[string]any {
+	out := make(map[string]any, m.Len())
+	for i := 0; i < m.Len(); i++ {
+		out[m.members[i].Key] = m.members[i].Value
+	}
+	return out
+}
+
+// newObject returns an empty object with room for n properties, in the
+// representation the parse calls for.
+func (ctx *parseContext) newObject(n int) any {
+	if ctx != nil && ctx.ordered {
+		return &OrderedMap{members: make([]Member, 0, n)}
+	}
+	return make(map[string]any, n)
+}
+
+// newProperty returns an object of the single property key, whose key
+// begins at off.
+func (ctx *parseContext) newProperty(key string, value any, off int) any {
+	var obj any
+	if ctx != nil && ctx.ordered {
+		obj = &OrderedMap{members: []Member{{Key: key, Value: value}}}
+	} else {
+		obj = map[string]any{key: value}
+	}
+	ctx.noteProperty(obj, key, off)
+	return obj
+}
+
+// setProperty sets a property of obj, an object from newObject.
+func setProperty(obj any, key string, value any) {
+	switch obj := obj.(type) {
+	case map[string]any:
+		obj[key] = value
+	case *OrderedMap:
+		obj.Set(key, value)
+	}
+}
+
+// isObject reports whether v is an object in either representation.
+func isObject(v any) bool {
+	switch v.(type) {
+	case map[string]any, *OrderedMap:
+		return true
+	}
+	return false
+}
+
+// objectLen returns the number of properties of obj.
+func objectLen(obj any) int {
+	switch obj := obj.(type) {
+	case map[string]any:
+		return len(obj)
+	case *OrderedMap:
+		return obj.Len()
+	}
+	return 0
+}
+
+// getProperty returns the value of key in obj.
+func getProperty(obj any, key string) (any, bool) {
+	switch obj := obj.(type) {
+	case map[string]any:
+		v, ok := obj[key]
+		return v, ok
+	case *OrderedMap:
+		return obj.Get(key)
+	}
+	return nil, false
+}
+
+// eachProperty calls f with each property of obj until f returns false,
+// in order for an *OrderedMap.
+func eachProperty(obj any, f func(key string, value any) bool) {
+	switch obj := obj.(type) {
+	case map[string]any:
+		for k, v := range obj {
+			if !f(k, v) {
+				return
+			}
+		}
+	case *OrderedMap:
+		for _, m := range obj.members {
+			if !f(m.Key, m.Value) {
+				return
+			}
+		}
+	}
+}
+
+// mergeObject sets every property of src on dst, in order.
+func mergeObject(dst, src any) {
+	eachProperty(src, func(k string, v any) bool {
+		setProperty(dst, k, v)
+		return true
+	})
+}
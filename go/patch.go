@@ -0,0 +1,261 @@
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ============================================================================
+// Patches
+// ============================================================================
+//
+// A patch is a list of operations on a document, after JSON Patch (RFC
+// 6902) but with paths in the syntax Get accepts. As a YAY document, a
+// patch is an array of objects:
+//
+//	- {op: "test", path: ".version", value: 2}
+//	- {op: "replace", path: ".servers[0].port", value: 8080}
+//	- {op: "add", path: ".servers[1]", value: {host: "b"}}
+//	- {op: "move", from: ".legacy", path: ".compat"}
+//	- {op: "remove", path: ".debug"}
+//
+// ApplyPatch applies the operations in order to a copy of the document, so
+// a patch that fails part way leaves the original as it was.
+
+// ErrTestFailed is reported, wrapped with the path, when a test operation
+// finds a value other than the one it expects.
+var ErrTestFailed = errors.New("Patch test failed")
+
+// PatchOperation is one operation of a patch.
+type PatchOperation struct {
+	// Op is "add", "remove", "replace", "move", or "test".
+	Op string
+	// Path is the value to operate on.
+	//   - add: an object property to add or replace, or an array index at
+	//     which to insert, up to and including the length of the array.
+	//   - remove, replace: a property or item that must be present.
+	//   - move: where to add the value removed from From.
+	//   - test: a value that must equal Value.
+	Path string
+	// From is the value that move removes.
+	From string
+	// Value is the value for add, replace, and test.
+	Value any
+}
+
+// DecodePatch returns the operations of a patch document, a value
+// Unmarshal produces, which must be an array of objects each with an "op"
+// and a "path", a "from" for move, and a "value" for add, replace, and
+// test.
+func DecodePatch(v any) ([]PatchOperation, error) {
+	items, ok := arrayItems(v)
+	if !ok {
+		return nil, fmt.Errorf("Patch must be an array of operations, not %s", describeValue(v))
+	}
+	patch := make([]PatchOperation, len(items))
+	for i, item := range items {
+		if !isObject(item) {
+			return nil, fmt.Errorf("Patch operation %d must be an object, not %s", i, describeValue(item))
+		}
+		op := &patch[i]
+		for _, f := range []struct {
+			key      string
+			s        *string
+			required bool
+		}{
+			{"op", &op.Op, true},
+			{"path", &op.Path, true},
+			{"from", &op.From, false},
+		} {
+			v, ok := getProperty(item, f.key)
+			if !ok && !f.required {
+				continue
+			}
+			s, isString := v.(string)
+			if !isString {
+				return nil, fmt.Errorf("Patch operation %d needs a string %q", i, f.key)
+			}
+			*f.s = s
+		}
+		value, ok := getProperty(item, "value")
+		if !ok && (op.Op == "add" || op.Op == "replace" || op.Op == "test") {
+			return nil, fmt.Errorf("Patch operation %d needs a \"value\"", i)
+		}
+		op.Value = value
+	}
+	return patch, nil
+}
+
+// ApplyPatch returns doc, a value Unmarshal produces, with the operations
+// of patch applied in order. The document is copied first, so doc is
+// unchanged even if the patch fails. Added values are not copied, and the
+// result may share them with patch.
+//
+// A path that leads nowhere is an error wrapping ErrNotFound, one that
+// meets a value of the wrong kind ErrPathType, and a test that fails
+// ErrTestFailed, each wrapped with the position of the operation.
+func ApplyPatch(doc any, patch []PatchOperation) (any, error) {
+	doc = copyValue(doc)
+	for i, op := range patch {
+		var err error
+		if doc, err = applyOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("Patch operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// applyOperation returns doc with op applied.
+func applyOperation(doc any, op PatchOperation) (any, error) {
+	path, err := parsePath(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, op.Value)
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+	case "replace":
+		if _, err := Get(doc, op.Path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return op.Value, nil
+		}
+		return updateParent(doc, path, func(parent any, s pathSegment) (any, error) {
+			return replaceItem(parent, s, op.Value), nil
+		})
+	case "move":
+		from, err := parsePath(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+			return nil, fmt.Errorf("Cannot move %s into itself", formatPath(from))
+		}
+		doc, value, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "test":
+		v, err := Get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(v, op.Value) {
+			return nil, fmt.Errorf("%w: %s is %s, not %s", ErrTestFailed, formatPath(path), describeValue(v), describeValue(op.Value))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("Unknown patch operation %q", op.Op)
+}
+
+// addValue returns doc with value added at path: set on an object, or
+// inserted into an array. Adding at the root replaces the document.
+func addValue(doc any, path []pathSegment, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(parent any, s pathSegment) (any, error) {
+		if s.isKey {
+			return replaceItem(parent, s, value), nil
+		}
+		items, _ := arrayItems(parent)
+		if s.index > len(items) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, formatPath(path))
+		}
+		return slices.Insert(items, s.index, value), nil
+	})
+}
+
+// removeValue returns doc with the value at path removed, and that value.
+// Removing the root leaves null.
+func removeValue(doc any, path []pathSegment) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	var removed any
+	doc, err := updateParent(doc, path, func(parent any, s pathSegment) (any, error) {
+		v, ok, _ := step(parent, []pathSegment{s})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, formatPath(path))
+		}
+		removed = v
+		switch parent := parent.(type) {
+		case map[string]any:
+			delete(parent, s.key)
+		case *OrderedMap:
+			parent.Delete(s.key)
+		default:
+			items, _ := arrayItems(parent)
+			return slices.Delete(items, s.index, s.index+1), nil
+		}
+		return parent, nil
+	})
+	return doc, removed, err
+}
+
+// replaceItem sets the property or item s of parent, which must be
+// present if s is an index, to value.
+func replaceItem(parent any, s pathSegment, value any) any {
+	if s.isKey {
+		setProperty(parent, s.key, value)
+	} else {
+		items, _ := arrayItems(parent)
+		items[s.index] = value
+	}
+	return parent
+}
+
+// updateParent returns doc with the collection holding the value at path
+// replaced by the result of f, given that collection and the last segment
+// of path. The collection must exist and be of the kind the segment calls
+// for, and path must not be empty.
+func updateParent(doc any, path []pathSegment, f func(parent any, s pathSegment) (any, error)) (any, error) {
+	var walk func(v any, n int) (any, error)
+	walk = func(v any, n int) (any, error) {
+		if n == len(path)-1 {
+			if _, _, err := step(v, path[:n+1]); err != nil {
+				return nil, err
+			}
+			return f(v, resolveSegment(v, path[n]))
+		}
+		child, ok, err := step(v, path[:n+1])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, formatPath(path[:n+1]))
+		}
+		if child, err = walk(child, n+1); err != nil {
+			return nil, err
+		}
+		return replaceItem(v, resolveSegment(v, path[n]), child), nil
+	}
+	return walk(doc, 0)
+}
+
+// copyValue returns a deep copy of the arrays and objects of v, sharing
+// its scalars.
+func copyValue(v any) any {
+	if isObject(v) {
+		out := copyObject(v, objectLen(v))
+		eachProperty(out, func(k string, item any) bool {
+			setProperty(out, k, copyValue(item))
+			return true
+		})
+		return out
+	}
+	if items, ok := arrayItems(v); ok {
+		out := make([]any, len(items))
+		for i, item := range items {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return v
+}
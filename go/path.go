@@ -0,0 +1,262 @@
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Paths
+// ============================================================================
+//
+// A path selects a value within a decoded document, in the syntax that
+// VerifyRoundTrip uses to report differences: ".name" selects a property,
+// "[2]" an array item, and `["odd key"]` a property whose key needs quotes.
+// The leading "." may be left off, as in "servers[2].port", and "" or "."
+// selects the whole document. A path that begins with "/" is instead a
+// JSON Pointer, as Pointer describes.
+
+// ErrInvalidPath is reported, wrapped with the path, for paths that do not
+// follow the path syntax.
+var ErrInvalidPath = errors.New("Invalid path")
+
+// ErrNotFound is reported, wrapped with the path, when a path leads to a
+// property or array item that is not present.
+var ErrNotFound = errors.New("Not found")
+
+// ErrPathType is reported, wrapped with the path, when a path selects a
+// property of a value that is not an object, or an item of a value that is
+// not an array.
+var ErrPathType = errors.New("Path does not fit value")
+
+// pathSegment is one step of a path: a property key or an array index.
+type pathSegment struct {
+	key   string
+	index int
+	isKey bool
+	token bool // From a pointer, so an index if it selects an array item
+}
+
+// String returns the segment as written in a path.
+func (s pathSegment) String() string {
+	if s.token {
+		return "/" + escapeToken(s.key)
+	}
+	if s.isKey {
+		return keyPathElement(s.key)
+	}
+	return "[" + strconv.Itoa(s.index) + "]"
+}
+
+// parsePath splits path into its segments.
+func parsePath(path string) ([]pathSegment, error) {
+	if path == "." {
+		return nil, nil
+	}
+	if strings.HasPrefix(path, "/") {
+		p, err := ParsePointer(path)
+		return p.segments(), err
+	}
+	var segments []pathSegment
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '[' && i+1 < len(path) && path[i+1] == '"':
+			quoted, err := strconv.QuotedPrefix(path[i+1:])
+			if err != nil || i+1+len(quoted) >= len(path) || path[i+1+len(quoted)] != ']' {
+				return nil, fmt.Errorf("%w %q", ErrInvalidPath, path)
+			}
+			key, _ := strconv.Unquote(quoted)
+			segments = append(segments, pathSegment{key: key, isKey: true})
+			i += len(quoted) + 2
+		case path[i] == '[':
+			j := i + 1
+			for j < len(path) && isDigit(path[j]) {
+				j++
+			}
+			if j == i+1 || j == len(path) || path[j] != ']' {
+				return nil, fmt.Errorf("%w %q", ErrInvalidPath, path)
+			}
+			index, err := strconv.Atoi(path[i+1 : j])
+			if err != nil {
+				return nil, fmt.Errorf("%w %q", ErrInvalidPath, path)
+			}
+			segments = append(segments, pathSegment{index: index})
+			i = j + 1
+		case path[i] == '.' || i == 0:
+			if path[i] == '.' {
+				i++
+			}
+			j := i
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("%w %q", ErrInvalidPath, path)
+			}
+			segments = append(segments, pathSegment{key: path[i:j], isKey: true})
+			i = j
+		default:
+			return nil, fmt.Errorf("%w %q", ErrInvalidPath, path)
+		}
+	}
+	return segments, nil
+}
+
+// formatPath returns segments as a path, with "." for the root, or as a
+// pointer if they came from one.
+func formatPath(segments []pathSegment) string {
+	path := ""
+	for _, s := range segments {
+		path += s.String()
+	}
+	if len(segments) > 0 && segments[0].token {
+		return path
+	}
+	return displayPath(path)
+}
+
+// resolveSegment returns s, a segment of a pointer, as an array index if v
+// is an array and s is a valid index or the "-" that stands for the end of
+// the array, and otherwise as an object key. Other segments are returned
+// as they are.
+func resolveSegment(v any, s pathSegment) pathSegment {
+	if !s.token {
+		return s
+	}
+	items, ok := arrayItems(v)
+	if !ok {
+		return s
+	}
+	if s.key == "-" {
+		return pathSegment{index: len(items)}
+	}
+	if index, ok := arrayIndex(s.key); ok {
+		return pathSegment{index: index}
+	}
+	return s
+}
+
+// Get returns the value at path within doc, a value Unmarshal produces.
+// A path that leads nowhere is an error wrapping ErrNotFound or, where it
+// meets a value of the wrong kind, ErrPathType:
+//
+//	port, err := yay.Get(doc, "servers[2].port")
+func Get(doc any, path string) (any, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	v := doc
+	for i := range segments {
+		var ok bool
+		v, ok, err = step(v, segments[:i+1])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, formatPath(segments[:i+1]))
+		}
+	}
+	return v, nil
+}
+
+// step returns the value that the last of segments selects within v, and
+// whether it is present.
+func step(v any, segments []pathSegment) (any, bool, error) {
+	s := resolveSegment(v, segments[len(segments)-1])
+	if s.isKey {
+		if !isObject(v) {
+			return nil, false, fmt.Errorf("%w: %s is %s, not an object", ErrPathType, formatPath(segments[:len(segments)-1]), describeValue(v))
+		}
+		item, ok := getProperty(v, s.key)
+		return item, ok, nil
+	}
+	items, ok := arrayItems(v)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %s is %s, not an array", ErrPathType, formatPath(segments[:len(segments)-1]), describeValue(v))
+	}
+	if s.index >= len(items) {
+		return nil, false, nil
+	}
+	return items[s.index], true, nil
+}
+
+// arrayItems returns the items of v if it is an array.
+func arrayItems(v any) ([]any, bool) {
+	switch v := v.(type) {
+	case []any:
+		return v, true
+	case Array:
+		return v, true
+	}
+	return nil, false
+}
+
+// Set stores value at path within *doc, a value Unmarshal produces.
+// Properties missing along the way are added, as objects or arrays as the
+// path calls for, and an index one past the end of an array appends to
+// it. A nil document is missing too, so Set can build one from nothing.
+// An index further past the end is an error wrapping ErrNotFound, and a
+// path that meets a value of the wrong kind, one wrapping ErrPathType:
+//
+//	err := yay.Set(&doc, "servers[2].port", big.NewInt(8080))
+func Set(doc *any, path string, value any) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	v, err := setPath(*doc, *doc != nil, segments, 0, value)
+	if err != nil {
+		return err
+	}
+	*doc = v
+	return nil
+}
+
+// setPath returns v, present or not according to ok, with value stored at
+// the path segments[n:] within it. Objects are updated in place, and
+// arrays too unless an item is appended.
+func setPath(v any, ok bool, segments []pathSegment, n int, value any) (any, error) {
+	if n == len(segments) {
+		return value, nil
+	}
+	s := segments[n]
+	if !ok {
+		if s.isKey {
+			v = map[string]any{}
+		} else {
+			v = []any{}
+		}
+	}
+	item, ok, err := step(v, segments[:n+1])
+	if err != nil {
+		return nil, err
+	}
+	s = resolveSegment(v, s)
+	if s.isKey {
+		item, err = setPath(item, ok, segments, n+1, value)
+		if err != nil {
+			return nil, err
+		}
+		setProperty(v, s.key, item)
+		return v, nil
+	}
+	items, _ := arrayItems(v)
+	if !ok && s.index > len(items) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, formatPath(segments[:n+1]))
+	}
+	item, err = setPath(item, ok, segments, n+1, value)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		items[s.index] = item
+		return v, nil
+	}
+	if a, isArray := v.(Array); isArray {
+		return append(a, item), nil
+	}
+	return append(items, item), nil
+}
@@ -0,0 +1,114 @@
+package yay
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// JSON Pointers
+// ============================================================================
+//
+// A JSON Pointer (RFC 6901) is a path written as "/" followed by each of
+// its reference tokens, with "~" escaped as "~0" and "/" as "~1". Since
+// nothing else needs escaping, keys holding dots, brackets, and quotes are
+// written as they are, and any key can be reached. A token selects an
+// array item if it is a valid index, written without leading zeros, and
+// otherwise an object property; "-" stands for the end of an array, where
+// a patch may add an item.
+//
+// Get, Set, and ApplyPatch take a pointer wherever they take a path, and
+// Diff gives each change's pointer as well as its path, so tools can agree
+// on pointers where the path syntax will not do.
+
+// Pointer is a JSON Pointer, as its unescaped reference tokens. The empty
+// pointer selects the whole document.
+type Pointer []string
+
+// ParsePointer parses a JSON Pointer such as "/servers/2/port". The empty
+// string is the pointer to the whole document; any other pointer begins
+// with "/". A "~" not followed by "0" or "1" is an error wrapping
+// ErrInvalidPath.
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("%w %q", ErrInvalidPath, s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		if !strings.Contains(token, "~") {
+			continue
+		}
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || token[j+1] != '0' && token[j+1] != '1') {
+				return nil, fmt.Errorf("%w %q", ErrInvalidPath, s)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return Pointer(tokens), nil
+}
+
+// String returns the pointer in the form ParsePointer reads.
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, token := range p {
+		b.WriteByte('/')
+		b.WriteString(escapeToken(token))
+	}
+	return b.String()
+}
+
+// Resolve returns the value the pointer selects within doc, a value
+// Unmarshal produces, with the errors Get reports.
+func (p Pointer) Resolve(doc any) (any, error) {
+	return Get(doc, p.String())
+}
+
+// segments returns the path segments of the pointer.
+func (p Pointer) segments() []pathSegment {
+	segments := make([]pathSegment, len(p))
+	for i, token := range p {
+		segments[i] = pathSegment{key: token, isKey: true, token: true}
+	}
+	return segments
+}
+
+// pointerOf returns the pointer that selects what segments do.
+func pointerOf(segments []pathSegment) Pointer {
+	p := make(Pointer, len(segments))
+	for i, s := range segments {
+		if s.isKey {
+			p[i] = s.key
+		} else {
+			p[i] = strconv.Itoa(s.index)
+		}
+	}
+	return p
+}
+
+// escapeToken escapes "~" and "/" in a reference token.
+func escapeToken(token string) string {
+	if !strings.ContainsAny(token, "~/") {
+		return token
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// arrayIndex returns the index that token stands for, if it is an array
+// index: "0", or digits not beginning with "0".
+func arrayIndex(token string) (int, bool) {
+	if token == "" || len(token) > 1 && token[0] == '0' {
+		return 0, false
+	}
+	for i := 0; i < len(token); i++ {
+		if !isDigit(token[i]) {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(token)
+	return index, err == nil
+}
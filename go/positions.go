@@ -0,0 +1,199 @@
+package yay
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// ============================================================================
+// Value Positions
+// ============================================================================
+//
+// Decoded values do not carry their positions in the document, which would
+// cost every decode for the sake of the few that fail. Instead, when a
+// value fails to convert to the Go type it is decoded into, the document is
+// parsed again with a positions table, which records where each property
+// and array item begins, and the table gives the position of the value at
+// the path where the conversion failed.
+//
+// A struct with a Position field is given its position the same way, from
+// a second parse made only for the types that ask for it.
+//
+// Properties are recorded by the identity of their object and their key,
+// and array items by the identity of their array's storage, so the table
+// describes only the values of the parse that filled it.
+
+// positions is the table of where the values of a document begin, by byte
+// offset.
+type positions struct {
+	root  int                // The root value
+	props map[propertyAt]int // The key of each property
+	items map[uintptr][]int  // The items of each array
+}
+
+// propertyAt identifies a property by its object and key.
+type propertyAt struct {
+	obj uintptr
+	key string
+}
+
+// identity returns the address of the storage of obj, an object or a
+// non-empty array, which is unique among the live values of a parse. It is
+// what reflect.Value.Pointer returns, found without reflection.
+func identity(obj any) uintptr {
+	switch x := obj.(type) {
+	case []any:
+		return uintptr(unsafe.Pointer(unsafe.SliceData(x)))
+	case *OrderedMap:
+		return uintptr(unsafe.Pointer(x))
+	case map[string]any:
+		// A map is a pointer to its storage.
+		return uintptr(*(*unsafe.Pointer)(unsafe.Pointer(&x)))
+	}
+	return 0
+}
+
+// noteProperty records that the property key of obj begins at off.
+func (ctx *parseContext) noteProperty(obj any, key string, off int) {
+	if ctx != nil && ctx.positions != nil {
+		ctx.positions.props[propertyAt{identity(obj), key}] = off
+	}
+}
+
+// noteMerged records that the properties of src, merged into dst, begin
+// where they began in src.
+func (ctx *parseContext) noteMerged(dst, src any) {
+	if ctx == nil || ctx.positions == nil {
+		return
+	}
+	eachProperty(src, func(k string, _ any) bool {
+		if off, ok := ctx.positions.props[propertyAt{identity(src), k}]; ok {
+			ctx.noteProperty(dst, k, off)
+		}
+		return true
+	})
+}
+
+// noteItem returns offs with off, where the next item of an array begins,
+// added if positions are being recorded.
+func (ctx *parseContext) noteItem(offs []int, off int) []int {
+	if ctx == nil || ctx.positions == nil {
+		return offs
+	}
+	return append(offs, off)
+}
+
+// noteItems records that the items of arr, in its final storage, begin at
+// offs.
+func (ctx *parseContext) noteItems(arr []any, offs []int) {
+	if ctx != nil && ctx.positions != nil && len(arr) > 0 {
+		ctx.positions.items[identity(arr)] = offs
+	}
+}
+
+// offsetOf returns where the value at path within root begins, or where
+// the deepest value along the path that was recorded begins.
+func (p *positions) offsetOf(root any, path []pathSegment) int {
+	off, v := p.root, root
+	for _, seg := range path {
+		if seg.isKey {
+			if !isObject(v) {
+				break
+			}
+			o, ok := p.props[propertyAt{identity(v), seg.key}]
+			if !ok {
+				break
+			}
+			off = o
+			v, _ = getProperty(v, seg.key)
+		} else {
+			items, ok := v.([]any)
+			if !ok || seg.index >= len(items) || seg.index >= len(p.items[identity(items)]) {
+				break
+			}
+			off = p.items[identity(items)][seg.index]
+			v = items[seg.index]
+		}
+	}
+	return off
+}
+
+// Position is where a value begins in a document. When a document is
+// decoded into a struct, a field of type Position or *Position, or a field
+// tagged `yay:",position"`, is set to where the struct's object begins,
+// so that checks made after decoding can point at the right line:
+//
+//	type Server struct {
+//		yay.Position
+//		Host string `yay:"host"`
+//		Port int    `yay:"port"`
+//	}
+//
+//	if s.Port < 1024 {
+//		return fmt.Errorf("%s: port %d is privileged", s.Position, s.Port)
+//	}
+//
+// The position of an object that is the value of a property is that of
+// its key, and of one that is an array item, that of the item.
+type Position struct {
+	Filename string
+
+	// Line and Column count from 1, with columns as DecodeOptions.Columns
+	// counts them, and are 0 if unknown.
+	Line, Column int
+
+	Offset int // The byte offset in the document
+}
+
+// String returns the position as "L:C of <file>", or "L:C" with no
+// filename.
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d of <%s>", p.Line, p.Column, p.Filename)
+}
+
+// positionFinder returns a function giving where the value at a path
+// begins in the document of data, which parsed without error under opts.
+// The document is parsed again, with a positions table, the first time
+// the function is called, and not at all if it is not.
+func positionFinder(data []byte, opts DecodeOptions) func(path []pathSegment) Position {
+	var (
+		ctx    *parseContext
+		root   any
+		parsed bool
+	)
+	return func(path []pathSegment) Position {
+		if !parsed {
+			ctx, root = recordPositions(data, opts)
+			parsed = true
+		}
+		if ctx == nil {
+			return Position{Filename: opts.Filename}
+		}
+		off := ctx.positions.offsetOf(root, path)
+		line, col := positionAt(ctx.source, off, ctx.columns)
+		if ctx.bom {
+			off += len("\uFEFF")
+		}
+		return Position{Filename: opts.Filename, Line: line + 1, Column: col + 1, Offset: off}
+	}
+}
+
+// recordPositions parses the document of data under opts, recording the
+// positions of its values, and returns the context holding them and the
+// value, or a nil context if the document does not parse.
+func recordPositions(data []byte, opts DecodeOptions) (*parseContext, any) {
+	ctx := newParseContext(string(data), opts.Filename, opts)
+	ctx.warn = nil // Warned of already
+	ctx.positions = &positions{
+		props: make(map[propertyAt]int),
+		items: make(map[uintptr][]int),
+	}
+	root, err := parse(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	return ctx, root
+}
@@ -0,0 +1,30 @@
+package yay
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRoundTripProperty(t *testing.T) {
+	iterations := 2000
+	if testing.Short() {
+		iterations = 200
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < iterations; i++ {
+		v := RandomValue(r, 4)
+		for _, opts := range []EncodeOptions{{}, {BlockStrings: BlockStringsExact}} {
+			out, err := MarshalWithOptions(v, opts)
+			if err != nil {
+				t.Fatalf("Marshal(%#v): %v", v, err)
+			}
+			got, err := Unmarshal(out)
+			if err != nil {
+				t.Fatalf("Unmarshal(%q): %v\nvalue: %#v", out, err, v)
+			}
+			if !Equal(got, v) {
+				t.Fatalf("round trip through %q:\ngot:  %#v\nwant: %#v", out, got, v)
+			}
+		}
+	}
+}
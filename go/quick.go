@@ -0,0 +1,27 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"math/rand"
+	"reflect"
+)
+
+// Generated holds a random value, and implements quick.Generator so that
+// testing/quick can supply YAY values to property functions. Builds with
+// the yay_tiny tag leave it out, for the reflection it needs:
+//
+//	f := func(g yay.Generated) bool { return check(g.Value) }
+//	if err := quick.Check(f, nil); err != nil { ... }
+type Generated struct {
+	Value any
+}
+
+// Generate returns a Generated value whose nesting grows with size.
+func (Generated) Generate(r *rand.Rand, size int) reflect.Value {
+	depth := 1 + size/25
+	if depth > 5 {
+		depth = 5
+	}
+	return reflect.ValueOf(Generated{RandomValue(r, depth)})
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package yay
+
+// raceEnabled reports whether the race detector is on. It makes sync.Pool
+// drop items at random, so allocation counts are meaningless.
+const raceEnabled = true
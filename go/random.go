@@ -0,0 +1,121 @@
+package yay
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+)
+
+// ============================================================================
+// Random Values
+// ============================================================================
+
+// RandomValue returns a random YAY value tree at most depth collections
+// deep, of the types Unmarshal produces, for property-based tests of code
+// that consumes decoded documents. Values are weighted toward the corners
+// hand-written fixtures seldom reach: integers beyond 64 bits, NaN,
+// infinities, signed zeros and subnormal floats, strings made of escapes
+// and astral code points, empty collections, and keys that need quoting.
+// Every value it returns survives Marshal and Unmarshal, so the strings
+// within an array that is an item of another array, which Marshal writes
+// on one line, need none but the JSON escapes.
+func RandomValue(r *rand.Rand, depth int) any {
+	return randomValue(r, depth, false, false)
+}
+
+// randomValue returns a random value at most depth collections deep. If
+// inline is set, the value is written on one line, and if item is set, it
+// is an item of an array.
+func randomValue(r *rand.Rand, depth int, inline, item bool) any {
+	n := 8
+	if depth > 0 {
+		n = 11
+	}
+	switch r.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 0
+	case 2:
+		return randomInt(r)
+	case 3:
+		return randomFloat(r)
+	case 4, 5:
+		s := randomString(r, 12)
+		for inline && !isInlineString(s) {
+			s = randomString(r, 12)
+		}
+		return s
+	case 6:
+		b := make([]byte, r.Intn(20))
+		r.Read(b)
+		return b
+	case 7:
+		if r.Intn(2) == 0 {
+			return []any{}
+		}
+		return map[string]any{}
+	case 8:
+		a := make([]any, 1+r.Intn(8))
+		for i := range a {
+			a[i] = randomValue(r, depth-1, inline || item, true)
+		}
+		return a
+	default:
+		m := map[string]any{}
+		for i := r.Intn(6); i >= 0; i-- {
+			m[randomKey(r)] = randomValue(r, depth-1, inline, false)
+		}
+		return m
+	}
+}
+
+func randomInt(r *rand.Rand) *big.Int {
+	n := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(r.Intn(200))))
+	if r.Intn(2) == 0 {
+		n.Neg(n)
+	}
+	return n
+}
+
+func randomFloat(r *rand.Rand) float64 {
+	special := []float64{
+		0, math.Copysign(0, -1), math.Inf(1), math.Inf(-1), math.NaN(),
+		math.MaxFloat64, math.SmallestNonzeroFloat64, 1e21, 1e-7, 0.1,
+	}
+	switch r.Intn(3) {
+	case 0:
+		return special[r.Intn(len(special))]
+	case 1:
+		return math.Float64frombits(r.Uint64())
+	}
+	return r.NormFloat64() * math.Pow(10, float64(r.Intn(40)-20))
+}
+
+// stringRunes are the characters random strings are drawn from.
+var stringRunes = []rune("aZ09 -_:#'\"\\/\b\f\n\r\t\x00\x1f\x7f\u0080\u00e9\u2028\ufffd\U0001f600{}[]<>`")
+
+func randomString(r *rand.Rand, max int) string {
+	var b strings.Builder
+	for i := r.Intn(max); i > 0; i-- {
+		b.WriteRune(stringRunes[r.Intn(len(stringRunes))])
+	}
+	return b.String()
+}
+
+// randomKey returns a key that Marshal can write: block keys have no
+// escapes other than \" and \\, so characters that may not appear
+// literally are left out, and a key may hold one kind of quote but not both.
+func randomKey(r *rand.Rand) string {
+	for {
+		k := randomString(r, 8)
+		if strings.ContainsFunc(k, func(c rune) bool { return c == '\n' || !isAllowedCodePoint(c) }) {
+			continue
+		}
+		if strings.Contains(k, "\"") && strings.Contains(k, "'") {
+			continue
+		}
+		return k
+	}
+}
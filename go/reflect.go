@@ -0,0 +1,209 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// ============================================================================
+// Reflection
+// ============================================================================
+//
+// Values of types other than those Unmarshal returns are prepared for the
+// encoder by reflection: through their codecs, their MarshalYAY and
+// MarshalText methods, and otherwise by their kinds. Builds with the
+// yay_tiny tag leave this out, as they do decoding into Go types; see
+// tiny.go.
+
+// visit identifies a pointer, map, or slice being prepared. A slice is
+// its array and length, since slices of one array may nest in each other
+// without a cycle.
+type visit struct {
+	ptr unsafe.Pointer
+	len int
+	typ reflect.Type
+}
+
+// visitOf returns the visit for v, or false if v is not a non-nil
+// pointer, map, or slice.
+func visitOf(v any) (visit, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return visit{}, false
+		}
+		key := visit{ptr: rv.UnsafePointer(), typ: rv.Type()}
+		if rv.Kind() == reflect.Slice {
+			key.len = rv.Len()
+		}
+		return key, true
+	}
+	return visit{}, false
+}
+
+// convert is value for v of a type other than those Unmarshal returns.
+func (p preparer) convert(v any) (any, bool, error) {
+	t := reflect.TypeOf(v)
+	k, ok := findCodec(p.opts.Codecs, t)
+	if !ok || k.marshal == nil {
+		if self, ok, err := marshalSelf(reflect.ValueOf(v)); ok {
+			if err != nil {
+				return nil, false, err
+			}
+			converted, _, err := p.value(self)
+			return converted, true, err
+		}
+		if text, ok, err := marshalText(reflect.ValueOf(v)); ok {
+			return text, true, err
+		}
+		if isNullable(t) {
+			converted, _, err := p.value(nullableValue(reflect.ValueOf(v)))
+			return converted, true, err
+		}
+		if collected, ok, err := collectIter(reflect.ValueOf(v), p); ok {
+			return collected, true, err
+		}
+		converted, err := p.reflectValue(reflect.ValueOf(v))
+		return converted, true, err
+	}
+	converted, err := k.marshal(v)
+	if err != nil {
+		return nil, false, &encodeError{typ: t.String(), err: err}
+	}
+	if reflect.TypeOf(converted) == t {
+		return nil, false, &encodeError{typ: t.String(), reason: "its codec returned another " + t.String()}
+	}
+	converted, _, err = p.value(converted)
+	return converted, true, err
+}
+
+// reflectValue returns v, of a type the encoder does not write, as a
+// value of one it does, by its kind, as encoding/json would: a struct as an
+// *OrderedMap of its fields in the order they are declared, a slice or
+// array as a []any, a map with string keys as a map[string]any, a pointer
+// as what it points to or nil, and a boolean, string, or number of a named
+// type as one of the unnamed type.
+func (p preparer) reflectValue(v reflect.Value) (any, error) {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := cachedTypeFields(t).list
+		if len(fields) == 0 && t.NumField() > 0 && !hasExportedFields(t) {
+			// Such as a type of another package meant to have a codec.
+			return nil, &encodeError{typ: t.String(), reason: "it has no exported fields"}
+		}
+		obj := &OrderedMap{members: make([]Member, 0, len(fields))}
+		for _, f := range fields {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil || f.omitEmpty && isEmptyValue(fv) {
+				continue // Promoted through a nil embedded pointer, or empty
+			}
+			x, _, err := p.value(fv.Interface())
+			if err != nil {
+				return nil, atPath(err, keyPathElement(f.name))
+			}
+			if !p.opts.OmitNull || !isNull(x) {
+				obj.members = append(obj.members, Member{Key: f.name, Value: x})
+			}
+		}
+		return obj, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		x, _, err := p.value(v.Elem().Interface())
+		return x, err
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			x, _, err := p.value(v.Index(i).Interface())
+			if err != nil {
+				return nil, atPath(err, "["+strconv.Itoa(i)+"]")
+			}
+			items[i] = x
+		}
+		return items, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, &encodeError{typ: t.String(), reason: "its keys are not strings"}
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		obj := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			k := iter.Key().String()
+			x, _, err := p.value(iter.Value().Interface())
+			if err != nil {
+				return nil, atPath(err, keyPathElement(k))
+			}
+			if !p.opts.OmitNull || !isNull(x) {
+				obj[k] = x
+			}
+		}
+		return obj, nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	return nil, &encodeError{typ: t.String()}
+}
+
+// isEmptyValue reports whether v is empty for a field tagged omitempty:
+// false, zero, a nil pointer, a nullable value without a value, or a
+// string, slice, map, or Go array of length zero. Structs are never empty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return isNullable(v.Type()) && nullableValue(v) == nil
+	}
+	return false
+}
+
+// hasExportedFields reports whether struct type t has any exported field,
+// including those promoted from embedded structs.
+func hasExportedFields(t reflect.Type) bool {
+	for _, sf := range reflect.VisibleFields(t) {
+		if sf.IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// deepEqual is Equal for values of types other than those Unmarshal
+// returns.
+func deepEqual(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,1067 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestCodecs(t *testing.T) {
+	var codecs Codecs
+	AddCodec(&codecs,
+		func(p point) (any, error) { return []any{p.x, p.y}, nil },
+		func(v any) (point, error) {
+			items, ok := v.([]any)
+			if !ok || len(items) != 2 {
+				return point{}, fmt.Errorf("Expected a pair, not %s", describeValue(v))
+			}
+			x, err := AsInt64(items[0])
+			if err != nil {
+				return point{}, err
+			}
+			y, err := AsInt64(items[1])
+			return point{int(x), int(y)}, err
+		})
+	RegisterCodec(func(c celsius) (any, error) { return fmt.Sprintf("%gC", float64(c)), nil }, nil)
+
+	doc := map[string]any{"at": point{1, 2}, "temps": []any{celsius(21.5), 3}}
+	opts := EncodeOptions{Codecs: &codecs}
+	got, err := MarshalWithOptions(doc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "at: [1, 2]\ntemps: [\"21.5C\", 3]\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, isPoint := doc["at"].(point); !isPoint {
+		t.Errorf("the value given to Marshal was changed")
+	}
+	if _, err := Marshal(doc); err == nil || err.Error() != "Cannot encode yay.point at .at: it has no exported fields" {
+		t.Errorf("without codecs: got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoderWithOptions(&buf, opts).Encode([]any{point{3, 4}}); err != nil || buf.String() != "- [3, 4]\n" {
+		t.Errorf("Encoder: got %q, %v", buf.String(), err)
+	}
+
+	var p point
+	dec := NewDecoderWithOptions(strings.NewReader("[5, 6]\n"), DecodeOptions{Codecs: &codecs})
+	if err := dec.Decode(&p); err != nil || p != (point{5, 6}) {
+		t.Errorf("Decode: got %v, %v", p, err)
+	}
+	dec = NewDecoderWithOptions(strings.NewReader("\"x\"\n"), DecodeOptions{Codecs: &codecs})
+	if err := dec.Decode(&p); err == nil || err.Error() != `Cannot decode yay.point: Expected a pair, not "x"` {
+		t.Errorf("Decode mismatch: got %v", err)
+	}
+	var c celsius
+	if err := NewDecoder(strings.NewReader("1\n")).Decode(&c); err != nil || c != 1 {
+		t.Errorf("Decode without an unmarshal codec: got %v, %v", c, err)
+	}
+
+	AddCodec(&codecs, func(p point) (any, error) { return p, nil }, nil)
+	if _, err := MarshalWithOptions(point{}, opts); err == nil || err.Error() != "Cannot encode yay.point: its codec returned another yay.point" {
+		t.Errorf("loop: got %v", err)
+	}
+}
+
+func TestDecodeConstraints(t *testing.T) {
+	type Inner struct {
+		Port int `yay:"port,min=1"`
+	}
+	type Config struct {
+		Inner Inner            `yay:"inner"`
+		Named map[string]Inner `yay:"named"`
+		List  []Inner          `yay:"list"`
+	}
+	for _, c := range []struct{ src, want string }{
+		{"inner:\n  port: 0\n", "Constraint not met: .inner.port is 0, less than the minimum 1 at 2:3 of <app.yay>"},
+		{"inner: {port: 1}\nlist:\n- port: 1\n- port: 0\n", "Constraint not met: .list[1].port is 0, less than the minimum 1 at 4:3 of <app.yay>"},
+		{"inner: {port: 1}\nnamed:\n  \"odd key\": {port: 0}\n", `Constraint not met: .named["odd key"].port is 0, less than the minimum 1 at 3:4 of <app.yay>`},
+	} {
+		var cfg Config
+		err := NewDecoderWithOptions(strings.NewReader(c.src), DecodeOptions{Filename: "app.yay"}).Decode(&cfg)
+		if !errors.Is(err, ErrConstraint) || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.src, err, c.want)
+		}
+	}
+	var cfg Config
+	if err := UnmarshalInto([]byte("inner:\n  port: 0\n"), &cfg); err == nil || err.Error() != "Constraint not met: .inner.port is 0, less than the minimum 1 at 2:3" {
+		t.Errorf("UnmarshalInto: got %v", err)
+	}
+	if err := UnmarshalInto([]byte("inner:\n  port: 80\n"), &cfg); err != nil || cfg.Inner.Port != 80 {
+		t.Errorf("valid: got %v, %+v", err, cfg)
+	}
+	// A field the document leaves out is not checked, though the struct
+	// is, as ValidateStruct shows.
+	cfg = Config{}
+	if err := UnmarshalInto([]byte("inner: {}\nlist: [{port: 1}]\n"), &cfg); err != nil {
+		t.Errorf("absent: got %v", err)
+	}
+	if err := ValidateStruct(cfg); !errors.Is(err, ErrConstraint) {
+		t.Errorf("absent, validated: got %v", err)
+	}
+	if err := UnmarshalInto([]byte("list: [{}, {port: 0}]\n"), &cfg); err == nil || err.Error() != "Constraint not met: .list[1].port is 0, less than the minimum 1 at 1:13" {
+		t.Errorf("absent, then present: got %v", err)
+	}
+
+	dec := NewDecoder(strings.NewReader("- {port: 1}\n- {port: 0}\n"))
+	if _, err := dec.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var inner Inner
+	if err := dec.Decode(&inner); err != nil {
+		t.Errorf("stream: got %v", err)
+	}
+	if err := dec.Decode(&inner); !errors.Is(err, ErrConstraint) {
+		t.Errorf("stream: got %v, want a constraint error", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	source := "ports:\n  - 80\n  - \"http\"\n"
+	var v map[string][]uint16
+	err := NewDecoderWithOptions(strings.NewReader(source), DecodeOptions{Filename: "app.yay"}).Decode(&v)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("got %v, want a *DecodeError", err)
+	}
+	if want := `Cannot decode "http" into uint16 at .ports[1] at 3:5 of <app.yay>`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	if de.Path != ".ports[1]" || de.Field != `["ports"][1]` || de.Type != reflect.TypeOf(uint16(0)) || de.Value != "http" || de.Line != 3 || de.Column != 5 {
+		t.Errorf("fields: got %+v", de)
+	}
+
+	for _, c := range []struct {
+		source string
+		target any
+		want   string
+	}{
+		{"a: 1\nb: {c: [1, 2, \"x\"]}\n", new(map[string]any), ""},
+		{"a: 1\nb: {c: [1, 2, \"x\"]}\n", new(map[string]map[string][]int), `Cannot decode 1 into map[string][]int at .a at 1:1 of <app.yay>`},
+		{"a: {}\nb: {c: [1, 2, \"x\"]}\n", new(map[string]map[string][]int), `Cannot decode "x" into int at .b.c[2] at 2:15 of <app.yay>`},
+		{"a:\n  b:\n    c: 300\n", new(map[string]map[string]map[string]int8), `Integer 300 overflows int8 at .a.b.c at 3:5 of <app.yay>`},
+		{"- - 1\n  - 2\n- - 3\n  - \"4\"\n", new([][2]int), `Cannot decode "4" into int at .[1][1] at 4:5 of <app.yay>`},
+		{"- [1]\n- [2, 3]\n", new([][1]int), `Cannot decode an array of 2 items into [1]int at .[1] at 2:3 of <app.yay>`},
+		{"- a: \"x\"\n", new([]map[string]int), `Cannot decode "x" into int at .[0].a at 1:3 of <app.yay>`},
+		{"- a: 1\n  b: \"x\"\n", new([]map[string]int), `Cannot decode "x" into int at .[0].b at 2:3 of <app.yay>`},
+		{"list:\n  - 1\n  - \"two\"\n", new(map[string]*[]*int), `Cannot decode "two" into int at .list[1] at 3:5 of <app.yay>`},
+		{"\"a\": 1\n\"b c\": true\n", new(map[string]int), `Cannot decode true into int at .["b c"] at 2:1 of <app.yay>`},
+		{"true\n", new([]string), `Cannot decode true into []string at 1:1 of <app.yay>`},
+	} {
+		err := NewDecoderWithOptions(strings.NewReader(c.source), DecodeOptions{Filename: "app.yay"}).Decode(c.target)
+		if c.want == "" {
+			if err != nil {
+				t.Errorf("%q into %T: got %v", c.source, c.target, err)
+			}
+		} else if err == nil || err.Error() != c.want {
+			t.Errorf("%q into %T: got %v, want %q", c.source, c.target, err, c.want)
+		}
+	}
+
+	// Without a filename, the position is in the error but not its message.
+	err = NewDecoder(strings.NewReader(source)).Decode(&v)
+	if !errors.As(err, &de) || err.Error() != `Cannot decode "http" into uint16 at .ports[1]` || de.Line != 3 || de.Column != 5 {
+		t.Errorf("without a filename: got %v", err)
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	type Server struct {
+		Host string `yay:"host"`
+		Port int    `yay:"port"`
+	}
+	type Config struct {
+		Server Server `yay:"server"`
+	}
+	source := "server:\n  host: \"example.com\"\n  prot: 8080\n"
+
+	var lax Config
+	if err := NewDecoder(strings.NewReader(source)).Decode(&lax); err != nil || lax.Server.Host != "example.com" {
+		t.Errorf("lax: got %+v, %v", lax, err)
+	}
+
+	var strict Config
+	dec := NewDecoderWithOptions(strings.NewReader(source), DecodeOptions{Filename: "app.yay"})
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&strict)
+	const want = `Unknown field "prot" in yay.Server at .server.prot (field Server) at 3:3 of <app.yay>`
+	if !errors.Is(err, ErrUnknownField) || err.Error() != want {
+		t.Errorf("strict: got %v, want %q", err, want)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Line != 3 || de.Column != 3 {
+		t.Errorf("strict: got %#v", err)
+	}
+
+	// Maps take any key.
+	var m map[string]map[string]any
+	err = NewDecoderWithOptions(strings.NewReader(source), DecodeOptions{DisallowUnknownFields: true}).Decode(&m)
+	if err != nil || len(m["server"]) != 2 {
+		t.Errorf("map: got %#v, %v", m, err)
+	}
+}
+
+func TestJSONConversion(t *testing.T) {
+	src := "name: \"app\\n\\\"x\\\"\"\nport: 8080\nid: 123456789012345678901234567890\nratio: 2.0\nkey: <cafe>\nlimits: [nan, -infinity, 1.0e+300]\nempty: {}\nnone: []\n"
+	out, err := ToJSON([]byte(src))
+	want := `{"name":"app\n\"x\"","port":8080,"id":123456789012345678901234567890,"ratio":2.0,"key":"yv4=","limits":[null,null,1e+300],"empty":{},"none":[]}`
+	if err != nil || string(out) != want {
+		t.Errorf("ToJSON:\ngot:  %s, %v\nwant: %s", out, err, want)
+	}
+	if !json.Valid(out) {
+		t.Errorf("ToJSON wrote invalid JSON: %s", out)
+	}
+	back, err := FromJSON(out)
+	want = "name: \"app\\n\\\"x\\\"\"\nport: 8080\nid: 123456789012345678901234567890\nratio: 2.0\nkey: \"yv4=\"\nlimits: [null, null, 1.0e+300]\nempty: {}\nnone: []\n"
+	if err != nil || string(back) != want {
+		t.Errorf("FromJSON:\ngot:  %q, %v\nwant: %q", back, err, want)
+	}
+
+	opts := JSONOptions{IntegerStrings: true, Bytes: BytesHex}
+	out, err = ToJSONWithOptions([]byte("- 9007199254740992\n- -9007199254740993\n- <cafe>\n"), opts)
+	if want := `[9007199254740992,"-9007199254740993","cafe"]`; err != nil || string(out) != want {
+		t.Errorf("ToJSONWithOptions: got %s, %v; want %s", out, err, want)
+	}
+	back, err = FromJSONWithOptions([]byte(`["-9007199254740993", "9007199254740992", "007199254740993123", 1.5]`), opts)
+	if want := "[-9007199254740993, \"9007199254740992\", \"007199254740993123\", 1.5]\n"; err != nil || string(back) != want {
+		t.Errorf("FromJSONWithOptions: got %q, %v; want %q", back, err, want)
+	}
+
+	for _, bad := range []string{"{", "[1] [2]", "1e999", `{"a": }`} {
+		if _, err := FromJSON([]byte(bad)); err == nil {
+			t.Errorf("FromJSON %q: no error", bad)
+		}
+	}
+	_, err = FromJSONWithOptions([]byte("{\"a\": [1,\n  1e400]}"), JSONOptions{Filename: "app.json"})
+	if want := "Float overflow at 2:3 of <app.json>"; err == nil || err.Error() != want || ErrorCode(err) != "float-overflow" {
+		t.Errorf("FromJSON overflow: got %v, want %s", err, want)
+	}
+	if _, err := ToJSON([]byte("a:\t1\n")); err == nil {
+		t.Error("ToJSON accepted an invalid document")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	type Node struct {
+		Name     string  `yay:"name"`
+		Children []*Node `yay:"children,omitempty"`
+	}
+	type Config struct {
+		Port  uint16            `yay:"port,min=1"`
+		Mode  string            `yay:"mode,oneof=dev|prod"`
+		Level int               `yay:"level,oneof=1|2"`
+		Key   []byte            `yay:"key"`
+		Tags  map[string]string `yay:"tags,max=4"`
+		Tree  *Node             `yay:"tree"`
+		Extra any               `yay:"extra"`
+		Note  sql.NullString    `yay:"note"`
+	}
+	schema, err := JSONSchema((*Config)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$defs":{"Node":{"properties":{"children":{"items":{"$ref":"#/$defs/Node"},"type":"array"},"name":{"type":"string"}},"required":["name"],"type":"object"}},` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"properties":{"extra":{},"key":{"contentEncoding":"base64","type":"string"},"level":{"enum":[1,2],"type":"integer"},` +
+		`"mode":{"enum":["dev","prod"],"type":"string"},"note":{"type":["string","null"]},"port":{"minimum":1,"type":"integer"},` +
+		`"tags":{"additionalProperties":{"type":"string"},"maxProperties":4,"type":"object"},"tree":{"anyOf":[{"$ref":"#/$defs/Node"},{"type":"null"}]}},` +
+		`"required":["port","mode","level","key","tags"],"title":"Config","type":"object"}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if _, err := Marshal(schema); err != nil {
+		t.Errorf("Marshal: %v", err)
+	}
+
+	if _, err := JSONSchema(map[int]string{}); err == nil || err.Error() != "Cannot describe map[int]string in a schema, for its keys are not strings" {
+		t.Errorf("int keys: got %v", err)
+	}
+	type Malformed struct {
+		N int `yay:"n,max=x"`
+	}
+	if _, err := JSONSchema(Malformed{}); err == nil || err.Error() != `Invalid constraint "max=x" on field n of yay.Malformed` {
+		t.Errorf("malformed: got %v", err)
+	}
+}
+
+func TestMarshalErrorPaths(t *testing.T) {
+	bad := errors.New("bad")
+	var codecs Codecs
+	AddCodec(&codecs, func(p point) (any, error) { return nil, bad }, nil)
+	for _, c := range []struct {
+		v    any
+		want string
+	}{
+		{make(chan int), "Cannot encode chan int"},
+		{map[string]any{"servers": []any{0, 1, 2, map[string]any{"handler": func() {}}}}, "Cannot encode func() at .servers[3].handler"},
+		{NewOrderedMap(Member{"odd key", map[int]string{}}), `Cannot encode map[int]string at .["odd key"]: its keys are not strings`},
+		{[]any{Some(point{})}, "Cannot encode yay.point at .[0]: bad"},
+	} {
+		_, err := MarshalWithOptions(c.v, EncodeOptions{Codecs: &codecs})
+		if err == nil || err.Error() != c.want {
+			t.Errorf("got %v, want %q", err, c.want)
+		}
+	}
+	_, err := MarshalWithOptions(point{}, EncodeOptions{Codecs: &codecs})
+	if !errors.Is(err, bad) {
+		t.Errorf("codec error: got %v", err)
+	}
+}
+
+func TestNullable(t *testing.T) {
+	doc := []any{
+		Some("a"), Optional[int]{},
+		sql.NullString{String: "b", Valid: true}, sql.NullInt64{},
+		sql.NullTime{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+		Some(Some(true)),
+	}
+	got, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- \"a\"\n- null\n- \"b\"\n- null\n- \"2024-01-02T03:04:05Z\"\n- true\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	decode := func(source string, target any) error {
+		return NewDecoder(strings.NewReader(source)).Decode(target)
+	}
+	var port Optional[uint16]
+	if err := decode("8080\n", &port); err != nil || port != Some[uint16](8080) {
+		t.Errorf("Optional: got %v, %v", port, err)
+	}
+	if err := decode("null\n", &port); err != nil || port.Valid {
+		t.Errorf("Optional from null: got %v, %v", port, err)
+	}
+	if err := decode("70000\n", &port); err == nil || err.Error() != "Integer 70000 overflows uint16" {
+		t.Errorf("Optional out of range: got %v", err)
+	}
+	var ratio Optional[*float32]
+	if err := decode("1\n", &ratio); err != nil || !ratio.Valid || *ratio.Value != 1 {
+		t.Errorf("Optional pointer: got %v, %v", ratio, err)
+	}
+	var name sql.NullString
+	if err := decode("\"c\"\n", &name); err != nil || name != (sql.NullString{String: "c", Valid: true}) {
+		t.Errorf("sql.NullString: got %v, %v", name, err)
+	}
+	if err := decode("1.5\n", &name); err == nil || err.Error() != "Cannot decode 1.5 into string" {
+		t.Errorf("sql.NullString from a float: got %v", err)
+	}
+	var when sql.NullTime
+	if err := decode("\"2024-01-02T03:04:05Z\"\n", &when); err != nil || !when.Valid || when.Time.Year() != 2024 {
+		t.Errorf("sql.NullTime: got %v, %v", when, err)
+	}
+	if got := port.Or(80); got != 80 {
+		t.Errorf("Or: got %d", got)
+	}
+
+	type Config struct {
+		Port Optional[int] `yay:"port,min=1"`
+	}
+	if err := ValidateStruct(Config{}); err != nil {
+		t.Errorf("empty Optional: got %v", err)
+	}
+	if err := ValidateStruct(Config{Port: Some(0)}); err == nil || err.Error() != "Constraint not met: .port is 0, less than the minimum 1" {
+		t.Errorf("Optional constraint: got %v", err)
+	}
+	schema, err := JSONSchema(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, required := schema["required"]; required || !reflect.DeepEqual(schema["properties"], map[string]any{"port": map[string]any{"type": []any{"integer", "null"}, "minimum": big.NewInt(1)}}) {
+		t.Errorf("schema: got %v", schema)
+	}
+}
+
+func TestOmitEmpty(t *testing.T) {
+	type Inner struct {
+		N int `yay:"n,omitempty"`
+	}
+	type Doc struct {
+		S     string            `yay:"s,omitempty"`
+		I     int               `yay:"i,omitempty"`
+		U     uint8             `yay:"u,omitempty"`
+		F     float64           `yay:"f,omitempty"`
+		B     bool              `yay:"b,omitempty"`
+		L     []string          `yay:"l,omitempty"`
+		M     map[string]int    `yay:"m,omitempty"`
+		P     *int              `yay:"p,omitempty"`
+		O     Optional[string]  `yay:"o,omitempty"`
+		A     [0]int            `yay:"a,omitempty"`
+		Inner Inner             `yay:"inner,omitempty"`
+		Kept  string            `yay:"kept"`
+		Empty map[string]string `yay:",omitempty"`
+	}
+	got, err := Marshal(Doc{L: []string{}, M: map[string]int{}})
+	if want := "inner: {}\nkept: \"\"\n"; err != nil || string(got) != want {
+		t.Errorf("empty: got %q, %v; want %q", got, err, want)
+	}
+	zero := 0
+	got, err = Marshal(Doc{I: -1, P: &zero, O: Some(""), L: []string{""}, Inner: Inner{N: 1}})
+	if want := "i: -1\nl: [\"\"]\np: 0\no: \"\"\ninner: {n: 1}\nkept: \"\"\n"; err != nil || string(got) != want {
+		t.Errorf("full: got %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestOmitNull(t *testing.T) {
+	doc := map[string]any{
+		"a":    nil,
+		"b":    (*big.Int)(nil),
+		"c":    Optional[string]{},
+		"d":    (*url.URL)(nil),
+		"e":    1,
+		"list": []any{nil, 2},
+		"ordered": NewOrderedMap(
+			Member{"x", nil},
+			Member{"y", Some(3)},
+			Member{"z", map[string]any{"gone": nil}},
+		),
+	}
+	got, err := MarshalWithOptions(doc, EncodeOptions{OmitNull: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "e: 1\nlist: [null, 2]\nordered:\n  y: 3\n  z: {}\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, present := doc["a"]; !present {
+		t.Errorf("the value given to Marshal was changed")
+	}
+	got, err = Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "a: null\nb: null\nc: null\nd: null\n") {
+		t.Errorf("without OmitNull: got %q", got)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	doc := map[string]any{
+		"database": map[string]any{"host": "db", "password": "hunter2"},
+		"users":    []any{map[string]any{"name": "ann", "token": Some("t0k3n")}},
+		"ordered":  NewOrderedMap(Member{"password", []byte("secret")}),
+		"weird":    map[string]any{"api key": "k"},
+	}
+	var paths []string
+	got, err := MarshalWithOptions(doc, EncodeOptions{Redact: func(path string) bool {
+		paths = append(paths, path)
+		return RedactKeys("password", "token", "api key")(path)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "database: {host: \"db\", password: \"[redacted]\"}\n" +
+		"ordered: {password: \"[redacted]\"}\n" +
+		"users:\n  - {name: \"ann\", token: \"[redacted]\"}\n" +
+		"weird:\n  \"api key\": \"[redacted]\"\n"
+	if string(got) != want {
+		t.Errorf("got:  %q\nwant: %q", got, want)
+	}
+	if !slices.Contains(paths, ".users[0].token") || !slices.Contains(paths, `.weird["api key"]`) {
+		t.Errorf("paths: %q", paths)
+	}
+	if doc["database"].(map[string]any)["password"] != "hunter2" {
+		t.Errorf("the value given to Marshal was changed")
+	}
+	if got, err := MarshalWithOptions("secret", EncodeOptions{Redact: func(string) bool { return true }}); err != nil || string(got) != "\"secret\"\n" {
+		t.Errorf("root: got %q, %v", got, err)
+	}
+}
+
+func TestStandardAdapters(t *testing.T) {
+	when := time.Date(2024, 5, 6, 7, 8, 9, 500, time.UTC)
+	home, _ := url.Parse("https://example.com/a?b=c")
+	doc := map[string]any{
+		"when":    when,
+		"timeout": 90 * time.Second,
+		"ip":      net.ParseIP("10.0.0.1"),
+		"addr":    netip.MustParseAddr("::1"),
+		"net":     netip.MustParsePrefix("10.0.0.0/8"),
+		"listen":  netip.MustParseAddrPort("127.0.0.1:8080"),
+		"home":    home,
+		"match":   regexp.MustCompile(`^a+$`),
+		"none":    (*url.URL)(nil),
+	}
+	got, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `addr: "::1"
+home: "https://example.com/a?b=c"
+ip: "10.0.0.1"
+listen: "127.0.0.1:8080"
+match: "^a+$"
+net: "10.0.0.0/8"
+none: null
+timeout: "1m30s"
+when: "2024-05-06T07:08:09.0000005Z"
+`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	decode := func(source string, target any) error {
+		return NewDecoder(strings.NewReader(source)).Decode(target)
+	}
+	var w time.Time
+	if err := decode(`"2024-05-06T07:08:09.0000005Z"`+"\n", &w); err != nil || !w.Equal(when) {
+		t.Errorf("time.Time: got %v, %v", w, err)
+	}
+	var d time.Duration
+	if err := decode(`"1m30s"`+"\n", &d); err != nil || d != 90*time.Second {
+		t.Errorf("time.Duration: got %v, %v", d, err)
+	}
+	var ip net.IP
+	if err := decode(`"10.0.0.1"`+"\n", &ip); err != nil || !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("net.IP: got %v, %v", ip, err)
+	}
+	var prefix netip.Prefix
+	if err := decode(`"10.0.0.0/8"`+"\n", &prefix); err != nil || prefix != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("netip.Prefix: got %v, %v", prefix, err)
+	}
+	u := home
+	if err := decode("null\n", &u); err != nil || u != nil {
+		t.Errorf("*url.URL from null: got %v, %v", u, err)
+	}
+	var re *regexp.Regexp
+	if err := decode(`"^a+$"`+"\n", &re); err != nil || !re.MatchString("aaa") {
+		t.Errorf("*regexp.Regexp: got %v, %v", re, err)
+	}
+	if err := decode("90\n", &d); err == nil || err.Error() != "Cannot decode time.Duration: Expected a string, not 90" {
+		t.Errorf("time.Duration from an integer: got %v", err)
+	}
+	if err := decode(`"ten"`+"\n", &ip); err == nil || err.Error() != `Cannot decode net.IP: Invalid IP address "ten"` {
+		t.Errorf("bad net.IP: got %v", err)
+	}
+
+	type Config struct {
+		When time.Time `yay:"when"`
+		Home *url.URL  `yay:"home"`
+	}
+	schema, err := JSONSchema(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := schema["properties"].(map[string]any)
+	if !reflect.DeepEqual(props["when"], map[string]any{"type": "string", "format": "date-time"}) ||
+		!reflect.DeepEqual(props["home"], map[string]any{"type": []any{"string", "null"}, "format": "uri-reference"}) {
+		t.Errorf("schema: got %v", props)
+	}
+}
+
+func TestTypeFields(t *testing.T) {
+	type Inner struct {
+		Shared string
+		Deep   int
+	}
+	type Other struct {
+		Shared string
+	}
+	type Outer struct {
+		Name    string `yay:"name"`
+		Skipped string `yay:"-"`
+		Count   int    `yay:"count,omitempty"`
+		private int
+		Inner
+		*Other
+	}
+
+	fields := cachedTypeFields(reflect.TypeOf(Outer{}))
+	var names []string
+	for _, f := range fields.list {
+		names = append(names, f.name)
+	}
+	// Shared is ambiguous between Inner and Other, so it is hidden.
+	want := []string{"name", "count", "Deep"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	if i := fields.byName["Deep"]; !reflect.DeepEqual(fields.list[i].index, []int{4, 1}) {
+		t.Errorf("Deep index: got %v", fields.list[i].index)
+	}
+	if !fields.list[fields.byName["count"]].omitEmpty {
+		t.Errorf("count: omitempty not recorded")
+	}
+	if again := cachedTypeFields(reflect.TypeOf(Outer{})); again != fields {
+		t.Errorf("field metadata not cached")
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	type Server struct {
+		Port  int      `yay:"port,min=1,max=65535"`
+		Host  string   `yay:"host,omitempty,min=1,pattern=^[a-z0-9.-]+$"`
+		Mode  string   `yay:"mode,oneof=dev|test|prod"`
+		Size  *big.Int `yay:"size,max=1e20"`
+		Ratio float64  `yay:"ratio,min=0,max=1"`
+		Tags  []string `yay:"tags,max=2"`
+	}
+	type Config struct {
+		Servers []Server          `yay:"servers"`
+		Primary *Server           `yay:"primary"`
+		Named   map[string]Server `yay:"named"`
+	}
+	good := Server{Port: 80, Host: "example.com", Mode: "prod", Size: big.NewInt(1), Ratio: 0.5}
+	if err := ValidateStruct(&Config{Servers: []Server{good}, Named: map[string]Server{"a": good}}); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+
+	huge, _ := new(big.Int).SetString("100000000000000000001", 10)
+	for _, c := range []struct {
+		edit func(*Server)
+		want string
+	}{
+		{func(s *Server) { s.Port = 0 }, "Constraint not met: .servers[0].port is 0, less than the minimum 1"},
+		{func(s *Server) { s.Port = 70000 }, "Constraint not met: .servers[0].port is 70000, more than the maximum 65535"},
+		{func(s *Server) { s.Host = "" }, "Constraint not met: .servers[0].host has length 0, less than the minimum 1"},
+		{func(s *Server) { s.Host = "Example.com" }, `Constraint not met: .servers[0].host is "Example.com", which does not match ^[a-z0-9.-]+$`},
+		{func(s *Server) { s.Mode = "staging" }, `Constraint not met: .servers[0].mode is "staging", not one of dev, test, prod`},
+		{func(s *Server) { s.Size = huge }, "Constraint not met: .servers[0].size is 100000000000000000001, more than the maximum 1e+20"},
+		{func(s *Server) { s.Ratio = math.NaN() }, "Constraint not met: .servers[0].ratio is NaN, less than the minimum 0"},
+		{func(s *Server) { s.Tags = []string{"a", "b", "c"} }, "Constraint not met: .servers[0].tags has length 3, more than the maximum 2"},
+	} {
+		s := good
+		c.edit(&s)
+		err := ValidateStruct(Config{Servers: []Server{s}})
+		if !errors.Is(err, ErrConstraint) || err.Error() != c.want {
+			t.Errorf("got %v, want %q", err, c.want)
+		}
+	}
+	bad := good
+	bad.Port = 0
+	if err := ValidateStruct(&Config{Primary: &bad}); err == nil || !strings.Contains(err.Error(), ".primary.port") {
+		t.Errorf("pointer: got %v", err)
+	}
+	if err := ValidateStruct(&Config{Named: map[string]Server{"odd key": bad}}); err == nil || !strings.Contains(err.Error(), `.named["odd key"].port`) {
+		t.Errorf("map: got %v", err)
+	}
+
+	type Malformed struct {
+		N int `yay:"n,min=one"`
+	}
+	if err := ValidateStruct(Malformed{}); err == nil || err.Error() != `Invalid constraint "min=one" on field n of yay.Malformed` {
+		t.Errorf("malformed: got %v", err)
+	}
+}
+
+func TestGeneratedQuick(t *testing.T) {
+	roundTrips := func(g Generated) bool {
+		out, err := Marshal(g.Value)
+		if err != nil {
+			return false
+		}
+		got, err := Unmarshal(out)
+		return err == nil && Equal(got, g.Value)
+	}
+	if err := quick.Check(roundTrips, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeTargets(t *testing.T) {
+	var obj Object
+	err := NewDecoder(strings.NewReader("b: 1\na: {d: 2, c: 3}\n")).Decode(&obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, _ := obj.Get("a")
+	if got := obj.Keys(); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("keys: got %v", got)
+	}
+	if got := inner.(*Object).Keys(); !reflect.DeepEqual(got, []string{"d", "c"}) {
+		t.Errorf("inner keys: got %v", got)
+	}
+
+	var arr Array
+	if err := NewDecoder(strings.NewReader("- 1\n- 2\n")).Decode(&arr); err != nil || arr.Len() != 2 {
+		t.Errorf("Array: got %#v, %v", arr, err)
+	}
+
+	for _, c := range []struct {
+		target any
+		source string
+		want   string
+	}{
+		{&obj, "[1, 2]\n", "Cannot decode an array of 2 items into an Object"},
+		{&arr, "a: 1\n", "Cannot decode an object of 1 properties into an Array"},
+		{new(string), "a: 1\n", "Cannot decode an object of 1 properties into string"},
+		{(*Array)(nil), "a: 1\n", "Decode needs a non-nil pointer, not *yay.Array"},
+		{0, "a: 1\n", "Decode needs a non-nil pointer, not int"},
+	} {
+		err := NewDecoder(strings.NewReader(c.source)).Decode(c.target)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%T: got %v, want %q", c.target, err, c.want)
+		}
+	}
+}
+
+func TestMarshaler(t *testing.T) {
+	type Order struct {
+		Price  money   `yay:"price"`
+		Refund *money  `yay:"refund"`
+		Label  label   `yay:"label"`
+		Items  []money `yay:"items"`
+	}
+	in := Order{Price: money{1250}, Label: label{"gift"}, Items: []money{{1}, {2}}}
+	got, err := Marshal(in)
+	want := "price: 1250\nrefund: null\nlabel: {text: \"gift\", upper: \"GIFT\"}\nitems: [1, 2]\n"
+	if err != nil || string(got) != want {
+		t.Errorf("Marshal: got %q, %v; want %q", got, err, want)
+	}
+	if _, err := Marshal(&label{}); err == nil || !strings.HasPrefix(err.Error(), "Cannot encode *yay.label: MarshalYAY returned an invalid document: ") {
+		t.Errorf("invalid document: got %v", err)
+	}
+
+	var out struct {
+		Price  money   `yay:"price"`
+		Refund *money  `yay:"refund"`
+		Items  []money `yay:"items"`
+	}
+	if err := UnmarshalInto([]byte("price: 1250\nrefund: 5\nitems: [1, 2]\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Price.cents != 1250 || out.Refund == nil || out.Refund.cents != 5 || !reflect.DeepEqual(out.Items, []money{{1}, {2}}) {
+		t.Errorf("UnmarshalInto: got %+v", out)
+	}
+	err = UnmarshalInto([]byte("price: \"free\"\n"), &out)
+	if err == nil || !strings.HasPrefix(err.Error(), "Cannot decode yay.money: ") || !strings.HasSuffix(err.Error(), " at .price (field Price)") {
+		t.Errorf("mismatch: got %v", err)
+	}
+}
+
+func TestTextMarshaler(t *testing.T) {
+	type Recipe struct {
+		Ratio *big.Rat `yay:"ratio"`
+		Scale big.Rat  `yay:"scale"`
+		Unset *big.Rat `yay:"unset"`
+	}
+	in := Recipe{Ratio: big.NewRat(1, 3), Scale: *big.NewRat(3, 2)}
+	got, err := Marshal(in)
+	if want := "{ratio: \"1/3\", scale: \"3/2\", unset: null}\n"; err != nil || string(got) != want {
+		t.Errorf("Marshal: got %q, %v; want %q", got, err, want)
+	}
+	var out Recipe
+	if err := UnmarshalInto(got, &out); err != nil || out.Ratio.Cmp(in.Ratio) != 0 || out.Scale.Cmp(&in.Scale) != 0 || out.Unset != nil {
+		t.Errorf("UnmarshalInto: got %+v, %v", out, err)
+	}
+	for source, want := range map[string]string{
+		"scale: \"1.5e\"\n": "Cannot decode big.Rat: ",
+		"scale: [1, 2]\n":   "Cannot decode an array of 2 items into big.Rat at .scale (field Scale)",
+	} {
+		if err := UnmarshalInto([]byte(source), &out); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", source, err, want)
+		}
+	}
+}
+
+func TestPositionFields(t *testing.T) {
+	type Server struct {
+		Position
+		Host string `yay:"host"`
+		Port int    `yay:"port"`
+	}
+	type Config struct {
+		At      *Position `yay:"at"`
+		Name    string    `yay:"name"`
+		Servers []Server  `yay:"servers"`
+	}
+	source := "name: \"app\"\nservers:\n  - host: \"a\"\n    port: 80\n  - host: \"b\"\n    port: \"x\"\n"
+	var c Config
+	err := NewDecoderWithOptions(strings.NewReader(source), DecodeOptions{Filename: "app.yay"}).Decode(&c)
+	if want := `Cannot decode "x" into int at .servers[1].port (field Servers[1].Port) at 6:5 of <app.yay>`; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+	source = strings.Replace(source, `"x"`, "443", 1)
+	c = Config{}
+	if err := NewDecoderWithOptions(strings.NewReader(source), DecodeOptions{Filename: "app.yay"}).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "app" || len(c.Servers) != 2 || c.Servers[1].Host != "b" || c.Servers[1].Port != 443 {
+		t.Errorf("got %+v", c)
+	}
+	if c.At == nil || *c.At != (Position{Filename: "app.yay", Line: 1, Column: 1}) {
+		t.Errorf("root: got %v", c.At)
+	}
+	if got := c.Servers[1].Position; got != (Position{Filename: "app.yay", Line: 5, Column: 5, Offset: 52}) || got.String() != "5:5 of <app.yay>" {
+		t.Errorf("item: got %+v", got)
+	}
+	if got := c.Servers[0].Position.String(); got != "3:5 of <app.yay>" {
+		t.Errorf("item: got %s", got)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	type Level string
+	type Limits struct {
+		Burst uint16 `yay:"burst"`
+	}
+	type Service struct {
+		Position
+		Limits
+		Name    string            `yay:"name"`
+		Level   Level             `yay:"level"`
+		Ports   []int             `yay:"ports"`
+		Labels  map[string]string `yay:"labels"`
+		Backup  *Service          `yay:"backup"`
+		Weight  float32
+		Key     [2]byte `yay:"key"`
+		Secret  string  `yay:"-"`
+		private int
+	}
+	in := Service{
+		Limits: Limits{Burst: 10},
+		Name:   "api",
+		Level:  "debug",
+		Ports:  []int{80, 443},
+		Labels: map[string]string{"team": "web"},
+		Backup: &Service{Name: "standby"},
+		Weight: 0.5,
+		Key:    [2]byte{0xca, 0xfe},
+		Secret: "hunter2",
+	}
+	got, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "burst: 10\nname: \"api\"\nlevel: \"debug\"\nports: [80, 443]\nlabels: {team: \"web\"}\n" +
+		"backup:\n  burst: 0\n  name: \"standby\"\n  level: \"\"\n  ports: null\n  labels: null\n  backup: null\n  Weight: 0.0\n  key: <0000>\n" +
+		"Weight: 0.5\nkey: <cafe>\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	var out Service
+	if err := UnmarshalInto(got, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Secret, out.Position, out.Backup.Position = "", Position{}, Position{}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip:\ngot:  %+v\nwant: %+v", out, in)
+	}
+	if err := UnmarshalInto([]byte("name: 1\n"), &out); err == nil || err.Error() != "Cannot decode 1 into string at .name (field Name)" {
+		t.Errorf("got %v", err)
+	}
+	if err := UnmarshalInto([]byte("1\n"), out); err == nil {
+		t.Error("UnmarshalInto accepted a non-pointer")
+	}
+
+	if got, err := Marshal(struct{}{}); err != nil || string(got) != "{}\n" {
+		t.Errorf("empty struct: got %q, %v", got, err)
+	}
+}
+
+func TestMarshalCycles(t *testing.T) {
+	type node struct {
+		Name string `yay:"name"`
+		Next *node  `yay:"next"`
+		Kids []any  `yay:"kids"`
+	}
+	self := &node{Name: "a"}
+	self.Next = self
+	m := map[string]any{"a": 1}
+	m["m"] = m
+	list := []any{1, nil}
+	list[1] = list
+	obj := NewOrderedMap()
+	obj.Set("obj", obj)
+	for _, c := range []struct {
+		v    any
+		want string
+	}{
+		{self, "Cannot encode a value that encountered a cycle via *yay.node"},
+		{m, "Cannot encode a value that encountered a cycle via map[string]interface {}"},
+		{list, "Cannot encode a value that encountered a cycle via []interface {}"},
+		{obj, "Cannot encode a value that encountered a cycle via *yay.OrderedMap"},
+		{&node{Kids: []any{m}}, "Cannot encode a value that encountered a cycle via map[string]interface {}"},
+	} {
+		_, err := Marshal(c.v)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("got %v, want %q", err, c.want)
+		}
+	}
+
+	// A value shared, but not within itself, is no cycle, however deep.
+	shared := []any{"x"}
+	var deep any = []any{shared, shared}
+	for i := 0; i < 2000; i++ {
+		deep = []any{deep, shared}
+	}
+	if _, err := Marshal(deep); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONCompatible(t *testing.T) {
+	source := []byte("a: 1\nb: [2.5, true, null]\nc: <cafe>\nd: {e: \"x\"}\nbig: 12345678901234567890\n")
+	const equivalent = `{"a": 1, "b": [2.5, true, null], "c": "yv4=", "d": {"e": "x"}, "big": 12345678901234567890}`
+
+	for _, useNumber := range []bool{false, true} {
+		got, err := UnmarshalWithOptions(source, DecodeOptions{JSONCompatible: true, UseNumber: useNumber})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(strings.NewReader(equivalent))
+		if useNumber {
+			dec.UseNumber()
+		}
+		var want any
+		if err := dec.Decode(&want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UseNumber %v:\ngot:  %#v\nwant: %#v", useNumber, got, want)
+		}
+	}
+
+	got, err := UnmarshalWithOptions([]byte("[nan, 1.0e400]\n"), DecodeOptions{JSONCompatible: true, UseNumber: true, SaturateFloats: true})
+	if items, _ := got.([]any); err != nil || len(items) != 2 || !math.IsNaN(items[0].(float64)) || !math.IsInf(items[1].(float64), 1) {
+		t.Errorf("non-finite: got %#v, %v", got, err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var stats []DecodeStats
+	opts := DecodeOptions{
+		Filename: "test.yay",
+		MaxDepth: 1,
+		Recorder: RecorderFunc(func(s DecodeStats) { stats = append(stats, s) }),
+	}
+	sources := []string{"a: 1\n", "a: {b: 1}\n", "a: [\n", "#!yay 99\na: 1\n"}
+	for _, source := range sources {
+		UnmarshalWithOptions([]byte(source), opts)
+	}
+	var n int
+	NewDecoderWithOptions(strings.NewReader("\"x\"\n"), opts).Decode(&n)
+	codes := []string{"", "too-deep", "unterminated-inline-array", "unsupported-version", CodeType}
+	if len(stats) != len(codes) {
+		t.Fatalf("got %d measurements, want %d", len(stats), len(codes))
+	}
+	for i, s := range stats {
+		if s.Code != codes[i] || (s.Err == nil) != (codes[i] == "") || s.Filename != "test.yay" {
+			t.Errorf("%d: got %+v, want code %q", i, s, codes[i])
+		}
+		if i < len(sources) && s.Bytes != len(sources[i]) {
+			t.Errorf("%d: got %d bytes, want %d", i, s.Bytes, len(sources[i]))
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	tokens := func(source string) string {
+		dec := NewDecoder(strings.NewReader(source))
+		var out []string
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				if err != io.EOF {
+					out = append(out, "error: "+err.Error())
+				}
+				return strings.Join(out, " ")
+			}
+			out = append(out, fmt.Sprint(tok))
+		}
+	}
+	for _, tc := range []struct{ source, want string }{
+		{"b: 1\na:\n  - 2\n  - {c: 3}\nd: \"text\"\n", "{ b 1 a [ 2 { c 3 } ] d text }"},
+		{"- 1\n- a: 2\n  b: <ff>\n- [true, null]\n", "[ 1 { a 2 b [255] } [ true <nil> ] ]"},
+		{"- 1\n- {a: 2}\n", "[ 1 { a 2 } ]"},
+		{"42\n", "42"},
+		{"a: 1\nb: [1 ]\n", `{ a 1 error: Unexpected space before "]"`},
+	} {
+		if got := tokens(tc.source); got != tc.want {
+			t.Errorf("Token(%q): got %q, want %q", tc.source, got, tc.want)
+		}
+	}
+
+	// Decode takes the members of a root array one at a time.
+	type record struct {
+		Name string `yay:"name"`
+	}
+	dec := NewDecoder(strings.NewReader("- name: \"a\"\n- name: \"b\"\n- extra: 1\n"))
+	if tok, err := dec.Token(); tok != Delim('[') || err != nil {
+		t.Fatalf("first token: got %v, %v", tok, err)
+	}
+	var names []string
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		names = append(names, r.Name)
+	}
+	if fmt.Sprint(names) != "[a b ]" {
+		t.Errorf("names: got %q", names)
+	}
+	if tok, err := dec.Token(); tok != Delim(']') || err != nil {
+		t.Errorf("last token: got %v, %v", tok, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		t.Errorf("after the last token: got %v, want io.EOF", err)
+	}
+
+	// Values decoded into *any have unordered objects, and a key must be
+	// taken with Token.
+	dec = NewDecoder(strings.NewReader("a:\n  b: 1\n"))
+	dec.Token()
+	var v any
+	if err := dec.Decode(&v); err == nil {
+		t.Error("Decode at a key: no error")
+	}
+	dec.Token()
+	if err := dec.Decode(&v); err != nil || !Equal(v, map[string]any{"b": big.NewInt(1)}) {
+		t.Errorf("Decode after a key: got %#v, %v", v, err)
+	}
+
+	// A syntax error is returned by every call after it is reached.
+	dec = NewDecoder(strings.NewReader("- 1\n- [1 ]\n"))
+	dec.Token()
+	dec.Token()
+	_, err := dec.Token()
+	if _, again := dec.Token(); err == nil || again != err || !dec.More() {
+		t.Errorf("syntax error: got %v, then %v", err, again)
+	}
+}
+
+func TestMust(t *testing.T) {
+	v := MustUnmarshal([]byte("a: [1, 2]\n"))
+	if got := string(MustMarshal(v)); got != "a: [1, 2]\n" {
+		t.Errorf("got %q", got)
+	}
+
+	mustPanic := func(name, want string, f func()) {
+		t.Helper()
+		defer func() {
+			err, _ := recover().(error)
+			if err == nil || err.Error() != want {
+				t.Errorf("%s: got panic %v, want %q", name, err, want)
+			}
+		}()
+		f()
+	}
+	mustPanic("MustUnmarshal", "Unexpected character \"}\"", func() { MustUnmarshal([]byte("}\n")) })
+	mustPanic("MustMarshal", "Cannot encode chan int", func() { MustMarshal(make(chan int)) })
+}
@@ -0,0 +1,302 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// ============================================================================
+// JSON Schema
+// ============================================================================
+//
+// JSONSchema describes a configuration type as a JSON Schema (draft
+// 2020-12), so that a team can publish the contract for its configuration
+// and editors and validators can check documents against it. The schema
+// follows the same fields, keys, and tag constraints as ValidateStruct:
+//
+//   - Booleans, strings, integers (including *big.Int), and floats map to
+//     "boolean", "string", "integer", and "number". Unsigned integers have a
+//     minimum of 0.
+//   - Byte arrays map to base64 strings, as JSONCompatible decodes them.
+//   - The standard library types with built-in codecs, such as time.Time,
+//     map to strings, with a "format" where there is one that fits.
+//   - Slices and arrays map to "array", string-keyed maps to "object" with
+//     additionalProperties, and any, Object, and Array to the most general
+//     schema that fits.
+//   - Optional and the Null types of database/sql map to the schema of
+//     their values.
+//   - Structs map to "object" with a property for each field. Fields that
+//     are pointers, interfaces such as any, or nullable, may be null, so
+//     their schemas admit null and they are not required; the other fields
+//     are required unless they have omitempty. Named struct types are
+//     described once under "$defs" and referred to by "$ref", which also
+//     serves recursive types.
+//   - min and max become minimum and maximum, or minLength, minItems, and
+//     so on, as the field's type calls for; pattern becomes pattern; and
+//     oneof becomes enum.
+//
+// The schema is a value of the kind Unmarshal returns, so it can be written
+// out with encoding/json or, as a YAY document, with Marshal.
+
+// JSONSchemaURI is the "$schema" of the schemas JSONSchema makes.
+const JSONSchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns a JSON Schema for the type of v, typically a struct or
+// a pointer to one, which may be nil, as in JSONSchema((*Config)(nil)).
+// Types that have no place in a document, such as channels, functions, and
+// maps without string keys, are an error, as are malformed constraints.
+//
+//	schema, err := yay.JSONSchema((*Config)(nil))
+//	data, err := json.MarshalIndent(schema, "", "  ")
+func JSONSchema(v any) (map[string]any, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("JSONSchema needs a value of some type, not nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	s := &schemaBuilder{
+		defs:  make(map[string]any),
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]bool),
+	}
+	var root map[string]any
+	var err error
+	if t.Kind() == reflect.Struct {
+		// The named root is described in place, but still gets a name so
+		// that recursive references to it resolve to "#".
+		s.names[t] = ""
+		root, err = s.structSchema(t)
+	} else {
+		root, err = s.schema(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema := map[string]any{"$schema": JSONSchemaURI}
+	for k, v := range root {
+		schema[k] = v
+	}
+	if t.Name() != "" {
+		schema["title"] = t.Name()
+	}
+	if len(s.defs) > 0 {
+		schema["$defs"] = s.defs
+	}
+	return schema, nil
+}
+
+// schemaBuilder holds the named struct types described so far.
+type schemaBuilder struct {
+	defs  map[string]any          // Schemas of named structs by def name
+	names map[reflect.Type]string // Def names by type; "" for the root
+	taken map[string]bool         // Def names in use
+}
+
+var (
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+	arrayType  = reflect.TypeOf(Array(nil))
+	objectType = reflect.TypeOf((*Object)(nil))
+)
+
+// schema returns the schema for a value of type t.
+func (s *schemaBuilder) schema(t reflect.Type) (map[string]any, error) {
+	switch t {
+	case bigIntType:
+		return map[string]any{"type": "integer"}, nil
+	case arrayType:
+		return map[string]any{"type": "array"}, nil
+	case objectType:
+		return map[string]any{"type": "object"}, nil
+	}
+	if _, ok := builtinCodecs[t]; ok {
+		schema := map[string]any{"type": "string"}
+		if format := builtinFormats[t]; format != "" {
+			schema["format"] = format
+		}
+		return schema, nil
+	}
+	if isNullable(t) {
+		return s.schema(t.Field(0).Type)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": big.NewInt(0)}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Pointer:
+		return s.schema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			schema["minItems"] = big.NewInt(int64(t.Len()))
+			schema["maxItems"] = big.NewInt(int64(t.Len()))
+		}
+		return schema, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("Cannot describe %s in a schema, for its keys are not strings", t)
+		}
+		values, err := s.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return s.ref(t)
+	}
+	return nil, fmt.Errorf("Cannot describe %s in a schema", t)
+}
+
+// ref returns a reference to the schema for named struct type t, adding
+// it to the defs on first use.
+func (s *schemaBuilder) ref(t reflect.Type) (map[string]any, error) {
+	if name, ok := s.names[t]; ok {
+		if name == "" {
+			return map[string]any{"$ref": "#"}, nil
+		}
+		return map[string]any{"$ref": "#/$defs/" + escapeToken(name)}, nil
+	}
+	name := t.Name()
+	for i := 2; s.taken[name]; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+	s.names[t], s.taken[name] = name, true
+	schema, err := s.structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	s.defs[name] = schema
+	return map[string]any{"$ref": "#/$defs/" + escapeToken(name)}, nil
+}
+
+// structSchema returns the schema for the fields of struct type t.
+func (s *schemaBuilder) structSchema(t reflect.Type) (map[string]any, error) {
+	fields := cachedTypeFields(t)
+	properties := make(map[string]any, len(fields.list))
+	var required []any
+	for i := range fields.list {
+		f := &fields.list[i]
+		if f.constraintErr != nil {
+			return nil, fmt.Errorf("%w on field %s of %s", f.constraintErr, f.name, t)
+		}
+		schema, err := s.schema(f.typ)
+		if err != nil {
+			return nil, err
+		}
+		if f.constraints != nil {
+			schema = constrainSchema(schema, f.constraints, f.typ)
+		}
+		nullable := f.typ.Kind() == reflect.Pointer || f.typ.Kind() == reflect.Interface || isNullable(f.typ)
+		if nullable {
+			schema = allowNull(schema)
+		}
+		properties[f.name] = schema
+		if !f.omitEmpty && !nullable {
+			required = append(required, f.name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// allowNull returns schema widened to admit null, as a field that may be
+// null needs.
+func allowNull(schema map[string]any) map[string]any {
+	if len(schema) == 0 {
+		return schema // Admits everything already
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		schema["enum"] = append(enum, nil)
+	}
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []any{t, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// constrainSchema adds constraints c, of a field of type t, to its schema.
+// A reference is wrapped with allOf, since its siblings may not be
+// honored by older validators.
+func constrainSchema(schema map[string]any, c *constraints, t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer && t != bigIntType {
+		t = t.Elem()
+	}
+	if _, ok := schema["$ref"]; ok {
+		schema = map[string]any{"allOf": []any{schema}}
+	}
+	minKey, maxKey := "minimum", "maximum"
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+		if t.Elem().Kind() == reflect.Uint8 {
+			// The length of a byte array is not that of its base64 form.
+			minKey, maxKey = "", ""
+		}
+	case reflect.Map:
+		minKey, maxKey = "minProperties", "maxProperties"
+	}
+	if c.hasMin && minKey != "" {
+		schema[minKey] = schemaNumber(c.min)
+	}
+	if c.hasMax && maxKey != "" {
+		schema[maxKey] = schemaNumber(c.max)
+	}
+	if c.pattern != nil {
+		schema["pattern"] = c.patternText
+	}
+	if c.oneOf != nil {
+		enum := make([]any, len(c.oneOf))
+		for i, text := range c.oneOf {
+			enum[i] = text
+			if t.Kind() != reflect.String {
+				if n, ok := new(big.Int).SetString(text, 10); ok {
+					enum[i] = n
+				} else if f, err := strconv.ParseFloat(text, 64); err == nil {
+					enum[i] = f
+				}
+			}
+		}
+		schema["enum"] = enum
+	}
+	return schema
+}
+
+// schemaNumber returns n as an integer if it is one, or as a float.
+func schemaNumber(n *big.Float) any {
+	if n.IsInt() {
+		i, _ := n.Int(nil)
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
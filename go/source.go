@@ -0,0 +1,411 @@
+package yay
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Source Transforms
+// ============================================================================
+//
+// These functions rewrite the text of a document rather than its value,
+// keeping what decoding and re-encoding would lose, such as the author's
+// layout. They check that the document is valid first, so that they never
+// have to guess at what a malformed line means.
+
+// StripComments returns src without its comments, for delivering a richly
+// commented document to machines that need only its value. Comments on
+// lines of their own are removed with their lines, comments after values
+// are trimmed from theirs, and every other line, including blank lines and
+// the text of block strings, is left as it was. Only a "#" at the margin
+// begins a comment line: an indented line that begins with "#" is content
+// to the parser, as "# inner" is a key in "outer:\n # inner: {}", and is
+// kept. A version directive is not a comment for this purpose, and is
+// kept too.
+//
+// The one line StripComments must change beyond removing a comment is a
+// block byte array whose ">" has a comment alone: the comment is replaced
+// with the first line of hex, or the ">" with "<>" if there is none.
+func StripComments(src []byte) ([]byte, error) {
+	want, err := Unmarshal(src)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(src), "\n")
+	out := make([]string, 0, len(lines))
+	body := -1     // Indent that the text lines of a block string are deeper than
+	hex := -1      // Index in out of a ">" awaiting its first line of hex
+	hexIndent := 0 // Indent of that ">" line
+	hexBody := -1  // Indent that the hex lines of block bytes are deeper than
+	closeHex := func() {
+		if hex >= 0 {
+			out[hex] = strings.TrimSuffix(out[hex], ">") + "<>"
+			hex = -1
+		}
+	}
+	for i, line := range lines {
+		indent := countIndent(line)
+		rest := line[indent:]
+		if i == 0 && strings.HasPrefix(line, versionPrefix) {
+			if v, _ := checkVersion(line, nil); v > 0 {
+				out = append(out, line)
+				continue
+			}
+		}
+		// The scanner skips comments at the margin even within a block.
+		if indent == 0 && strings.HasPrefix(rest, "#") {
+			continue
+		}
+		if body >= 0 {
+			if rest == "" || indent > body {
+				out = append(out, line)
+				continue
+			}
+			body = -1
+		}
+		if rest == "" {
+			out = append(out, line)
+			continue
+		}
+
+		lead := indent
+		for strings.HasPrefix(line[lead:], "- ") {
+			lead += 2
+		}
+		content := line[lead:]
+		if hexBody >= 0 && indent <= hexBody {
+			hexBody = -1
+		}
+		if content[0] == '#' && hexBody < 0 {
+			// Not at the margin nor among hex, so not a comment.
+			out = append(out, line)
+			continue
+		}
+		if content[0] == '`' {
+			// Text after the backtick is the first line of the string.
+			out = append(out, line)
+			body = indent
+			continue
+		}
+		stripped := stripComment(content)
+		if stripped == "" && lead == indent {
+			continue
+		}
+		if hex >= 0 {
+			if indent > hexIndent {
+				out[hex] += " " + stripped
+				hex = -1
+				continue
+			}
+			closeHex()
+		}
+		if stripped == ">" || strings.HasPrefix(stripped, "> ") || strings.HasSuffix(stripped, ": >") || strings.Contains(stripped, ": > ") {
+			hexBody = indent
+		}
+		switch {
+		case stripped == ">":
+			hex, hexIndent = len(out), indent
+		case strings.HasSuffix(stripped, "`"):
+			body = indent
+		}
+		out = append(out, line[:lead]+stripped)
+	}
+	closeHex()
+	stripped := []byte(strings.Join(out, "\n"))
+	if got, err := Unmarshal(stripped); err != nil || !Equal(got, want) {
+		return nil, errors.New("Cannot strip the comments of this document without changing its value")
+	}
+	return stripped, nil
+}
+
+// SortOptions configures SortKeys.
+type SortOptions struct {
+	// MaxDepth, when positive, limits sorting to the objects nested at
+	// most that deep, counting as DecodeOptions.MaxDepth does, so that 1
+	// sorts only the properties of a root object. Deeper objects keep the
+	// order they have.
+	MaxDepth int
+}
+
+// SortKeys returns src with the properties of its block objects in the
+// order of their keys, the order in which Marshal writes them, for
+// keeping hand-written documents in a canonical order without losing what
+// Marshal would. Each property moves with the lines of its value and with
+// the comments and blank lines above it, but for those at the top of the
+// document before its last blank line, which stay as its header. Inline
+// objects, written on one line, keep their order.
+//
+// SortKeys checks that the document it returns has the value of src, and
+// fails rather than return one that does not, as could only be for a
+// layout it does not anticipate.
+func SortKeys(src []byte, opts SortOptions) ([]byte, error) {
+	want, err := Unmarshal(src)
+	if err != nil {
+		return nil, err
+	}
+	text := string(src)
+	final := strings.HasSuffix(text, "\n")
+	lines := sourceLines(strings.TrimSuffix(text, "\n"))
+
+	// The header is the directive, if any, and the lines through the last
+	// blank one before the value.
+	head := 0
+	if len(lines) > 0 && strings.HasPrefix(lines[0].text, versionPrefix) {
+		head = 1
+	}
+	for i := head; i < len(lines) && lines[i].kind != lineValue; i++ {
+		if lines[i].kind == lineBlank {
+			head = i + 1
+		}
+	}
+	sorter := keySorter{maxDepth: opts.MaxDepth}
+	lines = append(lines[:head:head], sorter.value(lines[head:], 0, 1)...)
+
+	var b strings.Builder
+	b.Grow(len(src))
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(l.text)
+	}
+	if final {
+		b.WriteByte('\n')
+	}
+	out := []byte(b.String())
+	if got, err := Unmarshal(out); err != nil || !Equal(got, want) {
+		return nil, errors.New("Cannot sort the keys of this document without changing its value")
+	}
+	return out, nil
+}
+
+// lineKind classifies the lines of a document for source transforms.
+type lineKind int
+
+const (
+	lineValue   lineKind = iota // Part of a value, including block text
+	lineBlank                   // Empty
+	lineComment                 // A comment alone on its line
+)
+
+// sourceLine is a line of a document as source transforms see it.
+type sourceLine struct {
+	text   string // The line, without its newline
+	indent int    // Leading spaces
+	kind   lineKind
+}
+
+// sourceLines classifies the lines of text, a valid document. The text of
+// a block string is part of its value, even where it begins with "#",
+// while a comment at the margin is a comment wherever it is, since the
+// scanner skips those before looking at blocks.
+func sourceLines(text string) []sourceLine {
+	var lines []sourceLine
+	body := -1 // Indent that the text lines of a block string are deeper than
+	for _, s := range strings.Split(text, "\n") {
+		l := sourceLine{text: s, indent: countIndent(s)}
+		rest := s[l.indent:]
+		switch {
+		case rest == "":
+			l.kind = lineBlank
+		case l.indent == 0 && rest[0] == '#':
+			l.kind = lineComment
+		case body >= 0 && l.indent > body:
+			l.kind = lineValue
+		default:
+			body = -1
+			lead := l.indent
+			for strings.HasPrefix(s[lead:], "- ") {
+				lead += 2
+			}
+			content := s[lead:]
+			if content[0] == '`' {
+				body = l.indent
+				break
+			}
+			switch stripped := stripComment(content); {
+			case stripped == "" && lead == l.indent:
+				l.kind = lineComment
+			case strings.HasSuffix(stripped, "`"):
+				body = l.indent
+			}
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// keySorter sorts the keys of the objects of a document for SortKeys.
+type keySorter struct {
+	maxDepth int
+}
+
+// value returns lines, which hold a value whose first line begins at
+// column col, nested depth deep, with the keys of its objects sorted. The
+// first line of an array item's value follows the item's "- ", so its
+// indent is less than col. Comments and blank lines may precede it.
+func (s keySorter) value(lines []sourceLine, col, depth int) []sourceLine {
+	if s.maxDepth > 0 && depth > s.maxDepth {
+		return lines
+	}
+	first := 0
+	for first < len(lines) && lines[first].kind != lineValue {
+		first++
+	}
+	if first == len(lines) {
+		return lines
+	}
+	// A value indented past col, as a root may be, begins at its indent.
+	col = max(col, lines[first].indent)
+	text := lines[first].text[col:]
+	switch {
+	case strings.HasPrefix(text, "- "):
+		return s.array(lines, col, depth)
+	case isKeyLine(text):
+		return s.object(lines, col, depth)
+	}
+	return lines
+}
+
+// array sorts the keys of the objects within the items of an array.
+func (s keySorter) array(lines []sourceLine, col, depth int) []sourceLine {
+	spans, tail := memberSpans(lines, col, true)
+	if !aligned(spans, col) {
+		return lines
+	}
+	out := make([]sourceLine, 0, len(lines))
+	for _, span := range spans {
+		i := leaderLine(span)
+		out = append(out, span[:i]...)
+		out = append(out, s.value(span[i:], col+2, depth+1)...)
+	}
+	return append(out, tail...)
+}
+
+// object sorts the properties of an object and the keys of their values.
+func (s keySorter) object(lines []sourceLine, col, depth int) []sourceLine {
+	spans, tail := memberSpans(lines, col, false)
+	if !aligned(spans, col) {
+		return lines
+	}
+
+	// Blank lines above a property stay where they are, to separate
+	// whichever properties come to be on either side of them, while the
+	// comments directly above it move with it.
+	seps := make([][]sourceLine, len(spans))
+	for k, span := range spans {
+		i := leaderLine(span)
+		for i > 0 && span[i-1].kind == lineComment {
+			i--
+		}
+		seps[k], spans[k] = span[:i], span[i:]
+	}
+	// A line at the column of the keys that holds no key, which the
+	// parser passes over, leaves the object as it is.
+	keys := make(map[*sourceLine]string, len(spans))
+	for _, span := range spans {
+		l := &span[leaderLine(span)]
+		text := l.text[min(col, len(l.text)):]
+		colon := findColonOutsideQuotes(text)
+		if colon < 0 {
+			return lines
+		}
+		keys[&span[0]] = parseKeyName(text[:colon])
+	}
+
+	// The first property of an array item follows the item's "- ", which
+	// stays on the first line as the properties move beneath it.
+	was := &spans[0][leaderLine(spans[0])]
+	sort.SliceStable(spans, func(i, j int) bool {
+		return keys[&spans[i][0]] < keys[&spans[j][0]]
+	})
+	if now := &spans[0][leaderLine(spans[0])]; now != was && was.indent < col {
+		now.text = was.text[:col] + now.text[col:]
+		now.indent = was.indent
+		was.text = strings.Repeat(" ", col) + was.text[col:]
+		was.indent = col
+	}
+
+	out := make([]sourceLine, 0, len(lines))
+	for k, span := range spans {
+		out = append(out, seps[k]...)
+		i := leaderLine(span)
+		out = append(out, span[:i+1]...)
+		text := span[i].text[col:]
+		if stripComment(strings.TrimSpace(text[findColonOutsideQuotes(text)+1:])) != "" {
+			out = append(out, span[i+1:]...)
+			continue
+		}
+		// The value is on the lines that follow, beginning at the column
+		// of the first.
+		rest := span[i+1:]
+		if j := leaderLine(rest); j < len(rest) {
+			rest = s.value(rest, rest[j].indent, depth+1)
+		}
+		out = append(out, rest...)
+	}
+	return append(out, tail...)
+}
+
+// memberSpans divides lines, which hold the items of an array, if items
+// is set, or else the properties of an object, beginning at column col,
+// into the lines of each member, each with the comments and blank lines
+// above it. Comments indented past col belong to the member before, as do
+// the lines of the members' values, which include the items of an array
+// that a property's value may put at the property's own column. The
+// comments and blank lines after the last member are returned as tail.
+func memberSpans(lines []sourceLine, col int, items bool) (spans [][]sourceLine, tail []sourceLine) {
+	var starts []int
+	for i, l := range lines {
+		if l.kind == lineValue && (l.indent < col || l.indent == col && strings.HasPrefix(l.text[col:], "- ") == items) {
+			starts = append(starts, i)
+		}
+	}
+	begin := 0
+	for k, start := range starts {
+		end := len(lines)
+		if k+1 < len(starts) {
+			end = starts[k+1]
+		}
+		for end > start+1 && lines[end-1].kind != lineValue && lines[end-1].indent <= col {
+			end--
+		}
+		spans = append(spans, lines[begin:end])
+		begin = end
+	}
+	return spans, lines[begin:]
+}
+
+// aligned reports whether the members of spans, from memberSpans, begin
+// at column col, but for the first, which may follow an item's "- ". A
+// member that begins left of col is one the parser reads more loosely
+// than SortKeys does, and it leaves the lines as they are.
+func aligned(spans [][]sourceLine, col int) bool {
+	if len(spans) == 0 {
+		return false
+	}
+	for _, span := range spans[1:] {
+		if i := leaderLine(span); i < len(span) && span[i].indent != col {
+			return false
+		}
+	}
+	return true
+}
+
+// leaderLine returns the index of the first line of a value in span, or
+// its length if none.
+func leaderLine(span []sourceLine) int {
+	for i, l := range span {
+		if l.kind == lineValue {
+			return i
+		}
+	}
+	return len(span)
+}
+
+// isKeyLine reports whether text, the content of a line, begins a property.
+func isKeyLine(text string) bool {
+	return text != "" && strings.IndexByte("[{<>`", text[0]) < 0 && findColonOutsideQuotes(text) >= 0
+}
@@ -0,0 +1,111 @@
+//go:build yay_tiny
+
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// ============================================================================
+// Tiny Builds
+// ============================================================================
+//
+// The yay_tiny build tag makes a build for TinyGo and other small targets,
+// where package reflect, package regexp, encoding/json, and the packages
+// of the standard library adapters are too large or do not compile. The
+// parser and encoder need none of them: numbers are matched by hand, and
+// documents decode to the same values as in any other build. What a tiny
+// build leaves out is everything that works on Go types by reflection:
+//
+//   - Decode into variables of types other than any, Object, and Array,
+//     and with it struct tags, Position fields, nullable types, and the
+//     constraints of ValidateStruct.
+//   - Marshal of values of types other than those Unmarshal returns and
+//     the Go numbers: no structs, codecs, Marshaler methods, or iterators.
+//   - Codecs, whose type is empty, JSONSchema, and Generated.
+//   - DecodeOptions.JSONCompatible and the conversions to and from JSON,
+//     which need encoding/json.
+//
+// The package still uses fmt for its messages, which itself uses reflect.
+//
+//	tinygo build -tags yay_tiny -target wasi .
+
+// errTiny is the reason for what a tiny build cannot do.
+var errTiny = errors.New("not available in yay_tiny builds")
+
+// Codecs is empty in a tiny build, which converts no Go types.
+type Codecs struct{}
+
+// checkTarget returns an error unless v, given to Decode, is one of the
+// targets a tiny build decodes into.
+func checkTarget(v any) error {
+	return fmt.Errorf("Decode needs a non-nil *any, *Object, or *Array, not %T: decoding into Go types is %w", v, errTiny)
+}
+
+// decodeTyped reports that a tiny build does not decode into Go types.
+func decodeTyped(v, value any, opts DecodeOptions, where func(path []pathSegment) Position) error {
+	return checkTarget(v)
+}
+
+// isDecodeError reports false, there being no DecodeErrors in a tiny
+// build.
+func isDecodeError(err error) bool {
+	return false
+}
+
+// jsonCompatible reports that a tiny build has no JSONCompatible option.
+func jsonCompatible(v any, opts DecodeOptions) (any, error) {
+	return nil, fmt.Errorf("JSONCompatible is %w", errTiny)
+}
+
+// streamIter reports false, a tiny build having no iterators.
+func (e *Encoder) streamIter(v any) (bool, error) {
+	return false, nil
+}
+
+// visit identifies an array or object being prepared. An array is its
+// storage and length, since arrays of one storage may nest in each other
+// without a cycle.
+type visit struct {
+	ptr unsafe.Pointer
+	len int
+}
+
+// visitOf returns the visit for v, or false if v is not a non-empty array
+// or a non-nil object.
+func visitOf(v any) (visit, bool) {
+	switch x := v.(type) {
+	case []any:
+		if len(x) > 0 {
+			return visit{ptr: unsafe.Pointer(unsafe.SliceData(x)), len: len(x)}, true
+		}
+	case map[string]any:
+		if x != nil {
+			return visit{ptr: *(*unsafe.Pointer)(unsafe.Pointer(&x))}, true
+		}
+	case *OrderedMap:
+		if x != nil {
+			return visit{ptr: unsafe.Pointer(x)}, true
+		}
+	}
+	return visit{}, false
+}
+
+// convert reports that a tiny build encodes only the values Unmarshal
+// returns and the Go numbers.
+func (p preparer) convert(v any) (any, bool, error) {
+	return nil, false, &encodeError{typ: fmt.Sprintf("%T", v), reason: "encoding Go types is " + errTiny.Error()}
+}
+
+// deepEqual is Equal for values of other types, compared with ==, and
+// unequal if their type is not comparable.
+func deepEqual(a, b any) (equal bool) {
+	defer func() {
+		if recover() != nil {
+			equal = false
+		}
+	}()
+	return a == b
+}
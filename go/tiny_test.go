@@ -0,0 +1,40 @@
+//go:build yay_tiny
+
+package yay
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestTinyBuild(t *testing.T) {
+	var v any
+	if err := NewDecoder(strings.NewReader("a: [1, 2]\n")).Decode(&v); err != nil || !Equal(v, map[string]any{"a": []any{big.NewInt(1), big.NewInt(2)}}) {
+		t.Errorf("Decode into any: got %#v, %v", v, err)
+	}
+	var cfg struct{ Port int }
+	if err := NewDecoder(strings.NewReader("Port: 80\n")).Decode(&cfg); err == nil || !strings.Contains(err.Error(), "not available in yay_tiny builds") {
+		t.Errorf("Decode into a struct: got %v", err)
+	}
+	if _, err := Marshal(struct{ Port int }{80}); err == nil || err.Error() != "Cannot encode struct { Port int }: encoding Go types is not available in yay_tiny builds" {
+		t.Errorf("Marshal of a struct: got %v", err)
+	}
+	if out, err := Marshal(map[string]any{"a": []any{1, 2.5, "x"}}); err != nil || string(out) != "a: [1, 2.5, \"x\"]\n" {
+		t.Errorf("Marshal: got %q, %v", out, err)
+	}
+	if _, err := UnmarshalWithOptions([]byte("1\n"), DecodeOptions{JSONCompatible: true}); err == nil {
+		t.Error("JSONCompatible: got no error")
+	}
+
+	// Cycles are found without reflection.
+	m := map[string]any{}
+	m["m"] = m
+	if _, err := Marshal(m); err == nil || err.Error() != "Cannot encode a value that encountered a cycle via map[string]interface {}" {
+		t.Errorf("cycle: got %v", err)
+	}
+
+	if Equal([]int{1}, []int{1}) || !Equal(int64(1), int64(1)) {
+		t.Error("Equal of other types: want ==, unequal if not comparable")
+	}
+}
@@ -0,0 +1,368 @@
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// ============================================================================
+// Tokens
+// ============================================================================
+//
+// Decoder.Token walks a document a token at a time, as encoding/json's
+// does. It does not stream: the first call reads the whole stream into
+// memory, as Decode does, and scans it. What it saves is the decoded
+// value. The members of a root block array or object are parsed one at a
+// time, as Token reaches them, so that no more than one of them is held
+// as a value at once; the value of each member, and a root written
+// inline, is parsed whole and then walked. Decode, called between tokens,
+// takes the next value whole instead.
+
+// Token is a token of a document, as Decoder.Token returns it: a Delim for
+// the beginning or end of an array or object, a string for the key of a
+// property, or a value that is neither array nor object: nil, a bool, a
+// *big.Int, a float64, a string, or a []byte. Strings and byte arrays come
+// whole, never in parts.
+type Token any
+
+// Delim is an array or object delimiter: '[', ']', '{', or '}'. It stands
+// for the beginning or end of a block array or object as well as an inline
+// one.
+type Delim rune
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// closing returns the delimiter that ends what d begins.
+func (d Delim) closing() Delim {
+	if d == '[' {
+		return ']'
+	}
+	return '}'
+}
+
+// tokenStream is the state of a Decoder that has begun to return tokens.
+type tokenStream struct {
+	ctx    *parseContext
+	tokens []token
+	frames []*tokenFrame // The arrays and objects the next token is within
+	err    error         // Returned by every call after the first
+}
+
+// tokenFrame is an array or object whose tokens are being returned.
+type tokenFrame struct {
+	delim Delim                             // '[' or '{'
+	next  func() (string, any, bool, error) // Returns the next member, if any
+	end   func() error                      // Checks what follows the last member, if set
+
+	has   bool   // Whether key and value hold the next member
+	done  bool   // Whether the members have run out
+	keyed bool   // Whether key has been returned
+	key   string // Of the next member of an object
+	value any    // Of the next member
+}
+
+// peek reports whether f has another member, reading it if need be.
+func (f *tokenFrame) peek() (bool, error) {
+	if !f.has && !f.done {
+		key, value, ok, err := f.next()
+		if err != nil {
+			return false, err
+		}
+		f.key, f.value, f.has, f.done = key, value, ok, !ok
+	}
+	return f.has, nil
+}
+
+// take returns the value of the next member of f, which peek has found.
+func (f *tokenFrame) take() any {
+	value := f.value
+	f.has, f.keyed, f.key, f.value = false, false, "", nil
+	return value
+}
+
+// Token returns the next token of the document, or io.EOF after the last.
+// The first call reads the whole stream into memory, as Decode does, so
+// the document must fit there, though its value need not; a Decoder that
+// has returned a token decodes values from where the tokens have reached:
+// Decode takes the value that the next call to Token would begin, such as
+// an item of an array or the value of a property whose key Token has
+// returned, whole.
+//
+//	dec := yay.NewDecoder(r)
+//	if _, err := dec.Token(); err != nil { // The "[" of a root array
+//		return err
+//	}
+//	for dec.More() {
+//		var rec Record
+//		if err := dec.Decode(&rec); err != nil {
+//			return err
+//		}
+//		process(rec)
+//	}
+//
+// Keys come in the order the document gives them. A syntax error is
+// returned by the call that reaches it, after the tokens before it, and by
+// every call after.
+func (d *Decoder) Token() (tok Token, err error) {
+	if d.stream == nil {
+		if d.done {
+			return nil, io.EOF
+		}
+		if err := d.startTokens(); err != nil {
+			return nil, err
+		}
+		if f := d.stream.frames[0]; f.delim != 0 {
+			return f.delim, nil
+		}
+	}
+	s := d.stream
+	if s.err != nil {
+		return nil, s.err
+	}
+	defer s.recover(&err)
+	if len(s.frames) == 0 {
+		return nil, io.EOF
+	}
+	f := s.frames[len(s.frames)-1]
+	ok, err := f.peek()
+	if err != nil {
+		s.err = err
+		return nil, err
+	}
+	if !ok {
+		s.frames = s.frames[:len(s.frames)-1]
+		if f.end != nil {
+			if err := f.end(); err != nil {
+				s.err = err
+				return nil, err
+			}
+		}
+		return f.delim.closing(), nil
+	}
+	if f.delim == '{' && !f.keyed {
+		f.keyed = true
+		return f.key, nil
+	}
+	return s.begin(s.take(f)), nil
+}
+
+// More reports whether there is another item of the array, or property of
+// the object, that the tokens have reached. An error reading the next
+// member is reported as true, for Token or Decode to return.
+func (d *Decoder) More() bool {
+	if d.stream == nil {
+		return !d.done
+	}
+	s := d.stream
+	if s.err != nil {
+		return true
+	}
+	if len(s.frames) == 0 {
+		return false
+	}
+	var err error
+	func() {
+		defer s.recover(&err)
+		var ok bool
+		ok, err = s.frames[len(s.frames)-1].peek()
+		if err == nil && !ok {
+			err = io.EOF
+		}
+	}()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		s.err = err
+	}
+	return true
+}
+
+// startTokens reads the stream and begins returning its tokens.
+func (d *Decoder) startTokens() (err error) {
+	d.done = true
+	data, err := d.read()
+	if err != nil {
+		return err
+	}
+	if err := checkInput(len(data), d.opts); err != nil {
+		return err
+	}
+	source := unsafe.String(unsafe.SliceData(data), len(data))
+	ctx := newParseContext(source, d.opts.Filename, d.opts)
+	ctx.ordered = true // Keys come in the order of the document
+	s := &tokenStream{ctx: ctx}
+	d.stream = s
+	defer s.recover(&err)
+	defer func() { s.err = err }()
+
+	if ctx.bom {
+		ctx.warnf(0, "Ignored BOM")
+	}
+	lines, err := scan(ctx.source, ctx, nil)
+	if err != nil {
+		return err
+	}
+	s.tokens = outlineLex(lines, nil)
+	i, err := findRoot(s.tokens, ctx)
+	if err != nil {
+		return err
+	}
+	tokens := s.tokens
+	t := tokens[i]
+	end := func() error {
+		_, err := ensureAtEnd(nil, tokens, i, ctx)
+		return err
+	}
+	switch {
+	case isRootObject(t):
+		if err := ctx.enter(t.offset); err != nil {
+			return err
+		}
+		n := 0
+		s.frames = append(s.frames, &tokenFrame{
+			delim: '{',
+			next: func() (string, any, bool, error) {
+				p, next, ok, err := nextRootProperty(tokens, i, n, ctx)
+				i, n = next, n+1
+				return p.key, p.value, ok, err
+			},
+			end: end,
+		})
+	case t.typ == tokenStart && t.text == "- ":
+		if err := ctx.enter(t.offset); err != nil {
+			return err
+		}
+		n := 0
+		s.frames = append(s.frames, &tokenFrame{
+			delim: '[',
+			next: func() (string, any, bool, error) {
+				item, next, ok, err := nextArrayItem(tokens, i, -1, n, ctx)
+				i, n = next, n+1
+				return "", item.value, ok, err
+			},
+			end: end,
+		})
+	default:
+		value, next, err := parseValue(tokens, i, ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := ensureAtEnd(nil, tokens, next, ctx); err != nil {
+			return err
+		}
+		// A root written inline is the one member of a frame with no
+		// delimiters.
+		s.frames = append(s.frames, &tokenFrame{has: true, done: true, value: value})
+	}
+	return nil
+}
+
+// begin returns the token that begins value, entering the frame of its
+// members if it is an array or object.
+func (s *tokenStream) begin(value any) Token {
+	switch x := value.(type) {
+	case []any:
+		i := 0
+		s.frames = append(s.frames, &tokenFrame{
+			delim: '[',
+			next: func() (string, any, bool, error) {
+				if i == len(x) {
+					return "", nil, false, nil
+				}
+				i++
+				return "", x[i-1], true, nil
+			},
+		})
+		return Delim('[')
+	case *OrderedMap:
+		i := 0
+		s.frames = append(s.frames, &tokenFrame{
+			delim: '{',
+			next: func() (string, any, bool, error) {
+				if i == x.Len() {
+					return "", nil, false, nil
+				}
+				i++
+				return x.members[i-1].Key, x.members[i-1].Value, true, nil
+			},
+		})
+		return Delim('{')
+	}
+	return value
+}
+
+// take returns the value of the next member of f, the innermost frame,
+// which peek has found, leaving the frame of a root value written inline,
+// which has no other.
+func (s *tokenStream) take(f *tokenFrame) any {
+	if f.delim == 0 {
+		s.frames = s.frames[:len(s.frames)-1]
+	}
+	return f.take()
+}
+
+// decodeToken stores the value that the next token would begin in v, a
+// target that Decode accepts.
+func (d *Decoder) decodeToken(v any) (err error) {
+	s := d.stream
+	if s.err != nil {
+		return s.err
+	}
+	defer s.recover(&err)
+	if len(s.frames) == 0 {
+		return io.EOF
+	}
+	f := s.frames[len(s.frames)-1]
+	ok, err := f.peek()
+	if err != nil {
+		s.err = err
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Cannot decode the end of an %s", map[Delim]string{'[': "array", '{': "object"}[f.delim])
+	}
+	if f.delim == '{' && !f.keyed {
+		return errors.New("Cannot decode the key of a property; call Token for it first")
+	}
+	value := s.take(f)
+	if _, ok := v.(*Object); !ok && !d.opts.PreserveKeyOrder {
+		value = unordered(value)
+	}
+	switch v.(type) {
+	case *any, *Object, *Array:
+		return store(v, value)
+	}
+	return decodeTyped(v, value, d.opts, nil)
+}
+
+// recover reports a panic of the parser in err, as parse does, and keeps
+// it for every call after.
+func (s *tokenStream) recover(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w while parsing: %v", errInternal, r)
+		s.err = *err
+	}
+}
+
+// unordered returns v with its objects, at any depth, as map[string]any,
+// copying only what it must.
+func unordered(v any) any {
+	switch x := v.(type) {
+	case *OrderedMap:
+		m := make(map[string]any, x.Len())
+		for _, member := range x.members {
+			m[member.Key] = unordered(member.Value)
+		}
+		return m
+	case []any:
+		for i, item := range x {
+			x[i] = unordered(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,364 @@
+//go:build !yay_tiny
+
+package yay
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Struct Validation
+// ============================================================================
+//
+// Simple constraints on configuration values can be written in the options
+// of a field's yay tag rather than checked by hand:
+//
+//	type Server struct {
+//		Port int    `yay:"port,min=1,max=65535"`
+//		Host string `yay:"host,min=1,pattern=^[a-z0-9.-]+$"`
+//		Mode string `yay:"mode,oneof=dev|test|prod"`
+//	}
+//
+//   - min=N and max=N bound a number, or the length of a string (in code
+//     points), slice, array, or map.
+//   - pattern=RE requires a string to match the regular expression RE, which
+//     is not anchored unless it says so and cannot contain a comma.
+//   - oneof=A|B|C requires a string, or the decimal form of a number, to be
+//     one of those given.
+//
+// The constraints are parsed along with the rest of the tag, once per type,
+// and checked by ValidateStruct and as Decode stores values in structs.
+
+// ErrConstraint is reported, wrapped with the path of the value and the
+// constraint, for values that break a constraint of their struct tags.
+var ErrConstraint = errors.New("Constraint not met")
+
+// matcher is a compiled pattern constraint.
+type matcher interface {
+	MatchString(s string) bool
+}
+
+// constraints are the validation options of a field's tag.
+type constraints struct {
+	min, max       *big.Float
+	pattern        matcher
+	patternText    string
+	oneOf          []string
+	hasMin, hasMax bool
+}
+
+// parseConstraints returns the constraints among opts, or nil if there are
+// none.
+func parseConstraints(opts tagOptions) (*constraints, error) {
+	var c *constraints
+	for s := string(opts); s != ""; {
+		var opt string
+		opt, s, _ = strings.Cut(s, ",")
+		name, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			continue
+		}
+		if c == nil {
+			c = &constraints{}
+		}
+		switch name {
+		case "min", "max":
+			n, _, err := big.ParseFloat(value, 10, 128, big.ToNearestEven)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid constraint %q", opt)
+			}
+			if name == "min" {
+				c.min, c.hasMin = n, true
+			} else {
+				c.max, c.hasMax = n, true
+			}
+		case "pattern":
+			re, err := compilePattern(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid constraint %q: %w", opt, err)
+			}
+			c.pattern, c.patternText = re, value
+		case "oneof":
+			c.oneOf = strings.Split(value, "|")
+		default:
+			return nil, fmt.Errorf("Unknown constraint %q", opt)
+		}
+	}
+	return c, nil
+}
+
+// ValidateStruct checks the fields of v, a struct or a pointer to one,
+// against the constraints in their tags, as described above, looking into
+// nested structs and the structs in slices, arrays, and maps. Nil pointers
+// and nullable values holding nothing, such as an empty Optional, are not
+// checked. The error for the first value that breaks a constraint wraps
+// ErrConstraint and names the path of the value by its object keys, as in
+// ".servers[2].port". A malformed constraint is also an error.
+//
+// Decode and UnmarshalInto check the constraints of the values they
+// decode in the same way, giving also the position of the value in the
+// document, but for the fields of objects that the document leaves out,
+// which hold what they held before rather than anything it says.
+func ValidateStruct(v any) error {
+	return validateValue(reflect.ValueOf(v), nil, false)
+}
+
+// constraintError is the error for a value that breaks a constraint.
+type constraintError struct {
+	path   []pathSegment // Of the value, from the root
+	reason string        // Following the path, as "is 0, less than the minimum 1"
+	pos    Position      // Where the value begins, if decoded from a document
+}
+
+func (e *constraintError) Error() string {
+	var path strings.Builder
+	for _, seg := range e.path {
+		path.WriteString(seg.String())
+	}
+	msg := ErrConstraint.Error() + ": " + displayPath(path.String()) + " " + e.reason
+	if e.pos.Line > 0 {
+		msg += " at " + e.pos.String()
+	}
+	return msg
+}
+
+func (e *constraintError) Unwrap() error {
+	return ErrConstraint
+}
+
+// within returns err with seg before the path of the value, if it is a
+// constraintError.
+func within(err error, seg pathSegment) error {
+	if ce, ok := err.(*constraintError); ok {
+		ce.path = append([]pathSegment{seg}, ce.path...)
+	}
+	return err
+}
+
+// validateDecoded checks the constraints of target, just decoded from
+// value, if its type has any, returning the error ValidateStruct would
+// but for the fields value leaves out.
+func validateDecoded(target reflect.Value, value any) error {
+	if !hasConstraints(target.Type()) {
+		return nil
+	}
+	return validateValue(target, value, true)
+}
+
+// constrainedCache maps reflect.Type to whether values of the type may
+// hold a field with constraints.
+var constrainedCache sync.Map
+
+// hasConstraints reports whether values of type t may hold a field with
+// constraints, or with a malformed constraint, so that a decoded value
+// of a type that has none need not be walked.
+func hasConstraints(t reflect.Type) bool {
+	if c, ok := constrainedCache.Load(t); ok {
+		return c.(bool)
+	}
+	c := findConstraints(t, map[reflect.Type]bool{})
+	constrainedCache.Store(t, c)
+	return c
+}
+
+// findConstraints reports whether values of type t may hold a field with
+// constraints, skipping the types in seen, which are being looked into
+// already.
+func findConstraints(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return findConstraints(t.Elem(), seen)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && findConstraints(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		if isNullable(t) {
+			return findConstraints(t.Field(0).Type, seen)
+		}
+		for _, f := range cachedTypeFields(t).list {
+			if f.constraints != nil || f.constraintErr != nil || findConstraints(f.typ, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateValue checks the structs within v. If decoded, v was decoded
+// from doc, and the fields of objects that doc leaves out are not checked.
+func validateValue(v reflect.Value, doc any, decoded bool) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateValue(v.Elem(), doc, decoded)
+	case reflect.Struct:
+		if isNullable(v.Type()) {
+			if !v.Field(1).Bool() {
+				return nil
+			}
+			return validateValue(v.Field(0), doc, decoded)
+		}
+		// A struct decoded by a codec from something other than an
+		// object is checked whole.
+		decoded = decoded && isObject(doc)
+		fields := cachedTypeFields(v.Type())
+		for i := range fields.list {
+			f := &fields.list[i]
+			if f.constraintErr != nil {
+				return fmt.Errorf("%w on field %s of %s", f.constraintErr, f.name, v.Type())
+			}
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				continue
+			}
+			var fdoc any
+			if decoded {
+				if fdoc, ok = getProperty(doc, f.name); !ok {
+					continue
+				}
+			}
+			seg := pathSegment{key: f.name, isKey: true}
+			if f.constraints != nil {
+				if err := checkConstraints(f.constraints, fv); err != nil {
+					return within(err, seg)
+				}
+			}
+			if err := validateValue(fv, fdoc, decoded); err != nil {
+				return within(err, seg)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		items, ok := doc.([]any)
+		for i := 0; i < v.Len(); i++ {
+			var item any
+			present := decoded && ok && i < len(items)
+			if present {
+				item = items[i]
+			}
+			if err := validateValue(v.Index(i), item, present); err != nil {
+				return within(err, pathSegment{index: i})
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			var value any
+			present := false
+			if decoded && isObject(doc) {
+				value, present = getProperty(doc, iter.Key().String())
+			}
+			if err := validateValue(iter.Value(), value, present); err != nil {
+				return within(err, pathSegment{key: iter.Key().String(), isKey: true})
+			}
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of struct v at index, or false if it is
+// reached through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// checkConstraints checks v, the value of a field, against c, returning a
+// constraintError without a path.
+func checkConstraints(c *constraints, v reflect.Value) error {
+	for {
+		if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		} else if isNullable(v.Type()) {
+			if !v.Field(1).Bool() {
+				return nil
+			}
+			v = v.Field(0)
+		} else {
+			break
+		}
+	}
+	// The number min and max bound, the text pattern and oneof check, and
+	// how to describe the value for each.
+	var n *big.Float
+	var text, subject string
+	isText := true
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, text = new(big.Float).SetInt64(v.Int()), strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, text = new(big.Float).SetUint64(v.Uint()), strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		text = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		if !math.IsNaN(v.Float()) {
+			n = big.NewFloat(v.Float())
+		}
+	case reflect.String:
+		text = v.String()
+		length := utf8.RuneCountInString(text)
+		n, subject = new(big.Float).SetInt64(int64(length)), fmt.Sprintf("has length %d", length)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, isText = new(big.Float).SetInt64(int64(v.Len())), false
+		subject = fmt.Sprintf("has length %d", v.Len())
+	default:
+		isText = false
+		if v.CanAddr() {
+			if b, ok := v.Addr().Interface().(*big.Int); ok {
+				n, text, isText = new(big.Float).SetInt(b), b.String(), true
+			}
+		}
+	}
+	shown := v.Type().String()
+	if v.Kind() == reflect.String {
+		shown = describeValue(text)
+	} else if isText {
+		shown = text
+	}
+	if subject == "" {
+		subject = "is " + shown
+	}
+
+	if c.hasMin && (n == nil || n.Cmp(c.min) < 0) {
+		return &constraintError{reason: fmt.Sprintf("%s, less than the minimum %s", subject, c.min.Text('g', -1))}
+	}
+	if c.hasMax && (n == nil || n.Cmp(c.max) > 0) {
+		return &constraintError{reason: fmt.Sprintf("%s, more than the maximum %s", subject, c.max.Text('g', -1))}
+	}
+	if c.pattern != nil && (v.Kind() != reflect.String || !c.pattern.MatchString(text)) {
+		return &constraintError{reason: fmt.Sprintf("is %s, which does not match %s", shown, c.patternText)}
+	}
+	if c.oneOf != nil && !(isText && slices.Contains(c.oneOf, text)) {
+		return &constraintError{reason: fmt.Sprintf("is %s, not one of %s", shown, strings.Join(c.oneOf, ", "))}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package yay
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// ============================================================================
+// Round-Trip Verification
+// ============================================================================
+
+// VerifyRoundTrip checks that data survives canonical re-encoding: that it
+// parses, that Marshal can encode the result, and that the encoding parses
+// back to an equal value. It returns the first error, or for values that
+// differ, an error naming the first path at which they do, such as
+// ".servers[2].port".
+//
+// VerifyRoundTrip is meant as a one-call integrity check over a repository
+// of documents, catching anything the canonical encoder cannot faithfully
+// reproduce.
+func VerifyRoundTrip(data []byte) error {
+	v, err := Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	out, err := Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Cannot re-encode document: %w", err)
+	}
+	again, err := Unmarshal(out)
+	if err != nil {
+		return fmt.Errorf("Re-encoded document does not parse: %w", err)
+	}
+	if d, ok := firstDifference(v, again, ""); ok {
+		return fmt.Errorf("Round trip changes %s from %s to %s", displayPath(d.path), describe(d.a, d.aok), describe(d.b, d.bok))
+	}
+	return nil
+}
+
+// difference is the first place at which two values differ.
+type difference struct {
+	path     string
+	a, b     any
+	aok, bok bool // Whether the path leads to a value in a and in b
+}
+
+// describe returns describeValue(v) if ok, or "nothing".
+func describe(v any, ok bool) string {
+	if !ok {
+		return "nothing"
+	}
+	return describeValue(v)
+}
+
+// firstDifference finds the first place under path where a and b differ,
+// visiting object keys in sorted order.
+func firstDifference(a, b any, path string) (difference, bool) {
+	var first difference
+	found := !eachDifference(a, b, path, func(d difference) bool {
+		first = d
+		return false
+	})
+	return first, found
+}
+
+// eachDifference calls f with each place under path where a and b differ,
+// in order, visiting object keys in sorted order and array items by
+// position. It stops and returns false as soon as f does.
+func eachDifference(a, b any, path string, f func(difference) bool) bool {
+	switch {
+	case isObject(a) && isObject(b):
+		keys := make([]string, 0, objectLen(a)+objectLen(b))
+		eachProperty(a, func(k string, _ any) bool {
+			keys = append(keys, k)
+			return true
+		})
+		eachProperty(b, func(k string, _ any) bool {
+			if _, ok := getProperty(a, k); !ok {
+				keys = append(keys, k)
+			}
+			return true
+		})
+		sort.Strings(keys)
+		for _, k := range keys {
+			av, aok := getProperty(a, k)
+			bv, bok := getProperty(b, k)
+			if aok != bok {
+				if !f(difference{path + keyPathElement(k), av, bv, aok, bok}) {
+					return false
+				}
+			} else if !eachDifference(av, bv, path+keyPathElement(k), f) {
+				return false
+			}
+		}
+		return true
+	}
+	aa, aok := arrayItems(a)
+	bb, bok := arrayItems(b)
+	if aok && bok {
+		n := min(len(aa), len(bb))
+		for i := 0; i < n; i++ {
+			if !eachDifference(aa[i], bb[i], path+"["+strconv.Itoa(i)+"]", f) {
+				return false
+			}
+		}
+		for i := n; i < len(aa); i++ {
+			if !f(difference{path: path + "[" + strconv.Itoa(i) + "]", a: aa[i], aok: true}) {
+				return false
+			}
+		}
+		for i := n; i < len(bb); i++ {
+			if !f(difference{path: path + "[" + strconv.Itoa(i) + "]", b: bb[i], bok: true}) {
+				return false
+			}
+		}
+		return true
+	}
+	if !Equal(a, b) {
+		return f(difference{path, a, b, true, true})
+	}
+	return true
+}
+
+// keyPathElement returns the path element selecting key k.
+func keyPathElement(k string) string {
+	if isBareKey(k) {
+		return "." + k
+	}
+	return "[" + strconv.Quote(k) + "]"
+}
+
+// displayPath returns path as shown in messages, with "." for the root.
+func displayPath(path string) string {
+	if path == "" || path[0] == '[' {
+		return "." + path
+	}
+	return path
+}
+
+// describeValue returns a short description of v for messages.
+func describeValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case *big.Int:
+		if v == nil {
+			return "null"
+		}
+		return v.String()
+	case float64:
+		return string(appendFloat(nil, v, 64))
+	case string:
+		if len(v) > 40 {
+			return strconv.Quote(v[:37]) + "..."
+		}
+		return strconv.Quote(v)
+	case []byte:
+		if len(v) > 16 {
+			return fmt.Sprintf("%d bytes", len(v))
+		}
+		return string(appendBytes(nil, v))
+	case []any:
+		return fmt.Sprintf("an array of %d items", len(v))
+	case Array:
+		return fmt.Sprintf("an array of %d items", len(v))
+	case map[string]any, *OrderedMap:
+		return fmt.Sprintf("an object of %d properties", objectLen(v))
+	}
+	return fmt.Sprintf("%v", v)
+}
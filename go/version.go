@@ -0,0 +1,82 @@
+package yay
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Version Directive
+// ============================================================================
+//
+// A document may begin with a version directive, a comment on its first
+// line such as "#!yay 1" that names the version of the YAY specification
+// the document requires. Being a comment, the directive is ignored by
+// implementations that predate it. This one rejects documents requiring a
+// newer version than it implements, so that a service meeting syntax from
+// the future fails predictably rather than misreading it, and reads any
+// first line that is not exactly a directive as the comment it is to the
+// others.
+
+// SpecVersion is the version of the YAY specification this package
+// implements, and the newest a version directive may require.
+const SpecVersion = 1
+
+// ErrUnsupportedVersion is reported, wrapped with the version required,
+// for documents whose version directive requires a newer version of the
+// specification than SpecVersion.
+var ErrUnsupportedVersion = errors.New("Unsupported YAY version")
+
+// versionPrefix begins a version directive, which is the whole of the
+// first line: the prefix, one space, and a version in decimal without
+// leading zeros. Any other first line, such as "#!/usr/bin/env yay",
+// "#!yay 1 # note", or "#!yay x", is a comment like any other, as it is
+// to every implementation that does not read directives.
+const versionPrefix = "#!yay "
+
+// Version returns the version of the specification that the directive on
+// the first line of data requires, or 0 if data has no directive. It
+// returns the error that Unmarshal would report for an unsupported
+// version, without parsing the rest of the document.
+func Version(data []byte) (int, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[:i]
+	}
+	return checkVersion(string(data), nil)
+}
+
+// checkVersion returns the version required by the directive at the start
+// of source, or 0 if there is none, failing if the directive requires a
+// newer version than SpecVersion.
+func checkVersion(source string, ctx *parseContext) (int, error) {
+	digits, ok := strings.CutPrefix(source, versionPrefix)
+	if !ok {
+		return 0, nil
+	}
+	if i := strings.IndexByte(digits, '\n'); i >= 0 {
+		digits = digits[:i]
+	}
+	if digits == "" || digits[0] < '1' || digits[0] > '9' {
+		return 0, nil
+	}
+	for i := 1; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, nil
+		}
+	}
+	v, err := strconv.Atoi(digits)
+	if err != nil {
+		v = math.MaxInt // Newer than any there is
+	}
+	if v > SpecVersion {
+		// The error gives the version as written, though it may be too
+		// large for v to hold.
+		written, _ := new(big.Int).SetString(digits, 10)
+		return v, ctx.errorf(len(versionPrefix), "%w %d (up to %d is supported)", ErrUnsupportedVersion, written, SpecVersion)
+	}
+	return v, nil
+}
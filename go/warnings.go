@@ -0,0 +1,49 @@
+package yay
+
+import "fmt"
+
+// ============================================================================
+// Warnings
+// ============================================================================
+//
+// The lenient options of DecodeOptions accept documents that Unmarshal
+// rejects, such as one beginning with a byte order mark or holding a float
+// out of range. A tool that passes such documents through should still
+// nudge their authors to fix them, so each leniency the parser takes is
+// reported to DecodeOptions.Warn, apart from any error.
+
+// Warning describes something a document holds that decoded only because
+// an option allowed it, and that its author should fix.
+type Warning struct {
+	Message string // What was allowed, as "Ignored BOM"
+
+	// Filename, Line, and Column give where in the document. Line and
+	// Column count from 1, with columns as DecodeOptions.Columns counts
+	// them.
+	Filename     string
+	Line, Column int
+}
+
+// String returns the message of w, with its position if the document has
+// a filename, as errors give it.
+func (w Warning) String() string {
+	if w.Filename == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%s at %d:%d of <%s>", w.Message, w.Line, w.Column, w.Filename)
+}
+
+// warnf reports a warning at offset off to the Warn option, if any.
+func (ctx *parseContext) warnf(off int, format string, args ...any) {
+	if ctx == nil || ctx.warn == nil {
+		return
+	}
+	line, col := positionAt(ctx.source, off, ctx.columns)
+	_, format, _, args = ctx.messages.format(format, args)
+	ctx.warn(Warning{
+		Message:  fmt.Sprintf(format, args...),
+		Filename: ctx.filename,
+		Line:     line + 1,
+		Column:   col + 1,
+	})
+}
@@ -0,0 +1,156 @@
+package yay
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// ============================================================================
+// Watching
+// ============================================================================
+//
+// Watch reloads a configuration file, or a tree of them, as it changes. It
+// polls the modification times and sizes of the files rather than asking
+// the operating system for notifications, which keeps the package free of
+// dependencies and works the same on every platform and file system,
+// including network mounts and the symlink swaps of Kubernetes ConfigMaps.
+
+// defaultWatchInterval is how often Watch polls when WatchOptions.Interval
+// is zero.
+const defaultWatchInterval = time.Second
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Interval is how often to check for changes. The default is a second.
+	Interval time.Duration
+
+	// Decode is how to decode the files.
+	Decode DecodeOptions
+
+	// Validate, if set, checks each newly decoded value. A value it
+	// rejects is treated as a document that fails to parse.
+	Validate func(v any) error
+
+	// OnError, if set, is called with each error that stops a changed
+	// document from taking effect.
+	OnError func(err error)
+}
+
+// Watch decodes the file or, as LoadDir would, the directory tree name,
+// and calls onChange with its value. It then watches for changes until ctx
+// is done, and calls onChange again each time the value changes. Only
+// values that decode and pass opts.Validate reach onChange; when a change
+// fails either, Watch reports the error to opts.OnError and keeps waiting,
+// so a service goes on with the last good configuration.
+//
+// If the first decoding fails, Watch returns the error at once. Otherwise
+// it returns ctx.Err() once ctx is done. Calls to onChange and OnError are
+// made one at a time from the goroutine running Watch.
+//
+//	go yay.Watch(ctx, "/etc/myapp/config.yay", yay.WatchOptions{
+//		Validate: checkConfig,
+//		OnError:  func(err error) { log.Print(err) },
+//	}, func(v any) {
+//		config.Store(v)
+//	})
+func Watch(ctx context.Context, name string, opts WatchOptions, onChange func(v any)) error {
+	stamp, err := watchStamp(name)
+	if err != nil {
+		return err
+	}
+	v, err := watchLoad(name, &opts)
+	if err != nil {
+		return err
+	}
+	onChange(v)
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		next, err := watchStamp(name)
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(err)
+			}
+			continue
+		}
+		if next == stamp {
+			continue
+		}
+		stamp = next
+		w, err := watchLoad(name, &opts)
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(err)
+			}
+			continue
+		}
+		if !Equal(w, v) {
+			v = w
+			onChange(v)
+		}
+	}
+}
+
+// watchLoad decodes and validates the file or tree name.
+func watchLoad(name string, opts *WatchOptions) (any, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if info.IsDir() {
+		v, err = LoadDirWithOptions(os.DirFS(name), ".", opts.Decode)
+	} else {
+		var data []byte
+		if data, err = os.ReadFile(name); err == nil {
+			v, err = unmarshal(data, name, opts.Decode)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.Validate != nil {
+		if err := opts.Validate(v); err != nil {
+			return nil, fmt.Errorf("Invalid configuration in %s: %w", name, err)
+		}
+	}
+	return v, nil
+}
+
+// watchStamp returns a string that changes whenever the file, or any
+// file in the tree, name is changed, added, or removed.
+func watchStamp(name string) (string, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return fmt.Sprint(info.ModTime().UnixNano(), info.Size()), nil
+	}
+	stamp := ""
+	err = fs.WalkDir(os.DirFS(name), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stamp += fmt.Sprint(path, "\x00", info.ModTime().UnixNano(), info.Size(), "\x00")
+		return nil
+	})
+	return stamp, err
+}
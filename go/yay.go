@@ -18,13 +18,17 @@
 package yay
 
 import (
-	"encoding/hex"
+	"bytes"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
 )
 
 // ============================================================================
@@ -43,36 +47,393 @@ import (
 //   - object -> map[string]any
 //   - bytes -> []byte
 func Unmarshal(data []byte) (any, error) {
-	return unmarshal(data, "")
+	return unmarshal(data, "", DecodeOptions{})
+}
+
+// UnmarshalInto parses YAY-encoded data and stores the result in v, a
+// non-nil pointer, as Decoder.Decode does: into a struct by the keys of its
+// fields, which a `yay:"name"` tag renames and `yay:"-"` excludes, and into
+// a slice, map, or value of another type as its kind allows.
+//
+//	var cfg struct {
+//		Host string `yay:"host"`
+//		Port int    `yay:"port"`
+//	}
+//	err := yay.UnmarshalInto(data, &cfg)
+func UnmarshalInto(data []byte, v any) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
 }
 
 // UnmarshalFile parses YAY-encoded data with a filename for error messages.
 func UnmarshalFile(data []byte, filename string) (any, error) {
-	return unmarshal(data, filename)
+	return unmarshal(data, filename, DecodeOptions{})
+}
+
+// DecodeOptions configures optional decoding behavior.
+// The zero value decodes exactly as Unmarshal does.
+type DecodeOptions struct {
+	// Filename names the document in error messages, which then give the
+	// position of the error as well. With no filename, errors give no
+	// position.
+	Filename string
+
+	// InternKeys makes identical object keys share one string allocation.
+	// Documents that repeat a small set of keys many times retain much
+	// less heap, at the cost of a map lookup per key.
+	InternKeys bool
+
+	// Batch carves integers, strings, byte arrays, and array storage out
+	// of a few large per-parse slabs instead of allocating each value
+	// separately. This suits parse-inspect-discard workloads where GC
+	// pressure matters more than peak memory: a slab is reclaimed only
+	// once every value carved from it is unreachable, so retaining one
+	// value retains its neighbors. Maps are still allocated individually.
+	Batch bool
+
+	// Columns is the unit in which the columns of error positions are
+	// counted. The default counts code points.
+	Columns ColumnUnit
+
+	// SaturateFloats accepts floats beyond the range of float64, rounding
+	// them to infinity or zero as strconv.ParseFloat does, with a warning.
+	// By default they are errors, so that a mistyped exponent cannot
+	// silently become an infinity.
+	SaturateFloats bool
+
+	// AllowBOM accepts a document that begins with a byte order mark, as
+	// some editors write, ignoring the mark with a warning. By default a
+	// BOM is an error.
+	AllowBOM bool
+
+	// Warn, if set, is called with a Warning for each thing a lenient
+	// option accepts, such as a BOM with AllowBOM, so that tools can pass
+	// documents through while still telling their authors what to fix.
+	// Warn is called during decoding, in the order of the document.
+	Warn func(Warning)
+
+	// Catalog, if set, rewords the messages of errors and warnings, by
+	// their codes, as for another language. See DefaultCatalog.
+	Catalog Catalog
+
+	// Recorder, if set, receives the size, duration, and outcome of each
+	// document decoded, for monitoring. See the yayexpvar package for a
+	// Recorder that publishes them with expvar.
+	Recorder Recorder
+
+	// PreserveKeyOrder decodes objects to *OrderedMap instead of
+	// map[string]any, keeping keys in the order the document gives them,
+	// for tools that rewrite documents or must process keys in a stable,
+	// author-chosen order. A key given twice keeps its first position.
+	PreserveKeyOrder bool
+
+	// JSONCompatible decodes to the values json.Unmarshal produces when
+	// decoding into an any: numbers become float64, rounding integers that
+	// a float64 cannot represent exactly, and byte arrays become strings of
+	// standard base64, as encoding/json writes a []byte. Code written
+	// against JSON input can then take YAY without changing its type
+	// switches. NaN and the infinities, which JSON lacks, stay float64.
+	JSONCompatible bool
+
+	// UseNumber, with JSONCompatible, makes numbers json.Number instead of
+	// float64, as json.Decoder.UseNumber does, so that integers keep every
+	// digit. NaN and the infinities are still float64, having no JSON
+	// spelling.
+	UseNumber bool
+
+	// ChompBlockStrings drops the newline that ends every block string,
+	// for fields such as descriptions that are wanted without one. Quoted
+	// strings are left as they are.
+	ChompBlockStrings bool
+
+	// StrictIndentation rejects lines indented to columns no block opens.
+	// Each list item opens a block at the column of its value, two past
+	// each of its dashes, and a line that leaves its value to the lines
+	// below, such as "key:", lets the next line open one at any deeper
+	// column. A line indented deeper than the block it is in, below a
+	// line whose value is complete, fails with "Unexpected indent", and a
+	// line that dedents to a column between two enclosing blocks fails
+	// with "Inconsistent indentation". The bodies of block strings and
+	// block bytes, and the lines that continue an unclosed inline value,
+	// are not checked. Without the option, such lines are read as the
+	// grammar reads them, which may attach them to a block the author did
+	// not mean.
+	StrictIndentation bool
+
+	// DisallowUnknownFields makes a Decoder decoding into a struct fail
+	// with ErrUnknownField at any key of the object that names none of the
+	// struct's fields, rather than skip it, so that a misspelled setting
+	// in a configuration file is caught instead of quietly ignored.
+	DisallowUnknownFields bool
+
+	// MaxErrors, when positive, makes a document that fails to parse fail
+	// with ParseErrors, listing up to this many of its errors rather than
+	// only the first, so that an editor or a CI job can report every
+	// problem in one pass. The parse goes on past each error by setting
+	// its line aside, with the lines indented beneath it.
+	MaxErrors int
+
+	// Codecs, if set, convert decoded values to Go types of other
+	// packages, taking precedence over the codecs registered with
+	// RegisterCodec. A Decoder decodes into a pointer to any type with a
+	// codec.
+	Codecs *Codecs
+
+	// MaxInputBytes, when positive, is the largest document accepted.
+	// Longer documents fail with ErrTooLarge. A Decoder stops reading its
+	// stream once it passes the limit.
+	MaxInputBytes int
+
+	// MaxLineBytes, when positive, is the longest line accepted, in bytes
+	// not counting the newline. Longer lines are errors, found in one pass
+	// over the source before any line is validated or parsed.
+	MaxLineBytes int
+
+	// MaxDepth, when positive, is how deeply arrays and objects may nest.
+	// A document whose root is an array or object has depth 1. Deeper
+	// documents fail with ErrTooDeep. When MaxDepth is zero, nesting is
+	// still limited to 4096 levels to protect the stack.
+	MaxDepth int
+
+	// MaxInlineDepth, when positive, is how deeply inline arrays and
+	// objects may nest within one another, as in "[[[1]]]". Deeper inline
+	// values fail with ErrTooDeep, at the bracket that goes too deep.
+	MaxInlineDepth int
+
+	// MaxItems, when positive, is the most items any one array, or
+	// properties any one object, may hold.
+	MaxItems int
+
+	// MaxKeyBytes, when positive, is the longest object key accepted.
+	MaxKeyBytes int
+
+	// MaxStringBytes, when positive, is the longest string accepted,
+	// after escapes are decoded and block strings are assembled.
+	MaxStringBytes int
+
+	// MaxBlockBytes, when positive, is the most that the block strings
+	// and block byte arrays of a document may decode to, all told.
+	MaxBlockBytes int
+
+	// MemoryBudget, when positive, is roughly how much memory the decoded
+	// value may occupy. Each string, byte array, number, array, and object
+	// is charged an estimate of its size as it is built, and decoding fails
+	// with ErrMemoryBudget as soon as the total exceeds the budget. Unlike
+	// MaxInputBytes, this bounds the many small values of a short document
+	// as well as the few long ones of a large one.
+	MemoryBudget int
+}
+
+// ColumnUnit selects how columns in error positions are counted.
+type ColumnUnit int
+
+const (
+	// ColumnCodePoints counts Unicode code points, as a reader would.
+	ColumnCodePoints ColumnUnit = iota
+	// ColumnBytes counts bytes of UTF-8.
+	ColumnBytes
+	// ColumnUTF16 counts UTF-16 code units, as the Language Server
+	// Protocol and JavaScript strings do. Code points above U+FFFF
+	// count two.
+	ColumnUTF16
+)
+
+// UnmarshalWithOptions parses YAY-encoded data according to opts.
+func UnmarshalWithOptions(data []byte, opts DecodeOptions) (any, error) {
+	return unmarshal(data, opts.Filename, opts)
+}
+
+// UnmarshalFileWithOptions parses YAY-encoded data according to opts, with
+// a filename for error messages. The filename takes the place of
+// opts.Filename.
+func UnmarshalFileWithOptions(data []byte, filename string, opts DecodeOptions) (any, error) {
+	return unmarshal(data, filename, opts)
+}
+
+// Valid reports whether data is a well-formed YAY document.
+//
+// Valid makes every check Unmarshal makes, but keeps none of the values:
+// integers, strings, byte arrays, and arrays are checked and dropped
+// rather than built, and the source is read in place instead of copied.
+// Objects are still built, since the parser merges their properties as
+// it goes.
+func Valid(data []byte) bool {
+	return ValidFile(data, "") == nil
+}
+
+// ValidFile checks data, named filename, as Valid does, and returns the
+// error that UnmarshalFile would, giving its position in the file, for
+// pre-commit hooks and the like to report.
+func ValidFile(data []byte, filename string) error {
+	ctx := &parseContext{
+		filename: filename,
+		source:   unsafe.String(unsafe.SliceData(data), len(data)),
+		discard:  true,
+	}
+	_, err := parse(ctx)
+	return err
 }
 
 // Marshal returns the YAY encoding of v.
+//
+// Marshal accepts the values Unmarshal produces: nil, bool, *big.Int,
+// float64, string, []byte, []any, map[string]any, and *OrderedMap, as well
+// as Array, Go's other integer types, and float32. The keys of a
+// map[string]any are written in sorted order, and those of an *OrderedMap
+// in its own order.
+//
+// A value of a type with a codec registered with RegisterCodec is written
+// as the value its codec returns, and one of a Marshaler as the value of
+// the document its MarshalYAY method returns. Values of other types are
+// written by their kinds, as encoding/json writes them: a struct as an
+// object of its exported fields in the order they are declared, keyed by
+// their names or the names their `yay:"name"` tags give, leaving out
+// fields tagged `yay:"-"`, and those tagged `yay:",omitempty"` when they
+// are false, zero, nil, or of length zero; a slice or array as an array; a
+// map with string keys as an object; and a pointer as the value it points
+// to, or null.
 func Marshal(v any) ([]byte, error) {
-	// TODO: implement encoder
-	return nil, fmt.Errorf("not implemented")
+	return MarshalWithOptions(v, EncodeOptions{})
+}
+
+// EncodeOptions configures optional encoding behavior.
+// The zero value encodes exactly as Marshal does.
+type EncodeOptions struct {
+	// Codecs, if set, give the values to write for Go types of other
+	// packages, taking precedence over the codecs registered with
+	// RegisterCodec.
+	Codecs *Codecs
+
+	// OmitNull leaves out the properties of objects whose values are
+	// null, such as nil, nil pointers, and empty Optionals, rather than
+	// writing them as null, for consumers to whom a property that is
+	// absent means something other than one that is null. Array items
+	// are written as null regardless, since leaving them out would move
+	// the items after them.
+	OmitNull bool
+
+	// BlockStrings selects which strings are written as block strings,
+	// whose text follows a backtick on lines of their own, rather than
+	// quoted. A block string always ends with one newline, so a string
+	// that does not is either quoted or given one, as the mode says.
+	BlockStrings BlockStringMode
+
+	// InlineArrayMax and InlineObjectMax are the most items an array, and
+	// properties an object, may have to be written inline, on one line,
+	// when they hold only scalars. Larger collections are written as
+	// blocks, an item or property to a line. Zero leaves the defaults of
+	// 5 items and 3 properties, and a negative limit writes every
+	// non-empty collection as a block.
+	InlineArrayMax  int
+	InlineObjectMax int
+
+	// Redact, if set, is called with the path of each property and array
+	// item, as Get takes paths, such as ".database.password" or
+	// ".users[0]", and the values for which it reports true are written
+	// as the string "[redacted]", so that dumps of configuration do not
+	// disclose its secrets. See RedactKeys.
+	Redact func(path string) bool
+}
+
+// BlockStringMode selects which strings Marshal writes as block strings.
+// A collection holding one is written as a block, never inline.
+type BlockStringMode int
+
+const (
+	// BlockStringsNever quotes every string.
+	BlockStringsNever BlockStringMode = iota
+	// BlockStringsExact writes a string of lines, ending in a newline, as
+	// a block string when it reads back exactly, and quotes the rest.
+	BlockStringsExact
+	// BlockStringsAddNewline also writes a string of several lines that
+	// lacks only the final newline as a block string, which reads back
+	// with a newline added.
+	BlockStringsAddNewline
+)
+
+// MarshalWithOptions returns the YAY encoding of v according to opts.
+func MarshalWithOptions(v any, opts EncodeOptions) ([]byte, error) {
+	v, err := prepare(v, opts)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(v, opts)
+}
+
+// MustUnmarshal is like Unmarshal but panics with the error if data cannot
+// be parsed. It simplifies tests, examples, and the initialization of
+// variables from documents embedded in the program:
+//
+//	//go:embed defaults.yay
+//	var defaultsYAY []byte
+//
+//	var defaults = yay.MustUnmarshal(defaultsYAY)
+func MustUnmarshal(data []byte) any {
+	v, err := Unmarshal(data)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// MustMarshal is like Marshal but panics with the error if v cannot be
+// encoded.
+func MustMarshal(v any) []byte {
+	data, err := Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
 }
 
 // ============================================================================
 // Internal Types
 // ============================================================================
 
-// parseContext carries filename for error reporting through the parse phases.
+// parseContext carries filename for error reporting through the parse phases,
+// along with any per-parse state requested by DecodeOptions.
 type parseContext struct {
 	filename string
+	source   string            // Document text, for locating errors
+	keys     map[string]string // Interned object keys; nil unless interning
+	arena    *arena            // Slabs for batch allocation; nil unless batching
+	discard  bool              // Check values without keeping them, for Valid
+	sink     []byte            // Reused byte array storage when discarding
+	columns  ColumnUnit        // Unit of columns in error positions
+	ordered  bool              // Build objects as *OrderedMap
+	saturate bool              // Round out-of-range floats instead of failing
+	chomp    bool              // Drop the final newline of block strings
+	strict   bool              // Check indentation steps
+	limits   limits            // Limits from DecodeOptions
+
+	warn      func(Warning) // From DecodeOptions.Warn
+	messages  Catalog       // From DecodeOptions.Catalog
+	bom       bool          // Whether a BOM was stripped from the source
+	positions *positions    // Where values begin, when recording them
+	lists     []int         // Indents of the items of the block arrays being parsed
+}
+
+// internKey returns the canonical copy of k when key interning is enabled.
+// The first occurrence is cloned so that interned keys do not keep the
+// whole source text reachable.
+func (ctx *parseContext) internKey(k string) string {
+	if ctx == nil || ctx.keys == nil {
+		return k
+	}
+	if s, ok := ctx.keys[k]; ok {
+		return s
+	}
+	s := strings.Clone(k)
+	ctx.keys[s] = s
+	return s
 }
 
 // scanLine represents a single line after the scanning phase.
 // It captures the line's content, indentation level, and any list marker.
 type scanLine struct {
-	line    string // Content after indent and leader
-	indent  int    // Number of leading spaces
-	leader  string // "- " for list items, "" otherwise
-	lineNum int    // Zero-based line number for error reporting
+	line   string // Content after indent and leader
+	indent int    // Number of leading spaces
+	leader string // "- " for list items, "" otherwise
+	start  int    // Byte offset of the line in the source
 }
 
 // tokenType identifies the kind of token in the outline lexer output.
@@ -87,11 +448,74 @@ const (
 
 // token represents a single element in the token stream.
 type token struct {
-	typ     tokenType
-	text    string
-	indent  int
-	lineNum int
-	col     int
+	typ    tokenType
+	text   string
+	indent int
+	offset int // Byte offset of the token's first column in the source
+	count  int // On the first of a run of siblings, the run's length
+}
+
+// ============================================================================
+// Scratch Buffer Pools
+// ============================================================================
+//
+// Scan lines, tokens, block lines, and string/hex accumulators are transient:
+// none of them survive an Unmarshal call. Recycling them through sync.Pool
+// keeps them off the allocation profile of services that decode many small
+// documents. Scratch text is accumulated in bytes.Buffer rather than
+// strings.Builder because Builder.String hands its buffer to the result,
+// so a pooled Builder could never be reused safely.
+
+// maxPooledCap bounds the capacity of slices and buffers returned to a pool,
+// so that one unusually large document does not pin its scratch space forever.
+const maxPooledCap = 64 << 10
+
+// slicePool recycles scratch slices of T.
+type slicePool[T any] struct {
+	pool sync.Pool
+}
+
+// get returns an empty slice, reusing pooled capacity when available.
+func (p *slicePool[T]) get() []T {
+	if s, ok := p.pool.Get().(*[]T); ok {
+		return (*s)[:0]
+	}
+	return nil
+}
+
+// put returns s to the pool. Elements are cleared first so pooled slices
+// do not keep the previous document's source text reachable.
+func (p *slicePool[T]) put(s []T) {
+	if cap(s) == 0 || cap(s) > maxPooledCap {
+		return
+	}
+	clear(s)
+	p.pool.Put(&s)
+}
+
+var (
+	scanLinePool  slicePool[scanLine]
+	tokenPool     slicePool[token]
+	blockLinePool slicePool[blockLine]
+)
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty scratch buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a scratch buffer to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledCap {
+		return
+	}
+	bufferPool.Put(buf)
 }
 
 // ============================================================================
@@ -100,11 +524,14 @@ type token struct {
 
 // locSuffix formats a location suffix for error messages.
 // Returns empty string if no filename is set.
-// Uses 1-based line and column numbers for human-readable output.
-func locSuffix(ctx *parseContext, line, col int) string {
+// The parser tracks only byte offsets into the source; the line and column
+// are worked out here, once an error has actually occurred, and reported
+// 1-based for human-readable output.
+func locSuffix(ctx *parseContext, offset int) string {
 	if ctx == nil || ctx.filename == "" {
 		return ""
 	}
+	line, col := positionAt(ctx.source, offset, ctx.columns)
 	return fmt.Sprintf(" at %d:%d of <%s>", line+1, col+1, ctx.filename)
 }
 
@@ -113,49 +540,162 @@ func locSuffix(ctx *parseContext, line, col int) string {
 // ============================================================================
 //
 // The scanner converts raw source text into scan lines. It performs:
+//   - Version directive checking
 //   - UTF-8 validation (no BOM, no forbidden code points)
 //   - Whitespace validation (no tabs, no trailing spaces)
 //   - Indentation counting
 //   - List marker extraction (the "-" prefix)
 //   - Comment filtering
 
-func unmarshal(data []byte, filename string) (any, error) {
-	source := string(data)
-	ctx := &parseContext{filename: filename}
+func unmarshal(data []byte, filename string, opts DecodeOptions) (any, error) {
+	if opts.Recorder == nil {
+		return decodeDocument(data, filename, opts)
+	}
+	start := time.Now()
+	value, err := decodeDocument(data, filename, opts)
+	record(opts.Recorder, filename, len(data), start, err)
+	return value, err
+}
+
+// decodeDocument parses data, named filename, according to opts.
+func decodeDocument(data []byte, filename string, opts DecodeOptions) (any, error) {
+	return decodeSource(string(data), filename, opts)
+}
+
+// decodeSource parses source, named filename, according to opts. The
+// strings of the value it returns may share the memory of source.
+func decodeSource(source, filename string, opts DecodeOptions) (any, error) {
+	if err := checkInput(len(source), opts); err != nil {
+		if opts.MaxErrors > 0 {
+			return nil, collectErrors(source, err, filename, opts)
+		}
+		return nil, err
+	}
+	ctx := newParseContext(source, filename, opts)
+	if opts.Batch {
+		ctx.arena = &arena{}
+	}
+	value, err := parse(ctx)
+	if err != nil && opts.MaxErrors > 0 {
+		return nil, collectErrors(source, err, filename, opts)
+	}
+	if err != nil || !opts.JSONCompatible {
+		return value, err
+	}
+	return jsonCompatible(value, opts)
+}
+
+// newParseContext returns the context for parsing source according to opts.
+func newParseContext(source, filename string, opts DecodeOptions) *parseContext {
+	bom := opts.AllowBOM && strings.HasPrefix(source, "\uFEFF")
+	if bom {
+		source = source[len("\uFEFF"):]
+	}
+	ctx := &parseContext{
+		filename: filename,
+		source:   source,
+		columns:  opts.Columns,
+		ordered:  opts.PreserveKeyOrder,
+		saturate: opts.SaturateFloats,
+		chomp:    opts.ChompBlockStrings,
+		strict:   opts.StrictIndentation,
+		warn:     opts.Warn,
+		messages: opts.Catalog,
+		bom:      bom,
+		limits: limits{
+			line:   opts.MaxLineBytes,
+			depth:  opts.MaxDepth,
+			inline: opts.MaxInlineDepth,
+			items:  opts.MaxItems,
+			key:    opts.MaxKeyBytes,
+			str:    opts.MaxStringBytes,
+			block:  opts.MaxBlockBytes,
+			budget: opts.MemoryBudget,
+		},
+	}
+	if opts.InternKeys {
+		ctx.keys = make(map[string]string)
+	}
+	return ctx
+}
+
+// errInternal marks the error that stands in for a panic in the parser.
+// Any such panic is a bug, which the fuzz targets look for.
+var errInternal = errors.New("Internal error")
+
+// parse runs the parse phases over ctx.source.
+//
+// No input, however malformed, should make the parser panic. Each index
+// into the source or the tokens is guarded by a length check, and the fuzz
+// targets hold the parser and every entry point built on it, ParseAST,
+// Format, SortKeys, and Marshal among them, to that. As a backstop for
+// bugs that break that promise, parse recovers from a panic and reports it
+// as an error, since a panic in a configuration loader would take down the
+// whole process.
+func parse(ctx *parseContext) (value any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, fmt.Errorf("%w while parsing: %v", errInternal, r)
+		}
+	}()
+	source := ctx.source
+	if ctx.bom {
+		ctx.warnf(0, "Ignored BOM")
+	}
 
 	// Phase 1: Scan source into lines
-	lines, err := scan(source, ctx)
+	lines, err := scan(source, ctx, scanLinePool.get())
 	if err != nil {
 		return nil, err
 	}
 
 	// Phase 2: Convert lines to token stream
-	tokens := outlineLex(lines)
+	tokens := outlineLex(lines, tokenPool.get())
+	scanLinePool.put(lines)
 
 	// Phase 3: Parse tokens into value
-	return parseRoot(tokens, ctx)
+	value, err = parseRoot(tokens, ctx)
+	tokenPool.put(tokens)
+	return value, err
 }
 
 // scan converts source text into scan lines with validation.
-func scan(source string, ctx *parseContext) ([]scanLine, error) {
+// Lines are appended to buf, which may be a recycled scratch slice.
+func scan(source string, ctx *parseContext, buf []scanLine) ([]scanLine, error) {
+	// Validate: Supported version, ahead of anything a newer one may change
+	if _, err := checkVersion(source, ctx); err != nil {
+		return nil, err
+	}
+
 	// Validate: No BOM allowed
 	if err := validateNoBOM(source, ctx); err != nil {
 		return nil, err
 	}
 
-	// Validate: No forbidden code points
-	if err := validateCodePoints(source, ctx); err != nil {
+	// Validate: No line too long, ahead of the more costly checks
+	if err := checkLineLength(source, ctx); err != nil {
 		return nil, err
 	}
 
-	// Process each line
-	return scanLines(source, ctx)
+	// Process each line, checking its code points, indentation, and end
+	lines, err := scanLines(source, ctx, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate: Indentation steps, if asked
+	if ctx.strict {
+		if err := checkIndentation(lines, ctx); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
 }
 
 // validateNoBOM checks that the source doesn't start with a UTF-8 BOM.
 func validateNoBOM(source string, ctx *parseContext) error {
 	if len(source) >= 3 && source[0] == 0xEF && source[1] == 0xBB && source[2] == 0xBF {
-		return fmt.Errorf("Illegal BOM%s", locSuffix(ctx, 0, 0))
+		return ctx.errorf(0, "Illegal BOM")
 	}
 	return nil
 }
@@ -169,66 +709,155 @@ func isAllowedCodePoint(cp rune) bool {
 		(0x10000 <= cp && cp <= 0x10FFFF && (cp&0xFFFF) < 0xFFFE)
 }
 
+// asciiAllowed is a bit table of the ASCII bytes allowed in a YAY document:
+// line feed and the printable range U+0020 through U+007E.
+var asciiAllowed = func() (table [2]uint64) {
+	for c := 0; c < utf8.RuneSelf; c++ {
+		if isAllowedCodePoint(rune(c)) {
+			table[c>>6] |= 1 << (c & 63)
+		}
+	}
+	return table
+}()
+
 // validateCodePoints checks that the source contains no forbidden code points.
+// ASCII bytes are checked against a bit table; only bytes with the high bit
+// set fall back to UTF-8 decoding, which also catches malformed, truncated,
+// and overlong sequences. Line and column are worked out only once a
+// forbidden code point has been found.
 func validateCodePoints(source string, ctx *parseContext) error {
-	line := 0
-	col := 0
-	for _, r := range source {
-		if !isAllowedCodePoint(r) {
-			if r == '\t' {
-				return fmt.Errorf("Tab not allowed (use spaces)%s", locSuffix(ctx, line, col))
-			}
-			if r >= 0xD800 && r <= 0xDFFF {
-				return fmt.Errorf("Illegal surrogate%s", locSuffix(ctx, line, col))
+	for i := 0; i < len(source); {
+		c := source[i]
+		if c < utf8.RuneSelf {
+			if asciiAllowed[c>>6]&(1<<(c&63)) == 0 {
+				return codePointError(rune(c), source, i, ctx)
 			}
-			return fmt.Errorf("Forbidden code point U+%04X%s", r, locSuffix(ctx, line, col))
+			i++
+			continue
 		}
-		if r == '\n' {
-			line++
-			col = 0
-		} else {
-			col++
+		r, size := utf8.DecodeRuneInString(source[i:])
+		if r == utf8.RuneError && size == 1 {
+			// Decoding yields U+FFFD for a malformed sequence; a U+FFFD
+			// actually present in the source is three bytes long.
+			return invalidUTF8Error(source, i, ctx)
+		}
+		if !isAllowedCodePoint(r) {
+			return codePointError(r, source, i, ctx)
 		}
+		i += size
 	}
 	return nil
 }
 
+// invalidUTF8Error reports a malformed UTF-8 sequence at byte offset i.
+// The three-byte encodings of surrogates are reported as surrogates.
+func invalidUTF8Error(source string, i int, ctx *parseContext) error {
+	if i+2 < len(source) && source[i] == 0xED && source[i+1] >= 0xA0 && source[i+1] <= 0xBF && source[i+2]&0xC0 == 0x80 {
+		return codePointError(0xD800, source, i, ctx)
+	}
+	return ctx.errorf(i, "Invalid UTF-8 (byte offset %d)", i)
+}
+
+// codePointError reports forbidden code point r found at byte offset i.
+func codePointError(r rune, source string, i int, ctx *parseContext) error {
+	if r == '\t' {
+		return ctx.errorf(i, "Tab not allowed (use spaces)")
+	}
+	if r >= 0xD800 && r <= 0xDFFF {
+		return ctx.errorf(i, "Illegal surrogate")
+	}
+	return ctx.errorf(i, "Forbidden code point U+%04X", r)
+}
+
+// positionAt converts a byte offset into source to a zero-based line and
+// a zero-based column counted in unit.
+func positionAt(source string, offset int, unit ColumnUnit) (line, col int) {
+	lineStart := strings.LastIndexByte(source[:offset], '\n') + 1
+	line = strings.Count(source[:lineStart], "\n")
+	prefix := source[lineStart:offset]
+	switch unit {
+	case ColumnBytes:
+		col = len(prefix)
+	case ColumnUTF16:
+		for _, r := range prefix {
+			col += utf16Len(r)
+		}
+	default:
+		col = utf8.RuneCountInString(prefix)
+	}
+	return line, col
+}
+
+// utf16Len returns the number of UTF-16 code units that encode r.
+func utf16Len(r rune) int {
+	if r > 0xFFFF {
+		return 2
+	}
+	return 1
+}
+
 // scanLines processes each line of source, extracting indent and leader.
-func scanLines(source string, ctx *parseContext) ([]scanLine, error) {
-	var lines []scanLine
-	lineStrings := strings.Split(source, "\n")
+// It is one pass over the source: the leading spaces of each line are
+// counted as its indent, the rest of its bytes are checked against the
+// code points allowed, as validateCodePoints does, until the line feed
+// that ends it, and then its last byte is checked for a trailing space.
+// Only bytes beyond ASCII are decoded as UTF-8.
+func scanLines(source string, ctx *parseContext, lines []scanLine) ([]scanLine, error) {
+	for start := 0; ; {
+		i := start
+		for i < len(source) && source[i] == ' ' {
+			i++
+		}
+		indent := i - start
+		for i < len(source) {
+			c := source[i]
+			if c < utf8.RuneSelf {
+				if c == '\n' {
+					break
+				}
+				if asciiAllowed[c>>6]&(1<<(c&63)) == 0 {
+					return nil, codePointError(rune(c), source, i, ctx)
+				}
+				i++
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(source[i:])
+			if r == utf8.RuneError && size == 1 {
+				return nil, invalidUTF8Error(source, i, ctx)
+			}
+			if !isAllowedCodePoint(r) {
+				return nil, codePointError(r, source, i, ctx)
+			}
+			i += size
+		}
+		lineStr := source[start:i]
 
-	for lineNum, lineStr := range lineStrings {
 		// Validate: No trailing spaces
 		if len(lineStr) > 0 && lineStr[len(lineStr)-1] == ' ' {
-			return nil, fmt.Errorf("Unexpected trailing space%s", locSuffix(ctx, lineNum, len(lineStr)-1))
+			return nil, ctx.errorf(i-1, "Unexpected trailing space")
 		}
 
-		// Count leading spaces (indent)
-		indent := countIndent(lineStr)
-
-		rest := lineStr[indent:]
-
 		// Skip top-level comments
-		if strings.HasPrefix(rest, "#") && indent == 0 {
-			continue
+		rest := lineStr[indent:]
+		if indent > 0 || len(rest) == 0 || rest[0] != '#' {
+			// Extract leader (list marker) and content
+			leader, content, err := extractLeader(rest, start+indent, ctx)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, scanLine{
+				line:   content,
+				indent: indent,
+				leader: leader,
+				start:  start,
+			})
 		}
 
-		// Extract leader (list marker) and content
-		leader, content, err := extractLeader(rest, lineNum, indent, ctx)
-		if err != nil {
-			return nil, err
+		if i == len(source) {
+			return lines, nil
 		}
-
-		lines = append(lines, scanLine{
-			line:    content,
-			indent:  indent,
-			leader:  leader,
-			lineNum: lineNum,
-		})
+		start = i + 1
 	}
-
-	return lines, nil
 }
 
 // countIndent returns the number of leading spaces in a line.
@@ -243,7 +872,7 @@ func countIndent(line string) int {
 // extractLeader separates the list marker from line content.
 // Returns (leader, content, error) where leader is "- " for list items.
 // The list marker is always exactly two characters: dash and space.
-func extractLeader(rest string, lineNum, indent int, ctx *parseContext) (string, string, error) {
+func extractLeader(rest string, off int, ctx *parseContext) (string, string, error) {
 	// "- " prefix is the list marker (dash + space)
 	if strings.HasPrefix(rest, "- ") {
 		return "- ", rest[2:], nil
@@ -254,18 +883,101 @@ func extractLeader(rest string, lineNum, indent int, ctx *parseContext) (string,
 	if strings.HasPrefix(rest, "-") && len(rest) >= 2 {
 		second := rest[1]
 		if second != ' ' && second != '.' && !(second >= '0' && second <= '9') && rest != "-infinity" {
-			return "", "", fmt.Errorf("Expected space after \"-\"%s", locSuffix(ctx, lineNum, indent+1))
+			return "", "", ctx.errorf(off+1, "Expected space after \"%s\"", "-")
 		}
 	}
 
 	// "*" or "* " at top level is an error (asterisk multiline bytes not allowed at root)
 	if rest == "*" || strings.HasPrefix(rest, "* ") {
-		return "", "", fmt.Errorf("Unexpected character \"*\"%s", locSuffix(ctx, lineNum, indent))
+		return "", "", ctx.errorf(off, "Unexpected character \"%s\"", "*")
 	}
 
 	return "", rest, nil
 }
 
+// checkIndentation checks that each line either sits at the column of an
+// enclosing block or, directly below a line that leaves its value to the
+// lines that follow, is indented deeper. A line that dedents to a column
+// between two enclosing blocks, or that is indented under a complete value,
+// would otherwise be silently attached to the wrong parent.
+//
+// The body lines of block strings and block bytes are exempt, since their
+// indentation beyond the block's own is content, as are the lines after an
+// unclosed inline value, which the value parser reports.
+func checkIndentation(lines []scanLine, ctx *parseContext) error {
+	var stack [16]int
+	levels := stack[:1] // Columns of the enclosing blocks, innermost last
+	open := true        // Whether the previous line may have children
+	body := -1          // Indent that block body lines are deeper than
+
+	for _, sl := range lines {
+		content := sl.line
+		if sl.leader == "" && (content == "" || content[0] == '#') {
+			continue
+		}
+		if body >= 0 {
+			if sl.indent > body {
+				continue
+			}
+			body = -1
+		}
+
+		switch top := levels[len(levels)-1]; {
+		case sl.indent > top:
+			if !open {
+				return ctx.errorf(sl.start, "Unexpected indent")
+			}
+			levels = append(levels, sl.indent)
+		case sl.indent < top:
+			for len(levels) > 1 && levels[len(levels)-1] > sl.indent {
+				levels = levels[:len(levels)-1]
+			}
+			if levels[len(levels)-1] != sl.indent {
+				return ctx.errorf(sl.start, "Inconsistent indentation")
+			}
+		}
+
+		// Each list leader opens a block at the column of the item's value,
+		// where later lines may continue it.
+		if sl.leader != "" {
+			col := sl.indent
+			for {
+				col += 2
+				levels = append(levels, col)
+				if !strings.HasPrefix(content, "- ") {
+					break
+				}
+				content = content[2:]
+			}
+		}
+
+		content = stripComment(content)
+		open = content == "" || strings.HasSuffix(content, ":")
+		if opensBody(content) {
+			body = sl.indent
+		}
+	}
+	return nil
+}
+
+// opensBody reports whether the lines indented below content belong to it
+// rather than to the block structure: the body of a block string or block
+// bytes, or the continuation of an inline value left open.
+func opensBody(content string) bool {
+	if content == "" {
+		return false
+	}
+	switch content[0] {
+	case '`', '>':
+		return true
+	}
+	switch content[len(content)-1] {
+	case '[', '{', '<', ',':
+		return true
+	}
+	return strings.Contains(content, ": `") || strings.Contains(content, ": >")
+}
+
 // ============================================================================
 // Phase 2: Outline Lexer
 // ============================================================================
@@ -278,8 +990,8 @@ func extractLeader(rest string, lineNum, indent int, ctx *parseContext) (string,
 //   - tokenBreak: Blank lines (coalesced)
 
 // outlineLex converts scan lines to a token stream with block markers.
-func outlineLex(lines []scanLine) []token {
-	var tokens []token
+// Tokens are appended to tokens, which may be a recycled scratch slice.
+func outlineLex(lines []scanLine, tokens []token) []token {
 	stack := []int{0} // Indent level stack, starts at 0
 	top := 0          // Current indent level
 	broken := false   // Whether we just emitted a break
@@ -298,9 +1010,53 @@ func outlineLex(lines []scanLine) []token {
 	// Close any remaining open blocks
 	tokens = emitFinalStops(tokens, stack)
 
+	countSiblings(tokens)
 	return tokens
 }
 
+// siblingRun tracks a run of same-kind tokens at one indent.
+type siblingRun struct {
+	indent int
+	first  int // Index of the run's first token
+}
+
+// countSiblings records on the first token of each run of siblings how many
+// tokens the run holds: list starts at one indent become array elements and
+// text lines at one indent become object properties. The value parser uses
+// the counts to size arrays and maps up front. Runs end at any token of
+// lesser indent. The counts are only capacity hints, so the occasional
+// miscount (for example, lines of a block string) costs nothing but memory.
+func countSiblings(tokens []token) {
+	var starts, texts []siblingRun
+	for i := range tokens {
+		t := &tokens[i]
+		if t.typ != tokenStart && t.typ != tokenText {
+			continue
+		}
+		starts = popDeeperRuns(starts, t.indent)
+		texts = popDeeperRuns(texts, t.indent)
+
+		runs := &texts
+		if t.typ == tokenStart {
+			runs = &starts
+		}
+		if n := len(*runs); n > 0 && (*runs)[n-1].indent == t.indent {
+			tokens[(*runs)[n-1].first].count++
+			continue
+		}
+		t.count = 1
+		*runs = append(*runs, siblingRun{indent: t.indent, first: i})
+	}
+}
+
+// popDeeperRuns ends the runs nested deeper than indent.
+func popDeeperRuns(runs []siblingRun, indent int) []siblingRun {
+	for len(runs) > 0 && runs[len(runs)-1].indent > indent {
+		runs = runs[:len(runs)-1]
+	}
+	return runs
+}
+
 // emitDedents emits stop tokens when indentation decreases.
 func emitDedents(tokens []token, stack []int, top, indent int) ([]token, []int, int) {
 	for indent < top {
@@ -320,11 +1076,10 @@ func emitListStart(tokens []token, stack []int, top int, broken bool, sl scanLin
 	if sl.indent > top {
 		// New nested block
 		tokens = append(tokens, token{
-			typ:     tokenStart,
-			text:    sl.leader,
-			indent:  sl.indent,
-			lineNum: sl.lineNum,
-			col:     sl.indent,
+			typ:    tokenStart,
+			text:   sl.leader,
+			indent: sl.indent,
+			offset: sl.start + sl.indent,
 		})
 		stack = append(stack, sl.indent)
 		top = sl.indent
@@ -333,11 +1088,10 @@ func emitListStart(tokens []token, stack []int, top int, broken bool, sl scanLin
 		// Sibling item - close previous, start new
 		tokens = append(tokens, token{typ: tokenStop, text: ""})
 		tokens = append(tokens, token{
-			typ:     tokenStart,
-			text:    sl.leader,
-			indent:  sl.indent,
-			lineNum: sl.lineNum,
-			col:     sl.indent,
+			typ:    tokenStart,
+			text:   sl.leader,
+			indent: sl.indent,
+			offset: sl.start + sl.indent,
 		})
 		broken = false
 	}
@@ -349,11 +1103,10 @@ func emitListStart(tokens []token, stack []int, top int, broken bool, sl scanLin
 func emitContent(tokens []token, broken bool, sl scanLine) ([]token, bool) {
 	if len(sl.line) > 0 {
 		tokens = append(tokens, token{
-			typ:     tokenText,
-			text:    sl.line,
-			indent:  sl.indent,
-			lineNum: sl.lineNum,
-			col:     sl.indent,
+			typ:    tokenText,
+			text:   sl.line,
+			indent: sl.indent,
+			offset: sl.start + sl.indent,
 		})
 		return tokens, false
 	}
@@ -361,10 +1114,9 @@ func emitContent(tokens []token, broken bool, sl scanLine) ([]token, bool) {
 	// Empty line - emit break if not already broken
 	if !broken {
 		tokens = append(tokens, token{
-			typ:     tokenBreak,
-			text:    "",
-			lineNum: sl.lineNum,
-			col:     sl.indent,
+			typ:    tokenBreak,
+			text:   "",
+			offset: sl.start + sl.indent,
 		})
 		return tokens, true
 	}
@@ -394,21 +1146,15 @@ func emitFinalStops(tokens []token, stack []int) []token {
 
 // parseRoot is the entry point for parsing a YAY document.
 func parseRoot(tokens []token, ctx *parseContext) (any, error) {
-	i := skipBreaksAndStops(tokens, 0)
-	if i >= len(tokens) {
-		return nil, fmt.Errorf("No value found in document <%s>", ctx.filename)
+	i, err := findRoot(tokens, ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	t := tokens[i]
-
-	// Validate: No unexpected indent at root
-	if t.typ == tokenText && t.indent > 0 {
-		return nil, fmt.Errorf("Unexpected indent%s", locSuffix(ctx, t.lineNum, 0))
+	if ctx.positions != nil {
+		ctx.positions.root = tokens[i].offset
 	}
 
-	// Detect root object (key: value at indent 0)
-	// But not inline objects starting with {
-	if t.typ == tokenText && strings.Contains(t.text, ":") && t.indent == 0 && !strings.HasPrefix(t.text, "{") {
+	if isRootObject(tokens[i]) {
 		value, next, err := parseRootObject(tokens, i, ctx)
 		if err != nil {
 			return nil, err
@@ -424,12 +1170,34 @@ func parseRoot(tokens []token, ctx *parseContext) (any, error) {
 	return ensureAtEnd(value, tokens, next, ctx)
 }
 
+// findRoot returns the index of the first token of the root value.
+func findRoot(tokens []token, ctx *parseContext) (int, error) {
+	i := skipBreaksAndStops(tokens, 0)
+	if i >= len(tokens) {
+		return 0, ctx.unplacedErrorf("No value found in document <%s>", ctx.filename)
+	}
+
+	// Validate: No unexpected indent at root
+	if t := tokens[i]; t.typ == tokenText && t.indent > 0 {
+		return 0, ctx.errorf(t.offset-t.indent, "Unexpected indent")
+	}
+	return i, nil
+}
+
+// isRootObject reports whether t, the first token of the root value,
+// begins a block object (key: value at indent 0), but not an inline object
+// starting with {, and not for a colon within a quoted string.
+func isRootObject(t token) bool {
+	return t.typ == tokenText && t.indent == 0 && !strings.HasPrefix(t.text, "{") &&
+		findColonOutsideQuotes(t.text) >= 0
+}
+
 // ensureAtEnd verifies no content remains after parsing.
 func ensureAtEnd(value any, tokens []token, i int, ctx *parseContext) (any, error) {
 	j := skipBreaksAndStops(tokens, i)
 	if j < len(tokens) {
 		t := tokens[j]
-		return nil, fmt.Errorf("Unexpected extra content%s", locSuffix(ctx, t.lineNum, t.col))
+		return nil, ctx.errorf(t.offset, "Unexpected extra content")
 	}
 	return value, nil
 }
@@ -478,10 +1246,10 @@ func parseValue(tokens []token, i int, ctx *parseContext) (any, int, error) {
 // validateTextToken checks for invalid text patterns.
 func validateTextToken(t token, ctx *parseContext) error {
 	if strings.HasPrefix(t.text, " ") {
-		return fmt.Errorf("Unexpected leading space%s", locSuffix(ctx, t.lineNum, t.col))
+		return ctx.errorf(t.offset, "Unexpected leading space")
 	}
 	if t.text == "$" {
-		return fmt.Errorf("Unexpected character \"$\"%s", locSuffix(ctx, t.lineNum, t.col))
+		return ctx.errorf(t.offset, "Unexpected character \"%s\"", "$")
 	}
 	return nil
 }
@@ -497,7 +1265,7 @@ func parseTextValue(tokens []token, i int, ctx *parseContext) (any, int, error)
 	}
 
 	// Try numbers (with strict whitespace validation)
-	if num, ok, err := parseNumberStrict(s, ctx, t.lineNum, t.col); err != nil {
+	if num, ok, err := parseNumberStrict(s, ctx, t.offset); err != nil {
 		return nil, 0, err
 	} else if ok {
 		return num, i + 1, nil
@@ -507,12 +1275,15 @@ func parseTextValue(tokens []token, i int, ctx *parseContext) (any, int, error)
 	if isBlockStringStart(s) {
 		firstLine := extractBlockStringFirstLine(s)
 		// Use token's indent as base - block string content must be indented more
-		return parseBlockStringWithIndent(tokens, i, firstLine, false, t.indent)
+		return parseBlockStringWithIndent(tokens, i, firstLine, false, t.indent, ctx)
 	}
 
-	// Try quoted string
+	// Try quoted string, unless it is a quoted key
 	if isQuotedString(s) {
-		str, err := parseQuotedString(s, ctx, t.lineNum, t.col)
+		if colonIdx := findColonOutsideQuotes(s); colonIdx >= 0 {
+			return parseKeyValuePair(tokens, i, colonIdx, ctx)
+		}
+		str, err := parseQuotedString(s, ctx, t.offset)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -531,7 +1302,7 @@ func parseTextValue(tokens []token, i int, ctx *parseContext) (any, int, error)
 
 	// Try inline bytes
 	if strings.HasPrefix(s, "<") && strings.Contains(s, ">") {
-		bytes, err := parseAngleBytesStrict(s, ctx, t.lineNum, t.col)
+		bytes, err := parseAngleBytesStrict(s, ctx, t.offset)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -549,7 +1320,7 @@ func parseTextValue(tokens []token, i int, ctx *parseContext) (any, int, error)
 	}
 
 	// Fall back to scalar
-	scalar, err := parseScalar(s, ctx, t.lineNum, t.col)
+	scalar, err := parseScalar(s, ctx, t.offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -584,13 +1355,55 @@ func parseKeyword(s string) (any, bool) {
 // Number Parsing
 // ============================================================================
 
-var (
-	integerRe = regexp.MustCompile(`^-?\d+$`)
-	// Float patterns: with decimal point, or with exponent, or both
-	floatRe = regexp.MustCompile(`^-?\d*\.\d*([eE][+-]?\d+)?$`)
-	// Exponent-only float (no decimal point): e.g., 1e10
-	floatExpRe = regexp.MustCompile(`^-?\d+[eE][+-]?\d+$`)
-)
+// The shapes of numbers are matched by hand rather than with regular
+// expressions, so that the parser does not need package regexp (see
+// tiny.go).
+
+// isInteger reports whether s is an integer: an optional minus sign and
+// one or more digits.
+func isInteger(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return s != "" && skipDigits(s, 0) == len(s)
+}
+
+// floatShape reports whether s is a float: an optional minus sign, then
+// digits with a decimal point among or after them, an exponent, or both,
+// but not "." or "-." alone. It reports too whether s has a decimal point.
+func floatShape(s string) (ok, point bool) {
+	s = strings.TrimPrefix(s, "-")
+	i := skipDigits(s, 0)
+	digits := i > 0
+	if i < len(s) && s[i] == '.' {
+		point = true
+		i = skipDigits(s, i+1)
+	}
+	if !digits && !point || s == "." {
+		return false, point
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		j := skipDigits(s, i)
+		if j == i {
+			return false, point
+		}
+		i = j
+	} else if !point {
+		return false, point
+	}
+	return i == len(s), point
+}
+
+// skipDigits returns the index of the first byte of s at or after i that
+// is not a digit.
+func skipDigits(s string, i int) int {
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return i
+}
 
 // parseNumber attempts to parse s as a number.
 // Returns (*big.Int, true) for integers, (float64, true) for floats, (nil, false) otherwise.
@@ -599,22 +1412,14 @@ func parseNumber(s string) (any, bool) {
 	trimmed := strings.ReplaceAll(s, " ", "")
 
 	// Try integer
-	if integerRe.MatchString(trimmed) {
+	if isInteger(trimmed) {
 		n := new(big.Int)
 		n.SetString(trimmed, 10)
 		return n, true
 	}
 
-	// Try float with exponent only (no decimal point)
-	if floatExpRe.MatchString(trimmed) {
-		f, err := strconv.ParseFloat(trimmed, 64)
-		if err == nil {
-			return f, true
-		}
-	}
-
-	// Try float (must have decimal point, but not just "." or "-.")
-	if floatRe.MatchString(trimmed) && trimmed != "." && trimmed != "-." {
+	// Try float, with a decimal point, an exponent, or both
+	if ok, _ := floatShape(trimmed); ok {
 		f, err := strconv.ParseFloat(trimmed, 64)
 		if err == nil {
 			return f, true
@@ -626,23 +1431,23 @@ func parseNumber(s string) (any, bool) {
 
 // parseNumberStrict parses a number with strict whitespace validation.
 // Spaces are allowed for digit grouping in integers, but not around decimal points.
-func parseNumberStrict(s string, ctx *parseContext, lineNum, col int) (any, bool, error) {
-	// Check if it looks like a number at all
-	trimmed := strings.ReplaceAll(s, " ", "")
-	if trimmed == "" {
+func parseNumberStrict(s string, ctx *parseContext, off int) (any, bool, error) {
+	// Check if first char indicates a number (digit, minus, or leading dot)
+	// before paying to remove digit-grouping spaces
+	lead := strings.TrimLeft(s, " ")
+	if lead == "" {
 		return nil, false, nil
 	}
-
-	// Check if first char indicates a number (digit, minus, or leading dot)
-	firstChar := trimmed[0]
+	firstChar := lead[0]
 	if firstChar != '-' && firstChar != '.' && (firstChar < '0' || firstChar > '9') {
 		return nil, false, nil
 	}
+	trimmed := strings.ReplaceAll(lead, " ", "")
 
 	// Check for uppercase E in exponent (must be lowercase)
 	eIdx := strings.Index(s, "E")
 	if eIdx >= 0 {
-		return nil, false, fmt.Errorf("Uppercase exponent (use lowercase 'e')%s", locSuffix(ctx, lineNum, col+eIdx))
+		return nil, false, ctx.errorf(off+eIdx, "Uppercase exponent (use lowercase 'e')")
 	}
 
 	// Check for spaces around decimal point
@@ -650,38 +1455,74 @@ func parseNumberStrict(s string, ctx *parseContext, lineNum, col int) (any, bool
 	if dotIdx >= 0 {
 		// Check for space before decimal point (but not if dot is at start)
 		if dotIdx > 0 && s[dotIdx-1] == ' ' {
-			return nil, false, fmt.Errorf("Unexpected space in number%s", locSuffix(ctx, lineNum, col+dotIdx-1))
+			return nil, false, ctx.errorf(off+dotIdx-1, "Unexpected space in number")
 		}
 		// Check for space after decimal point
 		if dotIdx < len(s)-1 && s[dotIdx+1] == ' ' {
-			return nil, false, fmt.Errorf("Unexpected space in number%s", locSuffix(ctx, lineNum, col+dotIdx+1))
+			return nil, false, ctx.errorf(off+dotIdx+1, "Unexpected space in number")
 		}
 	}
 
 	// Try integer
-	if integerRe.MatchString(trimmed) {
-		n := new(big.Int)
-		n.SetString(trimmed, 10)
-		return n, true, nil
+	if isInteger(trimmed) {
+		if err := ctx.chargeInt(trimmed, off); err != nil {
+			return nil, false, err
+		}
+		return ctx.newInt(trimmed), true, nil
 	}
 
-	// Try float with exponent only (no decimal point)
-	if floatExpRe.MatchString(trimmed) {
-		f, err := strconv.ParseFloat(trimmed, 64)
-		if err == nil {
-			return f, true, nil
+	// Try float, with a decimal point, an exponent, or both (but not just
+	// "." or "-.")
+	if ok, _ := floatShape(trimmed); ok {
+		f, ok, err := ctx.parseFloat(trimmed, off+len(s)-len(lead))
+		if ok && err == nil {
+			err = ctx.charge(sizeFloat, off)
+		}
+		if ok || err != nil {
+			return ctx.newFloat(f), ok, err
 		}
 	}
 
-	// Try float (must have decimal point, but not just "." or "-.")
-	if floatRe.MatchString(trimmed) && trimmed != "." && trimmed != "-." {
-		f, err := strconv.ParseFloat(trimmed, 64)
-		if err == nil {
-			return f, true, nil
+	return nil, false, nil
+}
+
+// parseFloat converts s, which matches one of the float patterns, to a
+// float64, reporting false if it is not a float after all (as ".e5" is
+// not). A value too large for float64 is an error rather than an
+// infinity, and a nonzero value too small for float64 is an error rather
+// than zero, unless DecodeOptions.SaturateFloats asks for the rounded
+// result.
+func (ctx *parseContext) parseFloat(s string, off int) (float64, bool, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false, nil
+	}
+	if ctx != nil && ctx.saturate {
+		if err != nil {
+			ctx.warnf(off, "Float overflow rounded to %v", f)
+		} else if f == 0 && hasNonzeroMantissa(s) {
+			ctx.warnf(off, "Float underflow rounded to 0")
 		}
+		return f, true, nil
+	}
+	if err != nil {
+		return 0, false, ctx.errorf(off, "Float overflow")
+	}
+	if f == 0 && hasNonzeroMantissa(s) {
+		return 0, false, ctx.errorf(off, "Float underflow")
 	}
+	return f, true, nil
+}
 
-	return nil, false, nil
+// hasNonzeroMantissa reports whether the digits before any exponent in s
+// include one other than zero.
+func hasNonzeroMantissa(s string) bool {
+	for i := 0; i < len(s) && s[i] != 'e' && s[i] != 'E'; i++ {
+		if s[i] >= '1' && s[i] <= '9' {
+			return true
+		}
+	}
+	return false
 }
 
 // ============================================================================
@@ -709,35 +1550,44 @@ func isQuotedString(s string) bool {
 }
 
 // parseQuotedString parses a quoted string value.
-func parseQuotedString(s string, ctx *parseContext, lineNum, col int) (string, error) {
+func parseQuotedString(s string, ctx *parseContext, off int) (string, error) {
 	if strings.HasPrefix(s, "\"") {
-		return parseDoubleQuotedString(s, ctx, lineNum, col)
+		str, err := parseDoubleQuotedString(s, ctx, off)
+		if err != nil {
+			return "", err
+		}
+		return str, ctx.checkString(str, off)
 	}
 	if strings.HasPrefix(s, "'") {
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return "", ctx.errorf(off+len(s)-1, "Unterminated string")
+		}
 		// Single-quoted strings are literal (no escapes)
-		return s[1 : len(s)-1], nil
+		return s[1 : len(s)-1], ctx.checkString(s[1:len(s)-1], off)
 	}
 	return s, nil
 }
 
 // parseDoubleQuotedString parses a JSON-style double-quoted string.
-func parseDoubleQuotedString(s string, ctx *parseContext, lineNum, col int) (string, error) {
+func parseDoubleQuotedString(s string, ctx *parseContext, off int) (string, error) {
 	if len(s) < 2 || s[0] != '"' {
 		return s, nil
 	}
 	if s[len(s)-1] != '"' {
-		return "", fmt.Errorf("Unterminated string%s", locSuffix(ctx, lineNum, col+len(s)-1))
+		return "", ctx.errorf(off+len(s)-1, "Unterminated string")
 	}
 
-	var out strings.Builder
-	runes := []rune(s)
+	out := getBuffer()
+	defer putBuffer(out)
 
-	for i := 1; i < len(runes)-1; i++ {
-		ch := runes[i]
+	// Every byte of a multi-byte UTF-8 sequence is at least 0x80, so the
+	// string can be walked byte by byte, copying all but escapes verbatim.
+	for i := 1; i < len(s)-1; i++ {
+		ch := s[i]
 
 		if ch == '\\' {
 			// Handle escape sequence
-			escaped, advance, err := parseEscapeSequence(runes, i, ctx, lineNum, col)
+			escaped, advance, err := parseEscapeSequence(s, i, ctx, off)
 			if err != nil {
 				return "", err
 			}
@@ -745,23 +1595,23 @@ func parseDoubleQuotedString(s string, ctx *parseContext, lineNum, col int) (str
 			i += advance
 		} else if ch < 0x20 {
 			// Control characters not allowed
-			return "", fmt.Errorf("Bad character in string%s", locSuffix(ctx, lineNum, col+i))
+			return "", ctx.errorf(off+i, "Bad character in string")
 		} else {
-			out.WriteRune(ch)
+			out.WriteByte(ch)
 		}
 	}
 
-	return out.String(), nil
+	return ctx.newString(out.Bytes()), nil
 }
 
 // parseEscapeSequence parses a backslash escape sequence.
-// Returns (unescaped string, characters to advance, error).
-func parseEscapeSequence(runes []rune, i int, ctx *parseContext, lineNum, col int) (string, int, error) {
-	if i+1 >= len(runes)-1 {
-		return "", 0, fmt.Errorf("Bad escaped character%s", locSuffix(ctx, lineNum, col+i+1))
+// Returns (unescaped string, bytes to advance, error).
+func parseEscapeSequence(s string, i int, ctx *parseContext, off int) (string, int, error) {
+	if i+1 >= len(s)-1 {
+		return "", 0, ctx.errorf(off+i+1, "Bad escaped character")
 	}
 
-	esc := runes[i+1]
+	esc := s[i+1]
 	switch esc {
 	case '"':
 		return "\"", 1, nil
@@ -780,68 +1630,66 @@ func parseEscapeSequence(runes []rune, i int, ctx *parseContext, lineNum, col in
 	case 't':
 		return "\t", 1, nil
 	case 'u':
-		return parseUnicodeEscape(runes, i, ctx, lineNum, col)
+		return parseUnicodeEscape(s, i, ctx, off)
 	default:
-		return "", 0, fmt.Errorf("Bad escaped character%s", locSuffix(ctx, lineNum, col+i+1))
+		return "", 0, ctx.errorf(off+i+1, "Bad escaped character")
 	}
 }
 
 // parseUnicodeEscape parses a \u{XXXXXX} escape sequence (variable-length with braces).
-func parseUnicodeEscape(runes []rune, i int, ctx *parseContext, lineNum, col int) (string, int, error) {
-	// Column of the 'u' character (for "Bad escaped character" error)
-	uCol := col + i + 1
-	// Column of the opening brace (for other errors)
-	braceCol := col + i + 2
+func parseUnicodeEscape(s string, i int, ctx *parseContext, off int) (string, int, error) {
+	// Offset of the 'u' character (for "Bad escaped character" error)
+	uOff := off + i + 1
+	// Offset of the opening brace (for other errors)
+	braceOff := off + i + 2
 
 	// Expect opening brace after \u
-	if i+2 >= len(runes)-1 || runes[i+2] != '{' {
+	if i+2 >= len(s)-1 || s[i+2] != '{' {
 		// Old-style \uXXXX syntax is not supported - report as bad escaped character
-		return "", 0, fmt.Errorf("Bad escaped character%s", locSuffix(ctx, lineNum, uCol))
+		return "", 0, ctx.errorf(uOff, "Bad escaped character")
 	}
 
 	// Find closing brace
 	start := i + 3
 	end := start
-	for end < len(runes)-1 && runes[end] != '}' {
+	for end < len(s)-1 && s[end] != '}' {
 		end++
 	}
 
-	if end >= len(runes)-1 || runes[end] != '}' {
-		return "", 0, fmt.Errorf("Bad Unicode escape%s", locSuffix(ctx, lineNum, braceCol))
+	if end >= len(s)-1 || s[end] != '}' {
+		return "", 0, ctx.errorf(braceOff, "Bad Unicode escape")
 	}
 
 	// Validate hex digits
 	for j := start; j < end; j++ {
-		if !isHexDigit(runes[j]) {
-			return "", 0, fmt.Errorf("Bad Unicode escape%s", locSuffix(ctx, lineNum, braceCol))
+		if !isHexDigit(rune(s[j])) {
+			return "", 0, ctx.errorf(braceOff, "Bad Unicode escape")
 		}
 	}
 
 	if end == start {
-		return "", 0, fmt.Errorf("Bad Unicode escape%s", locSuffix(ctx, lineNum, braceCol))
+		return "", 0, ctx.errorf(braceOff, "Bad Unicode escape")
 	}
 
 	// Too many hex digits (max 6 for Unicode code points up to 10FFFF)
 	if end-start > 6 {
-		return "", 0, fmt.Errorf("Bad Unicode escape%s", locSuffix(ctx, lineNum, braceCol))
+		return "", 0, ctx.errorf(braceOff, "Bad Unicode escape")
 	}
 
 	// Parse code point
-	hexStr := string(runes[start:end])
-	var code int64
-	fmt.Sscanf(hexStr, "%x", &code)
+	code, _ := strconv.ParseInt(s[start:end], 16, 64)
 
 	// Reject surrogates
 	if code >= 0xD800 && code <= 0xDFFF {
-		return "", 0, fmt.Errorf("Illegal surrogate%s", locSuffix(ctx, lineNum, braceCol))
+		return "", 0, ctx.errorf(braceOff, "Illegal surrogate")
 	}
 
 	// Reject code points beyond Unicode range
 	if code > 0x10FFFF {
-		return "", 0, fmt.Errorf("Unicode code point out of range%s", locSuffix(ctx, lineNum, braceCol))
+		return "", 0, ctx.errorf(braceOff, "Unicode code point out of range")
 	}
 
-	// Return the character and the number of runes consumed (including \u{...})
+	// Return the character and the number of bytes consumed (including \u{...})
 	// advance = length of "u{...}" = 1 + 1 + (end-start) + 1 = end - start + 3
 	advance := end - i
 	return string(rune(code)), advance, nil
@@ -859,32 +1707,42 @@ func isHexDigit(r rune) bool {
 // parseBlockString parses a multiline block string.
 // firstLine is the content on the same line as the opening backtick (empty if backtick alone).
 // inPropertyContext indicates if this is a property value (affects leading newline behavior).
-func parseBlockString(tokens []token, i int, firstLine string, inPropertyContext bool) (string, int, error) {
-	return parseBlockStringWithIndent(tokens, i, firstLine, inPropertyContext, -1)
+func parseBlockString(tokens []token, i int, firstLine string, inPropertyContext bool, ctx *parseContext) (string, int, error) {
+	return parseBlockStringWithIndent(tokens, i, firstLine, inPropertyContext, -1, ctx)
 }
 
 // parseBlockStringWithIndent parses a multiline block string with a base indent constraint.
 // baseIndent is the indent of the key; content must be at indent > baseIndent.
 // If baseIndent is -1, no indent constraint is applied.
-func parseBlockStringWithIndent(tokens []token, i int, firstLine string, inPropertyContext bool, baseIndent int) (string, int, error) {
-	var lines []string
-	if firstLine != "" {
-		lines = append(lines, firstLine)
-	}
+func parseBlockStringWithIndent(tokens []token, i int, firstLine string, inPropertyContext bool, baseIndent int, ctx *parseContext) (string, int, error) {
+	off := tokens[i].offset
 	i++
 
 	// Collect continuation lines with their indentation
-	continuationLines, i := collectBlockStringLinesWithIndent(tokens, i, baseIndent)
-
-	// Normalize indentation
-	lines = append(lines, normalizeBlockIndent(continuationLines)...)
+	continuationLines, i := collectBlockStringLinesWithIndent(tokens, i, baseIndent, blockLinePool.get())
 
 	// Build result with appropriate leading newline
-	body := buildBlockStringResult(firstLine, lines, inPropertyContext)
+	body := assembleBlockString(firstLine, continuationLines, firstLine == "" && !inPropertyContext)
+	blockLinePool.put(continuationLines)
 	if body == "" {
-		return "", i, fmt.Errorf("Empty block string not allowed (use \"\" or \"\\n\" explicitly)")
+		return "", i, ctx.unplacedErrorf("Empty block string not allowed (use \"\" or \"\\n\" explicitly)")
+	}
+	if err := ctx.checkString(body, off); err != nil {
+		return "", 0, err
 	}
-	return body, i, nil
+	if err := ctx.countBlock(len(body), off); err != nil {
+		return "", 0, err
+	}
+	return ctx.chompBlock(body), i, nil
+}
+
+// chompBlock returns body, an assembled block string, without its final
+// newline when ChompBlockStrings asks for that.
+func (ctx *parseContext) chompBlock(body string) string {
+	if ctx.chomp {
+		return strings.TrimSuffix(body, "\n")
+	}
+	return body
 }
 
 // blockLine represents a line in a block string with its indent.
@@ -896,13 +1754,13 @@ type blockLine struct {
 
 // collectBlockStringLines gathers continuation lines for a block string.
 func collectBlockStringLines(tokens []token, i int) ([]blockLine, int) {
-	return collectBlockStringLinesWithIndent(tokens, i, -1)
+	return collectBlockStringLinesWithIndent(tokens, i, -1, nil)
 }
 
 // collectBlockStringLinesWithIndent gathers continuation lines with an indent constraint.
 // If baseIndent >= 0, only collect lines with indent > baseIndent.
-func collectBlockStringLinesWithIndent(tokens []token, i int, baseIndent int) ([]blockLine, int) {
-	var lines []blockLine
+// Lines are appended to lines, which may be a recycled scratch slice.
+func collectBlockStringLinesWithIndent(tokens []token, i int, baseIndent int, lines []blockLine) ([]blockLine, int) {
 
 	for i < len(tokens) && (tokens[i].typ == tokenText || tokens[i].typ == tokenBreak) {
 		if tokens[i].typ == tokenBreak {
@@ -920,59 +1778,66 @@ func collectBlockStringLinesWithIndent(tokens []token, i int, baseIndent int) ([
 	return lines, i
 }
 
-// normalizeBlockIndent strips the minimum indentation from block lines.
-func normalizeBlockIndent(contLines []blockLine) []string {
+// assembleBlockString constructs the final block string from the text on the
+// opening line (if any) and the continuation lines, which keep their
+// indentation relative to the least indented among them.
+// Empty lines in the middle are preserved as newlines.
+// Trailing empty lines collapse to a single trailing newline.
+// leadingNewline adds a newline before the content, as when the backtick
+// stands alone on its line at root or array level.
+// The result is measured first and written into a single allocation.
+func assembleBlockString(firstLine string, contLines []blockLine, leadingNewline bool) string {
+	// Trim trailing empty lines (they collapse to single trailing newline)
+	end := len(contLines)
+	for end > 0 && contLines[end-1].isBreak {
+		end--
+	}
+	contLines = contLines[:end]
+	if firstLine == "" && len(contLines) == 0 {
+		return ""
+	}
+
 	// Find minimum indent among non-break lines
-	minIndent := int(^uint(0) >> 1) // MaxInt
+	minIndent := -1
 	for _, cl := range contLines {
-		if !cl.isBreak && cl.indent < minIndent {
+		if !cl.isBreak && (minIndent < 0 || cl.indent < minIndent) {
 			minIndent = cl.indent
 		}
 	}
-	if minIndent == int(^uint(0)>>1) {
-		minIndent = 0
-	}
 
-	// Build lines with relative indentation
-	var lines []string
+	size := 0
+	if leadingNewline {
+		size++
+	}
+	if firstLine != "" {
+		size += len(firstLine) + 1
+	}
 	for _, cl := range contLines {
-		if cl.isBreak {
-			lines = append(lines, "")
-		} else {
-			extra := cl.indent - minIndent
-			prefix := ""
-			if extra > 0 {
-				prefix = strings.Repeat(" ", extra)
-			}
-			lines = append(lines, prefix+cl.text)
+		if !cl.isBreak {
+			size += cl.indent - minIndent + len(cl.text)
 		}
+		size++
 	}
 
-	return lines
-}
-
-// buildBlockStringResult constructs the final block string.
-// At root/array level: adds leading newline when backtick was alone on its line.
-// In property context: no leading newline.
-// Empty lines in the middle are preserved as newlines.
-// Trailing empty lines collapse to a single trailing newline.
-func buildBlockStringResult(firstLine string, lines []string, inPropertyContext bool) string {
-	// Trim trailing empty lines (they collapse to single trailing newline)
-	trimmed := trimTrailingEmpty(lines)
-
-	// Leading newline only when backtick alone and NOT in property context
-	leadingNewline := firstLine == "" && len(trimmed) > 0 && !inPropertyContext
-
-	var body string
+	var b strings.Builder
+	b.Grow(size)
 	if leadingNewline {
-		body = "\n"
+		b.WriteByte('\n')
 	}
-	body += strings.Join(trimmed, "\n")
-	if len(trimmed) > 0 {
-		body += "\n"
+	if firstLine != "" {
+		b.WriteString(firstLine)
+		b.WriteByte('\n')
 	}
-
-	return body
+	for _, cl := range contLines {
+		if !cl.isBreak {
+			for extra := cl.indent - minIndent; extra > 0; extra-- {
+				b.WriteByte(' ')
+			}
+			b.WriteString(cl.text)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
 }
 
 // ============================================================================
@@ -982,9 +1847,9 @@ func buildBlockStringResult(firstLine string, lines []string, inPropertyContext
 // parseInlineArrayValue parses an inline array from a text token.
 func parseInlineArrayValue(s string, t token, i int, ctx *parseContext) (any, int, error) {
 	if !strings.Contains(s, "]") {
-		return nil, 0, fmt.Errorf("Unexpected newline in inline array%s", locSuffix(ctx, t.lineNum, t.col))
+		return nil, 0, ctx.errorf(t.offset, "Unexpected newline in inline array")
 	}
-	arr, err := parseInlineArrayStrict(s, ctx, t.lineNum, t.col)
+	arr, err := parseInlineArrayStrict(s, ctx, t.offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -993,9 +1858,9 @@ func parseInlineArrayValue(s string, t token, i int, ctx *parseContext) (any, in
 
 func parseInlineObjectValue(s string, t token, i int, ctx *parseContext) (any, int, error) {
 	if !strings.Contains(s, "}") {
-		return nil, 0, fmt.Errorf("Unexpected newline in inline object%s", locSuffix(ctx, t.lineNum, t.col))
+		return nil, 0, ctx.errorf(t.offset, "Unexpected newline in inline object")
 	}
-	obj, err := parseInlineObjectStrict(s, ctx, t.lineNum, t.col)
+	obj, err := parseInlineObjectStrict(s, ctx, t.offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -1003,177 +1868,338 @@ func parseInlineObjectValue(s string, t token, i int, ctx *parseContext) (any, i
 }
 
 // parseInlineArrayStrict parses an inline array with strict whitespace validation.
-func parseInlineArrayStrict(s string, ctx *parseContext, lineNum, col int) ([]any, error) {
+func parseInlineArrayStrict(s string, ctx *parseContext, off int) ([]any, error) {
 	s = strings.TrimSpace(s)
 	if !strings.HasPrefix(s, "[") {
-		return nil, fmt.Errorf("Expected array%s", locSuffix(ctx, lineNum, col))
+		return nil, ctx.errorf(off, "Expected array")
 	}
 	if !strings.HasSuffix(s, "]") {
-		return nil, fmt.Errorf("Unterminated inline array%s", locSuffix(ctx, lineNum, col))
+		return nil, ctx.errorf(off, "Unterminated inline array")
 	}
-	if s == "[]" {
-		return []any{}, nil
+	// Like the reference implementation, report spaces just inside the
+	// outer brackets ahead of anything inside the array.
+	if len(s) > 2 && s[1] == ' ' {
+		return nil, ctx.errorf(off+1, "Unexpected space after \"%s\"", "[")
 	}
-
-	// Validate whitespace
-	if err := validateInlineSyntax(s, ctx, lineNum, col, '[', ']'); err != nil {
+	if len(s) > 2 && s[len(s)-2] == ' ' {
+		return nil, ctx.errorf(off+len(s)-2, "Unexpected space before \"%s\"", "]")
+	}
+	p := &inlineParser{s: s, ctx: ctx, off: off}
+	arr, err := p.parseCollection()
+	if err != nil {
 		return nil, err
 	}
+	return arr.([]any), p.expectEnd()
+}
 
-	inner := strings.TrimSpace(s[1 : len(s)-1])
-	if inner == "" {
-		return []any{}, nil
+// parseInlineObjectStrict parses an inline object with strict whitespace validation.
+func parseInlineObjectStrict(s string, ctx *parseContext, off int) (any, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return nil, ctx.errorf(off, "Expected object")
 	}
+	if !strings.HasSuffix(s, "}") {
+		return nil, ctx.errorf(off, "Unterminated inline object")
+	}
+	// Like the reference implementation, report spaces just inside the
+	// outer brackets ahead of anything inside the object.
+	if len(s) > 2 && s[1] == ' ' {
+		return nil, ctx.errorf(off+1, "Unexpected space after \"%s\"", "{")
+	}
+	if len(s) > 2 && s[len(s)-2] == ' ' {
+		return nil, ctx.errorf(off+len(s)-2, "Unexpected space before \"%s\"", "}")
+	}
+	p := &inlineParser{s: s, ctx: ctx, off: off}
+	obj, err := p.parseCollection()
+	if err != nil {
+		return nil, err
+	}
+	return obj, p.expectEnd()
+}
 
-	var result []any
-	remaining := inner
-	offset := 1 // Start after '['
-
-	for len(remaining) > 0 {
-		remaining = strings.TrimLeft(remaining, " ")
+// inlineParser parses inline arrays and objects in a single left-to-right
+// pass. Whitespace rules are checked at each separator as it is reached,
+// so nested collections are never rescanned and parsing is linear in the
+// length of the line.
+type inlineParser struct {
+	s   string
+	pos int // Byte offset of the next unread character
+	ctx *parseContext
+	off int // Byte offset of s in the source
+}
 
-		value, consumed, err := parseInlineValueStrict(remaining, ctx, lineNum, col+offset)
-		if err != nil {
-			return nil, err
-		}
+// peek returns the byte at offset k from the current position, or 0 past the end.
+func (p *inlineParser) peek(k int) byte {
+	if p.pos+k < len(p.s) {
+		return p.s[p.pos+k]
+	}
+	return 0
+}
 
-		result = append(result, value)
-		remaining = remaining[consumed:]
-		offset += consumed
-		remaining = strings.TrimLeft(remaining, " ")
+// errorf reports an error k bytes past the current position.
+func (p *inlineParser) errorf(k int, format string, args ...any) error {
+	return p.ctx.errorf(p.off+p.pos+k, format, args...)
+}
 
-		// Skip comma
-		if strings.HasPrefix(remaining, ",") {
-			remaining = remaining[1:]
-			offset++
-		}
+// expectEnd verifies that the outermost collection ended the text.
+func (p *inlineParser) expectEnd() error {
+	if p.pos < len(p.s) {
+		return p.errorf(0, "Unexpected extra content")
 	}
-
-	return result, nil
+	return nil
 }
 
-// validateInlineSyntax validates whitespace in inline arrays/objects.
-// Checks for:
-// - No tabs anywhere
-// - No space after opening bracket/brace
-// - No space before closing bracket/brace
-// - No space before comma
-// - Exactly one space after comma (unless followed by closing bracket/brace)
-func validateInlineSyntax(s string, ctx *parseContext, lineNum, col int, openChar, closeChar rune) error {
-	runes := []rune(s)
+// inlineFrame is an inline array or object whose items are being parsed.
+type inlineFrame struct {
+	close  byte   // ']' or '}'
+	off    int    // Byte offset of the opening bracket in the source
+	array  []any  // Items of an array
+	object any    // Properties of an object; nil for an array
+	key    string // Key of the property whose value comes next
+	keyOff int    // Byte offset of key in the source
+	offs   []int  // Byte offsets of the items of an array, when recorded
+}
 
-	// Check boundary conditions first (like JS implementation)
-	if len(runes) >= 2 && runes[0] == openChar && runes[1] == ' ' {
-		return fmt.Errorf("Unexpected space after \"%c\"%s", openChar, locSuffix(ctx, lineNum, col+1))
-	}
-	if len(runes) >= 2 && runes[len(runes)-1] == closeChar && runes[len(runes)-2] == ' ' {
-		return fmt.Errorf("Unexpected space before \"%c\"%s", closeChar, locSuffix(ctx, lineNum, col+len(runes)-2))
+// add adds value to the collection as its next item.
+func (f *inlineFrame) add(ctx *parseContext, value any) {
+	if f.object != nil {
+		setProperty(f.object, f.key, value)
+		ctx.noteProperty(f.object, f.key, f.keyOff)
+	} else {
+		f.array = append(f.array, value)
 	}
+}
 
-	inSingle := false
-	inDouble := false
-	escape := false
-	depth := 0
+// len returns the number of items added so far.
+func (f *inlineFrame) len() int {
+	if f.object != nil {
+		return objectLen(f.object)
+	}
+	return len(f.array)
+}
 
-	for i, ch := range runes {
-		if escape {
-			escape = false
-			continue
+// parseCollection parses the inline array or object at the current
+// position. Collections nested within it are kept on an explicit stack
+// rather than parsed recursively, so that however deeply a line nests
+// them, parsing uses a fixed amount of goroutine stack.
+func (p *inlineParser) parseCollection() (any, error) {
+	depth := p.ctx.depth()
+	var stack []inlineFrame
+	for {
+		// Open the collection at the current position
+		f, done, err := p.open(len(stack))
+		if err != nil {
+			p.ctx.leaveTo(depth)
+			return nil, err
 		}
-		if inSingle {
-			if ch == '\\' {
-				escape = true
-			} else if ch == '\'' {
-				inSingle = false
+		var value any
+		if done {
+			value = f.array
+			if f.object != nil {
+				value = f.object
 			}
-			continue
+		} else {
+			stack = append(stack, f)
 		}
-		if inDouble {
-			if ch == '\\' {
-				escape = true
-			} else if ch == '"' {
-				inDouble = false
+
+		for {
+			if !done {
+				// Begin the next item of the innermost collection,
+				// opening it in turn if it is a collection
+				if err := p.beginItem(&stack[len(stack)-1]); err != nil {
+					p.ctx.leaveTo(depth)
+					return nil, err
+				}
+				if c := p.peek(0); c == '[' || c == '{' {
+					break
+				}
+				scalar, consumed, err := parseInlineScalarStrict(p.s[p.pos:], p.ctx, p.off+p.pos)
+				if err != nil {
+					p.ctx.leaveTo(depth)
+					return nil, err
+				}
+				p.pos += consumed
+				value = scalar
 			}
-			continue
-		}
-		// Check for tabs (outside of strings)
-		if ch == '\t' {
-			return fmt.Errorf("Tab not allowed (use spaces)%s", locSuffix(ctx, lineNum, col+i))
-		}
-		if ch == '\'' {
-			inSingle = true
-			continue
-		}
-		if ch == '"' {
-			inDouble = true
-			continue
-		}
-		if ch == openChar {
-			depth++
-			// Check nested opening brackets (not the first one, which is already checked)
-			if i > 0 && i+1 < len(runes) && runes[i+1] == ' ' {
-				return fmt.Errorf("Unexpected space after \"%c\"%s", openChar, locSuffix(ctx, lineNum, col+i+1))
+
+			// Add the finished value to the innermost collection
+			if len(stack) == 0 {
+				return value, nil
 			}
-			continue
-		}
-		if ch == closeChar {
-			// Check nested closing brackets (not the last one, which is already checked)
-			if i < len(runes)-1 && i > 0 && runes[i-1] == ' ' {
-				return fmt.Errorf("Unexpected space before \"%c\"%s", closeChar, locSuffix(ctx, lineNum, col+i-1))
+			top := &stack[len(stack)-1]
+			top.add(p.ctx, value)
+			more, err := p.parseSeparator(top.close)
+			if err != nil {
+				p.ctx.leaveTo(depth)
+				return nil, err
 			}
-			if depth > 0 {
-				depth--
+			done = !more
+			if done {
+				stack = stack[:len(stack)-1]
+				p.ctx.leave()
+				value = top.object
+				if top.object == nil {
+					items := p.ctx.finishSlice(top.array)
+					p.ctx.noteItems(items, top.offs)
+					value = items
+				}
 			}
-			continue
 		}
-		if ch == ',' {
-			if i > 0 && runes[i-1] == ' ' {
-				return fmt.Errorf("Unexpected space before \",\"%s", locSuffix(ctx, lineNum, col+i-1))
-			}
-			// Check for tab after comma (before checking for space)
-			if i+1 < len(runes) && runes[i+1] == '\t' {
-				return fmt.Errorf("Tab not allowed (use spaces)%s", locSuffix(ctx, lineNum, col+i+1))
-			}
-			// Check for space after comma
-			if i+1 < len(runes) && runes[i+1] != ' ' && runes[i+1] != closeChar {
-				return fmt.Errorf("Expected space after \",\"%s", locSuffix(ctx, lineNum, col+i))
-			}
-			// Check for double space after comma
-			if i+2 < len(runes) && runes[i+1] == ' ' && runes[i+2] == ' ' {
-				return fmt.Errorf("Unexpected space after \",\"%s", locSuffix(ctx, lineNum, col+i+2))
-			}
-			continue
+	}
+}
+
+// open consumes the opening bracket of an inline array or object within
+// the given number of open collections. It reports done for an empty
+// collection, whose closing bracket it consumes as well.
+func (p *inlineParser) open(open int) (inlineFrame, bool, error) {
+	f := inlineFrame{close: ']', off: p.off + p.pos}
+	if p.peek(0) == '{' {
+		f.close = '}'
+	}
+	if err := p.ctx.checkInlineDepth(open+1, f.off); err != nil {
+		return f, false, err
+	}
+	if err := p.ctx.enter(f.off); err != nil {
+		return f, false, err
+	}
+	p.pos += 1
+	if p.peek(0) == f.close {
+		p.pos += 1
+		p.ctx.leave()
+		if f.close == '}' {
+			f.object = p.ctx.newObject(0)
+		} else {
+			f.array = []any{}
 		}
+		return f, true, nil
 	}
-	return nil
+	if p.peek(0) == ' ' {
+		p.ctx.leave()
+		if f.close == '}' {
+			return f, false, p.errorf(0, "Unexpected space after \"%s\"", "{")
+		}
+		return f, false, p.errorf(0, "Unexpected space after \"%s\"", "[")
+	}
+	if f.close == '}' {
+		f.object = p.ctx.newObject(0)
+	} else {
+		f.array = p.ctx.newSlice(0)
+	}
+	return f, false, nil
 }
 
-// parseInlineValueStrict parses a single value with strict validation.
-func parseInlineValueStrict(s string, ctx *parseContext, lineNum, col int) (any, int, error) {
-	if strings.HasPrefix(s, "[") {
-		end := findMatchingBracket(s)
-		if end < 0 {
-			return nil, 0, fmt.Errorf("Unterminated inline array%s", locSuffix(ctx, lineNum, col))
+// beginItem checks that collection f may take another item and, for an
+// object, consumes the item's key and colon, leaving the position at the
+// item's value.
+func (p *inlineParser) beginItem(f *inlineFrame) error {
+	if p.pos >= len(p.s) {
+		if f.object != nil {
+			return p.ctx.errorf(f.off, "Unterminated inline object")
 		}
-		arr, err := parseInlineArrayStrict(s[:end+1], ctx, lineNum, col)
-		return arr, end + 1, err
+		return p.ctx.errorf(f.off, "Unterminated inline array")
+	}
+	if err := p.ctx.checkItems(f.len(), p.off+p.pos); err != nil {
+		return err
+	}
+	if f.object == nil {
+		f.offs = p.ctx.noteItem(f.offs, p.off+p.pos)
+		return nil
 	}
 
-	if strings.HasPrefix(s, "{") {
-		end := findMatchingBrace(s)
-		if end < 0 {
-			return nil, 0, fmt.Errorf("Unterminated inline object%s", locSuffix(ctx, lineNum, col))
+	// Parse key
+	keyOff := p.off + p.pos
+	key, keyLen, err := parseInlineKeyStrict(p.s[p.pos:], p.ctx, keyOff, f.off)
+	if err != nil {
+		return err
+	}
+	if f.key, err = p.ctx.propertyKey(key, keyOff); err != nil {
+		return err
+	}
+	f.keyOff = keyOff
+	p.pos += keyLen
+
+	// Expect colon, then exactly one space
+	if p.peek(0) == ' ' {
+		j := 0
+		for p.peek(j) == ' ' {
+			j++
 		}
-		obj, err := parseInlineObjectStrict(s[:end+1], ctx, lineNum, col)
-		return obj, end + 1, err
+		if p.peek(j) == ':' {
+			return p.errorf(j-1, "Unexpected space before \"%s\"", ":")
+		}
+	}
+	if p.peek(0) != ':' {
+		return p.ctx.errorf(f.off, "Expected colon after key")
+	}
+	if p.peek(1) != ' ' {
+		return p.errorf(0, "Expected space after \"%s\"", ":")
+	}
+	if p.peek(2) == ' ' {
+		return p.errorf(2, "Unexpected space after \"%s\"", ":")
 	}
+	p.pos += 2
+	return nil
+}
 
+// parseSeparator consumes the ", " between items or the closing character
+// after the last one. It reports whether another item follows.
+func (p *inlineParser) parseSeparator(closeChar byte) (bool, error) {
+	switch p.peek(0) {
+	case closeChar:
+		p.pos += 1
+		return false, nil
+	case ',':
+		switch p.peek(1) {
+		case '\t':
+			return false, p.errorf(1, "Tab not allowed (use spaces)")
+		case ' ':
+		default:
+			return false, p.errorf(0, "Expected space after \"%s\"", ",")
+		}
+		if p.peek(2) == ' ' {
+			return false, p.errorf(2, "Unexpected space after \"%s\"", ",")
+		}
+		if p.peek(2) == closeChar {
+			return false, p.errorf(1, "Unexpected space before \"%s\"", string(closeChar))
+		}
+		p.pos += 2
+		return true, nil
+	case ' ':
+		j := 0
+		for p.peek(j) == ' ' {
+			j++
+		}
+		switch next := p.peek(j); {
+		case next == ',':
+			return false, p.errorf(j-1, "Unexpected space before \"%s\"", ",")
+		case next == closeChar:
+			return false, p.errorf(j-1, "Unexpected space before \"%s\"", string(closeChar))
+		case isDigit(next) && p.pos > 0 && isDigit(p.s[p.pos-1]):
+			return false, p.errorf(0, "Unexpected space in number")
+		case next == 0:
+			p.pos += j
+			return true, nil
+		default:
+			return false, p.errorf(j, "Unexpected character \"%s\"", string(next))
+		}
+	case 0:
+		// The closing character belonged to a nested collection;
+		// the caller reports this one as unterminated.
+		return true, nil
+	default:
+		return false, p.errorf(0, "Unexpected character \"%s\"", string(p.peek(0)))
+	}
+}
+
+// parseInlineScalarStrict parses a single non-collection value at the start
+// of s with strict validation. Returns (value, bytes consumed, error).
+func parseInlineScalarStrict(s string, ctx *parseContext, off int) (any, int, error) {
 	if strings.HasPrefix(s, "<") {
 		end := strings.Index(s, ">")
 		if end < 0 {
-			return nil, 0, fmt.Errorf("Unclosed angle bracket%s", locSuffix(ctx, lineNum, col))
+			return nil, 0, ctx.errorf(off, "Unclosed angle bracket")
 		}
-		bytes, err := parseAngleBytesStrict(s[:end+1], ctx, lineNum, col)
+		bytes, err := parseAngleBytesStrict(s[:end+1], ctx, off)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -1181,18 +2207,24 @@ func parseInlineValueStrict(s string, ctx *parseContext, lineNum, col int) (any,
 	}
 
 	if strings.HasPrefix(s, "\"") {
-		str, consumed, err := parseInlineString(s)
+		str, consumed, err := parseInlineString(s, ctx)
 		if err != nil {
-			return nil, 0, fmt.Errorf("%s%s", err.Error(), locSuffix(ctx, lineNum, col))
+			return nil, 0, ctx.placed(err, off)
+		}
+		if err := ctx.checkString(str, off); err != nil {
+			return nil, 0, err
 		}
 		return str, consumed, nil
 	}
 
 	// Single-quoted strings
 	if strings.HasPrefix(s, "'") {
-		str, consumed, err := parseInlineSingleQuotedString(s)
+		str, consumed, err := parseInlineSingleQuotedString(s, ctx)
 		if err != nil {
-			return nil, 0, fmt.Errorf("%s%s", err.Error(), locSuffix(ctx, lineNum, col))
+			return nil, 0, ctx.placed(err, off)
+		}
+		if err := ctx.checkString(str, off); err != nil {
+			return nil, 0, err
 		}
 		return str, consumed, nil
 	}
@@ -1222,7 +2254,7 @@ func parseInlineValueStrict(s string, ctx *parseContext, lineNum, col int) (any,
 	}
 
 	// Try number
-	num, consumed, err := parseInlineNumberStrict(s, ctx, lineNum, col)
+	num, consumed, err := parseInlineNumberStrict(s, ctx, off)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -1233,14 +2265,14 @@ func parseInlineValueStrict(s string, ctx *parseContext, lineNum, col int) (any,
 	// Bare words are not valid
 	if len(s) > 0 {
 		firstChar := string(s[0])
-		return nil, 0, fmt.Errorf("Unexpected character \"%s\"%s", firstChar, locSuffix(ctx, lineNum, col))
+		return nil, 0, ctx.errorf(off, "Unexpected character \"%s\"", firstChar)
 	}
 
-	return nil, 0, fmt.Errorf("Unexpected empty value%s", locSuffix(ctx, lineNum, col))
+	return nil, 0, ctx.errorf(off, "Unexpected empty value")
 }
 
 // parseInlineNumberStrict parses a number from inline context with validation.
-func parseInlineNumberStrict(s string, ctx *parseContext, lineNum, col int) (any, int, error) {
+func parseInlineNumberStrict(s string, ctx *parseContext, off int) (any, int, error) {
 	// Find the end of the number (up to comma, bracket, brace, or space)
 	end := 0
 	for i, ch := range s {
@@ -1261,293 +2293,99 @@ func parseInlineNumberStrict(s string, ctx *parseContext, lineNum, col int) (any
 	}
 	firstChar := numStr[0]
 	if firstChar != '-' && (firstChar < '0' || firstChar > '9') {
-		return nil, 0, nil
-	}
-
-	// Try integer
-	if integerRe.MatchString(numStr) {
-		n := new(big.Int)
-		n.SetString(numStr, 10)
-		return n, end, nil
-	}
-
-	// Try float
-	if floatRe.MatchString(numStr) && numStr != "." && numStr != "-." {
-		var f float64
-		fmt.Sscanf(numStr, "%f", &f)
-		return f, end, nil
-	}
-
-	return nil, 0, nil
-}
-
-// parseAngleBytesStrict parses angle bracket bytes with validation.
-func parseAngleBytesStrict(s string, ctx *parseContext, lineNum, col int) ([]byte, error) {
-	if !strings.HasPrefix(s, "<") || !strings.HasSuffix(s, ">") {
-		return nil, fmt.Errorf("Invalid byte literal%s", locSuffix(ctx, lineNum, col))
-	}
-	if s == "<>" {
-		return []byte{}, nil
-	}
-
-	// Check for space after <
-	if len(s) > 1 && s[1] == ' ' {
-		return nil, fmt.Errorf("Unexpected space after \"<\"%s", locSuffix(ctx, lineNum, col+1))
-	}
-	// Check for space before >
-	if len(s) > 1 && s[len(s)-2] == ' ' {
-		return nil, fmt.Errorf("Unexpected space before \">\"%s", locSuffix(ctx, lineNum, col+len(s)-2))
-	}
-
-	inner := s[1 : len(s)-1]
-
-	// Check for uppercase hex digits before lowercasing
-	for i, c := range inner {
-		if isUppercaseHex(c) {
-			return nil, fmt.Errorf("Uppercase hex digit (use lowercase)%s", locSuffix(ctx, lineNum, col+1+i))
-		}
-	}
-
-	// Remove internal spaces (allowed for grouping)
-	inner = strings.ReplaceAll(inner, " ", "")
-
-	if len(inner)%2 != 0 {
-		return nil, fmt.Errorf("Odd number of hex digits in byte literal%s", locSuffix(ctx, lineNum, col))
-	}
-
-	// Validate hex digits
-	for _, c := range inner {
-		if !isHexDigit(c) {
-			return nil, fmt.Errorf("Invalid hex digit%s", locSuffix(ctx, lineNum, col))
-		}
-	}
-
-	bytes, err := hex.DecodeString(inner)
-	if err != nil {
-		return nil, fmt.Errorf("Invalid hex%s", locSuffix(ctx, lineNum, col))
-	}
-	return bytes, nil
-}
-
-// findMatchingBracket finds the index of the closing bracket.
-func findMatchingBracket(s string) int {
-	depth := 0
-	inString := false
-	stringChar := rune(0)
-	escape := false
-
-	for i, c := range s {
-		if escape {
-			escape = false
-			continue
-		}
-		if c == '\\' && inString {
-			escape = true
-			continue
-		}
-		if (c == '"' || c == '\'') && (!inString || c == stringChar) {
-			if inString {
-				inString = false
-				stringChar = 0
-			} else {
-				inString = true
-				stringChar = c
-			}
-			continue
-		}
-		if inString {
-			continue
-		}
-		if c == '[' {
-			depth++
-		} else if c == ']' {
-			depth--
-			if depth == 0 {
-				return i
-			}
-		}
-	}
-	return -1
-}
-
-// findMatchingBrace finds the index of the closing brace for an inline object.
-func findMatchingBrace(s string) int {
-	depth := 0
-	inString := false
-	stringChar := rune(0)
-	escape := false
-
-	for i, c := range s {
-		if escape {
-			escape = false
-			continue
-		}
-		if c == '\\' && inString {
-			escape = true
-			continue
-		}
-		if (c == '"' || c == '\'') && (!inString || c == stringChar) {
-			if inString {
-				inString = false
-				stringChar = 0
-			} else {
-				inString = true
-				stringChar = c
-			}
-			continue
+		return nil, 0, nil
+	}
+
+	// Try integer
+	if isInteger(numStr) {
+		if err := ctx.chargeInt(numStr, off); err != nil {
+			return nil, 0, err
 		}
-		if inString {
-			continue
+		return ctx.newInt(numStr), end, nil
+	}
+
+	// Try float, which must have a decimal point
+	if ok, point := floatShape(numStr); ok && point {
+		f, ok, err := ctx.parseFloat(numStr, off)
+		if err != nil {
+			return nil, 0, err
 		}
-		if c == '{' {
-			depth++
-		} else if c == '}' {
-			depth--
-			if depth == 0 {
-				return i
+		if ok {
+			if err := ctx.charge(sizeFloat, off); err != nil {
+				return nil, 0, err
 			}
+			return ctx.newFloat(f), end, nil
 		}
 	}
-	return -1
+
+	return nil, 0, nil
 }
 
-// parseInlineObjectStrict parses an inline object with strict whitespace validation.
-func parseInlineObjectStrict(s string, ctx *parseContext, lineNum, col int) (map[string]any, error) {
-	s = strings.TrimSpace(s)
-	if !strings.HasPrefix(s, "{") {
-		return nil, fmt.Errorf("Expected object%s", locSuffix(ctx, lineNum, col))
-	}
-	if !strings.HasSuffix(s, "}") {
-		return nil, fmt.Errorf("Unterminated inline object%s", locSuffix(ctx, lineNum, col))
-	}
-	if s == "{}" {
-		return map[string]any{}, nil
+// parseAngleBytesStrict parses angle bracket bytes with validation.
+func parseAngleBytesStrict(s string, ctx *parseContext, off int) ([]byte, error) {
+	if !strings.HasPrefix(s, "<") || !strings.HasSuffix(s, ">") {
+		return nil, ctx.errorf(off, "Invalid byte literal")
 	}
-
-	// Validate whitespace
-	if err := validateInlineSyntax(s, ctx, lineNum, col, '{', '}'); err != nil {
-		return nil, err
+	if s == "<>" {
+		return []byte{}, nil
 	}
 
-	// Check for space before/after colon
-	if err := validateColonWhitespace(s, ctx, lineNum, col); err != nil {
-		return nil, err
+	// Check for space after <
+	if len(s) > 1 && s[1] == ' ' {
+		return nil, ctx.errorf(off+1, "Unexpected space after \"%s\"", "<")
 	}
-
-	inner := strings.TrimSpace(s[1 : len(s)-1])
-	if inner == "" {
-		return map[string]any{}, nil
+	// Check for space before >
+	if len(s) > 1 && s[len(s)-2] == ' ' {
+		return nil, ctx.errorf(off+len(s)-2, "Unexpected space before \"%s\"", ">")
 	}
 
-	result := make(map[string]any)
-	remaining := inner
-	offset := 1 // Start after '{'
-
-	for len(remaining) > 0 {
-		remaining = strings.TrimLeft(remaining, " ")
-
-		// Parse key
-		key, keyLen, err := parseInlineKeyStrict(remaining, ctx, lineNum, col+offset, col)
-		if err != nil {
-			return nil, err
-		}
-		remaining = remaining[keyLen:]
-		offset += keyLen
-		remaining = strings.TrimLeft(remaining, " ")
+	inner := s[1 : len(s)-1]
 
-		// Expect colon
-		if !strings.HasPrefix(remaining, ":") {
-			return nil, fmt.Errorf("Expected colon after key%s", locSuffix(ctx, lineNum, col))
+	// Check for uppercase hex digits before lowercasing
+	for i, c := range inner {
+		if isUppercaseHex(c) {
+			return nil, ctx.errorf(off+1+i, "Uppercase hex digit (use lowercase)")
 		}
-		remaining = remaining[1:]
-		offset++
-		remaining = strings.TrimLeft(remaining, " ")
+	}
 
-		// Parse value
-		value, consumed, err := parseInlineValueStrict(remaining, ctx, lineNum, col+offset)
-		if err != nil {
-			return nil, err
-		}
+	// Remove internal spaces (allowed for grouping)
+	inner = strings.ReplaceAll(inner, " ", "")
 
-		result[key] = value
-		remaining = remaining[consumed:]
-		offset += consumed
-		remaining = strings.TrimLeft(remaining, " ")
+	if len(inner)%2 != 0 {
+		return nil, ctx.errorf(off, "Odd number of hex digits in byte literal")
+	}
 
-		// Skip comma
-		if strings.HasPrefix(remaining, ",") {
-			remaining = remaining[1:]
-			offset++
+	// Validate hex digits
+	for _, c := range inner {
+		if !isHexDigit(c) {
+			return nil, ctx.errorf(off, "Invalid hex digit")
 		}
 	}
 
-	return result, nil
-}
-
-// validateColonWhitespace checks for invalid whitespace around colons in inline objects.
-func validateColonWhitespace(s string, ctx *parseContext, lineNum, col int) error {
-	runes := []rune(s)
-	inSingle := false
-	inDouble := false
-	escape := false
-
-	for i, ch := range runes {
-		if escape {
-			escape = false
-			continue
-		}
-		if inSingle {
-			if ch == '\\' {
-				escape = true
-			} else if ch == '\'' {
-				inSingle = false
-			}
-			continue
-		}
-		if inDouble {
-			if ch == '\\' {
-				escape = true
-			} else if ch == '"' {
-				inDouble = false
-			}
-			continue
-		}
-		if ch == '\'' {
-			inSingle = true
-			continue
-		}
-		if ch == '"' {
-			inDouble = true
-			continue
-		}
-		if ch == ':' {
-			// Check for space before colon
-			if i > 0 && runes[i-1] == ' ' {
-				return fmt.Errorf("Unexpected space before \":\"%s", locSuffix(ctx, lineNum, col+i-1))
-			}
-			// Check for space after colon (required unless followed by closing brace)
-			if i+1 < len(runes) && runes[i+1] != ' ' && runes[i+1] != '}' {
-				return fmt.Errorf("Expected space after \":\"%s", locSuffix(ctx, lineNum, col+i))
-			}
-		}
+	if err := ctx.charge(sizeBytes+len(inner)/2, off); err != nil {
+		return nil, err
 	}
-	return nil
+	bytes, err := ctx.decodeHex(inner)
+	if err != nil {
+		return nil, ctx.errorf(off, "Invalid hex")
+	}
+	return bytes, nil
 }
 
 // parseInlineKeyStrict parses an object key with strict validation.
 // braceCol is the column of the opening brace, used for "Invalid key" errors.
-func parseInlineKeyStrict(s string, ctx *parseContext, lineNum, col, braceCol int) (string, int, error) {
+func parseInlineKeyStrict(s string, ctx *parseContext, off, braceOff int) (string, int, error) {
 	if strings.HasPrefix(s, "\"") {
-		str, consumed, err := parseInlineString(s)
+		str, consumed, err := parseInlineString(s, ctx)
 		if err != nil {
-			return "", 0, fmt.Errorf("%s%s", err.Error(), locSuffix(ctx, lineNum, col))
+			return "", 0, ctx.placed(err, off)
 		}
 		return str, consumed, nil
 	}
 	if strings.HasPrefix(s, "'") {
-		str, consumed, err := parseInlineSingleQuotedString(s)
+		str, consumed, err := parseInlineSingleQuotedString(s, ctx)
 		if err != nil {
-			return "", 0, fmt.Errorf("%s%s", err.Error(), locSuffix(ctx, lineNum, col))
+			return "", 0, ctx.placed(err, off)
 		}
 		return str, consumed, nil
 	}
@@ -1559,7 +2397,7 @@ func parseInlineKeyStrict(s string, ctx *parseContext, lineNum, col, braceCol in
 	}
 	if i == 0 {
 		// Report at brace column for "Invalid key" (first char invalid)
-		return "", 0, fmt.Errorf("Invalid key%s", locSuffix(ctx, lineNum, braceCol))
+		return "", 0, ctx.errorf(braceOff, "Invalid key")
 	}
 	return s[:i], i, nil
 }
@@ -1567,13 +2405,19 @@ func isAlphanumeric(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
 
+// isDigit checks if c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
 // parseInlineSingleQuotedString parses a single-quoted string.
-func parseInlineSingleQuotedString(s string) (string, int, error) {
+func parseInlineSingleQuotedString(s string, ctx *parseContext) (string, int, error) {
 	if !strings.HasPrefix(s, "'") {
-		return "", 0, fmt.Errorf("expected single-quoted string")
+		return "", 0, ctx.unplacedErrorf("expected single-quoted string")
 	}
 
-	var out strings.Builder
+	out := getBuffer()
+	defer putBuffer(out)
 	escape := false
 
 	for i := 1; i < len(s); i++ {
@@ -1597,22 +2441,23 @@ func parseInlineSingleQuotedString(s string) (string, int, error) {
 		}
 
 		if c == '\'' {
-			return out.String(), i + 1, nil
+			return ctx.newString(out.Bytes()), i + 1, nil
 		}
 
 		out.WriteByte(c)
 	}
 
-	return "", 0, fmt.Errorf("unterminated string")
+	return "", 0, ctx.unplacedErrorf("unterminated string")
 }
 
 // parseInlineString parses a double-quoted string in inline notation.
-func parseInlineString(s string) (string, int, error) {
+func parseInlineString(s string, ctx *parseContext) (string, int, error) {
 	if !strings.HasPrefix(s, "\"") {
-		return "", 0, fmt.Errorf("expected string")
+		return "", 0, ctx.unplacedErrorf("expected string")
 	}
 
-	var out strings.Builder
+	out := getBuffer()
+	defer putBuffer(out)
 	escape := false
 
 	for i := 1; i < len(s); i++ {
@@ -1634,7 +2479,7 @@ func parseInlineString(s string) (string, int, error) {
 				out.WriteByte('\t')
 			case 'u':
 				if i+4 >= len(s) {
-					return "", 0, fmt.Errorf("invalid unicode escape")
+					return "", 0, ctx.unplacedErrorf("invalid unicode escape")
 				}
 				var code int
 				fmt.Sscanf(s[i+1:i+5], "%x", &code)
@@ -1653,13 +2498,13 @@ func parseInlineString(s string) (string, int, error) {
 		}
 
 		if c == '"' {
-			return out.String(), i + 1, nil
+			return ctx.newString(out.Bytes()), i + 1, nil
 		}
 
 		out.WriteByte(c)
 	}
 
-	return "", 0, fmt.Errorf("unterminated string")
+	return "", 0, ctx.unplacedErrorf("unterminated string")
 }
 
 // ============================================================================
@@ -1672,14 +2517,14 @@ func isUppercaseHex(r rune) bool {
 }
 
 // parseAngleBytes parses an inline byte array: <hexdigits>
-func parseAngleBytes(s string, ctx *parseContext, lineNum, col int) ([]byte, error) {
+func parseAngleBytes(s string, ctx *parseContext, off int) ([]byte, error) {
 	if s == "<>" {
 		return []byte{}, nil
 	}
 
 	// Check for unclosed angle bracket
 	if len(s) < 2 || !strings.HasSuffix(s, ">") {
-		return nil, fmt.Errorf("Unmatched angle bracket%s", locSuffix(ctx, lineNum, col))
+		return nil, ctx.errorf(off, "Unmatched angle bracket")
 	}
 
 	inner := s[1 : len(s)-1]
@@ -1687,24 +2532,27 @@ func parseAngleBytes(s string, ctx *parseContext, lineNum, col int) ([]byte, err
 	// Check for uppercase hex digits before lowercasing
 	for i, c := range inner {
 		if isUppercaseHex(c) {
-			return nil, fmt.Errorf("Uppercase hex digit (use lowercase)%s", locSuffix(ctx, lineNum, col+1+i))
+			return nil, ctx.errorf(off+1+i, "Uppercase hex digit (use lowercase)")
 		}
 	}
 
 	hexStr := strings.ReplaceAll(inner, " ", "")
 
 	if len(hexStr)%2 != 0 {
-		return nil, fmt.Errorf("Odd number of hex digits in byte literal%s", locSuffix(ctx, lineNum, col))
+		return nil, ctx.errorf(off, "Odd number of hex digits in byte literal")
 	}
 
 	// Validate hex digits
 	for _, c := range hexStr {
 		if !isHexDigit(c) {
-			return nil, fmt.Errorf("Invalid hex digit%s", locSuffix(ctx, lineNum, col))
+			return nil, ctx.errorf(off, "Invalid hex digit")
 		}
 	}
 
-	return hex.DecodeString(hexStr)
+	if err := ctx.charge(sizeBytes+len(hexStr)/2, off); err != nil {
+		return nil, err
+	}
+	return ctx.decodeHex(hexStr)
 }
 
 // parseBlockBytes parses a block byte array starting with >
@@ -1715,39 +2563,23 @@ func parseBlockBytes(tokens []token, i int, ctx *parseContext) ([]byte, int, err
 
 	// Validate: > alone on a line is invalid
 	if first.text == ">" {
-		return nil, 0, fmt.Errorf("Expected hex or comment in hex block%s", locSuffix(ctx, first.lineNum, first.col))
+		return nil, 0, ctx.errorf(first.offset, "Expected hex or comment in hex block")
 	}
 
-	// Extract hex from first line (after >)
-	hexPart := first.text[1:]
+	// Hex on the first line follows the > leader
+	leaderLen := 1
 	if strings.HasPrefix(first.text, "> ") {
-		hexPart = first.text[2:]
+		leaderLen = 2
 	}
-	hexPart = stripComment(hexPart)
-	hexPart = strings.ReplaceAll(hexPart, " ", "")
-
-	var hexStr strings.Builder
-	hexStr.WriteString(strings.ToLower(hexPart))
 	i++
 
 	// Collect continuation lines
-	for i < len(tokens) && tokens[i].typ == tokenText && tokens[i].indent > baseIndent {
-		line := stripComment(tokens[i].text)
-		line = strings.ReplaceAll(line, " ", "")
-		hexStr.WriteString(strings.ToLower(line))
-		i++
-	}
-
-	hexResult := hexStr.String()
-	if len(hexResult)%2 != 0 {
-		return nil, 0, fmt.Errorf("Odd number of hex digits in byte literal%s", locSuffix(ctx, first.lineNum, first.col))
-	}
-
-	result, err := hex.DecodeString(hexResult)
+	end := hexBlockEnd(tokens, i, baseIndent)
+	result, err := decodeHexBlock(first.text[leaderLen:], first.offset+leaderLen, tokens[i:end], ctx, first.offset)
 	if err != nil {
 		return nil, 0, err
 	}
-	return result, i, nil
+	return result, end, nil
 }
 
 // parseBlockBytesFromKeyLine parses block bytes after a key: >
@@ -1766,29 +2598,103 @@ func parseBlockBytesFromKeyLine(tokens []token, i int, ctx *parseContext, keyInd
 	afterComment := stripComment(afterLeader)
 	afterComment = strings.ReplaceAll(afterComment, " ", "")
 	if afterComment != "" {
-		return nil, 0, fmt.Errorf("Expected newline after block leader in property%s", locSuffix(ctx, startToken.lineNum, startToken.col))
+		return nil, 0, ctx.errorf(startToken.offset, "Expected newline after block leader in property")
 	}
 
 	i++
 
-	var hexStr strings.Builder
-	for i < len(tokens) && tokens[i].typ == tokenText && tokens[i].indent > keyIndent {
-		line := stripComment(tokens[i].text)
-		line = strings.ReplaceAll(line, " ", "")
-		hexStr.WriteString(strings.ToLower(line))
+	end := hexBlockEnd(tokens, i, keyIndent)
+	result, err := decodeHexBlock("", 0, tokens[i:end], ctx, startToken.offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, end, nil
+}
+
+// hexBlockEnd returns the index just past the lines of a block byte array
+// that begin at tokens[i] and are indented deeper than indent.
+func hexBlockEnd(tokens []token, i, indent int) int {
+	for i < len(tokens) && tokens[i].typ == tokenText && tokens[i].indent > indent {
 		i++
 	}
+	return i
+}
 
-	hexResult := hexStr.String()
-	if len(hexResult)%2 != 0 {
-		return nil, 0, fmt.Errorf("Odd number of hex digits in byte literal%s", locSuffix(ctx, startToken.lineNum, startToken.col))
+// decodeHexBlock decodes the hex digits of a block byte array: those of
+// first, the remainder of the leader line at source offset firstOff, then
+// those of each line in lines. Spaces and comments are skipped, and a digit
+// pair may span lines. The digits are counted before any are decoded, so
+// the result is allocated once at its final size and filled a nibble at a
+// time, with no intermediate hex string. Errors about the block as a whole
+// are reported at blockOff.
+func decodeHexBlock(first string, firstOff int, lines []token, ctx *parseContext, blockOff int) ([]byte, error) {
+	first = stripComment(first)
+	n := countHexDigits(first)
+	for _, t := range lines {
+		n += countHexDigits(stripComment(t.text))
+	}
+	if n%2 != 0 {
+		return nil, ctx.errorf(blockOff, "Odd number of hex digits in byte literal")
+	}
+	if err := ctx.countBlock(n/2, blockOff); err != nil {
+		return nil, err
+	}
+	if err := ctx.charge(sizeBytes+n/2, blockOff); err != nil {
+		return nil, err
 	}
 
-	result, err := hex.DecodeString(hexResult)
+	result := ctx.newBytes(n / 2)
+	k, err := decodeHexLine(result, 0, first, firstOff, ctx)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	for _, t := range lines {
+		if k, err = decodeHexLine(result, k, stripComment(t.text), t.offset, ctx); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// countHexDigits counts the non-space characters of a line of hex.
+func countHexDigits(line string) int {
+	return len(line) - strings.Count(line, " ")
+}
+
+// decodeHexLine decodes the hex digits of line, at source offset off, into
+// dst starting at nibble k, and returns the index of the next nibble.
+// Upper-case digits are accepted in blocks.
+func decodeHexLine(dst []byte, k int, line string, off int, ctx *parseContext) (int, error) {
+	for j := 0; j < len(line); j++ {
+		c := line[j]
+		if c == ' ' {
+			continue
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			return 0, ctx.errorf(off+j, "Invalid hex digit")
+		}
+		if k%2 == 0 {
+			dst[k/2] = v << 4
+		} else {
+			dst[k/2] |= v
+		}
+		k++
+	}
+	return k, nil
+}
+
+// hexValue returns the value of hex digit c.
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
 	}
-	return result, i, nil
+	return 0, false
 }
 
 // stripComment removes a # comment from a line (not inside quotes).
@@ -1821,40 +2727,110 @@ func stripComment(line string) string {
 // Multiline Array Parsing
 // ============================================================================
 
-var inlineListItemRe = regexp.MustCompile(`^-\s+`)
+// isInlineListItem reports whether text, a list item's value, is itself a
+// list item, a dash followed by space.
+func isInlineListItem(text string) bool {
+	return len(text) > 1 && text[0] == '-' && strings.IndexByte(" \t\n\f\r", text[1]) >= 0
+}
 
 // parseMultilineArray parses a multiline array (list items with - prefix).
 // minIndent specifies the minimum indent level for array items (-1 means no limit).
 func parseMultilineArray(tokens []token, i int, ctx *parseContext, minIndent int) ([]any, int, error) {
-	var arr []any
+	if err := ctx.enter(tokens[i].offset); err != nil {
+		return nil, 0, err
+	}
+	defer ctx.leave()
+	arr := ctx.newSlice(tokens[i].count)
+	var offs []int // Where the items begin, when recorded
 
-	for i < len(tokens) && tokens[i].typ == tokenStart && tokens[i].text == "- " {
-		listIndent := tokens[i].indent
-		// Stop if we encounter a list item at a lower indent than expected
-		if minIndent >= 0 && listIndent < minIndent {
-			break
+	for {
+		item, next, ok, err := nextArrayItem(tokens, i, minIndent, len(arr), ctx)
+		if err != nil {
+			return nil, 0, err
 		}
-		i++
-
-		// Skip breaks after list marker
-		i = skipBreaks(tokens, i)
-		if i >= len(tokens) {
+		i = next
+		if !ok {
 			break
 		}
+		offs = ctx.noteItem(offs, item.off)
+		arr = append(arr, item.value)
+	}
 
-		// Parse the array item
-		value, nextI, err := parseArrayItem(tokens, i, listIndent, ctx)
-		if err != nil {
-			return nil, 0, err
-		}
-		arr = append(arr, value)
-		i = nextI
+	arr = ctx.finishSlice(arr)
+	ctx.noteItems(arr, offs)
+	return arr, i, nil
+}
+
+// arrayItem is an item of a block array, with the offset where it begins.
+type arrayItem struct {
+	value any
+	off   int
+}
 
-		// Skip stops and breaks between items
-		i = skipBreaksAndStops(tokens, i)
+// nextArrayItem parses the item of a block array of n items so far that
+// begins at tokens[i], returning it with the index of the token after it
+// and the breaks and stops that follow. It reports false if the array has
+// no more, with the index of the token after the array. Items at an indent
+// less than minIndent, if it is not negative, belong to another array.
+func nextArrayItem(tokens []token, i, minIndent, n int, ctx *parseContext) (arrayItem, int, bool, error) {
+	if i >= len(tokens) || tokens[i].typ != tokenStart || tokens[i].text != "- " {
+		return arrayItem{}, i, false, nil
+	}
+	listIndent := tokens[i].indent
+	// Stop if we encounter a list item at a lower indent than expected
+	if minIndent >= 0 && listIndent < minIndent {
+		return arrayItem{}, i, false, nil
 	}
 
-	return arr, i, nil
+	// Skip breaks after list marker
+	i = skipBreaks(tokens, i+1)
+	if i >= len(tokens) {
+		return arrayItem{}, i, false, nil
+	}
+
+	// Parse the array item
+	if err := ctx.checkItems(n, tokens[i].offset); err != nil {
+		return arrayItem{}, 0, false, err
+	}
+	off := itemOffset(tokens, i)
+	ctx.openList(listIndent)
+	value, next, err := parseArrayItem(tokens, i, listIndent, ctx)
+	ctx.closeList()
+	if err != nil {
+		return arrayItem{}, 0, false, err
+	}
+
+	// Skip stops and breaks between items
+	return arrayItem{value: value, off: off}, skipBreaksAndStops(tokens, next), true, nil
+}
+
+// openList records that an item of a block array whose items are at
+// indent is being parsed, until closeList.
+func (ctx *parseContext) openList(indent int) {
+	if ctx != nil {
+		ctx.lists = append(ctx.lists, indent)
+	}
+}
+
+// closeList undoes the latest openList.
+func (ctx *parseContext) closeList() {
+	if ctx != nil {
+		ctx.lists = ctx.lists[:len(ctx.lists)-1]
+	}
+}
+
+// inList reports whether a list item at indent would be an item of a
+// block array being parsed, which a value within its current item ends at.
+func (ctx *parseContext) inList(indent int) bool {
+	if ctx == nil {
+		return false
+	}
+	for _, l := range ctx.lists {
+		if l == indent {
+			return true
+		}
+	}
+	return false
 }
 
 // parseArrayItem parses a single array item.
@@ -1873,7 +2849,7 @@ func parseArrayItem(tokens []token, i, listIndent int, ctx *parseContext) (any,
 	}
 
 	// Inline nested list: "- value" as text
-	if next.typ == tokenText && inlineListItemRe.MatchString(next.text) {
+	if next.typ == tokenText && isInlineListItem(next.text) {
 		return parseInlineNestedList(tokens, i, listIndent, ctx)
 	}
 
@@ -1886,21 +2862,30 @@ func parseArrayItem(tokens []token, i, listIndent int, ctx *parseContext) (any,
 }
 
 // parseInlineNestedList parses inline nested list items like "- a" as text.
+// Each is a scalar, or a list of one begun on the same line in turn, and
+// the list's later items are list starts indented past the enclosing list.
 func parseInlineNestedList(tokens []token, i, listIndent int, ctx *parseContext) ([]any, int, error) {
+	if err := ctx.enter(tokens[i].offset); err != nil {
+		return nil, 0, err
+	}
+	defer ctx.leave()
 	var group []any
+	var offs []int // Where the items begin, when recorded
 	j := i
 
 	// Collect inline items
-	for j < len(tokens) && tokens[j].typ == tokenText && inlineListItemRe.MatchString(tokens[j].text) {
+	for j < len(tokens) && tokens[j].typ == tokenText && isInlineListItem(tokens[j].text) {
 		text := tokens[j].text
 		// Check for double space after dash (e.g., "-  a")
-		if len(text) >= 3 && text[0] == '-' && text[1] == ' ' && text[2] == ' ' {
-			return nil, 0, fmt.Errorf("Unexpected space after \"-\"%s", locSuffix(ctx, tokens[j].lineNum, tokens[j].col+2))
+		if len(text) >= 3 && text[1] == ' ' && text[2] == ' ' {
+			return nil, 0, ctx.errorf(tokens[j].offset+2, "Unexpected space after \"%s\"", "-")
+		}
+		if err := ctx.checkItems(len(group), tokens[j].offset); err != nil {
+			return nil, 0, err
 		}
-		valStr := strings.TrimSpace(inlineListItemRe.ReplaceAllString(text, ""))
-		// Recursively handle nested inline bullets
 		// Column offset: token col + 2 for the "- " prefix we stripped
-		val, err := parseNestedInlineBullet(valStr, ctx, tokens[j].lineNum, tokens[j].col+2)
+		offs = ctx.noteItem(offs, tokens[j].offset+2)
+		val, err := parseNestedInlineBullet(strings.TrimSpace(text[2:]), ctx, tokens[j].offset+2)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -1910,24 +2895,63 @@ func parseInlineNestedList(tokens []token, i, listIndent int, ctx *parseContext)
 
 	// Continue with nested start tokens at deeper indent
 	for j < len(tokens) && tokens[j].typ == tokenStart && tokens[j].text == "- " && tokens[j].indent > listIndent {
-		j++
-		j = skipBreaks(tokens, j)
+		itemIndent := tokens[j].indent
+		if err := ctx.checkItems(len(group), tokens[j].offset); err != nil {
+			return nil, 0, err
+		}
+		j = skipBreaks(tokens, j+1)
 		if j >= len(tokens) {
 			break
 		}
 
+		offs = ctx.noteItem(offs, itemOffset(tokens, j))
+		ctx.openList(itemIndent)
 		subVal, nextJ, err := parseValue(tokens, j, ctx)
+		ctx.closeList()
 		if err != nil {
 			return nil, 0, err
 		}
 		group = append(group, subVal)
-		j = nextJ
-		j = skipStops(tokens, j)
+		j = skipStops(tokens, nextJ)
 	}
 
+	ctx.noteItems(group, offs)
 	return group, j, nil
 }
 
+// parseNestedInlineBullet parses the text of an inline list item after its
+// dash, at offset off. If the text starts with "- ", it is a list of one
+// whose item is the text after that dash in turn, and so on, until a
+// scalar. The dashes are counted in a loop rather than by recursion, so a
+// long run of them on one line costs no goroutine stack.
+func parseNestedInlineBullet(text string, ctx *parseContext, off int) (any, error) {
+	depth := ctx.depth()
+	defer ctx.leaveTo(depth)
+	var starts []int
+	for isInlineListItem(text) {
+		// Check for double space after dash
+		if len(text) >= 3 && text[1] == ' ' && text[2] == ' ' {
+			return nil, ctx.errorf(off+2, "Unexpected space after \"%s\"", "-")
+		}
+		if err := ctx.enter(off); err != nil {
+			return nil, err
+		}
+		text = strings.TrimSpace(text[2:])
+		off += 2
+		starts = append(starts, off)
+	}
+	value, err := parseScalar(text, ctx, off)
+	if err != nil {
+		return nil, err
+	}
+	for n := len(starts) - 1; n >= 0; n-- {
+		list := []any{value}
+		ctx.noteItems(list, ctx.noteItem(nil, starts[n]))
+		value = list
+	}
+	return value, nil
+}
+
 // parseArrayItemValue parses a regular array item value.
 // Handles objects that span multiple lines with properties at the same indent.
 func parseArrayItemValue(tokens []token, i, listIndent int, ctx *parseContext) (any, int, error) {
@@ -1937,9 +2961,11 @@ func parseArrayItemValue(tokens []token, i, listIndent int, ctx *parseContext) (
 	}
 
 	// If value is an object, check for additional properties at the same level
-	if obj, isObj := value.(map[string]any); isObj {
-		j = mergeAdditionalObjectProperties(tokens, j, listIndent, obj, ctx)
-		value = obj
+	if isObject(value) {
+		j = mergeAdditionalObjectProperties(tokens, j, listIndent, value, ctx)
+		if err := ctx.checkItems(objectLen(value)-1, tokens[i].offset); err != nil {
+			return nil, 0, err
+		}
 	}
 
 	// Check for nested list items after this value
@@ -1947,7 +2973,7 @@ func parseArrayItemValue(tokens []token, i, listIndent int, ctx *parseContext) (
 	if k < len(tokens) {
 		afterBreak := tokens[k]
 		if afterBreak.typ == tokenStart && afterBreak.text == "- " && afterBreak.indent > listIndent {
-			return collectNestedListGroup(tokens, k, listIndent, value, ctx)
+			return collectNestedListGroup(tokens, k, listIndent, value, itemOffset(tokens, i), ctx)
 		}
 	}
 
@@ -1956,7 +2982,7 @@ func parseArrayItemValue(tokens []token, i, listIndent int, ctx *parseContext) (
 
 // mergeAdditionalObjectProperties merges additional properties into an object.
 // Properties at indent > listIndent are part of the same array item object.
-func mergeAdditionalObjectProperties(tokens []token, j, listIndent int, obj map[string]any, ctx *parseContext) int {
+func mergeAdditionalObjectProperties(tokens []token, j, listIndent int, obj any, ctx *parseContext) int {
 	for j < len(tokens) {
 		j = skipBreaks(tokens, j)
 		if j >= len(tokens) {
@@ -1969,11 +2995,8 @@ func mergeAdditionalObjectProperties(tokens []token, j, listIndent int, obj map[
 			if err != nil {
 				break
 			}
-			if propObj, ok := propVal.(map[string]any); ok {
-				for k, v := range propObj {
-					obj[k] = v
-				}
-			}
+			mergeObject(obj, propVal)
+			ctx.noteMerged(obj, propVal)
 			j = nextJ
 		} else {
 			break
@@ -1982,17 +3005,37 @@ func mergeAdditionalObjectProperties(tokens []token, j, listIndent int, obj map[
 	return j
 }
 
-// collectNestedListGroup collects nested list items into a group.
-func collectNestedListGroup(tokens []token, i, listIndent int, firstValue any, ctx *parseContext) ([]any, int, error) {
+// itemOffset returns where the value that begins with tokens[i] begins,
+// which is after the list marker when the value shares its line.
+func itemOffset(tokens []token, i int) int {
+	off := tokens[i].offset
+	if i > 0 && tokens[i-1].typ == tokenStart && tokens[i-1].offset == off {
+		off += len(tokens[i-1].text)
+	}
+	return off
+}
+
+// collectNestedListGroup collects nested list items into a group, after
+// firstValue, which begins at firstOff.
+func collectNestedListGroup(tokens []token, i, listIndent int, firstValue any, firstOff int, ctx *parseContext) ([]any, int, error) {
+	if err := ctx.enter(tokens[i].offset); err != nil {
+		return nil, 0, err
+	}
+	defer ctx.leave()
 	group := []any{firstValue}
+	offs := ctx.noteItem(nil, firstOff)
 
 	for i < len(tokens) && tokens[i].typ == tokenStart && tokens[i].text == "- " && tokens[i].indent > listIndent {
+		if err := ctx.checkItems(len(group), tokens[i].offset); err != nil {
+			return nil, 0, err
+		}
 		i++
 		i = skipBreaks(tokens, i)
 		if i >= len(tokens) {
 			break
 		}
 
+		offs = ctx.noteItem(offs, itemOffset(tokens, i))
 		subVal, nextI, err := parseValue(tokens, i, ctx)
 		if err != nil {
 			return nil, 0, err
@@ -2002,6 +3045,7 @@ func collectNestedListGroup(tokens []token, i, listIndent int, firstValue any, c
 		i = skipStops(tokens, i)
 	}
 
+	ctx.noteItems(group, offs)
 	return group, i, nil
 }
 
@@ -2030,43 +3074,51 @@ func parseKeyValuePair(tokens []token, i, colonIdx int, ctx *parseContext) (any,
 	t := tokens[i]
 	s := t.text
 
+	if err := ctx.enter(t.offset); err != nil {
+		return nil, 0, err
+	}
+	defer ctx.leave()
+
 	keyRaw := strings.TrimSpace(s[:colonIdx])
-	key := parseKeyName(keyRaw)
+	key, err := ctx.propertyKey(parseKeyName(keyRaw), t.offset)
+	if err != nil {
+		return nil, 0, err
+	}
 	valuePart := strings.TrimSpace(s[colonIdx+1:])
 
-	// Calculate column for value part
+	// Calculate offset of value part
 	afterColon := s[colonIdx+1:]
 	valueOffset := strings.Index(afterColon, valuePart)
-	valueCol := t.col + colonIdx + 1
+	valueOff := t.offset + colonIdx + 1
 	if valueOffset >= 0 {
-		valueCol += valueOffset
+		valueOff += valueOffset
 	}
 
 	// Empty value part means nested content follows
-	if valuePart == "" && len(key) > 0 {
+	if valuePart == "" && keyRaw != "" {
 		return parseObjectOrNamedArray(tokens, i, key, ctx)
 	}
 
 	// Block bytes
-	if len(key) > 0 && isBlockBytesStart(valuePart) {
+	if keyRaw != "" && isBlockBytesStart(valuePart) {
 		bytes, j, err := parseBlockBytesFromKeyLine(tokens, i, ctx, t.indent, valuePart)
 		if err != nil {
 			return nil, 0, err
 		}
-		return map[string]any{key: bytes}, j, nil
+		return ctx.newProperty(key, bytes, itemOffset(tokens, i)), j, nil
 	}
 
 	// Inline value
-	if len(key) > 0 {
+	if keyRaw != "" {
 		var value any
 		if valuePart != "" {
 			var err error
-			value, err = parseScalar(valuePart, ctx, t.lineNum, valueCol)
+			value, err = parseScalar(valuePart, ctx, valueOff)
 			if err != nil {
 				return nil, 0, err
 			}
 		}
-		return map[string]any{key: value}, i + 1, nil
+		return ctx.newProperty(key, value, itemOffset(tokens, i)), i + 1, nil
 	}
 
 	return nil, i + 1, nil
@@ -2083,8 +3135,12 @@ func findColonOutsideQuotes(s string) int {
 	inDouble := false
 	inSingle := false
 
-	for i, c := range s {
-		if c == '"' && !inSingle {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && inDouble {
+			// Skip the escaped character, which may be a quote
+			i++
+		} else if c == '"' && !inSingle {
 			inDouble = !inDouble
 		} else if c == '\'' && !inDouble {
 			inSingle = !inSingle
@@ -2141,7 +3197,7 @@ func isPropertyBlockLeaderOnly(valuePart string, leader rune) bool {
 
 // validateUnquotedKey validates that an unquoted key contains only valid characters.
 // Returns (isQuoted, error). If isQuoted is true, no validation is needed.
-func validateUnquotedKey(s string, ctx *parseContext, lineNum, col int) error {
+func validateUnquotedKey(s string, ctx *parseContext, off int) error {
 	s = strings.TrimSpace(s)
 
 	// Quoted keys don't need character validation
@@ -2159,9 +3215,9 @@ func validateUnquotedKey(s string, ctx *parseContext, lineNum, col int) error {
 		isHyphen := c == '-'
 		if !isAlpha && !isDigit && !isUnderscore && !isHyphen {
 			if i == 0 {
-				return fmt.Errorf("Invalid key%s", locSuffix(ctx, lineNum, col))
+				return ctx.errorf(off, "Invalid key")
 			}
-			return fmt.Errorf("Invalid key character%s", locSuffix(ctx, lineNum, col+i))
+			return ctx.errorf(off+i, "Invalid key character")
 		}
 	}
 	return nil
@@ -2169,6 +3225,7 @@ func validateUnquotedKey(s string, ctx *parseContext, lineNum, col int) error {
 
 // parseObjectOrNamedArray parses content after "key:" (no inline value).
 func parseObjectOrNamedArray(tokens []token, i int, key string, ctx *parseContext) (any, int, error) {
+	keyOff := itemOffset(tokens, i)
 	i++
 
 	// Skip to next content
@@ -2180,7 +3237,7 @@ func parseObjectOrNamedArray(tokens []token, i int, key string, ctx *parseContex
 	}
 
 	if i >= len(tokens) {
-		return map[string]any{key: nil}, i, nil
+		return ctx.newProperty(key, nil, keyOff), i, nil
 	}
 
 	first := tokens[i]
@@ -2191,19 +3248,19 @@ func parseObjectOrNamedArray(tokens []token, i int, key string, ctx *parseContex
 		if err != nil {
 			return nil, 0, err
 		}
-		return map[string]any{key: arr}, next, nil
+		return ctx.newProperty(key, arr, keyOff), next, nil
 	}
 
 	// Block bytes on next line - this is invalid in strict YAY
 	// The > must be on the same line as the key
 	if first.typ == tokenText && isBlockBytesStart(first.text) {
-		return nil, 0, fmt.Errorf("Unexpected indent at %d:%d of <%s>", first.lineNum+1, 1, ctx.filename)
+		return nil, 0, ctx.errorf(first.offset-first.indent, "Unexpected indent")
 	}
 
 	// Block string on next line - this is invalid in strict YAY
 	// The backtick must be on the same line as the key
 	if first.typ == tokenText && strings.TrimSpace(first.text) == "`" {
-		return nil, 0, fmt.Errorf("Unexpected indent at %d:%d of <%s>", first.lineNum+1, 1, ctx.filename)
+		return nil, 0, ctx.errorf(first.offset-first.indent, "Unexpected indent")
 	}
 
 	// Nested object
@@ -2212,15 +3269,19 @@ func parseObjectOrNamedArray(tokens []token, i int, key string, ctx *parseContex
 		return nil, 0, err
 	}
 
-	if len(obj) > 0 {
-		return map[string]any{key: obj}, next, nil
+	if objectLen(obj) > 0 {
+		return ctx.newProperty(key, obj, keyOff), next, nil
 	}
-	return map[string]any{key: nil}, next, nil
+	return ctx.newProperty(key, nil, keyOff), next, nil
 }
 
 // parseNestedObjectContent parses the content of a nested object.
-func parseNestedObjectContent(tokens []token, i, baseIndent int, ctx *parseContext) (map[string]any, int, error) {
-	obj := make(map[string]any)
+func parseNestedObjectContent(tokens []token, i, baseIndent int, ctx *parseContext) (any, int, error) {
+	if err := ctx.enter(tokens[i].offset); err != nil {
+		return nil, 0, err
+	}
+	defer ctx.leave()
+	obj := ctx.newObject(tokens[i].count)
 
 	for i < len(tokens) {
 		t := tokens[i]
@@ -2230,35 +3291,50 @@ func parseNestedObjectContent(tokens []token, i, baseIndent int, ctx *parseConte
 			continue
 		}
 
+		// A list item of an enclosing array ends the object; any other
+		// dedented list item is misplaced, and its text is rejected below
+		if t.typ == tokenStart && t.indent < baseIndent && ctx.inList(t.indent) {
+			break
+		}
+
 		if t.typ == tokenText {
 			// Reject inline values on separate line (they look like keys starting with special chars)
 			if len(t.text) > 0 && (t.text[0] == '{' || t.text[0] == '[' || t.text[0] == '<') {
-				return nil, 0, fmt.Errorf("Unexpected indent at %d:%d of <%s>", t.lineNum+1, 1, ctx.filename)
+				return nil, 0, ctx.errorf(t.offset-t.indent, "Unexpected indent")
 			}
 
 			colonIdx := findColonOutsideQuotes(t.text)
 			if colonIdx < 0 {
 				// Text without colon in nested object context is invalid
-				return nil, 0, fmt.Errorf("Unexpected indent at %d:%d of <%s>", t.lineNum+1, 1, ctx.filename)
+				return nil, 0, ctx.errorf(t.offset-t.indent, "Unexpected indent")
 			}
 			if t.indent < baseIndent {
 				break
 			}
 
 			kRaw := strings.TrimSpace(t.text[:colonIdx])
-			k := parseKeyName(kRaw)
 			vPart := strings.TrimSpace(t.text[colonIdx+1:])
 
-			if k == "" {
-				i++
-				continue
+			if kRaw == "" {
+				return nil, 0, ctx.errorf(t.offset, "Missing key")
+			}
+			if err := validateUnquotedKey(kRaw, ctx, t.offset); err != nil {
+				return nil, 0, err
+			}
+			if err := ctx.checkItems(objectLen(obj), t.offset); err != nil {
+				return nil, 0, err
+			}
+			k, err := ctx.propertyKey(parseKeyName(kRaw), t.offset)
+			if err != nil {
+				return nil, 0, err
 			}
 
 			value, nextI, err := parseObjectPropertyValue(tokens, i, t, k, vPart, baseIndent, ctx)
 			if err != nil {
 				return nil, 0, err
 			}
-			obj[k] = value
+			setProperty(obj, k, value)
+			ctx.noteProperty(obj, k, t.offset)
 			i = nextI
 		} else {
 			i++
@@ -2272,12 +3348,12 @@ func parseNestedObjectContent(tokens []token, i, baseIndent int, ctx *parseConte
 func parseObjectPropertyValue(tokens []token, i int, t token, key, vPart string, baseIndent int, ctx *parseContext) (any, int, error) {
 	// Empty object
 	if vPart == "{}" {
-		return map[string]any{}, i + 1, nil
+		return ctx.newObject(0), i + 1, nil
 	}
 
 	// Block string in property context: backtick alone on line
 	if strings.TrimSpace(vPart) == "`" {
-		body, next, err := parseBlockStringWithIndent(tokens, i, "", true, t.indent)
+		body, next, err := parseBlockStringWithIndent(tokens, i, "", true, t.indent, ctx)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -2295,7 +3371,7 @@ func parseObjectPropertyValue(tokens []token, i int, t token, key, vPart string,
 
 	// Inline value
 	if vPart != "" {
-		scalar, err := parseScalar(vPart, ctx, t.lineNum, t.col)
+		scalar, err := parseScalar(vPart, ctx, t.offset)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -2312,6 +3388,11 @@ func parseObjectPropertyValue(tokens []token, i int, t token, key, vPart string,
 
 	nextT := tokens[j]
 
+	// A list item left of the object belongs to no value of this property
+	if nextT.typ == tokenStart && nextT.indent < baseIndent {
+		return nil, 0, ctx.errorf(t.offset+findColonOutsideQuotes(t.text)+1, "Expected value after property")
+	}
+
 	// Named array - pass baseIndent as minIndent so array stops at object's level
 	if nextT.typ == tokenStart && nextT.text == "- " {
 		arr, next, err := parseMultilineArray(tokens, j, ctx, baseIndent)
@@ -2323,7 +3404,7 @@ func parseObjectPropertyValue(tokens []token, i int, t token, key, vPart string,
 
 	// Block string
 	if nextT.typ == tokenText && strings.TrimSpace(nextT.text) == "`" {
-		body, next, err := parseBlockString(tokens, j, "", true)
+		body, next, err := parseBlockString(tokens, j, "", true, ctx)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -2359,8 +3440,38 @@ func skipToNextKey(tokens []token, i, baseIndent int) int {
 
 // parseRootObject parses an object at the document root level.
 func parseRootObject(tokens []token, i int, ctx *parseContext) (any, int, error) {
-	obj := make(map[string]any)
+	if err := ctx.enter(tokens[i].offset); err != nil {
+		return nil, 0, err
+	}
+	defer ctx.leave()
+	obj := ctx.newObject(tokens[i].count)
+
+	for {
+		p, next, ok, err := nextRootProperty(tokens, i, objectLen(obj), ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		i = next
+		if !ok {
+			return obj, i, nil
+		}
+		setProperty(obj, p.key, p.value)
+		ctx.noteProperty(obj, p.key, p.off)
+	}
+}
 
+// rootProperty is a property of a root object, with the offset of its key.
+type rootProperty struct {
+	key   string
+	value any
+	off   int
+}
+
+// nextRootProperty parses the property of a root object of n properties
+// so far that begins at or after tokens[i], returning it with the index of
+// the token after it. It reports false if the object has no more, with the
+// index of the end of the tokens.
+func nextRootProperty(tokens []token, i, n int, ctx *parseContext) (rootProperty, int, bool, error) {
 	for i < len(tokens) {
 		t := tokens[i]
 
@@ -2382,48 +3493,52 @@ func parseRootObject(tokens []token, i int, ctx *parseContext) (any, int, error)
 
 		// Validate: no space before colon
 		if colonIdx > 0 && t.text[colonIdx-1] == ' ' {
-			return nil, 0, fmt.Errorf("Unexpected space before \":\" at %d:%d of <%s>", t.lineNum+1, t.col+colonIdx, ctx.filename)
+			return rootProperty{}, 0, false, ctx.errorf(t.offset+colonIdx-1, "Unexpected space before \"%s\"", ":")
 		}
 
 		kRaw := strings.TrimSpace(t.text[:colonIdx])
 
 		// Validate key characters
-		if err := validateUnquotedKey(kRaw, ctx, t.lineNum, t.col); err != nil {
-			return nil, 0, err
+		if err := validateUnquotedKey(kRaw, ctx, t.offset); err != nil {
+			return rootProperty{}, 0, false, err
 		}
 
-		k := parseKeyName(kRaw)
+		if err := ctx.checkItems(n, t.offset); err != nil {
+			return rootProperty{}, 0, false, err
+		}
+		k, err := ctx.propertyKey(parseKeyName(kRaw), t.offset)
+		if err != nil {
+			return rootProperty{}, 0, false, err
+		}
 
 		// Validate: space after colon (if there's content)
 		afterColon := t.text[colonIdx+1:]
 		if len(afterColon) > 0 && afterColon[0] == '\t' {
-			return nil, 0, fmt.Errorf("Tab not allowed (use spaces) at %d:%d of <%s>", t.lineNum+1, t.col+colonIdx+2, ctx.filename)
+			return rootProperty{}, 0, false, ctx.errorf(t.offset+colonIdx+1, "Tab not allowed (use spaces)")
 		}
 		if len(afterColon) > 0 && afterColon[0] != ' ' {
-			return nil, 0, fmt.Errorf("Expected space after \":\" at %d:%d of <%s>", t.lineNum+1, t.col+colonIdx+1, ctx.filename)
+			return rootProperty{}, 0, false, ctx.errorf(t.offset+colonIdx, "Expected space after \"%s\"", ":")
 		}
 		// Validate: no double space after colon
 		if len(afterColon) > 1 && afterColon[0] == ' ' && afterColon[1] == ' ' {
-			return nil, 0, fmt.Errorf("Unexpected space after \":\" at %d:%d of <%s>", t.lineNum+1, t.col+colonIdx+3, ctx.filename)
+			return rootProperty{}, 0, false, ctx.errorf(t.offset+colonIdx+2, "Unexpected space after \"%s\"", ":")
 		}
 
 		vPart := strings.TrimSpace(afterColon)
 		// Calculate column of value part (colon + 1 for space + 1 for 1-based)
-		vCol := t.col + colonIdx + 2
+		vOff := t.offset + colonIdx + 2
 
-		value, nextI, err := parseRootObjectProperty(tokens, i, t, k, vPart, vCol, ctx)
+		value, nextI, err := parseRootObjectProperty(tokens, i, t, k, vPart, vOff, ctx)
 		if err != nil {
-			return nil, 0, err
+			return rootProperty{}, 0, false, err
 		}
-		obj[k] = value
-		i = nextI
+		return rootProperty{key: k, value: value, off: t.offset}, nextI, true, nil
 	}
-
-	return obj, i, nil
+	return rootProperty{}, i, false, nil
 }
 
 // parseRootObjectProperty parses a single property in a root object.
-func parseRootObjectProperty(tokens []token, i int, t token, key, vPart string, vCol int, ctx *parseContext) (any, int, error) {
+func parseRootObjectProperty(tokens []token, i int, t token, key, vPart string, vOff int, ctx *parseContext) (any, int, error) {
 	// Block bytes
 	if isBlockBytesStart(vPart) {
 		bytes, j, err := parseBlockBytesFromKeyLine(tokens, i, ctx, 0, vPart)
@@ -2435,16 +3550,16 @@ func parseRootObjectProperty(tokens []token, i int, t token, key, vPart string,
 
 	// Empty object
 	if vPart == "{}" {
-		return map[string]any{}, i + 1, nil
+		return ctx.newObject(0), i + 1, nil
 	}
 
 	// Block string
 	if strings.HasPrefix(vPart, "`") {
 		// In property context, backtick must be alone (or followed only by spaces/comment)
 		if !isPropertyBlockLeaderOnly(vPart, '`') {
-			return nil, 0, fmt.Errorf("Expected newline after block leader in property")
+			return nil, 0, ctx.unplacedErrorf("Expected newline after block leader in property")
 		}
-		return parseRootBlockString(tokens, i+1)
+		return parseRootBlockString(tokens, i+1, ctx, vOff)
 	}
 
 	// Nested content
@@ -2453,19 +3568,20 @@ func parseRootObjectProperty(tokens []token, i int, t token, key, vPart string,
 	}
 
 	// Inline scalar
-	scalar, err := parseScalar(vPart, ctx, t.lineNum, vCol)
+	scalar, err := parseScalar(vPart, ctx, vOff)
 	if err != nil {
 		return nil, 0, err
 	}
 	return scalar, i + 1, nil
 }
 
-// parseRootBlockString parses a block string in a root object property.
-func parseRootBlockString(tokens []token, i int) (string, int, error) {
+// parseRootBlockString parses a block string in a root object property
+// whose leader is at offset off.
+func parseRootBlockString(tokens []token, i int, ctx *parseContext, off int) (string, int, error) {
 	i = skipBreaksAndStops(tokens, i)
 
 	// Collect indented lines
-	var lines []blockLine
+	lines := blockLinePool.get()
 	for i < len(tokens) && ((tokens[i].typ == tokenText && tokens[i].indent > 0) || tokens[i].typ == tokenBreak) {
 		if tokens[i].typ == tokenBreak {
 			lines = append(lines, blockLine{isBreak: true})
@@ -2476,28 +3592,20 @@ func parseRootBlockString(tokens []token, i int) (string, int, error) {
 	}
 
 	// Normalize and build result
-	normalized := normalizeBlockIndent(lines)
-	trimmed := trimTrailingEmpty(normalized)
-
-	body := strings.Join(trimmed, "\n")
-	if len(trimmed) > 0 {
-		body += "\n"
-	}
+	body := assembleBlockString("", lines, false)
+	blockLinePool.put(lines)
 
 	if body == "" {
-		return "", 0, fmt.Errorf("Empty block string not allowed (use \"\" or \"\\n\" explicitly)")
+		return "", 0, ctx.unplacedErrorf("Empty block string not allowed (use \"\" or \"\\n\" explicitly)")
 	}
-
-	return body, i, nil
-}
-
-// trimTrailingEmpty removes trailing empty lines.
-func trimTrailingEmpty(lines []string) []string {
-	end := len(lines)
-	for end > 0 && lines[end-1] == "" {
-		end--
+	if err := ctx.checkString(body, off); err != nil {
+		return "", 0, err
 	}
-	return lines[:end]
+	if err := ctx.countBlock(len(body), off); err != nil {
+		return "", 0, err
+	}
+
+	return ctx.chompBlock(body), i, nil
 }
 
 // parseRootNestedContent parses nested content after "key:" at root level.
@@ -2510,7 +3618,7 @@ func parseRootNestedContent(tokens []token, i int, ctx *parseContext) (any, int,
 
 	if j >= len(tokens) {
 		// Empty property with no nested content is invalid
-		return nil, 0, fmt.Errorf("Expected value after property%s", locSuffix(ctx, t.lineNum, t.col+colonIdx+1))
+		return nil, 0, ctx.errorf(t.offset+colonIdx+1, "Expected value after property")
 	}
 
 	nextT := tokens[j]
@@ -2524,11 +3632,13 @@ func parseRootNestedContent(tokens []token, i int, ctx *parseContext) (any, int,
 		return arr, next, nil
 	}
 
-	// Concatenated quoted strings (multiple quoted strings on consecutive lines)
+	// Concatenated quoted strings (multiple quoted strings on consecutive lines),
+	// as opposed to a nested object with a quoted key and value
 	if nextT.typ == tokenText && nextT.indent > 0 {
 		trimmed := strings.TrimSpace(nextT.text)
-		if (strings.HasPrefix(trimmed, "\"") && strings.HasSuffix(trimmed, "\"") && len(trimmed) >= 2) ||
-			(strings.HasPrefix(trimmed, "'") && strings.HasSuffix(trimmed, "'") && len(trimmed) >= 2) {
+		if ((strings.HasPrefix(trimmed, "\"") && strings.HasSuffix(trimmed, "\"") && len(trimmed) >= 2) ||
+			(strings.HasPrefix(trimmed, "'") && strings.HasSuffix(trimmed, "'") && len(trimmed) >= 2)) &&
+			findColonOutsideQuotes(trimmed) < 0 {
 			concatStr, next, err := parseConcatenatedStrings(tokens, j, nextT.indent, ctx)
 			if err != nil {
 				return nil, 0, err
@@ -2537,7 +3647,7 @@ func parseRootNestedContent(tokens []token, i int, ctx *parseContext) (any, int,
 				return concatStr, next, nil
 			}
 			// Single string on new line is invalid - fall through to error
-			return nil, 0, fmt.Errorf("Unexpected indent%s", locSuffix(ctx, nextT.lineNum, 0))
+			return nil, 0, ctx.errorf(nextT.offset-nextT.indent, "Unexpected indent")
 		}
 	}
 
@@ -2551,7 +3661,7 @@ func parseRootNestedContent(tokens []token, i int, ctx *parseContext) (any, int,
 	}
 
 	// Empty property with no nested content is invalid
-	return nil, 0, fmt.Errorf("Expected value after property%s", locSuffix(ctx, t.lineNum, t.col+colonIdx+1))
+	return nil, 0, ctx.errorf(t.offset+colonIdx+1, "Expected value after property")
 }
 
 // ============================================================================
@@ -2561,6 +3671,7 @@ func parseRootNestedContent(tokens []token, i int, ctx *parseContext) (any, int,
 // parseConcatenatedStrings parses multiple quoted strings on consecutive lines.
 // Returns nil if there's only one string (single string on new line is invalid).
 func parseConcatenatedStrings(tokens []token, i, baseIndent int, ctx *parseContext) (any, int, error) {
+	start := i
 	var parts []string
 
 	for i < len(tokens) {
@@ -2586,7 +3697,7 @@ func parseConcatenatedStrings(tokens []token, i, baseIndent int, ctx *parseConte
 		}
 
 		// Parse the quoted string
-		parsed, err := parseQuotedString(trimmed, ctx, t.lineNum, t.col)
+		parsed, err := parseQuotedString(trimmed, ctx, t.offset)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -2600,33 +3711,19 @@ func parseConcatenatedStrings(tokens []token, i, baseIndent int, ctx *parseConte
 		return nil, i, nil
 	}
 
-	return strings.Join(parts, ""), i, nil
+	str := strings.Join(parts, "")
+	if err := ctx.checkString(str, tokens[start].offset); err != nil {
+		return nil, 0, err
+	}
+	return str, i, nil
 }
 
 // ============================================================================
 // Scalar Parsing
 // ============================================================================
 
-// parseNestedInlineBullet recursively parses inline bullet values.
-// If the text starts with "- ", it wraps the result in an array.
-func parseNestedInlineBullet(text string, ctx *parseContext, lineNum, col int) (any, error) {
-	if inlineListItemRe.MatchString(text) {
-		// Check for double space after dash
-		if len(text) >= 3 && text[0] == '-' && text[1] == ' ' && text[2] == ' ' {
-			return nil, fmt.Errorf("Unexpected space after \"-\"%s", locSuffix(ctx, lineNum, col+2))
-		}
-		innerText := strings.TrimSpace(inlineListItemRe.ReplaceAllString(text, ""))
-		innerVal, err := parseNestedInlineBullet(innerText, ctx, lineNum, col+2)
-		if err != nil {
-			return nil, err
-		}
-		return []any{innerVal}, nil
-	}
-	return parseScalar(text, ctx, lineNum, col)
-}
-
 // parseScalar parses a scalar value from a string.
-func parseScalar(s string, ctx *parseContext, lineNum, col int) (any, error) {
+func parseScalar(s string, ctx *parseContext, off int) (any, error) {
 	// Strip inline comments first
 	s = stripComment(s)
 
@@ -2636,42 +3733,42 @@ func parseScalar(s string, ctx *parseContext, lineNum, col int) (any, error) {
 	}
 
 	// Numbers (with whitespace validation)
-	if num, ok, err := parseNumberStrict(s, ctx, lineNum, col); err != nil {
+	if num, ok, err := parseNumberStrict(s, ctx, off); err != nil {
 		return nil, err
 	} else if ok {
 		return num, nil
 	}
 
 	// Double-quoted string
-	if strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"") {
-		return parseQuotedString(s, ctx, lineNum, col)
+	if len(s) >= 2 && strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"") {
+		return parseQuotedString(s, ctx, off)
 	}
 
 	// Single-quoted string
-	if strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") {
-		return s[1 : len(s)-1], nil
+	if len(s) >= 2 && strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") {
+		return s[1 : len(s)-1], ctx.checkString(s[1:len(s)-1], off)
 	}
 
 	// Inline array
 	if strings.HasPrefix(s, "[") {
-		return parseInlineArrayStrict(s, ctx, lineNum, col)
+		return parseInlineArrayStrict(s, ctx, off)
 	}
 
 	// Inline object
 	if strings.HasPrefix(s, "{") {
-		return parseInlineObjectStrict(s, ctx, lineNum, col)
+		return parseInlineObjectStrict(s, ctx, off)
 	}
 
 	// Inline bytes
 	if strings.HasPrefix(s, "<") {
-		return parseAngleBytes(s, ctx, lineNum, col)
+		return parseAngleBytes(s, ctx, off)
 	}
 
 	// Bare words are not valid - strings must be quoted
 	if len(s) > 0 {
 		firstChar := string(s[0])
-		return nil, fmt.Errorf("Unexpected character \"%s\"%s", firstChar, locSuffix(ctx, lineNum, col))
+		return nil, ctx.errorf(off, "Unexpected character \"%s\"", firstChar)
 	}
 
-	return nil, fmt.Errorf("Unexpected empty value%s", locSuffix(ctx, lineNum, col))
+	return nil, ctx.errorf(off, "Unexpected empty value")
 }
@@ -3,13 +3,28 @@
 package yay
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"math"
 	"math/big"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+	"unsafe"
 )
 
 func TestFixtures(t *testing.T) {
@@ -26,13 +41,88 @@ func TestFixtures(t *testing.T) {
 				t.Fatalf("Unmarshal error: %v", err)
 			}
 
-			if !deepEqual(got, expected) {
+			if !Equal(got, expected) {
 				t.Errorf("mismatch\ngot:  %#v\nwant: %#v", got, expected)
 			}
 		})
 	}
 }
 
+// TestJSONFixtures checks each fixture that test/json also gives as plain
+// JSON, shared with the other implementations, against that value. JSON
+// has neither integers apart from floats nor byte arrays, so numbers are
+// compared by their values.
+func TestJSONFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "test", "json", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			var want any
+			if err := dec.Decode(&want); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalFile(input, name+".yay")
+			if err != nil {
+				t.Fatalf("Unmarshal error: %v", err)
+			}
+			if !matchesJSON(got, want) {
+				t.Errorf("mismatch\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+// matchesJSON reports whether v, as Unmarshal gives it, has the value of
+// j, as encoding/json gives it with UseNumber.
+func matchesJSON(v, j any) bool {
+	switch v := v.(type) {
+	case *big.Int:
+		n, ok := j.(json.Number)
+		want, exact := new(big.Int).SetString(string(n), 10)
+		return ok && exact && v.Cmp(want) == 0
+	case float64:
+		n, ok := j.(json.Number)
+		want, err := strconv.ParseFloat(string(n), 64)
+		return ok && err == nil && v == want
+	case []any:
+		a, ok := j.([]any)
+		if !ok || len(a) != len(v) {
+			return false
+		}
+		for i := range v {
+			if !matchesJSON(v[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		m, ok := j.(map[string]any)
+		if !ok || len(m) != len(v) {
+			return false
+		}
+		for k, item := range v {
+			if want, ok := m[k]; !ok || !matchesJSON(item, want) {
+				return false
+			}
+		}
+		return true
+	}
+	return v == j
+}
+
 func TestErrorCases(t *testing.T) {
 	nayDir := filepath.Join("..", "test", "nay")
 	entries, err := os.ReadDir(nayDir)
@@ -73,69 +163,2133 @@ func TestErrorCases(t *testing.T) {
 	}
 }
 
-// deepEqual compares two values, handling special cases like NaN and *big.Int
-func deepEqual(a, b any) bool {
-	// Handle NaN
-	if af, ok := a.(float64); ok {
-		if bf, ok := b.(float64); ok {
-			if math.IsNaN(af) && math.IsNaN(bf) {
-				return true
-			}
-		}
+func TestInternKeys(t *testing.T) {
+	input := []byte("- name: \"a\"\n  size: 1\n- name: \"b\"\n  size: 2\n- {name: \"c\", size: 3}\n")
+
+	plain, err := Unmarshal(input)
+	if err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	interned, err := UnmarshalWithOptions(input, DecodeOptions{InternKeys: true})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions error: %v", err)
+	}
+	if !Equal(plain, interned) {
+		t.Fatalf("mismatch\ngot:  %#v\nwant: %#v", interned, plain)
 	}
 
-	// Handle *big.Int
-	if ai, ok := a.(*big.Int); ok {
-		if bi, ok := b.(*big.Int); ok {
-			return ai.Cmp(bi) == 0
+	// Every occurrence of a key must share the first occurrence's bytes.
+	first := map[string]*byte{}
+	for _, item := range interned.([]any) {
+		for k := range item.(map[string]any) {
+			p := unsafe.StringData(k)
+			if q, ok := first[k]; ok && p != q {
+				t.Errorf("key %q not interned", k)
+			}
+			first[k] = p
 		}
 	}
+}
 
-	// Handle []byte
-	if ab, ok := a.([]byte); ok {
-		if bb, ok := b.([]byte); ok {
-			if len(ab) != len(bb) {
-				return false
+func TestBatchFixtures(t *testing.T) {
+	for name, expected := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+			if err != nil {
+				t.Fatal(err)
 			}
-			for i := range ab {
-				if ab[i] != bb[i] {
-					return false
-				}
+			got, err := UnmarshalWithOptions(input, DecodeOptions{Batch: true})
+			if err != nil {
+				t.Fatalf("Unmarshal error: %v", err)
 			}
-			return true
-		}
+			if !Equal(got, expected) {
+				t.Errorf("mismatch\ngot:  %#v\nwant: %#v", got, expected)
+			}
+		})
 	}
+}
 
-	// Handle []any
-	if as, ok := a.([]any); ok {
-		if bs, ok := b.([]any); ok {
-			if len(as) != len(bs) {
-				return false
+func TestPreserveKeyOrderFixtures(t *testing.T) {
+	for name, expected := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+			if err != nil {
+				t.Fatal(err)
 			}
-			for i := range as {
-				if !deepEqual(as[i], bs[i]) {
-					return false
-				}
+			got, err := UnmarshalWithOptions(input, DecodeOptions{PreserveKeyOrder: true})
+			if err != nil {
+				t.Fatalf("Unmarshal error: %v", err)
 			}
-			return true
+			if !Equal(got, expected) {
+				t.Errorf("mismatch\ngot:  %#v\nwant: %#v", got, expected)
+			}
+		})
+	}
+}
+
+func TestPreserveKeyOrder(t *testing.T) {
+	input := []byte(`zebra: 1
+apple:
+  mango: {pear: 1, fig: 2}
+  kiwi: 3
+list:
+  - b: 1
+    a: 2
+zebra: 4
+`)
+	v, err := UnmarshalWithOptions(input, DecodeOptions{PreserveKeyOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := v.(*OrderedMap)
+	apple, _ := root.Get("apple")
+	mango, _ := apple.(*OrderedMap).Get("mango")
+	list, _ := root.Get("list")
+	item := list.([]any)[0]
+	for _, c := range []struct {
+		obj  any
+		want []string
+	}{
+		{root, []string{"zebra", "apple", "list"}},
+		{apple, []string{"mango", "kiwi"}},
+		{mango, []string{"pear", "fig"}},
+		{item, []string{"b", "a"}},
+	} {
+		if got := c.obj.(*OrderedMap).Keys(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("keys: got %v, want %v", got, c.want)
 		}
 	}
+	// A repeated key keeps its first position and its last value.
+	if zebra, _ := root.Get("zebra"); !Equal(zebra, big.NewInt(4)) {
+		t.Errorf("zebra: got %v", zebra)
+	}
 
-	// Handle map[string]any
-	if am, ok := a.(map[string]any); ok {
-		if bm, ok := b.(map[string]any); ok {
-			if len(am) != len(bm) {
-				return false
-			}
-			for k, av := range am {
-				bv, ok := bm[k]
-				if !ok || !deepEqual(av, bv) {
-					return false
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "zebra: 4\napple:\n  mango: {pear: 1, fig: 2}\n  kiwi: 3\nlist:\n  - {b: 1, a: 2}\n"
+	if string(out) != want {
+		t.Errorf("Marshal: got %q, want %q", out, want)
+	}
+}
+
+func TestOrderedMap(t *testing.T) {
+	var m OrderedMap
+	for i := 0; i < 20; i++ {
+		m.Set(fmt.Sprintf("k%02d", 19-i), i)
+	}
+	m.Set("k05", "again")
+	if !m.Delete("k10") || m.Delete("k10") {
+		t.Error("Delete should report presence")
+	}
+	keys := m.Keys()
+	if len(keys) != 19 || keys[0] != "k19" || keys[18] != "k00" {
+		t.Errorf("keys: %v", keys)
+	}
+	for i, k := range keys {
+		if v, ok := m.Get(k); !ok || v != m.Members()[i].Value {
+			t.Errorf("Get(%q) = %v, %v", k, v, ok)
+		}
+	}
+	if v, _ := m.Get("k05"); v != "again" {
+		t.Errorf("k05: got %v", v)
+	}
+	if !Equal(&m, m.Map()) {
+		t.Error("OrderedMap should equal its Map")
+	}
+}
+
+func TestArray(t *testing.T) {
+	var a Array
+	a.Append(big.NewInt(1), "two")
+	if !a.Insert(0, nil) || !a.Insert(3, 4.0) || a.Insert(5, 5.0) {
+		t.Error("Insert should report whether the index is in range")
+	}
+	if !a.Set(1, true) || a.Set(4, true) {
+		t.Error("Set should report whether the index is in range")
+	}
+	if !a.Delete(2) || a.Delete(3) {
+		t.Error("Delete should report whether the index is in range")
+	}
+	if v, ok := a.Get(1); !ok || v != true {
+		t.Errorf("Get(1) = %v, %v", v, ok)
+	}
+	if _, ok := a.Get(-1); ok {
+		t.Error("Get(-1) should be out of range")
+	}
+	if want := []any{nil, true, 4.0}; a.Len() != 3 || !Equal(a, want) || !Equal(want, a) {
+		t.Errorf("got %#v", a)
+	}
+
+	doc := map[string]any{"a": Array{Array{}, big.NewInt(1)}, "b": Array{}}
+	if got := string(MustMarshal(doc)); got != "a:\n  - []\n  - 1\nb: []\n" {
+		t.Errorf("Marshal: got %q", got)
+	}
+}
+
+// celsius and point stand for types of other packages, which get codecs.
+type celsius float64
+
+type point struct{ x, y int }
+
+// money encodes itself as a whole number of cents.
+type money struct{ cents int64 }
+
+func (m money) MarshalYAY() ([]byte, error) {
+	return Marshal(m.cents)
+}
+
+func (m *money) UnmarshalYAY(data []byte) error {
+	v, err := Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	m.cents, err = AsInt64(v)
+	return err
+}
+
+// label encodes itself by a method of its pointer, as cmd/yaygen writes.
+type label struct{ text string }
+
+func (l *label) MarshalYAY() ([]byte, error) {
+	if l.text == "" {
+		return []byte("not yay"), nil
+	}
+	return Marshal(map[string]any{"text": l.text, "upper": strings.ToUpper(l.text)})
+}
+
+func TestPath(t *testing.T) {
+	doc := MustUnmarshal([]byte("servers:\n  - host: \"a\"\n    port: 80\n  - host: \"b\"\n\"odd.key\": [true]\n"))
+	for _, c := range []struct {
+		path string
+		want any
+	}{
+		{"servers[0].port", big.NewInt(80)},
+		{".servers[1].host", "b"},
+		{`["odd.key"][0]`, true},
+		{".", doc},
+		{"", doc},
+	} {
+		if got, err := Get(doc, c.path); err != nil || !Equal(got, c.want) {
+			t.Errorf("Get(%q) = %#v, %v", c.path, got, err)
+		}
+	}
+	for _, c := range []struct {
+		path   string
+		target error
+		want   string
+	}{
+		{"servers[1].port", ErrNotFound, "Not found: .servers[1].port"},
+		{"servers[2]", ErrNotFound, "Not found: .servers[2]"},
+		{"servers.port", ErrPathType, "Path does not fit value: .servers is an array of 2 items, not an object"},
+		{"servers[0].host[0]", ErrPathType, `Path does not fit value: .servers[0].host is "a", not an array`},
+		{"servers..port", ErrInvalidPath, `Invalid path "servers..port"`},
+		{"servers[x]", ErrInvalidPath, `Invalid path "servers[x]"`},
+		{`["odd`, ErrInvalidPath, `Invalid path "[\"odd"`},
+	} {
+		_, err := Get(doc, c.path)
+		if !errors.Is(err, c.target) || err.Error() != c.want {
+			t.Errorf("Get(%q): got %v, want %q", c.path, err, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		path  string
+		value any
+	}{
+		{"servers[1].port", big.NewInt(81)},
+		{"servers[2]", map[string]any{"host": "c"}},
+		{"servers[0].tags[0]", "x"},
+		{"limits.cpu", 2.5},
+		{`["odd.key"][0]`, false},
+	} {
+		if err := Set(&doc, c.path, c.value); err != nil {
+			t.Errorf("Set(%q): %v", c.path, err)
+		}
+	}
+	want := "limits: {cpu: 2.5}\n\"odd.key\": [false]\nservers:\n  - host: \"a\"\n    port: 80\n    tags: [\"x\"]\n  - {host: \"b\", port: 81}\n  - {host: \"c\"}\n"
+	if got := string(MustMarshal(doc)); got != want {
+		t.Errorf("Set: got %q, want %q", got, want)
+	}
+	if err := Set(&doc, "servers[5]", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Set past the end: got %v", err)
+	}
+	if err := Set(&doc, "limits.cpu.max", nil); !errors.Is(err, ErrPathType) {
+		t.Errorf("Set through a scalar: got %v", err)
+	}
+
+	var built any
+	if err := Set(&built, "a[0].b", "c"); err != nil || !Equal(built, map[string]any{"a": []any{map[string]any{"b": "c"}}}) {
+		t.Errorf("Set on nil: got %#v, %v", built, err)
+	}
+}
+
+func TestPointer(t *testing.T) {
+	doc := MustUnmarshal([]byte("\"a/b\": {\"m~n\": [1, 2]}\n\"c.d[0]\": true\n\"\": \"empty\"\nlist: [{\"0\": \"zero\"}]\n"))
+	for _, c := range []struct {
+		pointer string
+		tokens  Pointer
+		want    any
+	}{
+		{"/a~1b/m~0n/1", Pointer{"a/b", "m~n", "1"}, big.NewInt(2)},
+		{"/c.d[0]", Pointer{"c.d[0]"}, true},
+		{"/", Pointer{""}, "empty"},
+		{"/list/0/0", Pointer{"list", "0", "0"}, "zero"},
+		{"", Pointer{}, doc},
+	} {
+		p, err := ParsePointer(c.pointer)
+		if err != nil || !reflect.DeepEqual(p, c.tokens) {
+			t.Errorf("ParsePointer(%q) = %q, %v", c.pointer, p, err)
+			continue
+		}
+		if s := p.String(); s != c.pointer {
+			t.Errorf("String() = %q, want %q", s, c.pointer)
+		}
+		if got, err := p.Resolve(doc); err != nil || !Equal(got, c.want) {
+			t.Errorf("Resolve(%q) = %#v, %v", c.pointer, got, err)
+		}
+	}
+	for _, s := range []string{"a/b", "/a~", "/a~2"} {
+		if _, err := ParsePointer(s); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("ParsePointer(%q): got %v, want ErrInvalidPath", s, err)
+		}
+	}
+	for _, c := range []struct {
+		pointer string
+		want    string
+	}{
+		{"/list/01", "Path does not fit value: /list is an array of 1 items, not an object"},
+		{"/list/1", "Not found: /list/1"},
+		{"/a~1b/x", "Not found: /a~1b/x"},
+	} {
+		if _, err := Get(doc, c.pointer); err == nil || err.Error() != c.want {
+			t.Errorf("Get(%q): got %v, want %q", c.pointer, err, c.want)
+		}
+	}
+
+	// Pointers work in patches, with "-" for the end of an array.
+	got, err := ApplyPatch(doc, []PatchOperation{
+		{Op: "add", Path: "/a~1b/m~0n/-", Value: big.NewInt(3)},
+		{Op: "move", From: "/c.d[0]", Path: "/list/0/1"},
+		{Op: "test", Path: "/a~1b/m~0n/2", Value: big.NewInt(3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := Get(got, "/list/0/1"); v != true {
+		t.Errorf("moved value: got %#v", v)
+	}
+
+	changes := Diff(doc, got)
+	var pointers []string
+	for _, c := range changes {
+		pointers = append(pointers, c.Pointer.String())
+	}
+	if want := []string{"/a~1b/m~0n/2", "/c.d[0]", "/list/0/1"}; !reflect.DeepEqual(pointers, want) {
+		t.Errorf("Diff pointers: got %q, want %q", pointers, want)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	doc := NewOrderedMap(
+		Member{"z", big.NewInt(1)},
+		Member{"big", huge},
+		Member{"list", Array{math.NaN(), math.Inf(-1), 2.5}},
+	)
+	before := string(MustMarshal(doc))
+	got, err := Normalize(doc, NormalizeOptions{SmallInts: true, Unordered: true, NonFinite: NonFiniteNull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"z": int64(1), "big": huge, "list": []any{nil, nil, 2.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if after := string(MustMarshal(doc)); after != before {
+		t.Errorf("Normalize modified its argument: %q", after)
+	}
+
+	// By default only Array changes.
+	got, err = Normalize(doc, NormalizeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(*OrderedMap); !ok || !Equal(got, doc) {
+		t.Errorf("default: got %#v", got)
+	}
+	if list, _ := got.(*OrderedMap).Get("list"); reflect.TypeOf(list) != reflect.TypeOf([]any{}) {
+		t.Errorf("default: list is %T", list)
+	}
+
+	_, err = Normalize(doc, NormalizeOptions{NonFinite: NonFiniteError})
+	if want := "Non-finite number nan at .list[0]"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	huge, _ := new(big.Int).SetString("9223372036854775808", 10)
+	odd, _ := new(big.Int).SetString("9007199254740993", 10)
+
+	if s, err := AsString("x"); err != nil || s != "x" {
+		t.Errorf("AsString: %q, %v", s, err)
+	}
+	if b, err := AsBool(true); err != nil || !b {
+		t.Errorf("AsBool: %v, %v", b, err)
+	}
+	if b, err := AsBytes([]byte{1}); err != nil || len(b) != 1 {
+		t.Errorf("AsBytes: %v, %v", b, err)
+	}
+	for _, c := range []struct {
+		v    any
+		want int64
+	}{
+		{big.NewInt(-7), -7},
+		{uint64(math.MaxInt64), math.MaxInt64},
+		{int8(3), 3},
+	} {
+		if n, err := AsInt64(c.v); err != nil || n != c.want {
+			t.Errorf("AsInt64(%#v) = %d, %v", c.v, n, err)
+		}
+	}
+	for _, c := range []struct {
+		v    any
+		want float64
+	}{
+		{2.5, 2.5},
+		{float32(0.5), 0.5},
+		{big.NewInt(1 << 53), 1 << 53},
+		{huge, 1 << 63},
+	} {
+		if f, err := AsFloat64(c.v); err != nil || f != c.want {
+			t.Errorf("AsFloat64(%#v) = %v, %v", c.v, f, err)
+		}
+	}
+
+	for _, c := range []struct {
+		f    func() error
+		want string
+	}{
+		{func() error { _, err := AsString(big.NewInt(42)); return err }, "Expected a string, not 42"},
+		{func() error { _, err := AsBool(nil); return err }, "Expected a boolean, not null"},
+		{func() error { _, err := AsBytes("ab"); return err }, `Expected a byte array, not "ab"`},
+		{func() error { _, err := AsInt64(1.0); return err }, "Expected an integer, not 1.0"},
+		{func() error { _, err := AsInt64(huge); return err }, "Integer 9223372036854775808 overflows int64"},
+		{func() error { _, err := AsInt64(uint64(math.MaxUint64)); return err }, "Integer 18446744073709551615 overflows int64"},
+		{func() error { _, err := AsFloat64(odd); return err }, "Integer 9007199254740993 is not exactly a float64"},
+		{func() error { _, err := AsFloat64([]any{}); return err }, "Expected a number, not an array of 0 items"},
+	} {
+		if err := c.f(); err == nil || err.Error() != c.want {
+			t.Errorf("got %v, want %q", err, c.want)
+		}
+	}
+}
+
+func TestChompBlockStrings(t *testing.T) {
+	source := []byte("about: `\n  Says hello.\n  Twice.\nitems:\n  - ` one\n  - \"two\\n\"\nroot: `\n  text\n\n")
+	got, err := UnmarshalWithOptions(source, DecodeOptions{ChompBlockStrings: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"about": "Says hello.\nTwice.", "items": []any{"one", "two\n"}, "root": "text"}
+	if !Equal(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, err := UnmarshalWithOptions([]byte("`\n  text\n"), DecodeOptions{ChompBlockStrings: true}); err != nil || got != "\ntext" {
+		t.Errorf("root: got %q, %v", got, err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/server.yay":        {Data: []byte("port: 80\n")},
+		"conf/plugins/auth.yay":  {Data: []byte("enabled: true\n")},
+		"conf/plugins/cache.yay": {Data: []byte("[1, 2]\n")},
+		"conf/plugins/README":    {Data: []byte("not a document")},
+		"conf/.hidden.yay":       {Data: []byte("}")},
+		"conf/.git/x.yay":        {Data: []byte("}")},
+		"conf/empty/notes.txt":   {Data: []byte("")},
+	}
+	got, err := LoadDir(fsys, "conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "plugins:\n  auth: {enabled: true}\n  cache: [1, 2]\nserver: {port: 80}\n"
+	if out := string(MustMarshal(got)); out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	got, err = LoadDirWithOptions(fsys, ".", DecodeOptions{PreserveKeyOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, _ := got.(*OrderedMap).Get("conf")
+	if keys := conf.(*OrderedMap).Keys(); !reflect.DeepEqual(keys, []string{"plugins", "server"}) {
+		t.Errorf("keys: got %v", keys)
+	}
+
+	fsys["conf/plugins/cache.yay"] = &fstest.MapFile{Data: []byte("[1,\n")}
+	_, err = LoadDir(fsys, "conf")
+	if want := "Unexpected newline in inline array at 1:1 of <conf/plugins/cache.yay>"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+
+	fsys["conf/plugins/cache.yay"] = &fstest.MapFile{Data: []byte("[]\n")}
+	fsys["conf/plugins.yay"] = &fstest.MapFile{Data: []byte("{}\n")}
+	_, err = LoadDir(fsys, "conf")
+	if want := `Both conf/plugins.yay and the directory conf/plugins give the property "plugins"`; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yay")
+	// Each version has a different size, and is renamed into place so that
+	// the watcher never sees it half written.
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(name+".tmp", []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(name+".tmp", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("port: 80\n")
+
+	values := make(chan any, 10)
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, name, WatchOptions{
+			Interval: time.Millisecond,
+			Validate: func(v any) error {
+				if _, err := Get(v, "port"); err != nil {
+					return err
 				}
-			}
-			return true
+				return nil
+			},
+			OnError: func(err error) { errs <- err },
+		}, func(v any) { values <- v })
+	}()
+	next := func() any {
+		t.Helper()
+		select {
+		case v := <-values:
+			return v
+		case err := <-errs:
+			t.Fatalf("unexpected error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a value")
+		}
+		return nil
+	}
+	nextError := func() error {
+		t.Helper()
+		select {
+		case v := <-values:
+			t.Fatalf("unexpected value: %#v", v)
+		case err := <-errs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an error")
+		}
+		return nil
+	}
+
+	if v := next(); !Equal(v, map[string]any{"port": big.NewInt(80)}) {
+		t.Errorf("initial: got %#v", v)
+	}
+	write("port: [\n")
+	if err := nextError(); !strings.Contains(err.Error(), "config.yay") {
+		t.Errorf("parse error: got %v", err)
+	}
+	write("host: \"a\"\n")
+	if err := nextError(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("validation error: got %v", err)
+	}
+	write("port: 8080\n")
+	if v := next(); !Equal(v, map[string]any{"port": big.NewInt(8080)}) {
+		t.Errorf("changed: got %#v", v)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Watch returned %v", err)
+	}
+
+	// A directory is loaded as a tree.
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.yay"), []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	err := Watch(ctx, dir, WatchOptions{}, func(v any) {
+		if !Equal(v, map[string]any{"a": big.NewInt(1)}) {
+			t.Errorf("tree: got %#v", v)
+		}
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("Watch returned %v", err)
+	}
+
+	if err := Watch(context.Background(), filepath.Join(t.TempDir(), "missing.yay"), WatchOptions{}, func(any) {}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	doc := MustUnmarshal([]byte("server:\n  port: 80\n  Host: \"a\"\nlog:\n  max-size: 10\nservers: [{name: \"x\"}]\ncode: \"007\"\n"))
+	err := ApplyEnv(&doc, "APP_", []string{
+		"APP_SERVER__PORT=9090",
+		"APP_SERVER__HOST=example.com",
+		"APP_LOG__MAX_SIZE=20",
+		"APP_LOG__LEVEL=debug",
+		"APP_SERVERS__0__NAME=y",
+		"APP_SERVERS__1={name: \"z\"}",
+		"APP_CODE=008",
+		"APP_FLAGS=[true, false]",
+		"OTHER_SERVER__PORT=1",
+		"APP_=ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "code: \"008\"\nflags: [true, false]\nlog: {level: \"debug\", max-size: 20}\nserver: {Host: \"example.com\", port: 9090}\nservers:\n  - {name: \"y\"}\n  - {name: \"z\"}\n"
+	if out := string(MustMarshal(doc)); out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	err = ApplyEnv(&doc, "APP_", []string{"APP_CODE__X=1"})
+	if want := `Cannot apply APP_CODE__X: Path does not fit value: .code is "008", not an object`; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+
+	var built any
+	if err := ApplyEnv(&built, "APP_", []string{"APP_A__B=1"}); err != nil || !Equal(built, map[string]any{"a": map[string]any{"b": big.NewInt(1)}}) {
+		t.Errorf("nil document: got %#v, %v", built, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	defaults := MustUnmarshal([]byte("name: \"app\"\nlog: {level: \"info\", json: false}\nports: [80]\nusers:\n  - {name: \"ann\", role: \"admin\"}\n  - {name: \"bob\", role: \"dev\"}\n"))
+	overlay := MustUnmarshal([]byte("log: {level: \"debug\"}\nports: [443]\nusers:\n  - {name: \"bob\", role: \"ops\"}\n  - {name: \"cat\"}\n  - \"guest\"\nextra: null\n"))
+	before := MustMarshal(defaults)
+
+	for _, c := range []struct {
+		opts MergeOptions
+		want string
+	}{
+		{MergeOptions{}, "extra: null\nlog: {json: false, level: \"debug\"}\nname: \"app\"\nports: [443]\nusers:\n  - {name: \"bob\", role: \"ops\"}\n  - {name: \"cat\"}\n  - \"guest\"\n"},
+		{MergeOptions{Arrays: MergeAppend}, "extra: null\nlog: {json: false, level: \"debug\"}\nname: \"app\"\nports: [80, 443]\nusers:\n  - {name: \"ann\", role: \"admin\"}\n  - {name: \"bob\", role: \"dev\"}\n  - {name: \"bob\", role: \"ops\"}\n  - {name: \"cat\"}\n  - \"guest\"\n"},
+		{MergeOptions{Arrays: MergeByKey, Key: "name"}, "extra: null\nlog: {json: false, level: \"debug\"}\nname: \"app\"\nports: [80, 443]\nusers:\n  - {name: \"ann\", role: \"admin\"}\n  - {name: \"bob\", role: \"ops\"}\n  - {name: \"cat\"}\n  - \"guest\"\n"},
+	} {
+		got, err := Merge(defaults, overlay, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := string(MustMarshal(got)); out != c.want {
+			t.Errorf("%+v:\ngot:  %q\nwant: %q", c.opts, out, c.want)
+		}
+	}
+	if after := MustMarshal(defaults); string(after) != string(before) {
+		t.Errorf("Merge modified dst: %q", after)
+	}
+
+	// Ordered objects stay ordered, with new keys after the old.
+	a := NewOrderedMap(Member{"z", big.NewInt(1)}, Member{"a", big.NewInt(2)})
+	got, _ := Merge(a, map[string]any{"m": big.NewInt(3), "z": big.NewInt(4)}, MergeOptions{})
+	if keys := got.(*OrderedMap).Keys(); !reflect.DeepEqual(keys, []string{"z", "a", "m"}) {
+		t.Errorf("keys: got %v", keys)
+	}
+
+	if _, err := Merge(a, a, MergeOptions{Arrays: MergeByKey}); err == nil {
+		t.Error("MergeByKey without a Key should fail")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := MustUnmarshal([]byte("n: 1\nf: nan\nlist: [1, 2, 3]\nobj: {x: 1}\ngone: true\n"))
+	b := MustUnmarshal([]byte("n: 1\nf: nan\nlist: [1, 5]\nobj: [1]\nnew: <cafe>\n"))
+	var got []string
+	for _, c := range Diff(a, b) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"- .gone: true",
+		"~ .list[1]: 2 -> 5",
+		"- .list[2]: 3",
+		"+ .new: <cafe>",
+		"~ .obj: an object of 1 properties -> an array of 1 items",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if changes := Diff(a, MustUnmarshal(MustMarshal(a))); changes != nil {
+		t.Errorf("equal documents: got %v", changes)
+	}
+	if changes := Diff("a", "b"); len(changes) != 1 || changes[0].Path != "." || changes[0].Pointer.String() != "" || changes[0].Before != "a" || changes[0].After != "b" {
+		t.Errorf("root: got %v", changes)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := MustUnmarshal([]byte("version: 2\nservers:\n  - {host: \"a\", port: 80}\nlegacy: {x: 1}\ndebug: true\n"))
+	before := string(MustMarshal(doc))
+	patch, err := DecodePatch(MustUnmarshal([]byte(`- {op: "test", path: ".version", value: 2}
+- {op: "replace", path: ".servers[0].port", value: 8080}
+- {op: "add", path: ".servers[1]", value: {host: "b"}}
+- {op: "add", path: ".servers[0]", value: {host: "z"}}
+- {op: "move", from: ".legacy", path: ".compat"}
+- {op: "remove", path: ".debug"}
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ApplyPatch(doc, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "compat: {x: 1}\nservers:\n  - {host: \"z\"}\n  - {host: \"a\", port: 8080}\n  - {host: \"b\"}\nversion: 2\n"
+	if out := string(MustMarshal(got)); out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	if after := string(MustMarshal(doc)); after != before {
+		t.Errorf("ApplyPatch modified doc: %q", after)
+	}
+
+	for _, c := range []struct {
+		op     PatchOperation
+		target error
+		want   string
+	}{
+		{PatchOperation{Op: "test", Path: ".version", Value: big.NewInt(3)}, ErrTestFailed, "Patch operation 0: Patch test failed: .version is 2, not 3"},
+		{PatchOperation{Op: "remove", Path: ".servers[1]"}, ErrNotFound, "Patch operation 0: Not found: .servers[1]"},
+		{PatchOperation{Op: "replace", Path: ".nope"}, ErrNotFound, "Patch operation 0: Not found: .nope"},
+		{PatchOperation{Op: "add", Path: ".servers[2]"}, ErrNotFound, "Patch operation 0: Not found: .servers[2]"},
+		{PatchOperation{Op: "add", Path: ".nope.x"}, ErrNotFound, "Patch operation 0: Not found: .nope"},
+		{PatchOperation{Op: "add", Path: ".debug.x"}, ErrPathType, "Patch operation 0: Path does not fit value: .debug is true, not an object"},
+		{PatchOperation{Op: "move", From: ".legacy", Path: ".legacy.y"}, nil, "Patch operation 0: Cannot move .legacy into itself"},
+		{PatchOperation{Op: "copy", Path: ".x"}, nil, `Patch operation 0: Unknown patch operation "copy"`},
+	} {
+		_, err := ApplyPatch(doc, []PatchOperation{c.op})
+		if err == nil || c.target != nil && !errors.Is(err, c.target) || err.Error() != c.want {
+			t.Errorf("%+v: got %v, want %q", c.op, err, c.want)
+		}
+	}
+
+	if got, err := ApplyPatch(doc, []PatchOperation{{Op: "replace", Path: ".", Value: "new"}}); err != nil || got != "new" {
+		t.Errorf("replace root: got %#v, %v", got, err)
+	}
+	for _, source := range []string{"{}\n", "- 1\n", "- {path: \".a\"}\n", "- {op: \"add\", path: \".a\"}\n"} {
+		if _, err := DecodePatch(MustUnmarshal([]byte(source))); err == nil {
+			t.Errorf("DecodePatch(%q) should fail", source)
+		}
+	}
+}
+
+func TestInlineDeepNesting(t *testing.T) {
+	// Each nesting level used to rescan the remainder of the line.
+	const depth = 20000
+	input := "[" + strings.Repeat("[", depth) + strings.Repeat("]", depth) + "]"
+	got, err := UnmarshalWithOptions([]byte(input), DecodeOptions{MaxDepth: depth + 1})
+	if err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	for i := 0; i < depth; i++ {
+		arr, ok := got.([]any)
+		if !ok || len(arr) != 1 {
+			t.Fatalf("level %d: got %#v", i, got)
+		}
+		got = arr[0]
+	}
+	if arr, ok := got.([]any); !ok || len(arr) != 0 {
+		t.Fatalf("innermost: got %#v", got)
+	}
+}
+
+func TestTooDeep(t *testing.T) {
+	// Without a limit, these exhaust the goroutine stack and crash.
+	const depth = 1000000
+	for _, input := range []string{
+		strings.Repeat("- ", depth) + "1\n",
+		strings.Repeat("[", depth) + strings.Repeat("]", depth) + "\n",
+		"a: " + strings.Repeat("{b: ", depth) + "1" + strings.Repeat("}", depth) + "\n",
+	} {
+		if _, err := Unmarshal([]byte(input)); !errors.Is(err, ErrTooDeep) {
+			t.Errorf("%.20q...: got %v, want ErrTooDeep", input, err)
+		}
+		if Valid([]byte(input)) {
+			t.Errorf("%.20q...: valid", input)
 		}
 	}
 
-	return reflect.DeepEqual(a, b)
+	_, err := UnmarshalWithOptions([]byte("[[[1]]]\n"), DecodeOptions{MaxDepth: 2})
+	if !errors.Is(err, ErrTooDeep) {
+		t.Errorf("MaxDepth: got %v, want ErrTooDeep", err)
+	}
+	if _, err := UnmarshalWithOptions([]byte("[[1]]\n"), DecodeOptions{MaxDepth: 2}); err != nil {
+		t.Errorf("MaxDepth: %v", err)
+	}
+
+	// A single line of open brackets fails at the first too many.
+	input := []byte(strings.Repeat("[", 100000) + strings.Repeat("]", 100000) + "\n")
+	_, err = UnmarshalFileWithOptions(input, "test.yay", DecodeOptions{MaxInlineDepth: 100})
+	if want := "Nesting too deep in inline value (limit 100) at 1:101 of <test.yay>"; !errors.Is(err, ErrTooDeep) || err.Error() != want {
+		t.Errorf("MaxInlineDepth: got %v, want %q", err, want)
+	}
+}
+
+func TestDeepNestingStack(t *testing.T) {
+	// Inline collections and lists begun on one line nest without
+	// recursion, so with the depth limit raised they parse in a small
+	// stack however deep they go.
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+	const depth = 200000
+	opts := DecodeOptions{MaxDepth: depth + 1}
+	for _, input := range []string{
+		strings.Repeat("- ", depth) + "1\n",
+		strings.Repeat("[", depth) + strings.Repeat("]", depth) + "\n",
+		"a: " + strings.Repeat("{b: ", depth) + "1" + strings.Repeat("}", depth) + "\n",
+		strings.Repeat("- ", depth/2) + "[" + strings.Repeat("[", depth/2) + strings.Repeat("]", depth/2) + "]\n",
+	} {
+		if _, err := UnmarshalWithOptions([]byte(input), opts); err != nil {
+			t.Errorf("%.20q...: %v", input, err)
+		}
+	}
+
+	// Nesting by indentation recurses, as do ParseAST, Format, SortKeys,
+	// and Marshal, but the default limit keeps them within a few
+	// megabytes of stack. Such a document grows with the square of its
+	// depth, so it is too large to be worth nesting deeper by accident.
+	var b strings.Builder
+	for i := 0; i < defaultMaxDepth-1; i++ {
+		b.WriteString(strings.Repeat("  ", i) + "a:\n")
+	}
+	opened := b.String()
+	deepest := []byte(opened + strings.Repeat("  ", defaultMaxDepth-1) + "a: 1\n")
+	v, err := Unmarshal(deepest)
+	if err != nil {
+		t.Fatalf("default limit: %v", err)
+	}
+	if _, err := ParseAST(deepest); err != nil {
+		t.Errorf("ParseAST: %v", err)
+	}
+	if _, err := Format(deepest); err != nil {
+		t.Errorf("Format: %v", err)
+	}
+	if _, err := SortKeys(deepest, SortOptions{}); err != nil {
+		t.Errorf("SortKeys: %v", err)
+	}
+	if _, err := Marshal(v); err != nil {
+		t.Errorf("Marshal: %v", err)
+	}
+	deeper := opened + strings.Repeat("  ", defaultMaxDepth-1) + "a:\n" + strings.Repeat("  ", defaultMaxDepth) + "a: 1\n"
+	if _, err := Unmarshal([]byte(deeper)); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("past the default limit: got %v, want ErrTooDeep", err)
+	}
+}
+
+func TestForbiddenCodePointPosition(t *testing.T) {
+	// Columns count code points, so the two-byte "é" is one column.
+	_, err := UnmarshalFile([]byte("a: 1\nb: \"é\x07\"\n"), "test.yay")
+	want := "Forbidden code point U+0007 at 2:6 of <test.yay>"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"a: \"\xff\"\n", "Invalid UTF-8 (byte offset 4) at 1:5 of <test.yay>"},
+		{"a: 1\nb: \"é\xc3\"\n", "Invalid UTF-8 (byte offset 11) at 2:6 of <test.yay>"},
+		{"a: \"\xc0\xaf\"\n", "Invalid UTF-8 (byte offset 4) at 1:5 of <test.yay>"},
+		{"a: \"\xed\xa0\x80\"\n", "Illegal surrogate at 1:5 of <test.yay>"},
+		{"# \xfe\na: 1\n", "Invalid UTF-8 (byte offset 2) at 1:3 of <test.yay>"},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFile([]byte(c.source), "test.yay")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+		if Valid([]byte(c.source)) {
+			t.Errorf("Valid(%q) = true", c.source)
+		}
+	}
+	// A replacement character that is really in the source is fine.
+	if _, err := Unmarshal([]byte("a: \"\uFFFD\"\n")); err != nil {
+		t.Errorf("U+FFFD: %v", err)
+	}
+}
+
+func TestErrorPositionAfterNonASCII(t *testing.T) {
+	// The parser records byte offsets; columns are derived in code points
+	// only when an error is reported.
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"k: \"héllo\\q\"\n", "Bad escaped character at 1:11 of <test.yay>"},
+		{"a: 1\nk: [\"é\",  2]\n", "Unexpected space after \",\" at 2:10 of <test.yay>"},
+		{"k: {\"é\": 1,2}\n", "Expected space after \",\" at 1:11 of <test.yay>"},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFile([]byte(c.source), "test.yay")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+	}
+}
+
+func TestIndentation(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string // Error, or "" if the document is valid
+	}{
+		{"a:\n    b: 1\n  c: 2\n", "Inconsistent indentation at 3:1 of <test.yay>"},
+		{"a:\n  b: 1\n c: 2\n", "Inconsistent indentation at 3:1 of <test.yay>"},
+		{"a:\n  b: 1\n   c: 2\n", "Unexpected indent at 3:1 of <test.yay>"},
+		{"a:\n  - 1\n   - 2\n", "Inconsistent indentation at 3:1 of <test.yay>"},
+		{"a:\n  - 1\n      - 2\n", "Unexpected indent at 3:1 of <test.yay>"},
+		{"- a: 1\n    b: 2\n", "Unexpected indent at 2:1 of <test.yay>"},
+		{"- - 1\n   - 2\n", "Inconsistent indentation at 2:1 of <test.yay>"},
+		{"a:\n   b: 1\n   c:\n       d: 2\ne: 3\n", ""},
+		{"- a: 1\n  b: 2\n- - 1\n  - 2\n", ""},
+		{"a:\n  b: `\n      deep\n     body\n  c: 1\n", ""},
+		{"a:\n  b: 1  # note\n  c: [1,\n     2]\n", "Unterminated inline array at 3:3 of <test.yay>"},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFileWithOptions([]byte(c.source), "test.yay", DecodeOptions{StrictIndentation: true})
+		if c.want == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", c.source, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+
+		// Without the option, only errors of other kinds remain.
+		_, err = UnmarshalFile([]byte(c.source), "test.yay")
+		if strings.Contains(c.want, "indent") && err != nil {
+			t.Errorf("%q without StrictIndentation: unexpected error %v", c.source, err)
+		}
+	}
+}
+
+func TestDedentedListItem(t *testing.T) {
+	// A list item left of a nested object ends the object only if it is an
+	// item of an array the object is within.
+	for source, want := range map[string]any{
+		"- a:\n    b: 1\n- 2\n":           []any{map[string]any{"a": map[string]any{"b": big.NewInt(1)}}, big.NewInt(2)},
+		"x:\n  - a:\n      b: 1\n  - 2\n": map[string]any{"x": []any{map[string]any{"a": map[string]any{"b": big.NewInt(1)}}, big.NewInt(2)}},
+	} {
+		if got, err := Unmarshal([]byte(source)); err != nil || !Equal(got, want) {
+			t.Errorf("%q: got %#v, %v", source, got, err)
+		}
+	}
+	for _, c := range []struct {
+		source string
+		want   string
+	}{
+		{"- a:\n    b: 1\n  - 2\n", "Unexpected indent at 3:1 of <test.yay>"},
+		{"- a:\n    b:\n      - 1\n      #- c: 3\n", "Invalid key at 4:7 of <test.yay>"},
+	} {
+		_, err := UnmarshalFile([]byte(c.source), "test.yay")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+	}
+}
+
+func TestInlineNestedList(t *testing.T) {
+	// A list begun on the line of its enclosing item holds a scalar or an
+	// inline collection, and columns count from the enclosing item's text,
+	// as the other implementations count.
+	for _, c := range []struct {
+		source string
+		want   string
+	}{
+		{"- - :1\n", `Unexpected character ":" at 1:3 of <test.yay>`},
+		{"- - - : 1\n", `Unexpected character ":" at 1:5 of <test.yay>`},
+		{"- - a:[1]\n", `Unexpected character "a" at 1:3 of <test.yay>`},
+		{"- - null: 1\n", `Unexpected character "n" at 1:3 of <test.yay>`},
+		{"- - 1e5: 1\n", `Unexpected character "1" at 1:3 of <test.yay>`},
+		{"- - > ab\n", `Unexpected character ">" at 1:3 of <test.yay>`},
+		{"- - a: 1\n    b: 2\n", `Unexpected character "a" at 1:3 of <test.yay>`},
+		{"- -  a\n", `Unexpected space after "-" at 1:3 of <test.yay>`},
+	} {
+		_, err := UnmarshalFile([]byte(c.source), "test.yay")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+	}
+	for source, want := range map[string]any{
+		"- - 42\n    - 4\n":                     []any{[]any{big.NewInt(42), big.NewInt(4)}},
+		"- - \"a\"\n  - \"b\"\n- - 1\n   - 2\n": []any{[]any{"a", "b"}, []any{big.NewInt(1), big.NewInt(2)}},
+		"- - - 1\n      - 2\n":                  []any{[]any{[]any{big.NewInt(1)}, big.NewInt(2)}},
+		"- - \"a: b\"\n":                        []any{[]any{"a: b"}},
+		"- - {a: 1}\n":                          []any{[]any{map[string]any{"a": big.NewInt(1)}}},
+	} {
+		if got, err := Unmarshal([]byte(source)); err != nil || !Equal(got, want) {
+			t.Errorf("%q: got %#v, %v", source, got, err)
+		}
+	}
+}
+
+func TestNumberShapes(t *testing.T) {
+	// The regular expressions the matchers replaced, so that a tiny build
+	// reads numbers as any other does.
+	integerRe := regexp.MustCompile(`^-?\d+$`)
+	floatRe := regexp.MustCompile(`^-?\d*\.\d*([eE][+-]?\d+)?$`)
+	floatExpRe := regexp.MustCompile(`^-?\d+[eE][+-]?\d+$`)
+	check := func(s string) {
+		if got, want := isInteger(s), integerRe.MatchString(s); got != want {
+			t.Errorf("isInteger(%q) = %v, want %v", s, got, want)
+		}
+		ok, point := floatShape(s)
+		want := floatExpRe.MatchString(s) || floatRe.MatchString(s) && s != "." && s != "-."
+		if ok != want || ok && point != strings.Contains(s, ".") {
+			t.Errorf("floatShape(%q) = %v, %v, want %v", s, ok, point, want)
+		}
+	}
+	for _, s := range []string{"", "-", "0", "-42", "4-2", "1.", ".5", "-.5", ".", "-.", "1e5", "1E+5", "1e-5", "1e", "e5", ".e5", "1.5e", "1.5e+", "1..5", "--1", "1e5.0", "١٢"} {
+		check(s)
+	}
+	// Every string of up to five of the characters that matter.
+	const alphabet = "-0.e+5x"
+	var each func(prefix string)
+	each = func(prefix string) {
+		check(prefix)
+		if len(prefix) < 5 {
+			for i := 0; i < len(alphabet); i++ {
+				each(prefix + alphabet[i:i+1])
+			}
+		}
+	}
+	each("")
+}
+
+func TestFloatRange(t *testing.T) {
+	cases := []struct {
+		source    string
+		want      string // Error by default
+		saturated any    // Value with SaturateFloats
+	}{
+		{"a: 1.0e999\n", "Float overflow at 1:4 of <test.yay>", math.Inf(1)},
+		{"a: 1e999\n", "Float overflow at 1:4 of <test.yay>", math.Inf(1)},
+		{"a: -1.5e400\n", "Float overflow at 1:4 of <test.yay>", math.Inf(-1)},
+		{"a: [1, 1.0e999]\n", "Float overflow at 1:8 of <test.yay>", []any{big.NewInt(1), math.Inf(1)}},
+		{"a: {x: -1.0e999}\n", "Float overflow at 1:8 of <test.yay>", map[string]any{"x": math.Inf(-1)}},
+		{"a: 1e-999\n", "Float underflow at 1:4 of <test.yay>", 0.0},
+		{"a: [2.0e-324]\n", "Float underflow at 1:5 of <test.yay>", []any{0.0}},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFile([]byte(c.source), "test.yay")
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+		got, err := UnmarshalWithOptions([]byte(c.source), DecodeOptions{SaturateFloats: true})
+		if want := map[string]any{"a": c.saturated}; err != nil || !Equal(got, want) {
+			t.Errorf("%q saturated: got %#v, %v, want %#v", c.source, got, err, want)
+		}
+	}
+
+	// Zero itself, and the smallest denormal, are in range.
+	for _, source := range []string{"a: 0.0e-999\n", "a: 4.9e-324\n", "a: [0.0, -0.0e5]\n"} {
+		if _, err := Unmarshal([]byte(source)); err != nil {
+			t.Errorf("%q: %v", source, err)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	var warnings []string
+	opts := DecodeOptions{
+		Filename:       "test.yay",
+		AllowBOM:       true,
+		SaturateFloats: true,
+		Warn:           func(w Warning) { warnings = append(warnings, w.String()) },
+	}
+	got, err := UnmarshalWithOptions([]byte("\uFEFFa: [1, -1.0e999]\nb: 1e-999\nc: 1.5\n"), opts)
+	if want := map[string]any{"a": []any{big.NewInt(1), math.Inf(-1)}, "b": 0.0, "c": 1.5}; err != nil || !Equal(got, want) {
+		t.Errorf("got %#v, %v", got, err)
+	}
+	want := []string{
+		"Ignored BOM at 1:1 of <test.yay>",
+		"Float overflow rounded to -Inf at 1:8 of <test.yay>",
+		"Float underflow rounded to 0 at 2:4 of <test.yay>",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings: got %q, want %q", warnings, want)
+	}
+
+	// Without the leniencies, the same document is an error, and without
+	// a Warn function, they pass silently.
+	if _, err := UnmarshalFile([]byte("\uFEFFa: 1\n"), "test.yay"); err == nil || err.Error() != "Illegal BOM at 1:1 of <test.yay>" {
+		t.Errorf("BOM: got %v", err)
+	}
+	if _, err := UnmarshalWithOptions([]byte("\uFEFFa: 1\n"), DecodeOptions{AllowBOM: true}); err != nil {
+		t.Errorf("BOM without Warn: got %v", err)
+	}
+	if w := (Warning{Message: "Ignored BOM", Line: 1, Column: 1}); w.String() != "Ignored BOM" {
+		t.Errorf("String without a filename: got %q", w)
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	for _, tt := range []struct {
+		literal string
+		want    any
+	}{
+		{"1 048 576", big.NewInt(1048576)},
+		{"-0.5", -0.5},
+		{"6e2", 600.0},
+		{"-infinity", math.Inf(-1)},
+	} {
+		got, err := ParseNumber(tt.literal)
+		if err != nil || !Equal(got, tt.want) {
+			t.Errorf("ParseNumber(%q) = %v, %v; want %v", tt.literal, got, err, tt.want)
+		}
+	}
+	if b, err := ParseBytesLiteral("<ca fe>"); err != nil || !bytes.Equal(b, []byte{0xca, 0xfe}) {
+		t.Errorf("ParseBytesLiteral: got %x, %v", b, err)
+	}
+	if s, err := ParseQuotedString(`"caf\u{e9}\n"`); err != nil || s != "café\n" {
+		t.Errorf("ParseQuotedString: got %q, %v", s, err)
+	}
+	if s, err := ParseQuotedString(`'a\b'`); err != nil || s != `a\b` {
+		t.Errorf("ParseQuotedString: got %q, %v", s, err)
+	}
+
+	for _, tt := range []struct {
+		parse   func(string) (any, error)
+		literal string
+		code    string
+		offset  int
+	}{
+		{ParseNumber, "1.5E3", "uppercase-exponent", 3},
+		{ParseNumber, "1 .5", "space-in-number", 1},
+		{ParseNumber, "one", "invalid-number", 0},
+		{ParseNumber, " 1", "leading-space", 0},
+		{func(s string) (any, error) { return ParseBytesLiteral(s) }, "<caFe>", "uppercase-hex", 3},
+		{func(s string) (any, error) { return ParseQuotedString(s) }, `"a\qb"`, "bad-escape", 3},
+		{func(s string) (any, error) { return ParseQuotedString(s) }, "\"a\tb\"", "tab", 2},
+		{func(s string) (any, error) { return ParseQuotedString(s) }, "plain", "expected-quoted-string", 0},
+	} {
+		_, err := tt.parse(tt.literal)
+		var le *LiteralError
+		if !errors.As(err, &le) || ErrorCode(err) != tt.code || le.Offset != tt.offset {
+			t.Errorf("%q: got %v, code %q; want code %q at %d", tt.literal, err, ErrorCode(err), tt.code, tt.offset)
+		}
+	}
+}
+
+func TestMessageCatalog(t *testing.T) {
+	catalog := DefaultCatalog()
+	catalog["tab"] = "Tabulation interdite"
+	catalog["too-deep"] = "Imbrication trop profonde (limite %d)"
+	catalog["forbidden-code-point"] = "Point de code U+%04[1]X interdit"
+	catalog["ignored-bom"] = "BOM ignoré"
+	opts := DecodeOptions{Filename: "test.yay", Catalog: catalog}
+
+	_, err := UnmarshalWithOptions([]byte("a:\t1\n"), opts)
+	if err == nil || err.Error() != "Tabulation interdite at 1:3 of <test.yay>" || ErrorCode(err) != "tab" {
+		t.Errorf("tab: got %v, code %q", err, ErrorCode(err))
+	}
+	opts.MaxDepth = 1
+	_, err = UnmarshalWithOptions([]byte("a: [1]\n"), opts)
+	if err == nil || err.Error() != "Imbrication trop profonde (limite 1) at 1:4 of <test.yay>" || !errors.Is(err, ErrTooDeep) {
+		t.Errorf("too deep: got %v", err)
+	}
+	_, err = UnmarshalWithOptions([]byte("a: \"\a\"\n"), opts)
+	if err == nil || err.Error() != "Point de code U+0007 interdit at 1:5 of <test.yay>" {
+		t.Errorf("forbidden code point: got %v", err)
+	}
+	var warnings []string
+	opts = DecodeOptions{Catalog: catalog, AllowBOM: true, Warn: func(w Warning) { warnings = append(warnings, w.Message) }}
+	if _, err := UnmarshalWithOptions([]byte("\uFEFF1\n"), opts); err != nil || !reflect.DeepEqual(warnings, []string{"BOM ignoré"}) {
+		t.Errorf("warning: got %q, %v", warnings, err)
+	}
+
+	// Messages keep their English wording, and their codes, without a
+	// catalog or with one missing their codes.
+	_, err = Unmarshal([]byte("a:\t1\n"))
+	if err == nil || err.Error() != "Tab not allowed (use spaces)" || ErrorCode(err) != "tab" {
+		t.Errorf("English: got %v, code %q", err, ErrorCode(err))
+	}
+	_, err = UnmarshalWithOptions([]byte("[1 ]\n"), DecodeOptions{Catalog: Catalog{}})
+	if err == nil || err.Error() != `Unexpected space before "]"` || ErrorCode(err) != "space-before" {
+		t.Errorf("missing code: got %v, code %q", err, ErrorCode(err))
+	}
+	err = NewDecoderWithOptions(strings.NewReader("[1, 2]\n"), DecodeOptions{MaxInputBytes: 4}).Decode(new(any))
+	if ErrorCode(err) != "too-large" || !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Decoder: got %v, code %q", err, ErrorCode(err))
+	}
+	if code := ErrorCode(errors.New("Other")); code != "" {
+		t.Errorf("other error: got code %q", code)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		source   string
+		filename string
+		want     ParseError
+	}{
+		{"a: 1\nb:\t2\n", "", ParseError{Code: "tab", Message: "Tab not allowed (use spaces)", Position: Position{Line: 2, Column: 3, Offset: 7}}},
+		{"a: 1\nb:\t2\n", "app.yay", ParseError{Code: "tab", Message: "Tab not allowed (use spaces)", Position: Position{Filename: "app.yay", Line: 2, Column: 3, Offset: 7}}},
+		{"a: {\"b\": \"x}\n", "app.yay", ParseError{Code: "unterminated-quoted-string", Message: "unterminated string", Position: Position{Filename: "app.yay", Line: 1, Column: 10, Offset: 9}}},
+		{"# nothing\n", "app.yay", ParseError{Code: "no-value", Message: "No value found in document <app.yay>"}},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalWithOptions([]byte(c.source), DecodeOptions{Filename: c.filename})
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: got %v, not a *ParseError", c.source, err)
+			continue
+		}
+		got := *pe
+		got.err = nil
+		if got != c.want {
+			t.Errorf("%q: got %+v, want %+v", c.source, got, c.want)
+		}
+	}
+
+	// The offset counts a byte order mark that is allowed.
+	_, err := UnmarshalWithOptions([]byte("\uFEFF[1 ]\n"), DecodeOptions{AllowBOM: true})
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 1 || pe.Column != 3 || pe.Offset != 5 {
+		t.Errorf("BOM: got %#v", err)
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	source := []byte("a:\t1\nb: 2 \nc:\n  d: [1 ]\n  e: 1\nf: tru\n")
+	opts := DecodeOptions{Filename: "app.yay", MaxErrors: 10}
+	_, err := UnmarshalWithOptions(source, opts)
+	var list ParseErrors
+	if !errors.As(err, &list) {
+		t.Fatalf("got %v, not ParseErrors", err)
+	}
+	var got []string
+	for _, e := range list {
+		got = append(got, fmt.Sprintf("%s %d", e, e.Offset))
+	}
+	want := []string{
+		"Tab not allowed (use spaces) at 1:3 of <app.yay> 2",
+		"Unexpected trailing space at 2:5 of <app.yay> 9",
+		`Unexpected space before "]" at 4:5 of <app.yay> 18`,
+		`Unexpected character "t" at 6:4 of <app.yay> 34`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if want := "Tab not allowed (use spaces) at 1:3 of <app.yay> (and 3 more errors)"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	if ErrorCode(err) != "tab" {
+		t.Errorf("code: got %q", ErrorCode(err))
+	}
+
+	// The cap is kept, and the option changes nothing for a valid document.
+	opts.MaxErrors = 2
+	if _, err := UnmarshalWithOptions(source, opts); !errors.As(err, &list) || len(list) != 2 {
+		t.Errorf("capped: got %v", err)
+	}
+	if v, err := UnmarshalWithOptions([]byte("a: 1\n"), opts); err != nil || !Equal(v, map[string]any{"a": big.NewInt(1)}) {
+		t.Errorf("valid: got %#v, %v", v, err)
+	}
+
+	// Blanking every line leaves no value, which is no error of the document.
+	if _, err := UnmarshalWithOptions([]byte("a:\t1\n"), opts); !errors.As(err, &list) || len(list) != 1 {
+		t.Errorf("one error: got %v", err)
+	}
+}
+
+func TestValidFile(t *testing.T) {
+	if err := ValidFile([]byte("a: [1.5, 2]\n"), "app.yay"); err != nil {
+		t.Errorf("valid: got %v", err)
+	}
+	err := ValidFile([]byte("a: 1\nb:\t2\n"), "app.yay")
+	var pe *ParseError
+	if !errors.As(err, &pe) || err.Error() != "Tab not allowed (use spaces) at 2:3 of <app.yay>" || pe.Offset != 7 {
+		t.Errorf("invalid: got %v", err)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{"a: 1\nb:\t2\n", "Tab not allowed (use spaces) at 2:3 of <app.yay>\nb:\t2\n  ^\n"},
+		{"s: \"é\" \n", "Unexpected trailing space at 1:7 of <app.yay>\ns: \"é\" \n      ^\n"},
+		{"x: [1 ]", "Unexpected space before \"]\" at 1:6 of <app.yay>\nx: [1 ]\n     ^\n"},
+		{"s: \"日本\" \n", "Unexpected trailing space at 1:8 of <app.yay>\ns: \"日本\" \n         ^\n"},
+		{"s: \"e\u0301\" \n", "Unexpected trailing space at 1:8 of <app.yay>\ns: \"e\u0301\" \n      ^\n"},
+		{"# nothing\n", "No value found in document <app.yay>\n"},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFile([]byte(c.source), "app.yay")
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%q: got %v", c.source, err)
+			continue
+		}
+		if got := pe.Render([]byte(c.source)); got != c.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", c.source, got, c.want)
+		}
+	}
+
+	source := []byte("a:\t1\nb: 2 \n")
+	_, err := UnmarshalWithOptions(source, DecodeOptions{Filename: "app.yay", MaxErrors: 5})
+	var list ParseErrors
+	want := "Tab not allowed (use spaces) at 1:3 of <app.yay>\na:\t1\n  ^\n" +
+		"Unexpected trailing space at 2:5 of <app.yay>\nb: 2 \n    ^\n"
+	if !errors.As(err, &list) || list.Render(source) != want {
+		t.Errorf("list: got %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		opts   DecodeOptions
+		source string
+		want   string
+	}{
+		{DecodeOptions{MaxInputBytes: 8}, "a: \"long\"\n", "Document too large (limit 8 bytes)"},
+		{DecodeOptions{MaxLineBytes: 8}, "a: 1\nb: \"long\"\n", "Line exceeds the limit of 8 bytes at 2:1 of <test.yay>"},
+		{DecodeOptions{MaxLineBytes: 8}, "a: 1\nb: \"long\"", "Line exceeds the limit of 8 bytes at 2:1 of <test.yay>"},
+		{DecodeOptions{MaxLineBytes: 8}, "a: 1\t\t\t\t\t\n", "Line exceeds the limit of 8 bytes at 1:1 of <test.yay>"},
+		{DecodeOptions{MaxDepth: 2}, "a:\n  b:\n    c: 1\n", "Nesting too deep (limit 2) at 3:5 of <test.yay>"},
+		{DecodeOptions{MaxDepth: 2}, "a: [[1]]\n", "Nesting too deep (limit 2) at 1:5 of <test.yay>"},
+		{DecodeOptions{MaxDepth: 2}, "- - - 1\n", "Nesting too deep (limit 2) at 1:3 of <test.yay>"},
+		{DecodeOptions{MaxDepth: 2}, "- a:\n    b: 1\n", "Nesting too deep (limit 2) at 2:5 of <test.yay>"},
+		{DecodeOptions{MaxInlineDepth: 2}, "a: [1, [2, [3]]]\n", "Nesting too deep in inline value (limit 2) at 1:12 of <test.yay>"},
+		{DecodeOptions{MaxInlineDepth: 2}, "x: {a: [{}]}\n", "Nesting too deep in inline value (limit 2) at 1:9 of <test.yay>"},
+		{DecodeOptions{MaxBlockBytes: 4}, "a: >\n  0102 0304\nb: >\n  05\n", "Block values exceed the limit of 4 bytes at 3:1 of <test.yay>"},
+		{DecodeOptions{MaxBlockBytes: 4}, "a: `\n  ab\nb: `\n  cd\n", "Block values exceed the limit of 4 bytes at 3:4 of <test.yay>"},
+		{DecodeOptions{MaxBlockBytes: 4}, "- > 0102\n- `\n  abc\n", "Block values exceed the limit of 4 bytes at 2:1 of <test.yay>"},
+		{DecodeOptions{MaxItems: 2}, "- 1\n- 2\n- 3\n", "Collection exceeds the limit of 2 items at 3:1 of <test.yay>"},
+		{DecodeOptions{MaxItems: 2}, "[1, 2, 3]\n", "Collection exceeds the limit of 2 items at 1:8 of <test.yay>"},
+		{DecodeOptions{MaxItems: 2}, "a: 1\nb: 2\nc: 3\n", "Collection exceeds the limit of 2 items at 3:1 of <test.yay>"},
+		{DecodeOptions{MaxItems: 2}, "x: {a: 1, b: 2, c: 3}\n", "Collection exceeds the limit of 2 items at 1:17 of <test.yay>"},
+		{DecodeOptions{MaxItems: 2}, "- a: 1\n  b: 2\n  c: 3\n", "Collection exceeds the limit of 2 items at 1:1 of <test.yay>"},
+		{DecodeOptions{MaxKeyBytes: 3}, "long: 1\n", "Key exceeds the limit of 3 bytes at 1:1 of <test.yay>"},
+		{DecodeOptions{MaxKeyBytes: 3}, "x: {\"long\": 1}\n", "Key exceeds the limit of 3 bytes at 1:5 of <test.yay>"},
+		{DecodeOptions{MaxStringBytes: 3}, "\"long\"\n", "String exceeds the limit of 3 bytes at 1:1 of <test.yay>"},
+		{DecodeOptions{MaxStringBytes: 3}, "a: ['long']\n", "String exceeds the limit of 3 bytes at 1:5 of <test.yay>"},
+		{DecodeOptions{MaxStringBytes: 3}, "a: `\n  long\n", "String exceeds the limit of 3 bytes at 1:4 of <test.yay>"},
+		{DecodeOptions{MaxStringBytes: 3}, "a:\n  \"lo\"\n  \"ng\"\n", "String exceeds the limit of 3 bytes at 2:3 of <test.yay>"},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFileWithOptions([]byte(c.source), "test.yay", c.opts)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+	}
+
+	// Every fixture is within the safe limits, and decodes as it would
+	// without them.
+	for name, want := range fixtures {
+		input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := UnmarshalWithOptions(input, SafeOptions())
+		if err != nil || !Equal(got, want) {
+			t.Errorf("%s: got %#v, %v", name, got, err)
+		}
+	}
+}
+
+// endlessReader reads an endless stream of spaces, counting the bytes read.
+type endlessReader struct{ n int }
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	r.n += len(p)
+	return len(p), nil
+}
+
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("a: [1, 2]\n"))
+	var v any
+	if err := dec.Decode(&v); err != nil || !Equal(v, map[string]any{"a": []any{big.NewInt(1), big.NewInt(2)}}) {
+		t.Errorf("got %#v, %v", v, err)
+	}
+	if err := dec.Decode(&v); err != io.EOF {
+		t.Errorf("second Decode: got %v, want io.EOF", err)
+	}
+	if err := NewDecoder(strings.NewReader("1\n")).Decode(v); err == nil {
+		t.Error("Decode into a non-pointer: no error")
+	}
+
+	// A stream delivered a byte at a time decodes as it would at once.
+	source := "name: `\n  multi\n  line\nlist:\n- 1\n- \"two\"\n"
+	want, _ := Unmarshal([]byte(source))
+	if err := NewDecoder(iotest.OneByteReader(strings.NewReader(source))).Decode(&v); err != nil || !Equal(v, want) {
+		t.Errorf("byte at a time: got %#v, %v", v, err)
+	}
+
+	// The limit stops reading, rather than checking after the fact.
+	r := &endlessReader{}
+	err := NewDecoderWithOptions(r, DecodeOptions{MaxInputBytes: 1 << 10}).Decode(&v)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("endless stream: got %v, want ErrTooLarge", err)
+	}
+	if r.n > 64<<10 {
+		t.Errorf("endless stream: read %d bytes", r.n)
+	}
+
+	// A document of exactly the limit is accepted.
+	err = NewDecoderWithOptions(strings.NewReader("true\n"), DecodeOptions{MaxInputBytes: 5}).Decode(&v)
+	if err != nil || v != true {
+		t.Errorf("document at limit: got %#v, %v", v, err)
+	}
+}
+
+func TestFilenameOption(t *testing.T) {
+	source := []byte("a: 1\nb: 1.5e999\n")
+	opts := DecodeOptions{Filename: "opts.yay", InternKeys: true, Batch: true}
+	const want = "Float overflow at 2:4 of <opts.yay>"
+
+	_, err := UnmarshalWithOptions(source, opts)
+	if err == nil || err.Error() != want {
+		t.Errorf("UnmarshalWithOptions: got %v, want %q", err, want)
+	}
+	var v any
+	err = NewDecoderWithOptions(strings.NewReader(string(source)), opts).Decode(&v)
+	if err == nil || err.Error() != want {
+		t.Errorf("Decoder: got %v, want %q", err, want)
+	}
+	_, err = UnmarshalFileWithOptions(source, "arg.yay", opts)
+	if want := "Float overflow at 2:4 of <arg.yay>"; err == nil || err.Error() != want {
+		t.Errorf("UnmarshalFileWithOptions: got %v, want %q", err, want)
+	}
+
+	opts.SaturateFloats = true
+	if v, err := UnmarshalWithOptions(source, opts); err != nil || !math.IsInf(v.(map[string]any)["b"].(float64), 1) {
+		t.Errorf("SaturateFloats: got %#v, %v", v, err)
+	}
+}
+
+func TestVersionDirective(t *testing.T) {
+	cases := []struct {
+		source  string
+		version int
+		want    string // Error, if any
+	}{
+		{"1\n", 0, ""},
+		{"#!yay 1\na: 1\n", 1, ""},
+		{"#!yay 1\n", 1, "No value found in document <test.yay>"},
+		{"#!/usr/bin/env yay\n1\n", 0, ""},
+		{"# !yay 2\n1\n", 0, ""},
+		{"1\n#!yay 2\n", 0, ""},
+		{"#!yay 2\na: 1\n", 2, "Unsupported YAY version 2 (up to 1 is supported) at 1:7 of <test.yay>"},
+		{"#!yay 10\n", 10, "Unsupported YAY version 10 (up to 1 is supported) at 1:7 of <test.yay>"},
+		{"#!yay 99999999999999999999\n", math.MaxInt, "Unsupported YAY version 99999999999999999999 (up to 1 is supported) at 1:7 of <test.yay>"},
+		// First lines that are not exactly a directive are comments, as
+		// they are to implementations that do not read directives.
+		{"#!yay\n1\n", 0, ""},
+		{"#!yay one\n1\n", 0, ""},
+		{"#!yay x\n1\n", 0, ""},
+		{"#!yay 01\n1\n", 0, ""},
+		{"#!yay -1\n1\n", 0, ""},
+		{"#!yay  1\n1\n", 0, ""},
+		{"#!yay 2 # c\n1\n", 0, ""},
+		{"#!yay2\n1\n", 0, ""},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFile([]byte(c.source), "test.yay")
+		if got := fmt.Sprint(err); c.want == "" && err != nil || c.want != "" && got != c.want {
+			t.Errorf("%q: got %v, want %q", c.source, err, c.want)
+		}
+		v, err := Version([]byte(c.source))
+		wantErr := strings.HasPrefix(c.want, "Unsupported")
+		if v != c.version || (err != nil) != wantErr {
+			t.Errorf("Version(%q): got %d, %v, want %d", c.source, v, err, c.version)
+		}
+	}
+
+	if _, err := Unmarshal([]byte("#!yay 2\n1\n")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("got %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	// Many small values, each cheap in the source, are charged for what
+	// they occupy once decoded.
+	small := []byte("[" + strings.Repeat("1, ", 9999) + "1]\n")
+	_, err := UnmarshalWithOptions(small, DecodeOptions{MemoryBudget: 64 << 10})
+	if !errors.Is(err, ErrMemoryBudget) {
+		t.Errorf("small values: got %v, want ErrMemoryBudget", err)
+	}
+	if _, err := UnmarshalWithOptions(small, DecodeOptions{MemoryBudget: 1 << 20}); err != nil {
+		t.Errorf("small values within budget: %v", err)
+	}
+
+	// The error is reported at the value that exceeds the budget.
+	source := []byte("a: 1\nb: \"" + strings.Repeat("x", 100) + "\"\n")
+	_, err = UnmarshalFileWithOptions(source, "test.yay", DecodeOptions{MemoryBudget: 300})
+	if want := "Memory budget exceeded (limit 300 bytes) at 2:4 of <test.yay>"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+
+	for _, source := range []string{
+		"a: <" + strings.Repeat("00", 1000) + ">\n",
+		"a: >\n  " + strings.Repeat("00", 1000) + "\n",
+		"a: `\n  " + strings.Repeat("x", 1000) + "\n",
+		"a: [" + strings.Repeat("1.5, ", 100) + "1.5]\n",
+		"a: {" + strings.Repeat("b: {", 30) + "}" + strings.Repeat("}", 30) + "\n",
+	} {
+		_, err := UnmarshalWithOptions([]byte(source), DecodeOptions{MemoryBudget: 1000})
+		if !errors.Is(err, ErrMemoryBudget) {
+			t.Errorf("%.20q...: got %v, want ErrMemoryBudget", source, err)
+		}
+	}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	for name := range fixtures {
+		input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyRoundTrip(input); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if err := VerifyRoundTrip([]byte("a: [1,  2]\n")); err == nil {
+		t.Error("expected parse error")
+	}
+	// A key with both kinds of quote can be read but not written.
+	err := VerifyRoundTrip([]byte("a:\n  - \"x\\\"'\": 1\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "Cannot re-encode document: ") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	for name, expected := range fixtures {
+		input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := StripComments(input)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got, err := Unmarshal(out); err != nil || !Equal(got, expected) {
+			t.Errorf("%s: %q reads as %#v, %v", name, out, got, err)
+		}
+	}
+
+	for _, tt := range []struct{ source, want string }{
+		{"#!yay 1\n# About.\na: 1  # One.\nb: 'x # y' # Quoted.\n\nc: \"it's\" # c\n", "#!yay 1\na: 1\nb: 'x # y'\n\nc: \"it's\"\n"},
+		{"a: ` # Text follows.\n  x # y\n# Margin.\n  z\n", "a: `\n  x # y\n  z\n"},
+		{"` # content\n  # content\n", "` # content\n  # content\n"},
+		{"a: > # Bytes.\n  ca fe # Hex.\n  # More.\n  be\n", "a: >\n  ca fe\n  be\n"},
+		{"- > # Bytes.\n  # More.\n  ca fe\n- > # Empty.\n", "- > ca fe\n- <>\n"},
+		{"a:\n  - 1\n  # c\n  - 2\n", "a:\n  - 1\n  # c\n  - 2\n"},
+	} {
+		out, err := StripComments([]byte(tt.source))
+		if err != nil || string(out) != tt.want {
+			t.Errorf("%q:\ngot:  %q, %v\nwant: %q", tt.source, out, err, tt.want)
+		}
+	}
+
+	for _, source := range []string{"a:\t1\n", "outer:\n # inner: {}\n"} {
+		if _, err := StripComments([]byte(source)); err == nil {
+			t.Errorf("%q: StripComments accepted an invalid document", source)
+		}
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	for name, expected := range fixtures {
+		input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := SortKeys(input, SortOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got, err := Unmarshal(out); err != nil || !Equal(got, expected) {
+			t.Errorf("%s: %q reads as %#v, %v", name, out, got, err)
+		}
+	}
+
+	source := "#!yay 1\n# Header.\n\n# About c.\nc: 1  # One.\n\n# About b.\nb:\n  z: `\n    text\n    # text\n\n  y: {z: 1, y: 2}\n  x:\n  - w: 1\n    v: 2\n# About a.\na: 3\n"
+	for _, tt := range []struct {
+		opts SortOptions
+		want string
+	}{
+		{SortOptions{}, "#!yay 1\n# Header.\n\n# About a.\na: 3\n\n# About b.\nb:\n  x:\n  - v: 2\n    w: 1\n\n  y: {z: 1, y: 2}\n  z: `\n    text\n    # text\n# About c.\nc: 1  # One.\n"},
+		{SortOptions{MaxDepth: 1}, "#!yay 1\n# Header.\n\n# About a.\na: 3\n\n# About b.\nb:\n  z: `\n    text\n    # text\n\n  y: {z: 1, y: 2}\n  x:\n  - w: 1\n    v: 2\n# About c.\nc: 1  # One.\n"},
+	} {
+		out, err := SortKeys([]byte(source), tt.opts)
+		if err != nil || string(out) != tt.want {
+			t.Errorf("%+v:\ngot:  %q, %v\nwant: %q", tt.opts, out, err, tt.want)
+		}
+	}
+
+	out, err := SortKeys([]byte("- b: 1\n  a: 2\n- ` text\n"), SortOptions{})
+	if want := "- a: 2\n  b: 1\n- ` text\n"; err != nil || string(out) != want {
+		t.Errorf("items: got %q, %v; want %q", out, err, want)
+	}
+	if _, err := SortKeys([]byte("a:\t1\n"), SortOptions{}); err == nil {
+		t.Error("SortKeys accepted an invalid document")
+	}
+
+	for _, tt := range []struct{ source, want string }{
+		{" - - {a: 42, b: \"hello\"}\n", " - - {a: 42, b: \"hello\"}\n"},
+		{"  - b: 1\n    a: 2\n", "  - a: 2\n    b: 1\n"},
+		{"data: > # raw bytes\n'  b0b5 c0ff\n", "data: > # raw bytes\n'  b0b5 c0ff\n"},
+		{"b: > # raw bytes\n  b0b5 c0ff\na: 1\n", "a: 1\nb: > # raw bytes\n  b0b5 c0ff\n"},
+	} {
+		out, err := SortKeys([]byte(tt.source), SortOptions{})
+		if err != nil || string(out) != tt.want {
+			t.Errorf("SortKeys(%q) = %q, %v; want %q", tt.source, out, err, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for name, expected := range fixtures {
+		input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Format(input)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got, err := Unmarshal(out); err != nil || !Equal(got, expected) {
+			t.Errorf("%s: %q reads as %#v, %v", name, out, got, err)
+		}
+		if again, err := Format(out); err != nil || !bytes.Equal(again, out) {
+			t.Errorf("%s: formatting %q again gives %q, %v", name, out, again, err)
+		}
+	}
+
+	source := "#!yay 1\n# Header.\n\n\n# About a.\na: 1  # One.\nb:\n    c: 'x'\n\n# About d.\n    d: [1, 'y']\n    e:\n        - 6.283 185\n        - <f33d face>\n        - `\n            text\n              more\nf:\n- x: 1\n  y: {\"z\": 2}\n- - 1\n  - 2\n\n"
+	want := "#!yay 1\n# Header.\n\n# About a.\na: 1  # One.\nb:\n  c: \"x\"\n\n# About d.\n  d: [1, \"y\"]\n  e:\n    - 6.283 185\n    - <f33d face>\n    - `\n        text\n          more\nf:\n  - x: 1\n    y: {z: 2}\n  - - 1\n    - 2\n"
+	if out, err := Format([]byte(source)); err != nil || string(out) != want {
+		t.Errorf("got:  %q, %v\nwant: %q", out, err, want)
+	}
+	if _, err := Format([]byte("a:\t1\n")); err == nil {
+		t.Error("Format accepted an invalid document")
+	}
+
+	for _, test := range []struct{ source, want string }{
+		{"  - 42\n  - \"hello\"\n", "- 42\n- \"hello\"\n"},
+		{" - - {a: 42, b: 'hello'}\n", "- - {a: 42, b: \"hello\"}\n"},
+		{"a:\n  b:", "a:\n  b:\n"},
+		{"a: 1\nb:\n  c: 2\na: 3\n", ""},
+	} {
+		out, err := Format([]byte(test.source))
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("Format(%q) = %q, want an error", test.source, out)
+			}
+		} else if err != nil || string(out) != test.want {
+			t.Errorf("Format(%q) = %q, %v, want %q", test.source, out, err, test.want)
+		}
+	}
+}
+
+func TestParseAST(t *testing.T) {
+	source := "a: 1 # one\nb:\n  - \"x\"\n  - {c: [true, null], \"d e\": <0a>}\nt: `\n  text\n\n  more\nn:\n- 1.5\n"
+	root, err := ParseAST([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	var walk func(Node)
+	walk = func(n Node) {
+		s := n.Extent()
+		got = append(got, fmt.Sprintf("%T %s %q", n, s.Start, source[s.Start.Offset:s.End.Offset]))
+		switch x := n.(type) {
+		case *ObjectNode:
+			for _, p := range x.Properties {
+				walk(p)
+			}
+		case *PropertyNode:
+			walk(x.Value)
+		case *ArrayNode:
+			for _, item := range x.Items {
+				walk(item)
+			}
+		}
+	}
+	walk(root)
+	want := []string{
+		`*yay.ObjectNode 1:1 "a: 1 # one\nb:\n  - \"x\"\n  - {c: [true, null], \"d e\": <0a>}\nt: ` + "`" + `\n  text\n\n  more\nn:\n- 1.5"`,
+		`*yay.PropertyNode 1:1 "a: 1"`,
+		`*yay.IntegerNode 1:4 "1"`,
+		`*yay.PropertyNode 2:1 "b:\n  - \"x\"\n  - {c: [true, null], \"d e\": <0a>}"`,
+		`*yay.ArrayNode 3:3 "- \"x\"\n  - {c: [true, null], \"d e\": <0a>}"`,
+		`*yay.StringNode 3:5 "\"x\""`,
+		`*yay.ObjectNode 4:5 "{c: [true, null], \"d e\": <0a>}"`,
+		`*yay.PropertyNode 4:6 "c: [true, null]"`,
+		`*yay.ArrayNode 4:9 "[true, null]"`,
+		`*yay.BoolNode 4:10 "true"`,
+		`*yay.NullNode 4:16 "null"`,
+		`*yay.PropertyNode 4:23 "\"d e\": <0a>"`,
+		`*yay.BytesNode 4:30 "<0a>"`,
+		`*yay.PropertyNode 5:1 "t: ` + "`" + `\n  text\n\n  more"`,
+		`*yay.StringNode 5:4 "` + "`" + `\n  text\n\n  more"`,
+		`*yay.PropertyNode 9:1 "n:\n- 1.5"`,
+		`*yay.ArrayNode 10:1 "- 1.5"`,
+		`*yay.FloatNode 10:3 "1.5"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	p := root.(*ObjectNode).Properties[2]
+	if v := p.Value.(*StringNode).Value; p.Key != "t" || v != "text\n\nmore\n" {
+		t.Errorf("block string: got %q: %q", p.Key, v)
+	}
+	if k := root.(*ObjectNode).Properties[1].Value.(*ArrayNode).Items[1].(*ObjectNode).Properties[1].KeySpan; source[k.Start.Offset:k.End.Offset] != `"d e"` {
+		t.Errorf("key span: got %v", k)
+	}
+
+	// Positions follow the options, and count the BOM in their offsets.
+	root, err = ParseASTWithOptions([]byte("\uFEFF\"é\": \"ü\"\n"), DecodeOptions{AllowBOM: true, Filename: "f.yay", Columns: ColumnBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := root.(*ObjectNode).Properties[0].Value.Extent(); s.Start.String() != "1:7 of <f.yay>" || s.Start.Offset != 9 || s.End.Offset != 13 {
+		t.Errorf("with options: got %v to %v", s.Start, s.End)
+	}
+
+	if _, err := ParseAST([]byte("a: [1 ]\n")); err == nil {
+		t.Error("invalid document: no error")
+	}
+
+	// The text of each scalar on one line is the value it holds.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		data, err := Marshal(RandomValue(r, 4))
+		if err != nil {
+			continue
+		}
+		root, err := ParseAST(data)
+		if err != nil {
+			t.Fatalf("%q: %v", data, err)
+		}
+		var check func(Node)
+		check = func(n Node) {
+			s := n.Extent()
+			text := data[s.Start.Offset:s.End.Offset]
+			var value any
+			switch x := n.(type) {
+			case *ObjectNode:
+				for _, p := range x.Properties {
+					check(p.Value)
+				}
+				return
+			case *ArrayNode:
+				for _, item := range x.Items {
+					check(item)
+				}
+				return
+			case *StringNode:
+				value = x.Value
+			case *BytesNode:
+				value = x.Value
+			case *IntegerNode:
+				value = x.Value
+			case *FloatNode:
+				value = x.Value
+			case *BoolNode:
+				value = x.Value
+			}
+			if v, err := Unmarshal(append(text, '\n')); !bytes.Contains(text, []byte("\n")) && (err != nil || !Equal(v, value)) {
+				t.Errorf("%q: %T %q holds %#v", data, n, text, value)
+			}
+		}
+		check(root)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	source := "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\", \"b\"]\n"
+	for _, tt := range []struct {
+		path  string
+		value any // Or deleted if nil
+		want  string
+	}{
+		{".server.port", 8080, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 8080\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".server.tls.cert", "c.pem", "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\n  tls: {cert: \"c.pem\"}\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".server", []any{1, 2, 3, 4, 5, 6}, "# Config.\nserver:\n  - 1\n  - 2\n  - 3\n  - 4\n  - 5\n  - 6\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".list[1].k[0].z", true, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n    z: true\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".list[2]", "c", "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\n- \"c\"\ntags: [\"a\", \"b\"]\n"},
+		{".tags[2]", "c", "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\", \"b\", \"c\"]\n"},
+		{".tags[0]", map[string]any{"a": []any{}, "b": 1, "c": 2, "d": 3}, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags:\n  - a: []\n    b: 1\n    c: 2\n    d: 3\n  - \"b\"\n"},
+		{".server.host", nil, "# Config.\nserver:\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".list[1].k[0].x", nil, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - y: 2\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".list[1].k[0]", nil, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - 3\ntags: [\"a\", \"b\"]\n"},
+		{".tags[1]", nil, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"a\"]\n"},
+		{"/tags/0", nil, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\ntags: [\"b\"]\n"},
+		{".tags", nil, "# Config.\nserver:\n  host: \"a\"  # Where.\n  port: 80\nlist:\n- 1\n- k:\n  - x: 1\n    y: 2\n  - 3\n"},
+	} {
+		doc, err := ParseDocument([]byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.value != nil {
+			err = doc.Set(tt.path, tt.value)
+		} else {
+			err = doc.Delete(tt.path)
+		}
+		if err != nil || string(doc.Bytes()) != tt.want {
+			t.Errorf("%s:\ngot:  %q, %v\nwant: %q", tt.path, doc.Bytes(), err, tt.want)
+		}
+	}
+
+	// Emptied collections are written inline.
+	doc, err := ParseDocument([]byte("a:\n  b: 1\nc: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Delete("a.b"); err != nil || string(doc.Bytes()) != "a: {}\nc: 2\n" {
+		t.Errorf("emptied: got %q, %v", doc.Bytes(), err)
+	}
+	if v, err := doc.Get("a"); err != nil || !Equal(v, map[string]any{}) {
+		t.Errorf("Get: got %#v, %v", v, err)
+	}
+
+	for _, path := range []string{".c.d", ".x[1]"} {
+		if err := doc.Set(path, 1); !errors.Is(err, ErrPathType) && !errors.Is(err, ErrNotFound) {
+			t.Errorf("Set %s: got %v", path, err)
+		}
+	}
+	if err := doc.Delete(".missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete: got %v", err)
+	}
+	if string(doc.Bytes()) != "a: {}\nc: 2\n" {
+		t.Errorf("failed edits changed the document: %q", doc.Bytes())
+	}
+	if _, err := ParseDocument([]byte("a:\t1\n")); err == nil {
+		t.Error("ParseDocument accepted an invalid document")
+	}
+
+	// Documents whose syntax trees were once out of reach.
+	for _, tt := range []struct {
+		source, path, want string
+	}{
+		{"  - 42\n  - \"hello\"\n", "[1]", "  - 42\n  - \"edited\"\n"},
+		{" - - [42, 42]\n", "[0][0][1]", " - - [42, \"edited\"]\n"},
+		{" - - {a: 42, b: \"hello\"}\n", "[0][0].b", " - - {a: 42, b: \"edited\"}\n"},
+		{"a: 1\n# c\na: 2\n", "a", "a: 1\n# c\na: \"edited\"\n"},
+	} {
+		doc, err := ParseDocument([]byte(tt.source))
+		if err != nil {
+			t.Errorf("%q: %v", tt.source, err)
+			continue
+		}
+		if err := doc.Set(tt.path, "edited"); err != nil || string(doc.Bytes()) != tt.want {
+			t.Errorf("%q, %s:\ngot:  %q, %v\nwant: %q", tt.source, tt.path, doc.Bytes(), err, tt.want)
+		}
+	}
+}
+
+func TestFirstDifference(t *testing.T) {
+	a := map[string]any{
+		"list":    []any{big.NewInt(1), map[string]any{"x y": "old"}},
+		"short":   []any{true},
+		"missing": nil,
+	}
+	cases := []struct {
+		b    any
+		want string
+	}{
+		{map[string]any{
+			"list":    []any{big.NewInt(1), map[string]any{"x y": "new"}},
+			"short":   []any{true},
+			"missing": nil,
+		}, `.list[1]["x y"] from "old" to "new"`},
+		{map[string]any{
+			"list":  a["list"],
+			"short": []any{true, 1.5},
+		}, `.missing from null to nothing`},
+		{map[string]any{
+			"list":    a["list"],
+			"short":   []any{true, 1.5},
+			"missing": nil,
+		}, `.short[1] from nothing to 1.5`},
+		{[]any{}, `. from an object of 3 properties to an array of 0 items`},
+	}
+	for _, c := range cases {
+		d, ok := firstDifference(a, c.b, "")
+		got := displayPath(d.path) + " from " + describe(d.a, d.aok) + " to " + describe(d.b, d.bok)
+		if !ok || got != c.want {
+			t.Errorf("got %v %s, want %s", ok, got, c.want)
+		}
+	}
+	if _, ok := firstDifference(a, a, ""); ok {
+		t.Error("a value should not differ from itself")
+	}
+}
+
+func TestColumnUnits(t *testing.T) {
+	// é is two bytes of UTF-8 and one UTF-16 unit; 😀 is four bytes and
+	// two UTF-16 units.
+	source := []byte("a: 1\nk: [\"é😀\",  2]\n")
+	cases := []struct {
+		unit ColumnUnit
+		want string
+	}{
+		{ColumnCodePoints, "at 2:11 of"},
+		{ColumnBytes, "at 2:15 of"},
+		{ColumnUTF16, "at 2:12 of"},
+	}
+	for _, c := range cases {
+		_, err := UnmarshalFileWithOptions(source, "test.yay", DecodeOptions{Columns: c.unit})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("unit %d: got %v, want %q", c.unit, err, c.want)
+		}
+	}
+}
+
+func TestBlockBytesDecoding(t *testing.T) {
+	got, err := UnmarshalFile([]byte("data: >\n  ca fe # comment\n  b\n  AbE\n"), "test.yay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"data": []byte{0xca, 0xfe, 0xba, 0xbe}}
+	if !Equal(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	_, err = UnmarshalFile([]byte("> ca\n  fg\n"), "test.yay")
+	if want := "Invalid hex digit at 2:4 of <test.yay>"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for name, expected := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			out, err := Marshal(expected)
+			if err != nil {
+				t.Fatalf("Marshal error: %v", err)
+			}
+			got, err := Unmarshal(out)
+			if err != nil {
+				t.Fatalf("Unmarshal error: %v\n%s", err, out)
+			}
+			if !Equal(got, expected) {
+				t.Errorf("mismatch\ngot:  %#v\nwant: %#v\n%s", got, expected, out)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	n := big.NewInt
+	cases := []struct {
+		value any
+		want  string
+	}{
+		{[]any{1.0, 1e21, 1e-7, math.Copysign(0, -1), math.Inf(-1)}, "[1.0, 1.0e+21, 1.0e-7, -0.0, -infinity]\n"},
+		{"tab\there \"quoted\" \x7f", "\"tab\\there \\\"quoted\\\" \\u{7f}\"\n"},
+		{map[string]any{"b": []byte{0xca, 0xfe}, "a key": n(-3)}, "\"a key\": -3\nb: <cafe>\n"},
+		{[]any{[]any{[]any{n(1), n(2), n(3), n(4), n(5), n(6)}}}, "- - [1, 2, 3, 4, 5, 6]\n"},
+		{[]any{[]any{map[string]any{"a": n(1), "b": n(2), "c": n(3), "d": n(4)}, n(5)}}, "- - {a: 1, b: 2, c: 3, d: 4}\n  - 5\n"},
+		{[]any{[]any{"\x01", []any{n(1), map[string]any{"a b": []any{}}}}, n(2)}, "- - \"\\u{1}\"\n  - [1, {\"a b\": []}]\n- 2\n"},
+		{map[string]any{"x": map[string]any{"y": []any{}}, "z": nil}, "x:\n  y: []\nz: null\n"},
+	}
+	for _, c := range cases {
+		out, err := Marshal(c.value)
+		if err != nil {
+			t.Errorf("%#v: %v", c.value, err)
+			continue
+		}
+		if string(out) != c.want {
+			t.Errorf("%#v:\ngot:  %q\nwant: %q", c.value, out, c.want)
+		}
+		got, err := Unmarshal(out)
+		if err != nil {
+			t.Errorf("%q: %v", out, err)
+		} else if !Equal(got, c.value) {
+			t.Errorf("%q: got %#v", out, got)
+		}
+	}
+	// Strings within an array in an array are written inline, where only
+	// the JSON escapes may appear.
+	if _, err := Marshal([]any{[]any{[]any{"\x01"}}}); err == nil || err.Error() != `Cannot encode "\x01" in an inline collection` {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestMarshalBlockStrings(t *testing.T) {
+	doc := map[string]any{
+		"poem":  "Roses are red,\n  violets are blue.\n\nThe end.\n",
+		"items": []any{"one\ntwo\n", "x", "one\ntwo", big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+		"plain": "no newline",
+	}
+	out, err := MarshalWithOptions(doc, EncodeOptions{BlockStrings: BlockStringsExact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "items:\n  - ` one\n      two\n  - \"x\"\n  - \"one\\ntwo\"\n  - 1\n  - 2\n  - 3\n" +
+		"plain: \"no newline\"\npoem: `\n  Roses are red,\n    violets are blue.\n\n  The end.\n"
+	if string(out) != want {
+		t.Errorf("exact:\ngot:  %q\nwant: %q", out, want)
+	}
+	if got, err := Unmarshal(out); err != nil || !Equal(got, doc) {
+		t.Errorf("exact: got %#v, %v", got, err)
+	}
+
+	// A block string keeps a small collection from being written inline.
+	out, err = MarshalWithOptions(map[string]any{"motd": "Welcome.\nBe kind.\n"}, EncodeOptions{BlockStrings: BlockStringsExact})
+	if want := "motd: `\n  Welcome.\n  Be kind.\n"; err != nil || string(out) != want {
+		t.Errorf("small object: got %q, %v; want %q", out, err, want)
+	}
+
+	out, err = MarshalWithOptions(doc["items"], EncodeOptions{BlockStrings: BlockStringsAddNewline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- ` one\n    two\n- \"x\"\n- ` one\n    two\n- 1\n- 2\n- 3\n"; string(out) != want {
+		t.Errorf("add newline:\ngot:  %q\nwant: %q", out, want)
+	}
+
+	// Strings a block string would not reproduce are quoted, in every
+	// place a block string may be written.
+	for _, s := range []string{
+		"a\n", "  indented\nfirst\n", "# not a comment\n", "a\n\n", "\na\n", "a \nb\n",
+		"a\n- b\n", "- a\n", "a\n  -b\n", "tab\there\n", "cr\r\n", "a\n`b\n", "\u00e9t\u00e9\n",
+		"key: value\nb\n", "a\n\nb\n", "\u2028\n", "a\u00a0\nb\n",
+	} {
+		items := []any{s, big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5)}
+		for _, v := range []any{s, map[string]any{"k": s}, items, map[string]any{"k": items}} {
+			out, err := MarshalWithOptions(v, EncodeOptions{BlockStrings: BlockStringsExact})
+			if err != nil {
+				t.Errorf("%q: %v", s, err)
+				continue
+			}
+			if got, err := Unmarshal(out); err != nil || !Equal(got, v) {
+				t.Errorf("%q:\n%s\ngot %#v, %v", s, out, got, err)
+			}
+		}
+	}
+}
+
+func TestMarshalInlineLimits(t *testing.T) {
+	doc := map[string]any{
+		"pair": []any{big.NewInt(1), big.NewInt(2)},
+		"ten":  []any{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5), big.NewInt(6), big.NewInt(7), big.NewInt(8), big.NewInt(9), big.NewInt(10)},
+		"size": map[string]any{"w": big.NewInt(3), "h": big.NewInt(4)},
+	}
+	for _, tt := range []struct {
+		opts EncodeOptions
+		want string
+	}{
+		{EncodeOptions{}, "pair: [1, 2]\nsize: {h: 4, w: 3}\nten:\n  - 1\n  - 2\n  - 3\n  - 4\n  - 5\n  - 6\n  - 7\n  - 8\n  - 9\n  - 10\n"},
+		{EncodeOptions{InlineArrayMax: 10, InlineObjectMax: 1}, "pair: [1, 2]\nsize:\n  h: 4\n  w: 3\nten: [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]\n"},
+		{EncodeOptions{InlineArrayMax: -1, InlineObjectMax: -1}, "pair:\n  - 1\n  - 2\nsize:\n  h: 4\n  w: 3\nten:\n  - 1\n  - 2\n  - 3\n  - 4\n  - 5\n  - 6\n  - 7\n  - 8\n  - 9\n  - 10\n"},
+	} {
+		out, err := MarshalWithOptions(doc, tt.opts)
+		if err != nil || string(out) != tt.want {
+			t.Errorf("%+v:\ngot:  %q, %v\nwant: %q", tt.opts, out, err, tt.want)
+			continue
+		}
+		if got, err := Unmarshal(out); err != nil || !Equal(got, doc) {
+			t.Errorf("%+v: got %#v, %v", tt.opts, got, err)
+		}
+	}
+	if out, err := MarshalWithOptions(map[string]any{"none": []any{}}, EncodeOptions{InlineArrayMax: -1, InlineObjectMax: -1}); err != nil || string(out) != "none: []\n" {
+		t.Errorf("empty: got %q, %v", out, err)
+	}
+}
+
+func TestMarshalSizeEstimate(t *testing.T) {
+	var items []any
+	for i := 0; i < 100; i++ {
+		items = append(items, []any{"a string", big.NewInt(int64(i)), 2.5, true, nil, []byte("bytes")})
+	}
+	var doc any = items
+	out, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := estimateSize(doc, 0); n < len(out) {
+		t.Errorf("estimate %d short of output %d", n, len(out))
+	}
+	// One allocation for the output buffer, none for regrowth.
+	if allocs := testing.AllocsPerRun(10, func() { Marshal(doc) }); allocs > 1 {
+		t.Errorf("got %v allocations, want 1", allocs)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		a, b any
+		want bool
+	}{
+		{big.NewInt(7), big.NewInt(7), true},
+		{big.NewInt(7), big.NewInt(8), false},
+		{math.NaN(), math.NaN(), true},
+		{0.0, math.Copysign(0, -1), true},
+		{[]byte{}, []byte(nil), true},
+		{[]any{"a", nil}, []any{"a", nil}, true},
+		{[]any{"a"}, []any{"a", nil}, false},
+		{map[string]any{"k": big.NewInt(1)}, map[string]any{"k": big.NewInt(1)}, true},
+		{map[string]any{"k": nil}, map[string]any{"j": nil}, false},
+		{big.NewInt(1), 1.0, false},
+		{"1", 1.0, false},
+	}
+	for _, c := range cases {
+		if got := Equal(c.a, c.b); got != c.want {
+			t.Errorf("Equal(%#v, %#v) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for name := range fixtures {
+		input, err := os.ReadFile(filepath.Join("..", "test", "yay", name+".yay"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Valid(input) {
+			t.Errorf("%s: not valid", name)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join("..", "test", "nay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".nay") {
+			continue
+		}
+		input, err := os.ReadFile(filepath.Join("..", "test", "nay", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Valid(input) {
+			t.Errorf("%s: valid", entry.Name())
+		}
+	}
 }
@@ -0,0 +1,410 @@
+package yayconv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"kriskowal.com/go/yay"
+)
+
+// ============================================================================
+// CBOR
+// ============================================================================
+//
+// ToCBOR and FromCBOR transcode between YAY and CBOR (RFC 8949), whose
+// values match YAY's nearly one for one, so that a YAY document can be
+// the readable twin of a binary message. Integers beyond 64 bits are
+// bignums, tags 2 and 3, byte arrays are byte strings, and objects are
+// maps with text keys in sorted order. Floats, NaN and the infinities
+// among them, are always written in double precision, so that a float
+// stays distinct from an integer of the same value whatever the reader.
+// The encoding of a document is thus the one the shared fixtures in
+// test/cbor give for it.
+//
+// Going the other way, what CBOR has and YAY lacks is flagged: tags other
+// than the bignums are dropped, undefined becomes null, and map keys
+// that are not text are written as strings. CBOR data has no lines, so
+// its errors and warnings give byte offsets.
+
+// maxDepth bounds the nesting of the arrays, maps, and tags of binary
+// formats, which, unlike most text, can nest deeply in few bytes, and of
+// YAML, whose flow collections can too.
+const maxDepth = 1000
+
+// ToCBOR returns the CBOR encoding of a YAY document.
+func ToCBOR(yayData []byte, opts Options) ([]byte, error) {
+	v, err := yay.UnmarshalWithOptions(yayData, yay.DecodeOptions{Filename: opts.Filename})
+	if err != nil {
+		return nil, err
+	}
+	return appendCBOR(make([]byte, 0, len(yayData)), v), nil
+}
+
+// appendCBOR appends the CBOR encoding of v, a value Unmarshal produces.
+func appendCBOR(buf []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xf6)
+	case bool:
+		if v {
+			return append(buf, 0xf5)
+		}
+		return append(buf, 0xf4)
+	case *big.Int:
+		if v.Sign() >= 0 {
+			if v.IsUint64() {
+				return appendCBORHead(buf, 0, v.Uint64())
+			}
+			buf = appendCBORHead(buf, 6, 2)
+			return appendCBORBytes(buf, 2, v.Bytes())
+		}
+		// A negative integer n is written as -1-n.
+		n := new(big.Int).Neg(v)
+		n.Sub(n, big.NewInt(1))
+		if n.IsUint64() {
+			return appendCBORHead(buf, 1, n.Uint64())
+		}
+		buf = appendCBORHead(buf, 6, 3)
+		return appendCBORBytes(buf, 2, n.Bytes())
+	case float64:
+		if math.IsNaN(v) {
+			// The one quiet NaN, whatever the payload.
+			return append(buf, 0xfb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 0)
+		}
+		buf = append(buf, 0xfb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+	case string:
+		return appendCBORBytes(buf, 3, []byte(v))
+	case []byte:
+		return appendCBORBytes(buf, 2, v)
+	case []any:
+		buf = appendCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			buf = appendCBOR(buf, item)
+		}
+		return buf
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = appendCBORHead(buf, 5, uint64(len(keys)))
+		for _, k := range keys {
+			buf = appendCBORBytes(buf, 3, []byte(k))
+			buf = appendCBOR(buf, v[k])
+		}
+		return buf
+	}
+	return buf
+}
+
+// appendCBORHead appends the head of a data item of the major type, with
+// its argument in the fewest bytes.
+func appendCBORHead(buf []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(buf, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major|27), arg)
+}
+
+// appendCBORBytes appends a byte or text string.
+func appendCBORBytes(buf []byte, major byte, b []byte) []byte {
+	return append(appendCBORHead(buf, major, uint64(len(b))), b...)
+}
+
+// FromCBOR returns the YAY encoding of a CBOR data item, as yay.Marshal
+// writes it but with map keys in the order the data gives them.
+func FromCBOR(cborData []byte, opts Options) ([]byte, error) {
+	r := &cborReader{src: cborData, opts: opts}
+	v, err := r.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos < len(r.src) {
+		return nil, r.errorf(r.pos, "Unexpected data after the CBOR item")
+	}
+	return yay.Marshal(v)
+}
+
+// cborReader decodes a CBOR data item.
+type cborReader struct {
+	src  []byte
+	pos  int
+	opts Options
+}
+
+// errorf returns an error at the byte offset off.
+func (r *cborReader) errorf(off int, format string, args ...any) error {
+	return &yay.ParseError{
+		Code:     "cbor-syntax",
+		Message:  fmt.Sprintf(format, args...) + fmt.Sprintf(" (byte offset %d)", off),
+		Position: yay.Position{Filename: r.opts.Filename, Offset: off},
+	}
+}
+
+// flag reports a construct at the byte offset off that YAY lacks.
+func (r *cborReader) flag(off int, format string, args ...any) error {
+	return r.opts.flag("cbor-unmapped", yay.Position{Offset: off}, format+" (byte offset %d)", append(args, off)...)
+}
+
+// head reads the head of a data item, returning its major type, its
+// additional information, and its argument.
+func (r *cborReader) head() (major, info byte, arg uint64, err error) {
+	if r.pos >= len(r.src) {
+		return 0, 0, 0, r.errorf(r.pos, "Unexpected end of CBOR data")
+	}
+	start := r.pos
+	b := r.src[r.pos]
+	r.pos++
+	major, info = b>>5, b&0x1f
+	size := 0
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		size = 1 << (info - 24)
+	case info == 31 && major >= 2 && major != 6:
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, r.errorf(start, "Invalid CBOR head 0x%02x", b)
+	}
+	if r.pos+size > len(r.src) {
+		return 0, 0, 0, r.errorf(r.pos, "Unexpected end of CBOR data")
+	}
+	for _, c := range r.src[r.pos : r.pos+size] {
+		arg = arg<<8 | uint64(c)
+	}
+	r.pos += size
+	return major, info, arg, nil
+}
+
+// length checks that n items, each of at least one byte, can follow.
+func (r *cborReader) length(n uint64) (int, error) {
+	if n > uint64(len(r.src)-r.pos) {
+		return 0, r.errorf(r.pos, "Unexpected end of CBOR data")
+	}
+	return int(n), nil
+}
+
+// value reads a data item at the nesting depth.
+func (r *cborReader) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, r.errorf(r.pos, "CBOR nested too deep (limit %d)", maxDepth)
+	}
+	start := r.pos
+	major, info, arg, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		return new(big.Int).SetUint64(arg), nil
+	case 1:
+		n := new(big.Int).SetUint64(arg)
+		return n.Neg(n).Sub(n, big.NewInt(1)), nil
+	case 2, 3:
+		b, err := r.bytes(start, major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == 2 {
+			return b, nil
+		}
+		if !utf8.Valid(b) {
+			return nil, r.errorf(start, "Invalid UTF-8 in CBOR text string")
+		}
+		return string(b), nil
+	case 4:
+		items := []any{}
+		for i := uint64(0); info == 31 || i < arg; i++ {
+			if info == 31 && r.isBreak() {
+				break
+			}
+			item, err := r.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		return r.mapping(start, info, arg, depth)
+	case 6:
+		return r.tagged(start, arg, depth)
+	}
+	switch {
+	case info == 20:
+		return false, nil
+	case info == 21:
+		return true, nil
+	case info == 22:
+		return nil, nil
+	case info == 23:
+		if err := r.flag(start, "Wrote undefined as null (YAY has no undefined)"); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case info == 25:
+		return halfFloat(uint16(arg)), nil
+	case info == 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case info == 27:
+		return math.Float64frombits(arg), nil
+	case info == 31:
+		return nil, r.errorf(start, "Unexpected CBOR break")
+	}
+	return nil, r.errorf(start, "Unsupported CBOR simple value %d", arg)
+}
+
+// isBreak reports whether the break that ends an item of indefinite
+// length is next, consuming it if so.
+func (r *cborReader) isBreak() bool {
+	if r.pos < len(r.src) && r.src[r.pos] == 0xff {
+		r.pos++
+		return true
+	}
+	return false
+}
+
+// bytes reads the content of a byte or text string, which may be given
+// in chunks of the same type if its length is indefinite.
+func (r *cborReader) bytes(start int, major, info byte, arg uint64) ([]byte, error) {
+	if info != 31 {
+		n, err := r.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		r.pos += n
+		return r.src[r.pos-n : r.pos], nil
+	}
+	var b []byte
+	for !r.isBreak() {
+		chunk := r.pos
+		m, i, arg, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || i == 31 {
+			return nil, r.errorf(chunk, "Invalid chunk of indefinite-length CBOR string")
+		}
+		part, err := r.bytes(chunk, m, i, arg)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, part...)
+	}
+	return b, nil
+}
+
+// mapping reads a map whose head begins at start, as an object.
+func (r *cborReader) mapping(start int, info byte, arg uint64, depth int) (*yay.OrderedMap, error) {
+	obj := yay.NewOrderedMap()
+	for i := uint64(0); info == 31 || i < arg; i++ {
+		if info == 31 && r.isBreak() {
+			break
+		}
+		keyOff := r.pos
+		k, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, err := r.key(keyOff, k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := obj.Get(key); ok {
+			if err := r.flag(keyOff, "Duplicate key %q (the last value is kept)", key); err != nil {
+				return nil, err
+			}
+		}
+		obj.Set(key, value)
+	}
+	return obj, nil
+}
+
+// key returns the string of map key k, flagging one that is not text.
+func (r *cborReader) key(off int, k any) (string, error) {
+	var key, kind string
+	switch k := k.(type) {
+	case string:
+		return k, nil
+	case *big.Int:
+		key, kind = k.String(), "an integer"
+	case float64:
+		key, kind = strconv.FormatFloat(k, 'g', -1, 64), "a float"
+	case bool:
+		key, kind = strconv.FormatBool(k), "a boolean"
+	case nil:
+		key, kind = "null", "null"
+	case []byte:
+		key, kind = fmt.Sprintf("%x", k), "a byte string"
+	default:
+		return "", r.errorf(off, "Unsupported CBOR map key (YAY keys are strings)")
+	}
+	if err := r.flag(off, "Key %s is %s in CBOR (YAY keys are strings)", key, kind); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// tagged reads the content of a tag whose head begins at start, reading
+// bignums as integers and dropping other tags.
+func (r *cborReader) tagged(start int, tag uint64, depth int) (any, error) {
+	if tag != 2 && tag != 3 {
+		if err := r.flag(start, "Dropped tag %d (YAY has no tags)", tag); err != nil {
+			return nil, err
+		}
+		return r.value(depth + 1)
+	}
+	content := r.pos
+	v, err := r.value(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, r.errorf(content, "Expected byte string in CBOR bignum")
+	}
+	n := new(big.Int).SetBytes(b)
+	if tag == 3 {
+		n.Neg(n).Sub(n, big.NewInt(1))
+	}
+	return n, nil
+}
+
+// halfFloat returns the value of an IEEE 754 half-precision float.
+func halfFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
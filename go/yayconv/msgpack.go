@@ -0,0 +1,399 @@
+package yayconv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"kriskowal.com/go/yay"
+)
+
+// ============================================================================
+// MessagePack
+// ============================================================================
+//
+// ToMsgPack and FromMsgPack transcode between YAY and MessagePack, so that
+// a service that speaks MessagePack can dump its payloads as YAY for
+// people to read and edit, and load them back. Integers are written in
+// the fewest bytes, floats as float32 where that holds them exactly, byte
+// arrays as bin, and objects as maps with str keys, in the order of the
+// document. MessagePack integers stop at 64 bits, so larger ones are
+// written as strings of their digits, with a warning.
+//
+// Going the other way, timestamps, extension type -1, are written as
+// RFC 3339 strings, other extension types as byte arrays of their data,
+// and map keys that are not str as strings, each with a warning.
+
+// ToMsgPack returns the MessagePack encoding of a YAY document.
+func ToMsgPack(yayData []byte, opts Options) ([]byte, error) {
+	root, err := yay.ParseASTWithOptions(yayData, yay.DecodeOptions{Filename: opts.Filename})
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPack(make([]byte, 0, len(yayData)), root, opts)
+}
+
+// appendMsgPack appends the MessagePack encoding of the value of node n.
+func appendMsgPack(buf []byte, n yay.Node, opts Options) ([]byte, error) {
+	var err error
+	switch n := n.(type) {
+	case *yay.NullNode:
+		return append(buf, 0xc0), nil
+	case *yay.BoolNode:
+		if n.Value {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case *yay.IntegerNode:
+		switch {
+		case n.Value.IsInt64():
+			return appendMsgPackInt(buf, n.Value.Int64()), nil
+		case n.Value.IsUint64():
+			return binary.BigEndian.AppendUint64(append(buf, 0xcf), n.Value.Uint64()), nil
+		}
+		if err := opts.flag("msgpack-unmapped", n.Start, "Wrote integer beyond 64 bits as a string (MessagePack integers are 64-bit)"); err != nil {
+			return nil, err
+		}
+		return appendMsgPackString(buf, n.Value.String()), nil
+	case *yay.FloatNode:
+		if float64(float32(n.Value)) == n.Value || math.IsNaN(n.Value) {
+			return binary.BigEndian.AppendUint32(append(buf, 0xca), math.Float32bits(float32(n.Value))), nil
+		}
+		return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(n.Value)), nil
+	case *yay.StringNode:
+		return appendMsgPackString(buf, n.Value), nil
+	case *yay.BytesNode:
+		buf = appendMsgPackLength(buf, len(n.Value), 0, 0xc4, 0xc5, 0xc6)
+		return append(buf, n.Value...), nil
+	case *yay.ArrayNode:
+		buf = appendMsgPackLength(buf, len(n.Items), 0x90, 0, 0xdc, 0xdd)
+		for _, item := range n.Items {
+			if buf, err = appendMsgPack(buf, item, opts); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case *yay.ObjectNode:
+		buf = appendMsgPackLength(buf, len(n.Properties), 0x80, 0, 0xde, 0xdf)
+		for _, p := range n.Properties {
+			buf = appendMsgPackString(buf, p.Key)
+			if buf, err = appendMsgPack(buf, p.Value, opts); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return buf, nil
+}
+
+// appendMsgPackInt appends an integer in the fewest bytes.
+func appendMsgPackInt(buf []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= math.MaxInt8, v < 0 && v >= -32:
+		return append(buf, byte(v))
+	case v >= 0 && v <= math.MaxUint8:
+		return append(buf, 0xcc, byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(v))
+	case v >= 0 && v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(v))
+	case v >= 0:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), uint64(v))
+	case v >= math.MinInt8:
+		return append(buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(v))
+}
+
+// appendMsgPackString appends s as a str.
+func appendMsgPackString(buf []byte, s string) []byte {
+	buf = appendMsgPackLength(buf, len(s), 0xa0, 0xd9, 0xda, 0xdb)
+	return append(buf, s...)
+}
+
+// appendMsgPackLength appends the head of a str, bin, array, or map of
+// length n: fixed, with n in the low bits of fix, if n fits, or else with
+// n in one, two, or four bytes. A format that a type lacks is 0.
+func appendMsgPackLength(buf []byte, n int, fix, one, two, four byte) []byte {
+	limit := 16
+	if fix == 0xa0 {
+		limit = 32
+	}
+	switch {
+	case fix != 0 && n < limit:
+		return append(buf, fix|byte(n))
+	case one != 0 && n <= math.MaxUint8:
+		return append(buf, one, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, two), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, four), uint32(n))
+}
+
+// FromMsgPack returns the YAY encoding of a MessagePack object, as
+// yay.Marshal writes it but with map keys in the order the data gives
+// them.
+func FromMsgPack(msgpackData []byte, opts Options) ([]byte, error) {
+	r := &msgpackReader{src: msgpackData, opts: opts}
+	v, err := r.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos < len(r.src) {
+		return nil, r.errorf(r.pos, "Unexpected data after the MessagePack object")
+	}
+	return yay.Marshal(v)
+}
+
+// msgpackReader decodes a MessagePack object.
+type msgpackReader struct {
+	src  []byte
+	pos  int
+	opts Options
+}
+
+// errorf returns an error at the byte offset off.
+func (r *msgpackReader) errorf(off int, format string, args ...any) error {
+	return &yay.ParseError{
+		Code:     "msgpack-syntax",
+		Message:  fmt.Sprintf(format, args...) + fmt.Sprintf(" (byte offset %d)", off),
+		Position: yay.Position{Filename: r.opts.Filename, Offset: off},
+	}
+}
+
+// flag reports a construct at the byte offset off that YAY lacks.
+func (r *msgpackReader) flag(off int, format string, args ...any) error {
+	return r.opts.flag("msgpack-unmapped", yay.Position{Offset: off}, format+" (byte offset %d)", append(args, off)...)
+}
+
+// next returns the n bytes that follow.
+func (r *msgpackReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.src)-r.pos) {
+		return nil, r.errorf(r.pos, "Unexpected end of MessagePack data")
+	}
+	b := r.src[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (r *msgpackReader) uint(size int) (uint64, error) {
+	b, err := r.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// value reads an object at the nesting depth.
+func (r *msgpackReader) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, r.errorf(r.pos, "MessagePack nested too deep (limit %d)", maxDepth)
+	}
+	start := r.pos
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return big.NewInt(int64(c)), nil
+	case c >= 0xe0:
+		return big.NewInt(int64(int8(c))), nil
+	case c <= 0x8f:
+		return r.mapping(uint64(c&0x0f), depth)
+	case c <= 0x9f:
+		return r.array(uint64(c&0x0f), depth)
+	case c <= 0xbf:
+		return r.str(start, uint64(c&0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.next(n)
+		return append([]byte{}, data...), err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return r.ext(start, n)
+	case 0xca:
+		bits, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := r.uint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := r.uint(1 << (c - 0xcc))
+		return new(big.Int).SetUint64(v), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := r.uint(size)
+		// Extend the sign of the size-byte integer.
+		shift := 64 - 8*size
+		return big.NewInt(int64(v<<shift) >> shift), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.ext(start, 1<<(c-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.str(start, n)
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.mapping(n, depth)
+	}
+	return nil, r.errorf(start, "Invalid MessagePack format 0x%02x", c)
+}
+
+// str reads a str of n bytes, whose head begins at start.
+func (r *msgpackReader) str(start int, n uint64) (string, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", r.errorf(start, "Invalid UTF-8 in MessagePack str")
+	}
+	return string(b), nil
+}
+
+// array reads n objects as an array.
+func (r *msgpackReader) array(n uint64, depth int) ([]any, error) {
+	items := []any{}
+	for i := uint64(0); i < n; i++ {
+		item, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mapping reads n key/value pairs as an object.
+func (r *msgpackReader) mapping(n uint64, depth int) (*yay.OrderedMap, error) {
+	obj := yay.NewOrderedMap()
+	for i := uint64(0); i < n; i++ {
+		keyOff := r.pos
+		k, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, err := r.key(keyOff, k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := obj.Get(key); ok {
+			if err := r.flag(keyOff, "Duplicate key %q (the last value is kept)", key); err != nil {
+				return nil, err
+			}
+		}
+		obj.Set(key, value)
+	}
+	return obj, nil
+}
+
+// key returns the string of map key k, flagging one that is not a str.
+func (r *msgpackReader) key(off int, k any) (string, error) {
+	var key, kind string
+	switch k := k.(type) {
+	case string:
+		return k, nil
+	case *big.Int:
+		key, kind = k.String(), "an integer"
+	case float64:
+		key, kind = strconv.FormatFloat(k, 'g', -1, 64), "a float"
+	case bool:
+		key, kind = strconv.FormatBool(k), "a boolean"
+	case nil:
+		key, kind = "null", "nil"
+	case []byte:
+		key, kind = fmt.Sprintf("%x", k), "a bin"
+	default:
+		return "", r.errorf(off, "Unsupported MessagePack map key (YAY keys are strings)")
+	}
+	if err := r.flag(off, "Key %s is %s in MessagePack (YAY keys are strings)", key, kind); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ext reads the type and n bytes of data of an extension, whose head
+// begins at start, writing a timestamp as a string and the data of
+// others as a byte array.
+func (r *msgpackReader) ext(start int, n uint64) (any, error) {
+	t, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	typ := int8(t[0])
+	data, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if typ != -1 {
+		if err := r.flag(start, "Wrote extension type %d as its data (YAY has no extensions)", typ); err != nil {
+			return nil, err
+		}
+		return append([]byte{}, data...), nil
+	}
+	var sec int64
+	var nsec uint32
+	switch len(data) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(v&(1<<34-1)), uint32(v>>34)
+	case 12:
+		nsec, sec = binary.BigEndian.Uint32(data), int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return nil, r.errorf(start, "Invalid MessagePack timestamp")
+	}
+	if nsec > 999999999 {
+		return nil, r.errorf(start, "Invalid MessagePack timestamp")
+	}
+	s := time.Unix(sec, int64(nsec)).UTC().Format(time.RFC3339Nano)
+	if err := r.flag(start, "Wrote timestamp %s as a string (YAY has no dates)", s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,953 @@
+package yayconv
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"kriskowal.com/go/yay"
+)
+
+// ============================================================================
+// TOML
+// ============================================================================
+//
+// FromTOML reads TOML 1.0 and ToTOML writes it. Every TOML value but the
+// date-times has a YAY value of the same kind, and the date-times are
+// written as the strings they were spelled with. Going the other way, a
+// YAY document must be an object, as a TOML document is a table, and YAY
+// has three kinds of value that TOML lacks, which are errors: null, byte
+// arrays, and integers beyond 64 bits. Both directions write what the
+// fixtures in test/toml and test/from-toml hold.
+
+// FromTOML returns the YAY encoding of a TOML document, as yay.Marshal
+// writes it, with keys sorted. Comments are not kept.
+func FromTOML(tomlData []byte, opts Options) ([]byte, error) {
+	root := yay.NewOrderedMap()
+	r := &tomlReader{
+		src:    bytes.TrimPrefix(tomlData, []byte("\xef\xbb\xbf")),
+		opts:   opts,
+		root:   root,
+		table:  root,
+		tables: map[*yay.OrderedMap]tableKind{root: explicitTable},
+		arrays: map[tableArray]bool{},
+	}
+	if err := r.document(); err != nil {
+		return nil, err
+	}
+	return yay.Marshal(plainMaps(root))
+}
+
+// tableKind tells how a table was defined, which decides whether a later
+// header or dotted key may add to it.
+type tableKind int
+
+const (
+	implicitTable tableKind = iota // By a header or dotted key naming a table within it
+	explicitTable                  // By its own header
+	dottedTable                    // By a dotted key
+	inlineTable                    // In braces, which closes it
+)
+
+// tableArray names an array of tables by the table that holds it and its
+// key.
+type tableArray struct {
+	parent *yay.OrderedMap
+	key    string
+}
+
+// tomlReader parses a TOML document into the tables of root.
+type tomlReader struct {
+	src  []byte
+	pos  int
+	opts Options
+
+	root   *yay.OrderedMap
+	table  *yay.OrderedMap // The table that key/value pairs go to
+	tables map[*yay.OrderedMap]tableKind
+	arrays map[tableArray]bool // The arrays defined with [[headers]]
+}
+
+// position returns the position of the byte offset off.
+func (r *tomlReader) position(off int) yay.Position {
+	start := bytes.LastIndexByte(r.src[:off], '\n') + 1
+	return yay.Position{
+		Filename: r.opts.Filename,
+		Line:     bytes.Count(r.src[:start], []byte{'\n'}) + 1,
+		Column:   utf8.RuneCount(r.src[start:off]) + 1,
+		Offset:   off,
+	}
+}
+
+// errorf returns a syntax error at offset off.
+func (r *tomlReader) errorf(off int, format string, args ...any) error {
+	return &yay.ParseError{Code: "toml-syntax", Message: fmt.Sprintf(format, args...), Position: r.position(off)}
+}
+
+// at returns the byte at offset i, or 0 past the end.
+func (r *tomlReader) at(i int) byte {
+	if i < len(r.src) {
+		return r.src[i]
+	}
+	return 0
+}
+
+func (r *tomlReader) eof() bool {
+	return r.pos >= len(r.src)
+}
+
+// skipInline skips spaces and tabs.
+func (r *tomlReader) skipInline() {
+	for r.at(r.pos) == ' ' || r.at(r.pos) == '\t' {
+		r.pos++
+	}
+}
+
+// skipComment skips a comment, if one begins at the current offset.
+func (r *tomlReader) skipComment() {
+	if r.at(r.pos) == '#' {
+		for !r.eof() && r.src[r.pos] != '\n' {
+			r.pos++
+		}
+	}
+}
+
+// skipSpace skips whitespace, line breaks, and comments, as may come
+// between the items of an array.
+func (r *tomlReader) skipSpace() {
+	for {
+		r.skipInline()
+		r.skipComment()
+		switch {
+		case r.at(r.pos) == '\n':
+			r.pos++
+		case r.at(r.pos) == '\r' && r.at(r.pos+1) == '\n':
+			r.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// endLine expects the end of a line, after any comment.
+func (r *tomlReader) endLine() error {
+	r.skipInline()
+	r.skipComment()
+	switch {
+	case r.eof():
+	case r.src[r.pos] == '\n':
+		r.pos++
+	case r.src[r.pos] == '\r' && r.at(r.pos+1) == '\n':
+		r.pos += 2
+	default:
+		return r.errorf(r.pos, "Expected newline after value")
+	}
+	return nil
+}
+
+// document parses each line of the document in turn.
+func (r *tomlReader) document() error {
+	for {
+		r.skipSpace()
+		if r.eof() {
+			return nil
+		}
+		var err error
+		if r.src[r.pos] == '[' {
+			err = r.header()
+		} else {
+			err = r.keyValue(r.table)
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.endLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// header parses a [table] or [[array of tables]] header, making the table
+// it names current.
+func (r *tomlReader) header() error {
+	start := r.pos
+	array := r.at(r.pos+1) == '['
+	r.pos++
+	if array {
+		r.pos++
+	}
+	r.skipInline()
+	keys, offs, err := r.key()
+	if err != nil {
+		return err
+	}
+	r.skipInline()
+	if r.at(r.pos) != ']' || array && r.at(r.pos+1) != ']' {
+		if array {
+			return r.errorf(r.pos, "Expected \"]]\" after table name")
+		}
+		return r.errorf(r.pos, "Expected \"]\" after table name")
+	}
+	r.pos++
+	if array {
+		r.pos++
+	}
+
+	parent := r.root
+	for i, key := range keys[:len(keys)-1] {
+		if parent, err = r.descend(parent, key, offs[i], implicitTable); err != nil {
+			return err
+		}
+	}
+	key, off := keys[len(keys)-1], offs[len(offs)-1]
+	existing, ok := parent.Get(key)
+	if array {
+		table := yay.NewOrderedMap()
+		r.tables[table] = explicitTable
+		switch {
+		case !ok:
+			parent.Set(key, []any{table})
+			r.arrays[tableArray{parent, key}] = true
+		case r.arrays[tableArray{parent, key}]:
+			parent.Set(key, append(existing.([]any), table))
+		default:
+			return r.errorf(off, "Key %s is already defined", formatKey(keys))
+		}
+		r.table = table
+		return nil
+	}
+	if !ok {
+		table := yay.NewOrderedMap()
+		r.tables[table] = explicitTable
+		parent.Set(key, table)
+		r.table = table
+		return nil
+	}
+	table, isTable := existing.(*yay.OrderedMap)
+	if !isTable || r.tables[table] != implicitTable {
+		return r.errorf(start, "Table %s is already defined", formatKey(keys))
+	}
+	r.tables[table] = explicitTable
+	r.table = table
+	return nil
+}
+
+// descend returns the table under key in parent, creating it as kind if
+// missing, or the last table of an array of tables.
+func (r *tomlReader) descend(parent *yay.OrderedMap, key string, off int, kind tableKind) (*yay.OrderedMap, error) {
+	existing, ok := parent.Get(key)
+	if !ok {
+		table := yay.NewOrderedMap()
+		r.tables[table] = kind
+		parent.Set(key, table)
+		return table, nil
+	}
+	switch v := existing.(type) {
+	case *yay.OrderedMap:
+		if r.tables[v] == inlineTable || kind == dottedTable && r.tables[v] == explicitTable {
+			return nil, r.errorf(off, "Table %s is already defined", tomlKey(key))
+		}
+		return v, nil
+	case []any:
+		if r.arrays[tableArray{parent, key}] {
+			return v[len(v)-1].(*yay.OrderedMap), nil
+		}
+	}
+	return nil, r.errorf(off, "Key %s is not a table", tomlKey(key))
+}
+
+// formatKey returns keys as a header would name them.
+func formatKey(keys []string) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = tomlKey(key)
+	}
+	return strings.Join(parts, ".")
+}
+
+// keyValue parses a key/value pair into table.
+func (r *tomlReader) keyValue(table *yay.OrderedMap) error {
+	keys, offs, err := r.key()
+	if err != nil {
+		return err
+	}
+	r.skipInline()
+	if r.at(r.pos) != '=' {
+		return r.errorf(r.pos, "Expected \"=\" after key")
+	}
+	r.pos++
+	r.skipInline()
+	for i, key := range keys[:len(keys)-1] {
+		if table, err = r.descend(table, key, offs[i], dottedTable); err != nil {
+			return err
+		}
+	}
+	key, off := keys[len(keys)-1], offs[len(offs)-1]
+	if _, ok := table.Get(key); ok {
+		return r.errorf(off, "Duplicate key %s", tomlKey(key))
+	}
+	value, err := r.value()
+	if err != nil {
+		return err
+	}
+	table.Set(key, value)
+	return nil
+}
+
+// key parses a key, which may be dotted, returning each of its parts and
+// where each begins.
+func (r *tomlReader) key() ([]string, []int, error) {
+	var keys []string
+	var offs []int
+	for {
+		start := r.pos
+		var key string
+		switch c := r.at(r.pos); {
+		case c == '"':
+			s, err := r.basicString()
+			if err != nil {
+				return nil, nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := r.literalString()
+			if err != nil {
+				return nil, nil, err
+			}
+			key = s
+		default:
+			for isBareKey(r.at(r.pos)) {
+				r.pos++
+			}
+			if r.pos == start {
+				if r.eof() {
+					return nil, nil, r.errorf(start, "Expected key")
+				}
+				c, _ := utf8.DecodeRune(r.src[start:])
+				return nil, nil, r.errorf(start, "Unexpected %q", string(c))
+			}
+			key = string(r.src[start:r.pos])
+		}
+		keys = append(keys, key)
+		offs = append(offs, start)
+		r.skipInline()
+		if r.at(r.pos) != '.' {
+			return keys, offs, nil
+		}
+		r.pos++
+		r.skipInline()
+	}
+}
+
+func isBareKey(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// value parses a value.
+func (r *tomlReader) value() (any, error) {
+	start := r.pos
+	switch c := r.at(r.pos); c {
+	case '"':
+		return r.basicString()
+	case '\'':
+		return r.literalString()
+	case '[':
+		return r.array()
+	case '{':
+		return r.inlineTable()
+	case 0, '\n', '\r', '#':
+		return nil, r.errorf(start, "Expected value")
+	}
+	// Booleans, numbers, and date-times run to the next delimiter, but
+	// for the space that may part a date from its time.
+	end := r.pos
+	for end < len(r.src) && !strings.ContainsRune(" \t\r\n,]}#", rune(r.src[end])) {
+		end++
+	}
+	if end-r.pos == 10 && r.at(end) == ' ' && isDigit(r.at(end+1)) && isDigit(r.at(end+2)) && r.at(end+3) == ':' {
+		end++
+		for end < len(r.src) && !strings.ContainsRune(" \t\r\n,]}#", rune(r.src[end])) {
+			end++
+		}
+	}
+	token := string(r.src[r.pos:end])
+	r.pos = end
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+	if isDateTime(token) {
+		if err := r.opts.flag("toml-unmapped", r.position(start), "Wrote date-time %s as a string (YAY has no dates)", token); err != nil {
+			return nil, err
+		}
+		return token, nil
+	}
+	if n, ok := parseTOMLInteger(token); ok {
+		return n, nil
+	}
+	if f, ok := parseTOMLFloat(token); ok {
+		return f, nil
+	}
+	return nil, r.errorf(start, "Invalid value %s", token)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isDateTime reports whether s is an offset or local date-time, date, or
+// time.
+func isDateTime(s string) bool {
+	if len(s) >= 11 && (s[10] == ' ' || s[10] == 't') {
+		s = s[:10] + "T" + s[11:]
+	}
+	s = strings.Replace(s, "z", "Z", 1)
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02",
+		"15:04:05.999999999",
+	} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// digits removes the underscores from s, which TOML allows only between
+// digits, reporting whether there were none elsewhere.
+func digits(s string, isDigit func(byte) bool) (string, bool) {
+	if !strings.Contains(s, "_") {
+		return s, true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && (i == 0 || i == len(s)-1 || !isDigit(s[i-1]) || !isDigit(s[i+1])) {
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(s, "_", ""), true
+}
+
+// parseTOMLInteger parses a decimal integer, with an optional sign and no
+// leading zeros, or a hexadecimal, octal, or binary one.
+func parseTOMLInteger(s string) (*big.Int, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		base = 16
+	case strings.HasPrefix(s, "0o"):
+		base = 8
+	case strings.HasPrefix(s, "0b"):
+		base = 2
+	}
+	if base != 10 {
+		d, ok := digits(s[2:], func(c byte) bool {
+			return strings.IndexByte("0123456789abcdefABCDEF"[:base+max(base-10, 0)], c) >= 0
+		})
+		if !ok || d == "" || strings.ContainsAny(d, "+-") {
+			return nil, false
+		}
+		return new(big.Int).SetString(d, base)
+	}
+	unsigned := strings.TrimLeft(s, "+-")
+	if len(s)-len(unsigned) > 1 || len(unsigned) > 1 && unsigned[0] == '0' {
+		return nil, false
+	}
+	d, ok := digits(unsigned, isDigit)
+	if !ok || d == "" {
+		return nil, false
+	}
+	for i := 0; i < len(d); i++ {
+		if !isDigit(d[i]) {
+			return nil, false
+		}
+	}
+	n, _ := new(big.Int).SetString(d, 10)
+	if s[0] == '-' {
+		n.Neg(n)
+	}
+	return n, true
+}
+
+// parseTOMLFloat parses a float, with a fraction, an exponent, or both.
+func parseTOMLFloat(s string) (float64, bool) {
+	unsigned := strings.TrimLeft(s, "+-")
+	if len(s)-len(unsigned) > 1 {
+		return 0, false
+	}
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(unsigned), "e")
+	whole, fraction, hasFraction := strings.Cut(mantissa, ".")
+	if !hasExponent && !hasFraction {
+		return 0, false
+	}
+	whole, okWhole := digits(whole, isDigit)
+	fraction, okFraction := digits(fraction, isDigit)
+	exponent, okExponent := digits(strings.TrimLeft(exponent, "+-"), isDigit)
+	if !okWhole || !okFraction || !okExponent ||
+		!isDecimal(whole) || len(whole) > 1 && whole[0] == '0' ||
+		hasFraction && !isDecimal(fraction) ||
+		hasExponent && !isDecimal(exponent) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// basicString parses a string in double quotes, or in three for one that
+// may span lines.
+func (r *tomlReader) basicString() (string, error) {
+	start := r.pos
+	multiline := bytes.HasPrefix(r.src[r.pos:], []byte(`"""`))
+	if multiline {
+		r.pos += 3
+		r.skipNewline()
+	} else {
+		r.pos++
+	}
+	var b strings.Builder
+	for {
+		if r.eof() {
+			return "", r.errorf(start, "Unterminated string")
+		}
+		c := r.src[r.pos]
+		switch {
+		case c == '"' && !multiline:
+			r.pos++
+			return b.String(), nil
+		case c == '"' && bytes.HasPrefix(r.src[r.pos:], []byte(`"""`)):
+			// Up to two quotes may end the string before its closing ones.
+			n := 3
+			for n < 5 && r.at(r.pos+n) == '"' {
+				n++
+			}
+			b.WriteString(strings.Repeat(`"`, n-3))
+			r.pos += n
+			return b.String(), nil
+		case c == '\\':
+			if multiline && r.lineEndingBackslash() {
+				continue
+			}
+			if err := r.escape(&b); err != nil {
+				return "", err
+			}
+		case c == '\n' && !multiline:
+			return "", r.errorf(start, "Unterminated string")
+		default:
+			if err := r.char(&b, multiline); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// literalString parses a string in single quotes, or in three for one
+// that may span lines, in which backslashes are not escapes.
+func (r *tomlReader) literalString() (string, error) {
+	start := r.pos
+	multiline := bytes.HasPrefix(r.src[r.pos:], []byte(`'''`))
+	if multiline {
+		r.pos += 3
+		r.skipNewline()
+	} else {
+		r.pos++
+	}
+	var b strings.Builder
+	for {
+		if r.eof() {
+			return "", r.errorf(start, "Unterminated string")
+		}
+		c := r.src[r.pos]
+		switch {
+		case c == '\'' && !multiline:
+			r.pos++
+			return b.String(), nil
+		case c == '\'' && bytes.HasPrefix(r.src[r.pos:], []byte(`'''`)):
+			n := 3
+			for n < 5 && r.at(r.pos+n) == '\'' {
+				n++
+			}
+			b.WriteString(strings.Repeat(`'`, n-3))
+			r.pos += n
+			return b.String(), nil
+		case c == '\n' && !multiline:
+			return "", r.errorf(start, "Unterminated string")
+		default:
+			if err := r.char(&b, multiline); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// skipNewline skips the line break that may follow the quotes that open a
+// multi-line string.
+func (r *tomlReader) skipNewline() {
+	if r.at(r.pos) == '\n' {
+		r.pos++
+	} else if r.at(r.pos) == '\r' && r.at(r.pos+1) == '\n' {
+		r.pos += 2
+	}
+}
+
+// lineEndingBackslash skips a backslash that ends a line of a multi-line
+// string, with the whitespace and line breaks after it, reporting whether
+// there was one.
+func (r *tomlReader) lineEndingBackslash() bool {
+	i := r.pos + 1
+	for r.at(i) == ' ' || r.at(i) == '\t' {
+		i++
+	}
+	if r.at(i) == '\r' {
+		i++
+	}
+	if r.at(i) != '\n' {
+		return false
+	}
+	for r.at(i) == ' ' || r.at(i) == '\t' || r.at(i) == '\r' || r.at(i) == '\n' {
+		i++
+	}
+	r.pos = i
+	return true
+}
+
+// char writes the character at the current offset to b, which may be a
+// line break only in a multi-line string.
+func (r *tomlReader) char(b *strings.Builder, multiline bool) error {
+	c, size := utf8.DecodeRune(r.src[r.pos:])
+	switch {
+	case c == utf8.RuneError && size == 1:
+		return r.errorf(r.pos, "Invalid UTF-8")
+	case c == '\r' && multiline && r.at(r.pos+1) == '\n':
+		r.pos++
+		return nil
+	case c < 0x20 && c != '\t' && !(c == '\n' && multiline), c == 0x7f:
+		return r.errorf(r.pos, "Unexpected control character U+%04X in string", c)
+	}
+	b.WriteRune(c)
+	r.pos += size
+	return nil
+}
+
+// escape writes the escape sequence at the current offset to b.
+func (r *tomlReader) escape(b *strings.Builder) error {
+	start := r.pos
+	c := r.at(r.pos + 1)
+	if s, ok := map[byte]string{
+		'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", 'e': "\x1b",
+		'"': "\"", '\\': "\\",
+	}[c]; ok {
+		b.WriteString(s)
+		r.pos += 2
+		return nil
+	}
+	n := map[byte]int{'u': 4, 'U': 8}[c]
+	if n == 0 || r.pos+2+n > len(r.src) {
+		return r.errorf(start, "Invalid escape sequence")
+	}
+	code, err := strconv.ParseUint(string(r.src[r.pos+2:r.pos+2+n]), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return r.errorf(start, "Invalid escape sequence")
+	}
+	b.WriteRune(rune(code))
+	r.pos += 2 + n
+	return nil
+}
+
+// array parses an array, which may span lines.
+func (r *tomlReader) array() ([]any, error) {
+	start := r.pos
+	r.pos++
+	items := []any{}
+	for {
+		r.skipSpace()
+		if r.at(r.pos) == ']' {
+			r.pos++
+			return items, nil
+		}
+		if r.eof() {
+			return nil, r.errorf(start, "Unterminated array")
+		}
+		item, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		r.skipSpace()
+		switch r.at(r.pos) {
+		case ',':
+			r.pos++
+		case ']':
+		default:
+			if r.eof() {
+				return nil, r.errorf(start, "Unterminated array")
+			}
+			return nil, r.errorf(r.pos, "Expected \",\" or \"]\"")
+		}
+	}
+}
+
+// inlineTable parses a table in braces, on one line.
+func (r *tomlReader) inlineTable() (*yay.OrderedMap, error) {
+	start := r.pos
+	r.pos++
+	table := yay.NewOrderedMap()
+	r.tables[table] = inlineTable
+	r.skipInline()
+	if r.at(r.pos) == '}' {
+		r.pos++
+		return table, nil
+	}
+	for {
+		r.skipInline()
+		if r.eof() || r.src[r.pos] == '\n' {
+			return nil, r.errorf(start, "Unterminated inline table")
+		}
+		// Dotted keys may make tables within, which close with it.
+		before := len(r.tables)
+		if err := r.keyValue(table); err != nil {
+			return nil, err
+		}
+		if len(r.tables) > before {
+			r.closeTables(table)
+		}
+		r.skipInline()
+		switch r.at(r.pos) {
+		case ',':
+			r.pos++
+		case '}':
+			r.pos++
+			return table, nil
+		default:
+			if r.eof() || r.src[r.pos] == '\n' {
+				return nil, r.errorf(start, "Unterminated inline table")
+			}
+			return nil, r.errorf(r.pos, "Expected \",\" or \"}\"")
+		}
+	}
+}
+
+// closeTables marks the tables within table as inline, so that nothing
+// outside its braces may add to them.
+func (r *tomlReader) closeTables(table *yay.OrderedMap) {
+	for _, m := range table.Members() {
+		if t, ok := m.Value.(*yay.OrderedMap); ok && r.tables[t] == dottedTable {
+			r.tables[t] = inlineTable
+			r.closeTables(t)
+		}
+	}
+}
+
+// ToTOML returns the TOML encoding of a YAY document, which must be an
+// object, laid out as the other implementations write it: keys sorted,
+// and in each table the properties whose values are not objects before
+// those that are, which are written as tables, each with a header of its
+// own. Objects in arrays are written as inline tables, and strings with
+// line breaks in triple quotes. Null, byte arrays, and integers beyond 64
+// bits have no TOML values and are errors. Comments are not kept.
+func ToTOML(yayData []byte, opts Options) ([]byte, error) {
+	root, err := yay.ParseASTWithOptions(yayData, yay.DecodeOptions{Filename: opts.Filename})
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := root.(*yay.ObjectNode)
+	if !ok {
+		return nil, unmapped(root, "Expected object (a TOML document is a table)")
+	}
+	w := &tomlWriter{}
+	if err := w.table(nil, obj); err != nil {
+		return nil, err
+	}
+	if w.buf.Len() == 0 {
+		// An empty document is a blank line, as the other
+		// implementations write it.
+		w.buf.WriteByte('\n')
+	}
+	return w.buf.Bytes(), nil
+}
+
+// tomlWriter writes a YAY syntax tree as TOML.
+type tomlWriter struct {
+	buf bytes.Buffer
+}
+
+// unmapped returns an error for a value at node n that TOML lacks.
+func unmapped(n yay.Node, format string, args ...any) error {
+	return &yay.ParseError{Code: "toml-unmapped", Message: fmt.Sprintf(format, args...), Position: n.Extent().Start}
+}
+
+// sortedProperties returns the properties of obj sorted by key.
+func sortedProperties(obj *yay.ObjectNode) []*yay.PropertyNode {
+	props := append([]*yay.PropertyNode(nil), obj.Properties...)
+	sort.Slice(props, func(i, j int) bool { return props[i].Key < props[j].Key })
+	return props
+}
+
+// table writes the properties of obj, the table at path, with its
+// tables after its other values.
+func (w *tomlWriter) table(path []string, obj *yay.ObjectNode) error {
+	props := sortedProperties(obj)
+	for _, p := range props {
+		if _, ok := p.Value.(*yay.ObjectNode); ok {
+			continue
+		}
+		w.buf.WriteString(tomlKey(p.Key) + " = ")
+		if err := w.value(p.Value); err != nil {
+			return err
+		}
+		w.buf.WriteByte('\n')
+	}
+	for _, p := range props {
+		if child, ok := p.Value.(*yay.ObjectNode); ok {
+			sub := append(path[:len(path):len(path)], p.Key)
+			w.header(sub)
+			if err := w.table(sub, child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// header writes a table header, parted by a blank line from what comes
+// before.
+func (w *tomlWriter) header(path []string) {
+	if w.buf.Len() > 0 {
+		w.buf.WriteByte('\n')
+	}
+	w.buf.WriteString("[" + formatKey(path) + "]\n")
+}
+
+// value writes n inline.
+func (w *tomlWriter) value(n yay.Node) error {
+	switch n := n.(type) {
+	case *yay.StringNode:
+		w.buf.WriteString(tomlString(n.Value))
+	case *yay.BytesNode:
+		return unmapped(n, "Unexpected byte array (TOML has no binary data type)")
+	case *yay.IntegerNode:
+		if !n.Value.IsInt64() {
+			return unmapped(n, "Unexpected integer beyond 64 bits (TOML integers are 64-bit)")
+		}
+		w.buf.WriteString(n.Value.String())
+	case *yay.FloatNode:
+		switch {
+		case math.IsNaN(n.Value):
+			w.buf.WriteString("nan")
+		case math.IsInf(n.Value, 1):
+			w.buf.WriteString("inf")
+		case math.IsInf(n.Value, -1):
+			w.buf.WriteString("-inf")
+		default:
+			s := strconv.FormatFloat(n.Value, 'f', -1, 64)
+			w.buf.WriteString(s)
+			if !strings.Contains(s, ".") {
+				w.buf.WriteString(".0")
+			}
+		}
+	case *yay.BoolNode:
+		w.buf.WriteString(strconv.FormatBool(n.Value))
+	case *yay.NullNode:
+		return unmapped(n, "Unexpected null (TOML has no null)")
+	case *yay.ArrayNode:
+		w.buf.WriteByte('[')
+		for i, item := range n.Items {
+			if i > 0 {
+				w.buf.WriteString(", ")
+			}
+			if err := w.value(item); err != nil {
+				return err
+			}
+		}
+		w.buf.WriteByte(']')
+	case *yay.ObjectNode:
+		if len(n.Properties) == 0 {
+			w.buf.WriteString("{}")
+			break
+		}
+		w.buf.WriteString("{ ")
+		for i, p := range sortedProperties(n) {
+			if i > 0 {
+				w.buf.WriteString(", ")
+			}
+			w.buf.WriteString(tomlKey(p.Key) + " = ")
+			if err := w.value(p.Value); err != nil {
+				return err
+			}
+		}
+		w.buf.WriteString(" }")
+	}
+	return nil
+}
+
+// tomlKey returns key bare if it can be, or else quoted.
+func tomlKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if !isBareKey(key[i]) {
+			return tomlString(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// tomlString returns s as a basic string, escaping the quote, the
+// backslash, and control characters, in triple quotes with its line breaks
+// as they are if it has any.
+func tomlString(s string) string {
+	multiline := strings.Contains(s, "\n")
+	var b strings.Builder
+	if multiline {
+		b.WriteString(`"""` + "\n")
+	} else {
+		b.WriteByte('"')
+	}
+	for _, c := range s {
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case '\n':
+			if multiline {
+				b.WriteByte('\n')
+			} else {
+				b.WriteString(`\n`)
+			}
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	if multiline {
+		b.WriteString(`"""`)
+	} else {
+		b.WriteByte('"')
+	}
+	return b.String()
+}
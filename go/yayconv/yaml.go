@@ -0,0 +1,1319 @@
+package yayconv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"kriskowal.com/go/yay"
+)
+
+// ============================================================================
+// YAML
+// ============================================================================
+//
+// FromYAML reads YAML 1.2 without a YAML library: block and flow
+// collections, the five kinds of scalar, comments, and one document, with
+// plain scalars resolved by the core schema. What YAML has and YAY lacks
+// is flagged: anchors and aliases are expanded, tags other than the core
+// ones dropped, and keys that are not strings written as the strings they
+// were spelled with. Complex keys, those that are collections, cannot be
+// converted at all. The merge key "<<" of YAML 1.1 is a key like any
+// other, as YAML 1.2 has it, unless Options.MergeKeys asks for merging.
+
+// maxValues bounds the values FromYAML writes, so that aliases of aliases
+// cannot expand a small document into an enormous one.
+const maxValues = 1 << 20
+
+// FromYAML returns the YAY encoding of a YAML document, as yay.Marshal
+// writes it, with keys sorted. Comments are not kept.
+func FromYAML(yamlData []byte, opts Options) ([]byte, error) {
+	r := &yamlReader{src: yamlData, opts: opts, anchors: map[string]*yamlNode{}}
+	root, err := r.document()
+	if err != nil {
+		return nil, err
+	}
+	v, err := r.value(root)
+	if err != nil {
+		return nil, err
+	}
+	return yay.Marshal(plainMaps(v))
+}
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlSequence
+	yamlMapping
+	yamlAlias
+)
+
+// yamlNode is a node of a YAML document as written, before its scalars
+// are resolved to values.
+type yamlNode struct {
+	kind yamlKind
+	off  int // Where the node begins
+
+	tag    string // Resolved to its full name, or "!" or "" if none
+	tagOff int
+
+	text  string // A scalar's content, after escapes and folding
+	plain bool   // Whether a scalar was written without quotes or indicator
+
+	items []*yamlNode // A sequence's items, or a mapping's keys and values in turn
+	alias *yamlNode   // The node an alias names
+}
+
+// yamlReader parses a YAML document and converts it to a value.
+type yamlReader struct {
+	src  []byte
+	pos  int
+	opts Options
+
+	lines     []int // The offset at which each line begins
+	depth     int   // Of the collections being read or converted
+	anchors   map[string]*yamlNode
+	expanding int // The depth of aliases being expanded
+	values    int
+}
+
+// ----------------------------------------------------------------------------
+// Positions and reporting
+// ----------------------------------------------------------------------------
+
+// position returns the position of the byte offset off.
+func (r *yamlReader) position(off int) yay.Position {
+	start := bytes.LastIndexByte(r.src[:off], '\n') + 1
+	return yay.Position{
+		Filename: r.opts.Filename,
+		Line:     bytes.Count(r.src[:start], []byte{'\n'}) + 1,
+		Column:   utf8.RuneCount(r.src[start:off]) + 1,
+		Offset:   off,
+	}
+}
+
+// errorf returns a syntax error at offset off.
+func (r *yamlReader) errorf(off int, format string, args ...any) error {
+	return &yay.ParseError{Code: "yaml-syntax", Message: fmt.Sprintf(format, args...), Position: r.position(off)}
+}
+
+// flag reports a construct at offset off that YAY lacks.
+func (r *yamlReader) flag(off int, format string, args ...any) error {
+	return r.opts.flag("yaml-unmapped", r.position(off), format, args...)
+}
+
+// ----------------------------------------------------------------------------
+// Characters
+// ----------------------------------------------------------------------------
+
+// at returns the byte at offset i, or 0 past the end.
+func (r *yamlReader) at(i int) byte {
+	if i < len(r.src) {
+		return r.src[i]
+	}
+	return 0
+}
+
+// blankAt reports whether offset i holds a space, tab, line break, or the
+// end of the document, which end tokens.
+func (r *yamlReader) blankAt(i int) bool {
+	switch r.at(i) {
+	case 0, ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+func isFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+func (r *yamlReader) eof() bool {
+	return r.pos >= len(r.src)
+}
+
+// lineStart returns the offset at which the line holding off begins,
+// found among the line starts by binary search, so that the tokens of one
+// long line are not each scanned back to its beginning.
+func (r *yamlReader) lineStart(off int) int {
+	return r.lines[sort.SearchInts(r.lines, off+1)-1]
+}
+
+// column returns the column of offset off, counting bytes from 0, which
+// is the indentation of the token there if it begins its line.
+func (r *yamlReader) column(off int) int {
+	return off - r.lineStart(off)
+}
+
+// firstOnLine reports whether only spaces precede offset off on its line.
+func (r *yamlReader) firstOnLine(off int) bool {
+	for i := r.lineStart(off); i < off; i++ {
+		if r.src[i] != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+// atMarker reports whether the document marker "---" or "..." begins a
+// line at offset off.
+func (r *yamlReader) atMarker(off int) bool {
+	if r.column(off) != 0 || off+3 > len(r.src) {
+		return false
+	}
+	m := string(r.src[off : off+3])
+	return (m == "---" || m == "...") && r.blankAt(off+3)
+}
+
+// skipSpace skips spaces, line breaks, and comments. Tabs may separate
+// tokens but not indent them.
+func (r *yamlReader) skipSpace() error {
+	for !r.eof() {
+		switch c := r.src[r.pos]; c {
+		case ' ', '\r':
+			r.pos++
+		case '\t':
+			if r.firstOnLine(r.pos) {
+				end := r.pos
+				for r.at(end) == ' ' || r.at(end) == '\t' {
+					end++
+				}
+				if !r.blankAt(end) && r.at(end) != '#' {
+					return r.errorf(r.pos, "Tab not allowed in indentation (use spaces)")
+				}
+			}
+			r.pos++
+		case '\n':
+			r.pos++
+		case '#':
+			if r.pos > 0 && !r.blankAt(r.pos-1) {
+				return nil
+			}
+			for !r.eof() && r.src[r.pos] != '\n' {
+				r.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipInline skips spaces and tabs on the current line.
+func (r *yamlReader) skipInline() {
+	for r.at(r.pos) == ' ' || r.at(r.pos) == '\t' {
+		r.pos++
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Documents and block collections
+// ----------------------------------------------------------------------------
+
+// document parses the one document of the stream, with any directives.
+func (r *yamlReader) document() (*yamlNode, error) {
+	r.src = bytes.TrimPrefix(r.src, []byte("\xef\xbb\xbf"))
+	r.lines = []int{0}
+	for i, c := range r.src {
+		if c == '\n' {
+			r.lines = append(r.lines, i+1)
+		}
+	}
+	for {
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r.at(r.pos) != '%' || r.column(r.pos) != 0 {
+			break
+		}
+		end := r.pos
+		for end < len(r.src) && r.src[end] != '\n' {
+			end++
+		}
+		directive := strings.TrimSpace(string(r.src[r.pos:end]))
+		if strings.HasPrefix(directive, "%TAG") {
+			if err := r.flag(r.pos, "Ignored directive %s (YAY has no tags)", directive); err != nil {
+				return nil, err
+			}
+		}
+		r.pos = end
+	}
+	if r.atMarker(r.pos) && r.src[r.pos] == '-' {
+		r.pos += 3
+	}
+	root, err := r.node(-1, false, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.skipSpace(); err != nil {
+		return nil, err
+	}
+	if r.atMarker(r.pos) && r.src[r.pos] == '.' {
+		r.pos += 3
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+	if r.atMarker(r.pos) {
+		return nil, r.errorf(r.pos, "Unexpected second document (YAY has one per file)")
+	}
+	if !r.eof() {
+		return nil, r.errorf(r.pos, "Unexpected %s", r.describe(r.pos))
+	}
+	return root, nil
+}
+
+// enter begins a node, at offset off, nested one deeper than the one
+// being read or converted, failing past maxDepth, as deep nesting costs
+// stack out of proportion to the text. Each successful enter is paired
+// with a leave.
+func (r *yamlReader) enter(off int) error {
+	if r.depth >= maxDepth {
+		return r.errorf(off, "YAML nested too deep (limit %d)", maxDepth)
+	}
+	r.depth++
+	return nil
+}
+
+// leave ends a node begun by enter.
+func (r *yamlReader) leave() {
+	r.depth--
+}
+
+// describe names the token at offset off for an error.
+func (r *yamlReader) describe(off int) string {
+	c, _ := utf8.DecodeRune(r.src[off:])
+	return strconv.Quote(string(c))
+}
+
+// node parses a block node more indented than parent, or a block sequence
+// as indented as parent if sequenceAtParent, as the value of a mapping
+// may be. With compact, a block collection may begin on the line where
+// parsing does, as in a sequence item; otherwise only on a later one. A
+// node that is missing is an empty plain scalar, which resolves to null.
+func (r *yamlReader) node(parent int, sequenceAtParent, compact bool) (*yamlNode, error) {
+	if err := r.enter(r.pos); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	start := r.pos
+	if err := r.skipSpace(); err != nil {
+		return nil, err
+	}
+	var props yamlNode
+	propsLine := -1
+	for c := r.at(r.pos); c == '&' || c == '!'; c = r.at(r.pos) {
+		propsLine = r.lineStart(r.pos)
+		if err := r.properties(&props); err != nil {
+			return nil, err
+		}
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.IndexByte(r.src[start:r.pos], '\n') >= 0 {
+		compact = true
+	}
+
+	col := r.column(r.pos)
+	sequence := r.at(r.pos) == '-' && r.blankAt(r.pos+1)
+	if r.eof() || r.atMarker(r.pos) || col < parent || col == parent && !(sequenceAtParent && sequence) && r.firstOnLine(r.pos) {
+		return r.anchor(&yamlNode{kind: yamlScalar, off: r.pos, plain: true}, &props), nil
+	}
+	// Properties on the line of a mapping's first key belong to the key.
+	keyProps := propsLine >= 0 && propsLine == r.lineStart(r.pos)
+
+	var n *yamlNode
+	var err error
+	switch c := r.src[r.pos]; {
+	case sequence:
+		if !compact {
+			return nil, r.errorf(r.pos, "Unexpected sequence on the line of its key")
+		}
+		n, err = r.blockSequence(col)
+		return r.anchor(n, &props), err
+	case c == '?' && r.blankAt(r.pos+1):
+		return nil, r.errorf(r.pos, "Complex keys are not supported (YAY keys are strings)")
+	case c == '|' || c == '>':
+		n, err = r.blockScalar(parent)
+		return r.anchor(n, &props), err
+	case c == '[' || c == '{':
+		n, err = r.flowCollection()
+		if err != nil {
+			return nil, err
+		}
+		r.skipInline()
+		if r.at(r.pos) == ':' && r.blankAt(r.pos+1) {
+			return nil, r.errorf(n.off, "Complex keys are not supported (YAY keys are strings)")
+		}
+		return r.anchor(n, &props), nil
+	case c == '*':
+		n, err = r.alias()
+	case c == '"' || c == '\'':
+		n, err = r.quoted()
+	default:
+		n, err = r.plainLine(false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.skipInline()
+	if r.at(r.pos) == ':' && r.blankAt(r.pos+1) {
+		if !compact {
+			return nil, r.errorf(n.off, "Unexpected mapping on the line of its key")
+		}
+		if keyProps {
+			r.anchor(n, &props)
+			props = yamlNode{}
+		}
+		m, err := r.blockMapping(col, n)
+		return r.anchor(m, &props), err
+	}
+	if n.plain {
+		if err := r.plainLines(n, parent, false); err != nil {
+			return nil, err
+		}
+	}
+	return r.anchor(n, &props), nil
+}
+
+// properties parses an anchor or tag into props.
+func (r *yamlReader) properties(props *yamlNode) error {
+	start := r.pos
+	if r.src[r.pos] == '&' {
+		r.pos++
+		name := r.name()
+		if name == "" {
+			return r.errorf(start, "Expected anchor name")
+		}
+		if err := r.flag(start, "Dropped anchor &%s (YAY has no anchors)", name); err != nil {
+			return err
+		}
+		props.text = name
+		return nil
+	}
+	if r.at(r.pos+1) == '<' {
+		end := bytes.IndexByte(r.src[r.pos:], '>')
+		if end < 0 {
+			return r.errorf(start, "Unterminated tag")
+		}
+		r.pos += end + 1
+		props.tag = string(r.src[start+2 : r.pos-1])
+	} else {
+		for !r.blankAt(r.pos) && !isFlowIndicator(r.src[r.pos]) {
+			r.pos++
+		}
+		props.tag = string(r.src[start:r.pos])
+		if rest, ok := strings.CutPrefix(props.tag, "!!"); ok {
+			props.tag = "tag:yaml.org,2002:" + rest
+		} else if props.tag == "!binary" {
+			// As the other implementations read it.
+			props.tag = "tag:yaml.org,2002:binary"
+		}
+	}
+	props.tagOff = start
+	if _, ok := coreTags[props.tag]; !ok && props.tag != "!" {
+		return r.flag(start, "Dropped tag %s (YAY has no tags)", string(r.src[start:r.pos]))
+	}
+	return nil
+}
+
+// coreTags are the tags of the YAML core schema, and binary, which YAY
+// can express.
+var coreTags = map[string]struct{}{
+	"tag:yaml.org,2002:str":    {},
+	"tag:yaml.org,2002:int":    {},
+	"tag:yaml.org,2002:float":  {},
+	"tag:yaml.org,2002:bool":   {},
+	"tag:yaml.org,2002:null":   {},
+	"tag:yaml.org,2002:seq":    {},
+	"tag:yaml.org,2002:map":    {},
+	"tag:yaml.org,2002:binary": {},
+}
+
+// name returns the anchor or alias name at the current offset.
+func (r *yamlReader) name() string {
+	start := r.pos
+	for !r.blankAt(r.pos) && !isFlowIndicator(r.src[r.pos]) && !(r.src[r.pos] == ':' && r.blankAt(r.pos+1)) {
+		r.pos++
+	}
+	return string(r.src[start:r.pos])
+}
+
+// anchor gives n the tag and anchor of props, recording n under the
+// anchor's name for the aliases that follow.
+func (r *yamlReader) anchor(n *yamlNode, props *yamlNode) *yamlNode {
+	if n == nil {
+		return nil
+	}
+	if props.tag != "" {
+		n.tag, n.tagOff = props.tag, props.tagOff
+	}
+	if props.text != "" {
+		r.anchors[props.text] = n
+	}
+	return n
+}
+
+// alias parses an alias to a node anchored before it.
+func (r *yamlReader) alias() (*yamlNode, error) {
+	start := r.pos
+	r.pos++
+	name := r.name()
+	target, ok := r.anchors[name]
+	if !ok {
+		return nil, r.errorf(start, "Unknown anchor *%s", name)
+	}
+	if err := r.flag(start, "Expanded alias *%s (YAY has no aliases)", name); err != nil {
+		return nil, err
+	}
+	return &yamlNode{kind: yamlAlias, off: start, alias: target}, nil
+}
+
+// blockSequence parses a block sequence whose dashes are at column col.
+func (r *yamlReader) blockSequence(col int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlSequence, off: r.pos}
+	for {
+		r.pos++ // Past "-"
+		item, err := r.node(col, false, true)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r.eof() || r.atMarker(r.pos) {
+			return n, nil
+		}
+		if !r.firstOnLine(r.pos) {
+			return nil, r.errorf(r.pos, "Unexpected %s", r.describe(r.pos))
+		}
+		if c := r.column(r.pos); c < col {
+			return n, nil
+		} else if c > col {
+			return nil, r.errorf(r.pos, "Unexpected indent")
+		}
+		if r.src[r.pos] != '-' || !r.blankAt(r.pos+1) {
+			return n, nil
+		}
+	}
+}
+
+// blockMapping parses a block mapping whose keys are at column col, from
+// the colon after its first key.
+func (r *yamlReader) blockMapping(col int, key *yamlNode) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlMapping, off: key.off}
+	for {
+		r.pos++ // Past ":"
+		value, err := r.node(col, true, false)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, key, value)
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r.eof() || r.atMarker(r.pos) {
+			return n, nil
+		}
+		if !r.firstOnLine(r.pos) {
+			return nil, r.errorf(r.pos, "Unexpected %s", r.describe(r.pos))
+		}
+		if c := r.column(r.pos); c < col {
+			return n, nil
+		} else if c > col {
+			return nil, r.errorf(r.pos, "Unexpected indent")
+		}
+		if key, err = r.mappingKey(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// mappingKey parses the key of a block mapping entry through to its
+// colon.
+func (r *yamlReader) mappingKey() (*yamlNode, error) {
+	var props yamlNode
+	for c := r.at(r.pos); c == '&' || c == '!'; c = r.at(r.pos) {
+		if err := r.properties(&props); err != nil {
+			return nil, err
+		}
+		r.skipInline()
+	}
+	var n *yamlNode
+	var err error
+	switch c := r.at(r.pos); {
+	case c == '?' && r.blankAt(r.pos+1), c == '[', c == '{':
+		return nil, r.errorf(r.pos, "Complex keys are not supported (YAY keys are strings)")
+	case c == '-' && r.blankAt(r.pos+1):
+		return nil, r.errorf(r.pos, "Unexpected sequence in mapping")
+	case c == '*':
+		n, err = r.alias()
+	case c == '"' || c == '\'':
+		n, err = r.quoted()
+	default:
+		n, err = r.plainLine(false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.skipInline()
+	if r.at(r.pos) != ':' || !r.blankAt(r.pos+1) {
+		return nil, r.errorf(r.pos, "Expected colon after key")
+	}
+	return r.anchor(n, &props), nil
+}
+
+// ----------------------------------------------------------------------------
+// Flow collections
+// ----------------------------------------------------------------------------
+
+// flowCollection parses a flow sequence or mapping, which may span lines.
+func (r *yamlReader) flowCollection() (*yamlNode, error) {
+	start := r.pos
+	open := r.src[r.pos]
+	n := &yamlNode{kind: yamlSequence, off: start}
+	closing := byte(']')
+	if open == '{' {
+		n.kind, closing = yamlMapping, '}'
+	}
+	r.pos++
+	for {
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r.eof() || r.atMarker(r.pos) {
+			return nil, r.errorf(start, "Unterminated flow collection")
+		}
+		if r.src[r.pos] == closing {
+			r.pos++
+			return n, nil
+		}
+		if r.src[r.pos] == '?' && r.blankAt(r.pos+1) {
+			return nil, r.errorf(r.pos, "Complex keys are not supported (YAY keys are strings)")
+		}
+		entry, err := r.flowNode()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r.at(r.pos) == ':' {
+			r.pos++
+			value, err := r.flowNode()
+			if err != nil {
+				return nil, err
+			}
+			if open == '{' {
+				n.items = append(n.items, entry, value)
+			} else {
+				n.items = append(n.items, &yamlNode{kind: yamlMapping, off: entry.off, items: []*yamlNode{entry, value}})
+			}
+		} else if open == '{' {
+			n.items = append(n.items, entry, &yamlNode{kind: yamlScalar, off: r.pos, plain: true})
+		} else {
+			n.items = append(n.items, entry)
+		}
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		switch r.at(r.pos) {
+		case ',':
+			r.pos++
+		case closing:
+		default:
+			if r.eof() {
+				return nil, r.errorf(start, "Unterminated flow collection")
+			}
+			return nil, r.errorf(r.pos, "Expected \",\" or %q", string(closing))
+		}
+	}
+}
+
+// flowNode parses a node within a flow collection, which is an empty
+// plain scalar if missing.
+func (r *yamlReader) flowNode() (*yamlNode, error) {
+	if err := r.enter(r.pos); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	if err := r.skipSpace(); err != nil {
+		return nil, err
+	}
+	var props yamlNode
+	for c := r.at(r.pos); c == '&' || c == '!'; c = r.at(r.pos) {
+		if err := r.properties(&props); err != nil {
+			return nil, err
+		}
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+	var n *yamlNode
+	var err error
+	switch c := r.at(r.pos); {
+	case c == 0, c == ',', c == ']', c == '}', c == ':' && (r.blankAt(r.pos+1) || isFlowIndicator(r.at(r.pos+1))):
+		n = &yamlNode{kind: yamlScalar, off: r.pos, plain: true}
+	case c == '[' || c == '{':
+		n, err = r.flowCollection()
+	case c == '*':
+		n, err = r.alias()
+	case c == '"' || c == '\'':
+		n, err = r.quoted()
+	default:
+		if n, err = r.plainLine(true); err == nil {
+			err = r.plainLines(n, -1, true)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.anchor(n, &props), nil
+}
+
+// ----------------------------------------------------------------------------
+// Scalars
+// ----------------------------------------------------------------------------
+
+// plainLine parses the part of a plain scalar on the current line.
+func (r *yamlReader) plainLine(flow bool) (*yamlNode, error) {
+	start := r.pos
+	c := r.at(r.pos)
+	if strings.IndexByte("-?:", c) >= 0 {
+		next := r.at(r.pos + 1)
+		if r.blankAt(r.pos+1) || flow && isFlowIndicator(next) {
+			return nil, r.errorf(start, "Unexpected %s", r.describe(start))
+		}
+	} else if strings.IndexByte(",[]{}#&*!|>'\"%@`", c) >= 0 || r.blankAt(r.pos) {
+		return nil, r.errorf(start, "Unexpected %s", r.describe(start))
+	}
+	n := &yamlNode{kind: yamlScalar, off: start, plain: true}
+	n.text = r.plainText(flow)
+	return n, nil
+}
+
+// plainText scans plain scalar text to the end of the line, a comment, a
+// colon and space, or in flow, a flow indicator, leaving trailing spaces.
+func (r *yamlReader) plainText(flow bool) string {
+	start, end := r.pos, r.pos
+	for !r.eof() {
+		c := r.src[r.pos]
+		if c == '\n' || c == '\r' ||
+			c == ':' && (r.blankAt(r.pos+1) || flow && isFlowIndicator(r.at(r.pos+1))) ||
+			c == '#' && r.pos > start && r.blankAt(r.pos-1) ||
+			flow && isFlowIndicator(c) {
+			break
+		}
+		r.pos++
+		if c != ' ' && c != '\t' {
+			end = r.pos
+		}
+	}
+	r.pos = end
+	return string(r.src[start:end])
+}
+
+// plainLines continues plain scalar n onto the lines that follow, while
+// they are more indented than parent, folding each line break to a space
+// and keeping those of blank lines.
+func (r *yamlReader) plainLines(n *yamlNode, parent int, flow bool) error {
+	for {
+		save := r.pos
+		r.skipInline()
+		if r.at(r.pos) == '\r' {
+			r.pos++
+		}
+		if r.at(r.pos) != '\n' {
+			r.pos = save
+			return nil
+		}
+		breaks := 0
+		for r.at(r.pos) == '\n' {
+			r.pos++
+			breaks++
+			for r.at(r.pos) == ' ' || r.at(r.pos) == '\t' || r.at(r.pos) == '\r' {
+				r.pos++
+			}
+		}
+		c := r.at(r.pos)
+		if r.eof() || r.atMarker(r.lineStart(r.pos)) || c == '#' ||
+			!flow && r.indent(r.pos) <= parent ||
+			flow && (isFlowIndicator(c) || c == ':' && (r.blankAt(r.pos+1) || isFlowIndicator(r.at(r.pos+1)))) ||
+			!flow && c == ':' && r.blankAt(r.pos+1) {
+			r.pos = save
+			return nil
+		}
+		if breaks == 1 {
+			n.text += " "
+		} else {
+			n.text += strings.Repeat("\n", breaks-1)
+		}
+		n.text += r.plainText(flow)
+	}
+}
+
+// indent returns the count of spaces that begin the line holding off.
+func (r *yamlReader) indent(off int) int {
+	i := r.lineStart(off)
+	n := 0
+	for r.at(i+n) == ' ' {
+		n++
+	}
+	return n
+}
+
+// quoted parses a single- or double-quoted scalar, which may span lines.
+func (r *yamlReader) quoted() (*yamlNode, error) {
+	start := r.pos
+	quote := r.src[r.pos]
+	r.pos++
+	var b strings.Builder
+	kept := 0 // The length of b that trailing space trimming must keep
+	for {
+		if r.eof() {
+			return nil, r.errorf(start, "Unterminated string")
+		}
+		c := r.src[r.pos]
+		switch {
+		case c == quote && quote == '\'' && r.at(r.pos+1) == '\'':
+			b.WriteByte('\'')
+			r.pos += 2
+			kept = b.Len()
+		case c == quote:
+			r.pos++
+			return &yamlNode{kind: yamlScalar, off: start, text: b.String()}, nil
+		case c == '\\' && quote == '"':
+			if r.at(r.pos+1) == '\n' || r.at(r.pos+1) == '\r' && r.at(r.pos+2) == '\n' {
+				r.pos++
+				if err := r.fold(&b, start, true); err != nil {
+					return nil, err
+				}
+				kept = b.Len()
+				continue
+			}
+			if err := r.escape(&b); err != nil {
+				return nil, err
+			}
+			kept = b.Len()
+		case c == '\n' || c == '\r' && r.at(r.pos+1) == '\n':
+			s := b.String()
+			trimmed := strings.TrimRight(s[kept:], " \t")
+			b.Reset()
+			b.WriteString(s[:kept] + trimmed)
+			if err := r.fold(&b, start, false); err != nil {
+				return nil, err
+			}
+			kept = b.Len()
+		default:
+			b.WriteByte(c)
+			r.pos++
+			if c != ' ' && c != '\t' {
+				kept = b.Len()
+			}
+		}
+	}
+}
+
+// fold writes the line breaks from the current offset, within a quoted
+// scalar that begins at start, as YAML folds them: one break as a space,
+// unless escaped, and each that follows as a line feed. It skips the
+// indentation of the line that continues the scalar.
+func (r *yamlReader) fold(b *strings.Builder, start int, escaped bool) error {
+	breaks := 0
+	for {
+		if r.at(r.pos) == '\r' {
+			r.pos++
+		}
+		if r.at(r.pos) != '\n' {
+			break
+		}
+		r.pos++
+		breaks++
+		if r.atMarker(r.pos) {
+			return r.errorf(start, "Unterminated string")
+		}
+		for r.at(r.pos) == ' ' || r.at(r.pos) == '\t' {
+			r.pos++
+		}
+	}
+	switch {
+	case breaks > 1:
+		b.WriteString(strings.Repeat("\n", breaks-1))
+	case !escaped:
+		b.WriteByte(' ')
+	}
+	return nil
+}
+
+// escapes are the single-character escapes of double-quoted scalars.
+var escapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': " ", 'L': " ",
+	'P': " ",
+}
+
+// escape writes the escape sequence at the current offset to b.
+func (r *yamlReader) escape(b *strings.Builder) error {
+	start := r.pos
+	c := r.at(r.pos + 1)
+	if s, ok := escapes[c]; ok {
+		b.WriteString(s)
+		r.pos += 2
+		return nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if digits == 0 || r.pos+2+digits > len(r.src) {
+		return r.errorf(start, "Invalid escape sequence")
+	}
+	code, err := strconv.ParseUint(string(r.src[r.pos+2:r.pos+2+digits]), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return r.errorf(start, "Invalid escape sequence")
+	}
+	b.WriteRune(rune(code))
+	r.pos += 2 + digits
+	return nil
+}
+
+// blockScalar parses a literal or folded block scalar, whose lines are
+// more indented than parent.
+func (r *yamlReader) blockScalar(parent int) (*yamlNode, error) {
+	start := r.pos
+	folded := r.src[r.pos] == '>'
+	r.pos++
+	chomp, indent := byte(0), 0
+	for i := 0; i < 2; i++ {
+		switch c := r.at(r.pos); {
+		case (c == '+' || c == '-') && chomp == 0:
+			chomp = c
+			r.pos++
+		case c >= '1' && c <= '9' && indent == 0:
+			indent = max(parent, 0) + int(c-'0')
+			r.pos++
+		}
+	}
+	r.skipInline()
+	if r.at(r.pos) == '#' && r.blankAt(r.pos-1) {
+		for !r.eof() && r.src[r.pos] != '\n' {
+			r.pos++
+		}
+	}
+	if r.at(r.pos) == '\r' {
+		r.pos++
+	}
+	if !r.eof() && r.src[r.pos] != '\n' {
+		return nil, r.errorf(r.pos, "Expected newline after block scalar indicator")
+	}
+
+	// Each line of content, after its indentation, and the count of
+	// blank lines that end the scalar.
+	var lines []string
+	trailing := 0
+	for !r.eof() {
+		lineStart := r.pos + 1
+		end := lineStart
+		for end < len(r.src) && r.src[end] != '\n' {
+			end++
+		}
+		line := strings.TrimSuffix(string(r.src[lineStart:end]), "\r")
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.TrimSpace(line) == "" && (indent == 0 || spaces <= indent) {
+			if lineStart >= len(r.src) {
+				break
+			}
+			trailing++
+			r.pos = end
+			continue
+		}
+		if indent == 0 {
+			if spaces <= parent || r.atMarker(lineStart) {
+				break
+			}
+			indent = spaces
+		}
+		if spaces < indent || r.atMarker(lineStart) {
+			break
+		}
+		for ; trailing > 0; trailing-- {
+			lines = append(lines, "")
+		}
+		lines = append(lines, line[indent:])
+		r.pos = end
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		prev, blanks := "", 0
+		for _, line := range lines {
+			if line == "" {
+				blanks++
+				continue
+			}
+			moreIndented := line[0] == ' ' || line[0] == '\t' || prev != "" && (prev[0] == ' ' || prev[0] == '\t')
+			switch {
+			case prev == "":
+				b.WriteString(strings.Repeat("\n", blanks))
+			case blanks == 0 && !moreIndented:
+				b.WriteByte(' ')
+			default:
+				b.WriteString(strings.Repeat("\n", blanks))
+				if moreIndented {
+					b.WriteByte('\n')
+				}
+			}
+			b.WriteString(line)
+			prev, blanks = line, 0
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case chomp == '-':
+	case chomp == '+':
+		if len(lines) > 0 {
+			text += "\n"
+		}
+		text += strings.Repeat("\n", trailing)
+	case len(lines) > 0:
+		text += "\n"
+	}
+	return &yamlNode{kind: yamlScalar, off: start, text: text}, nil
+}
+
+// ----------------------------------------------------------------------------
+// Values
+// ----------------------------------------------------------------------------
+
+// value returns the YAY value of node n, with mappings as
+// *yay.OrderedMap.
+func (r *yamlReader) value(n *yamlNode) (any, error) {
+	if r.values++; r.values > maxValues {
+		return nil, r.errorf(n.off, "Aliases expand to more than %d values", maxValues)
+	}
+	// Aliases within the nodes they name nest deeper than the document.
+	if err := r.enter(n.off); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	switch n.kind {
+	case yamlAlias:
+		r.expanding++
+		v, err := r.value(n.alias)
+		r.expanding--
+		return v, err
+	case yamlSequence:
+		if n.tag != "" && n.tag != "!" && n.tag != "tag:yaml.org,2002:seq" {
+			if _, ok := coreTags[n.tag]; ok {
+				return nil, r.errorf(n.tagOff, "Tag does not apply to a sequence")
+			}
+		}
+		items := make([]any, len(n.items))
+		for i, item := range n.items {
+			v, err := r.value(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = v
+		}
+		return items, nil
+	case yamlMapping:
+		if n.tag != "" && n.tag != "!" && n.tag != "tag:yaml.org,2002:map" {
+			if _, ok := coreTags[n.tag]; ok {
+				return nil, r.errorf(n.tagOff, "Tag does not apply to a mapping")
+			}
+		}
+		return r.mapping(n)
+	}
+	return r.scalar(n)
+}
+
+// mapping returns the YAY object of mapping n, expanding merge keys if
+// the options call for it.
+func (r *yamlReader) mapping(n *yamlNode) (*yay.OrderedMap, error) {
+	obj := yay.NewOrderedMap()
+	explicit := map[string]bool{}
+	var keys []string
+	for i := 0; i < len(n.items); i += 2 {
+		if isMergeKey(n.items[i]) {
+			if r.opts.MergeKeys {
+				keys = append(keys, "")
+				continue
+			}
+			if r.expanding == 0 {
+				if err := r.flag(n.items[i].off, "\"<<\" is a key in YAML 1.2 but a merge key in YAML 1.1"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		key, err := r.key(n.items[i])
+		if err != nil {
+			return nil, err
+		}
+		if explicit[key] && r.expanding == 0 {
+			if err := r.flag(n.items[i].off, "Duplicate key %q (the last value is kept)", key); err != nil {
+				return nil, err
+			}
+		}
+		explicit[key] = true
+		keys = append(keys, key)
+	}
+	for i := 0; i < len(n.items); i += 2 {
+		k, v := n.items[i], n.items[i+1]
+		if r.opts.MergeKeys && isMergeKey(k) {
+			if err := r.merge(obj, explicit, k, v); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		value, err := r.value(v)
+		if err != nil {
+			return nil, err
+		}
+		obj.Set(keys[i/2], value)
+	}
+	return obj, nil
+}
+
+// isMergeKey reports whether key is the merge key "<<".
+func isMergeKey(key *yamlNode) bool {
+	return key.kind == yamlScalar && key.plain && key.text == "<<" &&
+		(key.tag == "" || key.tag == "tag:yaml.org,2002:merge")
+}
+
+// merge sets in obj the properties of the mappings that merge key k
+// names in v, but for the keys that the mapping gives explicitly or that
+// an earlier mapping merged.
+func (r *yamlReader) merge(obj *yay.OrderedMap, explicit map[string]bool, k, v *yamlNode) error {
+	if r.expanding == 0 {
+		if err := r.flag(k.off, "Expanded merge key \"<<\" (YAY has no merge keys)"); err != nil {
+			return err
+		}
+	}
+	sources := []*yamlNode{v}
+	if target := resolveAlias(v); target.kind == yamlSequence {
+		sources = target.items
+	}
+	for _, source := range sources {
+		if resolveAlias(source).kind != yamlMapping {
+			return r.errorf(source.off, "Expected mapping to merge")
+		}
+		r.expanding++
+		value, err := r.value(source)
+		r.expanding--
+		if err != nil {
+			return err
+		}
+		for _, m := range value.(*yay.OrderedMap).Members() {
+			if _, ok := obj.Get(m.Key); !ok && !explicit[m.Key] {
+				obj.Set(m.Key, m.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveAlias returns the node that n names, if an alias, or n.
+func resolveAlias(n *yamlNode) *yamlNode {
+	for n.kind == yamlAlias {
+		n = n.alias
+	}
+	return n
+}
+
+// key returns the string of key node n, flagging a key that YAML reads as
+// some other kind of value.
+func (r *yamlReader) key(n *yamlNode) (string, error) {
+	target := resolveAlias(n)
+	if target.kind != yamlScalar {
+		return "", r.errorf(n.off, "Complex keys are not supported (YAY keys are strings)")
+	}
+	v, err := r.scalar(target)
+	if err != nil {
+		return "", err
+	}
+	var kind string
+	switch v.(type) {
+	case string:
+		return target.text, nil
+	case nil:
+		kind = "null"
+	case bool:
+		kind = "a boolean"
+	case *big.Int:
+		kind = "an integer"
+	case float64:
+		kind = "a float"
+	case []byte:
+		kind = "a byte array"
+	}
+	if r.expanding == 0 {
+		if err := r.flag(n.off, "Key %q is %s in YAML (YAY keys are strings)", target.text, kind); err != nil {
+			return "", err
+		}
+	}
+	return target.text, nil
+}
+
+// scalar returns the value of scalar n, resolving plain scalars by the
+// core schema and honoring core tags.
+func (r *yamlReader) scalar(n *yamlNode) (any, error) {
+	switch n.tag {
+	case "":
+		if n.plain {
+			return r.resolve(n)
+		}
+		return n.text, nil
+	case "!", "tag:yaml.org,2002:str":
+		return n.text, nil
+	case "tag:yaml.org,2002:null":
+		return nil, nil
+	case "tag:yaml.org,2002:bool":
+		switch n.text {
+		case "true", "True", "TRUE":
+			return true, nil
+		case "false", "False", "FALSE":
+			return false, nil
+		}
+	case "tag:yaml.org,2002:int":
+		if v, ok := parseInt(n.text); ok {
+			return v, nil
+		}
+	case "tag:yaml.org,2002:float":
+		if v, ok := parseFloat(n.text); ok {
+			return v, nil
+		}
+		if v, ok := parseInt(n.text); ok {
+			f, _ := new(big.Float).SetInt(v).Float64()
+			return f, nil
+		}
+	case "tag:yaml.org,2002:binary":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.text), ""))
+		if err == nil {
+			return data, nil
+		}
+	case "tag:yaml.org,2002:seq", "tag:yaml.org,2002:map":
+		return nil, r.errorf(n.tagOff, "Tag does not apply to a scalar")
+	default:
+		if n.plain {
+			return r.resolve(n)
+		}
+		return n.text, nil
+	}
+	return nil, r.errorf(n.off, "Invalid %s", strings.TrimPrefix(n.tag, "tag:yaml.org,2002:"))
+}
+
+// resolve returns the value of untagged plain scalar n by the YAML 1.2
+// core schema, flagging the scalars YAML 1.1 read otherwise.
+func (r *yamlReader) resolve(n *yamlNode) (any, error) {
+	s := n.text
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if v, ok := parseInt(s); ok {
+		if u := strings.TrimLeft(s, "+-"); len(u) > 1 && u[0] == '0' && isDecimal(u) && r.expanding == 0 {
+			if err := r.flag(n.off, "%s is decimal in YAML 1.2 but octal in YAML 1.1", s); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+	if v, ok := parseFloat(s); ok {
+		return v, nil
+	}
+	switch strings.ToLower(s) {
+	case "yes", "no", "on", "off":
+		if r.expanding == 0 {
+			if err := r.flag(n.off, "%s is a string in YAML 1.2 but a boolean in YAML 1.1", s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// parseInt parses an integer of the core schema: decimal with an optional
+// sign, or 0o octal or 0x hexadecimal.
+func parseInt(s string) (*big.Int, bool) {
+	base, digits := 10, s
+	switch {
+	case strings.HasPrefix(s, "0o"):
+		base, digits = 8, s[2:]
+	case strings.HasPrefix(s, "0x"):
+		base, digits = 16, s[2:]
+	default:
+		digits = strings.TrimPrefix(strings.TrimPrefix(digits, "+"), "-")
+		if !isDecimal(digits) {
+			return nil, false
+		}
+		digits = strings.TrimPrefix(s, "+")
+	}
+	if digits == "" || strings.ContainsAny(digits, "_+") {
+		return nil, false
+	}
+	return new(big.Int).SetString(digits, base)
+}
+
+// isDecimal reports whether s is a nonempty run of decimal digits.
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFloat parses a float of the core schema, including .inf and .nan.
+func parseFloat(s string) (float64, bool) {
+	unsigned := strings.TrimLeft(s, "+-")
+	if len(s)-len(unsigned) > 1 {
+		return 0, false
+	}
+	switch unsigned {
+	case ".inf", ".Inf", ".INF":
+		if s[0] == '-' {
+			return math.Inf(-1), true
+		}
+		return math.Inf(1), true
+	case ".nan", ".NaN", ".NAN":
+		if s == unsigned {
+			return math.NaN(), true
+		}
+		return 0, false
+	}
+	// Digits, with at most one point, and at least one digit, before an
+	// optional exponent.
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(unsigned), "e")
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	if whole == "" && fraction == "" || whole != "" && !isDecimal(whole) || fraction != "" && !isDecimal(fraction) {
+		return 0, false
+	}
+	if hasExponent && !isDecimal(strings.TrimLeft(exponent, "+-")) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && f == 0 {
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,76 @@
+// Package yayconv converts documents between YAY and other formats, so
+// that existing configuration can be migrated mechanically rather than by
+// hand.
+//
+//	out, err := yayconv.FromYAML(data, yayconv.Options{
+//		Filename: "app.yaml",
+//		Warn: func(w yay.Warning) {
+//			log.Print(w)
+//		},
+//	})
+//
+// What one format has and the other lacks, such as the anchors and tags
+// of YAML, or the big integers of YAY in MessagePack, is converted as
+// nearly as the other allows, with a warning for each place where the
+// document means something that cannot be said, so that its authors can
+// check what changed. With Strict, each is an error instead. TOML is the
+// exception, where what it lacks is an error, as in the other
+// implementations.
+package yayconv
+
+import (
+	"fmt"
+
+	"kriskowal.com/go/yay"
+)
+
+// Options configures the conversions.
+type Options struct {
+	// Filename, if set, is given in the positions of errors and warnings.
+	Filename string
+
+	// Warn, if set, is called with a Warning for each construct the
+	// document uses that the other format lacks and that the conversion
+	// approximates.
+	Warn func(yay.Warning)
+
+	// Strict makes each such construct an error instead.
+	Strict bool
+
+	// MergeKeys makes FromYAML expand the merge keys of YAML 1.1, "<<",
+	// into the properties of the mappings they name, rather than keep
+	// them as keys, as YAML 1.2 does.
+	MergeKeys bool
+}
+
+// flag reports a construct at p that the format converted to lacks, as a
+// warning or, with Strict, as an error with code.
+func (opts Options) flag(code string, p yay.Position, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if opts.Strict {
+		return &yay.ParseError{Code: code, Message: msg, Position: p}
+	}
+	if opts.Warn != nil {
+		opts.Warn(yay.Warning{Message: msg, Filename: p.Filename, Line: p.Line, Column: p.Column})
+	}
+	return nil
+}
+
+// plainMaps returns v with its ordered maps as maps, which yay.Marshal
+// writes with their keys sorted, as the other implementations write the
+// documents they convert.
+func plainMaps(v any) any {
+	switch v := v.(type) {
+	case *yay.OrderedMap:
+		m := v.Map()
+		for k, x := range m {
+			m[k] = plainMaps(x)
+		}
+		return m
+	case []any:
+		for i, x := range v {
+			v[i] = plainMaps(x)
+		}
+	}
+	return v
+}
@@ -0,0 +1,622 @@
+package yayconv_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/yayconv"
+)
+
+func TestFromYAML(t *testing.T) {
+	for _, test := range []struct {
+		yaml, want string
+		mergeKeys  bool
+		warnings   []string
+	}{
+		{
+			yaml: "name: app\nport: 8080\nratio: 1.5e3\nenabled: true\nnothing: ~\n",
+			want: "enabled: true\nname: \"app\"\nnothing: null\nport: 8080\nratio: 1500.0\n",
+		},
+		{
+			yaml: "servers:\n- host: a\n  port: 1\n- host: b\n  tags: [x, 'y', \"z\"]\n",
+			want: "servers:\n  - {host: \"a\", port: 1}\n  - host: \"b\"\n    tags: [\"x\", \"y\", \"z\"]\n",
+		},
+		{
+			yaml: "literal: |\n  one\n  two\n\nfolded: >-\n  three\n  four\n\n  five\nplain: six\n  seven\n",
+			want: "{folded: \"three four\\nfive\", literal: \"one\\ntwo\\n\", plain: \"six seven\"}\n",
+		},
+		{
+			yaml: "--- # config\n{a: 0x1f, b: 0o17, c: [.inf, -.inf], \"d\": 'it''s \\n'}\n...\n",
+			want: "a: 31\nb: 15\nc: [infinity, -infinity]\nd: \"it's \\\\n\"\n",
+		},
+		{
+			yaml: "big: 123456789012345678901234567890\nescaped: \"caf\\u00e9\\t\\x21\"\ndata: !!binary aGVsbG8=\nquoted: !!str 1\n",
+			want: "big: 123456789012345678901234567890\ndata: <68656c6c6f>\nescaped: \"café\\t!\"\nquoted: \"1\"\n",
+		},
+		{
+			yaml: "base: &base\n  x: 1\n  y: 2\nderived:\n  <<: *base\n  y: 3\n",
+			want: "base: {x: 1, y: 2}\nderived:\n  \"<<\": {x: 1, y: 2}\n  y: 3\n",
+			warnings: []string{
+				"Dropped anchor &base (YAY has no anchors) at 1:7 of <app.yaml>",
+				"Expanded alias *base (YAY has no aliases) at 5:7 of <app.yaml>",
+				"\"<<\" is a key in YAML 1.2 but a merge key in YAML 1.1 at 5:3 of <app.yaml>",
+			},
+		},
+		{
+			yaml:      "base: &base\n  x: 1\n  y: 2\nderived:\n  <<: *base\n  y: 3\n",
+			mergeKeys: true,
+			want:      "base: {x: 1, y: 2}\nderived: {x: 1, y: 3}\n",
+			warnings: []string{
+				"Dropped anchor &base (YAY has no anchors) at 1:7 of <app.yaml>",
+				"Expanded alias *base (YAY has no aliases) at 5:7 of <app.yaml>",
+				"Expanded merge key \"<<\" (YAY has no merge keys) at 5:3 of <app.yaml>",
+			},
+		},
+		{
+			yaml: "1: one\non: push\nmode: 0755\nwhen: !date 2024-01-01\nk: 1\nk: 2\n",
+			want: "1: \"one\"\nk: 2\nmode: 755\non: \"push\"\nwhen: \"2024-01-01\"\n",
+			warnings: []string{
+				"Dropped tag !date (YAY has no tags) at 4:7 of <app.yaml>",
+				"Key \"1\" is an integer in YAML (YAY keys are strings) at 1:1 of <app.yaml>",
+				"on is a string in YAML 1.2 but a boolean in YAML 1.1 at 2:1 of <app.yaml>",
+				"Duplicate key \"k\" (the last value is kept) at 6:1 of <app.yaml>",
+				"0755 is decimal in YAML 1.2 but octal in YAML 1.1 at 3:7 of <app.yaml>",
+			},
+		},
+		{
+			yaml: "",
+			want: "null\n",
+		},
+	} {
+		var warnings []string
+		got, err := yayconv.FromYAML([]byte(test.yaml), yayconv.Options{
+			Filename:  "app.yaml",
+			Warn:      func(w yay.Warning) { warnings = append(warnings, w.String()) },
+			MergeKeys: test.mergeKeys,
+		})
+		if err != nil {
+			t.Errorf("%q: %v", test.yaml, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%q: got %q, want %q", test.yaml, got, test.want)
+		}
+		if strings.Join(warnings, "\n") != strings.Join(test.warnings, "\n") {
+			t.Errorf("%q: got warnings %q, want %q", test.yaml, warnings, test.warnings)
+		}
+		if _, err := yay.Unmarshal(got); err != nil {
+			t.Errorf("%q: wrote invalid YAY: %v", test.yaml, err)
+		}
+	}
+
+	for yaml, want := range map[string]string{
+		"a: b: c\n":          "Unexpected mapping on the line of its key at 1:4 of <app.yaml>",
+		"[a]: b\n":           "Complex keys are not supported (YAY keys are strings) at 1:1 of <app.yaml>",
+		"? a\n: b\n":         "Complex keys are not supported (YAY keys are strings) at 1:1 of <app.yaml>",
+		"a:\n\t- b\n":        "Tab not allowed in indentation (use spaces) at 2:1 of <app.yaml>",
+		"a: 1\n---\nb: 2\n":  "Unexpected second document (YAY has one per file) at 2:1 of <app.yaml>",
+		"a: *missing\n":      "Unknown anchor *missing at 1:4 of <app.yaml>",
+		"a: [1, 2\n":         "Unterminated flow collection at 1:4 of <app.yaml>",
+		"a: \"open\n":        "Unterminated string at 1:4 of <app.yaml>",
+		"a: 1\n  b: 2\n":     "Unexpected \":\" at 2:4 of <app.yaml>",
+		"a: [1]\n  b: 2\n":   "Unexpected indent at 2:3 of <app.yaml>",
+		"a: !!int one\n":     "Invalid int at 1:10 of <app.yaml>",
+		"a: &x [*x]\n":       "Unknown anchor *x at 1:8 of <app.yaml>",
+		"a: 1\nb\n":          "Expected colon after key at 2:2 of <app.yaml>",
+		"a: |x\n  b\n":       "Expected newline after block scalar indicator at 1:5 of <app.yaml>",
+		"- a\nb: 1\n":        "Unexpected \"b\" at 2:1 of <app.yaml>",
+		"a: \"\\q\"\n":       "Invalid escape sequence at 1:5 of <app.yaml>",
+		"a: [x y]: z\n":      "Complex keys are not supported (YAY keys are strings) at 1:4 of <app.yaml>",
+		"key: value # ok\n}": "Unexpected \"}\" at 2:1 of <app.yaml>",
+	} {
+		_, err := yayconv.FromYAML([]byte(yaml), yayconv.Options{Filename: "app.yaml"})
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: got error %v, want %s", yaml, err, want)
+		}
+	}
+}
+
+func TestFromYAMLLimits(t *testing.T) {
+	deep := strings.Repeat("[", 900) + strings.Repeat("]", 900)
+	for yaml, want := range map[string]string{
+		strings.Repeat("[", 100000) + strings.Repeat("]", 100000):      "YAML nested too deep (limit 1000) at 1:1001 of <app.yaml>",
+		strings.Repeat("- ", 1001) + "1\n":                             "YAML nested too deep (limit 1000) at 1:2000 of <app.yaml>",
+		"a: &a " + deep + "\nb: " + deep[:200] + "*a" + deep[900:1100]: "YAML nested too deep (limit 1000) at 1:805 of <app.yaml>",
+		// Each of the many collections is read once, not once for each
+		// that encloses it, before the missing bracket is found.
+		"[" + strings.Repeat("[a, {b: c}], ", 100000):            "Unterminated flow collection at 1:1 of <app.yaml>",
+		strings.Repeat("[", 999) + strings.Repeat("a, ", 100000): "Unterminated flow collection at 1:999 of <app.yaml>",
+	} {
+		_, err := yayconv.FromYAML([]byte(yaml), yayconv.Options{Filename: "app.yaml"})
+		if err == nil || err.Error() != want {
+			t.Errorf("%.20q: got error %v, want %s", yaml, err, want)
+		}
+	}
+	if _, err := yayconv.FromYAML([]byte(strings.Repeat("- ", 999)+"1\n"), yayconv.Options{}); err != nil {
+		t.Errorf("999 nested sequences: %v", err)
+	}
+}
+
+func TestFromYAMLStrict(t *testing.T) {
+	opts := yayconv.Options{Filename: "app.yaml", Strict: true}
+	if _, err := yayconv.FromYAML([]byte("a: 1\nb: [x, y]\n"), opts); err != nil {
+		t.Errorf("plain document: %v", err)
+	}
+	_, err := yayconv.FromYAML([]byte("a: &x 1\nb: *x\n"), opts)
+	if err == nil || err.Error() != "Dropped anchor &x (YAY has no anchors) at 1:4 of <app.yaml>" {
+		t.Errorf("anchor: got %v", err)
+	}
+	if yay.ErrorCode(err) != "yaml-unmapped" {
+		t.Errorf("anchor: got code %q", yay.ErrorCode(err))
+	}
+}
+
+// TestYAMLFixtures checks FromYAML against the fixtures shared with the
+// other implementations: the document for each YAML file in
+// test/from-yaml, or an .error file where the YAML is invalid.
+func TestYAMLFixtures(t *testing.T) {
+	for _, name := range fixtureNames(t, "from-yaml", ".yaml") {
+		data := readFixture(t, "from-yaml", name+".yaml")
+		got, err := yayconv.FromYAML(data, yayconv.Options{Filename: name + ".yaml"})
+		if hasFixture("from-yaml", name+".error") {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", name, got)
+			}
+			continue
+		}
+		if want := readFixture(t, "from-yaml", name+".yay"); err != nil || string(got) != string(want) {
+			t.Errorf("%s: got %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestFromTOML(t *testing.T) {
+	const toml = `title = "example" # a comment
+
+[owner]
+name = "Tom"
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+ports = [ 8000, 8001,
+  8002, ]
+limits = { cpu = 79.5, memory.max = 0x10 }
+path = 'C:\Users'
+motd = """
+Hello \
+  world"""
+
+[servers.alpha]
+ip = "10.0.0.1"
+
+[[products]]
+name = "Hammer"
+sku = 738_594_937
+
+[[products]]
+name = "Nail"
+ratio = -inf
+`
+	var warnings []string
+	got, err := yayconv.FromTOML([]byte(toml), yayconv.Options{
+		Filename: "app.toml",
+		Warn:     func(w yay.Warning) { warnings = append(warnings, w.String()) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `database:
+  limits:
+    cpu: 79.5
+    memory: {max: 16}
+  motd: "Hello world"
+  path: "C:\\Users"
+  ports: [8000, 8001, 8002]
+owner: {dob: "1979-05-27T07:32:00-08:00", name: "Tom"}
+products:
+  - {name: "Hammer", sku: 738594937}
+  - {name: "Nail", ratio: -infinity}
+servers:
+  alpha: {ip: "10.0.0.1"}
+title: "example"
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if want := []string{"Wrote date-time 1979-05-27T07:32:00-08:00 as a string (YAY has no dates) at 5:7 of <app.toml>"}; strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got warnings %q, want %q", warnings, want)
+	}
+
+	for toml, want := range map[string]string{
+		"a = 1\na = 2\n":             "Duplicate key a at 2:1 of <app.toml>",
+		"[a]\n[a]\n":                 "Table a is already defined at 2:1 of <app.toml>",
+		"a = {x = 1}\n[a.b]\n":       "Table a is already defined at 2:2 of <app.toml>",
+		"a = [1]\n[[a]]\n":           "Key a is already defined at 2:3 of <app.toml>",
+		"a = 1\nb.c = 2\n[a.d]\n":    "Key a is not a table at 3:2 of <app.toml>",
+		"a = 01\n":                   "Invalid value 01 at 1:5 of <app.toml>",
+		"a = 1_\n":                   "Invalid value 1_ at 1:5 of <app.toml>",
+		"a = \"open\n":               "Unterminated string at 1:5 of <app.toml>",
+		"a = [1, 2\n":                "Unterminated array at 1:5 of <app.toml>",
+		"a = {x = 1,\ny = 2}\n":      "Unterminated inline table at 1:5 of <app.toml>",
+		"a = 1 b = 2\n":              "Expected newline after value at 1:7 of <app.toml>",
+		"a\n":                        "Expected \"=\" after key at 1:2 of <app.toml>",
+		"a = \"\\q\"\n":              "Invalid escape sequence at 1:6 of <app.toml>",
+		"[a.b]\nc = 1\n[a]\nb.d = 2": "Table b is already defined at 4:1 of <app.toml>",
+	} {
+		_, err := yayconv.FromTOML([]byte(toml), yayconv.Options{Filename: "app.toml"})
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: got error %v, want %s", toml, err, want)
+		}
+	}
+}
+
+func TestToTOML(t *testing.T) {
+	const doc = `name: "app"
+ratio: 1.0
+server:
+  tls:
+    enabled: true
+  host: "localhost"
+plugins:
+  - name: "a"
+  - {}
+"odd key": [1, {y: "two\nlines", x: 2}]
+empty: {}
+`
+	got, err := yayconv.ToTOML([]byte(doc), yayconv.Options{Filename: "app.yay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `name = "app"
+"odd key" = [1, { x = 2, y = """
+two
+lines""" }]
+plugins = [{ name = "a" }, {}]
+ratio = 1.0
+
+[empty]
+
+[server]
+host = "localhost"
+
+[server.tls]
+enabled = true
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	back, err := yayconv.FromTOML(got, yayconv.Options{})
+	if err != nil {
+		t.Fatalf("reading back: %v", err)
+	}
+	if !strings.Contains(string(back), "server:\n  host: \"localhost\"\n  tls: {enabled: true}\n") {
+		t.Errorf("read back:\n%s", back)
+	}
+
+	for doc, want := range map[string]string{
+		"[1, 2]\n":                            "Expected object (a TOML document is a table) at 1:1 of <app.yay>",
+		"a: [1, null]\n":                      "Unexpected null (TOML has no null) at 1:8 of <app.yay>",
+		"a: null\n":                           "Unexpected null (TOML has no null) at 1:4 of <app.yay>",
+		"a: {b: <cafe>}\n":                    "Unexpected byte array (TOML has no binary data type) at 1:8 of <app.yay>",
+		"a: 123456789012345678901234567890\n": "Unexpected integer beyond 64 bits (TOML integers are 64-bit) at 1:4 of <app.yay>",
+	} {
+		_, err := yayconv.ToTOML([]byte(doc), yayconv.Options{Filename: "app.yay"})
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: got error %v, want %s", doc, err, want)
+		}
+		if yay.ErrorCode(err) != "toml-unmapped" {
+			t.Errorf("%q: got code %q", doc, yay.ErrorCode(err))
+		}
+	}
+}
+
+// TestTOMLFixtures checks the conversions against the fixtures shared with
+// the other implementations: the TOML for each test/yay document in
+// test/toml, or an .error file where the document has what TOML lacks,
+// and the document for each TOML file in test/from-toml, or an .error file
+// where the TOML is invalid. The documents are compared with the
+// encoding of their values, as the encoder that wrote them escapes "/"
+// and indents each item of a block array after the first further than
+// the first. That indentation nests the items after the first in an
+// array within the first, in every implementation's reading, so the
+// fixtures with a block array of arrays or objects are left out.
+func TestTOMLFixtures(t *testing.T) {
+	misindented := map[string]bool{"array-of-tables": true, "arrays": true}
+	for _, name := range fixtureNames(t, "yay", ".yay") {
+		source := readFixture(t, "yay", name+".yay")
+		got, err := yayconv.ToTOML(source, yayconv.Options{Filename: name + ".yay"})
+		if hasFixture("toml", name+".error") {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", name, got)
+			}
+			continue
+		}
+		if want := readFixture(t, "toml", name+".toml"); err != nil || string(got) != string(want) {
+			t.Errorf("%s: got %q, %v, want %q", name, got, err, want)
+		}
+	}
+	for _, name := range fixtureNames(t, "from-toml", ".toml") {
+		data := readFixture(t, "from-toml", name+".toml")
+		got, err := yayconv.FromTOML(data, yayconv.Options{Filename: name + ".toml"})
+		if hasFixture("from-toml", name+".error") {
+			if err == nil {
+				t.Errorf("from %s: got %q, want an error", name, got)
+			}
+			continue
+		}
+		if misindented[name] {
+			continue
+		}
+		value, werr := yay.Unmarshal(readFixture(t, "from-toml", name+".yay"))
+		if werr != nil {
+			t.Fatalf("from %s: %v", name, werr)
+		}
+		want, werr := yay.Marshal(value)
+		if werr != nil {
+			t.Fatalf("from %s: %v", name, werr)
+		}
+		if err != nil || string(got) != string(want) {
+			t.Errorf("from %s: got %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	for _, test := range []struct {
+		yay, cbor string
+	}{
+		{"0\n", "00"},
+		{"-1\n", "20"},
+		{"1000000\n", "1a000f4240"},
+		{"18446744073709551616\n", "c249010000000000000000"},
+		{"-18446744073709551617\n", "c349010000000000000000"},
+		{"1.5\n", "fb3ff8000000000000"},
+		{"1.1\n", "fb3ff199999999999a"},
+		{"infinity\n", "fb7ff0000000000000"},
+		{"nan\n", "fb7ff8000000000000"},
+		{"null\n", "f6"},
+		{"true\n", "f5"},
+		{"\"a\"\n", "6161"},
+		{"<01020304>\n", "4401020304"},
+		{"- 1\n- [2, 3]\n", "8201820203"},
+		{"a: [\"x\"]\nb: 1\n", "a26161816178616201"},
+	} {
+		got, err := yayconv.ToCBOR([]byte(test.yay), yayconv.Options{})
+		if err != nil {
+			t.Errorf("%q: %v", test.yay, err)
+			continue
+		}
+		if hex.EncodeToString(got) != test.cbor {
+			t.Errorf("%q: got %x, want %s", test.yay, got, test.cbor)
+		}
+		back, err := yayconv.FromCBOR(got, yayconv.Options{})
+		if err != nil {
+			t.Errorf("%s: %v", test.cbor, err)
+			continue
+		}
+		if string(back) != test.yay {
+			t.Errorf("%s: got %q, want %q", test.cbor, back, test.yay)
+		}
+	}
+
+	for _, test := range []struct {
+		cbor, yay string
+		warnings  []string
+	}{
+		{"f93e00", "1.5\n", nil},
+		{"f90001", "5.960464477539063e-8\n", nil},
+		{"7f6261626163ff", "\"abc\"\n", nil},
+		{"5f42010243030405ff", "<0102030405>\n", nil},
+		{"9f0102ff", "[1, 2]\n", nil},
+		{"bf6161f5ff", "{a: true}\n", nil},
+		{"c11a514b67b0", "1363896240\n", []string{"Dropped tag 1 (YAY has no tags) (byte offset 0)"}},
+		{"a201f7616102", "{1: null, a: 2}\n", []string{
+			"Key 1 is an integer in CBOR (YAY keys are strings) (byte offset 1)",
+			"Wrote undefined as null (YAY has no undefined) (byte offset 2)",
+		}},
+		{"a2616101616102", "{a: 2}\n", []string{"Duplicate key \"a\" (the last value is kept) (byte offset 4)"}},
+	} {
+		data, _ := hex.DecodeString(test.cbor)
+		var warnings []string
+		got, err := yayconv.FromCBOR(data, yayconv.Options{
+			Warn: func(w yay.Warning) { warnings = append(warnings, w.String()) },
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.cbor, err)
+			continue
+		}
+		if string(got) != test.yay {
+			t.Errorf("%s: got %q, want %q", test.cbor, got, test.yay)
+		}
+		if strings.Join(warnings, "\n") != strings.Join(test.warnings, "\n") {
+			t.Errorf("%s: got warnings %q, want %q", test.cbor, warnings, test.warnings)
+		}
+	}
+
+	for cbor, want := range map[string]string{
+		"":                                "Unexpected end of CBOR data (byte offset 0)",
+		"0001":                            "Unexpected data after the CBOR item (byte offset 1)",
+		"1a0001":                          "Unexpected end of CBOR data (byte offset 1)",
+		"5bffffffffffffffff":              "Unexpected end of CBOR data (byte offset 9)",
+		"62c328":                          "Invalid UTF-8 in CBOR text string (byte offset 0)",
+		"1f":                              "Invalid CBOR head 0x1f (byte offset 0)",
+		"ff":                              "Unexpected CBOR break (byte offset 0)",
+		"c26161":                          "Expected byte string in CBOR bignum (byte offset 1)",
+		"a18001":                          "Unsupported CBOR map key (YAY keys are strings) (byte offset 1)",
+		"7f4100ff":                        "Invalid chunk of indefinite-length CBOR string (byte offset 1)",
+		"f0":                              "Unsupported CBOR simple value 16 (byte offset 0)",
+		strings.Repeat("81", 1002) + "00": "CBOR nested too deep (limit 1000) (byte offset 1001)",
+	} {
+		data, _ := hex.DecodeString(cbor)
+		_, err := yayconv.FromCBOR(data, yayconv.Options{})
+		if err == nil || err.Error() != want {
+			t.Errorf("%.20s: got error %v, want %s", cbor, err, want)
+		}
+	}
+	_, err := yayconv.FromCBOR([]byte{0xc1, 0x00}, yayconv.Options{Strict: true})
+	if yay.ErrorCode(err) != "cbor-unmapped" {
+		t.Errorf("strict: got %v", err)
+	}
+}
+
+// TestCBORFixtures checks the conversions against the fixtures shared with
+// the other implementations: the encoding of each test/yay document in
+// test/cbor, and the document for each encoding in test/from-cbor, or an
+// .error file where the encoding has what YAY lacks. Such encodings are
+// converted with warnings unless Strict is set, so they are converted
+// with it.
+func TestCBORFixtures(t *testing.T) {
+	for _, name := range fixtureNames(t, "cbor", ".cbor") {
+		source := readFixture(t, "yay", name+".yay")
+		got, err := yayconv.ToCBOR(source, yayconv.Options{Filename: name + ".yay"})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if want := readFixture(t, "cbor", name+".cbor"); !bytes.Equal(got, want) {
+			t.Errorf("%s: got %x, want %x", name, got, want)
+		}
+	}
+	for _, name := range fixtureNames(t, "from-cbor", ".cbor") {
+		data := readFixture(t, "from-cbor", name+".cbor")
+		got, err := yayconv.FromCBOR(data, yayconv.Options{Strict: true})
+		if hasFixture("from-cbor", name+".error") {
+			if err == nil {
+				t.Errorf("from %s: got %q, want an error", name, got)
+			}
+			continue
+		}
+		if want := readFixture(t, "from-cbor", name+".yay"); err != nil || string(got) != string(want) {
+			t.Errorf("from %s: got %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+// fixtureNames returns the names of the fixtures in the directory dir of
+// the shared corpus whose files end with ext.
+func fixtureNames(t *testing.T, dir, ext string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("..", "..", "test", dir, "*"+ext))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures in test/%s: %v", dir, err)
+	}
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = strings.TrimSuffix(filepath.Base(path), ext)
+	}
+	return names
+}
+
+// readFixture returns the content of a file of the shared corpus.
+func readFixture(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// hasFixture reports whether the shared corpus has a file.
+func hasFixture(dir, name string) bool {
+	_, err := os.Stat(filepath.Join("..", "..", "test", dir, name))
+	return err == nil
+}
+
+func TestMsgPack(t *testing.T) {
+	for _, test := range []struct {
+		yay, msgpack string
+	}{
+		{"0\n", "00"},
+		{"-1\n", "ff"},
+		{"-33\n", "d0df"},
+		{"200\n", "ccc8"},
+		{"-40000\n", "d2ffff63c0"},
+		{"18446744073709551615\n", "cfffffffffffffffff"},
+		{"1.5\n", "ca3fc00000"},
+		{"1.1\n", "cb3ff199999999999a"},
+		{"null\n", "c0"},
+		{"false\n", "c2"},
+		{"\"a\"\n", "a161"},
+		{"<0102>\n", "c4020102"},
+		{"- 1\n- [2, 3]\n", "9201920203"},
+		{"b: 1\na: [\"x\"]\n", "82a16201a16191a178"},
+	} {
+		got, err := yayconv.ToMsgPack([]byte(test.yay), yayconv.Options{})
+		if err != nil {
+			t.Errorf("%q: %v", test.yay, err)
+			continue
+		}
+		if hex.EncodeToString(got) != test.msgpack {
+			t.Errorf("%q: got %x, want %s", test.yay, got, test.msgpack)
+		}
+		back, err := yayconv.FromMsgPack(got, yayconv.Options{})
+		if err != nil {
+			t.Errorf("%s: %v", test.msgpack, err)
+			continue
+		}
+		if string(back) != test.yay {
+			t.Errorf("%s: got %q, want %q", test.msgpack, back, test.yay)
+		}
+	}
+
+	var warnings []string
+	warn := yayconv.Options{
+		Filename: "app.yay",
+		Warn:     func(w yay.Warning) { warnings = append(warnings, w.String()) },
+	}
+	got, err := yayconv.ToMsgPack([]byte("123456789012345678901234567890\n"), warn)
+	if err != nil || hex.EncodeToString(got) != "be"+hex.EncodeToString([]byte("123456789012345678901234567890")) {
+		t.Errorf("big integer: got %x, %v", got, err)
+	}
+	if want := "Wrote integer beyond 64 bits as a string (MessagePack integers are 64-bit) at 1:1 of <app.yay>"; strings.Join(warnings, "\n") != want {
+		t.Errorf("big integer: got warnings %q, want %q", warnings, want)
+	}
+
+	for _, test := range []struct {
+		msgpack, yay string
+		warnings     []string
+	}{
+		{"d6ff5e0be100", "\"2020-01-01T00:00:00Z\"\n", []string{"Wrote timestamp 2020-01-01T00:00:00Z as a string (YAY has no dates) (byte offset 0)"}},
+		{"d5010102", "<0102>\n", []string{"Wrote extension type 1 as its data (YAY has no extensions) (byte offset 0)"}},
+		{"8201c0a16102", "{1: null, a: 2}\n", []string{"Key 1 is an integer in MessagePack (YAY keys are strings) (byte offset 1)"}},
+		{"d903616263", "\"abc\"\n", nil},
+		{"de0001a16101", "{a: 1}\n", nil},
+	} {
+		data, _ := hex.DecodeString(test.msgpack)
+		warnings = nil
+		got, err := yayconv.FromMsgPack(data, warn)
+		if err != nil {
+			t.Errorf("%s: %v", test.msgpack, err)
+			continue
+		}
+		if string(got) != test.yay {
+			t.Errorf("%s: got %q, want %q", test.msgpack, got, test.yay)
+		}
+		if strings.Join(warnings, "\n") != strings.Join(test.warnings, "\n") {
+			t.Errorf("%s: got warnings %q, want %q", test.msgpack, warnings, test.warnings)
+		}
+	}
+
+	for msgpack, want := range map[string]string{
+		"":                                "Unexpected end of MessagePack data (byte offset 0)",
+		"c1":                              "Invalid MessagePack format 0xc1 (byte offset 0)",
+		"0000":                            "Unexpected data after the MessagePack object (byte offset 1)",
+		"a2c328":                          "Invalid UTF-8 in MessagePack str (byte offset 0)",
+		"dbffffffff":                      "Unexpected end of MessagePack data (byte offset 5)",
+		"819000":                          "Unsupported MessagePack map key (YAY keys are strings) (byte offset 1)",
+		"d6ff00":                          "Unexpected end of MessagePack data (byte offset 2)",
+		"d4ff00":                          "Invalid MessagePack timestamp (byte offset 0)",
+		strings.Repeat("91", 1002) + "00": "MessagePack nested too deep (limit 1000) (byte offset 1001)",
+	} {
+		data, _ := hex.DecodeString(msgpack)
+		_, err := yayconv.FromMsgPack(data, yayconv.Options{})
+		if err == nil || err.Error() != want {
+			t.Errorf("%.20s: got error %v, want %s", msgpack, err, want)
+		}
+	}
+}
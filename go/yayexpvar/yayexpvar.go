@@ -0,0 +1,57 @@
+// Package yayexpvar publishes the measurements of YAY decoding with
+// expvar, so that a service can watch how its configuration loads from
+// /debug/vars.
+//
+//	var stats = yayexpvar.New("yay")
+//
+//	opts := yay.DecodeOptions{Recorder: stats}
+//
+// The published map holds counts of the documents decoded, the bytes
+// they held, the nanoseconds spent decoding them, and the errors among
+// them, with the errors also counted by code:
+//
+//	"yay": {"bytes": 5120, "documents": 12, "errors": 1,
+//		"errorsByCode": {"tab": 1}, "nanoseconds": 402113}
+//
+// It is a package of its own since importing expvar registers a handler
+// with http.DefaultServeMux, which not every program that reads YAY wants.
+package yayexpvar
+
+import (
+	"expvar"
+
+	"kriskowal.com/go/yay"
+)
+
+// Recorder is a yay.Recorder that counts what it records in an
+// expvar.Map. It may be used by several decoders at once.
+type Recorder struct {
+	m      *expvar.Map
+	byCode *expvar.Map
+}
+
+// New returns a Recorder whose counts are published under name. Like
+// expvar.NewMap, it panics if name is already published, as by an earlier
+// call to New with the same name, so it is meant to be called once per
+// name, from a package-level variable or an init function.
+func New(name string) *Recorder {
+	r := &Recorder{m: expvar.NewMap(name), byCode: new(expvar.Map).Init()}
+	r.m.Set("errorsByCode", r.byCode)
+	return r
+}
+
+// Map returns the map in which r keeps its counts.
+func (r *Recorder) Map() *expvar.Map {
+	return r.m
+}
+
+// RecordDecode counts the document s measures.
+func (r *Recorder) RecordDecode(s yay.DecodeStats) {
+	r.m.Add("documents", 1)
+	r.m.Add("bytes", int64(s.Bytes))
+	r.m.Add("nanoseconds", int64(s.Duration))
+	if s.Err != nil {
+		r.m.Add("errors", 1)
+		r.byCode.Add(s.Code, 1)
+	}
+}
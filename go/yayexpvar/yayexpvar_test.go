@@ -0,0 +1,42 @@
+//go:build !yay_tiny
+
+package yayexpvar_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/yayexpvar"
+)
+
+// runs counts the runs of TestRecorder, so that each publishes its counts
+// under a name of its own, as expvar allows a name only once.
+var runs int
+
+func TestRecorder(t *testing.T) {
+	runs++
+	r := yayexpvar.New("yaytest" + strconv.Itoa(runs))
+	opts := yay.DecodeOptions{Recorder: r}
+	for _, source := range []string{"a: 1\n", "b: [\n", "- 1\n- 2\n"} {
+		yay.UnmarshalWithOptions([]byte(source), opts)
+	}
+	var n int
+	if err := yay.NewDecoderWithOptions(strings.NewReader("\"x\"\n"), opts).Decode(&n); err == nil {
+		t.Fatal("decoded a string into an int")
+	}
+	m := r.Map()
+	for key, want := range map[string]string{
+		"documents": "4",
+		"bytes":     "22",
+		"errors":    "2",
+	} {
+		if got := m.Get(key).String(); got != want {
+			t.Errorf("%s: got %s, want %s", key, got, want)
+		}
+	}
+	if got, want := m.Get("errorsByCode").String(), `{"type": 1, "unterminated-inline-array": 1}`; got != want {
+		t.Errorf("errorsByCode: got %s, want %s", got, want)
+	}
+}
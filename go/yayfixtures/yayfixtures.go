@@ -0,0 +1,307 @@
+// Package yayfixtures generates Go test fixtures from a corpus of YAY
+// documents, so that tests can compare what a decoder gives for each
+// document against its expected value without reading files at test time.
+// It is the library behind this repository's own cmd/gen_fixtures, and
+// another repository can use it from a small generator of its own:
+//
+//	//go:build ignore
+//
+//	package main
+//
+//	func main() {
+//		fixtures, err := yayfixtures.Corpus{Dir: "testdata", Decode: true}.Fixtures()
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		var out bytes.Buffer
+//		if err := yayfixtures.Generate(&out, "config", "fixtures", fixtures); err != nil {
+//			log.Fatal(err)
+//		}
+//		if err := os.WriteFile("fixtures_gen_test.go", out.Bytes(), 0o644); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// run with a directive beside the tests:
+//
+//	//go:generate go run gen_fixtures.go
+package yayfixtures
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+// Fixture is the expected value of one document of a corpus.
+type Fixture struct {
+	Name string // The document's filename without ".yay"
+	Expr string // The value as a Go expression, as GoExpr writes it
+}
+
+// Corpus locates the documents of a corpus and their expected values. The
+// expected value of a document NAME.yay in Dir comes from GoDir/NAME.go,
+// holding a raw Go expression, not a full source file, or else from the
+// document itself, decoded, if Decode is set. A document with neither has
+// no fixture.
+type Corpus struct {
+	Dir    string // The documents
+	GoDir  string // Go expressions, or "" for none
+	Decode bool   // Whether a document without an expression is its own
+}
+
+// Fixtures returns the fixtures of the corpus, sorted by name.
+func (c Corpus) Fixtures() ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(c.Dir, "*.yay"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No .yay documents in %s", c.Dir)
+	}
+	var fixtures []Fixture
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".yay")
+		expr, ok, err := c.expectation(path, name)
+		if err != nil {
+			return nil, fmt.Errorf("Reading the expectation of %s: %w", name, err)
+		}
+		if ok {
+			fixtures = append(fixtures, Fixture{Name: name, Expr: expr})
+		}
+	}
+	sort.Slice(fixtures, func(i, j int) bool {
+		return fixtures[i].Name < fixtures[j].Name
+	})
+	return fixtures, nil
+}
+
+// expectation returns the expected value of the document at path as a Go
+// expression, if it has one.
+func (c Corpus) expectation(path, name string) (string, bool, error) {
+	if c.GoDir != "" {
+		src, err := os.ReadFile(filepath.Join(c.GoDir, name+".go"))
+		if err == nil {
+			return strings.TrimSpace(string(src)), true, nil
+		} else if !os.IsNotExist(err) {
+			return "", false, err
+		}
+	}
+	if c.Decode {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, err
+		}
+		v, err := yay.UnmarshalFile(data, filepath.Base(path))
+		if err != nil {
+			return "", false, err
+		}
+		return goExprOK(v)
+	}
+	return "", false, nil
+}
+
+// goExprOK is GoExpr for expectation.
+func goExprOK(v any) (string, bool, error) {
+	expr, err := GoExpr(v)
+	if err != nil {
+		return "", false, err
+	}
+	return expr, true, nil
+}
+
+// GoExpr returns v, a value as Unmarshal gives it, as a Go expression of
+// the same value. Integers are big.NewInt calls, or BigInt calls beyond
+// int64; NaN and the infinities are NaN, Inf, and NegInf; and maps are
+// written with their keys sorted. An array or object holding another
+// array or object is written an item to a line, indented by tabs, as gofmt
+// would. The names are those Generate declares.
+func GoExpr(v any) (string, error) {
+	var b strings.Builder
+	if err := writeExpr(&b, v, ""); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeExpr writes v to b as a Go expression, on a line indented by
+// indent.
+func writeExpr(b *strings.Builder, v any, indent string) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("nil")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		b.WriteString(strconv.Quote(v))
+	case *big.Int:
+		if v.IsInt64() {
+			fmt.Fprintf(b, "big.NewInt(%d)", v.Int64())
+		} else {
+			fmt.Fprintf(b, "BigInt(%q)", v.String())
+		}
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			b.WriteString("NaN")
+		case math.IsInf(v, 1):
+			b.WriteString("Inf")
+		case math.IsInf(v, -1):
+			b.WriteString("NegInf")
+		case v == 0 && math.Signbit(v):
+			b.WriteString("math.Copysign(0, -1)")
+		default:
+			// A constant without a point or exponent would be an int.
+			s := strconv.FormatFloat(v, 'g', -1, 64)
+			if !strings.ContainsAny(s, ".e") {
+				s += ".0"
+			}
+			b.WriteString(s)
+		}
+	case []byte:
+		b.WriteString("[]byte{")
+		for i, c := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "0x%02x", c)
+		}
+		b.WriteString("}")
+	case []any:
+		b.WriteString("[]any{")
+		inner, broken := itemIndent(v, indent)
+		for i, item := range v {
+			openItem(b, i, inner, broken)
+			if err := writeExpr(b, item, inner); err != nil {
+				return err
+			}
+		}
+		closeExpr(b, indent, broken)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]any, len(keys))
+		for i, k := range keys {
+			items[i] = v[k]
+		}
+		b.WriteString("map[string]any{")
+		inner, broken := itemIndent(items, indent)
+		for i, k := range keys {
+			openItem(b, i, inner, broken)
+			b.WriteString(strconv.Quote(k))
+			b.WriteString(": ")
+			if err := writeExpr(b, v[k], inner); err != nil {
+				return err
+			}
+		}
+		closeExpr(b, indent, broken)
+	default:
+		return fmt.Errorf("Unexpected value of type %T", v)
+	}
+	return nil
+}
+
+// itemIndent returns the indent of the lines of items in a composite
+// literal on a line indented by indent, and whether each has a line of its
+// own, as they do when one of them is an array or object.
+func itemIndent(items []any, indent string) (string, bool) {
+	for _, item := range items {
+		switch item.(type) {
+		case []any, map[string]any:
+			return indent + "\t", true
+		}
+	}
+	return indent, false
+}
+
+// openItem writes what goes before item i of a composite literal.
+func openItem(b *strings.Builder, i int, inner string, broken bool) {
+	switch {
+	case broken && i > 0:
+		b.WriteString(",\n" + inner)
+	case broken:
+		b.WriteString("\n" + inner)
+	case i > 0:
+		b.WriteString(", ")
+	}
+}
+
+// closeExpr ends a composite literal, after a comma if its items were on
+// lines of their own.
+func closeExpr(b *strings.Builder, indent string, broken bool) {
+	if broken {
+		b.WriteString(",\n" + indent)
+	}
+	b.WriteString("}")
+}
+
+// header is the start of a generated file, after its package clause. It
+// declares the helpers that GoExpr and the expressions of a Go corpus use.
+const header = `
+import (
+	"math"
+	"math/big"
+)
+
+// Helper functions for fixture expressions
+func NewInt(x int64) *big.Int { return big.NewInt(x) }
+
+func BigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func List(items ...any) []any { return items }
+
+func Map(kvs ...any) map[string]any {
+	m := make(map[string]any)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if k, ok := kvs[i].(string); ok {
+			m[k] = kvs[i+1]
+		}
+	}
+	return m
+}
+
+var (
+	Null   any     = nil
+	NaN            = math.NaN()
+	Inf            = math.Inf(1)
+	NegInf         = math.Inf(-1)
+)
+`
+
+// Generate writes a Go source file of package pkg to w, formatted as gofmt
+// formats it, declaring a map named name from the name of each fixture to
+// its value. The file also declares the helpers the expressions use:
+// NewInt, BigInt, List, Map, Null, NaN, Inf, and NegInf, which must not
+// otherwise be declared in the package.
+func Generate(w io.Writer, pkg, name string, fixtures []Fixture) error {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by go generate; DO NOT EDIT.\n\npackage %s\n", pkg)
+	src.WriteString(header)
+	fmt.Fprintf(&src, "\nvar %s = map[string]any{\n", name)
+	for _, f := range fixtures {
+		fmt.Fprintf(&src, "\t%q: %s,\n", f.Name, f.Expr)
+	}
+	src.WriteString("}\n")
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("Formatting the fixtures: %w", err)
+	}
+	_, err = w.Write(formatted)
+	return err
+}
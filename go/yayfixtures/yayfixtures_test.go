@@ -0,0 +1,97 @@
+package yayfixtures_test
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay/yayfixtures"
+)
+
+func TestGoExpr(t *testing.T) {
+	huge, _ := new(big.Int).SetString("99999999999999999999", 10)
+	for _, tt := range []struct {
+		value any
+		want  string
+	}{
+		{nil, "nil"},
+		{"a\n", `"a\n"`},
+		{big.NewInt(-7), "big.NewInt(-7)"},
+		{huge, `BigInt("99999999999999999999")`},
+		{2.0, "2.0"},
+		{math.Inf(-1), "NegInf"},
+		{math.Copysign(0, -1), "math.Copysign(0, -1)"},
+		{[]byte{0xca, 0xfe}, "[]byte{0xca, 0xfe}"},
+		{map[string]any{"b": true, "a": 0.5}, `map[string]any{"a": 0.5, "b": true}`},
+		{map[string]any{"b": []any{true, map[string]any{}}, "a": 0.5}, "map[string]any{\n\t\"a\": 0.5,\n\t\"b\": []any{\n\t\ttrue,\n\t\tmap[string]any{},\n\t},\n}"},
+	} {
+		got, err := yayfixtures.GoExpr(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("GoExpr(%#v) = %s, %v; want %s", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := yayfixtures.GoExpr(struct{}{}); err == nil {
+		t.Error("GoExpr accepted a struct")
+	}
+}
+
+// TestGenerated checks that the repository's generated fixtures are
+// current with its corpus.
+func TestGenerated(t *testing.T) {
+	testRoot := filepath.Join("..", "..", "test")
+	fixtures, err := yayfixtures.Corpus{
+		Dir:   filepath.Join(testRoot, "yay"),
+		GoDir: filepath.Join(testRoot, "go"),
+	}.Fixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := yayfixtures.Generate(&out, "yay", "fixtures", fixtures); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("..", "fixtures_gen_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("fixtures_gen_test.go is stale; run go generate")
+	}
+}
+
+func TestDecodeCorpus(t *testing.T) {
+	dir := t.TempDir()
+	for name, source := range map[string]string{
+		"port.yay":  "port: 8080\n",
+		"bad.yay":   "a:\t1\n",
+		"notes.txt": "ignored\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := (yayfixtures.Corpus{Dir: dir, Decode: true}).Fixtures(); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Fatalf("got %v, want an error naming bad", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "bad.yay")); err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := yayfixtures.Corpus{Dir: dir, Decode: true}.Fixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 1 || fixtures[0] != (yayfixtures.Fixture{Name: "port", Expr: `map[string]any{"port": big.NewInt(8080)}`}) {
+		t.Errorf("got %+v", fixtures)
+	}
+	var out bytes.Buffer
+	if err := yayfixtures.Generate(&out, "config", "golden", fixtures); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "package config\n") || !strings.Contains(out.String(), "var golden = map[string]any{") {
+		t.Errorf("got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,476 @@
+package yayschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+// schema is a compiled schema or subschema. A boolean schema is one whose
+// never is set for false, and which is otherwise empty.
+type schema struct {
+	never bool
+
+	ref *schema
+
+	types    []string // Of "null", "boolean", "object", "array", "number", "integer", "string"
+	enum     []any    // Each normalized, as normalize returns
+	cnst     any
+	hasConst bool
+
+	minimum, maximum                   *big.Rat
+	exclusiveMinimum, exclusiveMaximum *big.Rat
+	multipleOf                         *big.Rat
+
+	minLength, maxLength int // -1 if absent
+	pattern              *regexp.Regexp
+
+	prefixItems              []*schema
+	items                    *schema
+	minItems, maxItems       int
+	uniqueItems              bool
+	contains                 *schema
+	minContains, maxContains int
+
+	properties                   map[string]*schema
+	required                     []string
+	patternProperties            []patternSchema
+	additionalProperties         *schema
+	propertyNames                *schema
+	minProperties, maxProperties int
+	dependentRequired            map[string][]string
+	dependentSchemas             map[string]*schema
+
+	allOf, anyOf, oneOf []*schema
+	not                 *schema
+	ifSchema            *schema
+	thenSchema          *schema
+	elseSchema          *schema
+}
+
+type patternSchema struct {
+	pattern *regexp.Regexp
+	schema  *schema
+}
+
+// compiler compiles a schema and the subschemas its references reach,
+// each once, by its JSON Pointer, so that recursive references end.
+type compiler struct {
+	root     any
+	compiled map[string]*schema
+	anchors  map[string]string // From the name of each $anchor to its pointer
+}
+
+// unsupported lists the keywords Compile refuses rather than ignore, since
+// a schema that uses them would accept values it means to reject.
+var unsupported = []string{"unevaluatedProperties", "unevaluatedItems", "$dynamicRef", "$recursiveRef"}
+
+func (c *compiler) compile(raw any, ptr string) (*schema, error) {
+	if s, ok := c.compiled[ptr]; ok {
+		return s, nil
+	}
+	s := &schema{minLength: -1, maxLength: -1, maxItems: -1, minContains: 1, maxContains: -1, maxProperties: -1}
+	c.compiled[ptr] = s
+	if b, ok := raw.(bool); ok {
+		s.never = !b
+		return s, nil
+	}
+	m, ok := object(raw)
+	if !ok {
+		return nil, fmt.Errorf("Expected object or boolean for schema at %s, found %s", ptr, kindOf(raw))
+	}
+	for _, k := range unsupported {
+		if _, ok := m[k]; ok {
+			return nil, fmt.Errorf("Unsupported keyword %s at %s", k, ptr)
+		}
+	}
+
+	var err error
+	// sub compiles the subschema under keyword, if given.
+	sub := func(keyword string) *schema {
+		v, ok := m[keyword]
+		if !ok || err != nil {
+			return nil
+		}
+		var s *schema
+		s, err = c.compile(v, ptr+"/"+escapePointer(keyword))
+		return s
+	}
+	// subs compiles the array of subschemas under keyword, if given.
+	subs := func(keyword string) []*schema {
+		v, ok := m[keyword]
+		if !ok || err != nil {
+			return nil
+		}
+		arr, ok := v.([]any)
+		if !ok || len(arr) == 0 && keyword != "prefixItems" && keyword != "items" {
+			err = fmt.Errorf("Expected non-empty array for %s at %s", keyword, ptr)
+			return nil
+		}
+		list := make([]*schema, len(arr))
+		for i, v := range arr {
+			if list[i], err = c.compile(v, ptr+"/"+escapePointer(keyword)+"/"+strconv.Itoa(i)); err != nil {
+				return nil
+			}
+		}
+		return list
+	}
+	// subMap compiles the object of subschemas under keyword, if given.
+	subMap := func(keyword string) map[string]*schema {
+		v, ok := m[keyword]
+		if !ok || err != nil {
+			return nil
+		}
+		props, ok := object(v)
+		if !ok {
+			err = fmt.Errorf("Expected object for %s at %s", keyword, ptr)
+			return nil
+		}
+		out := make(map[string]*schema, len(props))
+		for k, v := range props {
+			if out[k], err = c.compile(v, ptr+"/"+escapePointer(keyword)+"/"+escapePointer(k)); err != nil {
+				return nil
+			}
+		}
+		return out
+	}
+	// number reads the number under keyword, if given.
+	number := func(keyword string) *big.Rat {
+		v, ok := m[keyword]
+		if !ok || err != nil {
+			return nil
+		}
+		r, ok := ratOf(v)
+		if !ok {
+			err = fmt.Errorf("Expected number for %s at %s, found %s", keyword, ptr, kindOf(v))
+		}
+		return r
+	}
+	// count reads the non-negative integer under keyword, if given, or
+	// else returns def.
+	count := func(keyword string, def int) int {
+		v, ok := m[keyword]
+		if !ok || err != nil {
+			return def
+		}
+		r, ok := ratOf(v)
+		if !ok || !r.IsInt() || r.Sign() < 0 {
+			err = fmt.Errorf("Expected non-negative integer for %s at %s, found %s", keyword, ptr, describe(normalize(v)))
+			return def
+		}
+		if !r.Num().IsInt64() || r.Num().Int64() > math.MaxInt32 {
+			return math.MaxInt32
+		}
+		return int(r.Num().Int64())
+	}
+	// stringList reads the array of strings under keyword, if given.
+	stringList := func(keyword string, v any) []string {
+		if err != nil {
+			return nil
+		}
+		var list []string
+		switch v := v.(type) {
+		case []string:
+			return v
+		case []any:
+			for _, e := range v {
+				s, ok := e.(string)
+				if !ok {
+					err = fmt.Errorf("Expected array of strings for %s at %s", keyword, ptr)
+					return nil
+				}
+				list = append(list, s)
+			}
+			return list
+		}
+		err = fmt.Errorf("Expected array of strings for %s at %s", keyword, ptr)
+		return nil
+	}
+	// compilePattern compiles a regular expression, which JSON Schema
+	// gives in the dialect of ECMAScript, as Go's RE2 if it can.
+	compilePattern := func(keyword, p string) *regexp.Regexp {
+		re, e := regexp.Compile(p)
+		if e != nil && err == nil {
+			err = fmt.Errorf("Invalid %s %q at %s (%v)", keyword, p, ptr, e)
+		}
+		return re
+	}
+
+	if v, ok := m["$ref"]; ok {
+		ref, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("Expected string for $ref at %s", ptr)
+		}
+		if s.ref, err = c.resolve(ref, ptr); err != nil {
+			return nil, err
+		}
+	}
+
+	switch v := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{v}
+	default:
+		s.types = stringList("type", v)
+	}
+	for _, t := range s.types {
+		switch t {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return nil, fmt.Errorf("Unexpected type %q at %s", t, ptr)
+		}
+	}
+	if v, ok := m["enum"]; ok {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("Expected array for enum at %s", ptr)
+		}
+		for _, e := range arr {
+			s.enum = append(s.enum, normalize(e))
+		}
+		if s.enum == nil {
+			s.enum = []any{}
+		}
+	}
+	if v, ok := m["const"]; ok {
+		s.cnst, s.hasConst = normalize(v), true
+	}
+
+	s.minimum = number("minimum")
+	s.maximum = number("maximum")
+	s.exclusiveMinimum = number("exclusiveMinimum")
+	s.exclusiveMaximum = number("exclusiveMaximum")
+	if s.multipleOf = number("multipleOf"); s.multipleOf != nil && s.multipleOf.Sign() <= 0 {
+		return nil, fmt.Errorf("Expected positive number for multipleOf at %s", ptr)
+	}
+
+	s.minLength = count("minLength", -1)
+	s.maxLength = count("maxLength", -1)
+	if v, ok := m["pattern"]; ok {
+		p, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("Expected string for pattern at %s", ptr)
+		}
+		s.pattern = compilePattern("pattern", p)
+	}
+
+	if _, ok := m["items"].([]any); ok {
+		// Draft-07 gave the schemas of leading items as an array of
+		// items, and that of the rest as additionalItems.
+		s.prefixItems = subs("items")
+		s.items = sub("additionalItems")
+	} else {
+		s.prefixItems = subs("prefixItems")
+		s.items = sub("items")
+	}
+	s.minItems = count("minItems", 0)
+	s.maxItems = count("maxItems", -1)
+	if v, ok := m["uniqueItems"]; ok {
+		if s.uniqueItems, ok = v.(bool); !ok {
+			return nil, fmt.Errorf("Expected boolean for uniqueItems at %s", ptr)
+		}
+	}
+	s.contains = sub("contains")
+	s.minContains = count("minContains", 1)
+	s.maxContains = count("maxContains", -1)
+
+	s.properties = subMap("properties")
+	if v, ok := m["required"]; ok {
+		s.required = stringList("required", v)
+	}
+	if pp := subMap("patternProperties"); len(pp) > 0 {
+		patterns := make([]string, 0, len(pp))
+		for p := range pp {
+			patterns = append(patterns, p)
+		}
+		sort.Strings(patterns)
+		for _, p := range patterns {
+			s.patternProperties = append(s.patternProperties, patternSchema{compilePattern("patternProperties", p), pp[p]})
+		}
+	}
+	s.additionalProperties = sub("additionalProperties")
+	s.propertyNames = sub("propertyNames")
+	s.minProperties = count("minProperties", 0)
+	s.maxProperties = count("maxProperties", -1)
+	if v, ok := m["dependentRequired"]; ok {
+		deps, ok := object(v)
+		if !ok {
+			return nil, fmt.Errorf("Expected object for dependentRequired at %s", ptr)
+		}
+		s.dependentRequired = map[string][]string{}
+		for k, v := range deps {
+			s.dependentRequired[k] = stringList("dependentRequired", v)
+		}
+	}
+	s.dependentSchemas = subMap("dependentSchemas")
+	if v, ok := m["dependencies"]; ok && err == nil {
+		// Draft-07 gave both dependentRequired and dependentSchemas as
+		// dependencies.
+		deps, ok := object(v)
+		if !ok {
+			return nil, fmt.Errorf("Expected object for dependencies at %s", ptr)
+		}
+		for k, v := range deps {
+			if _, ok := v.([]any); ok {
+				if s.dependentRequired == nil {
+					s.dependentRequired = map[string][]string{}
+				}
+				s.dependentRequired[k] = stringList("dependencies", v)
+				continue
+			}
+			if s.dependentSchemas == nil {
+				s.dependentSchemas = map[string]*schema{}
+			}
+			if s.dependentSchemas[k], err = c.compile(v, ptr+"/dependencies/"+escapePointer(k)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s.allOf = subs("allOf")
+	s.anyOf = subs("anyOf")
+	s.oneOf = subs("oneOf")
+	s.not = sub("not")
+	s.ifSchema = sub("if")
+	s.thenSchema = sub("then")
+	s.elseSchema = sub("else")
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// resolve compiles the schema a $ref at ptr refers to.
+func (c *compiler) resolve(ref, ptr string) (*schema, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("Unsupported $ref %q at %s (only references within the schema resolve)", ref, ptr)
+	}
+	target := ref
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		var ok bool
+		if target, ok = c.anchors[ref[1:]]; !ok {
+			return nil, fmt.Errorf("Unresolved $ref %q at %s", ref, ptr)
+		}
+	}
+	raw, ok := c.lookup(target)
+	if !ok {
+		return nil, fmt.Errorf("Unresolved $ref %q at %s", ref, ptr)
+	}
+	return c.compile(raw, target)
+}
+
+// lookup returns the value of the schema at a JSON Pointer, "#" or
+// "#/$defs/name".
+func (c *compiler) lookup(ptr string) (any, bool) {
+	v := c.root
+	if ptr == "#" {
+		return v, true
+	}
+	for _, tok := range strings.Split(ptr[2:], "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		if m, ok := object(v); ok {
+			if v, ok = m[tok]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, false
+		}
+		i, err := strconv.Atoi(tok)
+		if err != nil || i < 0 || i >= len(arr) {
+			return nil, false
+		}
+		v = arr[i]
+	}
+	return v, true
+}
+
+// findAnchors records the pointer of each $anchor in the schema at ptr
+// and its subschemas. It looks into every object and array, since
+// keywords the compiler does not know, such as $defs in draft-07 schemas
+// or definitions, may hold schemas that references reach.
+func (c *compiler) findAnchors(v any, ptr string) {
+	if m, ok := object(v); ok {
+		if a, ok := m["$anchor"].(string); ok {
+			if _, ok := c.anchors[a]; !ok {
+				c.anchors[a] = ptr
+			}
+		}
+		for k, v := range m {
+			if k == "enum" || k == "const" {
+				continue
+			}
+			c.findAnchors(v, ptr+"/"+escapePointer(k))
+		}
+	} else if arr, ok := v.([]any); ok {
+		for i, v := range arr {
+			c.findAnchors(v, ptr+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// object returns the properties of a schema object, given as a map or an
+// ordered map.
+func object(v any) (map[string]any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		return v, true
+	case *yay.OrderedMap:
+		return v.Map(), true
+	}
+	return nil, false
+}
+
+// ratOf returns the value of a number in a schema.
+func ratOf(v any) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(v), true
+	case int:
+		return big.NewRat(int64(v), 1), true
+	case int64:
+		return big.NewRat(v, 1), true
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	}
+	return nil, false
+}
+
+// kindOf names the kind of a value in a schema.
+func kindOf(v any) string {
+	if _, ok := object(v); ok {
+		return "object"
+	}
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	if _, ok := ratOf(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,51 @@
+//go:build !yay_tiny
+
+package yayschema_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/yayschema"
+)
+
+func TestJSONSchema(t *testing.T) {
+	type Config struct {
+		Port uint16  `yay:"port,min=1024"`
+		Mode string  `yay:"mode,oneof=dev|prod"`
+		Key  []byte  `yay:"key"`
+		Host *string `yay:"host,oneof=a|b"`
+	}
+	generated, err := yay.JSONSchema((*Config)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := yayschema.Compile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := "port: 80\nmode: \"prod\"\nkey: <cafe>\n"
+	got := violations(t, s.ValidateDocument([]byte(doc), yay.DecodeOptions{Filename: "app.yay"}))
+	want := []string{`.port is 80, less than the minimum 1024 at 1:7 of <app.yay>`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// The same schema, by way of JSON, has float64 numbers.
+	data, err := json.Marshal(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = yayschema.Compile(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateDocument([]byte("port: 1024\nmode: \"dev\"\nkey: <>\nhost: null\n"), yay.DecodeOptions{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,362 @@
+package yayschema
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"kriskowal.com/go/yay"
+)
+
+// maxDepth bounds the nesting of schemas a validation may pass through,
+// so that a schema that refers to itself without descending into the
+// value, such as {"$ref": "#"}, is reported rather than recursing without
+// end.
+const maxDepth = 1000
+
+// validator collects the violations of a value.
+type validator struct {
+	violations Violations
+	depth      int
+}
+
+// fail records a violation of keyword by n at path.
+func (v *validator) fail(n yay.Node, path []string, keyword, format string, args ...any) {
+	v.failAt(n.Extent().Start, path, keyword, format, args...)
+}
+
+func (v *validator) failAt(p yay.Position, path []string, keyword, format string, args ...any) {
+	v.violations = append(v.violations, &Violation{
+		Path:     formatPath(path),
+		Keyword:  keyword,
+		Message:  fmt.Sprintf(format, args...),
+		Position: p,
+	})
+}
+
+// valid reports whether n meets s, without recording its violations, as
+// for anyOf, oneOf, not, if, and contains.
+func (v *validator) valid(s *schema, n yay.Node, path []string) bool {
+	sub := validator{depth: v.depth}
+	sub.validate(s, n, path)
+	return len(sub.violations) == 0
+}
+
+// validate records each violation of s by n, the value at path.
+func (v *validator) validate(s *schema, n yay.Node, path []string) {
+	if s.never {
+		v.fail(n, path, "false", "is not allowed")
+		return
+	}
+	v.depth++
+	defer func() { v.depth-- }()
+	if v.depth > maxDepth {
+		v.fail(n, path, "$ref", "exceeds the depth of %d schemas (the schema may refer to itself without end)", maxDepth)
+		return
+	}
+	if s.ref != nil {
+		v.validate(s.ref, n, path)
+	}
+
+	if s.types != nil && !hasType(n, s.types) {
+		v.fail(n, path, "type", "is %s, not %s", kindOfNode(n), typeNames(s.types))
+	}
+	if s.enum != nil {
+		value := valueOf(n)
+		found := false
+		for _, e := range s.enum {
+			if equal(value, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(s.enum))
+			for i, e := range s.enum {
+				names[i] = describe(e)
+			}
+			v.fail(n, path, "enum", "is %s, not one of %s", describeNode(n), strings.Join(names, ", "))
+		}
+	}
+	if s.hasConst && !equal(valueOf(n), s.cnst) {
+		v.fail(n, path, "const", "is %s, not %s", describeNode(n), describe(s.cnst))
+	}
+
+	switch n := n.(type) {
+	case *yay.IntegerNode:
+		v.number(s, n, new(big.Rat).SetInt(n.Value), path)
+	case *yay.FloatNode:
+		var r *big.Rat
+		if !math.IsNaN(n.Value) && !math.IsInf(n.Value, 0) {
+			r = new(big.Rat).SetFloat64(n.Value)
+		}
+		v.number(s, n, r, path)
+	case *yay.StringNode:
+		v.string(s, n, n.Value, path)
+	case *yay.BytesNode:
+		v.string(s, n, base64.StdEncoding.EncodeToString(n.Value), path)
+	case *yay.ArrayNode:
+		v.array(s, n, path)
+	case *yay.ObjectNode:
+		v.object(s, n, path)
+	}
+
+	for _, sub := range s.allOf {
+		v.validate(sub, n, path)
+	}
+	if s.anyOf != nil {
+		matched := false
+		for _, sub := range s.anyOf {
+			if v.valid(sub, n, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(n, path, "anyOf", "matches none of the schemas of anyOf")
+		}
+	}
+	if s.oneOf != nil {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if v.valid(sub, n, path) {
+				matched++
+			}
+		}
+		if matched == 0 {
+			v.fail(n, path, "oneOf", "matches none of the schemas of oneOf")
+		} else if matched > 1 {
+			v.fail(n, path, "oneOf", "matches %d of the schemas of oneOf, not exactly one", matched)
+		}
+	}
+	if s.not != nil && v.valid(s.not, n, path) {
+		v.fail(n, path, "not", "matches the schema of not")
+	}
+	if s.ifSchema != nil {
+		if v.valid(s.ifSchema, n, path) {
+			if s.thenSchema != nil {
+				v.validate(s.thenSchema, n, path)
+			}
+		} else if s.elseSchema != nil {
+			v.validate(s.elseSchema, n, path)
+		}
+	}
+}
+
+// number checks the numeric keywords of s against r, the value of n, or
+// nil for NaN and the infinities, which meet no bound.
+func (v *validator) number(s *schema, n yay.Node, r *big.Rat, path []string) {
+	shown := describeNode(n)
+	if s.minimum != nil && (r == nil || r.Cmp(s.minimum) < 0) {
+		v.fail(n, path, "minimum", "is %s, less than the minimum %s", shown, formatRat(s.minimum))
+	}
+	if s.maximum != nil && (r == nil || r.Cmp(s.maximum) > 0) {
+		v.fail(n, path, "maximum", "is %s, more than the maximum %s", shown, formatRat(s.maximum))
+	}
+	if s.exclusiveMinimum != nil && (r == nil || r.Cmp(s.exclusiveMinimum) <= 0) {
+		v.fail(n, path, "exclusiveMinimum", "is %s, not more than the exclusive minimum %s", shown, formatRat(s.exclusiveMinimum))
+	}
+	if s.exclusiveMaximum != nil && (r == nil || r.Cmp(s.exclusiveMaximum) >= 0) {
+		v.fail(n, path, "exclusiveMaximum", "is %s, not less than the exclusive maximum %s", shown, formatRat(s.exclusiveMaximum))
+	}
+	if s.multipleOf != nil && (r == nil || !new(big.Rat).Quo(r, s.multipleOf).IsInt()) {
+		v.fail(n, path, "multipleOf", "is %s, not a multiple of %s", shown, formatRat(s.multipleOf))
+	}
+}
+
+// string checks the string keywords of s against text, the value of n,
+// counting its length in code points.
+func (v *validator) string(s *schema, n yay.Node, text string, path []string) {
+	length := utf8.RuneCountInString(text)
+	if s.minLength >= 0 && length < s.minLength {
+		v.fail(n, path, "minLength", "has length %d, less than the minimum %d", length, s.minLength)
+	}
+	if s.maxLength >= 0 && length > s.maxLength {
+		v.fail(n, path, "maxLength", "has length %d, more than the maximum %d", length, s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(text) {
+		v.fail(n, path, "pattern", "is %s, which does not match %s", describe(text), s.pattern)
+	}
+}
+
+func (v *validator) array(s *schema, n *yay.ArrayNode, path []string) {
+	if len(n.Items) < s.minItems {
+		v.fail(n, path, "minItems", "has length %d, less than the minimum %d", len(n.Items), s.minItems)
+	}
+	if s.maxItems >= 0 && len(n.Items) > s.maxItems {
+		v.fail(n, path, "maxItems", "has length %d, more than the maximum %d", len(n.Items), s.maxItems)
+	}
+	if s.uniqueItems {
+		values := make([]any, len(n.Items))
+	unique:
+		for i, item := range n.Items {
+			values[i] = valueOf(item)
+			for j := 0; j < i; j++ {
+				if equal(values[j], values[i]) {
+					v.fail(n, path, "uniqueItems", "has item [%d] equal to item [%d]", i, j)
+					break unique
+				}
+			}
+		}
+	}
+	for i, item := range n.Items {
+		itemPath := append(path[:len(path):len(path)], "["+strconv.Itoa(i)+"]")
+		if i < len(s.prefixItems) {
+			v.validate(s.prefixItems[i], item, itemPath)
+		} else if s.items != nil {
+			v.validate(s.items, item, itemPath)
+		}
+	}
+	if s.contains != nil {
+		count := 0
+		for i, item := range n.Items {
+			if v.valid(s.contains, item, append(path[:len(path):len(path)], "["+strconv.Itoa(i)+"]")) {
+				count++
+			}
+		}
+		if count < s.minContains {
+			v.fail(n, path, "contains", "has %d items matching contains, fewer than the minimum %d", count, s.minContains)
+		}
+		if s.maxContains >= 0 && count > s.maxContains {
+			v.fail(n, path, "maxContains", "has %d items matching contains, more than the maximum %d", count, s.maxContains)
+		}
+	}
+}
+
+func (v *validator) object(s *schema, n *yay.ObjectNode, path []string) {
+	if len(n.Properties) < s.minProperties {
+		v.fail(n, path, "minProperties", "has %d properties, fewer than the minimum %d", len(n.Properties), s.minProperties)
+	}
+	if s.maxProperties >= 0 && len(n.Properties) > s.maxProperties {
+		v.fail(n, path, "maxProperties", "has %d properties, more than the maximum %d", len(n.Properties), s.maxProperties)
+	}
+	has := make(map[string]bool, len(n.Properties))
+	for _, p := range n.Properties {
+		has[p.Key] = true
+	}
+	for _, k := range s.required {
+		if !has[k] {
+			v.fail(n, path, "required", "is missing required property %q", k)
+		}
+	}
+	for _, p := range n.Properties {
+		for _, k := range s.dependentRequired[p.Key] {
+			if !has[k] {
+				v.fail(n, path, "dependentRequired", "is missing property %q, which %q requires", k, p.Key)
+			}
+		}
+	}
+
+	for _, p := range n.Properties {
+		propPath := append(path[:len(path):len(path)], keyElement(p.Key))
+		if s.propertyNames != nil {
+			key := &yay.StringNode{Span: p.KeySpan, Value: p.Key}
+			v.validate(s.propertyNames, key, propPath)
+		}
+		matched := false
+		if sub, ok := s.properties[p.Key]; ok {
+			v.validate(sub, p.Value, propPath)
+			matched = true
+		}
+		for _, pp := range s.patternProperties {
+			if pp.pattern.MatchString(p.Key) {
+				v.validate(pp.schema, p.Value, propPath)
+				matched = true
+			}
+		}
+		if !matched && s.additionalProperties != nil {
+			if s.additionalProperties.never {
+				v.failAt(p.Start, propPath, "additionalProperties", "is not an allowed property")
+			} else {
+				v.validate(s.additionalProperties, p.Value, propPath)
+			}
+		}
+	}
+	for _, p := range n.Properties {
+		if sub, ok := s.dependentSchemas[p.Key]; ok {
+			v.validate(sub, n, path)
+		}
+	}
+}
+
+// hasType reports whether n is of one of the types, with byte arrays
+// strings and floats without fractions integers.
+func hasType(n yay.Node, types []string) bool {
+	for _, t := range types {
+		switch n := n.(type) {
+		case *yay.NullNode:
+			if t == "null" {
+				return true
+			}
+		case *yay.BoolNode:
+			if t == "boolean" {
+				return true
+			}
+		case *yay.StringNode, *yay.BytesNode:
+			if t == "string" {
+				return true
+			}
+		case *yay.IntegerNode:
+			if t == "integer" || t == "number" {
+				return true
+			}
+		case *yay.FloatNode:
+			if t == "number" || t == "integer" && n.Value == math.Trunc(n.Value) && !math.IsInf(n.Value, 0) {
+				return true
+			}
+		case *yay.ArrayNode:
+			if t == "array" {
+				return true
+			}
+		case *yay.ObjectNode:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kindOfNode names the kind of value of n, with its article.
+func kindOfNode(n yay.Node) string {
+	switch n.(type) {
+	case *yay.NullNode:
+		return "null"
+	case *yay.BoolNode:
+		return "a boolean"
+	case *yay.StringNode:
+		return "a string"
+	case *yay.BytesNode:
+		return "a byte array"
+	case *yay.IntegerNode:
+		return "an integer"
+	case *yay.FloatNode:
+		return "a float"
+	case *yay.ArrayNode:
+		return "an array"
+	case *yay.ObjectNode:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+// typeNames names the types of a schema, with their articles, as "an
+// integer or null".
+func typeNames(types []string) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		switch t {
+		case "null":
+			names[i] = t
+		case "integer", "object", "array":
+			names[i] = "an " + t
+		default:
+			names[i] = "a " + t
+		}
+	}
+	return strings.Join(names, " or ")
+}
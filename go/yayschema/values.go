@@ -0,0 +1,260 @@
+package yayschema
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kriskowal.com/go/yay"
+)
+
+// nodeOf returns a syntax tree for v, without positions, so that a value
+// validates as the document it was decoded from would.
+func nodeOf(v any) (yay.Node, error) {
+	switch v := v.(type) {
+	case nil:
+		return &yay.NullNode{}, nil
+	case bool:
+		return &yay.BoolNode{Value: v}, nil
+	case string:
+		return &yay.StringNode{Value: v}, nil
+	case []byte:
+		return &yay.BytesNode{Value: v}, nil
+	case *big.Int:
+		if v == nil {
+			return &yay.NullNode{}, nil
+		}
+		return &yay.IntegerNode{Value: v}, nil
+	case float64:
+		return &yay.FloatNode{Value: v}, nil
+	case json.Number:
+		if i, ok := new(big.Int).SetString(string(v), 10); ok {
+			return &yay.IntegerNode{Value: i}, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: number %s", errUnsupportedValue, v)
+		}
+		return &yay.FloatNode{Value: f}, nil
+	case []any:
+		return arrayNode(v)
+	case yay.Array:
+		return arrayNode(v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		obj := &yay.ObjectNode{Properties: make([]*yay.PropertyNode, len(keys))}
+		for i, k := range keys {
+			n, err := nodeOf(v[k])
+			if err != nil {
+				return nil, err
+			}
+			obj.Properties[i] = &yay.PropertyNode{Key: k, Value: n}
+		}
+		return obj, nil
+	case *yay.OrderedMap:
+		members := v.Members()
+		obj := &yay.ObjectNode{Properties: make([]*yay.PropertyNode, len(members))}
+		for i, m := range members {
+			n, err := nodeOf(m.Value)
+			if err != nil {
+				return nil, err
+			}
+			obj.Properties[i] = &yay.PropertyNode{Key: m.Key, Value: n}
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("%w of type %T", errUnsupportedValue, v)
+}
+
+func arrayNode(items []any) (yay.Node, error) {
+	arr := &yay.ArrayNode{Items: make([]yay.Node, len(items))}
+	for i, item := range items {
+		n, err := nodeOf(item)
+		if err != nil {
+			return nil, err
+		}
+		arr.Items[i] = n
+	}
+	return arr, nil
+}
+
+// normalize returns a value of a schema, as for enum and const, in the form
+// that valueOf gives values of a document, so that equal can compare
+// them: nil, bool, string, *big.Rat for finite numbers, float64 for the
+// others, []any, or map[string]any. Byte arrays become their base64
+// encoding, which is what they are to a schema.
+func normalize(v any) any {
+	if m, ok := object(v); ok {
+		out := make(map[string]any, len(m))
+		for k, v := range m {
+			out[k] = normalize(v)
+		}
+		return out
+	}
+	switch v := v.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, v := range v {
+			out[i] = normalize(v)
+		}
+		return out
+	case yay.Array:
+		return normalize([]any(v))
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return v
+		}
+	case nil, bool, string:
+		return v
+	}
+	if r, ok := ratOf(v); ok {
+		return r
+	}
+	return v
+}
+
+// valueOf returns the value of a node as normalize would return it.
+func valueOf(n yay.Node) any {
+	switch n := n.(type) {
+	case *yay.ObjectNode:
+		out := make(map[string]any, len(n.Properties))
+		for _, p := range n.Properties {
+			out[p.Key] = valueOf(p.Value)
+		}
+		return out
+	case *yay.ArrayNode:
+		out := make([]any, len(n.Items))
+		for i, item := range n.Items {
+			out[i] = valueOf(item)
+		}
+		return out
+	case *yay.StringNode:
+		return n.Value
+	case *yay.BytesNode:
+		return base64.StdEncoding.EncodeToString(n.Value)
+	case *yay.IntegerNode:
+		return new(big.Rat).SetInt(n.Value)
+	case *yay.FloatNode:
+		return normalize(n.Value)
+	case *yay.BoolNode:
+		return n.Value
+	}
+	return nil
+}
+
+// equal reports whether two normalized values are equal, as JSON Schema
+// compares them: numbers by value, whether written as integers or floats,
+// and objects regardless of the order of their properties.
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case *big.Rat:
+		b, ok := b.(*big.Rat)
+		return ok && a.Cmp(b) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// describe returns a short description of a normalized value for
+// messages.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if len(v) > 40 {
+			return strconv.Quote(v[:37]) + "..."
+		}
+		return strconv.Quote(v)
+	case *big.Rat:
+		return formatRat(v)
+	case float64:
+		return formatFloat(v)
+	case []any:
+		return fmt.Sprintf("an array of %d items", len(v))
+	case map[string]any:
+		return fmt.Sprintf("an object of %d properties", len(v))
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// describeNode returns a short description of the value of a node.
+func describeNode(n yay.Node) string {
+	switch n := n.(type) {
+	case *yay.ObjectNode:
+		return fmt.Sprintf("an object of %d properties", len(n.Properties))
+	case *yay.ArrayNode:
+		return fmt.Sprintf("an array of %d items", len(n.Items))
+	case *yay.BytesNode:
+		if len(n.Value) > 16 {
+			return fmt.Sprintf("%d bytes", len(n.Value))
+		}
+		return fmt.Sprintf("<%x>", n.Value)
+	case *yay.IntegerNode:
+		return n.Value.String()
+	case *yay.FloatNode:
+		return formatFloat(n.Value)
+	}
+	return describe(valueOf(n))
+}
+
+// formatRat returns a number of a schema as it would be written.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	f, _ := r.Float64()
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// formatFloat returns a float as YAY writes it.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "infinity"
+	case math.IsInf(f, -1):
+		return "-infinity"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
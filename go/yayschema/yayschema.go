@@ -0,0 +1,166 @@
+// Package yayschema validates YAY documents against JSON Schemas, such as
+// yay.JSONSchema makes, reporting each violation with the path of the
+// value and, when validating a document or its syntax tree, the line and
+// column where the value is written.
+//
+//	s, err := yayschema.Compile(schema)
+//	if err := s.ValidateDocument(data, yay.DecodeOptions{Filename: "app.yay"}); err != nil {
+//		log.Fatal(err) // .port is 80, less than the minimum 1024 at 3:7 of <app.yay>
+//	}
+//
+// Schemas follow draft 2020-12, with the keywords of draft-07 that it
+// replaced, such as "definitions" and "items" given as an array, accepted
+// too. Every validation keyword is checked but for those whose results
+// depend on the evaluation of others, unevaluatedProperties and
+// unevaluatedItems, and $dynamicRef, which Compile reports as errors
+// rather than ignore. References may point only within the schema, by
+// JSON Pointer or $anchor. The format keyword is an annotation, as the
+// draft has it by default, and is not checked.
+//
+// YAY has two kinds of value that JSON lacks. Byte arrays are strings to
+// a schema, their base64 encoding, as yay.JSONSchema describes them and
+// JSONCompatible decodes them. Integers and floats are both numbers, with
+// floats that have no fraction also integers, as in JSON Schema, and NaN
+// and the infinities numbers that meet no bound.
+package yayschema
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"kriskowal.com/go/yay"
+)
+
+// Violation is a way in which a value fails its schema.
+type Violation struct {
+	Path    string // Of the value, as ".servers[2].port", or "." for the root
+	Keyword string // Of the schema, as "minimum"
+	Message string // Following the path, as "is 80, less than the minimum 1024"
+
+	// Position is where the value begins, if validated from a document or
+	// syntax tree. Line is 0 otherwise.
+	yay.Position
+}
+
+// Error returns the violation as its path and message, followed by its
+// position if it has a filename, as yay.ParseError gives it.
+func (v *Violation) Error() string {
+	msg := v.Path + " " + v.Message
+	if v.Filename == "" || v.Line == 0 {
+		return msg
+	}
+	return msg + " at " + v.Position.String()
+}
+
+// Violations lists each way in which a value fails its schema, in the
+// order of the document. Validation returns it as its error.
+type Violations []*Violation
+
+func (l Violations) Error() string {
+	switch len(l) {
+	case 0:
+		return "No violations"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return l[0].Error() + " (and 1 more violation)"
+	}
+	return fmt.Sprintf("%s (and %d more violations)", l[0], len(l)-1)
+}
+
+// Schema is a compiled JSON Schema. It may be used by several goroutines
+// at once.
+type Schema struct {
+	root *schema
+}
+
+// Compile compiles a JSON Schema: a value of the kinds that yay.Unmarshal
+// and encoding/json decode, with objects as map[string]any or
+// *yay.OrderedMap, or as yay.JSONSchema returns. Keywords it does not
+// know are ignored, as the draft allows, but malformed keywords, the
+// keywords it does not support, and references it cannot resolve are
+// errors.
+func Compile(v any) (*Schema, error) {
+	c := &compiler{root: v, compiled: map[string]*schema{}, anchors: map[string]string{}}
+	c.findAnchors(v, "#")
+	root, err := c.compile(v, "#")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate validates v, a value such as yay.Unmarshal returns. Violations
+// give the paths of values but not their positions, which v does not
+// keep; ValidateDocument and ValidateNode give both.
+func (s *Schema) Validate(v any) error {
+	n, err := nodeOf(v)
+	if err != nil {
+		return err
+	}
+	return s.ValidateNode(n)
+}
+
+// ValidateDocument parses a YAY document according to opts and validates
+// its value, returning the error yay.Unmarshal would if it does not
+// parse, or else the Violations of the schema, if any, with positions.
+// For the few documents that parse but whose values yay.ParseAST cannot
+// place in the text, the violations have no positions.
+func (s *Schema) ValidateDocument(data []byte, opts yay.DecodeOptions) error {
+	n, err := yay.ParseASTWithOptions(data, opts)
+	if yay.ErrorCode(err) == "unplaced-value" {
+		v, err := yay.UnmarshalWithOptions(data, opts)
+		if err != nil {
+			return err
+		}
+		return s.Validate(v)
+	}
+	if err != nil {
+		return err
+	}
+	return s.ValidateNode(n)
+}
+
+// ValidateNode validates the value of n, the root of a syntax tree such
+// as yay.ParseAST returns, or a node within one, giving each violation the
+// position of its value.
+func (s *Schema) ValidateNode(n yay.Node) error {
+	var v validator
+	v.validate(s.root, n, nil)
+	if len(v.violations) > 0 {
+		return v.violations
+	}
+	return nil
+}
+
+// errUnsupportedValue is returned by Validate for values of types that
+// yay.Unmarshal does not produce.
+var errUnsupportedValue = errors.New("Unsupported value")
+
+// formatPath returns the elements of a path, each as ".key", `["odd
+// key"]`, or "[2]", joined, with "." for the root.
+func formatPath(path []string) string {
+	s := ""
+	for _, p := range path {
+		s += p
+	}
+	if s == "" || s[0] == '[' {
+		return "." + s
+	}
+	return s
+}
+
+// keyElement returns the path element for key.
+func keyElement(key string) string {
+	if key == "" || key[0] == '-' {
+		return "[" + strconv.Quote(key) + "]"
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			return "[" + strconv.Quote(key) + "]"
+		}
+	}
+	return "." + key
+}
@@ -0,0 +1,297 @@
+package yayschema_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+	"kriskowal.com/go/yay/yayschema"
+)
+
+// compile compiles a schema written as a YAY document.
+func compile(t *testing.T, text string) *yayschema.Schema {
+	t.Helper()
+	v, err := yay.Unmarshal([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := yayschema.Compile(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// violations returns the messages of the violations err lists.
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var list yayschema.Violations
+	if !errors.As(err, &list) {
+		t.Fatalf("got %v, want violations", err)
+	}
+	var got []string
+	for _, v := range list {
+		got = append(got, v.Error())
+	}
+	return got
+}
+
+func TestValidateDocument(t *testing.T) {
+	s := compile(t, `type: "object"
+required: ["name", "port"]
+properties:
+  name: {type: "string", pattern: "^[a-z]+$", maxLength: 8}
+  port: {type: "integer", minimum: 1024, maximum: 65535}
+  ratio: {type: "number", exclusiveMinimum: 0, multipleOf: 0.25}
+  mode: {enum: ["dev", "prod"]}
+  key: {type: "string", minLength: 4}
+  tags:
+    type: "array"
+    items: {type: "string"}
+    uniqueItems: true
+    maxItems: 3
+additionalProperties: false
+`)
+	for _, test := range []struct {
+		doc  string
+		want []string
+	}{
+		{
+			doc: "name: \"app\"\nport: 8080\nratio: 1.5\nmode: \"dev\"\nkey: <cafe>\ntags: [\"a\", \"b\"]\n",
+		},
+		{
+			doc: "name: \"App\"\nport: 80\n",
+			want: []string{
+				`.name is "App", which does not match ^[a-z]+$ at 1:7 of <app.yay>`,
+				`.port is 80, less than the minimum 1024 at 2:7 of <app.yay>`,
+			},
+		},
+		{
+			doc: "port: 8080.5\nratio: 0.3\nmode: \"test\"\n",
+			want: []string{
+				`. is missing required property "name" at 1:1 of <app.yay>`,
+				`.port is a float, not an integer at 1:7 of <app.yay>`,
+				`.ratio is 0.3, not a multiple of 0.25 at 2:8 of <app.yay>`,
+				`.mode is "test", not one of "dev", "prod" at 3:7 of <app.yay>`,
+			},
+		},
+		{
+			doc: "name: \"app\"\nport: 8080.0\nkey: <>\ntags:\n  - \"a\"\n  - 1\n  - \"a\"\n  - \"b\"\nextra: null\n",
+			want: []string{
+				`.key has length 0, less than the minimum 4 at 3:6 of <app.yay>`,
+				`.tags has length 4, more than the maximum 3 at 5:3 of <app.yay>`,
+				`.tags has item [2] equal to item [0] at 5:3 of <app.yay>`,
+				`.tags[1] is an integer, not a string at 6:5 of <app.yay>`,
+				`.extra is not an allowed property at 9:1 of <app.yay>`,
+			},
+		},
+		{
+			doc:  "[1, 2]\n",
+			want: []string{`. is an array, not an object at 1:1 of <app.yay>`},
+		},
+	} {
+		err := s.ValidateDocument([]byte(test.doc), yay.DecodeOptions{Filename: "app.yay"})
+		got := violations(t, err)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q:\ngot  %q\nwant %q", test.doc, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := compile(t, `"$defs":
+  node:
+    type: "object"
+    required: ["name"]
+    properties:
+      name: {type: "string"}
+      children: {type: "array", items: {"$ref": "#/$defs/node"}}
+"$ref": "#/$defs/node"
+`)
+	for _, test := range []struct {
+		doc  string
+		want []string
+	}{
+		{
+			doc: `{name: "a", children: [{name: "b", children: [{name: "c"}]}]}`,
+		},
+		{
+			doc: `{name: "a", children: [{name: "b", children: [{title: "c"}, {name: 1}]}]}`,
+			want: []string{
+				`.children[0].children[0] is missing required property "name"`,
+				`.children[0].children[1].name is an integer, not a string`,
+			},
+		},
+	} {
+		v, err := yay.Unmarshal([]byte(test.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := violations(t, s.Validate(v))
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.doc, got, test.want)
+		}
+	}
+
+	if err := s.Validate(42); err == nil {
+		t.Error("validated a value of type int")
+	}
+}
+
+func TestApplicators(t *testing.T) {
+	for _, test := range []struct {
+		schema, doc string
+		want        []string
+	}{
+		{
+			schema: `{anyOf: [{type: "string"}, {type: "integer", minimum: 0}]}`,
+			doc:    `-1`,
+			want:   []string{`. matches none of the schemas of anyOf`},
+		},
+		{
+			schema: `{oneOf: [{type: "number"}, {type: "integer"}]}`,
+			doc:    `1`,
+			want:   []string{`. matches 2 of the schemas of oneOf, not exactly one`},
+		},
+		{
+			schema: `{oneOf: [{type: "number"}, {type: "integer"}]}`,
+			doc:    `1.5`,
+		},
+		{
+			schema: `{not: {const: {a: [1, "b"]}}}`,
+			doc:    `{a: [1.0, "b"]}`,
+			want:   []string{`. matches the schema of not`},
+		},
+		{
+			schema: `{if: {properties: {tls: {const: true}}}, then: {required: ["cert"]}, else: {maxProperties: 1}}`,
+			doc:    `{tls: true}`,
+			want:   []string{`. is missing required property "cert"`},
+		},
+		{
+			schema: `{if: {properties: {tls: {const: true}}}, then: {required: ["cert"]}, else: {maxProperties: 1}}`,
+			doc:    `{tls: false, cert: "x"}`,
+			want:   []string{`. has 2 properties, more than the maximum 1`},
+		},
+		{
+			schema: `{contains: {type: "string"}, minContains: 2, prefixItems: [{type: "integer"}], items: {type: "string"}}`,
+			doc:    `[1, "a", "b", null]`,
+			want:   []string{`.[3] is null, not a string`},
+		},
+		{
+			schema: `{prefixItems: [{type: "integer"}], items: false}`,
+			doc:    `[1, 2]`,
+			want:   []string{`.[1] is not allowed`},
+		},
+		{
+			schema: `{contains: {type: "string"}, minContains: 2}`,
+			doc:    `[1, "a"]`,
+			want:   []string{`. has 1 items matching contains, fewer than the minimum 2`},
+		},
+		{
+			schema: `{propertyNames: {pattern: "^[a-z]+$"}, patternProperties: {"^x ": {type: "string"}}, dependentRequired: {cert: ["key"]}}`,
+			doc:    `{"x a": 1, Cert: "c", cert: "c"}`,
+			want: []string{
+				`. is missing property "key", which "cert" requires`,
+				`.Cert is "Cert", which does not match ^[a-z]+$`,
+				`.["x a"] is "x a", which does not match ^[a-z]+$`,
+				`.["x a"] is an integer, not a string`,
+			},
+		},
+		{
+			schema: `{type: ["integer", "null"], maximum: 10}`,
+			doc:    `infinity`,
+			want: []string{
+				`. is a float, not an integer or null`,
+				`. is infinity, more than the maximum 10`,
+			},
+		},
+		{
+			schema: `{type: "string", const: "yWs="}`,
+			doc:    `<c96b>`,
+		},
+	} {
+		s := compile(t, test.schema)
+		v, err := yay.Unmarshal([]byte(test.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := violations(t, s.Validate(v))
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s against %s:\ngot  %q\nwant %q", test.doc, test.schema, got, test.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, test := range []struct {
+		schema, want string
+	}{
+		{`{type: "text"}`, `Unexpected type "text" at #`},
+		{`{properties: {a: {minLength: -1}}}`, `Expected non-negative integer for minLength at #/properties/a, found -1`},
+		{`{"$ref": "other.json#/a"}`, `Unsupported $ref "other.json#/a" at # (only references within the schema resolve)`},
+		{`{items: {"$ref": "#/$defs/missing"}}`, `Unresolved $ref "#/$defs/missing" at #/items`},
+		{`{unevaluatedProperties: false}`, `Unsupported keyword unevaluatedProperties at #`},
+		{`{allOf: [1]}`, `Expected object or boolean for schema at #/allOf/0, found number`},
+	} {
+		v, err := yay.Unmarshal([]byte(test.schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := yayschema.Compile(v); err == nil || err.Error() != test.want {
+			t.Errorf("%s: got %v, want %s", test.schema, err, test.want)
+		}
+	}
+}
+
+func TestViolationsError(t *testing.T) {
+	s := compile(t, `{type: "object", properties: {a: {type: "string"}, b: {type: "string"}}}`)
+	err := s.ValidateDocument([]byte("a: 1\nb: 2\n"), yay.DecodeOptions{Filename: "app.yay"})
+	if want := ".a is an integer, not a string at 1:4 of <app.yay> (and 1 more violation)"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+	var list yayschema.Violations
+	if !errors.As(err, &list) || list[1].Keyword != "type" || list[1].Line != 2 || list[1].Column != 4 {
+		t.Errorf("got %#v", list)
+	}
+	if err := s.ValidateDocument([]byte("a:\t1\n"), yay.DecodeOptions{}); yay.ErrorCode(err) != "tab" {
+		t.Errorf("got %v, want the parse error", err)
+	}
+}
+
+func TestValidateDocumentLayouts(t *testing.T) {
+	s := compile(t, `{type: "array", items: {type: "array", items: {type: "integer"}}}`)
+	for _, test := range []struct {
+		doc  string
+		want []string
+	}{
+		{
+			doc:  " - - [42, 42]\n",
+			want: []string{`.[0][0] is an array, not an integer at 1:6 of <app.yay>`},
+		},
+		{
+			doc:  "  - - 42\n  - - \"hello\"\n",
+			want: []string{`.[1][0] is a string, not an integer at 2:7 of <app.yay>`},
+		},
+		{
+			// The parser reads the key, indented less than its value, as
+			// within the list, which ParseAST cannot follow.
+			doc:  "- 0:\n0:",
+			want: []string{`.[0] is an object, not an array`},
+		},
+	} {
+		err := s.ValidateDocument([]byte(test.doc), yay.DecodeOptions{Filename: "app.yay"})
+		got := violations(t, err)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q:\ngot  %q\nwant %q", test.doc, got, test.want)
+		}
+	}
+
+	s = compile(t, `{type: "array", items: {type: "integer"}}`)
+	if err := s.ValidateDocument([]byte("  - 42\n  - \"hello\"\n"), yay.DecodeOptions{}); err == nil {
+		t.Error("validated a string as an integer")
+	}
+}
@@ -0,0 +1,75 @@
+package yaytest
+
+import "strings"
+
+// Diff returns a line diff from a to b, with removed lines marked "-",
+// added lines "+", and unchanged lines indented to match. Runs of more
+// than three unchanged lines between changes are elided.
+func Diff(a, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		mark byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j]})
+			j++
+		}
+	}
+
+	// Keep unchanged lines only within three lines of a change.
+	const context = 3
+	keep := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.mark == ' ' {
+			continue
+		}
+		for n := max(k-context, 0); n <= min(k+context, len(lines)-1); n++ {
+			keep[n] = true
+		}
+	}
+
+	var out strings.Builder
+	for k, l := range lines {
+		if !keep[k] {
+			if k == 0 || keep[k-1] {
+				out.WriteString("  ...\n")
+			}
+			continue
+		}
+		out.WriteByte(l.mark)
+		out.WriteByte(' ')
+		out.WriteString(l.text)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
@@ -0,0 +1,61 @@
+package yaytest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kriskowal.com/go/yay"
+)
+
+// SnapshotDir is the directory, relative to the package under test, where
+// Snapshot keeps its files.
+const SnapshotDir = "testdata/snapshots"
+
+// Snapshot fails the test unless value, written as canonical YAY, matches
+// the snapshot stored for this test under name, reporting a line diff if
+// not. Snapshots are kept at testdata/snapshots/TEST/NAME.yay, where TEST
+// is the test's name, subtests included. When updating, Snapshot writes
+// the snapshot instead.
+//
+// Unlike RequireEqualYAY, Snapshot compares text, so a change in how a
+// value is laid out fails too.
+func Snapshot(t testing.TB, name string, value any) {
+	t.Helper()
+	got, err := yay.Marshal(value)
+	if err != nil {
+		t.Fatalf("Cannot encode snapshot %s: %v", name, err)
+	}
+	path := snapshotPath(t.Name(), name)
+	if Updating() {
+		writeFile(t, path, got)
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with YAYTEST_UPDATE=1 to create it)", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Snapshot %s differs (-want +got):\n%s", path, Diff(string(want), string(got)))
+	}
+}
+
+// snapshotPath returns the file for snapshot name of test. Characters
+// that are awkward in file names become underscores; the slashes that
+// separate subtests become directories.
+func snapshotPath(test, name string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == '/':
+				return r
+			}
+			return '_'
+		}, s)
+	}
+	parts := strings.Split(clean(test), "/")
+	parts = append([]string{SnapshotDir}, parts...)
+	parts = append(parts, strings.ReplaceAll(clean(name), "/", "_")+".yay")
+	return filepath.Join(parts...)
+}
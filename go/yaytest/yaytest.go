@@ -0,0 +1,77 @@
+// Package yaytest provides test helpers that keep expected values in YAY
+// files, which read and diff more kindly than JSON or Go literals.
+//
+// Expected files are rewritten from the values under test, rather than
+// compared, when the tests are run with YAYTEST_UPDATE=1 in the
+// environment, or with an -update flag that the test package declares for
+// itself:
+//
+//	YAYTEST_UPDATE=1 go test ./...
+//
+//	var update = flag.Bool("update", false, "rewrite expected files")
+package yaytest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kriskowal.com/go/yay"
+)
+
+// Updating reports whether expected files are being rewritten: whether
+// YAYTEST_UPDATE is 1, or an -update flag of the test binary is set.
+// Yaytest declares no flag of its own, which would clash with a test
+// package's.
+func Updating() bool {
+	if os.Getenv("YAYTEST_UPDATE") == "1" {
+		return true
+	}
+	f := flag.Lookup("update")
+	return f != nil && f.Value.String() == "true"
+}
+
+// RequireEqualYAY fails the test unless got equals the value in the YAY
+// file wantFile, as judged by yay.Equal. On failure it reports a line diff
+// of the two values in canonical YAY. When updating, it writes got to
+// wantFile instead, creating directories as needed.
+func RequireEqualYAY(t testing.TB, wantFile string, got any) {
+	t.Helper()
+	gotText, err := yay.Marshal(got)
+	if err != nil {
+		t.Fatalf("Cannot encode value for %s: %v", wantFile, err)
+	}
+	if Updating() {
+		writeFile(t, wantFile, gotText)
+		return
+	}
+
+	data, err := os.ReadFile(wantFile)
+	if err != nil {
+		t.Fatalf("%v (run with YAYTEST_UPDATE=1 to create it)", err)
+	}
+	want, err := yay.UnmarshalFile(data, wantFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if yay.Equal(got, want) {
+		return
+	}
+	wantText, err := yay.Marshal(want)
+	if err != nil {
+		t.Fatalf("Cannot encode value from %s: %v", wantFile, err)
+	}
+	t.Fatalf("Value differs from %s (-want +got):\n%s", wantFile, Diff(string(wantText), string(gotText)))
+}
+
+// writeFile writes data to path for an update.
+func writeFile(t testing.TB, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,140 @@
+package yaytest
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// update is declared as a test package that imports yaytest would declare
+// it, which must not clash with a flag of yaytest's own.
+var update = flag.Bool("update", false, "rewrite expected files")
+
+// recorder stands in for a testing.TB, recording the first fatal message.
+type recorder struct {
+	testing.TB
+	msg string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatal(args ...any) {
+	r.msg = fmt.Sprint(args...)
+	runtime.Goexit()
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.msg = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// record runs f against a recorder and returns the fatal message, if any.
+func record(t *testing.T, f func(testing.TB)) string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	return r.msg
+}
+
+func TestRequireEqualYAY(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "golden", "want.yay")
+	value := map[string]any{"name": "yay", "count": big.NewInt(3), "tags": []any{"a", "b"}}
+
+	if msg := record(t, func(tb testing.TB) { RequireEqualYAY(tb, file, value) }); !strings.Contains(msg, "YAYTEST_UPDATE=1") {
+		t.Errorf("missing file: got %q", msg)
+	}
+
+	t.Setenv("YAYTEST_UPDATE", "1")
+	if msg := record(t, func(tb testing.TB) { RequireEqualYAY(tb, file, value) }); msg != "" {
+		t.Fatalf("update: %s", msg)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "count: 3\nname: \"yay\"\ntags: [\"a\", \"b\"]\n"; string(data) != want {
+		t.Errorf("golden file:\ngot:  %q\nwant: %q", data, want)
+	}
+	t.Setenv("YAYTEST_UPDATE", "")
+
+	if msg := record(t, func(tb testing.TB) { RequireEqualYAY(tb, file, value) }); msg != "" {
+		t.Errorf("equal: %s", msg)
+	}
+
+	value["count"] = big.NewInt(4)
+	msg := record(t, func(tb testing.TB) { RequireEqualYAY(tb, file, value) })
+	if !strings.Contains(msg, "- count: 3\n+ count: 4\n") {
+		t.Errorf("differ: got %q", msg)
+	}
+}
+
+func TestUpdating(t *testing.T) {
+	t.Setenv("YAYTEST_UPDATE", "")
+	if Updating() {
+		t.Fatal("updating without the flag or environment")
+	}
+	flag.Set("update", "true")
+	defer flag.Set("update", "false")
+	if !*update || !Updating() {
+		t.Error("not updating with the test package's -update flag")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2\n3\n4\n5\nsix\n7\n8\n9\n10\n"
+	want := "  ...\n  3\n  4\n  5\n- 6\n+ six\n  7\n  8\n  9\n  ...\n"
+	if got := Diff(a, b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	// Snapshots live relative to the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	value := []any{"first", "second", "third", "fourth", "fifth", "sixth"}
+	t.Setenv("YAYTEST_UPDATE", "1")
+	if msg := record(t, func(tb testing.TB) { Snapshot(tb, "list", value) }); msg != "" {
+		t.Fatalf("update: %s", msg)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "snapshots", "TestSnapshot", "list.yay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "- \"first\"\n") {
+		t.Errorf("snapshot: %q", data)
+	}
+	t.Setenv("YAYTEST_UPDATE", "")
+
+	if msg := record(t, func(tb testing.TB) { Snapshot(tb, "list", value) }); msg != "" {
+		t.Errorf("equal: %s", msg)
+	}
+	value[2] = "3rd"
+	msg := record(t, func(tb testing.TB) { Snapshot(tb, "list", value) })
+	if !strings.Contains(msg, "- - \"third\"\n+ - \"3rd\"\n") {
+		t.Errorf("differ: got %q", msg)
+	}
+
+	got := snapshotPath("TestAPI/GET /users#01", "page 1/2")
+	if want := filepath.Join("testdata", "snapshots", "TestAPI", "GET_", "users_01", "page_1_2.yay"); got != want {
+		t.Errorf("snapshotPath: got %q, want %q", got, want)
+	}
+}
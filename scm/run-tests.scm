@@ -390,6 +390,9 @@
            "array-multiline-invalid-nested-space"
            "array-multiline-invalid-trailing-space"
            "array-multiline-nested-invalid-bare-word"
+           "array-multiline-nested-invalid-colon"
+           "array-multiline-nested-invalid-colon-array"
+           "array-multiline-nested-invalid-colon-space"
            "blank-line-trailing-space"
            "bytearray-block-invalid-empty-leader"
            "bytearray-block-property-invalid-same-line"